@@ -0,0 +1,63 @@
+package alpaca
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+)
+
+// Asset is the subset of GET /v2/assets the engine checks before streaming or trading a symbol.
+type Asset struct {
+	ID           string `json:"id"`
+	Symbol       string `json:"symbol"`
+	Name         string `json:"name"`
+	Exchange     string `json:"exchange"`
+	Class        string `json:"class"`
+	Status       string `json:"status"` // active or inactive
+	Tradable     bool   `json:"tradable"`
+	Marginable   bool   `json:"marginable"`
+	Shortable    bool   `json:"shortable"`
+	EasyToBorrow bool   `json:"easy_to_borrow"`
+	Fractionable bool   `json:"fractionable"`
+}
+
+// GetAssets returns the active US equities among symbols, by symbol. It lists every active US equity in
+// one request rather than one per symbol, so a long watchlist costs a single call against the rate
+// limit; symbols missing from the result are unknown or delisted.
+func (c *TradingClient) GetAssets(symbols []string) (map[string]Asset, error) {
+	if len(symbols) == 0 {
+		return map[string]Asset{}, nil
+	}
+	body, err := c.do("GET", "/v2/assets?status=active&asset_class=us_equity")
+	if err != nil {
+		return nil, err
+	}
+	var all []Asset
+	if err := json.Unmarshal(body, &all); err != nil {
+		return nil, err
+	}
+	want := make(map[string]bool, len(symbols))
+	for _, s := range symbols {
+		want[strings.ToUpper(s)] = true
+	}
+	out := make(map[string]Asset, len(symbols))
+	for _, a := range all {
+		if want[a.Symbol] {
+			out[a.Symbol] = a
+		}
+	}
+	return out, nil
+}
+
+// GetAsset returns one asset by symbol (GET /v2/assets/{symbol}).
+func (c *TradingClient) GetAsset(symbol string) (*Asset, error) {
+	body, err := c.do("GET", "/v2/assets/"+url.PathEscape(strings.ToUpper(symbol)))
+	if err != nil {
+		return nil, err
+	}
+	var out Asset
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
@@ -0,0 +1,66 @@
+package alpaca
+
+import (
+	"fmt"
+	"time"
+)
+
+// BarRangeSource fetches bars by time range (Client, or any market-data provider).
+type BarRangeSource interface {
+	GetBarsRange(symbols []string, req BarsRequest) (*BarsResponse, error)
+}
+
+// DefaultBackfillChunk is the range of one backfill request: five days of minute bars, extended hours
+// included, fit in one page.
+const DefaultBackfillChunk = 5 * 24 * time.Hour
+
+// Backfill pulls bars from req.Start up to req.End (exclusive; zero = now) one symbol at a time, in
+// chunks of chunk (0 = DefaultBackfillChunk), and hands each chunk to fn oldest first, so a long range of
+// minute bars is never held in memory at once. It stops at the first error from the source or from fn.
+func Backfill(src BarRangeSource, symbols []string, req BarsRequest, chunk time.Duration, fn func(symbol string, bars []Bar) error) error {
+	if req.Start.IsZero() {
+		return fmt.Errorf("backfill: start required")
+	}
+	if req.Timeframe == "" {
+		req.Timeframe = "1Min"
+	}
+	if chunk <= 0 {
+		chunk = DefaultBackfillChunk
+	}
+	end := req.End
+	if end.IsZero() {
+		end = time.Now()
+	}
+	req.Limit = 0
+	for _, sym := range symbols {
+		for from := req.Start; from.Before(end); from = from.Add(chunk) {
+			r := req
+			r.Start, r.End = from, from.Add(chunk)
+			if r.End.After(end) {
+				r.End = end
+			}
+			resp, err := src.GetBarsRange([]string{sym}, r)
+			if err != nil {
+				return fmt.Errorf("backfill %s from %s: %w", sym, from.Format(time.RFC3339), err)
+			}
+			var bars []Bar
+			if resp != nil {
+				bars = resp.Bars[sym]
+			}
+			// Alpaca's range end is inclusive: a bar exactly at r.End belongs to the next chunk (or, at the
+			// end of the range, is left out)
+			if n := len(bars); n > 0 {
+				if t, err := time.Parse(time.RFC3339, bars[n-1].Time); err == nil && !t.Before(r.End) {
+					bars = bars[:n-1]
+				}
+			}
+			if len(bars) == 0 {
+				continue
+			}
+			if err := fn(sym, bars); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package alpaca
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TimeframeDuration parses an Alpaca timeframe ("1Min", "5Min", "15Min", "1Hour", "1Day") into a duration.
+func TimeframeDuration(tf string) (time.Duration, error) {
+	units := []struct {
+		suffix string
+		d      time.Duration
+	}{{"Min", time.Minute}, {"T", time.Minute}, {"Hour", time.Hour}, {"H", time.Hour}, {"Day", 24 * time.Hour}, {"D", 24 * time.Hour}}
+	for _, u := range units {
+		if strings.HasSuffix(tf, u.suffix) {
+			n, err := strconv.Atoi(strings.TrimSuffix(tf, u.suffix))
+			if err != nil || n <= 0 {
+				break
+			}
+			return time.Duration(n) * u.d, nil
+		}
+	}
+	return 0, fmt.Errorf("unsupported timeframe %q", tf)
+}
+
+// barPollDelay gives Alpaca time to finalize a bar after its interval closes.
+const barPollDelay = 5 * time.Second
+
+// BarSource fetches bars from a start time until now (Client, or another market-data provider).
+type BarSource interface {
+	GetBarsSince(symbols []string, timeframe string, start time.Time) (*BarsResponse, error)
+}
+
+// BarPoller keeps recent bars fresh via REST for several timeframes so brains that operate on standard
+// bars don't have to aggregate ticks. Each timeframe polls shortly after its bars close and reports only
+// bars newer than the last one seen per symbol (the first poll reports the full lookback).
+type BarPoller struct {
+	client     BarSource
+	symbols    []string
+	timeframes []string
+	lookback   int // bars per symbol on the first poll
+
+	mu       sync.Mutex
+	lastSeen map[string]string // timeframe|symbol -> last bar time
+
+	// OnBars receives new bars (oldest first) for one symbol and timeframe.
+	OnBars func(symbol, timeframe string, bars []Bar)
+}
+
+// NewBarPoller creates a poller for the given timeframes (e.g. 1Min, 5Min, 1Hour).
+func NewBarPoller(client BarSource, symbols, timeframes []string, lookback int) *BarPoller {
+	if lookback <= 0 {
+		lookback = 50
+	}
+	return &BarPoller{
+		client:     client,
+		symbols:    symbols,
+		timeframes: timeframes,
+		lookback:   lookback,
+		lastSeen:   make(map[string]string),
+	}
+}
+
+// Run starts one polling loop per timeframe and blocks until ctx is done.
+func (bp *BarPoller) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, tf := range bp.timeframes {
+		d, err := TimeframeDuration(tf)
+		if err != nil {
+			slog.Warn("bar poller: skipping timeframe", "timeframe", tf, "err", err)
+			continue
+		}
+		wg.Add(1)
+		go func(tf string, d time.Duration) {
+			defer wg.Done()
+			bp.loop(ctx, tf, d)
+		}(tf, d)
+	}
+	wg.Wait()
+}
+
+func (bp *BarPoller) loop(ctx context.Context, tf string, d time.Duration) {
+	bp.poll(tf, d)
+	for {
+		// Wake just after the next bar boundary
+		now := time.Now()
+		next := now.Truncate(d).Add(d).Add(barPollDelay)
+		timer := time.NewTimer(next.Sub(now))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+			bp.poll(tf, d)
+		}
+	}
+}
+
+func (bp *BarPoller) poll(tf string, d time.Duration) {
+	// Calendar span covering the lookback with room for nights/weekends on intraday timeframes
+	span := time.Duration(bp.lookback) * d
+	if d < 24*time.Hour {
+		span += 4 * 24 * time.Hour
+	} else {
+		span = span*7/5 + 4*24*time.Hour
+	}
+	t0 := time.Now()
+	resp, err := bp.client.GetBarsSince(bp.symbols, tf, time.Now().Add(-span))
+	if err != nil {
+		slog.Error("bar poller fetch error", "timeframe", tf, "err", err)
+		return
+	}
+	slog.Debug("latency", "step", "alpaca_get_bars", "timeframe", tf, "ms", time.Since(t0).Milliseconds())
+	for _, sym := range bp.symbols {
+		bars := resp.Bars[sym]
+		if len(bars) > bp.lookback {
+			bars = bars[len(bars)-bp.lookback:]
+		}
+		fresh := bp.newBars(tf, sym, bars)
+		if len(fresh) > 0 && bp.OnBars != nil {
+			bp.OnBars(sym, tf, fresh)
+		}
+	}
+}
+
+// newBars returns bars after the last one reported for tf/sym and records the newest.
+// RFC3339 timestamps from Alpaca compare correctly as strings.
+func (bp *BarPoller) newBars(tf, sym string, bars []Bar) []Bar {
+	if len(bars) == 0 {
+		return nil
+	}
+	key := tf + "|" + sym
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	last := bp.lastSeen[key]
+	i := 0
+	for i < len(bars) && bars[i].Time <= last {
+		i++
+	}
+	bp.lastSeen[key] = bars[len(bars)-1].Time
+	return bars[i:]
+}
@@ -0,0 +1,57 @@
+package alpaca
+
+import (
+	"fmt"
+	"math"
+)
+
+// Beta regresses a symbol's daily log returns on a benchmark's (e.g. SPY) over the bars both have:
+// beta = cov(r_sym, r_bench) / var(r_bench). Bars are matched by timestamp, so missing days on either
+// side are skipped rather than misaligned. minReturns <= 0 defaults to 5. Returns an error wrapping
+// ErrInsufficientData when too few paired returns exist or the benchmark has no variance.
+func Beta(bars, benchmark []Bar, minReturns int) (float64, error) {
+	if minReturns <= 0 {
+		minReturns = 5
+	}
+	benchClose := make(map[string]float64, len(benchmark))
+	for _, b := range benchmark {
+		if b.Close > 0 {
+			benchClose[b.Time] = b.Close
+		}
+	}
+	var xs, ys []float64
+	for i := 1; i < len(bars); i++ {
+		prev, cur := bars[i-1], bars[i]
+		if prev.Close <= 0 || cur.Close <= 0 {
+			continue
+		}
+		bPrev, ok1 := benchClose[prev.Time]
+		bCur, ok2 := benchClose[cur.Time]
+		if !ok1 || !ok2 {
+			continue
+		}
+		xs = append(xs, math.Log(bCur/bPrev))
+		ys = append(ys, math.Log(cur.Close/prev.Close))
+	}
+	if len(xs) < minReturns {
+		return 0, fmt.Errorf("%w: %d paired returns, need %d", ErrInsufficientData, len(xs), minReturns)
+	}
+	var mx, my float64
+	for i := range xs {
+		mx += xs[i]
+		my += ys[i]
+	}
+	n := float64(len(xs))
+	mx /= n
+	my /= n
+	var cov, varX float64
+	for i := range xs {
+		dx := xs[i] - mx
+		cov += dx * (ys[i] - my)
+		varX += dx * dx
+	}
+	if varX <= 0 {
+		return 0, fmt.Errorf("%w: benchmark returns have no variance", ErrInsufficientData)
+	}
+	return cov / varX, nil
+}
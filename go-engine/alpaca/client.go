@@ -1,12 +1,12 @@
 // Package alpaca provides clients for Alpaca Market Data (REST + WebSocket) and Trading API.
-// Client: REST for news, snapshots, bars. PriceStream: WebSocket for trades/quotes. NewsStream: WebSocket for news.
-// TradingClient: REST for positions and open orders (read-only from Go; Python places orders).
+// Client: REST for news, snapshots, bars and option chains. PriceStream: WebSocket for trades/quotes. NewsStream:
+// WebSocket for news. OptionStream: WebSocket for option quotes.
+// TradingClient: REST for positions and orders (Python places orders; PlaceOrder serves the Go fallback brain).
 package alpaca
 
 import (
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"net/url"
 	"strings"
@@ -19,6 +19,8 @@ type Client struct {
 	keyID      string
 	secretKey  string
 	httpClient *http.Client
+	adjustment string // corporate action adjustment for bars requests without their own
+	retrier
 }
 
 // NewClient builds an Alpaca data API client.
@@ -30,31 +32,33 @@ func NewClient(baseURL, keyID, secretKey string) *Client {
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		retrier: retrier{api: "alpaca.data", policy: DefaultRetryPolicy},
 	}
 }
 
+// SetBarsAdjustment sets the adjustment ("raw", "split", "dividend" or "all") of bars requests that
+// don't give one; Alpaca's own default is raw. Call it before the client is in use.
+func (c *Client) SetBarsAdjustment(adjustment string) { c.adjustment = adjustment }
+
 func (c *Client) do(method, path string, params url.Values) ([]byte, error) {
 	u := c.baseURL + path
 	if len(params) > 0 {
 		u += "?" + params.Encode()
 	}
-	req, err := http.NewRequest(method, u, nil)
+	status, body, err := c.send(c.httpClient, method, func() (*http.Request, error) {
+		req, err := http.NewRequest(method, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("APCA-API-KEY-ID", c.keyID)
+		req.Header.Set("APCA-API-SECRET-KEY", c.secretKey)
+		return req, nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("APCA-API-KEY-ID", c.keyID)
-	req.Header.Set("APCA-API-SECRET-KEY", c.secretKey)
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("alpaca API %s %s: %s (status %d)", method, path, string(body), resp.StatusCode)
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("alpaca API %s %s: %s (status %d)", method, path, string(body), status)
 	}
 	return body, nil
 }
@@ -99,6 +103,43 @@ func (c *Client) GetNews(symbols []string, limit int) (*NewsResponse, error) {
 	return &out, nil
 }
 
+// GetNewsSince fetches news for symbols published since start, following next_page_token. With max > 0
+// only the newest max articles are kept. Articles are returned oldest first.
+func (c *Client) GetNewsSince(symbols []string, start time.Time, max int) ([]NewsArticle, error) {
+	params := url.Values{}
+	if len(symbols) > 0 {
+		params.Set("symbols", strings.Join(symbols, ","))
+	}
+	params.Set("start", start.UTC().Format(time.RFC3339))
+	params.Set("sort", "desc")
+	params.Set("limit", "50")
+	var out []NewsArticle
+	var err error
+	for {
+		var body []byte
+		if body, err = c.do("GET", "/v1beta1/news", params); err != nil {
+			break
+		}
+		var page NewsResponse
+		if err = json.Unmarshal(body, &page); err != nil {
+			break
+		}
+		out = append(out, page.News...)
+		if max > 0 && len(out) >= max {
+			out = out[:max]
+			break
+		}
+		if page.NextPageToken == "" {
+			break
+		}
+		params.Set("page_token", page.NextPageToken)
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out, err
+}
+
 // Snapshot is the latest trade, quote, and daily bar for a symbol.
 type Snapshot struct {
 	Symbol struct {
@@ -113,11 +154,12 @@ type Snapshot struct {
 
 // Trade is a single trade.
 type Trade struct {
-	Price  float64 `json:"p"`
-	Size   uint64  `json:"s"`
-	Time   string  `json:"t"`
-	Cond   []int   `json:"c"`
-	Exchange string `json:"x"`
+	Price    float64  `json:"p"`
+	Size     uint64   `json:"s"`
+	Time     string   `json:"t"`
+	Cond     []string `json:"c"` // SIP sale condition codes (DecodeConditions)
+	Exchange string   `json:"x"`
+	Tape     string   `json:"z"`
 }
 
 // Quote is bid/ask.
@@ -137,6 +179,7 @@ type Bar struct {
 	Close  float64 `json:"c"`
 	Volume uint64  `json:"v"`
 	Time   string  `json:"t"`
+	VWAP   float64 `json:"vw"` // volume-weighted average price over the bar
 }
 
 // GetSnapshots returns latest price (and daily bar) per symbol.
@@ -187,6 +230,9 @@ func (c *Client) GetBars(symbols []string, timeframe string, limit int) (*BarsRe
 	params.Set("symbols", strings.Join(symbols, ","))
 	params.Set("timeframe", timeframe)
 	params.Set("limit", fmt.Sprintf("%d", limit))
+	if c.adjustment != "" {
+		params.Set("adjustment", c.adjustment)
+	}
 	body, err := c.do("GET", "/v2/stocks/bars", params)
 	if err != nil {
 		return nil, err
@@ -198,3 +244,79 @@ func (c *Client) GetBars(symbols []string, timeframe string, limit int) (*BarsRe
 	return &out, nil
 }
 
+
+// GetBarsSince fetches bars from start until now for the given symbols, following next_page_token so
+// multi-symbol requests are complete (Alpaca's limit applies per page across all symbols).
+func (c *Client) GetBarsSince(symbols []string, timeframe string, start time.Time) (*BarsResponse, error) {
+	return c.GetBarsRange(symbols, BarsRequest{Timeframe: timeframe, Start: start})
+}
+
+// BarsRequest selects bars by time range. Zero fields use Alpaca's defaults (End: now; Adjustment: raw;
+// Feed: the account's default).
+type BarsRequest struct {
+	Timeframe  string // e.g. 1Min, 15Min, 1Hour, 1Day; default 1Day
+	Start      time.Time
+	End        time.Time
+	Limit      int    // total bars per symbol across pages; 0 = all in the range
+	Adjustment string // "raw", "split", "dividend" or "all"; default SetBarsAdjustment
+	Feed       string // "sip" or "iex"
+}
+
+// GetBarsRange fetches bars between req.Start and req.End for the given symbols, oldest first, following
+// next_page_token.
+func (c *Client) GetBarsRange(symbols []string, req BarsRequest) (*BarsResponse, error) {
+	if len(symbols) == 0 {
+		return nil, nil
+	}
+	if req.Timeframe == "" {
+		req.Timeframe = "1Day"
+	}
+	out := &BarsResponse{Bars: make(map[string][]Bar)}
+	params := url.Values{}
+	params.Set("symbols", strings.Join(symbols, ","))
+	params.Set("timeframe", req.Timeframe)
+	params.Set("start", req.Start.UTC().Format(time.RFC3339))
+	if !req.End.IsZero() {
+		params.Set("end", req.End.UTC().Format(time.RFC3339))
+	}
+	if req.Adjustment == "" {
+		req.Adjustment = c.adjustment
+	}
+	if req.Adjustment != "" {
+		params.Set("adjustment", req.Adjustment)
+	}
+	if req.Feed != "" {
+		params.Set("feed", req.Feed)
+	}
+	params.Set("limit", "10000")
+	for {
+		body, err := c.do("GET", "/v2/stocks/bars", params)
+		if err != nil {
+			return nil, err
+		}
+		var page BarsResponse
+		if err := json.Unmarshal(body, &page); err != nil {
+			return nil, err
+		}
+		for sym, bars := range page.Bars {
+			out.Bars[sym] = append(out.Bars[sym], bars...)
+			if req.Limit > 0 && len(out.Bars[sym]) > req.Limit {
+				out.Bars[sym] = out.Bars[sym][:req.Limit]
+			}
+		}
+		// With a limit, stop once every symbol has it
+		done := page.NextPageToken == ""
+		if req.Limit > 0 && !done {
+			done = true
+			for _, sym := range symbols {
+				if len(out.Bars[sym]) < req.Limit {
+					done = false
+				}
+			}
+		}
+		if done {
+			return out, nil
+		}
+		params.Set("page_token", page.NextPageToken)
+	}
+}
@@ -0,0 +1,100 @@
+package alpaca
+
+// TradeCondition is one SIP sale condition code: its name and whether a trade carrying it updates the
+// last sale price. Trades that don't (odd lots, average-price and derivatively priced trades, late and
+// out-of-sequence reports, official opens and closes, corrected closes) still count toward volume.
+type TradeCondition struct {
+	Name        string
+	UpdatesLast bool
+}
+
+// ctaConditions are the CTA codes (tapes A and B: NYSE and regional listings). Form T (extended hours)
+// counts as updating so pre- and post-market prices move, though the SIP leaves the official last alone.
+var ctaConditions = map[string]TradeCondition{
+	"@": {"regular_sale", true},
+	" ": {"regular_sale", true},
+	"B": {"average_price_trade", false},
+	"C": {"cash_trade", false},
+	"E": {"automatic_execution", true},
+	"F": {"intermarket_sweep", true},
+	"H": {"price_variation_trade", false},
+	"I": {"odd_lot_trade", false},
+	"K": {"rule_127_155_trade", true},
+	"L": {"sold_last", true},
+	"M": {"official_close", false},
+	"N": {"next_day_trade", false},
+	"O": {"opening_trade", true},
+	"P": {"prior_reference_price", false},
+	"Q": {"official_open", false},
+	"R": {"seller", false},
+	"T": {"extended_hours_trade", true},
+	"U": {"extended_hours_sold_out_of_sequence", false},
+	"V": {"contingent_trade", false},
+	"X": {"cross_trade", true},
+	"Z": {"sold_out_of_sequence", false},
+	"4": {"derivatively_priced", false},
+	"5": {"reopening_trade", true},
+	"6": {"closing_trade", true},
+	"7": {"qualified_contingent_trade", false},
+	"9": {"corrected_consolidated_close", false},
+}
+
+// utpConditions are the UTP codes (tape C: Nasdaq listings). Form T is treated as for CTA.
+var utpConditions = map[string]TradeCondition{
+	"@": {"regular_sale", true},
+	" ": {"regular_sale", true},
+	"A": {"acquisition", true},
+	"B": {"bunched_trade", true},
+	"C": {"cash_sale", false},
+	"D": {"distribution", true},
+	"F": {"intermarket_sweep", true},
+	"G": {"bunched_sold_trade", false},
+	"H": {"price_variation_trade", false},
+	"I": {"odd_lot_trade", false},
+	"K": {"rule_155_trade", true},
+	"L": {"sold_last", true},
+	"M": {"official_close", false},
+	"N": {"next_day", false},
+	"O": {"opening_prints", true},
+	"P": {"prior_reference_price", false},
+	"Q": {"official_open", false},
+	"R": {"seller", false},
+	"S": {"split_trade", true},
+	"T": {"form_t", true},
+	"U": {"extended_hours_sold_out_of_sequence", false},
+	"V": {"contingent_trade", false},
+	"W": {"average_price_trade", false},
+	"X": {"cross_trade", true},
+	"Y": {"yellow_flag_regular_trade", true},
+	"Z": {"sold_out_of_sequence", false},
+	"1": {"stopped_stock", true},
+	"4": {"derivatively_priced", false},
+	"5": {"reopening_prints", true},
+	"6": {"closing_prints", true},
+	"7": {"qualified_contingent_trade", false},
+	"8": {"placeholder_611_exempt", true},
+	"9": {"corrected_consolidated_close", false},
+}
+
+// DecodeConditions names a trade's condition codes on its tape (C = UTP; A, B and anything else = CTA)
+// and reports whether the trade updates the last sale price: false if any of its conditions doesn't.
+// Unknown codes keep the code as their name and don't stop an update.
+func DecodeConditions(tape string, codes []string) (names []string, updatesLast bool) {
+	table := ctaConditions
+	if tape == "C" {
+		table = utpConditions
+	}
+	updatesLast = true
+	for _, c := range codes {
+		tc, ok := table[c]
+		if !ok {
+			names = append(names, c)
+			continue
+		}
+		names = append(names, tc.Name)
+		if !tc.UpdatesLast {
+			updatesLast = false
+		}
+	}
+	return names, updatesLast
+}
@@ -0,0 +1,98 @@
+package alpaca
+
+import (
+	"encoding/json"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Corporate action types from /v1/corporate-actions.
+const (
+	ActionForwardSplit = "forward_split"
+	ActionReverseSplit = "reverse_split"
+	ActionCashDividend = "cash_dividend"
+)
+
+// CorporateAction is one split or dividend. Dates are YYYY-MM-DD; ExDate is the first day prices trade
+// adjusted (split) or without the dividend.
+type CorporateAction struct {
+	Type        string  `json:"-"`
+	Symbol      string  `json:"symbol"`
+	ExDate      string  `json:"ex_date"`
+	RecordDate  string  `json:"record_date"`
+	PayableDate string  `json:"payable_date"`
+	ProcessDate string  `json:"process_date"`
+	OldRate     float64 `json:"old_rate"` // splits: OldRate shares become NewRate shares
+	NewRate     float64 `json:"new_rate"`
+	Rate        float64 `json:"rate"` // cash dividends: per share
+	Special     bool    `json:"special"`
+	Foreign     bool    `json:"foreign"`
+}
+
+// SplitRatio is NewRate/OldRate for splits (10 for a 10-for-1), 0 otherwise.
+func (a CorporateAction) SplitRatio() float64 {
+	if a.OldRate <= 0 {
+		return 0
+	}
+	return a.NewRate / a.OldRate
+}
+
+// corporateActionsResponse is a page of /v1/corporate-actions: one list per type.
+type corporateActionsResponse struct {
+	CorporateActions struct {
+		ForwardSplits []CorporateAction `json:"forward_splits"`
+		ReverseSplits []CorporateAction `json:"reverse_splits"`
+		CashDividends []CorporateAction `json:"cash_dividends"`
+	} `json:"corporate_actions"`
+	NextPageToken string `json:"next_page_token"`
+}
+
+// GetCorporateActions returns the splits and cash dividends of symbols with an ex date between start
+// and end (dates, inclusive), ordered by ex date, following next_page_token.
+func (c *Client) GetCorporateActions(symbols []string, start, end time.Time) ([]CorporateAction, error) {
+	if len(symbols) == 0 {
+		return nil, nil
+	}
+	params := url.Values{}
+	params.Set("symbols", strings.Join(symbols, ","))
+	params.Set("types", strings.Join([]string{ActionForwardSplit, ActionReverseSplit, ActionCashDividend}, ","))
+	params.Set("start", start.Format("2006-01-02"))
+	params.Set("end", end.Format("2006-01-02"))
+	params.Set("limit", "1000")
+	var out []CorporateAction
+	for {
+		body, err := c.do("GET", "/v1/corporate-actions", params)
+		if err != nil {
+			return nil, err
+		}
+		var page corporateActionsResponse
+		if err := json.Unmarshal(body, &page); err != nil {
+			return nil, err
+		}
+		ca := page.CorporateActions
+		for typ, list := range map[string][]CorporateAction{
+			ActionForwardSplit: ca.ForwardSplits, ActionReverseSplit: ca.ReverseSplits, ActionCashDividend: ca.CashDividends,
+		} {
+			for _, a := range list {
+				a.Type = typ
+				out = append(out, a)
+			}
+		}
+		if page.NextPageToken == "" {
+			break
+		}
+		params.Set("page_token", page.NextPageToken)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].ExDate != out[j].ExDate {
+			return out[i].ExDate < out[j].ExDate
+		}
+		if out[i].Symbol != out[j].Symbol {
+			return out[i].Symbol < out[j].Symbol
+		}
+		return out[i].Type < out[j].Type
+	})
+	return out, nil
+}
@@ -0,0 +1,89 @@
+package alpaca
+
+import (
+	"math"
+	"sort"
+)
+
+// Correlation is the Pearson correlation of log returns for one symbol pair over N shared bars.
+type Correlation struct {
+	A    string  `json:"a"`
+	B    string  `json:"b"`
+	Corr float64 `json:"corr"`
+	N    int     `json:"n"` // paired returns used
+}
+
+// logReturnsByTime maps each bar's timestamp to its log return from the previous bar.
+func logReturnsByTime(bars []Bar) map[string]float64 {
+	out := make(map[string]float64, len(bars))
+	for i := 1; i < len(bars); i++ {
+		if bars[i-1].Close <= 0 || bars[i].Close <= 0 {
+			continue
+		}
+		out[bars[i].Time] = math.Log(bars[i].Close / bars[i-1].Close)
+	}
+	return out
+}
+
+// CorrelationMatrix computes pairwise return correlations for symbols from bars (daily or intraday,
+// oldest first). Returns are matched by bar timestamp. Pairs with fewer than minReturns shared returns
+// (default 5) or zero variance are omitted. Output is ordered by symbol order then partner.
+func CorrelationMatrix(bars map[string][]Bar, symbols []string, minReturns int) []Correlation {
+	if minReturns <= 0 {
+		minReturns = 5
+	}
+	rets := make(map[string]map[string]float64, len(symbols))
+	for _, sym := range symbols {
+		rets[sym] = logReturnsByTime(bars[sym])
+	}
+	var out []Correlation
+	for i := 0; i < len(symbols); i++ {
+		ra := rets[symbols[i]]
+		if len(ra) < minReturns {
+			continue
+		}
+		for j := i + 1; j < len(symbols); j++ {
+			rb := rets[symbols[j]]
+			if len(rb) < minReturns {
+				continue
+			}
+			if c, n, ok := pearson(ra, rb); ok && n >= minReturns {
+				out = append(out, Correlation{A: symbols[i], B: symbols[j], Corr: c, N: n})
+			}
+		}
+	}
+	return out
+}
+
+// pearson correlates two return series over their shared timestamps.
+func pearson(a, b map[string]float64) (corr float64, n int, ok bool) {
+	keys := make([]string, 0, len(a))
+	for k := range a {
+		if _, found := b[k]; found {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) < 2 {
+		return 0, len(keys), false
+	}
+	sort.Strings(keys)
+	var ma, mb float64
+	for _, k := range keys {
+		ma += a[k]
+		mb += b[k]
+	}
+	fn := float64(len(keys))
+	ma /= fn
+	mb /= fn
+	var cov, va, vb float64
+	for _, k := range keys {
+		da, db := a[k]-ma, b[k]-mb
+		cov += da * db
+		va += da * da
+		vb += db * db
+	}
+	if va <= 0 || vb <= 0 {
+		return 0, len(keys), false
+	}
+	return cov / math.Sqrt(va*vb), len(keys), true
+}
@@ -0,0 +1,193 @@
+package alpaca
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FeedStats is one feed's activity for a symbol over a comparison window. Lag is receive time minus the
+// exchange timestamp, so it includes clock skew; compare it across feeds rather than reading it alone.
+type FeedStats struct {
+	Trades       int     `json:"trades"`
+	Quotes       int     `json:"quotes"`
+	Volume       int64   `json:"volume"`
+	LagMsAvg     float64 `json:"lag_ms_avg"`
+	LagMsMax     float64 `json:"lag_ms_max"`
+	SpreadBpsAvg float64 `json:"spread_bps_avg"`
+}
+
+// FeedDivergence compares the IEX and SIP feeds for one symbol over a window. Mid differences are sampled
+// on every quote from either feed once both have quoted.
+type FeedDivergence struct {
+	Symbol        string    `json:"symbol"`
+	IEX           FeedStats `json:"iex"`
+	SIP           FeedStats `json:"sip"`
+	LagMsDiff     float64   `json:"lag_ms_diff"`      // IEX average lag minus SIP's; > 0 = IEX arrives later
+	MidDiffBpsAvg float64   `json:"mid_diff_bps_avg"` // mean |IEX mid - SIP mid| in bps of the SIP mid
+	MidDiffBpsMax float64   `json:"mid_diff_bps_max"`
+	SameQuotePct  float64   `json:"same_quote_pct"` // percent of samples where the IEX bid and ask equal the NBBO
+	Samples       int       `json:"samples"`
+	VolumeShare   float64   `json:"volume_share"` // IEX volume / SIP volume
+}
+
+// FeedCompare collects trades and quotes for a sample of symbols from an IEX and a SIP stream running in
+// parallel and reports how far the feeds diverge. Safe for concurrent use.
+type FeedCompare struct {
+	mu      sync.Mutex
+	symbols map[string]*feedPair
+}
+
+type feedPair struct {
+	feeds      [2]feedSide // 0 = iex, 1 = sip
+	midDiffSum float64
+	midDiffMax float64
+	same       int
+	samples    int
+}
+
+type feedSide struct {
+	trades    int
+	quotes    int
+	volume    int64
+	lagSum    time.Duration
+	lagN      int
+	lagMax    time.Duration
+	spreadSum float64
+	bid, ask  float64
+}
+
+// NewFeedCompare compares the feeds for symbols; other symbols are ignored.
+func NewFeedCompare(symbols []string) *FeedCompare {
+	c := &FeedCompare{symbols: make(map[string]*feedPair, len(symbols))}
+	for _, s := range symbols {
+		c.symbols[strings.ToUpper(s)] = &feedPair{}
+	}
+	return c
+}
+
+// Symbols returns the sampled symbols, sorted.
+func (c *FeedCompare) Symbols() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make([]string, 0, len(c.symbols))
+	for s := range c.symbols {
+		out = append(out, s)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// side returns the pair and feed index for a feed name ("iex" or "sip"); the pair is nil if symbol is not
+// sampled. Call with mu held.
+func (c *FeedCompare) side(feed, symbol string) (*feedPair, int) {
+	p := c.symbols[symbol]
+	if strings.EqualFold(feed, "iex") {
+		return p, 0
+	}
+	return p, 1
+}
+
+// Trade records a trade print from feed, received at recv.
+func (c *FeedCompare) Trade(feed, symbol string, size int, t, recv time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p, i := c.side(feed, symbol)
+	if p == nil {
+		return
+	}
+	f := &p.feeds[i]
+	f.trades++
+	f.volume += int64(size)
+	f.lag(t, recv)
+}
+
+// Quote records a quote from feed, received at recv, and samples the mid difference when both feeds have
+// quoted.
+func (c *FeedCompare) Quote(feed, symbol string, bid, ask float64, t, recv time.Time) {
+	if bid <= 0 || ask <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p, i := c.side(feed, symbol)
+	if p == nil {
+		return
+	}
+	f := &p.feeds[i]
+	f.quotes++
+	f.bid, f.ask = bid, ask
+	f.spreadSum += (ask - bid) / ((ask + bid) / 2) * 10000
+	f.lag(t, recv)
+	iex, sip := &p.feeds[0], &p.feeds[1]
+	if iex.bid <= 0 || sip.bid <= 0 {
+		return
+	}
+	sipMid := (sip.bid + sip.ask) / 2
+	diff := math.Abs((iex.bid+iex.ask)/2-sipMid) / sipMid * 10000
+	p.midDiffSum += diff
+	p.midDiffMax = math.Max(p.midDiffMax, diff)
+	if iex.bid == sip.bid && iex.ask == sip.ask {
+		p.same++
+	}
+	p.samples++
+}
+
+func (f *feedSide) lag(t, recv time.Time) {
+	if t.IsZero() {
+		return
+	}
+	d := recv.Sub(t)
+	f.lagSum += d
+	f.lagN++
+	if d > f.lagMax {
+		f.lagMax = d
+	}
+}
+
+func (f *feedSide) stats() FeedStats {
+	s := FeedStats{Trades: f.trades, Quotes: f.quotes, Volume: f.volume, LagMsMax: ms(f.lagMax)}
+	if f.lagN > 0 {
+		s.LagMsAvg = ms(f.lagSum) / float64(f.lagN)
+	}
+	if f.quotes > 0 {
+		s.SpreadBpsAvg = f.spreadSum / float64(f.quotes)
+	}
+	return s
+}
+
+func ms(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+
+// Report returns the divergence per sampled symbol that had activity since the last Report, sorted by
+// symbol, and starts a new window. The last quote of each feed carries over so sampling resumes at once.
+func (c *FeedCompare) Report() []FeedDivergence {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var out []FeedDivergence
+	for sym, p := range c.symbols {
+		iex, sip := p.feeds[0].stats(), p.feeds[1].stats()
+		if iex.Trades+iex.Quotes+sip.Trades+sip.Quotes > 0 {
+			d := FeedDivergence{Symbol: sym, IEX: iex, SIP: sip, MidDiffBpsMax: p.midDiffMax, Samples: p.samples}
+			if p.feeds[0].lagN > 0 && p.feeds[1].lagN > 0 {
+				d.LagMsDiff = iex.LagMsAvg - sip.LagMsAvg
+			}
+			if p.samples > 0 {
+				d.MidDiffBpsAvg = p.midDiffSum / float64(p.samples)
+				d.SameQuotePct = float64(p.same) / float64(p.samples) * 100
+			}
+			if sip.Volume > 0 {
+				d.VolumeShare = float64(iex.Volume) / float64(sip.Volume)
+			}
+			out = append(out, d)
+		}
+		next := &feedPair{}
+		for i := range p.feeds {
+			next.feeds[i].bid, next.feeds[i].ask = p.feeds[i].bid, p.feeds[i].ask
+		}
+		c.symbols[sym] = next
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Symbol < out[j].Symbol })
+	return out
+}
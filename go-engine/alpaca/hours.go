@@ -0,0 +1,289 @@
+package alpaca
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sunnyp94/sentry-bridge/go-engine/clock"
+)
+
+// Clock is GET /v2/clock: whether the regular session is open and the next open/close.
+type Clock struct {
+	Timestamp time.Time `json:"timestamp"`
+	IsOpen    bool      `json:"is_open"`
+	NextOpen  time.Time `json:"next_open"`
+	NextClose time.Time `json:"next_close"`
+}
+
+// GetClock returns the broker's market clock.
+func (c *TradingClient) GetClock() (*Clock, error) {
+	body, err := c.do("GET", "/v2/clock")
+	if err != nil {
+		return nil, err
+	}
+	var out Clock
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CalendarDay is one trading day from GET /v2/calendar. Open/Close are "HH:MM" ET (early closes included);
+// SessionOpen/SessionClose bound the extended-hours session as "HHMM" ET.
+type CalendarDay struct {
+	Date         string `json:"date"`
+	Open         string `json:"open"`
+	Close        string `json:"close"`
+	SessionOpen  string `json:"session_open"`
+	SessionClose string `json:"session_close"`
+}
+
+// ExtendedHours returns the start of pre-market and the end of post-market on d in loc (America/New_York).
+// Missing session bounds default to 04:00 and 20:00.
+func (d CalendarDay) ExtendedHours(loc *time.Location) (open, close time.Time, err error) {
+	day, err := time.ParseInLocation("2006-01-02", d.Date, loc)
+	if err != nil {
+		return open, close, fmt.Errorf("calendar date %q: %w", d.Date, err)
+	}
+	at := func(hhmm, def string) (time.Time, error) {
+		hhmm = strings.ReplaceAll(hhmm, ":", "")
+		if hhmm == "" {
+			hhmm = def
+		}
+		t, err := time.Parse("1504", hhmm)
+		if err != nil {
+			return t, fmt.Errorf("calendar %s session time %q: %w", d.Date, hhmm, err)
+		}
+		return time.Date(day.Year(), day.Month(), day.Day(), t.Hour(), t.Minute(), 0, 0, loc), nil
+	}
+	if open, err = at(d.SessionOpen, "0400"); err != nil {
+		return open, close, err
+	}
+	close, err = at(d.SessionClose, "2000")
+	return open, close, err
+}
+
+// GetCalendar returns trading days between start and end (inclusive, "YYYY-MM-DD").
+func (c *TradingClient) GetCalendar(start, end string) ([]CalendarDay, error) {
+	params := url.Values{}
+	params.Set("start", start)
+	params.Set("end", end)
+	body, err := c.do("GET", "/v2/calendar?"+params.Encode())
+	if err != nil {
+		return nil, err
+	}
+	var out []CalendarDay
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Order sessions as seen by the hours guard.
+const (
+	SessionRegular    = "regular"
+	SessionPreMarket  = "pre_market"
+	SessionAfterHours = "after_hours"
+	SessionClosed     = "closed"
+)
+
+// Hours guard modes.
+const (
+	GuardOff     = "off"     // pass orders through unchanged
+	GuardBlock   = "block"   // reject orders the current session won't accept as-is
+	GuardConvert = "convert" // fix what can be fixed (mark extended_hours, TIF day), reject the rest
+)
+
+// ErrOutsideTradingHours is returned (wrapped) when the guard refuses an order for the current session.
+var ErrOutsideTradingHours = errors.New("order not allowed in current session")
+
+// Extended-hours window around the regular session (ET).
+const (
+	preMarketStart  = 4 * time.Hour
+	afterHoursClose = 20 * time.Hour
+)
+
+// clockTTL bounds how stale the cached market clock may be.
+const clockTTL = 30 * time.Second
+
+// OrderPlacer submits orders. TradingClient and HoursGuard both implement it.
+type OrderPlacer interface {
+	PlaceOrder(req OrderRequest) (*Order, error)
+}
+
+// OrderChecker is implemented by order guards that can evaluate an order without submitting it. It
+// returns the order as it would be forwarded (a guard may adjust it) or the error PlaceOrder would give.
+type OrderChecker interface {
+	CheckOrder(req OrderRequest) (OrderRequest, error)
+}
+
+// CheckOrder runs req through p's checks without submitting it. Placers without checks (the broker
+// client) accept it unchanged.
+func CheckOrder(p OrderPlacer, req OrderRequest) (OrderRequest, error) {
+	if c, ok := p.(OrderChecker); ok {
+		return c.CheckOrder(req)
+	}
+	return req, nil
+}
+
+// HoursGuard wraps an order placer and checks each order against the live market clock before it
+// reaches the broker: market orders are refused outside the regular session, and limit orders in pre-
+// market/after-hours must be extended_hours day orders (set automatically in convert mode).
+type HoursGuard struct {
+	client *TradingClient
+	next   OrderPlacer
+	mode   string
+	now    clock.Clock
+
+	mu      sync.Mutex
+	clock   *Clock
+	clockAt time.Time
+	calDate string
+	calDay  *CalendarDay // nil = not a trading day
+	eastern *time.Location
+}
+
+// NewHoursGuard wraps next (usually client itself) using client for clock/calendar lookups.
+func NewHoursGuard(client *TradingClient, next OrderPlacer, mode string) *HoursGuard {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		loc = time.FixedZone("EST", -5*3600)
+	}
+	return &HoursGuard{client: client, next: next, mode: mode, now: clock.Real{}, eastern: loc}
+}
+
+// SetClock replaces the clock that decides the current session. Call before use.
+func (g *HoursGuard) SetClock(c clock.Clock) { g.now = c }
+
+// PlaceOrder checks (and in convert mode adjusts) req for the current session, then forwards it.
+func (g *HoursGuard) PlaceOrder(req OrderRequest) (*Order, error) {
+	if g.mode == GuardOff {
+		return g.next.PlaceOrder(req)
+	}
+	session, err := g.Session(g.now.Now())
+	if err != nil {
+		return nil, fmt.Errorf("hours guard: %w", err)
+	}
+	req, err = g.check(req, session)
+	if err != nil {
+		return nil, err
+	}
+	return g.next.PlaceOrder(req)
+}
+
+// CheckOrder applies the session rules for the current session, then the checks further down the chain.
+func (g *HoursGuard) CheckOrder(req OrderRequest) (OrderRequest, error) {
+	if g.mode != GuardOff {
+		session, err := g.Session(g.now.Now())
+		if err != nil {
+			return req, fmt.Errorf("hours guard: %w", err)
+		}
+		if req, err = g.check(req, session); err != nil {
+			return req, err
+		}
+	}
+	return CheckOrder(g.next, req)
+}
+
+// check applies the session rules to req.
+func (g *HoursGuard) check(req OrderRequest, session string) (OrderRequest, error) {
+	typ := strings.ToLower(req.Type)
+	if typ == "" {
+		typ = "market"
+	}
+	tif := strings.ToLower(req.TimeInForce)
+	if tif == "" {
+		tif = "day"
+	}
+	refuse := func(why string) (OrderRequest, error) {
+		return req, fmt.Errorf("%w: %s %s %s in %s: %s", ErrOutsideTradingHours, req.Side, typ, req.Symbol, session, why)
+	}
+	switch session {
+	case SessionRegular:
+		if req.ExtendedHours && typ != "limit" {
+			if g.mode == GuardBlock {
+				return refuse("extended_hours requires a limit order")
+			}
+			req.ExtendedHours = false
+		}
+		return req, nil
+	case SessionPreMarket, SessionAfterHours:
+		if req.OrderClass != "" && req.OrderClass != "simple" {
+			return refuse(req.OrderClass + " orders trade in regular hours only")
+		}
+		if typ != "limit" {
+			return refuse("only limit orders trade outside regular hours")
+		}
+		if !req.ExtendedHours || tif != "day" {
+			if g.mode == GuardBlock {
+				return refuse("needs extended_hours=true and time_in_force=day")
+			}
+			req.ExtendedHours = true
+			req.TimeInForce = "day"
+		}
+		return req, nil
+	default:
+		return refuse("market closed")
+	}
+}
+
+// Session returns the current order session from the broker clock and today's calendar (cached).
+func (g *HoursGuard) Session(now time.Time) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.clock == nil || now.Sub(g.clockAt) > clockTTL {
+		clk, err := g.client.GetClock()
+		if err != nil {
+			return "", err
+		}
+		g.clock, g.clockAt = clk, now
+	}
+	if g.clock.IsOpen {
+		return SessionRegular, nil
+	}
+	et := now.In(g.eastern)
+	date := et.Format("2006-01-02")
+	if g.calDate != date {
+		days, err := g.client.GetCalendar(date, date)
+		if err != nil {
+			return "", err
+		}
+		g.calDate, g.calDay = date, nil
+		for i := range days {
+			if days[i].Date == date {
+				g.calDay = &days[i]
+			}
+		}
+	}
+	if g.calDay == nil {
+		return SessionClosed, nil
+	}
+	midnight := time.Date(et.Year(), et.Month(), et.Day(), 0, 0, 0, 0, g.eastern)
+	sinceMidnight := et.Sub(midnight)
+	open, errOpen := parseHHMM(g.calDay.Open)
+	closeAt, errClose := parseHHMM(g.calDay.Close)
+	if errOpen != nil || errClose != nil {
+		return SessionClosed, nil
+	}
+	switch {
+	case sinceMidnight >= preMarketStart && sinceMidnight < open:
+		return SessionPreMarket, nil
+	case sinceMidnight >= closeAt && sinceMidnight < afterHoursClose:
+		return SessionAfterHours, nil
+	}
+	return SessionClosed, nil
+}
+
+// parseHHMM parses "HH:MM" as a duration since midnight.
+func parseHHMM(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
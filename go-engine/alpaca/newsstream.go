@@ -5,10 +5,19 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/sunnyp94/sentry-bridge/go-engine/telemetry"
 )
 
+// NewsHandlers are a news stream's callbacks (all optional).
+type NewsHandlers struct {
+	OnNews func(article NewsArticle)
+	// OnConnect runs after every successful connect and subscription, before articles are read.
+	OnConnect func()
+}
+
 // NewsStream connects to Alpaca's news WebSocket for real-time headlines.
 type NewsStream struct {
 	baseURL   string
@@ -16,7 +25,9 @@ type NewsStream struct {
 	secretKey string
 	symbols   []string // empty or ["*"] = all news
 
-	OnNews func(article NewsArticle)
+	closer StreamCloser
+
+	NewsHandlers
 }
 
 // NewNewsStream creates a stream for v1beta1/news.
@@ -29,7 +40,11 @@ func NewNewsStream(streamBaseURL, keyID, secretKey string, symbols []string) *Ne
 	}
 }
 
-// Run connects, authenticates, subscribes to news, and processes messages until connection fails.
+// Close ends Run and keeps the stream from connecting again.
+func (n *NewsStream) Close() error { return n.closer.Close() }
+
+// Run connects, authenticates, subscribes to news, and processes messages until the connection fails or
+// Close is called.
 func (n *NewsStream) Run() error {
 	url := n.baseURL + "/v1beta1/news"
 	header := http.Header{}
@@ -42,6 +57,10 @@ func (n *NewsStream) Run() error {
 		}
 		return fmt.Errorf("dial %s: %w", url, err)
 	}
+	if !n.closer.Track(conn) {
+		return ErrStreamClosed
+	}
+	defer n.closer.Untrack()
 	defer conn.Close()
 
 	// Auth by message
@@ -75,18 +94,27 @@ func (n *NewsStream) Run() error {
 	}
 
 	slog.Info("news stream connected", "url", url)
+	if n.OnConnect != nil {
+		n.OnConnect()
+	}
 
 	for {
 		_, data, err := conn.ReadMessage()
 		if err != nil {
 			return fmt.Errorf("read: %w", err)
 		}
-		if err := n.handleMessage(data); err != nil {
+		received := time.Now()
+		err = n.handleMessage(data)
+		telemetry.Message("alpaca.news", received, err)
+		if err != nil {
 			slog.Error("news stream handle", "err", err)
 		}
 	}
 }
 
+// Handlers returns the stream's callbacks for setting.
+func (n *NewsStream) Handlers() *NewsHandlers { return &n.NewsHandlers }
+
 func (n *NewsStream) readOneControl(conn *websocket.Conn) error {
 	_, data, err := conn.ReadMessage()
 	if err != nil {
@@ -0,0 +1,235 @@
+package alpaca
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OptionContract is an OCC option symbol decoded: AAPL240119C00190000 is the AAPL call expiring
+// 2024-01-19 with a 190 strike.
+type OptionContract struct {
+	Symbol     string
+	Underlying string
+	Expiry     time.Time // expiration date (midnight UTC)
+	Right      string    // "call" or "put"
+	Strike     float64
+}
+
+// ParseOptionSymbol decodes an OCC symbol: underlying, YYMMDD expiry, C or P, strike x 1000 in 8 digits.
+func ParseOptionSymbol(symbol string) (OptionContract, error) {
+	s := strings.ToUpper(strings.TrimSpace(symbol))
+	if len(s) < 16 {
+		return OptionContract{}, fmt.Errorf("option symbol %q: too short", symbol)
+	}
+	root, date, right, strike := s[:len(s)-15], s[len(s)-15:len(s)-9], s[len(s)-9], s[len(s)-8:]
+	expiry, err := time.Parse("060102", date)
+	if err != nil {
+		return OptionContract{}, fmt.Errorf("option symbol %q: expiry %q", symbol, date)
+	}
+	k, err := strconv.ParseInt(strike, 10, 64)
+	if err != nil {
+		return OptionContract{}, fmt.Errorf("option symbol %q: strike %q", symbol, strike)
+	}
+	c := OptionContract{Symbol: s, Underlying: strings.TrimSpace(root), Expiry: expiry, Strike: float64(k) / 1000}
+	switch right {
+	case 'C':
+		c.Right = "call"
+	case 'P':
+		c.Right = "put"
+	default:
+		return OptionContract{}, fmt.Errorf("option symbol %q: right %q is not C or P", symbol, string(right))
+	}
+	return c, nil
+}
+
+// OptionGreeks are the greeks Alpaca computes for a contract (missing when the quote is unusable).
+type OptionGreeks struct {
+	Delta float64 `json:"delta"`
+	Gamma float64 `json:"gamma"`
+	Theta float64 `json:"theta"`
+	Vega  float64 `json:"vega"`
+	Rho   float64 `json:"rho"`
+}
+
+// OptionSnapshot is the latest quote and trade of one contract with its implied volatility and greeks.
+type OptionSnapshot struct {
+	LatestTrade       *Trade        `json:"latestTrade"`
+	LatestQuote       *Quote        `json:"latestQuote"`
+	Greeks            *OptionGreeks `json:"greeks"`
+	ImpliedVolatility float64       `json:"impliedVolatility"` // annualized; 0 when Alpaca has none
+}
+
+// OptionChainFilter narrows GetOptionChain. Zero fields don't filter.
+type OptionChainFilter struct {
+	Feed          string // "indicative" (free, delayed) or "opra"
+	ExpirationGTE time.Time
+	ExpirationLTE time.Time
+	StrikeGTE     float64
+	StrikeLTE     float64
+}
+
+// optionSnapshotsResponse is a page of /v1beta1/options/snapshots.
+type optionSnapshotsResponse struct {
+	Snapshots     map[string]OptionSnapshot `json:"snapshots"`
+	NextPageToken string                    `json:"next_page_token"`
+}
+
+// GetOptionChain returns the snapshot of every contract on underlying that passes the filter, keyed by
+// contract symbol, following next_page_token.
+func (c *Client) GetOptionChain(underlying string, f OptionChainFilter) (map[string]OptionSnapshot, error) {
+	params := url.Values{}
+	params.Set("limit", "1000")
+	if f.Feed != "" {
+		params.Set("feed", f.Feed)
+	}
+	if !f.ExpirationGTE.IsZero() {
+		params.Set("expiration_date_gte", f.ExpirationGTE.Format("2006-01-02"))
+	}
+	if !f.ExpirationLTE.IsZero() {
+		params.Set("expiration_date_lte", f.ExpirationLTE.Format("2006-01-02"))
+	}
+	if f.StrikeGTE > 0 {
+		params.Set("strike_price_gte", strconv.FormatFloat(f.StrikeGTE, 'f', -1, 64))
+	}
+	if f.StrikeLTE > 0 {
+		params.Set("strike_price_lte", strconv.FormatFloat(f.StrikeLTE, 'f', -1, 64))
+	}
+	return c.optionSnapshots("/v1beta1/options/snapshots/"+url.PathEscape(strings.ToUpper(underlying)), params)
+}
+
+// GetOptionSnapshots returns the snapshots of the given contracts, keyed by contract symbol.
+func (c *Client) GetOptionSnapshots(symbols []string, feed string) (map[string]OptionSnapshot, error) {
+	out := make(map[string]OptionSnapshot, len(symbols))
+	for start := 0; start < len(symbols); start += 100 {
+		end := min(start+100, len(symbols))
+		params := url.Values{}
+		params.Set("symbols", strings.Join(symbols[start:end], ","))
+		if feed != "" {
+			params.Set("feed", feed)
+		}
+		page, err := c.optionSnapshots("/v1beta1/options/snapshots", params)
+		if err != nil {
+			return nil, err
+		}
+		for sym, s := range page {
+			out[sym] = s
+		}
+	}
+	return out, nil
+}
+
+func (c *Client) optionSnapshots(path string, params url.Values) (map[string]OptionSnapshot, error) {
+	out := make(map[string]OptionSnapshot)
+	for {
+		body, err := c.do("GET", path, params)
+		if err != nil {
+			return nil, err
+		}
+		var page optionSnapshotsResponse
+		if err := json.Unmarshal(body, &page); err != nil {
+			return nil, err
+		}
+		for sym, s := range page.Snapshots {
+			out[sym] = s
+		}
+		if page.NextPageToken == "" {
+			return out, nil
+		}
+		params.Set("page_token", page.NextPageToken)
+	}
+}
+
+// IVPoint is the at-the-money implied volatility of one expiration: the mean of the call and put IV at
+// the strike nearest spot (whichever of the two has one).
+type IVPoint struct {
+	Expiry time.Time
+	Days   int // calendar days to expiry
+	Strike float64
+	IV     float64
+	Call   string // contract symbols at that strike
+	Put    string
+}
+
+// InterpolateIV returns the IV at days to expiry from a term structure (nearest first), interpolating
+// total variance (IV² x days) linearly between the expirations either side; outside them the nearest
+// one's IV. 0 for an empty term structure.
+func InterpolateIV(term []IVPoint, days int) float64 {
+	if len(term) == 0 {
+		return 0
+	}
+	if days <= term[0].Days {
+		return term[0].IV
+	}
+	for i := 1; i < len(term); i++ {
+		a, b := term[i-1], term[i]
+		if days > b.Days {
+			continue
+		}
+		wa, wb := a.IV*a.IV*float64(a.Days), b.IV*b.IV*float64(b.Days)
+		w := wa + (wb-wa)*float64(days-a.Days)/float64(b.Days-a.Days)
+		return math.Sqrt(w / float64(days))
+	}
+	return term[len(term)-1].IV
+}
+
+// ATMTermStructure picks the at-the-money IV of every expiration in a chain, nearest expiry first.
+// Contracts without an IV, and expirations without one at any strike, are skipped. Days to expiry count
+// from now's date, so pass now in America/New_York.
+func ATMTermStructure(chain map[string]OptionSnapshot, spot float64, now time.Time) []IVPoint {
+	type side struct {
+		symbol string
+		iv     float64
+	}
+	type strikeKey struct {
+		expiry time.Time
+		strike float64
+	}
+	strikes := make(map[strikeKey][2]side) // [call, put]
+	for sym, s := range chain {
+		c, err := ParseOptionSymbol(sym)
+		if err != nil || s.ImpliedVolatility <= 0 {
+			continue
+		}
+		k := strikeKey{c.Expiry, c.Strike}
+		pair := strikes[k]
+		i := 0
+		if c.Right == "put" {
+			i = 1
+		}
+		pair[i] = side{sym, s.ImpliedVolatility}
+		strikes[k] = pair
+	}
+	best := make(map[time.Time]IVPoint)
+	for k, pair := range strikes {
+		if cur, ok := best[k.expiry]; ok {
+			// nearest strike wins; the lower one on a tie
+			if dc, dk := math.Abs(cur.Strike-spot), math.Abs(k.strike-spot); dc < dk || (dc == dk && cur.Strike < k.strike) {
+				continue
+			}
+		}
+		p := IVPoint{Expiry: k.expiry, Strike: k.strike, Call: pair[0].symbol, Put: pair[1].symbol}
+		var n int
+		for _, sd := range pair {
+			if sd.iv > 0 {
+				p.IV += sd.iv
+				n++
+			}
+		}
+		p.IV /= float64(n)
+		y, m, d := now.Date()
+		p.Days = int(k.expiry.Sub(time.Date(y, m, d, 0, 0, 0, 0, time.UTC)).Hours() / 24)
+		best[k.expiry] = p
+	}
+	out := make([]IVPoint, 0, len(best))
+	for _, p := range best {
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Expiry.Before(out[j].Expiry) })
+	return out
+}
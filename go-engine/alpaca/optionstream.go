@@ -0,0 +1,225 @@
+package alpaca
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/sunnyp94/sentry-bridge/go-engine/telemetry"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// StreamOptionQuote is one quote from the options stream.
+type StreamOptionQuote struct {
+	Symbol   string // contract (OCC symbol)
+	Bid      float64
+	Ask      float64
+	BidSize  int
+	AskSize  int
+	Time     time.Time
+	Received time.Time
+}
+
+// OptionStream connects to Alpaca's options WebSocket (v1beta1/indicative or v1beta1/opra) for contract
+// quotes. The options stream speaks msgpack only.
+type OptionStream struct {
+	baseURL   string
+	keyID     string
+	secretKey string
+	feed      string
+
+	// Contracts streamed on every connect, and the live connection for changing them. mu also
+	// serializes writes to conn.
+	mu      sync.Mutex
+	symbols []string
+	conn    *websocket.Conn
+
+	closer StreamCloser
+
+	OnQuote func(q StreamOptionQuote)
+	// OnConnect runs after every successful connect and subscription, before quotes are read.
+	OnConnect func()
+}
+
+// NewOptionStream creates a stream for feed "indicative" (default) or "opra".
+func NewOptionStream(streamBaseURL, keyID, secretKey, feed string) *OptionStream {
+	if feed == "" {
+		feed = "indicative"
+	}
+	return &OptionStream{baseURL: streamBaseURL, keyID: keyID, secretKey: secretKey, feed: feed}
+}
+
+// Close ends Run and keeps the stream from connecting again.
+func (o *OptionStream) Close() error { return o.closer.Close() }
+
+// Run connects, authenticates, subscribes to quotes for the contracts and processes messages until the
+// connection fails or Close is called.
+func (o *OptionStream) Run() error {
+	url := o.baseURL + "/v1beta1/" + o.feed
+	header := http.Header{}
+	header.Set("APCA-API-KEY-ID", o.keyID)
+	header.Set("APCA-API-SECRET-KEY", o.secretKey)
+	header.Set("Content-Type", "application/msgpack")
+	conn, resp, err := websocket.DefaultDialer.Dial(url, header)
+	if err != nil {
+		if resp != nil {
+			return fmt.Errorf("dial %s: %w (status %d)", url, err, resp.StatusCode)
+		}
+		return fmt.Errorf("dial %s: %w", url, err)
+	}
+	if !o.closer.Track(conn) {
+		return ErrStreamClosed
+	}
+	defer o.closer.Untrack()
+	defer conn.Close()
+
+	if err := writeMsgpack(conn, map[string]string{"action": "auth", "key": o.keyID, "secret": o.secretKey}); err != nil {
+		return fmt.Errorf("auth write: %w", err)
+	}
+	// "connected", then "authenticated" (or an error)
+	for i := 0; i < 2; i++ {
+		if err := o.read(conn, time.Now().Add(10*time.Second)); err != nil {
+			return err
+		}
+	}
+
+	o.mu.Lock()
+	symbols := append([]string(nil), o.symbols...)
+	if len(symbols) > 0 {
+		if err := writeMsgpack(conn, map[string]interface{}{"action": "subscribe", "quotes": symbols}); err != nil {
+			o.mu.Unlock()
+			return fmt.Errorf("subscribe write: %w", err)
+		}
+	}
+	o.conn = conn
+	o.mu.Unlock()
+	defer func() {
+		o.mu.Lock()
+		o.conn = nil
+		o.mu.Unlock()
+	}()
+
+	slog.Info("option stream connected", "url", url, "contracts", len(symbols))
+	if o.OnConnect != nil {
+		o.OnConnect()
+	}
+	for {
+		if err := o.read(conn, time.Time{}); err != nil {
+			return err
+		}
+	}
+}
+
+// SetSymbols replaces the streamed contracts: the difference is subscribed and unsubscribed on the live
+// connection (if any), and the new set is requested on every reconnect.
+func (o *OptionStream) SetSymbols(symbols []string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	want := make(map[string]bool, len(symbols))
+	for _, s := range symbols {
+		want[s] = true
+	}
+	have := make(map[string]bool, len(o.symbols))
+	var drop, add []string
+	for _, s := range o.symbols {
+		have[s] = true
+		if !want[s] {
+			drop = append(drop, s)
+		}
+	}
+	for _, s := range symbols {
+		if !have[s] {
+			add = append(add, s)
+		}
+	}
+	o.symbols = append([]string(nil), symbols...)
+	if o.conn == nil {
+		return nil
+	}
+	if len(drop) > 0 {
+		if err := writeMsgpack(o.conn, map[string]interface{}{"action": "unsubscribe", "quotes": drop}); err != nil {
+			return fmt.Errorf("unsubscribe write: %w", err)
+		}
+	}
+	if len(add) > 0 {
+		if err := writeMsgpack(o.conn, map[string]interface{}{"action": "subscribe", "quotes": add}); err != nil {
+			return fmt.Errorf("subscribe write: %w", err)
+		}
+	}
+	return nil
+}
+
+// read handles one frame (a msgpack array of messages); an error message from Alpaca is returned as an
+// error. A zero deadline reads without one.
+func (o *OptionStream) read(conn *websocket.Conn, deadline time.Time) (err error) {
+	if err := conn.SetReadDeadline(deadline); err != nil {
+		return err
+	}
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		return fmt.Errorf("read: %w", err)
+	}
+	received := time.Now()
+	defer func() { telemetry.Message("alpaca.options", received, err) }()
+	var arr []map[string]interface{}
+	if err := msgpack.Unmarshal(data, &arr); err != nil {
+		return fmt.Errorf("option stream decode: %w", err)
+	}
+	for _, m := range arr {
+		switch m["T"] {
+		case "error":
+			code := msgpackFloat(m["code"])
+			msg, _ := m["msg"].(string)
+			return fmt.Errorf("alpaca option stream error: code=%.0f msg=%s", code, msg)
+		case "q":
+			if o.OnQuote == nil {
+				continue
+			}
+			q := StreamOptionQuote{Received: received}
+			q.Symbol, _ = m["S"].(string)
+			q.Bid, q.Ask = msgpackFloat(m["bp"]), msgpackFloat(m["ap"])
+			q.BidSize, q.AskSize = int(msgpackFloat(m["bs"])), int(msgpackFloat(m["as"]))
+			q.Time, _ = m["t"].(time.Time)
+			o.OnQuote(q)
+		}
+	}
+	return nil
+}
+
+func writeMsgpack(conn *websocket.Conn, v interface{}) error {
+	data, err := msgpack.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return conn.WriteMessage(websocket.BinaryMessage, data)
+}
+
+// msgpackFloat reads a number that msgpack may have decoded as any integer or float type.
+func msgpackFloat(v interface{}) float64 {
+	switch n := v.(type) {
+	case float64:
+		return n
+	case float32:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case uint64:
+		return float64(n)
+	case int8:
+		return float64(n)
+	case int16:
+		return float64(n)
+	case int32:
+		return float64(n)
+	case uint8:
+		return float64(n)
+	case uint16:
+		return float64(n)
+	case uint32:
+		return float64(n)
+	}
+	return 0
+}
@@ -0,0 +1,37 @@
+package alpaca
+
+import (
+	"encoding/json"
+	"net/url"
+	"strconv"
+)
+
+// PortfolioHistory is the account's equity curve (GET /v2/account/portfolio/history). The slices are
+// parallel to Timestamp; Equity and ProfitLoss are nil at points Alpaca has no value for.
+type PortfolioHistory struct {
+	Timestamp     []int64    `json:"timestamp"` // unix seconds
+	Equity        []*float64 `json:"equity"`
+	ProfitLoss    []*float64 `json:"profit_loss"`     // against BaseValue
+	ProfitLossPct []*float64 `json:"profit_loss_pct"` // fraction of BaseValue
+	BaseValue     float64    `json:"base_value"`      // equity at the start of the period (for 1D, the previous close)
+	Timeframe     string     `json:"timeframe"`
+}
+
+// GetPortfolioHistory returns the equity curve over period ("1D", "1W", "1M", ...) at timeframe ("1Min",
+// "5Min", "15Min", "1H", "1D"); extendedHours includes pre- and after-market points on intraday
+// timeframes.
+func (c *TradingClient) GetPortfolioHistory(period, timeframe string, extendedHours bool) (*PortfolioHistory, error) {
+	params := url.Values{}
+	params.Set("period", period)
+	params.Set("timeframe", timeframe)
+	params.Set("extended_hours", strconv.FormatBool(extendedHours))
+	body, err := c.do("GET", "/v2/account/portfolio/history?"+params.Encode())
+	if err != nil {
+		return nil, err
+	}
+	var out PortfolioHistory
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
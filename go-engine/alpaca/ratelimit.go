@@ -0,0 +1,44 @@
+package alpaca
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket shared by REST clients so that together they stay under Alpaca's
+// per-account request limit (200/min). It refills at perMin tokens a minute and holds up to a twentieth
+// of that, so no minute sees more than perMin plus that burst.
+type RateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration // time per token
+	burst    float64
+	tokens   float64
+	last     time.Time
+}
+
+// NewRateLimiter allows perMin requests a minute; nil for perMin <= 0 (no limit).
+func NewRateLimiter(perMin int) *RateLimiter {
+	if perMin <= 0 {
+		return nil
+	}
+	burst := float64(max(1, perMin/20))
+	return &RateLimiter{interval: time.Minute / time.Duration(perMin), burst: burst, tokens: burst, last: time.Now()}
+}
+
+// Wait blocks until a request may be sent. A nil limiter never blocks.
+func (l *RateLimiter) Wait() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = min(l.burst, l.tokens+float64(now.Sub(l.last))/float64(l.interval))
+	l.last = now
+	// Take the token now, even if that goes negative, so concurrent callers queue up in order
+	l.tokens--
+	wait := time.Duration(-l.tokens * float64(l.interval))
+	l.mu.Unlock()
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+}
@@ -0,0 +1,143 @@
+package alpaca
+
+import (
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/sunnyp94/sentry-bridge/go-engine/telemetry"
+)
+
+// RetryPolicy controls how REST calls are retried. 429 (rate limited) is always retryable and waits
+// for Retry-After when the server sends one. 5xx, 408 and network errors are retried only for GET and
+// DELETE: a retried order submission could be placed twice. Other statuses fail at once.
+type RetryPolicy struct {
+	MaxRetries int           // retries after the first attempt; 0 = none
+	BaseDelay  time.Duration // first backoff, doubled per retry, with up to 50% jitter
+	MaxDelay   time.Duration // cap on one backoff or Retry-After wait
+}
+
+// DefaultRetryPolicy is what new clients use until SetRetryPolicy.
+var DefaultRetryPolicy = RetryPolicy{MaxRetries: 3, BaseDelay: 500 * time.Millisecond, MaxDelay: 30 * time.Second}
+
+// RetryStats are cumulative retry counters of one client.
+type RetryStats struct {
+	Retries     uint64 // requests sent again
+	RateLimited uint64 // 429 responses
+	GaveUp      uint64 // calls that failed after using every retry
+}
+
+// retrier sends requests under a retry policy and an optional shared rate limiter; both REST clients
+// embed it.
+type retrier struct {
+	api         string // "alpaca.data" or "alpaca.trading", for telemetry
+	policy      RetryPolicy
+	limiter     *RateLimiter
+	retries     atomic.Uint64
+	rateLimited atomic.Uint64
+	gaveUp      atomic.Uint64
+}
+
+// SetRetryPolicy replaces the retry policy. Call it before the client is in use.
+func (r *retrier) SetRetryPolicy(p RetryPolicy) { r.policy = p }
+
+// SetRateLimiter makes every request (retries included) wait for l, which other clients may share. Call
+// it before the client is in use.
+func (r *retrier) SetRateLimiter(l *RateLimiter) { r.limiter = l }
+
+// RetryStats returns the client's retry counters.
+func (r *retrier) RetryStats() RetryStats {
+	return RetryStats{Retries: r.retries.Load(), RateLimited: r.rateLimited.Load(), GaveUp: r.gaveUp.Load()}
+}
+
+// send sends the request built by newReq (rebuilt per attempt so a body can be re-read) and returns
+// the final status and body. An error is returned only when no response arrived. The call, retries
+// included, is reported to telemetry as one REST call.
+func (r *retrier) send(hc *http.Client, method string, newReq func() (*http.Request, error)) (int, []byte, error) {
+	start := time.Now()
+	var path string
+	attempts := 0
+	status, body, err := r.attempt(hc, method, func() (*http.Request, error) {
+		req, err := newReq()
+		if req != nil {
+			path = req.URL.Path
+		}
+		attempts++
+		return req, err
+	})
+	telemetry.REST(r.api, method, path, start, status, attempts-1, err)
+	return status, body, err
+}
+
+// attempt is send's retry loop.
+func (r *retrier) attempt(hc *http.Client, method string, newReq func() (*http.Request, error)) (int, []byte, error) {
+	idempotent := method == http.MethodGet || method == http.MethodDelete
+	for attempt := 0; ; attempt++ {
+		req, err := newReq()
+		if err != nil {
+			return 0, nil, err
+		}
+		var status int
+		var body []byte
+		var wait time.Duration
+		r.limiter.Wait()
+		resp, err := hc.Do(req)
+		if err == nil {
+			status = resp.StatusCode
+			body, err = io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if status == http.StatusTooManyRequests {
+				r.rateLimited.Add(1)
+				wait = retryAfter(resp.Header.Get("Retry-After"))
+			}
+		}
+		retry := status == http.StatusTooManyRequests || (idempotent && (err != nil || retryableStatus(status)))
+		if !retry {
+			return status, body, err
+		}
+		if attempt >= r.policy.MaxRetries {
+			if r.policy.MaxRetries > 0 {
+				r.gaveUp.Add(1)
+			}
+			return status, body, err
+		}
+		if wait <= 0 {
+			wait = r.policy.BaseDelay << attempt
+			wait += time.Duration(rand.Int63n(int64(wait)/2 + 1))
+		}
+		if r.policy.MaxDelay > 0 && wait > r.policy.MaxDelay {
+			wait = r.policy.MaxDelay
+		}
+		r.retries.Add(1)
+		slog.Warn("REST retry", "method", method, "path", req.URL.Path, "status", status, "err", err, "attempt", attempt+1, "wait_ms", wait.Milliseconds())
+		time.Sleep(wait)
+	}
+}
+
+// retryableStatus reports whether a response status is worth retrying (for an idempotent request).
+func retryableStatus(status int) bool {
+	switch status {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests, http.StatusInternalServerError,
+		http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryAfter parses a Retry-After header: seconds or an HTTP date. 0 if absent or unparseable.
+func retryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if sec, err := strconv.Atoi(v); err == nil && sec >= 0 {
+		return time.Duration(sec) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
@@ -0,0 +1,62 @@
+package alpaca
+
+import (
+	"encoding/json"
+	"net/url"
+	"strconv"
+)
+
+// Mover is one symbol of the top gainers or losers screen.
+type Mover struct {
+	Symbol        string  `json:"symbol"`
+	PercentChange float64 `json:"percent_change"`
+	Change        float64 `json:"change"`
+	Price         float64 `json:"price"`
+}
+
+// Movers is GET /v1beta1/screener/stocks/movers: the day's top gainers and losers by percent change.
+type Movers struct {
+	Gainers     []Mover `json:"gainers"`
+	Losers      []Mover `json:"losers"`
+	LastUpdated string  `json:"last_updated"`
+}
+
+// MostActive is one symbol of the most-actives screen.
+type MostActive struct {
+	Symbol     string  `json:"symbol"`
+	Volume     float64 `json:"volume"`
+	TradeCount float64 `json:"trade_count"`
+}
+
+// GetMovers returns the top gainers and losers, up to top of each.
+func (c *Client) GetMovers(top int) (*Movers, error) {
+	params := url.Values{}
+	params.Set("top", strconv.Itoa(top))
+	body, err := c.do("GET", "/v1beta1/screener/stocks/movers", params)
+	if err != nil {
+		return nil, err
+	}
+	var out Movers
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetMostActives returns the day's most active stocks by "volume" or "trades", up to top.
+func (c *Client) GetMostActives(by string, top int) ([]MostActive, error) {
+	params := url.Values{}
+	params.Set("by", by)
+	params.Set("top", strconv.Itoa(top))
+	body, err := c.do("GET", "/v1beta1/screener/stocks/most-actives", params)
+	if err != nil {
+		return nil, err
+	}
+	var out struct {
+		MostActives []MostActive `json:"most_actives"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, err
+	}
+	return out.MostActives, nil
+}
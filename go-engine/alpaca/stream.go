@@ -2,30 +2,158 @@ package alpaca
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/sunnyp94/sentry-bridge/go-engine/telemetry"
 )
 
+// ErrStreamClosed is what a stream's Run returns once Close was called.
+var ErrStreamClosed = errors.New("stream closed")
+
+// StreamCloser lets Close end a stream's Run from another goroutine: it closes the connection Run is
+// reading, and a Run after Close gives up on connecting. The zero value is ready to use.
+type StreamCloser struct {
+	mu     sync.Mutex
+	closed bool
+	conn   io.Closer
+}
+
+// Track registers conn as the live connection. After Close it closes conn instead and reports false.
+func (c *StreamCloser) Track(conn io.Closer) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		_ = conn.Close()
+		return false
+	}
+	c.conn = conn
+	return true
+}
+
+// Untrack forgets the connection once Run is done with it.
+func (c *StreamCloser) Untrack() {
+	c.mu.Lock()
+	c.conn = nil
+	c.mu.Unlock()
+}
+
+// Closed reports whether Close was called.
+func (c *StreamCloser) Closed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+// Close closes the live connection, if any, and keeps later Runs from connecting.
+func (c *StreamCloser) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+// StreamTrade is one trade from the stock stream.
+type StreamTrade struct {
+	Symbol     string
+	Price      float64
+	Size       int
+	Time       time.Time // exchange timestamp
+	Received   time.Time // when the WebSocket message carrying it was read
+	ID         int64     // trade ID, unique per symbol and exchange
+	Exchange   string    // exchange code (V = IEX, Q = Nasdaq, N = NYSE, D = FINRA ADF, ...)
+	Tape       string    // A, B (CTA) or C (UTP)
+	Conditions []string  // SIP sale condition codes; see DecodeConditions
+}
+
+// StreamCorrection is a correction ("c") or a cancellation or error ("x") of an earlier trade, from the
+// stock stream. Original is the trade as first reported; Corrected is set for a correction only. For a
+// cancellation only the original's ID, price and size are known.
+type StreamCorrection struct {
+	Symbol    string
+	Action    string // "correction", "cancel" or "error"
+	Original  StreamTrade
+	Corrected StreamTrade
+	Exchange  string
+	Tape      string
+	Time      time.Time
+	Received  time.Time
+}
+
+// StreamStatus is a trading status message from the stock stream: a halt, a pause, a resumption or one
+// of the exchange's informational statuses.
+type StreamStatus struct {
+	Symbol        string
+	Code          string // status code: H halt, P volatility (LULD) pause, Q quotation resumption, T trading resumption (tape C); 2 halt, 3 resume (tapes A and B)
+	Message       string
+	ReasonCode    string // e.g. T1 news pending, LUDP LULD pause, MWC1 market-wide circuit breaker
+	ReasonMessage string
+	Tape          string
+	Time          time.Time // exchange timestamp
+	Received      time.Time
+}
+
+// Halted reports whether the status halts trading in the symbol (halted) or lifts a halt (!halted);
+// known is false for informational statuses that do neither. A quotation resumption still halts trading:
+// quotes come back before trades do.
+func (s StreamStatus) Halted() (halted, known bool) {
+	switch s.Code {
+	case "H", "P", "Q", "2":
+		return true, true
+	case "T", "3":
+		return false, true
+	}
+	return false, false
+}
+
+// StreamHandlers are a price stream's callbacks (all optional). Quote includes bid/ask size for
+// order-book context. t is the exchange timestamp, received when the WebSocket message carrying the tick
+// was read.
+type StreamHandlers struct {
+	OnTrade func(tr StreamTrade)
+	OnQuote func(symbol string, bid, ask float64, bidSize, askSize int, t, received time.Time)
+	// OnCorrection receives corrections and cancellations of earlier trades (sent with the trades).
+	OnCorrection func(c StreamCorrection)
+	// OnStatus receives trading status messages (halts, LULD pauses, resumptions) for the symbols.
+	OnStatus func(st StreamStatus)
+	// OnConnect runs after every successful connect and subscription, before ticks are read.
+	OnConnect func()
+}
+
 // PriceStream connects to Alpaca's stock WebSocket (trades + quotes) for real-time price.
 type PriceStream struct {
 	baseURL   string
 	keyID     string
 	secretKey string
 	feed      string // "sip" (default) or "iex"
-	symbols   []string
 
 	// Last price per symbol (mid from quote or last trade)
 	mu     sync.RWMutex
 	prices map[string]float64
 
-	// Callbacks (optional). Quote includes bid/ask size for order-book context.
-	OnTrade func(symbol string, price float64, size int, t time.Time)
-	OnQuote func(symbol string, bid, ask float64, bidSize, askSize int, t time.Time)
+	// Requested symbols, those Alpaca confirmed in the last "subscription" message, and the live
+	// connection for changing the subscription. subMu also serializes writes to conn.
+	subMu     sync.Mutex
+	symbols   []string
+	confirmed []string
+	conn      *websocket.Conn
+
+	closer StreamCloser
+
+	StreamHandlers
 }
 
 // NewPriceStream creates a stream for v2/sip (default) or v2/iex. Set ALPACA_DATA_FEED=iex for free tier.
@@ -43,7 +171,8 @@ func NewPriceStream(streamBaseURL, keyID, secretKey, feed string, symbols []stri
 	}
 }
 
-// Run connects, authenticates, subscribes to trades and quotes, and processes messages until ctx is done or connection fails.
+// Run connects, authenticates, subscribes to trades, quotes and trading statuses, and processes messages
+// until the connection fails or Close is called.
 func (p *PriceStream) Run() error {
 	url := p.baseURL + "/v2/" + p.feed
 	req, _ := http.NewRequest("GET", url, nil)
@@ -56,6 +185,10 @@ func (p *PriceStream) Run() error {
 		}
 		return fmt.Errorf("dial %s: %w", url, err)
 	}
+	if !p.closer.Track(conn) {
+		return ErrStreamClosed
+	}
+	defer p.closer.Untrack()
 	defer conn.Close()
 
 	// Auth by message (required within 10s)
@@ -73,32 +206,56 @@ func (p *PriceStream) Run() error {
 		return err
 	}
 
-	// Subscribe trades and quotes
+	// Subscribe trades, quotes and trading statuses. The lock is held until the connection is registered so an
+	// Unsubscribe during the handshake is not lost.
+	p.subMu.Lock()
+	symbols := append([]string(nil), p.symbols...)
 	sub := map[string]interface{}{
-		"action": "subscribe",
-		"trades": p.symbols,
-		"quotes": p.symbols,
+		"action":   "subscribe",
+		"trades":   symbols,
+		"quotes":   symbols,
+		"statuses": symbols,
 	}
 	if err := conn.WriteJSON(sub); err != nil {
+		p.subMu.Unlock()
 		return fmt.Errorf("subscribe write: %w", err)
 	}
-	if err := p.readOneControl(conn); err != nil {
+	confirmed, err := p.awaitSubscription(conn, symbols)
+	if err != nil {
+		p.subMu.Unlock()
 		return err
 	}
+	p.confirmed = confirmed
+	p.conn = conn
+	p.subMu.Unlock()
+	defer func() {
+		p.subMu.Lock()
+		p.conn = nil
+		p.subMu.Unlock()
+	}()
 
-	slog.Info("price stream connected", "url", url, "symbols", p.symbols)
+	slog.Info("price stream connected", "url", url, "symbols", symbols)
+	if p.OnConnect != nil {
+		p.OnConnect()
+	}
 
 	for {
 		_, data, err := conn.ReadMessage()
 		if err != nil {
 			return fmt.Errorf("read: %w", err)
 		}
-		if err := p.handleMessage(data); err != nil {
+		received := time.Now()
+		err = p.handleMessage(data, received)
+		telemetry.Message("alpaca.stocks", received, err)
+		if err != nil {
 			slog.Error("stream handle message", "err", err)
 		}
 	}
 }
 
+// Close ends Run and keeps the stream from connecting again.
+func (p *PriceStream) Close() error { return p.closer.Close() }
+
 func (p *PriceStream) readOneControl(conn *websocket.Conn) error {
 	_, data, err := conn.ReadMessage()
 	if err != nil {
@@ -121,7 +278,183 @@ func (p *PriceStream) readOneControl(conn *websocket.Conn) error {
 	return nil
 }
 
-func (p *PriceStream) handleMessage(data []byte) error {
+// subscriptionTimeout bounds the wait for Alpaca to confirm the subscribe request.
+const subscriptionTimeout = 10 * time.Second
+
+// awaitSubscription reads until Alpaca's "subscription" message, checks it against the requested
+// symbols and returns the confirmed ones. Symbols that were not confirmed for both trades and quotes (typos, delisted names) and
+// confirmed symbols that were never requested are logged as errors; none confirmed at all is an error,
+// since no ticks would ever arrive. Ticks that race the confirmation are handled normally.
+func (p *PriceStream) awaitSubscription(conn *websocket.Conn, symbols []string) ([]string, error) {
+	if err := conn.SetReadDeadline(time.Now().Add(subscriptionTimeout)); err != nil {
+		return nil, err
+	}
+	defer conn.SetReadDeadline(time.Time{})
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return nil, fmt.Errorf("awaiting subscription: %w", err)
+		}
+		var arr []map[string]interface{}
+		if err := json.Unmarshal(data, &arr); err != nil {
+			return nil, fmt.Errorf("unexpected control: %s", string(data))
+		}
+		for _, m := range arr {
+			switch m["T"] {
+			case "error":
+				code, _ := m["code"].(float64)
+				msg, _ := m["msg"].(string)
+				return nil, fmt.Errorf("alpaca stream error: code=%.0f msg=%s", code, msg)
+			case "subscription":
+				return checkSubscription(m, symbols)
+			}
+		}
+		if err := p.handleMessage(data, time.Now()); err != nil {
+			slog.Error("stream handle message", "err", err)
+		}
+	}
+}
+
+// checkSubscription returns the confirmed symbols and reports the difference from the requested set.
+func checkSubscription(m map[string]interface{}, symbols []string) ([]string, error) {
+	trades := symbolSet(m["trades"])
+	quotes := symbolSet(m["quotes"])
+	var confirmed, missing, unknown []string
+	requested := make(map[string]bool, len(symbols))
+	for _, s := range symbols {
+		s = strings.ToUpper(s)
+		requested[s] = true
+		if trades[s] && quotes[s] {
+			confirmed = append(confirmed, s)
+		} else {
+			missing = append(missing, s)
+		}
+	}
+	for s := range trades {
+		if !requested[s] {
+			unknown = append(unknown, s)
+		}
+	}
+	for s := range quotes {
+		if !requested[s] && !trades[s] {
+			unknown = append(unknown, s)
+		}
+	}
+	sort.Strings(unknown)
+	if len(missing) > 0 || len(unknown) > 0 {
+		slog.Error("price stream subscription mismatch", "missing", missing, "unknown", unknown, "confirmed", len(confirmed))
+	}
+	if len(confirmed) == 0 && len(symbols) > 0 {
+		return nil, fmt.Errorf("alpaca confirmed none of the requested symbols %v", symbols)
+	}
+	return confirmed, nil
+}
+
+// Handlers returns the stream's callbacks for setting.
+func (p *PriceStream) Handlers() *StreamHandlers { return &p.StreamHandlers }
+
+// Confirmed returns the symbols Alpaca confirmed for trades and quotes on the current connection.
+func (p *PriceStream) Confirmed() []string {
+	p.subMu.Lock()
+	defer p.subMu.Unlock()
+	return append([]string(nil), p.confirmed...)
+}
+
+// Symbols returns the symbols the stream subscribes to on every connect.
+func (p *PriceStream) Symbols() []string {
+	p.subMu.Lock()
+	defer p.subMu.Unlock()
+	return append([]string(nil), p.symbols...)
+}
+
+// Subscribe adds symbols to the stream: they are subscribed on the live connection (if any) and
+// requested on every reconnect. Symbols already streamed are ignored.
+func (p *PriceStream) Subscribe(symbols []string) error {
+	p.subMu.Lock()
+	defer p.subMu.Unlock()
+	have := make(map[string]bool, len(p.symbols))
+	for _, s := range p.symbols {
+		have[strings.ToUpper(s)] = true
+	}
+	var add []string
+	for _, s := range symbols {
+		if s = strings.ToUpper(s); !have[s] {
+			have[s] = true
+			add = append(add, s)
+		}
+	}
+	if len(add) == 0 {
+		return nil
+	}
+	p.symbols = append(p.symbols, add...)
+	if p.conn == nil {
+		return nil
+	}
+	msg := map[string]interface{}{
+		"action":   "subscribe",
+		"trades":   add,
+		"quotes":   add,
+		"statuses": add,
+	}
+	if err := p.conn.WriteJSON(msg); err != nil {
+		return fmt.Errorf("subscribe write: %w", err)
+	}
+	return nil
+}
+
+// Unsubscribe drops symbols from the stream: they are unsubscribed on the live connection (if any)
+// and not requested again on reconnect.
+func (p *PriceStream) Unsubscribe(symbols []string) error {
+	if len(symbols) == 0 {
+		return nil
+	}
+	drop := make(map[string]bool, len(symbols))
+	for _, s := range symbols {
+		drop[strings.ToUpper(s)] = true
+	}
+	p.subMu.Lock()
+	defer p.subMu.Unlock()
+	p.symbols = removeSymbols(p.symbols, drop)
+	p.confirmed = removeSymbols(p.confirmed, drop)
+	if p.conn == nil {
+		return nil
+	}
+	msg := map[string]interface{}{
+		"action":   "unsubscribe",
+		"trades":   symbols,
+		"quotes":   symbols,
+		"statuses": symbols,
+	}
+	if err := p.conn.WriteJSON(msg); err != nil {
+		return fmt.Errorf("unsubscribe write: %w", err)
+	}
+	return nil
+}
+
+// removeSymbols returns list without the symbols in drop (compared uppercase).
+func removeSymbols(list []string, drop map[string]bool) []string {
+	var kept []string
+	for _, s := range list {
+		if !drop[strings.ToUpper(s)] {
+			kept = append(kept, s)
+		}
+	}
+	return kept
+}
+
+// symbolSet turns a subscription list (e.g. "trades") into an uppercase set.
+func symbolSet(v interface{}) map[string]bool {
+	list, _ := v.([]interface{})
+	set := make(map[string]bool, len(list))
+	for _, x := range list {
+		if s, ok := x.(string); ok {
+			set[strings.ToUpper(s)] = true
+		}
+	}
+	return set
+}
+
+func (p *PriceStream) handleMessage(data []byte, received time.Time) error {
 	var arr []map[string]interface{}
 	if err := json.Unmarshal(data, &arr); err != nil {
 		return err
@@ -130,16 +463,37 @@ func (p *PriceStream) handleMessage(data []byte) error {
 		t, _ := m["T"].(string)
 		sym, _ := m["S"].(string)
 		switch t {
+		case "subscription":
+			// Confirmation of a Subscribe/Unsubscribe on the live connection (the connect-time one is
+			// consumed by awaitSubscription while it holds subMu)
+			trades, quotes := symbolSet(m["trades"]), symbolSet(m["quotes"])
+			var confirmed []string
+			for s := range trades {
+				if quotes[s] {
+					confirmed = append(confirmed, s)
+				}
+			}
+			sort.Strings(confirmed)
+			p.subMu.Lock()
+			p.confirmed = confirmed
+			p.subMu.Unlock()
 		case "t":
-			price, _ := m["p"].(float64)
-			size := 0
+			tr := StreamTrade{Symbol: sym, Time: parseTime(m["t"]), Received: received}
+			tr.Price, _ = m["p"].(float64)
 			if s, ok := m["s"].(float64); ok {
-				size = int(s)
+				tr.Size = int(s)
+			}
+			if id, ok := m["i"].(float64); ok {
+				tr.ID = int64(id)
+			}
+			tr.Exchange, _ = m["x"].(string)
+			tr.Tape, _ = m["z"].(string)
+			tr.Conditions = stringList(m["c"])
+			if _, updatesLast := DecodeConditions(tr.Tape, tr.Conditions); updatesLast {
+				p.setPrice(sym, tr.Price)
 			}
-			ts := parseTime(m["t"])
-			p.setPrice(sym, price)
 			if p.OnTrade != nil {
-				p.OnTrade(sym, price, size, ts)
+				p.OnTrade(tr)
 			}
 		case "q":
 			bp, _ := m["bp"].(float64)
@@ -152,13 +506,71 @@ func (p *PriceStream) handleMessage(data []byte) error {
 			}
 			ts := parseTime(m["t"])
 			if p.OnQuote != nil {
-				p.OnQuote(sym, bp, ap, int(bs), int(as), ts)
+				p.OnQuote(sym, bp, ap, int(bs), int(as), ts, received)
+			}
+		case "c", "x":
+			if p.OnCorrection == nil {
+				continue
+			}
+			c := StreamCorrection{Symbol: sym, Time: parseTime(m["t"]), Received: received}
+			c.Exchange, _ = m["x"].(string)
+			c.Tape, _ = m["z"].(string)
+			prefix := ""
+			if t == "c" {
+				c.Action, prefix = "correction", "o"
+				c.Corrected = streamTradeFields(m, "c")
+			} else if a, _ := m["a"].(string); a == "E" {
+				c.Action = "error"
+			} else {
+				c.Action = "cancel"
+			}
+			c.Original = streamTradeFields(m, prefix)
+			for _, tr := range []*StreamTrade{&c.Original, &c.Corrected} {
+				tr.Symbol, tr.Exchange, tr.Tape, tr.Time, tr.Received = sym, c.Exchange, c.Tape, c.Time, received
+			}
+			p.OnCorrection(c)
+		case "s":
+			if p.OnStatus != nil {
+				st := StreamStatus{Symbol: sym, Time: parseTime(m["t"]), Received: received}
+				st.Code, _ = m["sc"].(string)
+				st.Message, _ = m["sm"].(string)
+				st.ReasonCode, _ = m["rc"].(string)
+				st.ReasonMessage, _ = m["rm"].(string)
+				st.Tape, _ = m["z"].(string)
+				p.OnStatus(st)
 			}
 		}
 	}
 	return nil
 }
 
+// streamTradeFields reads the ID, price, size and conditions of a trade from a correction or cancel
+// message, whose keys carry a prefix ("o" original, "c" corrected, none in a cancel).
+func streamTradeFields(m map[string]interface{}, prefix string) StreamTrade {
+	var tr StreamTrade
+	tr.Price, _ = m[prefix+"p"].(float64)
+	if s, ok := m[prefix+"s"].(float64); ok {
+		tr.Size = int(s)
+	}
+	if id, ok := m[prefix+"i"].(float64); ok {
+		tr.ID = int64(id)
+	}
+	tr.Conditions = stringList(m[prefix+"c"])
+	return tr
+}
+
+// stringList reads a JSON array of strings (non-strings are skipped).
+func stringList(v interface{}) []string {
+	list, _ := v.([]interface{})
+	var out []string
+	for _, x := range list {
+		if s, ok := x.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
 func (p *PriceStream) setPrice(symbol string, price float64) {
 	if symbol == "" || price <= 0 {
 		return
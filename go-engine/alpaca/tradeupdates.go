@@ -0,0 +1,133 @@
+package alpaca
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/sunnyp94/sentry-bridge/go-engine/telemetry"
+)
+
+// TradeUpdate is one order event from the trading stream ("new", "partial_fill", "fill", "canceled",
+// "replaced", "rejected", "expired", ...). Price/Qty are set for fills.
+type TradeUpdate struct {
+	Event       string     `json:"event"`
+	ExecutionID string     `json:"execution_id"`
+	Order       Order      `json:"order"`
+	Price       *FlexFloat `json:"price,omitempty"`
+	Qty         *FlexFloat `json:"qty,omitempty"`
+	PositionQty *FlexFloat `json:"position_qty,omitempty"`
+	Timestamp   string     `json:"timestamp"`
+}
+
+// TradeUpdateStream connects to the Trading API WebSocket and delivers trade_updates for the account,
+// so fills and partial fills are seen as they happen rather than on the next positions/orders poll.
+type TradeUpdateStream struct {
+	url       string
+	keyID     string
+	secretKey string
+
+	closer StreamCloser
+
+	OnUpdate func(u TradeUpdate)
+}
+
+// NewTradeUpdateStream derives the stream URL from the trading base URL
+// (https://paper-api.alpaca.markets -> wss://paper-api.alpaca.markets/stream).
+func NewTradeUpdateStream(tradingBaseURL, keyID, secretKey string) *TradeUpdateStream {
+	u := strings.TrimRight(tradingBaseURL, "/")
+	u = strings.Replace(u, "https://", "wss://", 1)
+	u = strings.Replace(u, "http://", "ws://", 1)
+	return &TradeUpdateStream{url: u + "/stream", keyID: keyID, secretKey: secretKey}
+}
+
+// streamMessage is the trading stream envelope: {"stream": "...", "data": {...}}.
+type streamMessage struct {
+	Stream string          `json:"stream"`
+	Data   json.RawMessage `json:"data"`
+}
+
+// Close ends Run and keeps the stream from connecting again.
+func (s *TradeUpdateStream) Close() error { return s.closer.Close() }
+
+// Run connects, authenticates, listens to trade_updates, and processes messages until the connection fails
+// or Close is called.
+func (s *TradeUpdateStream) Run() error {
+	conn, resp, err := websocket.DefaultDialer.Dial(s.url, nil)
+	if err != nil {
+		if resp != nil {
+			return fmt.Errorf("dial %s: %w (status %d)", s.url, err, resp.StatusCode)
+		}
+		return fmt.Errorf("dial %s: %w", s.url, err)
+	}
+	if !s.closer.Track(conn) {
+		return ErrStreamClosed
+	}
+	defer s.closer.Untrack()
+	defer conn.Close()
+
+	auth := map[string]interface{}{
+		"action": "authenticate",
+		"data":   map[string]string{"key_id": s.keyID, "secret_key": s.secretKey},
+	}
+	if err := conn.WriteJSON(auth); err != nil {
+		return fmt.Errorf("auth write: %w", err)
+	}
+	msg, err := s.read(conn)
+	if err != nil {
+		return err
+	}
+	var authResp struct {
+		Status string `json:"status"`
+	}
+	_ = json.Unmarshal(msg.Data, &authResp)
+	if msg.Stream != "authorization" || authResp.Status != "authorized" {
+		return fmt.Errorf("trade updates auth failed: %s", string(msg.Data))
+	}
+
+	listen := map[string]interface{}{
+		"action": "listen",
+		"data":   map[string][]string{"streams": {"trade_updates"}},
+	}
+	if err := conn.WriteJSON(listen); err != nil {
+		return fmt.Errorf("listen write: %w", err)
+	}
+	slog.Info("trade updates stream connected", "url", s.url)
+
+	for {
+		msg, err := s.read(conn)
+		if err != nil {
+			return err
+		}
+		if msg.Stream != "trade_updates" {
+			continue
+		}
+		received := time.Now()
+		var u TradeUpdate
+		if err := json.Unmarshal(msg.Data, &u); err != nil {
+			slog.Error("trade update decode", "err", err)
+			telemetry.Message("alpaca.trade_updates", received, err)
+			continue
+		}
+		if s.OnUpdate != nil {
+			s.OnUpdate(u)
+		}
+		telemetry.Message("alpaca.trade_updates", received, nil)
+	}
+}
+
+// read returns the next message; the trading stream sends JSON in binary or text frames.
+func (s *TradeUpdateStream) read(conn *websocket.Conn) (streamMessage, error) {
+	var msg streamMessage
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		return msg, fmt.Errorf("read: %w", err)
+	}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return msg, fmt.Errorf("unexpected message: %s", string(data))
+	}
+	return msg, nil
+}
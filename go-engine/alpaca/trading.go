@@ -1,43 +1,56 @@
 package alpaca
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strconv"
 	"time"
 )
 
-// flexFloat unmarshals from string or number (Alpaca sometimes returns decimals as strings).
-type flexFloat float64
+// FlexFloat unmarshals from string or number (Alpaca sometimes returns decimals as strings). Other
+// brokers set it directly when converting into the alpaca types.
+type FlexFloat float64
 
-func (f *flexFloat) UnmarshalJSON(data []byte) error {
+func (f *FlexFloat) UnmarshalJSON(data []byte) error {
 	var v interface{}
 	if err := json.Unmarshal(data, &v); err != nil {
 		return err
 	}
 	switch x := v.(type) {
 	case float64:
-		*f = flexFloat(x)
+		*f = FlexFloat(x)
 	case string:
 		parsed, err := strconv.ParseFloat(x, 64)
 		if err != nil {
 			return err
 		}
-		*f = flexFloat(parsed)
+		*f = FlexFloat(parsed)
 	default:
 		return fmt.Errorf("current_price: cannot unmarshal %T into float", v)
 	}
 	return nil
 }
 
-// TradingClient calls Alpaca Trading API (paper or live). Used for positions and open orders only; Python brain places buy/sell orders.
+// Value returns the float, or 0 for a nil pointer (optional fields such as limit_price).
+func (f *FlexFloat) Value() float64 {
+	if f == nil {
+		return 0
+	}
+	return float64(*f)
+}
+
+// TradingClient calls Alpaca Trading API (paper or live). Used for positions and open orders; the Python brain
+// places its own orders, PlaceOrder is for engine-side strategies (fallback brain).
 type TradingClient struct {
 	baseURL    string
 	keyID      string
 	secretKey  string
 	httpClient *http.Client
+	retrier
 }
 
 func NewTradingClient(baseURL, keyID, secretKey string) *TradingClient {
@@ -48,38 +61,81 @@ func NewTradingClient(baseURL, keyID, secretKey string) *TradingClient {
 		httpClient: &http.Client{
 			Timeout: 15 * time.Second,
 		},
+		retrier: retrier{api: "alpaca.trading", policy: DefaultRetryPolicy},
 	}
 }
 
 func (c *TradingClient) do(method, path string) ([]byte, error) {
-	req, err := http.NewRequest(method, c.baseURL+path, nil)
+	return c.doBody(method, path, nil)
+}
+
+// doBody sends an optional JSON body and returns the response body for any 2xx status.
+func (c *TradingClient) doBody(method, path string, payload []byte) ([]byte, error) {
+	status, respBody, err := c.send(c.httpClient, method, func() (*http.Request, error) {
+		var body io.Reader
+		if payload != nil {
+			body = bytes.NewReader(payload)
+		}
+		req, err := http.NewRequest(method, c.baseURL+path, body)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("APCA-API-KEY-ID", c.keyID)
+		req.Header.Set("APCA-API-SECRET-KEY", c.secretKey)
+		if payload != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		return req, nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("APCA-API-KEY-ID", c.keyID)
-	req.Header.Set("APCA-API-SECRET-KEY", c.secretKey)
-	resp, err := c.httpClient.Do(req)
+	if status < 200 || status >= 300 {
+		return nil, fmt.Errorf("trading API %s %s: %s (status %d)", method, path, string(respBody), status)
+	}
+	return respBody, nil
+}
+
+// Account is the subset of GET /v2/account the engine uses for sizing and risk.
+type Account struct {
+	ID               string    `json:"id"`
+	Status           string    `json:"status"`
+	Currency         string    `json:"currency"`
+	Equity           FlexFloat `json:"equity"`
+	LastEquity       FlexFloat `json:"last_equity"`
+	Cash             FlexFloat `json:"cash"`
+	BuyingPower      FlexFloat `json:"buying_power"`
+	PortfolioValue   FlexFloat `json:"portfolio_value"`
+	DaytradeCount    int       `json:"daytrade_count"`
+	PatternDayTrader bool      `json:"pattern_day_trader"`
+	TradingBlocked   bool      `json:"trading_blocked"`
+	ShortingEnabled  bool      `json:"shorting_enabled"`
+}
+
+// GetAccount returns the account's equity, cash and buying power.
+func (c *TradingClient) GetAccount() (*Account, error) {
+	body, err := c.do("GET", "/v2/account")
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-	body, _ := io.ReadAll(resp.Body)
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("trading API %s %s: %s (status %d)", method, path, string(body), resp.StatusCode)
+	var out Account
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, err
 	}
-	return body, nil
+	return &out, nil
 }
 
 // Position is a single position from GET /v2/positions.
 type Position struct {
-	Symbol         string  `json:"symbol"`
-	Qty            string  `json:"qty"`
-	Side           string  `json:"side"`
-	MarketValue    string  `json:"market_value"`
-	CostBasis      string  `json:"cost_basis"`
-	UnrealizedPL   string  `json:"unrealized_pl"`
-	UnrealizedPLPC string  `json:"unrealized_plpc"`
-	CurrentPrice   flexFloat `json:"current_price"`
+	Symbol         string    `json:"symbol"`
+	Qty            string    `json:"qty"`
+	Side           string    `json:"side"`
+	MarketValue    string    `json:"market_value"`
+	CostBasis      string    `json:"cost_basis"`
+	UnrealizedPL   string    `json:"unrealized_pl"`
+	UnrealizedPLPC string    `json:"unrealized_plpc"`
+	CurrentPrice   FlexFloat `json:"current_price"`
+	AvgEntryPrice  FlexFloat `json:"avg_entry_price"`
 }
 
 // GetPositions returns open positions.
@@ -97,16 +153,28 @@ func (c *TradingClient) GetPositions() ([]Position, error) {
 
 // Order is a single order from GET /v2/orders.
 type Order struct {
-	ID         string    `json:"id"`
-	Symbol     string    `json:"symbol"`
-	Side       string    `json:"side"`
-	Qty        string    `json:"qty"`
-	FilledQty  string    `json:"filled_qty"`
-	Type       string    `json:"type"`
-	Status     string    `json:"status"`
-	LimitPrice *flexFloat `json:"limit_price,omitempty"` // Alpaca may return string or number
-	StopPrice  *flexFloat `json:"stop_price,omitempty"`
-	CreatedAt  string    `json:"created_at"`
+	ID             string     `json:"id"`
+	ClientOrderID  string     `json:"client_order_id"`
+	Symbol         string     `json:"symbol"`
+	Side           string     `json:"side"`
+	Qty            string     `json:"qty"`
+	FilledQty      string     `json:"filled_qty"`
+	FilledAvgPrice *FlexFloat `json:"filled_avg_price,omitempty"`
+	Type           string     `json:"type"`
+	TimeInForce    string     `json:"time_in_force"`
+	Status         string     `json:"status"`
+	LimitPrice     *FlexFloat `json:"limit_price,omitempty"` // Alpaca may return string or number
+	StopPrice      *FlexFloat `json:"stop_price,omitempty"`
+	ExtendedHours  bool       `json:"extended_hours"`
+	CreatedAt      string     `json:"created_at"`
+	UpdatedAt      string     `json:"updated_at"`
+	SubmittedAt    string     `json:"submitted_at"`
+	FilledAt       string     `json:"filled_at"`
+	CanceledAt     string     `json:"canceled_at"`
+	ReplacedBy     string     `json:"replaced_by"`
+	Replaces       string     `json:"replaces"`
+	OrderClass     string     `json:"order_class"`    // "" or "simple", "bracket", "oco", "oto"
+	Legs           []Order    `json:"legs,omitempty"` // an advanced order's exit legs
 }
 
 // GetOpenOrders returns orders with status=open.
@@ -121,3 +189,133 @@ func (c *TradingClient) GetOpenOrders() ([]Order, error) {
 	}
 	return out, nil
 }
+
+// GetOrders returns orders filtered by status ("open", "closed", or "all") created after the given time
+// (zero = no lower bound, exclusive), oldest first. limit is capped at 500 by Alpaca; page with after set
+// to the last order's submitted_at.
+func (c *TradingClient) GetOrders(status string, after time.Time, limit int) ([]Order, error) {
+	if status == "" {
+		status = "open"
+	}
+	if limit <= 0 || limit > 500 {
+		limit = 500
+	}
+	params := url.Values{}
+	params.Set("status", status)
+	params.Set("limit", strconv.Itoa(limit))
+	params.Set("direction", "asc")
+	if !after.IsZero() {
+		params.Set("after", after.UTC().Format(time.RFC3339Nano))
+	}
+	body, err := c.do("GET", "/v2/orders?"+params.Encode())
+	if err != nil {
+		return nil, err
+	}
+	var out []Order
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// OrderRequest is the body for POST /v2/orders. Qty is a string so fractional quantities round-trip exactly;
+// zero prices are omitted.
+type OrderRequest struct {
+	Symbol        string  `json:"symbol"`
+	Qty           string  `json:"qty"`
+	Side          string  `json:"side"`          // "buy" or "sell"
+	Type          string  `json:"type"`          // "market", "limit", "stop", "stop_limit"
+	TimeInForce   string  `json:"time_in_force"` // "day", "gtc", "ioc", ...
+	LimitPrice    float64 `json:"limit_price,omitempty"`
+	StopPrice     float64 `json:"stop_price,omitempty"`
+	ExtendedHours bool    `json:"extended_hours,omitempty"`
+	ClientOrderID string  `json:"client_order_id,omitempty"`
+
+	// Advanced orders: OrderClass "bracket" (entry with take-profit and stop-loss exits), "oco" (exit
+	// with both), "oto" (entry with one exit); empty = simple. The legs are placed with the order, atomically.
+	OrderClass string      `json:"order_class,omitempty"`
+	TakeProfit *TakeProfit `json:"take_profit,omitempty"`
+	StopLoss   *StopLoss   `json:"stop_loss,omitempty"`
+
+	// Engine-side only, never sent: where the intent came from (brain, command, fallback, chase) and the
+	// audit log's correlation ID for it, carried down the order placer chain.
+	Source        string `json:"-"`
+	CorrelationID string `json:"-"`
+}
+
+// TakeProfit is the limit exit leg of an advanced order.
+type TakeProfit struct {
+	LimitPrice float64 `json:"limit_price"`
+}
+
+// StopLoss is the stop exit leg of an advanced order; with LimitPrice it is a stop-limit.
+type StopLoss struct {
+	StopPrice  float64 `json:"stop_price"`
+	LimitPrice float64 `json:"limit_price,omitempty"`
+}
+
+// PlaceOrder submits an order and returns the broker's order record.
+func (c *TradingClient) PlaceOrder(req OrderRequest) (*Order, error) {
+	if req.Type == "" {
+		req.Type = "market"
+	}
+	if req.TimeInForce == "" {
+		req.TimeInForce = "day"
+	}
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := c.doBody("POST", "/v2/orders", payload)
+	if err != nil {
+		return nil, err
+	}
+	var out Order
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CancelOrder requests cancellation of an open order. The final state arrives as a trade update.
+func (c *TradingClient) CancelOrder(id string) error {
+	_, err := c.do("DELETE", "/v2/orders/"+url.PathEscape(id))
+	return err
+}
+
+// CancelAllOrders requests cancellation of every open order.
+func (c *TradingClient) CancelAllOrders() error {
+	_, err := c.do("DELETE", "/v2/orders")
+	return err
+}
+
+// CloseAllPositions liquidates every position at market, canceling open orders first.
+func (c *TradingClient) CloseAllPositions() error {
+	_, err := c.do("DELETE", "/v2/positions?cancel_orders=true")
+	return err
+}
+
+// ReplaceRequest is the body for PATCH /v2/orders/{id}; zero fields are left unchanged.
+type ReplaceRequest struct {
+	Qty         string  `json:"qty,omitempty"`
+	TimeInForce string  `json:"time_in_force,omitempty"`
+	LimitPrice  float64 `json:"limit_price,omitempty"`
+	StopPrice   float64 `json:"stop_price,omitempty"`
+}
+
+// ReplaceOrder modifies an open order. Alpaca cancels it and returns the replacement (new ID).
+func (c *TradingClient) ReplaceOrder(id string, req ReplaceRequest) (*Order, error) {
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := c.doBody("PATCH", "/v2/orders/"+url.PathEscape(id), payload)
+	if err != nil {
+		return nil, err
+	}
+	var out Order
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
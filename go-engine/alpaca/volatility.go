@@ -1,31 +1,153 @@
 package alpaca
 
-import "math"
+import (
+	"errors"
+	"fmt"
+	"math"
+)
 
-// AnnualizedVolatility computes volatility from daily close prices.
-// Bars should be in chronological order (oldest first). Uses log returns
-// and annualizes with 252 trading days. Returns NaN if insufficient data.
-func AnnualizedVolatility(bars []Bar) float64 {
-	if len(bars) < 2 {
-		return math.NaN()
+// Volatility estimators selectable via Options.Method.
+const (
+	MethodClose       = "close"        // close-to-close log returns (sample std dev)
+	MethodEWMA        = "ewma"         // RiskMetrics exponentially weighted
+	MethodParkinson   = "parkinson"    // high/low range
+	MethodGarmanKlass = "garman_klass" // full OHLC
+)
+
+// DefaultEWMALambda is the RiskMetrics decay factor for daily returns.
+const DefaultEWMALambda = 0.94
+
+// ErrInsufficientData is returned (wrapped) when there are too few usable bars for an estimate.
+// Callers should skip or flag the symbol rather than publish a number.
+var ErrInsufficientData = errors.New("insufficient bars for volatility")
+
+// Options configures Volatility. Zero values select the defaults noted per field.
+type Options struct {
+	Window        int     // Use only the most recent Window bars; 0 = all bars
+	Annualization float64 // Periods per year; 0 = 252 (daily bars)
+	MinBars       int     // Minimum usable bars (after Window); 0 = 3, the fewest that give a sample std dev
+	Method        string  // MethodClose (default), MethodEWMA, MethodParkinson, MethodGarmanKlass
+	Lambda        float64 // EWMA decay factor in (0,1); 0 = DefaultEWMALambda
+}
+
+// Volatility returns annualized volatility for bars in chronological order (oldest first).
+// It never returns NaN or Inf: short or degenerate histories yield an error wrapping ErrInsufficientData.
+func Volatility(bars []Bar, opts Options) (float64, error) {
+	if opts.Window > 0 && len(bars) > opts.Window {
+		bars = bars[len(bars)-opts.Window:]
+	}
+	if opts.Annualization <= 0 {
+		opts.Annualization = 252
+	}
+	if opts.MinBars <= 0 {
+		opts.MinBars = 3
+	}
+	var (
+		variance float64
+		usable   int
+	)
+	switch opts.Method {
+	case MethodEWMA:
+		variance, usable = ewmaVariance(bars, opts.Lambda)
+	case MethodParkinson:
+		variance, usable = parkinsonVariance(bars)
+	case MethodGarmanKlass:
+		variance, usable = garmanKlassVariance(bars)
+	case MethodClose, "":
+		variance, usable = closeVariance(bars)
+	default:
+		return 0, fmt.Errorf("unknown volatility method %q", opts.Method)
+	}
+	if usable < opts.MinBars {
+		return 0, fmt.Errorf("%w: %d usable of %d bars, need %d", ErrInsufficientData, usable, len(bars), opts.MinBars)
 	}
+	if math.IsNaN(variance) || math.IsInf(variance, 0) {
+		return 0, fmt.Errorf("%w: non-finite variance", ErrInsufficientData)
+	}
+	if variance <= 0 {
+		return 0, nil
+	}
+	return math.Sqrt(variance * opts.Annualization), nil
+}
+
+// closeVariance is the sample variance of close-to-close log returns. usable counts bars that
+// contributed (returns + 1), so a gap in the data doesn't inflate the sample size.
+func closeVariance(bars []Bar) (variance float64, usable int) {
 	var sum, sumSq float64
-	n := float64(len(bars) - 1)
+	n := 0
 	for i := 1; i < len(bars); i++ {
-		if bars[i-1].Close <= 0 {
+		if bars[i-1].Close <= 0 || bars[i].Close <= 0 {
 			continue
 		}
 		logRet := math.Log(bars[i].Close / bars[i-1].Close)
 		sum += logRet
 		sumSq += logRet * logRet
+		n++
 	}
 	if n < 2 {
-		return math.NaN()
+		return 0, n
 	}
-	variance := (sumSq - sum*sum/n) / (n - 1)
-	if variance <= 0 {
-		return 0
+	fn := float64(n)
+	return (sumSq - sum*sum/fn) / (fn - 1), n + 1
+}
+
+// ewmaVariance is the RiskMetrics recursion var_t = lambda*var_{t-1} + (1-lambda)*r_t^2, seeded with
+// the first squared log return. Recent shocks dominate, unlike the flat close-to-close window.
+func ewmaVariance(bars []Bar, lambda float64) (variance float64, usable int) {
+	if lambda <= 0 || lambda >= 1 {
+		lambda = DefaultEWMALambda
+	}
+	n := 0
+	for i := 1; i < len(bars); i++ {
+		if bars[i-1].Close <= 0 || bars[i].Close <= 0 {
+			continue
+		}
+		logRet := math.Log(bars[i].Close / bars[i-1].Close)
+		if n == 0 {
+			variance = logRet * logRet
+		} else {
+			variance = lambda*variance + (1-lambda)*logRet*logRet
+		}
+		n++
+	}
+	if n == 0 {
+		return 0, 0
+	}
+	return variance, n + 1
+}
+
+// parkinsonVariance uses daily high/low ranges: var = sum(ln(H/L)^2) / (4 ln2 * n). It uses the full bar
+// range rather than just closes, so it is more efficient than close-to-close on the same window.
+func parkinsonVariance(bars []Bar) (variance float64, usable int) {
+	var sum float64
+	for _, b := range bars {
+		if b.High <= 0 || b.Low <= 0 || b.High < b.Low {
+			continue
+		}
+		hl := math.Log(b.High / b.Low)
+		sum += hl * hl
+		usable++
+	}
+	if usable == 0 {
+		return 0, 0
+	}
+	return sum / (4 * math.Ln2 * float64(usable)), usable
+}
+
+// garmanKlassVariance uses full OHLC: var = mean(0.5*ln(H/L)^2 - (2ln2-1)*ln(C/O)^2).
+func garmanKlassVariance(bars []Bar) (variance float64, usable int) {
+	var sum float64
+	for _, b := range bars {
+		if b.Open <= 0 || b.High <= 0 || b.Low <= 0 || b.Close <= 0 || b.High < b.Low {
+			continue
+		}
+		hl := math.Log(b.High / b.Low)
+		co := math.Log(b.Close / b.Open)
+		sum += 0.5*hl*hl - (2*math.Ln2-1)*co*co
+		usable++
+	}
+	if usable == 0 {
+		return 0, 0
 	}
-	// Annualize: multiply daily std dev by sqrt(252)
-	return math.Sqrt(variance * 252)
+	return sum / float64(usable), usable
 }
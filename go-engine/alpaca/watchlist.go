@@ -0,0 +1,80 @@
+package alpaca
+
+import (
+	"encoding/json"
+	"net/url"
+)
+
+// Watchlist is an account watchlist from /v2/watchlists, the lists shown in Alpaca's web UI. Assets are
+// only filled in when a single watchlist is fetched.
+type Watchlist struct {
+	ID     string  `json:"id"`
+	Name   string  `json:"name"`
+	Assets []Asset `json:"assets"`
+}
+
+// Symbols returns the watchlist's symbols in list order.
+func (w *Watchlist) Symbols() []string {
+	out := make([]string, 0, len(w.Assets))
+	for _, a := range w.Assets {
+		out = append(out, a.Symbol)
+	}
+	return out
+}
+
+// findWatchlist returns the ID of the watchlist called name, or "" when there is none.
+func (c *TradingClient) findWatchlist(name string) (string, error) {
+	body, err := c.do("GET", "/v2/watchlists")
+	if err != nil {
+		return "", err
+	}
+	var lists []Watchlist
+	if err := json.Unmarshal(body, &lists); err != nil {
+		return "", err
+	}
+	for _, w := range lists {
+		if w.Name == name {
+			return w.ID, nil
+		}
+	}
+	return "", nil
+}
+
+// GetWatchlist returns the watchlist called name with its assets, or nil when the account has none by
+// that name.
+func (c *TradingClient) GetWatchlist(name string) (*Watchlist, error) {
+	id, err := c.findWatchlist(name)
+	if err != nil || id == "" {
+		return nil, err
+	}
+	body, err := c.do("GET", "/v2/watchlists/"+url.PathEscape(id))
+	if err != nil {
+		return nil, err
+	}
+	var out Watchlist
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SetWatchlist replaces the symbols of the watchlist called name, creating it if needed.
+func (c *TradingClient) SetWatchlist(name string, symbols []string) error {
+	id, err := c.findWatchlist(name)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(struct {
+		Name    string   `json:"name"`
+		Symbols []string `json:"symbols"`
+	}{name, symbols})
+	if err != nil {
+		return err
+	}
+	if id == "" {
+		_, err = c.doBody("POST", "/v2/watchlists", payload)
+		return err
+	}
+	_, err = c.doBody("PUT", "/v2/watchlists/"+url.PathEscape(id), payload)
+	return err
+}
@@ -0,0 +1,346 @@
+// Package audit keeps the engine's audit log: every order intent it receives, the pre-trade checks'
+// decision on it, every broker call that changes the account, every fill and every operator action, one
+// JSON record per line in an append-only file (and, through OnRecord, a Redis stream). Records that
+// belong to one order share a correlation ID, so a fill can be read back to the intent that caused it.
+// The compliance trail, by contrast, follows the broker's view of each order's lifecycle.
+package audit
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sunnyp94/sentry-bridge/go-engine/alpaca"
+	"github.com/sunnyp94/sentry-bridge/go-engine/broker"
+)
+
+// SchemaVersion is bumped whenever Record fields change meaning, so reviewers can parse older files.
+const SchemaVersion = 1
+
+// Record kinds.
+const (
+	KindIntent   = "order_intent"   // an order intent reached the engine's order chain
+	KindDecision = "order_decision" // the pre-trade checks (and the broker) accepted or refused it
+	KindCall     = "api_call"       // a broker call that changes the account, with its outcome and latency
+	KindFill     = "fill"           // a partial or full fill from the trade update stream
+	KindControl  = "control"        // an operator action or a kill switch change
+)
+
+// Calls recorded as api_call.
+const (
+	CallPlaceOrder     = "place_order"
+	CallReplaceOrder   = "replace_order"
+	CallCancelOrder    = "cancel_order"
+	CallCancelAll      = "cancel_all_orders"
+	CallClosePositions = "close_all_positions"
+)
+
+// Record is one line of the audit log. Fields that don't apply to a kind are omitted; quantities are the
+// strings the engine and broker use, so nothing is lost to float formatting.
+type Record struct {
+	Schema        int      `json:"schema"`
+	Seq           uint64   `json:"seq"` // from 1 when the log opens; a gap in the stream means a lost record
+	At            string   `json:"at"`  // RFC3339Nano UTC, when the engine recorded it
+	Kind          string   `json:"kind"`
+	CorrelationID string   `json:"correlation_id,omitempty"` // shared by an order's intent, decision, calls and fills
+	Source        string   `json:"source,omitempty"`         // intents: brain, command, fallback, chase, engine; control: http, command, daily_loss
+	Symbol        string   `json:"symbol,omitempty"`
+	Side          string   `json:"side,omitempty"`
+	Qty           string   `json:"qty,omitempty"`
+	OrderType     string   `json:"order_type,omitempty"`
+	TimeInForce   string   `json:"time_in_force,omitempty"`
+	LimitPrice    float64  `json:"limit_price,omitempty"`
+	StopPrice     float64  `json:"stop_price,omitempty"`
+	OrderClass    string   `json:"order_class,omitempty"`
+	OrderID       string   `json:"order_id,omitempty"`
+	ClientOrderID string   `json:"client_order_id,omitempty"`
+	Call          string   `json:"call,omitempty"` // api_call: one of the Call* constants
+	Broker        string   `json:"broker,omitempty"`
+	LatencyMs     float64  `json:"latency_ms,omitempty"`
+	Action        string   `json:"action,omitempty"`  // control: pause, resume, flatten, kill
+	Outcome       string   `json:"outcome,omitempty"` // order_decision: accepted or refused; api_call and control: ok or error; fill: fill or partial_fill
+	Reason        string   `json:"reason,omitempty"`  // why an intent was refused, the call's error, or the operator's reason
+	FillQty       float64  `json:"fill_qty,omitempty"`
+	FillPrice     float64  `json:"fill_price,omitempty"`
+	FilledQty     string   `json:"filled_qty,omitempty"`   // the order's cumulative filled qty
+	PositionQty   *float64 `json:"position_qty,omitempty"` // fill: signed position after it
+	EventTime     string   `json:"event_time,omitempty"`   // fill: the broker's timestamp
+	ExecutionID   string   `json:"execution_id,omitempty"` // fill: the broker's execution ID
+	Detail        string   `json:"detail,omitempty"`       // anything else worth keeping
+}
+
+// Log appends Records to a file and hands each to OnRecord. All methods are no-ops on a nil Log, so the
+// engine can call it unconditionally. Safe for concurrent use.
+type Log struct {
+	mu   sync.Mutex
+	file *os.File
+	seq  uint64
+
+	// OnRecord receives each record after it is written, e.g. for the audit Redis stream. It must not
+	// block. Optional; set before use.
+	OnRecord func(Record)
+	// Correlation returns the correlation ID of a broker order, for replace and cancel calls. Optional;
+	// set before use.
+	Correlation func(orderID string) string
+}
+
+// Open appends to the file at path, creating it and its directory; an empty path keeps no file (records
+// only go to OnRecord).
+func Open(path string) (*Log, error) {
+	l := &Log{}
+	if path == "" {
+		return l, nil
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o750); err != nil {
+			return nil, fmt.Errorf("audit log dir %s: %w", dir, err)
+		}
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o640)
+	if err != nil {
+		return nil, fmt.Errorf("audit log: %w", err)
+	}
+	l.file = f
+	return l, nil
+}
+
+// NewID returns a random correlation ID (16 hex digits).
+func NewID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// Record numbers, timestamps and writes r. Each record is written with one write call, so lines never
+// interleave; a failed write is logged and the record still goes to OnRecord.
+func (l *Log) Record(r Record) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.seq++
+	r.Schema, r.Seq = SchemaVersion, l.seq
+	if r.At == "" {
+		r.At = time.Now().UTC().Format(time.RFC3339Nano)
+	}
+	if l.file != nil {
+		line, err := json.Marshal(r)
+		if err == nil {
+			_, err = l.file.Write(append(line, '\n'))
+		}
+		if err != nil {
+			slog.Error("audit log write failed", "kind", r.Kind, "seq", r.Seq, "err", err)
+		}
+	}
+	if l.OnRecord != nil {
+		l.OnRecord(r)
+	}
+}
+
+// Close closes the file.
+func (l *Log) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// correlation returns the correlation ID of a broker order; "" when it isn't known.
+func (l *Log) correlation(orderID string) string {
+	if l.Correlation == nil {
+		return ""
+	}
+	return l.Correlation(orderID)
+}
+
+// Invalid records an intent that failed validation and never reached the order chain, and its refusal.
+func (l *Log) Invalid(req alpaca.OrderRequest, err error) {
+	if l == nil {
+		return
+	}
+	if req.CorrelationID == "" {
+		req.CorrelationID = NewID()
+	}
+	l.Record(intentRecord(KindIntent, req))
+	rec := intentRecord(KindDecision, req)
+	rec.Outcome, rec.Reason = "refused", err.Error()
+	l.Record(rec)
+}
+
+// OnTradeUpdate records fills; correlationID ties one to its intent when the engine placed the order.
+func (l *Log) OnTradeUpdate(u alpaca.TradeUpdate, correlationID string) {
+	if l == nil || (u.Event != "fill" && u.Event != "partial_fill") {
+		return
+	}
+	o := u.Order
+	rec := Record{
+		Kind:          KindFill,
+		CorrelationID: correlationID,
+		Symbol:        strings.ToUpper(o.Symbol),
+		Side:          o.Side,
+		Qty:           o.Qty,
+		OrderType:     o.Type,
+		OrderID:       o.ID,
+		ClientOrderID: o.ClientOrderID,
+		Outcome:       u.Event,
+		FillQty:       u.Qty.Value(),
+		FillPrice:     u.Price.Value(),
+		FilledQty:     o.FilledQty,
+		EventTime:     u.Timestamp,
+		ExecutionID:   u.ExecutionID,
+	}
+	if u.PositionQty != nil {
+		qty := u.PositionQty.Value()
+		rec.PositionQty = &qty
+	}
+	l.Record(rec)
+}
+
+// Orders wraps an order placer so each intent that reaches it is recorded, then the decision on it. An
+// intent without a correlation ID (one not tied to a market event) gets a new one, which the broker calls
+// and fills it leads to share.
+// Returns next itself on a nil Log.
+func (l *Log) Orders(next alpaca.OrderPlacer) alpaca.OrderPlacer {
+	if l == nil {
+		return next
+	}
+	return &auditedOrders{next: next, l: l}
+}
+
+type auditedOrders struct {
+	next alpaca.OrderPlacer
+	l    *Log
+}
+
+func (a *auditedOrders) PlaceOrder(req alpaca.OrderRequest) (*alpaca.Order, error) {
+	if req.CorrelationID == "" {
+		req.CorrelationID = NewID()
+	}
+	a.l.Record(intentRecord(KindIntent, req))
+	o, err := a.next.PlaceOrder(req)
+	rec := intentRecord(KindDecision, req)
+	if err != nil {
+		rec.Outcome, rec.Reason = "refused", err.Error()
+	} else {
+		rec.Outcome = "accepted"
+		if o != nil {
+			rec.OrderID = o.ID
+		}
+	}
+	a.l.Record(rec)
+	return o, err
+}
+
+// CheckOrder keeps the chain's dry-run checks reachable through the wrapper; checks are not recorded.
+func (a *auditedOrders) CheckOrder(req alpaca.OrderRequest) (alpaca.OrderRequest, error) {
+	return alpaca.CheckOrder(a.next, req)
+}
+
+func intentRecord(kind string, req alpaca.OrderRequest) Record {
+	source := req.Source
+	if source == "" {
+		source = "engine"
+	}
+	return Record{
+		Kind:          kind,
+		CorrelationID: req.CorrelationID,
+		Source:        source,
+		Symbol:        strings.ToUpper(req.Symbol),
+		Side:          req.Side,
+		Qty:           req.Qty,
+		OrderType:     req.Type,
+		TimeInForce:   req.TimeInForce,
+		LimitPrice:    req.LimitPrice,
+		StopPrice:     req.StopPrice,
+		OrderClass:    req.OrderClass,
+		ClientOrderID: req.ClientOrderID,
+	}
+}
+
+// Broker wraps a broker so every call that changes the account is recorded as an api_call with its
+// outcome and latency. Reads are not recorded. Returns next itself on a nil Log.
+func (l *Log) Broker(next broker.Broker) broker.Broker {
+	if l == nil {
+		return next
+	}
+	return &auditedBroker{Broker: next, l: l}
+}
+
+type auditedBroker struct {
+	broker.Broker
+	l *Log
+}
+
+// call records one broker call that took since start and ended with err.
+func (b *auditedBroker) call(rec Record, start time.Time, err error) {
+	rec.Kind, rec.Broker = KindCall, b.Name()
+	rec.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+	rec.Outcome = "ok"
+	if err != nil {
+		rec.Outcome, rec.Reason = "error", err.Error()
+	}
+	b.l.Record(rec)
+}
+
+func (b *auditedBroker) PlaceOrder(req alpaca.OrderRequest) (*alpaca.Order, error) {
+	start := time.Now()
+	o, err := b.Broker.PlaceOrder(req)
+	rec := intentRecord(KindCall, req)
+	rec.Source, rec.Call = "", CallPlaceOrder
+	if err == nil && o != nil {
+		rec.OrderID = o.ID
+	}
+	b.call(rec, start, err)
+	return o, err
+}
+
+func (b *auditedBroker) ReplaceOrder(id string, req alpaca.ReplaceRequest) (*alpaca.Order, error) {
+	// Looked up first: the order's trade updates may retire its ID before the call returns
+	rec := Record{Call: CallReplaceOrder, CorrelationID: b.l.correlation(id), OrderID: id, Qty: req.Qty, LimitPrice: req.LimitPrice,
+		StopPrice: req.StopPrice, TimeInForce: req.TimeInForce}
+	start := time.Now()
+	o, err := b.Broker.ReplaceOrder(id, req)
+	if err == nil && o != nil {
+		rec.Symbol = strings.ToUpper(o.Symbol)
+		rec.Detail = "replaced by " + o.ID
+	}
+	b.call(rec, start, err)
+	return o, err
+}
+
+func (b *auditedBroker) CancelOrder(id string) error {
+	rec := Record{Call: CallCancelOrder, CorrelationID: b.l.correlation(id), OrderID: id}
+	start := time.Now()
+	err := b.Broker.CancelOrder(id)
+	b.call(rec, start, err)
+	return err
+}
+
+func (b *auditedBroker) CancelAllOrders() error {
+	start := time.Now()
+	err := b.Broker.CancelAllOrders()
+	b.call(Record{Call: CallCancelAll}, start, err)
+	return err
+}
+
+func (b *auditedBroker) CloseAllPositions() error {
+	start := time.Now()
+	err := b.Broker.CloseAllPositions()
+	b.call(Record{Call: CallClosePositions}, start, err)
+	return err
+}
@@ -0,0 +1,99 @@
+package brain
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Session labels besides pre_open, regular and post_close.
+const (
+	SessionClosed     = "closed"      // weekend or market holiday, all day
+	SessionEarlyClose = "early_close" // after the close on a half-day
+)
+
+// Regular session in minutes after midnight ET (9:30 and 16:00).
+const (
+	regularOpen  = 570
+	regularClose = 960
+)
+
+// TradingDay is one day of the broker calendar: ET date and regular open/close as "HH:MM".
+type TradingDay struct {
+	Date  string
+	Open  string
+	Close string
+}
+
+// Calendar knows the holidays and half-days in a date range. Weekdays in the range without a trading
+// day are holidays; outside it, every weekday is a full day. A nil Calendar only knows weekends.
+type Calendar struct {
+	from, to string // YYYY-MM-DD, inclusive
+	days     map[string][2]int
+}
+
+// NewCalendar covers from through to (YYYY-MM-DD) with the given trading days. Days with an unreadable
+// open or close keep the regular hours.
+func NewCalendar(from, to string, days []TradingDay) *Calendar {
+	c := &Calendar{from: from, to: to, days: make(map[string][2]int, len(days))}
+	for _, d := range days {
+		open, close := minutesET(d.Open, regularOpen), minutesET(d.Close, regularClose)
+		c.days[d.Date] = [2]int{open, close}
+	}
+	return c
+}
+
+func minutesET(hhmm string, def int) int {
+	t, err := time.Parse("15:04", hhmm)
+	if err != nil {
+		return def
+	}
+	return t.Hour()*60 + t.Minute()
+}
+
+// hours returns the regular open and close for t's ET date; false when the market is closed that day.
+func (c *Calendar) hours(t time.Time) (open, close int, ok bool) {
+	et := t.In(eastern)
+	if wd := et.Weekday(); wd == time.Saturday || wd == time.Sunday {
+		return 0, 0, false
+	}
+	if c == nil {
+		return regularOpen, regularClose, true
+	}
+	day := et.Format("2006-01-02")
+	if day < c.from || day > c.to {
+		return regularOpen, regularClose, true
+	}
+	h, ok := c.days[day]
+	return h[0], h[1], ok
+}
+
+// Closed reports whether the market is closed all day on t's ET date.
+func (c *Calendar) Closed(t time.Time) bool {
+	_, _, ok := c.hours(t)
+	return !ok
+}
+
+// Session labels now: closed, pre_open, regular, early_close (after a half-day's close) or post_close.
+func (c *Calendar) Session(now time.Time) string {
+	open, close, ok := c.hours(now)
+	if !ok {
+		return SessionClosed
+	}
+	et := now.In(eastern)
+	minutes := et.Hour()*60 + et.Minute()
+	switch {
+	case minutes < open:
+		return "pre_open"
+	case minutes >= close && close < regularClose:
+		return SessionEarlyClose
+	case minutes >= close:
+		return "post_close"
+	}
+	return "regular"
+}
+
+// calendar is the Calendar used by Session; nil until SetCalendar.
+var calendar atomic.Pointer[Calendar]
+
+// SetCalendar replaces the calendar Session uses.
+func SetCalendar(c *Calendar) { calendar.Store(c) }
@@ -0,0 +1,126 @@
+package brain
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// checkpointVersion is bumped when the checkpoint layout changes; older checkpoints are ignored.
+const checkpointVersion = 2
+
+// checkpoint is State as written to disk or Redis (msgpack, json field names).
+type checkpoint struct {
+	Version int                          `json:"version"`
+	Saved   time.Time                    `json:"saved"`
+	Symbols map[string]*symbolCheckpoint `json:"symbols"`
+	Vol     *VolSnapshot                 `json:"vol,omitempty"`
+}
+
+type symbolCheckpoint struct {
+	Prices      []Tick         `json:"prices,omitempty"`  // price points (size unused)
+	Volumes     []Tick         `json:"volumes,omitempty"` // volume points
+	Ticks       []Tick         `json:"ticks,omitempty"`
+	Quote       *QuoteSnapshot `json:"quote,omitempty"`
+	Day         int            `json:"day,omitempty"` // ET date (yyyymmdd) of the day counters
+	DayVolume   int64          `json:"day_volume,omitempty"`
+	DayNotional float64        `json:"day_notional,omitempty"`
+}
+
+// Checkpoint encodes the price/volume history, recent ticks, latest quotes, today's volume and VWAP
+// totals and the current volatility snapshot, for Restore after a restart.
+func (s *State) Checkpoint() ([]byte, error) {
+	s.mu.RLock()
+	cp := checkpoint{Version: checkpointVersion, Saved: s.clock.Now(), Symbols: make(map[string]*symbolCheckpoint, len(s.symbols))}
+	symbols := make(map[string]*symbolState, len(s.symbols))
+	for symbol, ss := range s.symbols {
+		symbols[symbol] = ss
+	}
+	s.mu.RUnlock()
+	for symbol, ss := range symbols {
+		ss.mu.RLock()
+		c := &symbolCheckpoint{Ticks: ss.ticks.last(0), Day: ss.day, DayVolume: ss.dayVolume, DayNotional: ss.dayNotional}
+		for i := 0; i < ss.prices.len(); i++ {
+			p := ss.prices.at(i)
+			c.Prices = append(c.Prices, Tick{Time: p.t, Price: p.p})
+		}
+		for i := 0; i < ss.volumes.len(); i++ {
+			p := ss.volumes.at(i)
+			c.Volumes = append(c.Volumes, Tick{Time: p.t, Price: p.p, Size: p.v})
+		}
+		if ss.hasQuote {
+			q := ss.quote
+			c.Quote = &q
+		}
+		ss.mu.RUnlock()
+		cp.Symbols[symbol] = c
+	}
+	if v := s.vol.Load(); v != nil {
+		cp.Vol = v
+	}
+
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	enc.UseCompactInts(true)
+	if err := enc.Encode(&cp); err != nil {
+		return nil, fmt.Errorf("state checkpoint: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Restore loads a Checkpoint into an empty State (call before recording or Seed), so a quick restart
+// keeps the lookback windows. A checkpoint saved more than maxAge ago is ignored; points that have since
+// left the lookback window are dropped, and the day counters are kept only on the same ET day. The
+// volatility snapshot is restored unless one was already published. Returns the symbols restored.
+func (s *State) Restore(data []byte, maxAge time.Duration) (int, error) {
+	var cp checkpoint
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	if err := dec.Decode(&cp); err != nil {
+		return 0, fmt.Errorf("state checkpoint: %w", err)
+	}
+	if cp.Version != checkpointVersion {
+		return 0, fmt.Errorf("state checkpoint: version %d, want %d", cp.Version, checkpointVersion)
+	}
+	s.mu.RLock()
+	now := s.clock.Now()
+	s.mu.RUnlock()
+	if age := now.Sub(cp.Saved); age > maxAge {
+		return 0, fmt.Errorf("state checkpoint: saved %s ago, more than %s", age.Round(time.Second), maxAge)
+	}
+	cut := now.Add(-lookback)
+	today := etDate(now)
+	restored := 0
+	for symbol, c := range cp.Symbols {
+		ss, _ := s.symbol(symbol, true)
+		ss.mu.Lock()
+		for _, p := range c.Prices {
+			if !p.Time.Before(cut) {
+				ss.prices.push(pricePoint{t: p.Time, p: p.Price})
+			}
+		}
+		for _, p := range c.Volumes {
+			if !p.Time.Before(cut) {
+				ss.pushVolume(volumePoint{t: p.Time, v: p.Size, p: p.Price})
+			}
+		}
+		for _, t := range c.Ticks {
+			ss.ticks.push(t)
+		}
+		if c.Quote != nil {
+			ss.quote, ss.hasQuote = *c.Quote, true
+		}
+		if c.Day == today {
+			ss.day, ss.dayVolume, ss.dayNotional = c.Day, c.DayVolume, c.DayNotional
+		}
+		ss.mu.Unlock()
+		restored++
+	}
+	if cp.Vol != nil && s.vol.Load() == nil {
+		s.vol.Store(cp.Vol)
+	}
+	return restored, nil
+}
@@ -0,0 +1,211 @@
+package brain
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/sunnyp94/sentry-bridge/go-engine/events"
+)
+
+// Wire encodings for events written to the brain's stdin (BRAIN_ENCODING). The brain process gets the
+// choice in its environment as BRAIN_ENCODING.
+const (
+	EncodingJSON     = "json"     // one JSON envelope per line (default)
+	EncodingMsgpack  = "msgpack"  // MessagePack envelope with the JSON field names, length-prefixed
+	EncodingProtobuf = "protobuf" // brain.proto Envelope (native trade/quote, JSON payload otherwise), length-prefixed
+)
+
+// encoder serializes one envelope. Binary encodings are framed with a 4-byte big-endian length instead
+// of a trailing newline.
+type encoder struct {
+	name   string
+	encode func(events.Envelope) ([]byte, error)
+	framed bool
+}
+
+// newEncoder returns the encoder for name ("" = JSON).
+func newEncoder(name string) (encoder, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", EncodingJSON:
+		return encoder{name: EncodingJSON, encode: encodeJSON}, nil
+	case EncodingMsgpack:
+		return encoder{name: EncodingMsgpack, encode: encodeMsgpack, framed: true}, nil
+	case EncodingProtobuf:
+		return encoder{name: EncodingProtobuf, encode: encodeProtobuf, framed: true}, nil
+	}
+	return encoder{}, fmt.Errorf("brain encoding %q: want json, msgpack or protobuf", name)
+}
+
+func encodeJSON(ev events.Envelope) ([]byte, error) {
+	return json.Marshal(ev)
+}
+
+func encodeMsgpack(ev events.Envelope) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.GetEncoder()
+	defer msgpack.PutEncoder(enc)
+	enc.Reset(&buf)
+	enc.SetCustomStructTag("json")
+	enc.UseCompactInts(true)
+	if err := enc.Encode(ev); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Field numbers from brain.proto.
+const (
+	envType        = 1
+	envTS          = 2
+	envPayloadJSON = 3
+	envTrade       = 4
+	envQuote       = 5
+	envTags        = 6
+	envExpires     = 7
+	envSeq         = 8
+	envID          = 9
+)
+
+// encodeProtobuf writes the brain.proto Envelope. Trades and quotes (the hot path) are native messages;
+// every other event type is carried as JSON in payload_json.
+func encodeProtobuf(ev events.Envelope) ([]byte, error) {
+	b := make([]byte, 0, 128)
+	b = appendString(b, envType, ev.Type)
+	b = appendString(b, envTS, ev.TS)
+	switch p := ev.Payload.(type) {
+	case events.TradeEvent:
+		b = appendMessage(b, envTrade, appendTrade(nil, p))
+	case events.QuoteEvent:
+		b = appendMessage(b, envQuote, appendQuote(nil, p))
+	default:
+		js, err := json.Marshal(ev.Payload)
+		if err != nil {
+			return nil, err
+		}
+		b = protowire.AppendTag(b, envPayloadJSON, protowire.BytesType)
+		b = protowire.AppendBytes(b, js)
+	}
+	for _, t := range ev.Tags {
+		b = appendString(b, envTags, t)
+	}
+	b = appendString(b, envExpires, ev.Expires)
+	b = appendInt(b, envSeq, int64(ev.Seq))
+	return appendString(b, envID, ev.ID), nil
+}
+
+func appendTrade(b []byte, t events.TradeEvent) []byte {
+	b = appendString(b, 1, t.Symbol)
+	b = appendDouble(b, 2, t.Price)
+	b = appendInt(b, 3, int64(t.Size))
+	b = appendInt(b, 4, t.Volume1m)
+	b = appendInt(b, 5, t.Volume5m)
+	b = appendDouble(b, 6, t.Return1m)
+	b = appendDouble(b, 7, t.Return5m)
+	b = appendString(b, 8, t.Session)
+	b = appendDouble(b, 9, t.Volatility)
+	b = appendDoubles(b, 10, t.Features)
+	b = appendInt(b, 11, int64(t.FeatureSchema))
+	b = appendOptionalDouble(b, 12, t.ModelScore)
+	b = appendString(b, 13, t.ExchangeTS)
+	b = appendString(b, 14, t.ReceivedTS)
+	b = appendBool(b, 15, t.Stale)
+	b = appendDouble(b, 16, t.AgeMs)
+	for _, c := range t.Conditions {
+		b = protowire.AppendTag(b, 17, protowire.BytesType)
+		b = protowire.AppendString(b, c)
+	}
+	b = appendString(b, 18, t.Exchange)
+	b = appendString(b, 19, t.Tape)
+	b = appendString(b, 20, t.ExpiryContext)
+	return appendInt(b, 21, t.VolVersion)
+}
+
+func appendQuote(b []byte, q events.QuoteEvent) []byte {
+	b = appendString(b, 1, q.Symbol)
+	b = appendDouble(b, 2, q.Bid)
+	b = appendDouble(b, 3, q.Ask)
+	b = appendInt(b, 4, int64(q.BidSize))
+	b = appendInt(b, 5, int64(q.AskSize))
+	b = appendDouble(b, 6, q.Mid)
+	b = appendInt(b, 7, q.Volume1m)
+	b = appendInt(b, 8, q.Volume5m)
+	b = appendDouble(b, 9, q.Return1m)
+	b = appendDouble(b, 10, q.Return5m)
+	b = appendString(b, 11, q.Session)
+	b = appendDouble(b, 12, q.Volatility)
+	b = appendDoubles(b, 13, q.Features)
+	b = appendInt(b, 14, int64(q.FeatureSchema))
+	b = appendOptionalDouble(b, 15, q.ModelScore)
+	b = appendDouble(b, 16, q.SpreadBps)
+	b = appendString(b, 17, q.ExchangeTS)
+	b = appendString(b, 18, q.ReceivedTS)
+	b = appendBool(b, 19, q.Stale)
+	b = appendDouble(b, 20, q.AgeMs)
+	b = appendBool(b, 21, q.Halted)
+	b = appendString(b, 22, q.ExpiryContext)
+	return appendInt(b, 23, q.VolVersion)
+}
+
+// proto3 scalars: zero values are not written.
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func appendDouble(b []byte, num protowire.Number, v float64) []byte {
+	if v == 0 {
+		return b
+	}
+	return appendOptionalDouble(b, num, &v)
+}
+
+func appendOptionalDouble(b []byte, num protowire.Number, v *float64) []byte {
+	if v == nil {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, math.Float64bits(*v))
+}
+
+func appendInt(b []byte, num protowire.Number, v int64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, uint64(v))
+}
+
+func appendBool(b []byte, num protowire.Number, v bool) []byte {
+	if !v {
+		return b
+	}
+	return appendInt(b, num, 1)
+}
+
+// appendDoubles writes a packed repeated double.
+func appendDoubles(b []byte, num protowire.Number, vs []float64) []byte {
+	if len(vs) == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	b = protowire.AppendVarint(b, uint64(8*len(vs)))
+	for _, v := range vs {
+		b = protowire.AppendFixed64(b, math.Float64bits(v))
+	}
+	return b
+}
+
+func appendMessage(b []byte, num protowire.Number, msg []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, msg)
+}
@@ -0,0 +1,53 @@
+package brain
+
+import "time"
+
+// Option expiry kinds, from least to most significant.
+const (
+	ExpiryWeekly         = "weekly"          // standard weekly options (every Friday)
+	ExpiryMonthly        = "monthly"         // third Friday: monthly equity options
+	ExpiryTripleWitching = "triple_witching" // third Friday of Mar/Jun/Sep/Dec: stock options, index options and index futures
+)
+
+// Expiry is the standard options expiration on or after a day.
+type Expiry struct {
+	Date    string `json:"date"` // YYYY-MM-DD (ET)
+	Kind    string `json:"kind"`
+	Shifted bool   `json:"shifted,omitempty"` // moved to Thursday because the Friday is a market holiday
+}
+
+// NextExpiry returns the first standard expiration on or after day's ET date. Expirations are Fridays,
+// or the Thursday before when closed reports the Friday as a market holiday (nil = no holidays). The
+// kind is that of the Friday, so a shifted monthly is still monthly.
+func NextExpiry(day time.Time, closed func(time.Time) bool) Expiry {
+	y, m, dd := day.In(eastern).Date()
+	d := time.Date(y, m, dd, 0, 0, 0, 0, eastern)
+	for {
+		fri := d.AddDate(0, 0, (int(time.Friday)-int(d.Weekday())+7)%7)
+		exp := fri
+		if closed != nil && closed(fri) {
+			exp = fri.AddDate(0, 0, -1)
+		}
+		if exp.Before(d) {
+			d = fri.AddDate(0, 0, 1) // holiday Friday after the Thursday expiry: next week's
+			continue
+		}
+		e := Expiry{Date: exp.Format("2006-01-02"), Kind: ExpiryWeekly, Shifted: !exp.Equal(fri)}
+		if fri.Day() >= 15 && fri.Day() <= 21 {
+			e.Kind = ExpiryMonthly
+			if fri.Month()%3 == 0 {
+				e.Kind = ExpiryTripleWitching
+			}
+		}
+		return e
+	}
+}
+
+// ExpiryContext is the expiry kind if day's ET date is an expiration day, else "".
+func ExpiryContext(day time.Time, closed func(time.Time) bool) string {
+	e := NextExpiry(day, closed)
+	if e.Date != day.In(eastern).Format("2006-01-02") {
+		return ""
+	}
+	return e.Kind
+}
@@ -0,0 +1,76 @@
+package brain
+
+// FeatureSchemaVersion identifies the order and meaning of FeatureNames. Bump it whenever a feature is
+// added, removed, or reordered so models trained on an older layout can refuse mismatched vectors.
+const FeatureSchemaVersion = 1
+
+// FeatureNames is the fixed layout of the vector built by FeatureVector. New features are appended.
+var FeatureNames = []string{
+	"is_trade",   // 1 for trade events, 0 for quotes
+	"price",      // trade price or quote mid
+	"size",       // trade size (0 for quotes)
+	"bid",        // latest bid (0 if no quote yet)
+	"ask",        // latest ask
+	"bid_size",   // latest bid size
+	"ask_size",   // latest ask size
+	"spread_bps", // (ask-bid)/mid in basis points
+	"imbalance",  // (bid_size-ask_size)/(bid_size+ask_size)
+	"volume_1m",
+	"volume_5m",
+	"return_1m",
+	"return_5m",
+	"volatility", // annualized 30d
+	"session",    // 0 pre_open, 1 regular, 2 post_close
+}
+
+// FeatureInput holds the raw values for one event; fields map 1:1 to FeatureNames.
+type FeatureInput struct {
+	IsTrade    bool
+	Price      float64
+	Size       int
+	Quote      QuoteSnapshot
+	Volume1m   int64
+	Volume5m   int64
+	Return1m   float64
+	Return5m   float64
+	Volatility float64
+	Session    string
+}
+
+// FeatureVector returns the ordered float vector for in (len == len(FeatureNames)), so ML brains can feed
+// it straight into a model without per-message dict parsing.
+func FeatureVector(in FeatureInput) []float64 {
+	isTrade := 0.0
+	if in.IsTrade {
+		isTrade = 1
+	}
+	return []float64{
+		isTrade,
+		in.Price,
+		float64(in.Size),
+		in.Quote.Bid,
+		in.Quote.Ask,
+		float64(in.Quote.BidSize),
+		float64(in.Quote.AskSize),
+		in.Quote.SpreadBps,
+		in.Quote.Imbalance,
+		float64(in.Volume1m),
+		float64(in.Volume5m),
+		in.Return1m,
+		in.Return5m,
+		in.Volatility,
+		SessionCode(in.Session),
+	}
+}
+
+// SessionCode maps Session() labels to numbers for feature vectors. An early close codes as post_close
+// and a closed day as pre_open, so the schema is unchanged.
+func SessionCode(session string) float64 {
+	switch session {
+	case "regular":
+		return 1
+	case "post_close", SessionEarlyClose:
+		return 2
+	}
+	return 0
+}
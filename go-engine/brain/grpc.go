@@ -0,0 +1,310 @@
+package brain
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Brain transports (BRAIN_TRANSPORT).
+const (
+	TransportPipe = "pipe" // child process on stdin/stdout (default)
+	TransportGRPC = "grpc" // remote brain streaming over gRPC (service sentry.brain.Brain in brain.proto)
+)
+
+// errListenerClosed ends a pending accept when the pipe is closed.
+var errListenerClosed = errors.New("brain gRPC listener closed")
+
+// ListenGRPC serves the Brain service on addr and returns a Pipe whose brain is the connected client, so
+// the brain can run in another container or host. Events are brain.proto Envelopes (opts.Encoding is
+// ignored); the client sends Decision messages, the same ready/request lines a stdin brain writes. One
+// client is served at a time: a second one waits until the first disconnects, and a reconnecting brain
+// gets the ready handshake, snapshot and buffered replay just like a restarted process.
+//
+// The brain can place orders, so a stream is only accepted with the bearer token opts.GRPCToken (in the
+// "authorization" metadata) when one is set, and a token is required unless addr is a loopback address.
+// With opts.GRPCTLSCert and GRPCTLSKey the server speaks TLS.
+func ListenGRPC(addr string, opts PipeOptions) (*Pipe, error) {
+	if opts.GRPCToken == "" && !loopback(addr) {
+		return nil, fmt.Errorf("brain gRPC: %s is not a loopback address; set a token (BRAIN_GRPC_TOKEN)", addr)
+	}
+	serverOpts := []grpc.ServerOption{grpc.ForceServerCodec(rawCodec{})}
+	if opts.GRPCTLSCert != "" || opts.GRPCTLSKey != "" {
+		creds, err := credentials.NewServerTLSFromFile(opts.GRPCTLSCert, opts.GRPCTLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("brain gRPC: %w", err)
+		}
+		serverOpts = append(serverOpts, grpc.Creds(creds))
+	}
+	opts.Encoding = EncodingProtobuf
+	p, err := newPipe(opts)
+	if err != nil {
+		return nil, err
+	}
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		p.release()
+		return nil, err
+	}
+	l := &grpcListener{token: opts.GRPCToken, sessions: make(chan *grpcSession), closed: make(chan struct{})}
+	l.srv = grpc.NewServer(serverOpts...)
+	l.srv.RegisterService(&brainServiceDesc, l)
+	go func() {
+		if err := l.srv.Serve(lis); err != nil {
+			p.log.Error("brain gRPC server stopped", "addr", addr, "err", err)
+		}
+	}()
+	p.listen = lis.Addr().String()
+	p.start = l.accept
+	p.stop = l.close
+	go p.writer()
+	go p.supervisor()
+	return p, nil
+}
+
+// brainServiceDesc is service Brain from brain.proto, registered without generated code: messages are
+// passed through as encoded bytes (rawCodec).
+var brainServiceDesc = grpc.ServiceDesc{
+	ServiceName: "sentry.brain.Brain",
+	HandlerType: (*interface{})(nil),
+	Streams: []grpc.StreamDesc{{
+		StreamName:    "Stream",
+		Handler:       func(srv interface{}, st grpc.ServerStream) error { return srv.(*grpcListener).stream(st) },
+		ServerStreams: true,
+		ClientStreams: true,
+	}},
+	Metadata: "brain.proto",
+}
+
+// rawCodec sends []byte as-is and receives into *[]byte. It is named "proto" so clients generated from
+// brain.proto interoperate.
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	b, ok := v.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("raw codec: cannot marshal %T", v)
+	}
+	return b, nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return fmt.Errorf("raw codec: cannot unmarshal into %T", v)
+	}
+	*b = append((*b)[:0], data...)
+	return nil
+}
+
+func (rawCodec) Name() string { return "proto" }
+
+// loopback reports whether addr (host:port) only listens on the local machine. An empty host is every
+// interface.
+func loopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil || host == "" {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// grpcListener hands connected streams to the pipe's supervisor one at a time.
+type grpcListener struct {
+	token     string // bearer token a stream must present; empty = none
+	srv       *grpc.Server
+	sessions  chan *grpcSession
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// accept blocks until a brain connects and returns its session as a process.
+func (l *grpcListener) accept() (*process, error) {
+	select {
+	case s := <-l.sessions:
+		return &process{stdin: s, stdout: s.stdout, readers: &sync.WaitGroup{}, wait: s.wait, peer: s.peer}, nil
+	case <-l.closed:
+		return nil, errListenerClosed
+	}
+}
+
+func (l *grpcListener) close() {
+	l.closeOnce.Do(func() {
+		close(l.closed)
+		l.srv.GracefulStop()
+	})
+}
+
+// stream serves one Brain.Stream call: it waits to be accepted, then forwards Decisions to the pipe's
+// reader as JSON lines until the client goes away or the pipe ends the session.
+func (l *grpcListener) stream(st grpc.ServerStream) error {
+	ctx := st.Context()
+	if !l.authorized(ctx) {
+		return status.Error(codes.Unauthenticated, "bad or missing token")
+	}
+	pr, pw := io.Pipe()
+	s := &grpcSession{stream: st, stdout: pr, ended: make(chan struct{}), done: make(chan struct{})}
+	if pe, ok := peer.FromContext(ctx); ok {
+		s.peer = pe.Addr.String()
+	}
+	defer close(s.done)
+	select {
+	case l.sessions <- s:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-l.closed:
+		return status.Error(codes.Unavailable, "engine shutting down")
+	}
+	go s.recvLoop(pw)
+	select {
+	case <-s.ended:
+	case <-ctx.Done():
+	case <-l.closed:
+	}
+	return nil
+}
+
+// grpcSession is one connected brain. It is the process's stdin (Write re-frames encoded envelopes into
+// messages) and feeds its stdout from received Decisions.
+type grpcSession struct {
+	stream  grpc.ServerStream
+	stdout  *io.PipeReader
+	peer    string
+	buf     []byte // partial length-prefixed frame from the pipe writer
+	ended   chan struct{}
+	endOnce sync.Once
+	done    chan struct{} // closed when the stream handler returns
+}
+
+// Write takes the pipe's framed output (4-byte big-endian length, then the Envelope) and sends each
+// complete frame as one message.
+func (s *grpcSession) Write(b []byte) (int, error) {
+	s.buf = append(s.buf, b...)
+	for len(s.buf) >= 4 {
+		n := int(binary.BigEndian.Uint32(s.buf))
+		if len(s.buf) < 4+n {
+			break
+		}
+		msg := append([]byte(nil), s.buf[4:4+n]...)
+		s.buf = s.buf[4+n:]
+		if err := s.stream.SendMsg(msg); err != nil {
+			s.buf = nil
+			return len(b), err
+		}
+	}
+	if len(s.buf) == 0 {
+		s.buf = nil
+	}
+	return len(b), nil
+}
+
+// Close ends the session; the client sees the stream finish.
+func (s *grpcSession) Close() error {
+	s.endOnce.Do(func() { close(s.ended) })
+	return nil
+}
+
+func (s *grpcSession) wait() error {
+	<-s.done
+	return nil
+}
+
+// recvLoop converts each Decision to the JSON line readLoop expects. The reader sees EOF once the client
+// stops sending or the stream ends.
+func (s *grpcSession) recvLoop(w *io.PipeWriter) {
+	defer w.Close()
+	for {
+		var msg []byte
+		if err := s.stream.RecvMsg(&msg); err != nil {
+			return
+		}
+		req, err := decodeDecision(msg)
+		if err != nil {
+			continue
+		}
+		line, err := json.Marshal(req)
+		if err != nil {
+			continue
+		}
+		if _, err := w.Write(append(line, '\n')); err != nil {
+			return
+		}
+	}
+}
+
+// Decision field numbers from brain.proto.
+const (
+	decType       = 1
+	decID         = 2
+	decMethod     = 3
+	decParamsJSON = 4
+)
+
+// decodeDecision parses a brain.proto Decision into the Request a stdin brain would have written.
+func decodeDecision(b []byte) (Request, error) {
+	var req Request
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return req, protowire.ParseError(n)
+		}
+		b = b[n:]
+		if typ != protowire.BytesType {
+			n = protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return req, protowire.ParseError(n)
+			}
+			b = b[n:]
+			continue
+		}
+		v, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return req, protowire.ParseError(n)
+		}
+		b = b[n:]
+		switch num {
+		case decType:
+			req.Type = string(v)
+		case decID:
+			req.ID = string(v)
+		case decMethod:
+			req.Method = string(v)
+		case decParamsJSON:
+			req.Params = append(json.RawMessage(nil), v...)
+		}
+	}
+	return req, nil
+}
+
+// authorized checks the stream's "authorization: Bearer <token>" metadata.
+func (l *grpcListener) authorized(ctx context.Context) bool {
+	if l.token == "" {
+		return true
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, v := range md.Get("authorization") {
+		if subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(v, "Bearer ")), []byte(l.token)) == 1 {
+			return true
+		}
+	}
+	return false
+}
@@ -2,84 +2,274 @@ package brain
 
 import (
 	"bufio"
+	"context"
+	"encoding/binary"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"log/slog"
+	"math/rand"
+	"os"
 	"os/exec"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/sunnyp94/sentry-bridge/go-engine/events"
+	"github.com/sunnyp94/sentry-bridge/go-engine/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// Pipe starts a child process (the Python brain) and sends events as newline-delimited JSON to its stdin.
+// sendDuration is how long events take from Send to the brain's stdin.
+var sendDuration = telemetry.Histogram("brain.pipe.send.duration", "Brain pipe latency from Send to the brain's stdin")
+
+// Pipe starts a child process (the Python brain) and sends events to its stdin as newline-delimited JSON,
+// or as length-prefixed MessagePack/protobuf frames (PipeOptions.Encoding).
 // If the brain process exits unexpectedly, it is restarted after a short backoff so the engine can run
-// continuously without gaps. Close() stops the process and disables restart.
+// continuously without gaps. Close() stops the process and disables restart. ListenGRPC returns a Pipe
+// whose brain is a remote gRPC client instead of a child process; everything below applies to it too.
+//
+// Send never blocks on the child: events go into a bounded queue drained by a writer goroutine, and when
+// the queue is full the oldest event is dropped (counted in Stats) so a slow brain can't stall market data.
+// While the brain is down (restart backoff) the writer buffers events (memory, then disk) and replays the
+// ones younger than the max age in order once the new process is up.
+//
+// With a ready timeout configured, each (re)started brain counts as down until it writes {"type":"ready"}
+// to stdout (or the timeout passes); the snapshot (SetSnapshot) is then written first, followed by
+// buffered and new events, so the brain never starts blind mid-stream.
 type Pipe struct {
-	cmd       *exec.Cmd
+	wait      func() error // current process or session; nil while none is running
+	readers   *sync.WaitGroup
+	start     func() (*process, error)
+	stop      func() // unblocks start on Close (gRPC listener); nil for a child process
+	listen    string // gRPC listen address; empty for a child process
+	name      string
+	env       []string
+	log       *slog.Logger
 	stdinPipe io.WriteCloser
 	stdin     *bufio.Writer
+	enc       encoder
 	mu        sync.Mutex
 	closed    bool
 	shutdown  bool
 	cmdLine   string
 	done      chan struct{}
 	doneOnce  sync.Once
+
+	handlersMu sync.RWMutex
+	handlers   map[string]Handler
+
+	errMu     sync.Mutex
+	onError   func(events.BrainErrorEvent)
+	errCounts map[string]int // tracebacks seen per exception line
+
+	queue      chan queuedEvent
+	stopping   atomic.Bool
+	stopWriter chan struct{}
+	writerDone chan struct{}
+	resumed    chan struct{}
+	spill      *spillBuffer // nil = discard while down; guarded by mu
+
+	// Simulated feed latency (testing): writer-only state.
+	latency time.Duration
+	jitter  time.Duration
+	lastDue time.Time
+
+	// Handshake, guarded by mu. gen identifies the current process so a stale timer or reader is ignored.
+	gen          uint64
+	ready        bool
+	readyTimeout time.Duration
+	snapshot     SnapshotFunc
+	needSnapshot bool
+
+	enqueued    atomic.Uint64
+	sent        atomic.Uint64
+	dropped     atomic.Uint64
+	discarded   atomic.Uint64
+	writeErrors atomic.Uint64
+	buffered    atomic.Uint64
+	replayed    atomic.Uint64
+	expired     atomic.Uint64
+}
+
+// PipeStats are cumulative counters for the brain pipe.
+type PipeStats struct {
+	Enqueued    uint64 `json:"enqueued"`     // events accepted by Send
+	Sent        uint64 `json:"sent"`         // events written to the brain's stdin
+	Dropped     uint64 `json:"dropped"`      // oldest events evicted because the queue was full
+	Discarded   uint64 `json:"discarded"`    // events lost while the brain was down (buffering off, full, or spill failed)
+	WriteErrors uint64 `json:"write_errors"` // stdin write/flush failures
+	Buffered    uint64 `json:"buffered"`     // events buffered while the brain was down
+	Replayed    uint64 `json:"replayed"`     // buffered events written after a restart
+	Expired     uint64 `json:"expired"`      // events dropped unsent past their TTL, or skipped at replay for exceeding the max age
+	Restarts    uint64 `json:"restarts"`     // brain processes restarted (gRPC: reconnects)
+	QueueLen    int    `json:"queue_len"`
+	QueueCap    int    `json:"queue_cap"`
+	BufferLen   int    `json:"buffer_len"` // events currently buffered
+}
+
+// PipeOptions configures StartPipe. Zero values select the defaults noted per field.
+type PipeOptions struct {
+	QueueSize     int           // Send queue capacity; 0 = DefaultQueueSize
+	BufferMaxAge  time.Duration // Buffer events while the brain is down and replay those younger than this; 0 = discard
+	BufferMemory  int           // Buffered events kept in memory; 0 = DefaultBufferMemory
+	SpillDir      string        // Directory for events beyond BufferMemory; empty = evict oldest in memory instead
+	SpillMaxBytes int64         // Spill file cap; 0 = unlimited
+	Name          string        // Instance name for logs and brain_error events when several brains run
+	Env           []string      // Extra environment ("KEY=value") for the brain process
+	ReadyTimeout  time.Duration // Wait this long for {"type":"ready"} from a (re)started brain; 0 = no handshake
+	Encoding      string        // EncodingJSON (default), EncodingMsgpack or EncodingProtobuf
+	GRPCToken     string        // gRPC transport: bearer token the brain must send; required off loopback
+	GRPCTLSCert   string        // gRPC transport: serve TLS with this certificate (PEM file)...
+	GRPCTLSKey    string        // ...and key; empty = plaintext
+	InjectLatency time.Duration // Testing: hold each event this long after Send before writing it
+	InjectJitter  time.Duration // Testing: plus a random 0..InjectJitter on top of InjectLatency
 }
 
+// queuedEvent is one encoded event waiting for the writer.
+type queuedEvent struct {
+	line     []byte
+	at       time.Time
+	deadline time.Time // end of the event's TTL; zero = never expires
+	typ, id  string    // event type and ID, for telemetry
+}
+
+// SnapshotFunc builds the events written to a brain as soon as it is ready, ahead of anything queued.
+// Envelope TS is filled in when empty.
+type SnapshotFunc func() []events.Envelope
+
 const brainRestartBackoff = 5 * time.Second
 
+// DefaultQueueSize is the brain pipe queue capacity when none is configured.
+const DefaultQueueSize = 10000
+
+// DefaultBufferMemory is the in-memory event count kept while the brain is down before spilling to disk.
+const DefaultBufferMemory = 10000
+
+// dropLogInterval rate-limits the "events dropped" warning.
+const dropLogInterval = 10 * time.Second
+
 // StartPipe starts the brain process. cmdLine is the full command, e.g. "python3 python-brain/consumer.py".
 // Run from project root so paths in cmdLine resolve. If the process exits, it is restarted after brainRestartBackoff
 // until Close() is called.
-func StartPipe(cmdLine string) (*Pipe, error) {
-	parts := splitCmd(cmdLine)
-	if len(parts) == 0 {
+func StartPipe(cmdLine string, opts PipeOptions) (*Pipe, error) {
+	p, err := newPipe(opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(splitCmd(cmdLine)) == 0 {
+		p.release()
 		return nil, nil
 	}
-	cmd := exec.Command(parts[0], parts[1:]...)
-	cmd.Stderr = nil
-	stdinPipe, err := cmd.StdinPipe()
+	proc, err := startProcess(cmdLine, p.env)
 	if err != nil {
+		p.release()
 		return nil, err
 	}
-	if err := cmd.Start(); err != nil {
+	p.cmdLine = cmdLine
+	p.start = func() (*process, error) { return startProcess(cmdLine, p.env) }
+	p.wait = proc.wait
+	p.readers = proc.readers
+	p.stdinPipe = proc.stdin
+	p.stdin = bufio.NewWriter(proc.stdin)
+	p.closed = false
+	p.mu.Lock()
+	gen := p.startedLocked()
+	p.mu.Unlock()
+	p.startReaders(proc, gen)
+	go p.writer()
+	go p.supervisor()
+	return p, nil
+}
+
+// newPipe applies option defaults and builds a Pipe with no brain attached yet.
+func newPipe(opts PipeOptions) (*Pipe, error) {
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = DefaultQueueSize
+	}
+	if opts.BufferMemory <= 0 {
+		opts.BufferMemory = DefaultBufferMemory
+	}
+	enc, err := newEncoder(opts.Encoding)
+	if err != nil {
 		return nil, err
 	}
+	opts.Env = append(append([]string(nil), opts.Env...), "BRAIN_ENCODING="+enc.name)
+	var spill *spillBuffer
+	if opts.BufferMaxAge > 0 {
+		sb, err := newSpillBuffer(opts.SpillDir, opts.BufferMemory, opts.BufferMaxAge, opts.SpillMaxBytes)
+		if err != nil {
+			return nil, err
+		}
+		spill = sb
+	}
 	p := &Pipe{
-		cmd:       cmd,
-		stdinPipe: stdinPipe,
-		stdin:     bufio.NewWriter(stdinPipe),
-		cmdLine:   cmdLine,
-		done:      make(chan struct{}),
+		enc:      enc,
+		name:     opts.Name,
+		env:      opts.Env,
+		log:      slog.Default(),
+		closed:   true,
+		done:     make(chan struct{}),
+		handlers: make(map[string]Handler),
+
+		queue:      make(chan queuedEvent, opts.QueueSize),
+		stopWriter: make(chan struct{}),
+		writerDone: make(chan struct{}),
+		resumed:    make(chan struct{}, 1),
+		spill:      spill,
+
+		readyTimeout: opts.ReadyTimeout,
+		latency:      opts.InjectLatency,
+		jitter:       opts.InjectJitter,
 	}
-	go p.supervisor()
+	if opts.Name != "" {
+		p.log = p.log.With("brain", opts.Name)
+	}
+	p.handlers["pipe_stats"] = func(json.RawMessage) (interface{}, error) { return p.Stats(), nil }
 	return p, nil
 }
 
-// supervisor waits for the current brain process to exit; if not shutdown, restarts after backoff.
-// Edge cases: (1) cmd may be nil after a failed restart (we cleared it to avoid double-Wait).
+// release frees what newPipe set up (the spill buffer and its file) for a pipe that is never started.
+func (p *Pipe) release() {
+	if p.spill != nil {
+		p.spill.reset()
+	}
+}
+
+// supervisor waits for the current brain process to exit; if not shutdown, restarts after backoff. A gRPC
+// pipe instead waits for the brain to reconnect.
+// Edge cases: (1) wait may be nil after a failed restart (we cleared it to avoid double-Wait).
 // (2) done is closed exactly once via doneOnce so Close() always unblocks.
 func (p *Pipe) supervisor() {
 	defer p.doneOnce.Do(func() { close(p.done) })
 	for {
 		p.mu.Lock()
-		cmd := p.cmd
+		wait, readers := p.wait, p.readers
 		p.mu.Unlock()
-		if cmd != nil {
-			_ = cmd.Wait()
+		if wait != nil {
+			// Drain stdout/stderr first: Wait closes the pipes, which would cut off a dying traceback.
+			readers.Wait()
+			_ = wait()
 		}
 		p.mu.Lock()
 		if p.shutdown {
 			p.closed = true
 			p.mu.Unlock()
-			slog.Info("brain process stopped (shutdown)")
+			p.log.Info("brain process stopped (shutdown)")
 			return
 		}
 		p.closed = true
 		p.mu.Unlock()
-		slog.Info("brain process exited; restarting", "backoff", brainRestartBackoff)
-
-		time.Sleep(brainRestartBackoff)
+		if p.listen == "" {
+			p.log.Info("brain process exited; restarting", "backoff", brainRestartBackoff)
+			time.Sleep(brainRestartBackoff)
+		} else if wait != nil {
+			p.log.Info("brain disconnected; waiting for it to reconnect", "addr", p.listen)
+		}
 
 		p.mu.Lock()
 		if p.shutdown {
@@ -88,83 +278,461 @@ func (p *Pipe) supervisor() {
 		}
 		p.mu.Unlock()
 
-		parts := splitCmd(p.cmdLine)
-		if len(parts) == 0 {
-			return
-		}
-		newCmd := exec.Command(parts[0], parts[1:]...)
-		newCmd.Stderr = nil
-		newStdin, err := newCmd.StdinPipe()
+		proc, err := p.start()
 		if err != nil {
-			slog.Error("brain restart stdin pipe failed", "err", err)
 			p.mu.Lock()
-			p.cmd = nil
+			shutdown := p.shutdown
+			p.wait = nil
+			p.readers = nil
 			p.stdinPipe = nil
 			p.stdin = nil
 			p.mu.Unlock()
+			if !shutdown {
+				p.log.Error("brain restart failed", "err", err)
+			}
 			continue
 		}
-		if err := newCmd.Start(); err != nil {
-			slog.Error("brain restart start failed", "err", err)
-			p.mu.Lock()
-			p.cmd = nil
-			p.stdinPipe = nil
-			p.stdin = nil
+		p.mu.Lock()
+		if p.shutdown {
 			p.mu.Unlock()
+			_ = proc.stdin.Close()
 			continue
 		}
-		p.mu.Lock()
-		p.cmd = newCmd
-		p.stdinPipe = newStdin
-		p.stdin = bufio.NewWriter(newStdin)
+		p.wait = proc.wait
+		p.readers = proc.readers
+		p.stdinPipe = proc.stdin
+		p.stdin = bufio.NewWriter(proc.stdin)
 		p.closed = false
+		gen := p.startedLocked()
 		p.mu.Unlock()
-		slog.Info("brain process restarted", "cmd", p.cmdLine)
+		p.startReaders(proc, gen)
+		if p.listen == "" {
+			p.log.Info("brain process restarted", "cmd", p.cmdLine)
+		} else {
+			p.log.Info("brain connected", "addr", p.listen, "peer", proc.peer)
+		}
 	}
 }
 
-// Send writes one event as a single JSON line to the brain's stdin.
-func (p *Pipe) Send(typ string, payload interface{}) error {
+// process is one started brain command with its stdio pipes, or one gRPC session standing in for it.
+type process struct {
+	cmd     *exec.Cmd // nil for a gRPC session
+	stdin   io.WriteCloser
+	stdout  io.ReadCloser
+	stderr  io.ReadCloser   // nil for a gRPC session
+	readers *sync.WaitGroup // stdout + stderr readers; Wait before wait
+	wait    func() error    // cmd.Wait, or the end of the gRPC session
+	peer    string          // gRPC client address
+}
+
+// startProcess starts cmdLine with stdin, stdout (request channel) and stderr (logs) piped. env is
+// added to the engine's environment.
+func startProcess(cmdLine string, env []string) (*process, error) {
+	parts := splitCmd(cmdLine)
+	if len(parts) == 0 {
+		return nil, errors.New("empty brain command")
+	}
+	cmd := exec.Command(parts[0], parts[1:]...)
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("stdin pipe: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("stdout pipe: %w", err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, fmt.Errorf("stderr pipe: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &process{cmd: cmd, stdin: stdin, stdout: stdout, stderr: stderr, readers: &sync.WaitGroup{}, wait: cmd.Wait}, nil
+}
+
+// startReaders runs the stdout and stderr readers for process gen.
+func (p *Pipe) startReaders(proc *process, gen uint64) {
+	proc.readers.Add(1)
+	go func() {
+		defer proc.readers.Done()
+		p.readLoop(proc.stdout, gen)
+	}()
+	if proc.stderr == nil {
+		return
+	}
+	proc.readers.Add(1)
+	go func() {
+		defer proc.readers.Done()
+		p.stderrLoop(proc.stderr, proc.cmd.Process.Pid)
+	}()
+}
+
+// startedLocked resets handshake state for a newly started process and returns its generation. Without
+// a ready timeout the process is ready at once; otherwise a timer marks it ready if it never says so.
+// Caller holds mu.
+func (p *Pipe) startedLocked() uint64 {
+	p.gen++
+	gen := p.gen
+	p.needSnapshot = true
+	p.ready = p.readyTimeout <= 0
+	if p.ready {
+		p.signalResumed()
+	} else {
+		time.AfterFunc(p.readyTimeout, func() { p.markReady(gen, true) })
+	}
+	return gen
+}
+
+// markReady flags process gen as ready (from its "ready" line, or timedOut) and wakes the writer to send
+// the snapshot and buffered events.
+func (p *Pipe) markReady(gen uint64, timedOut bool) {
+	p.mu.Lock()
+	if p.gen != gen || p.ready || p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.ready = true
+	p.mu.Unlock()
+	if timedOut {
+		p.log.Warn("brain did not send ready; streaming anyway", "timeout", p.readyTimeout)
+	} else {
+		p.log.Info("brain ready")
+	}
+	p.signalResumed()
+}
+
+// signalResumed wakes the writer without blocking.
+func (p *Pipe) signalResumed() {
+	select {
+	case p.resumed <- struct{}{}:
+	default:
+	}
+}
+
+// SetSnapshot sets the function that builds the initial snapshot for each ready brain. If the current
+// brain is already ready and hasn't had one, it is sent now.
+func (p *Pipe) SetSnapshot(fn SnapshotFunc) {
 	if p == nil {
-		return nil
+		return
 	}
 	p.mu.Lock()
-	defer p.mu.Unlock()
-	if p.closed || p.stdin == nil {
+	p.snapshot = fn
+	p.mu.Unlock()
+	p.signalResumed()
+}
+
+// Send queues one encoded event for the brain's stdin. payload should be one of the
+// events package structs so the wire schema is checked at compile time. It never blocks: when the queue
+// is full the oldest queued event is dropped.
+func (p *Pipe) Send(typ string, payload interface{}) error {
+	return p.send(events.Envelope{Type: typ, Payload: payload}, time.Time{})
+}
+
+// send queues a dispatched event, keeping its tags (added by event filter rules) and TTL: the writer
+// drops it unsent once deadline has passed.
+func (p *Pipe) send(ev events.Envelope, deadline time.Time) error {
+	if p == nil || p.stopping.Load() {
 		return nil
 	}
-	ts := time.Now().UTC().Format(time.RFC3339Nano)
-	obj := map[string]interface{}{"type": typ, "ts": ts, "payload": payload}
-	line, err := json.Marshal(obj)
+	now := time.Now()
+	ev.TS = now.UTC().Format(time.RFC3339Nano)
+	line, err := p.enc.encode(ev)
 	if err != nil {
 		return err
 	}
+	for {
+		select {
+		case p.queue <- queuedEvent{line: line, at: now, deadline: deadline, typ: ev.Type, id: ev.ID}:
+			p.enqueued.Add(1)
+			return nil
+		default:
+		}
+		// Full: evict the oldest event and retry
+		select {
+		case <-p.queue:
+			p.dropped.Add(1)
+		default:
+		}
+	}
+}
+
+// writer drains the queue into the current brain process until Close, then flushes what is left.
+func (p *Pipe) writer() {
+	defer close(p.writerDone)
+	ticker := time.NewTicker(dropLogInterval)
+	defer ticker.Stop()
+	var lastDropped uint64
+	for {
+		select {
+		case q := <-p.queue:
+			p.injectDelay(q.at)
+			if p.late(q) {
+				continue
+			}
+			p.deliver(q)
+		case <-p.resumed:
+			p.mu.Lock()
+			if !p.closed && p.stdin != nil && p.ready {
+				p.sendSnapshotLocked()
+				p.replaySpillLocked()
+				if err := p.stdin.Flush(); err != nil {
+					p.writeErrors.Add(1)
+				}
+			}
+			p.mu.Unlock()
+		case <-ticker.C:
+			if d := p.dropped.Load(); d > lastDropped {
+				p.log.Warn("brain pipe queue full; dropped oldest events", "dropped", d-lastDropped, "total_dropped", d, "queue_cap", cap(p.queue))
+				lastDropped = d
+			}
+		case <-p.stopWriter:
+			for {
+				select {
+				case q := <-p.queue:
+					if !p.late(q) {
+						p.deliver(q)
+					}
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// late reports (and counts) an event that outlived its TTL in the queue.
+func (p *Pipe) late(q queuedEvent) bool {
+	if q.deadline.IsZero() || !time.Now().After(q.deadline) {
+		return false
+	}
+	p.expired.Add(1)
+	return true
+}
+
+// injectDelay holds an event queued at until InjectLatency (+ jitter) has passed, so the brain sees a
+// slower feed. Events stay in order: one never goes out before an earlier one. Close cuts the wait short.
+func (p *Pipe) injectDelay(at time.Time) {
+	if p.latency <= 0 && p.jitter <= 0 {
+		return
+	}
+	d := p.latency
+	if p.jitter > 0 {
+		d += time.Duration(rand.Int63n(int64(p.jitter) + 1))
+	}
+	due := at.Add(d)
+	if due.Before(p.lastDue) {
+		due = p.lastDue
+	}
+	p.lastDue = due
+	wait := time.Until(due)
+	if wait <= 0 {
+		return
+	}
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-p.stopWriter:
+	}
+}
+
+// deliver writes a queued event and records its trip from Send to the brain's stdin (queueing and
+// injected latency included) as a producer span and a brain.pipe.send.duration sample.
+func (p *Pipe) deliver(q queuedEvent) {
+	sent := p.writeLine(q.line, q.deadline)
+	ms := telemetry.EventSpan(q.id, "brain.pipe send", trace.SpanKindProducer, q.at, nil, attribute.String("brain", p.name),
+		attribute.String("event.type", q.typ), attribute.String("event.id", q.id), attribute.Bool("sent", sent))
+	sendDuration.Record(context.Background(), ms, metric.WithAttributes(attribute.String("brain", p.name),
+		attribute.String("event.type", q.typ), attribute.Bool("sent", sent)))
+}
+
+// writeLine writes one line to the brain's stdin, flushing once the queue is empty so bursts are batched.
+// While the brain is down the line is buffered for replay instead (or discarded if buffering is off).
+// It reports whether the line was written.
+func (p *Pipe) writeLine(line []byte, deadline time.Time) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed || p.stdin == nil || !p.ready {
+		if p.spill != nil && !p.shutdown {
+			p.buffered.Add(1)
+			if p.spill.add(line, time.Now(), deadline) {
+				p.discarded.Add(1)
+			}
+			return false
+		}
+		p.discarded.Add(1)
+		return false
+	}
+	p.sendSnapshotLocked()
+	p.replaySpillLocked()
+	if !p.writeRawLocked(line) {
+		return false
+	}
+	// With injected latency the queue holds events that aren't due yet, so flush each one on time.
+	if len(p.queue) == 0 || p.latency > 0 || p.jitter > 0 {
+		if err := p.stdin.Flush(); err != nil {
+			p.writeErrors.Add(1)
+		}
+	}
+	return true
+}
+
+// writeRawLocked writes one encoded event to stdin without flushing: line plus newline for JSON, or a
+// 4-byte big-endian length then the body for binary encodings. Caller holds mu.
+func (p *Pipe) writeRawLocked(line []byte) bool {
+	if p.enc.framed {
+		var hdr [4]byte
+		binary.BigEndian.PutUint32(hdr[:], uint32(len(line)))
+		if _, err := p.stdin.Write(hdr[:]); err != nil {
+			p.writeErrors.Add(1)
+			return false
+		}
+	}
 	if _, err := p.stdin.Write(line); err != nil {
-		return err
+		p.writeErrors.Add(1)
+		return false
 	}
-	if err := p.stdin.WriteByte('\n'); err != nil {
-		return err
+	if !p.enc.framed {
+		if err := p.stdin.WriteByte('\n'); err != nil {
+			p.writeErrors.Add(1)
+			return false
+		}
 	}
-	return p.stdin.Flush()
+	p.sent.Add(1)
+	return true
 }
 
-// Close signals shutdown, closes stdin so the process exits, and waits for the supervisor to finish.
+// sendSnapshotLocked writes the snapshot once per ready process. Caller holds mu and the brain is ready.
+func (p *Pipe) sendSnapshotLocked() {
+	if !p.needSnapshot || p.snapshot == nil {
+		return
+	}
+	p.needSnapshot = false
+	ts := time.Now().UTC().Format(time.RFC3339Nano)
+	evs := p.snapshot()
+	for _, ev := range evs {
+		if ev.TS == "" {
+			ev.TS = ts
+		}
+		line, err := p.enc.encode(ev)
+		if err != nil {
+			p.log.Error("brain snapshot marshal failed", "type", ev.Type, "err", err)
+			continue
+		}
+		if !p.writeRawLocked(line) {
+			return
+		}
+	}
+	p.log.Info("brain snapshot sent", "events", len(evs))
+}
+
+// replaySpillLocked writes events buffered during the last outage, oldest first. Caller holds mu and the
+// brain is up.
+func (p *Pipe) replaySpillLocked() {
+	if p.spill == nil || p.spill.len() == 0 {
+		return
+	}
+	pending := p.spill.len()
+	replayed, expired := p.spill.replay(time.Now(), p.writeRawLocked)
+	p.replayed.Add(uint64(replayed))
+	p.expired.Add(uint64(expired))
+	if lost := pending - replayed - expired; lost > 0 {
+		p.discarded.Add(uint64(lost))
+	}
+	p.log.Info("brain buffered events replayed", "replayed", replayed, "expired", expired, "buffered", pending)
+}
+
+// Alive reports whether a brain process is currently running and ready for events (false during restart
+// backoff, before its ready handshake, and after Close).
+func (p *Pipe) Alive() bool {
+	if p == nil {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return !p.closed && p.stdin != nil && p.ready
+}
+
+// Name returns the instance name given in PipeOptions.
+func (p *Pipe) Name() string {
+	if p == nil {
+		return ""
+	}
+	return p.name
+}
+
+// Stats returns cumulative pipe counters.
+func (p *Pipe) Stats() PipeStats {
+	if p == nil {
+		return PipeStats{}
+	}
+	return PipeStats{
+		Enqueued:    p.enqueued.Load(),
+		Sent:        p.sent.Load(),
+		Dropped:     p.dropped.Load(),
+		Discarded:   p.discarded.Load(),
+		WriteErrors: p.writeErrors.Load(),
+		Buffered:    p.buffered.Load(),
+		Replayed:    p.replayed.Load(),
+		Expired:     p.expired.Load(),
+		Restarts:    p.restartCount(),
+		QueueLen:    len(p.queue),
+		QueueCap:    cap(p.queue),
+		BufferLen:   p.bufferLen(),
+	}
+}
+
+// restartCount is how many times a brain was (re)started or reconnected after the first one.
+func (p *Pipe) restartCount() uint64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.gen <= 1 {
+		return 0
+	}
+	return p.gen - 1
+}
+
+func (p *Pipe) bufferLen() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.spill == nil {
+		return 0
+	}
+	return p.spill.len()
+}
+
+// Close drains queued events, signals shutdown, closes stdin so the process exits (or ends the gRPC
+// session and stops the listener), and waits for the supervisor to finish.
 func (p *Pipe) Close() error {
 	if p == nil {
 		return nil
 	}
-	p.mu.Lock()
-	if p.shutdown {
-		p.mu.Unlock()
+	if !p.stopping.CompareAndSwap(false, true) {
 		return nil
 	}
+	close(p.stopWriter)
+	<-p.writerDone
+	p.mu.Lock()
 	p.shutdown = true
+	if p.spill != nil {
+		if n := p.spill.len(); n > 0 {
+			p.discarded.Add(uint64(n))
+		}
+		p.spill.reset()
+	}
 	if !p.closed && p.stdinPipe != nil {
 		p.closed = true
 		_ = p.stdin.Flush()
 		_ = p.stdinPipe.Close()
 	}
 	p.mu.Unlock()
+	if p.stop != nil {
+		p.stop()
+	}
 	<-p.done
 	return nil
 }
@@ -0,0 +1,93 @@
+package brain
+
+// ring is a FIFO over a circular buffer with a fixed capacity: push at the back, pop at the front,
+// without the reallocation and copying of an appended and re-sliced slice. A push into a full ring drops
+// the oldest element. The buffer starts small and doubles up to the capacity, never shrinking, so a quiet
+// symbol stays small, a busy one's memory is bounded, and a warm ring allocates nothing. Not safe for
+// concurrent use.
+type ring[T any] struct {
+	buf  []T
+	head int // index of the oldest element
+	n    int
+	max  int // capacity
+}
+
+// minRing is the first buffer size.
+const minRing = 16
+
+// newRing returns an empty ring holding at most max elements.
+func newRing[T any](max int) ring[T] { return ring[T]{max: max} }
+
+func (r *ring[T]) len() int { return r.n }
+
+// at returns the i-th oldest element (0 = oldest).
+func (r *ring[T]) at(i int) T { return r.buf[(r.head+i)%len(r.buf)] }
+
+// front returns the oldest element; the ring must not be empty.
+func (r *ring[T]) front() T { return r.buf[r.head] }
+
+// back returns the newest element; the ring must not be empty.
+func (r *ring[T]) back() T { return r.at(r.n - 1) }
+
+// full reports whether the next push drops the oldest element.
+func (r *ring[T]) full() bool { return r.n >= r.max }
+
+// push appends v, dropping the oldest element first when the ring is full.
+func (r *ring[T]) push(v T) {
+	if r.full() {
+		r.pop()
+	}
+	if r.n == len(r.buf) {
+		size := min(max(2*len(r.buf), minRing), r.max)
+		buf := make([]T, size)
+		r.copyTo(buf)
+		r.buf, r.head = buf, 0
+	}
+	r.buf[(r.head+r.n)%len(r.buf)] = v
+	r.n++
+}
+
+// pop drops the oldest element; the ring must not be empty.
+func (r *ring[T]) pop() {
+	var zero T
+	r.buf[r.head] = zero
+	r.head = (r.head + 1) % len(r.buf)
+	r.n--
+}
+
+// set replaces the i-th oldest element.
+func (r *ring[T]) set(i int, v T) { r.buf[(r.head+i)%len(r.buf)] = v }
+
+// remove drops the i-th oldest element, moving the newer ones down one place.
+func (r *ring[T]) remove(i int) {
+	for ; i < r.n-1; i++ {
+		r.set(i, r.at(i+1))
+	}
+	var zero T
+	r.set(r.n-1, zero)
+	r.n--
+}
+
+// last returns a copy of the newest n elements (all when n <= 0 or n > len), oldest first.
+func (r *ring[T]) last(n int) []T {
+	if n <= 0 || n > r.n {
+		n = r.n
+	}
+	out := make([]T, n)
+	for i := range out {
+		out[i] = r.at(r.n - n + i)
+	}
+	return out
+}
+
+func (r *ring[T]) copyTo(dst []T) {
+	if r.n == 0 {
+		return
+	}
+	if end := r.head + r.n; end <= len(r.buf) {
+		copy(dst, r.buf[r.head:end])
+		return
+	}
+	k := copy(dst, r.buf[r.head:])
+	copy(dst[k:], r.buf[:r.n-k])
+}
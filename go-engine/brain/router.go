@@ -0,0 +1,301 @@
+package brain
+
+import (
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sunnyp94/sentry-bridge/go-engine/events"
+	"github.com/sunnyp94/sentry-bridge/go-engine/sink"
+)
+
+// Router spreads the watchlist across one or more brain processes so a slow strategy process doesn't
+// bottleneck every symbol. Symbol events go to the brain that owns the symbol (explicit route, else a
+// stable hash); account-wide events (positions, orders, trade updates, ...) go to every brain.
+// The Router is the "brain" sink of the event dispatcher. All methods are no-ops on a nil Router.
+type Router struct {
+	pipes     []*Pipe
+	routes    map[string]int   // symbol -> index into pipes
+	filters   []*sink.Filter   // per pipe; nil entries pass everything
+	seqs      []sink.Sequencer // per pipe, numbering what passed its filter
+	encodeErr atomic.Uint64    // events that failed to encode for a brain
+	filtered  atomic.Uint64    // events dropped by a brain's filter or while muted
+	muted     atomic.Pointer[map[string]bool]
+
+	muteMu sync.Mutex
+	mutes  map[string][]string // reason -> muted types; muted holds their union
+}
+
+// NewRouter routes across pipes. routes pins symbols to a pipe index; out-of-range entries are ignored.
+func NewRouter(pipes []*Pipe, routes map[string]int) *Router {
+	if len(pipes) == 0 {
+		return nil
+	}
+	r := &Router{pipes: pipes, routes: make(map[string]int), seqs: make([]sink.Sequencer, len(pipes))}
+	for sym, i := range routes {
+		if i >= 0 && i < len(pipes) {
+			r.routes[sym] = i
+		}
+	}
+	return r
+}
+
+// Pipes returns every brain pipe.
+func (r *Router) Pipes() []*Pipe {
+	if r == nil {
+		return nil
+	}
+	return r.pipes
+}
+
+// Index returns the pipe index that owns symbol.
+func (r *Router) Index(symbol string) int {
+	if i, ok := r.routes[symbol]; ok {
+		return i
+	}
+	if len(r.pipes) == 1 {
+		return 0
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(symbol))
+	return int(h.Sum32() % uint32(len(r.pipes)))
+}
+
+// For returns the pipe that owns symbol.
+func (r *Router) For(symbol string) *Pipe {
+	if r == nil {
+		return nil
+	}
+	return r.pipes[r.Index(symbol)]
+}
+
+// Send queues an account-wide event for every brain.
+func (r *Router) Send(typ string, payload interface{}) error {
+	if r == nil {
+		return nil
+	}
+	var firstErr error
+	for _, p := range r.pipes {
+		if err := p.Send(typ, payload); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// SendSymbol queues an event for the brain that owns symbol.
+func (r *Router) SendSymbol(symbol, typ string, payload interface{}) error {
+	if r == nil {
+		return nil
+	}
+	return r.For(symbol).Send(typ, payload)
+}
+
+// SendSymbols queues one copy of an event for each brain that owns any of symbols (e.g. a news article
+// tagged with several tickers); with no symbols it goes to every brain.
+func (r *Router) SendSymbols(symbols []string, typ string, payload interface{}) error {
+	if r == nil {
+		return nil
+	}
+	var firstErr error
+	for _, i := range r.targets(symbols) {
+		if err := r.pipes[i].Send(typ, payload); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// targets returns the indexes of the pipes that own any of symbols; every pipe when there are none.
+func (r *Router) targets(symbols []string) []int {
+	out := make([]int, 0, len(r.pipes))
+	if len(symbols) == 0 {
+		for i := range r.pipes {
+			out = append(out, i)
+		}
+		return out
+	}
+	sent := make(map[int]bool, len(r.pipes))
+	for _, sym := range symbols {
+		i := r.Index(sym)
+		if !sent[i] {
+			sent[i] = true
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+// SetFilters sets each brain's event filter (EVENT_FILTERS), indexed like Pipes. Call before use.
+func (r *Router) SetFilters(filters []*sink.Filter) {
+	if r == nil {
+		return
+	}
+	r.filters = filters
+}
+
+var _ sink.Sink = (*Router)(nil)
+
+// Name identifies the brain sink.
+func (r *Router) Name() string { return sink.NameBrain }
+
+// Publish routes a dispatched event: symbol events to the owning brains, account-wide events to all,
+// each through that brain's filter. Each brain numbers the events it gets on its own.
+func (r *Router) Publish(ev sink.Event) {
+	if r == nil {
+		return
+	}
+	if m := r.muted.Load(); m != nil && (*m)[ev.Type] {
+		r.filtered.Add(1)
+		return
+	}
+	failed := false
+	for _, i := range r.targets(ev.Symbols) {
+		e := ev
+		if i < len(r.filters) {
+			var ok bool
+			if e, ok = r.filters[i].Apply(ev); !ok {
+				r.filtered.Add(1)
+				continue
+			}
+		}
+		r.seqs[i].Publish(e, func(e sink.Event) {
+			if err := r.pipes[i].send(e.Envelope, e.Deadline); err != nil {
+				failed = true
+			}
+		})
+	}
+	if failed {
+		r.encodeErr.Add(1)
+	}
+}
+
+// NumbersEvents marks r as numbering its events per brain (sink.Sequencing).
+func (r *Router) NumbersEvents() {}
+
+// Mute stops forwarding events of types to every brain until Unmute with the same reason (e.g. market
+// data once the daily loss limit halts trading); everything else still goes through. Each reason keeps
+// its own set, replaced by a later Mute for it, and an event type stays muted while any set has it. Muted
+// events count as filtered.
+func (r *Router) Mute(reason string, types ...string) {
+	if r == nil {
+		return
+	}
+	r.muteMu.Lock()
+	defer r.muteMu.Unlock()
+	if r.mutes == nil {
+		r.mutes = make(map[string][]string)
+	}
+	r.mutes[reason] = types
+	r.storeMuted()
+}
+
+// Unmute drops the mute set of reason; types another reason mutes stay muted.
+func (r *Router) Unmute(reason string) {
+	if r == nil {
+		return
+	}
+	r.muteMu.Lock()
+	defer r.muteMu.Unlock()
+	delete(r.mutes, reason)
+	r.storeMuted()
+}
+
+// storeMuted publishes the union of the mute sets. Call with muteMu held.
+func (r *Router) storeMuted() {
+	if len(r.mutes) == 0 {
+		r.muted.Store(nil)
+		return
+	}
+	m := make(map[string]bool)
+	for _, types := range r.mutes {
+		for _, t := range types {
+			m[t] = true
+		}
+	}
+	r.muted.Store(&m)
+}
+
+// Handle registers a request handler on every brain.
+func (r *Router) Handle(method string, h Handler) {
+	if r == nil {
+		return
+	}
+	for _, p := range r.pipes {
+		p.Handle(method, h)
+	}
+}
+
+// SetSnapshot sets the ready snapshot for every brain; fn gets the pipe and an ownership test so each
+// brain only receives its own symbols.
+func (r *Router) SetSnapshot(fn func(p *Pipe, owns func(symbol string) bool) []events.Envelope) {
+	if r == nil {
+		return
+	}
+	for i, p := range r.pipes {
+		i, p := i, p
+		owns := func(symbol string) bool { return r.Index(symbol) == i }
+		p.SetSnapshot(func() []events.Envelope { return fn(p, owns) })
+	}
+}
+
+// OnError sets the traceback callback on every brain.
+func (r *Router) OnError(fn func(events.BrainErrorEvent)) {
+	if r == nil {
+		return
+	}
+	for _, p := range r.pipes {
+		p.OnError(fn)
+	}
+}
+
+// Alive reports whether the brain that owns symbol is running and ready.
+func (r *Router) Alive(symbol string) bool {
+	if r == nil {
+		return false
+	}
+	return r.For(symbol).Alive()
+}
+
+// PipeStats returns counters per brain, keyed by name.
+func (r *Router) PipeStats() map[string]PipeStats {
+	out := make(map[string]PipeStats)
+	if r == nil {
+		return out
+	}
+	for _, p := range r.pipes {
+		out[p.Name()] = p.Stats()
+	}
+	return out
+}
+
+// Stats sums the brain counters for the dispatcher: events written, events lost to a full queue or
+// a down brain, and encode/write failures. It is healthy while at least one brain is up.
+func (r *Router) Stats() sink.Stats {
+	var st sink.Stats
+	if r == nil {
+		return st
+	}
+	st.Errors = r.encodeErr.Load()
+	st.Filtered = r.filtered.Load()
+	for _, p := range r.pipes {
+		ps := p.Stats()
+		st.Published += ps.Sent
+		st.Dropped += ps.Dropped + ps.Discarded
+		st.Errors += ps.WriteErrors
+		st.Expired += ps.Expired
+		st.Healthy = st.Healthy || p.Alive()
+	}
+	return st
+}
+
+// Close stops every brain.
+func (r *Router) Close() error {
+	if r == nil {
+		return nil
+	}
+	for _, p := range r.pipes {
+		_ = p.Close()
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package brain
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/sunnyp94/sentry-bridge/go-engine/events"
+)
+
+// Request is a line the brain writes to its stdout to query the engine, e.g.
+// {"type":"request","id":"42","method":"ticks","params":{"symbol":"AAPL","n":300}}.
+// The engine answers on stdin with a "response" event whose payload carries the same id.
+type Request struct {
+	Type   string          `json:"type"`
+	ID     string          `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+// Response is the payload of the "response" event sent back to the brain.
+type Response struct {
+	ID     string      `json:"id"`
+	Method string      `json:"method"`
+	Result interface{} `json:"result,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// Handler answers one request method. params is the raw "params" object (may be empty).
+type Handler func(params json.RawMessage) (interface{}, error)
+
+// maxRequestLine bounds a single stdout line from the brain.
+const maxRequestLine = 1 << 20
+
+// Handle registers h for method, replacing any previous handler.
+func (p *Pipe) Handle(method string, h Handler) {
+	if p == nil {
+		return
+	}
+	p.handlersMu.Lock()
+	p.handlers[method] = h
+	p.handlersMu.Unlock()
+}
+
+// readLoop reads the stdout of brain process gen until EOF. {"type":"ready"} completes the handshake,
+// JSON lines with type "request" are dispatched to handlers, and anything else (stray prints) is logged
+// so it isn't lost.
+func (p *Pipe) readLoop(r io.Reader, gen uint64) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), maxRequestLine)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		var req Request
+		if line[0] != '{' || json.Unmarshal([]byte(line), &req) != nil || req.Type == "" {
+			p.log.Info("brain stdout", "line", line)
+			continue
+		}
+		if req.Type == "ready" {
+			p.markReady(gen, false)
+			continue
+		}
+		p.dispatch(req)
+	}
+	if err := sc.Err(); err != nil && !errors.Is(err, io.EOF) {
+		p.log.Warn("brain stdout read ended", "err", err)
+	}
+}
+
+// dispatch runs the handler for a request line and sends the response event.
+func (p *Pipe) dispatch(req Request) {
+	if req.Type != "request" {
+		p.log.Debug("brain message ignored", "type", req.Type)
+		return
+	}
+	p.handlersMu.RLock()
+	h := p.handlers[req.Method]
+	p.handlersMu.RUnlock()
+	resp := Response{ID: req.ID, Method: req.Method}
+	if h == nil {
+		resp.Error = fmt.Sprintf("unknown method %q", req.Method)
+	} else if result, err := h(req.Params); err != nil {
+		resp.Error = err.Error()
+	} else {
+		resp.Result = result
+	}
+	if err := p.Send(events.TypeResponse, resp); err != nil {
+		p.log.Warn("brain response send failed", "method", req.Method, "id", req.ID, "err", err)
+	}
+}
+
+// symbolParams is the common {"symbol": "...", "n": N} request shape.
+type symbolParams struct {
+	Symbol string `json:"symbol"`
+	N      int    `json:"n"`
+}
+
+func parseSymbolParams(raw json.RawMessage) (symbolParams, error) {
+	var sp symbolParams
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &sp); err != nil {
+			return sp, fmt.Errorf("bad params: %w", err)
+		}
+	}
+	sp.Symbol = strings.ToUpper(strings.TrimSpace(sp.Symbol))
+	if sp.Symbol == "" {
+		return sp, errors.New("symbol required")
+	}
+	return sp, nil
+}
+
+// RegisterStateHandlers exposes State to the brain so it can query history on demand instead of
+// mirroring it in Python memory:
+//
+//	ticks  {"symbol","n"} -> last n trades (default 300, max 1000), oldest first
+//	quote  {"symbol"}     -> latest bid/ask with spread, spread_bps, imbalance
+//	stats  {"symbol"}     -> State.Snapshot: last price, returns, volumes, VWAP, volatility, quote
+func RegisterStateHandlers(p *Pipe, s *State) {
+	p.Handle("ticks", func(raw json.RawMessage) (interface{}, error) {
+		sp, err := parseSymbolParams(raw)
+		if err != nil {
+			return nil, err
+		}
+		if sp.N <= 0 {
+			sp.N = 300
+		}
+		return map[string]interface{}{"symbol": sp.Symbol, "ticks": s.LastTicks(sp.Symbol, sp.N)}, nil
+	})
+	p.Handle("quote", func(raw json.RawMessage) (interface{}, error) {
+		sp, err := parseSymbolParams(raw)
+		if err != nil {
+			return nil, err
+		}
+		q, ok := s.LastQuote(sp.Symbol)
+		if !ok {
+			return nil, fmt.Errorf("no quote for %s", sp.Symbol)
+		}
+		return map[string]interface{}{"symbol": sp.Symbol, "quote": q}, nil
+	})
+	p.Handle("stats", func(raw json.RawMessage) (interface{}, error) {
+		sp, err := parseSymbolParams(raw)
+		if err != nil {
+			return nil, err
+		}
+		return s.Snapshot(sp.Symbol), nil
+	})
+}
@@ -0,0 +1,168 @@
+package brain
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// spillBuffer holds events while the brain process is down so they can be replayed in order after a
+// restart. The first memLimit events stay in memory; beyond that they are appended to a file in dir
+// (when set) up to maxBytes. Events older than maxAge, or past their own TTL, at replay time are skipped.
+type spillBuffer struct {
+	dir      string
+	memLimit int
+	maxAge   time.Duration
+	maxBytes int64
+
+	mem       []spilled
+	file      *os.File
+	w         *bufio.Writer
+	fileBytes int64
+	fileCount int
+}
+
+// spillHeader is the per-record header on disk: expiry in unix nanos (8 bytes, 0 = none) and event
+// length (4 bytes).
+const spillHeader = 12
+
+type spilled struct {
+	expires time.Time // zero = never
+	line    []byte
+}
+
+func newSpillBuffer(dir string, memLimit int, maxAge time.Duration, maxBytes int64) (*spillBuffer, error) {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("brain spill dir: %w", err)
+		}
+	}
+	return &spillBuffer{dir: dir, memLimit: memLimit, maxAge: maxAge, maxBytes: maxBytes}, nil
+}
+
+// len is the number of buffered events.
+func (b *spillBuffer) len() int {
+	return len(b.mem) + b.fileCount
+}
+
+// add buffers one event received at now with its TTL deadline (zero = none) and reports whether an
+// event was lost: with no dir and memory full the oldest buffered event is evicted; with a dir the new
+// event is lost if the spill file is at maxBytes or fails.
+func (b *spillBuffer) add(line []byte, now, deadline time.Time) (lost bool) {
+	expires := deadline
+	if b.maxAge > 0 && (expires.IsZero() || now.Add(b.maxAge).Before(expires)) {
+		expires = now.Add(b.maxAge)
+	}
+	// Once spilling has started everything goes to disk so replay order is preserved.
+	if b.file == nil && len(b.mem) < b.memLimit {
+		b.mem = append(b.mem, spilled{expires: expires, line: line})
+		return false
+	}
+	if b.dir == "" {
+		if len(b.mem) == 0 {
+			return true
+		}
+		copy(b.mem, b.mem[1:])
+		b.mem[len(b.mem)-1] = spilled{expires: expires, line: line}
+		return true
+	}
+	if b.file == nil {
+		f, err := os.Create(filepath.Join(b.dir, "brain_spill.bin"))
+		if err != nil {
+			slog.Error("brain spill file create failed", "dir", b.dir, "err", err)
+			return true
+		}
+		b.file, b.w, b.fileBytes, b.fileCount = f, bufio.NewWriter(f), 0, 0
+		slog.Warn("brain down; spilling events to disk", "path", f.Name(), "in_memory", len(b.mem))
+	}
+	if b.maxBytes > 0 && b.fileBytes+int64(len(line)) > b.maxBytes {
+		return true
+	}
+	// Record: 8-byte expiry, 4-byte length, encoded event (binary-safe for every BRAIN_ENCODING)
+	rec := make([]byte, spillHeader, spillHeader+len(line))
+	if !expires.IsZero() {
+		binary.BigEndian.PutUint64(rec, uint64(expires.UnixNano()))
+	}
+	binary.BigEndian.PutUint32(rec[8:], uint32(len(line)))
+	rec = append(rec, line...)
+	if _, err := b.w.Write(rec); err != nil {
+		slog.Error("brain spill write failed", "err", err)
+		return true
+	}
+	b.fileBytes += int64(len(rec))
+	b.fileCount++
+	return false
+}
+
+// replay passes buffered events (memory first, then disk) to write in order, skipping those that expired,
+// then resets the buffer and removes the spill file. write returning false stops the replay;
+// the remaining events are discarded (the brain went away again).
+func (b *spillBuffer) replay(now time.Time, write func([]byte) bool) (replayed, expired int) {
+	defer b.reset()
+	fresh := func(expires time.Time) bool { return expires.IsZero() || !now.After(expires) }
+	for _, ev := range b.mem {
+		if !fresh(ev.expires) {
+			expired++
+			continue
+		}
+		if !write(ev.line) {
+			return replayed, expired
+		}
+		replayed++
+	}
+	if b.file == nil {
+		return replayed, expired
+	}
+	if err := b.w.Flush(); err != nil {
+		slog.Error("brain spill flush failed", "err", err)
+		return replayed, expired
+	}
+	if _, err := b.file.Seek(0, 0); err != nil {
+		slog.Error("brain spill seek failed", "err", err)
+		return replayed, expired
+	}
+	r := bufio.NewReader(b.file)
+	var hdr [spillHeader]byte
+	for {
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			if err != io.EOF {
+				slog.Error("brain spill read failed", "err", err)
+			}
+			return replayed, expired
+		}
+		line := make([]byte, binary.BigEndian.Uint32(hdr[8:]))
+		if _, err := io.ReadFull(r, line); err != nil {
+			slog.Error("brain spill read failed", "err", err)
+			return replayed, expired
+		}
+		var expires time.Time
+		if ns := binary.BigEndian.Uint64(hdr[:8]); ns != 0 {
+			expires = time.Unix(0, int64(ns))
+		}
+		if !fresh(expires) {
+			expired++
+			continue
+		}
+		if !write(line) {
+			return replayed, expired
+		}
+		replayed++
+	}
+}
+
+// reset drops all buffered events and removes the spill file.
+func (b *spillBuffer) reset() {
+	b.mem = nil
+	if b.file != nil {
+		name := b.file.Name()
+		_ = b.file.Close()
+		_ = os.Remove(name)
+		b.file, b.w = nil, nil
+	}
+	b.fileBytes, b.fileCount = 0, 0
+}
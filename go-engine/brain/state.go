@@ -3,78 +3,412 @@ package brain
 
 import (
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/sunnyp94/sentry-bridge/go-engine/alpaca"
+	"github.com/sunnyp94/sentry-bridge/go-engine/clock"
 )
 
 // lookback is how long we keep price/volume points for computing returns and volume_1m/5m.
 const lookback = 6 * time.Minute
 
-// pricePoint is a single (time, price) used to compute return_1m and return_5m.
+// TradeRef identifies a trade from the stream: the ID is unique per symbol and exchange. The zero value
+// is a trade without one (seeded or restored), which a correction matches by price and size instead.
+type TradeRef struct {
+	ID       int64
+	Exchange string
+}
+
+// pricePoint is a single (time, price) used to compute return_1m and return_5m, with the trade it came
+// from so a correction or cancellation can find it.
 type pricePoint struct {
-	t time.Time
-	p float64
+	t   time.Time
+	p   float64
+	ref TradeRef
 }
 
-// volumePoint is a single (time, size) for volume_1m and volume_5m.
+// volumePoint is a single (time, size) for volume_1m and volume_5m, with the trade's price and ref so a
+// correction or cancellation can find it.
 type volumePoint struct {
-	t time.Time
-	v int
+	t   time.Time
+	v   int
+	p   float64
+	ref TradeRef
+}
+
+// maxTicks caps the per-symbol raw tick history kept for brain queries (see rpc.go).
+const maxTicks = 1000
+
+// maxWindowPoints caps the price and volume points a symbol keeps within lookback (about 23 trades a
+// second over the window). Past it the oldest price point is dropped, so return_5m is measured from the
+// oldest one kept, and the oldest volume point is folded into the next, so the volume totals hold.
+const maxWindowPoints = 8192
+
+// Tick is a single trade kept for on-demand queries from the brain.
+type Tick struct {
+	Time  time.Time `json:"t"`
+	Price float64   `json:"p"`
+	Size  int       `json:"s"`
+	ref   TradeRef
+}
+
+// QuoteSnapshot is the latest NBBO for a symbol with derived spread and size imbalance.
+type QuoteSnapshot struct {
+	Bid       float64   `json:"bid"`
+	Ask       float64   `json:"ask"`
+	BidSize   int       `json:"bid_size"`
+	AskSize   int       `json:"ask_size"`
+	Mid       float64   `json:"mid"`
+	Spread    float64   `json:"spread"`
+	SpreadBps float64   `json:"spread_bps"`
+	Imbalance float64   `json:"imbalance"` // (bid_size - ask_size) / (bid_size + ask_size), in [-1, 1]
+	Time      time.Time `json:"t"`
+}
+
+// SymbolSnapshot is every derived value State holds for one symbol, read under one lock so the fields
+// agree with each other. Returns are measured against the last trade price.
+type SymbolSnapshot struct {
+	Symbol     string         `json:"symbol"`
+	LastPrice  float64        `json:"last_price"` // 0 = no trade seen
+	LastSize   int            `json:"last_size"`
+	LastTime   time.Time      `json:"last_time"`
+	Return1m   float64        `json:"return_1m"`
+	Return5m   float64        `json:"return_5m"`
+	Volume1m   int64          `json:"volume_1m"`
+	Volume5m   int64          `json:"volume_5m"`
+	DayVolume  int64          `json:"day_volume"`
+	VWAP       float64        `json:"vwap"` // today's (ET) volume-weighted trade price from the stream; 0 = no volume
+	Volatility float64        `json:"volatility"`
+	Quote      *QuoteSnapshot `json:"quote,omitempty"` // latest NBBO; nil = none seen
 }
 
 // State holds per-symbol price/volume history and volatility. Used to build return_1m, return_5m,
 // volume_1m, volume_5m for each trade/quote payload sent to the brain. Volatility is set from bars in main.
+// Each symbol has its own lock and ring buffers, so trades in different symbols never wait on each other
+// and a trade in a warm symbol allocates nothing; the State lock only guards the symbol table.
 type State struct {
-	mu sync.RWMutex
+	mu      sync.RWMutex
+	clock   clock.Clock
+	symbols map[string]*symbolState
 
-	priceHistory  map[string][]pricePoint
-	volumeHistory map[string][]volumePoint
-	volatility    map[string]float64
+	vol atomic.Pointer[VolSnapshot] // replaced whole on each refresh, never modified
 }
 
-func NewState() *State {
-	return &State{
-		priceHistory:  make(map[string][]pricePoint),
-		volumeHistory: make(map[string][]volumePoint),
-		volatility:    make(map[string]float64),
+// symbolState is one symbol's history, guarded by its own mu.
+type symbolState struct {
+	mu          sync.RWMutex
+	prices      ring[pricePoint]  // within lookback
+	volumes     ring[volumePoint] // within lookback
+	ticks       ring[Tick]        // last maxTicks
+	quote       QuoteSnapshot
+	hasQuote    bool
+	dayVolume   int64   // shares traded since midnight ET
+	dayNotional float64 // price * size since midnight ET, for VWAP
+	day         int     // ET date (yyyymmdd) dayVolume and dayNotional count
+}
+
+func newSymbolState() *symbolState {
+	return &symbolState{
+		prices:  newRing[pricePoint](maxWindowPoints),
+		volumes: newRing[volumePoint](maxWindowPoints),
+		ticks:   newRing[Tick](maxTicks),
 	}
 }
 
-// RecordTrade appends a trade to the symbol's history and trims older than lookback so Volume1m/5m and Return1m/5m are correct.
-func (s *State) RecordTrade(symbol string, price float64, size int, t time.Time) {
+func NewState() *State {
+	return &State{clock: clock.Real{}, symbols: make(map[string]*symbolState)}
+}
+
+// SetClock replaces the clock used for rolling windows and the ET day (e.g. a simulated clock in a
+// backtest). Call before recording.
+func (s *State) SetClock(c clock.Clock) {
+	s.mu.Lock()
+	s.clock = c
+	s.mu.Unlock()
+}
+
+// symbol returns symbol's state and the clock, creating the state if create is set (nil otherwise when
+// the symbol is unknown).
+func (s *State) symbol(symbol string, create bool) (*symbolState, clock.Clock) {
+	s.mu.RLock()
+	ss, c := s.symbols[symbol], s.clock
+	s.mu.RUnlock()
+	if ss != nil || !create {
+		return ss, c
+	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	if ss = s.symbols[symbol]; ss == nil {
+		ss = newSymbolState()
+		s.symbols[symbol] = ss
+	}
+	return ss, s.clock
+}
+
+// RecordTrade appends a trade to the symbol's history and trims older than lookback so Volume1m/5m and Return1m/5m are correct.
+// ref is kept with it for CorrectTrade.
+func (s *State) RecordTrade(symbol string, price float64, size int, t time.Time, ref TradeRef) {
+	ss, c := s.symbol(symbol, true)
 	now := t
 	if now.IsZero() {
-		now = time.Now()
+		now = c.Now()
 	}
 	cut := now.Add(-lookback)
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
 
 	// Trim price history to lookback window
-	s.priceHistory[symbol] = append(s.priceHistory[symbol], pricePoint{t: now, p: price})
-	ph := s.priceHistory[symbol]
-	for len(ph) > 0 && ph[0].t.Before(cut) {
-		ph = ph[1:]
+	ss.prices.push(pricePoint{t: now, p: price, ref: ref})
+	for ss.prices.len() > 0 && ss.prices.front().t.Before(cut) {
+		ss.prices.pop()
 	}
-	s.priceHistory[symbol] = ph
 
-	// Trim volume history to lookback window
-	if size > 0 {
-		s.volumeHistory[symbol] = append(s.volumeHistory[symbol], volumePoint{t: now, v: size})
-		vh := s.volumeHistory[symbol]
-		for len(vh) > 0 && vh[0].t.Before(cut) {
-			vh = vh[1:]
+	ss.recordVolume(price, size, now, cut, ref)
+
+	// Keep the last maxTicks trades for brain queries
+	ss.ticks.push(Tick{Time: now, Price: price, Size: size, ref: ref})
+}
+
+// RecordVolume counts a trade that doesn't update the last sale price (an odd lot, average-price or late
+// report; see alpaca.DecodeConditions) toward the volume windows and today's volume and VWAP, leaving
+// prices, returns and recent ticks alone.
+func (s *State) RecordVolume(symbol string, price float64, size int, t time.Time, ref TradeRef) {
+	ss, c := s.symbol(symbol, true)
+	now := t
+	if now.IsZero() {
+		now = c.Now()
+	}
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ss.recordVolume(price, size, now, now.Add(-lookback), ref)
+}
+
+// CorrectTrade applies a trade correction or cancellation from the stream to a trade recorded earlier:
+// the trade with the original's ref (or, for trades recorded without one, the newest with its price and
+// size) gets the corrected price and size, or is removed when newSize is 0 (a cancel), in the price,
+// volume and tick histories. Today's volume and VWAP totals are adjusted too, also for a trade that has
+// left the lookback window as long as the correction at t falls on the day they count. Returns false if
+// no recorded trade in the window matches.
+func (s *State) CorrectTrade(symbol string, ref TradeRef, oldPrice float64, oldSize int, newPrice float64, newSize int, t time.Time) bool {
+	ss, _ := s.symbol(symbol, false)
+	if ss == nil {
+		return false
+	}
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	// same reports whether a point recorded as rec with price p and size v is the corrected trade
+	same := func(rec TradeRef, p float64, v int) bool {
+		if ref.ID != 0 && rec.ID != 0 {
+			return rec == ref
 		}
-		s.volumeHistory[symbol] = vh
+		return p == oldPrice && (v < 0 || v == oldSize)
 	}
+	at, found := time.Time{}, false
+	for i := ss.volumes.len() - 1; i >= 0; i-- {
+		vp := ss.volumes.at(i)
+		if !same(vp.ref, vp.p, vp.v) {
+			continue
+		}
+		at, found = vp.t, true
+		if newSize > 0 {
+			ss.volumes.set(i, volumePoint{t: vp.t, v: newSize, p: newPrice, ref: vp.ref})
+		} else {
+			ss.volumes.remove(i)
+		}
+		break
+	}
+	if found {
+		t = at
+	}
+	if !t.IsZero() && etDate(t) == ss.day {
+		ss.dayVolume = max(ss.dayVolume+int64(newSize-oldSize), 0)
+		ss.dayNotional = max(ss.dayNotional+newPrice*float64(newSize)-oldPrice*float64(oldSize), 0)
+	}
+	for i := ss.prices.len() - 1; i >= 0; i-- {
+		pp := ss.prices.at(i)
+		if !same(pp.ref, pp.p, -1) || (found && pp.ref.ID == 0 && !pp.t.Equal(at)) {
+			continue
+		}
+		found = true
+		if newSize > 0 {
+			ss.prices.set(i, pricePoint{t: pp.t, p: newPrice, ref: pp.ref})
+		} else {
+			ss.prices.remove(i)
+		}
+		break
+	}
+	for i := ss.ticks.len() - 1; i >= 0; i-- {
+		tk := ss.ticks.at(i)
+		if !same(tk.ref, tk.Price, tk.Size) {
+			continue
+		}
+		if newSize > 0 {
+			ss.ticks.set(i, Tick{Time: tk.Time, Price: newPrice, Size: newSize, ref: tk.ref})
+		} else {
+			ss.ticks.remove(i)
+		}
+		break
+	}
+	return found
 }
 
-// SetVolatilityMap sets per-symbol volatility (e.g. from 30d bars in main). Used when building payloads.
-func (s *State) SetVolatilityMap(vol map[string]float64) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	for k, v := range vol {
-		s.volatility[k] = v
+// recordVolume trims volume history to the lookback window and adds to the day counters; ss.mu held.
+func (ss *symbolState) recordVolume(price float64, size int, now, cut time.Time, ref TradeRef) {
+	if size <= 0 {
+		return
+	}
+	ss.pushVolume(volumePoint{t: now, v: size, p: price, ref: ref})
+	for ss.volumes.len() > 0 && ss.volumes.front().t.Before(cut) {
+		ss.volumes.pop()
+	}
+
+	if day := etDate(now); day != ss.day {
+		ss.day, ss.dayVolume, ss.dayNotional = day, 0, 0
+	}
+	ss.dayVolume += int64(size)
+	ss.dayNotional += price * float64(size)
+}
+
+// pushVolume appends vp to the volume window; when it is full the oldest point is folded into the next
+// rather than dropped, so volume_5m keeps counting it. ss.mu held.
+func (ss *symbolState) pushVolume(vp volumePoint) {
+	if ss.volumes.full() && ss.volumes.len() > 1 {
+		oldest := ss.volumes.front()
+		ss.volumes.pop()
+		next := ss.volumes.front()
+		next.v += oldest.v
+		ss.volumes.set(0, next)
+	}
+	ss.volumes.push(vp)
+}
+
+// Seed fills an empty symbol's history from REST so the first stream events after a mid-session start
+// carry returns and volumes: minute bars (oldest first) become price and volume points at each bar's
+// end, and the snapshot adds the latest trade and quote and today's volume and VWAP. A symbol that
+// already has history from the stream is left alone.
+func (s *State) Seed(symbol string, bars []alpaca.Bar, snap alpaca.SnapshotData) {
+	ss, c := s.symbol(symbol, true)
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if ss.ticks.len() > 0 || ss.prices.len() > 0 {
+		return
+	}
+	now := c.Now()
+	cut := now.Add(-lookback)
+	var last time.Time
+	for _, b := range bars {
+		start, err := time.Parse(time.RFC3339, b.Time)
+		if err != nil || b.Close <= 0 {
+			continue
+		}
+		end := start.Add(time.Minute)
+		if end.After(now) {
+			end = now // the bar still forming
+		}
+		if end.Before(cut) || !end.After(last) {
+			continue
+		}
+		last = end
+		ss.prices.push(pricePoint{t: end, p: b.Close})
+		if b.Volume > 0 {
+			ss.pushVolume(volumePoint{t: end, v: int(b.Volume), p: b.Close})
+		}
+	}
+	if tr := snap.LatestTrade; tr != nil && tr.Price > 0 {
+		if t, err := time.Parse(time.RFC3339Nano, tr.Time); err == nil {
+			ss.ticks.push(Tick{Time: t, Price: tr.Price, Size: int(tr.Size)})
+			if t.After(last) && !t.Before(cut) {
+				ss.prices.push(pricePoint{t: t, p: tr.Price})
+			}
+		}
+	}
+	if q := snap.LatestQuote; q != nil && !ss.hasQuote {
+		t, _ := time.Parse(time.RFC3339Nano, q.Timestamp)
+		ss.quote, ss.hasQuote = newQuoteSnapshot(q.BidPrice, q.AskPrice, int(q.BidSize), int(q.AskSize), t), true
+	}
+	if d := snap.DailyBar; d != nil && d.Volume > 0 {
+		t, err := time.Parse(time.RFC3339, d.Time)
+		today := etDate(now)
+		if err == nil && etDate(t) == today {
+			vwap := d.VWAP
+			if vwap <= 0 {
+				vwap = d.Close
+			}
+			ss.day = today
+			ss.dayVolume = int64(d.Volume)
+			ss.dayNotional = vwap * float64(d.Volume)
+		}
+	}
+}
+
+// RecordQuote stores the latest quote for symbol so spread/imbalance can be queried on demand.
+func (s *State) RecordQuote(symbol string, bid, ask float64, bidSize, askSize int, t time.Time) {
+	ss, c := s.symbol(symbol, true)
+	if t.IsZero() {
+		t = c.Now()
+	}
+	q := newQuoteSnapshot(bid, ask, bidSize, askSize, t)
+	ss.mu.Lock()
+	ss.quote, ss.hasQuote = q, true
+	ss.mu.Unlock()
+}
+
+func newQuoteSnapshot(bid, ask float64, bidSize, askSize int, t time.Time) QuoteSnapshot {
+	q := QuoteSnapshot{Bid: bid, Ask: ask, BidSize: bidSize, AskSize: askSize, Time: t}
+	if bid > 0 && ask > 0 {
+		q.Mid = (bid + ask) / 2
+		q.Spread = ask - bid
+		q.SpreadBps = q.Spread / q.Mid * 10000
+	}
+	if total := bidSize + askSize; total > 0 {
+		q.Imbalance = float64(bidSize-askSize) / float64(total)
+	}
+	return q
+}
+
+// LastTicks returns up to n most recent trades for symbol, oldest first (n <= 0 = all kept).
+func (s *State) LastTicks(symbol string, n int) []Tick {
+	ss, _ := s.symbol(symbol, false)
+	if ss == nil {
+		return []Tick{}
+	}
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+	return ss.ticks.last(n)
+}
+
+// LastQuote returns the latest quote for symbol and whether one has been seen.
+func (s *State) LastQuote(symbol string) (QuoteSnapshot, bool) {
+	ss, _ := s.symbol(symbol, false)
+	if ss == nil {
+		return QuoteSnapshot{}, false
 	}
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+	return ss.quote, ss.hasQuote
+}
+
+// Volatility returns the last volatility set for symbol (0 if unknown).
+func (s *State) Volatility(symbol string) float64 {
+	return s.VolSnapshot().Volatility(symbol)
+}
+
+// VolSnapshot returns the current volatility snapshot (empty before the first refresh). Load it once per
+// payload or batch of payloads and read every volatility value from it.
+func (s *State) VolSnapshot() *VolSnapshot {
+	if v := s.vol.Load(); v != nil {
+		return v
+	}
+	return emptyVol
+}
+
+// PublishVolatility makes v, a fresh snapshot from one refresh (e.g. 30d bars in main), current. Its
+// Version is set to one more than the previous snapshot's; v must not be changed afterwards. Refreshes
+// must not run concurrently.
+func (s *State) PublishVolatility(v *VolSnapshot) {
+	v.Version = s.VolSnapshot().Version + 1
+	s.vol.Store(v)
 }
 
 // Volume1m returns total trade volume in the last 1 minute for symbol.
@@ -87,13 +421,79 @@ func (s *State) Volume5m(symbol string) int64 {
 	return s.volumeSince(symbol, 5*time.Minute)
 }
 
+// DayVolume returns the shares traded in symbol so far today (ET), as seen on the stream.
+func (s *State) DayVolume(symbol string) int64 {
+	ss, c := s.symbol(symbol, false)
+	if ss == nil {
+		return 0
+	}
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+	if !ss.today(c.Now()) {
+		return 0
+	}
+	return ss.dayVolume
+}
+
+// Snapshot returns the current derived values for symbol in one call, for callers that need several
+// of them (brain requests, snapshots, gap recovery) instead of one getter each.
+func (s *State) Snapshot(symbol string) SymbolSnapshot {
+	ss, c := s.symbol(symbol, false)
+	snap := SymbolSnapshot{Symbol: symbol, Volatility: s.VolSnapshot().Volatility(symbol)}
+	if ss == nil {
+		return snap
+	}
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+	now := c.Now()
+	snap.Volume1m = ss.volumeSince(now, time.Minute)
+	snap.Volume5m = ss.volumeSince(now, 5*time.Minute)
+	if ss.ticks.len() > 0 {
+		last := ss.ticks.back()
+		snap.LastPrice, snap.LastSize, snap.LastTime = last.Price, last.Size, last.Time
+		snap.Return1m = ss.returnSince(now, last.Price, time.Minute)
+		snap.Return5m = ss.returnSince(now, last.Price, 5*time.Minute)
+	}
+	if ss.today(now) {
+		snap.DayVolume = ss.dayVolume
+		if snap.DayVolume > 0 {
+			snap.VWAP = ss.dayNotional / float64(snap.DayVolume)
+		}
+	}
+	if ss.hasQuote {
+		q := ss.quote
+		snap.Quote = &q
+	}
+	return snap
+}
+
+// today reports whether the day counters are for now's ET date. Caller holds mu.
+func (ss *symbolState) today(now time.Time) bool {
+	return ss.day == etDate(now)
+}
+
+// etDate is t's ET date as yyyymmdd; unlike a formatted date it doesn't allocate on every trade.
+func etDate(t time.Time) int {
+	y, m, d := t.In(eastern).Date()
+	return y*10000 + int(m)*100 + d
+}
+
 func (s *State) volumeSince(symbol string, d time.Duration) int64 {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	cut := time.Now().Add(-d)
+	ss, c := s.symbol(symbol, false)
+	if ss == nil {
+		return 0
+	}
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+	return ss.volumeSince(c.Now(), d)
+}
+
+// volumeSince sums the volume points after now-d. Caller holds mu.
+func (ss *symbolState) volumeSince(now time.Time, d time.Duration) int64 {
+	cut := now.Add(-d)
 	var sum int64
-	for _, p := range s.volumeHistory[symbol] {
-		if p.t.After(cut) {
+	for i := 0; i < ss.volumes.len(); i++ {
+		if p := ss.volumes.at(i); p.t.After(cut) {
 			sum += int64(p.v)
 		}
 	}
@@ -111,17 +511,25 @@ func (s *State) Return5m(symbol string, currentPrice float64) float64 {
 }
 
 func (s *State) returnSince(symbol string, current float64, d time.Duration) float64 {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	cut := time.Now().Add(-d)
-	ph := s.priceHistory[symbol]
-	if len(ph) == 0 || current <= 0 {
+	ss, c := s.symbol(symbol, false)
+	if ss == nil {
+		return 0
+	}
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+	return ss.returnSince(c.Now(), current, d)
+}
+
+// returnSince measures current against the newest price point at or before now-d. Caller holds mu.
+func (ss *symbolState) returnSince(now time.Time, current float64, d time.Duration) float64 {
+	cut := now.Add(-d)
+	if ss.prices.len() == 0 || current <= 0 {
 		return 0
 	}
 	var past float64
-	for i := len(ph) - 1; i >= 0; i-- {
-		if ph[i].t.Before(cut) || ph[i].t.Equal(cut) {
-			past = ph[i].p
+	for i := ss.prices.len() - 1; i >= 0; i-- {
+		if p := ss.prices.at(i); !p.t.After(cut) {
+			past = p.p
 			break
 		}
 	}
@@ -131,20 +539,15 @@ func (s *State) returnSince(symbol string, current float64, d time.Duration) flo
 	return (current - past) / past
 }
 
-// Session returns "pre_open", "regular", or "post_close" based on Eastern Time.
+// Session returns "pre_open", "regular" or "post_close" based on Eastern Time, "closed" on weekends and
+// holidays, and "early_close" after the close on a half-day (holidays and half-days per SetCalendar).
 func Session(now time.Time) string {
-	et := now.In(eastern)
-	h := et.Hour()
-	m := et.Minute()
-	minutes := h*60 + m
-	// 9:30 = 570, 16:00 = 960
-	if minutes < 570 {
-		return "pre_open"
-	}
-	if minutes >= 960 {
-		return "post_close"
-	}
-	return "regular"
+	return calendar.Load().Session(now)
+}
+
+// Eastern returns the America/New_York location (fixed UTC-5 if tzdata is missing).
+func Eastern() *time.Location {
+	return eastern
 }
 
 // eastern is used by Session() to classify pre_open / regular / post_close.
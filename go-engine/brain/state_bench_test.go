@@ -0,0 +1,75 @@
+package brain
+
+import (
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// benchSymbols is about a SIP watchlist's worth of symbols.
+const benchSymbols = 500
+
+func benchState(b *testing.B) (*State, []string) {
+	s := NewState()
+	symbols := make([]string, benchSymbols)
+	start := time.Now().Add(-lookback)
+	for i := range symbols {
+		symbols[i] = "SYM" + strconv.Itoa(i)
+		// Warm every symbol with a full window so the benchmark measures steady state
+		for j := 0; j < 400; j++ {
+			s.RecordTrade(symbols[i], 100, 100, start.Add(time.Duration(j)*time.Second), TradeRef{ID: int64(j), Exchange: "V"})
+		}
+	}
+	return s, symbols
+}
+
+// BenchmarkRecordTrade records trades from parallel goroutines, each cycling through every symbol, as
+// the stream handlers do across hundreds of symbols.
+func BenchmarkRecordTrade(b *testing.B) {
+	s, symbols := benchState(b)
+	var seq atomic.Int64
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := int(seq.Add(1)) * 7919
+		for pb.Next() {
+			i++
+			s.RecordTrade(symbols[i%len(symbols)], 100+float64(i%10)/100, 100, time.Now(), TradeRef{ID: int64(i), Exchange: "V"})
+		}
+	})
+}
+
+// BenchmarkRecordTradeOneSymbol records trades in a single hot symbol from parallel goroutines, the
+// worst case for the per-symbol lock.
+func BenchmarkRecordTradeOneSymbol(b *testing.B) {
+	s, symbols := benchState(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			i++
+			s.RecordTrade(symbols[0], 100+float64(i%10)/100, 100, time.Now(), TradeRef{ID: int64(i), Exchange: "V"})
+		}
+	})
+}
+
+// BenchmarkRecordTradeWithReads mixes trades with the reads that build each trade event (returns and
+// volumes) in other symbols.
+func BenchmarkRecordTradeWithReads(b *testing.B) {
+	s, symbols := benchState(b)
+	var seq atomic.Int64
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := int(seq.Add(1)) * 7919
+		for pb.Next() {
+			i++
+			sym := symbols[i%len(symbols)]
+			s.RecordTrade(sym, 100, 100, time.Now(), TradeRef{ID: int64(i), Exchange: "V"})
+			_ = s.Volume1m(sym)
+			_ = s.Volume5m(sym)
+		}
+	})
+}
@@ -0,0 +1,107 @@
+package brain
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/sunnyp94/sentry-bridge/go-engine/events"
+)
+
+// maxTracebackLines bounds one collected traceback so a runaway stderr can't grow it without limit.
+const maxTracebackLines = 200
+
+// OnError sets fn to receive a BrainErrorEvent for every Python traceback the brain prints to stderr.
+// Count tracks how often the same exception line has been seen, so repeated crashes stand out.
+func (p *Pipe) OnError(fn func(events.BrainErrorEvent)) {
+	if p == nil {
+		return
+	}
+	p.errMu.Lock()
+	p.onError = fn
+	p.errMu.Unlock()
+}
+
+// stderrLoop logs each stderr line of brain process pid through slog (component=brain) and collects
+// Python tracebacks into brain_error events.
+func (p *Pipe) stderrLoop(r io.Reader, pid int) {
+	log := p.log.With("component", "brain", "pid", pid)
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), maxRequestLine)
+	var tb []string
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		level, complete := stderrLevel(line), false
+		switch {
+		case strings.HasPrefix(line, "Traceback (most recent call last):"):
+			tb = []string{line}
+		case tb != nil && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") ||
+			strings.HasPrefix(line, "During handling") || strings.HasPrefix(line, "The above exception")):
+			level = slog.LevelError
+			if len(tb) < maxTracebackLines {
+				tb = append(tb, line)
+			}
+		case tb != nil:
+			// First unindented line after the frames is the exception ("ValueError: ...")
+			level, complete = slog.LevelError, true
+			tb = append(tb, line)
+		}
+		log.Log(context.Background(), level, "brain stderr", "line", line)
+		if complete {
+			p.reportTraceback(line, tb, pid)
+			tb = nil
+		}
+	}
+	if tb != nil {
+		p.reportTraceback(tb[len(tb)-1], tb, pid)
+	}
+	if err := sc.Err(); err != nil && !errors.Is(err, io.EOF) {
+		log.Warn("brain stderr read ended", "err", err)
+	}
+}
+
+// reportTraceback counts the exception and hands the event to the OnError callback.
+func (p *Pipe) reportTraceback(exception string, lines []string, pid int) {
+	p.errMu.Lock()
+	if p.errCounts == nil {
+		p.errCounts = make(map[string]int)
+	}
+	p.errCounts[exception]++
+	count := p.errCounts[exception]
+	fn := p.onError
+	p.errMu.Unlock()
+	p.mu.Lock()
+	restarts := int(p.gen) - 1
+	p.mu.Unlock()
+	ev := events.BrainErrorEvent{
+		Brain:     p.name,
+		Exception: exception,
+		Traceback: strings.Join(lines, "\n"),
+		Count:     count,
+		Restarts:  restarts,
+		PID:       pid,
+	}
+	p.log.Error("brain traceback", "component", "brain", "exception", exception, "count", count, "restarts", restarts)
+	if fn != nil {
+		fn(ev)
+	}
+}
+
+// stderrLevel maps a Python log line to a slog level by its level name.
+func stderrLevel(line string) slog.Level {
+	switch {
+	case strings.Contains(line, "CRITICAL"), strings.Contains(line, "ERROR"), strings.HasPrefix(line, "Traceback"):
+		return slog.LevelError
+	case strings.Contains(line, "WARNING"):
+		return slog.LevelWarn
+	case strings.Contains(line, "DEBUG"):
+		return slog.LevelDebug
+	}
+	return slog.LevelInfo
+}
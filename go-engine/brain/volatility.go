@@ -0,0 +1,25 @@
+package brain
+
+import "time"
+
+// VolSnapshot is the result of one volatility refresh. It is never modified once published: a refresh
+// builds a new snapshot and swaps it in whole, so a payload built from one snapshot never mixes values
+// from two refreshes. Version goes up by one per refresh and is sent as vol_version, so the brain can tell
+// where a new regime estimate took over.
+type VolSnapshot struct {
+	Version      int64
+	AsOf         time.Time
+	Vol          map[string]float64 // annualized, VOL_METHOD
+	Parkinson    map[string]float64
+	GarmanKlass  map[string]float64
+	Beta         map[string]float64
+	Insufficient map[string]int // symbols with too few usable bars: bars received
+}
+
+// emptyVol is the snapshot before the first refresh.
+var emptyVol = &VolSnapshot{}
+
+// Volatility returns symbol's annualized volatility (0 if unknown).
+func (v *VolSnapshot) Volatility(symbol string) float64 {
+	return v.Vol[symbol]
+}
@@ -0,0 +1,24 @@
+package broker
+
+import "github.com/sunnyp94/sentry-bridge/go-engine/alpaca"
+
+// Alpaca is the default broker: Alpaca's Trading API and its trade_updates stream.
+type Alpaca struct {
+	*alpaca.TradingClient
+	baseURL   string
+	keyID     string
+	secretKey string
+}
+
+// NewAlpaca wraps client, whose trading base URL and keys also give the trade updates stream.
+func NewAlpaca(client *alpaca.TradingClient, baseURL, keyID, secretKey string) *Alpaca {
+	return &Alpaca{TradingClient: client, baseURL: baseURL, keyID: keyID, secretKey: secretKey}
+}
+
+func (a *Alpaca) Name() string { return "alpaca" }
+
+func (a *Alpaca) TradeUpdates(onUpdate func(u alpaca.TradeUpdate)) UpdateStream {
+	s := alpaca.NewTradeUpdateStream(a.baseURL, a.keyID, a.secretKey)
+	s.OnUpdate = onUpdate
+	return s
+}
@@ -0,0 +1,33 @@
+// Package broker puts order execution and the account (BROKER) behind one interface, so market data
+// can stay on one provider while orders go to another. Payloads are the alpaca types throughout; a
+// broker other than Alpaca converts into them. The market clock and calendar always come from Alpaca.
+package broker
+
+import (
+	"time"
+
+	"github.com/sunnyp94/sentry-bridge/go-engine/alpaca"
+)
+
+// Broker is where orders are placed and positions and the account are read.
+type Broker interface {
+	Name() string
+	GetAccount() (*alpaca.Account, error)
+	GetPositions() ([]alpaca.Position, error)
+	GetOpenOrders() ([]alpaca.Order, error)
+	GetOrders(status string, after time.Time, limit int) ([]alpaca.Order, error)
+	PlaceOrder(req alpaca.OrderRequest) (*alpaca.Order, error)
+	ReplaceOrder(id string, req alpaca.ReplaceRequest) (*alpaca.Order, error)
+	CancelOrder(id string) error
+	CancelAllOrders() error
+	CloseAllPositions() error
+	// TradeUpdates returns the account's order event stream, delivering each event to onUpdate.
+	TradeUpdates(onUpdate func(u alpaca.TradeUpdate)) UpdateStream
+}
+
+// UpdateStream is a trade update stream. Run processes events until the connection fails; the caller
+// reconnects. Close ends Run from another goroutine, and for good.
+type UpdateStream interface {
+	Run() error
+	Close() error
+}
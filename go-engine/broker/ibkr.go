@@ -0,0 +1,678 @@
+package broker
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sunnyp94/sentry-bridge/go-engine/alpaca"
+	"github.com/sunnyp94/sentry-bridge/go-engine/telemetry"
+)
+
+// IBKR places orders through the Interactive Brokers Client Portal API, via a logged-in Client Portal
+// Gateway (https://localhost:5000/v1/api by default). The gateway signs its own certificate, so TLS
+// verification is off unless asked for. Symbols are resolved to IBKR contract IDs (US stocks) and cached.
+type IBKR struct {
+	baseURL    string
+	accountID  string
+	poll       time.Duration
+	confirm    map[string]bool // warning message IDs submit confirms
+	httpClient *http.Client
+
+	mu     sync.Mutex
+	conids map[string]int64 // symbol -> contract ID
+}
+
+// NewIBKR builds an IBKR broker for accountID (e.g. U1234567); order updates are polled every poll
+// (default 2s). Order warnings with a message ID in confirm are confirmed; any other fails the order.
+func NewIBKR(baseURL, accountID string, poll time.Duration, verifyTLS bool, confirm []string) *IBKR {
+	if poll <= 0 {
+		poll = 2 * time.Second
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if !verifyTLS {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	b := &IBKR{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		accountID:  accountID,
+		poll:       poll,
+		confirm:    make(map[string]bool, len(confirm)),
+		httpClient: &http.Client{Timeout: 15 * time.Second, Transport: transport},
+		conids:     make(map[string]int64),
+	}
+	for _, id := range confirm {
+		b.confirm[strings.ToLower(id)] = true
+	}
+	return b
+}
+
+func (b *IBKR) Name() string { return "ibkr" }
+
+// do sends an optional JSON body and returns the response body for any 2xx status.
+func (b *IBKR) do(method, path string, payload interface{}) ([]byte, error) {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, b.baseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	start := time.Now()
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		telemetry.REST("ibkr", method, req.URL.Path, start, 0, 0, err)
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body)
+	telemetry.REST("ibkr", method, req.URL.Path, start, resp.StatusCode, 0, nil)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("ibkr API %s %s: %s (status %d)", method, path, string(respBody), resp.StatusCode)
+	}
+	return respBody, nil
+}
+
+// ibkrAmount is one value of the portfolio summary.
+type ibkrAmount struct {
+	Amount float64 `json:"amount"`
+}
+
+// GetAccount maps the portfolio summary onto the Alpaca account: net liquidation is equity and
+// portfolio value. Day trade count and PDT status aren't reported.
+func (b *IBKR) GetAccount() (*alpaca.Account, error) {
+	body, err := b.do("GET", "/portfolio/"+url.PathEscape(b.accountID)+"/summary", nil)
+	if err != nil {
+		return nil, err
+	}
+	var sum map[string]ibkrAmount
+	if err := json.Unmarshal(body, &sum); err != nil {
+		return nil, err
+	}
+	return &alpaca.Account{
+		ID:              b.accountID,
+		Status:          "ACTIVE",
+		Currency:        "USD",
+		Equity:          alpaca.FlexFloat(sum["netliquidation"].Amount),
+		LastEquity:      alpaca.FlexFloat(sum["previousdayequitywithloanvalue"].Amount),
+		Cash:            alpaca.FlexFloat(sum["totalcashvalue"].Amount),
+		BuyingPower:     alpaca.FlexFloat(sum["buyingpower"].Amount),
+		PortfolioValue:  alpaca.FlexFloat(sum["netliquidation"].Amount),
+		ShortingEnabled: true,
+	}, nil
+}
+
+// ibkrPosition is one entry of /portfolio/{account}/positions/{page}.
+type ibkrPosition struct {
+	Conid         int64   `json:"conid"`
+	Ticker        string  `json:"ticker"`
+	ContractDesc  string  `json:"contractDesc"`
+	Position      float64 `json:"position"`
+	MktPrice      float64 `json:"mktPrice"`
+	MktValue      float64 `json:"mktValue"`
+	AvgPrice      float64 `json:"avgPrice"`
+	UnrealizedPnl float64 `json:"unrealizedPnl"`
+}
+
+// GetPositions returns open positions; IBKR pages them 100 at a time.
+func (b *IBKR) GetPositions() ([]alpaca.Position, error) {
+	var out []alpaca.Position
+	for page := 0; ; page++ {
+		body, err := b.do("GET", "/portfolio/"+url.PathEscape(b.accountID)+"/positions/"+strconv.Itoa(page), nil)
+		if err != nil {
+			return nil, err
+		}
+		var rows []ibkrPosition
+		if err := json.Unmarshal(body, &rows); err != nil {
+			return nil, err
+		}
+		for _, r := range rows {
+			if r.Position == 0 {
+				continue
+			}
+			sym := r.Ticker
+			if sym == "" {
+				sym = r.ContractDesc
+			}
+			b.mu.Lock()
+			b.conids[sym] = r.Conid
+			b.mu.Unlock()
+			p := alpaca.Position{
+				Symbol:        sym,
+				Qty:           strconv.FormatFloat(r.Position, 'f', -1, 64),
+				Side:          "long",
+				MarketValue:   strconv.FormatFloat(r.MktValue, 'f', 2, 64),
+				UnrealizedPL:  strconv.FormatFloat(r.UnrealizedPnl, 'f', 2, 64),
+				CurrentPrice:  alpaca.FlexFloat(r.MktPrice),
+				AvgEntryPrice: alpaca.FlexFloat(r.AvgPrice),
+			}
+			if r.Position < 0 {
+				p.Side = "short"
+			}
+			cost := r.AvgPrice * r.Position
+			p.CostBasis = strconv.FormatFloat(cost, 'f', 2, 64)
+			if cost != 0 {
+				p.UnrealizedPLPC = strconv.FormatFloat(r.UnrealizedPnl/math.Abs(cost), 'f', 4, 64)
+			}
+			out = append(out, p)
+		}
+		if len(rows) < 100 {
+			return out, nil
+		}
+	}
+}
+
+// ibkrOrder is one entry of /iserver/account/orders.
+type ibkrOrder struct {
+	OrderID           int64       `json:"orderId"`
+	Conid             int64       `json:"conid"`
+	Ticker            string      `json:"ticker"`
+	Side              string      `json:"side"` // "BUY" or "SELL"
+	OrderType         string      `json:"orderType"`
+	TimeInForce       string      `json:"timeInForce"`
+	Status            string      `json:"status"`
+	TotalSize         json.Number `json:"totalSize"`
+	FilledQuantity    json.Number `json:"filledQuantity"`
+	AvgPrice          json.Number `json:"avgPrice"`
+	Price             json.Number `json:"price"`
+	AuxPrice          json.Number `json:"auxPrice"`
+	OrderRef          string      `json:"order_ref"`
+	OutsideRTH        bool        `json:"outsideRTH"`
+	LastExecutionTime int64       `json:"lastExecutionTime_r"` // Unix ms
+}
+
+// ibkrOrderTypes maps IBKR order types to Alpaca's, and back.
+var ibkrOrderTypes = map[string]string{"MKT": "market", "LMT": "limit", "STP": "stop", "STP LMT": "stop_limit", "STOP_LIMIT": "stop_limit"}
+
+// status maps the IBKR order status onto Alpaca's.
+func (o ibkrOrder) status() string {
+	filled, _ := o.FilledQuantity.Float64()
+	switch o.Status {
+	case "Filled":
+		return "filled"
+	case "Cancelled", "ApiCancelled":
+		return "canceled"
+	case "Inactive":
+		return "rejected"
+	case "PendingCancel":
+		return "pending_cancel"
+	case "PendingSubmit", "ApiPending":
+		return "pending_new"
+	}
+	if filled > 0 {
+		return "partially_filled"
+	}
+	return "new"
+}
+
+func (o ibkrOrder) terminal() bool {
+	switch o.status() {
+	case "filled", "canceled", "rejected":
+		return true
+	}
+	return false
+}
+
+func (o ibkrOrder) order() alpaca.Order {
+	out := alpaca.Order{
+		ID:            strconv.FormatInt(o.OrderID, 10),
+		ClientOrderID: o.OrderRef,
+		Symbol:        o.Ticker,
+		Side:          strings.ToLower(o.Side),
+		Qty:           o.TotalSize.String(),
+		FilledQty:     o.FilledQuantity.String(),
+		Type:          ibkrOrderTypes[o.OrderType],
+		TimeInForce:   strings.ToLower(o.TimeInForce),
+		Status:        o.status(),
+		ExtendedHours: o.OutsideRTH,
+	}
+	if out.FilledQty == "" {
+		out.FilledQty = "0"
+	}
+	if avg, err := o.AvgPrice.Float64(); err == nil && avg > 0 {
+		p := alpaca.FlexFloat(avg)
+		out.FilledAvgPrice = &p
+	}
+	if lp, err := o.Price.Float64(); err == nil && lp > 0 {
+		p := alpaca.FlexFloat(lp)
+		out.LimitPrice = &p
+	}
+	if sp, err := o.AuxPrice.Float64(); err == nil && sp > 0 {
+		p := alpaca.FlexFloat(sp)
+		out.StopPrice = &p
+	}
+	if o.LastExecutionTime > 0 {
+		t := time.UnixMilli(o.LastExecutionTime).UTC().Format(time.RFC3339)
+		out.UpdatedAt = t
+		switch out.Status {
+		case "filled":
+			out.FilledAt = t
+		case "canceled":
+			out.CanceledAt = t
+		}
+	}
+	return out
+}
+
+// orders returns the orders of the current session (all the Client Portal API keeps).
+func (b *IBKR) orders() ([]ibkrOrder, error) {
+	body, err := b.do("GET", "/iserver/account/orders", nil)
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Orders []ibkrOrder `json:"orders"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Orders, nil
+}
+
+func (b *IBKR) GetOpenOrders() ([]alpaca.Order, error) {
+	return b.GetOrders("open", time.Time{}, 0)
+}
+
+// GetOrders filters the session's orders by status ("open", "closed" or "all") and last update after
+// the given time. IBKR keeps only the current session's orders, so older ones are never returned.
+func (b *IBKR) GetOrders(status string, after time.Time, limit int) ([]alpaca.Order, error) {
+	rows, err := b.orders()
+	if err != nil {
+		return nil, err
+	}
+	var out []alpaca.Order
+	for _, r := range rows {
+		switch {
+		case status == "open" && r.terminal(), status == "closed" && !r.terminal():
+			continue
+		case !after.IsZero() && r.LastExecutionTime > 0 && time.UnixMilli(r.LastExecutionTime).Before(after):
+			continue
+		}
+		out = append(out, r.order())
+		if limit > 0 && len(out) == limit {
+			break
+		}
+	}
+	return out, nil
+}
+
+// conid resolves a US stock symbol to its IBKR contract ID.
+func (b *IBKR) conid(symbol string) (int64, error) {
+	symbol = strings.ToUpper(symbol)
+	b.mu.Lock()
+	id, ok := b.conids[symbol]
+	b.mu.Unlock()
+	if ok {
+		return id, nil
+	}
+	body, err := b.do("GET", "/trsrv/stocks?symbols="+url.QueryEscape(symbol), nil)
+	if err != nil {
+		return 0, err
+	}
+	var resp map[string][]struct {
+		Contracts []struct {
+			Conid int64 `json:"conid"`
+			IsUS  bool  `json:"isUS"`
+		} `json:"contracts"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return 0, err
+	}
+	for _, stock := range resp[symbol] {
+		for _, c := range stock.Contracts {
+			if c.IsUS {
+				b.mu.Lock()
+				b.conids[symbol] = c.Conid
+				b.mu.Unlock()
+				return c.Conid, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("ibkr: no US stock contract for %s", symbol)
+}
+
+// ibkrOrderRequest is one order of POST /iserver/account/{account}/orders (and the modify body).
+type ibkrOrderRequest struct {
+	Conid      int64   `json:"conid"`
+	OrderType  string  `json:"orderType"`
+	Side       string  `json:"side"`
+	Quantity   float64 `json:"quantity"`
+	Tif        string  `json:"tif"`
+	Price      float64 `json:"price,omitempty"`
+	AuxPrice   float64 `json:"auxPrice,omitempty"`
+	COID       string  `json:"cOID,omitempty"`
+	OutsideRTH bool    `json:"outsideRTH,omitempty"`
+}
+
+// ibkrReply is one element of an order submission response: the order, or a warning to confirm.
+type ibkrReply struct {
+	OrderID     string   `json:"order_id"`
+	OrderStatus string   `json:"order_status"`
+	ID          string   `json:"id"`
+	Message     []string `json:"message"`
+	MessageIDs  []string `json:"messageIds"`
+	Error       string   `json:"error"`
+}
+
+// submit posts an order (or a modification) and answers the precautionary warnings IBKR asks about
+// (price far from market, order size...). A warning is confirmed only when every message ID in it is
+// in b.confirm; otherwise it is declined and the order fails with the warning.
+func (b *IBKR) submit(path string, payload interface{}) (string, error) {
+	body, err := b.do("POST", path, payload)
+	for i := 0; i < 5; i++ {
+		if err != nil {
+			return "", err
+		}
+		var replies []ibkrReply
+		if err := json.Unmarshal(body, &replies); err != nil {
+			var single ibkrReply
+			if json.Unmarshal(body, &single) != nil {
+				return "", fmt.Errorf("ibkr order response: %s", string(body))
+			}
+			replies = []ibkrReply{single}
+		}
+		if len(replies) == 0 {
+			return "", fmt.Errorf("ibkr order response: %s", string(body))
+		}
+		r := replies[0]
+		switch {
+		case r.Error != "":
+			return "", fmt.Errorf("ibkr order rejected: %s", r.Error)
+		case r.OrderID != "":
+			return r.OrderID, nil
+		case r.ID != "":
+			msg := strings.Join(r.Message, " ")
+			if !b.confirms(r.MessageIDs) {
+				_, _ = b.do("POST", "/iserver/reply/"+url.PathEscape(r.ID), map[string]bool{"confirmed": false})
+				return "", fmt.Errorf("ibkr order warning not confirmed (message ids %s): %s", strings.Join(r.MessageIDs, ","), msg)
+			}
+			slog.Info("ibkr order warning confirmed", "message_ids", r.MessageIDs, "message", msg)
+			body, err = b.do("POST", "/iserver/reply/"+url.PathEscape(r.ID), map[string]bool{"confirmed": true})
+		default:
+			return "", fmt.Errorf("ibkr order response: %s", string(body))
+		}
+	}
+	return "", errors.New("ibkr order: too many confirmation prompts")
+}
+
+// confirms reports whether every warning in ids may be confirmed.
+func (b *IBKR) confirms(ids []string) bool {
+	if len(ids) == 0 {
+		return false
+	}
+	for _, id := range ids {
+		if !b.confirm[strings.ToLower(id)] {
+			return false
+		}
+	}
+	return true
+}
+
+// request converts an Alpaca order request.
+func (b *IBKR) request(req alpaca.OrderRequest) (ibkrOrderRequest, error) {
+	conid, err := b.conid(req.Symbol)
+	if err != nil {
+		return ibkrOrderRequest{}, err
+	}
+	qty, err := strconv.ParseFloat(req.Qty, 64)
+	if err != nil {
+		return ibkrOrderRequest{}, fmt.Errorf("qty %q: %w", req.Qty, err)
+	}
+	if req.OrderClass != "" && req.OrderClass != "simple" {
+		return ibkrOrderRequest{}, fmt.Errorf("ibkr: order class %q not supported", req.OrderClass)
+	}
+	r := ibkrOrderRequest{Conid: conid, Side: strings.ToUpper(req.Side), Quantity: qty, Tif: strings.ToUpper(req.TimeInForce),
+		Price: req.LimitPrice, COID: req.ClientOrderID, OutsideRTH: req.ExtendedHours}
+	switch req.Type {
+	case "", "market":
+		r.OrderType = "MKT"
+	case "limit":
+		r.OrderType = "LMT"
+	case "stop":
+		r.OrderType, r.Price = "STP", req.StopPrice
+	case "stop_limit":
+		r.OrderType, r.AuxPrice = "STOP_LIMIT", req.StopPrice
+	default:
+		return ibkrOrderRequest{}, fmt.Errorf("ibkr: order type %q not supported", req.Type)
+	}
+	if r.Tif == "" {
+		r.Tif = "DAY"
+	}
+	return r, nil
+}
+
+// PlaceOrder submits an order; the returned record is the request as accepted (status "new").
+func (b *IBKR) PlaceOrder(req alpaca.OrderRequest) (*alpaca.Order, error) {
+	r, err := b.request(req)
+	if err != nil {
+		return nil, err
+	}
+	id, err := b.submit("/iserver/account/"+url.PathEscape(b.accountID)+"/orders", map[string][]ibkrOrderRequest{"orders": {r}})
+	if err != nil {
+		return nil, err
+	}
+	o := &alpaca.Order{ID: id, ClientOrderID: req.ClientOrderID, Symbol: strings.ToUpper(req.Symbol), Side: req.Side, Qty: req.Qty,
+		FilledQty: "0", Type: ibkrOrderTypes[r.OrderType], TimeInForce: strings.ToLower(r.Tif), Status: "new",
+		ExtendedHours: req.ExtendedHours, SubmittedAt: time.Now().UTC().Format(time.RFC3339)}
+	if req.LimitPrice > 0 {
+		p := alpaca.FlexFloat(req.LimitPrice)
+		o.LimitPrice = &p
+	}
+	if req.StopPrice > 0 {
+		p := alpaca.FlexFloat(req.StopPrice)
+		o.StopPrice = &p
+	}
+	return o, nil
+}
+
+// ReplaceOrder modifies an open order in place: unlike Alpaca, IBKR keeps the order ID.
+func (b *IBKR) ReplaceOrder(id string, req alpaca.ReplaceRequest) (*alpaca.Order, error) {
+	rows, err := b.orders()
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range rows {
+		if strconv.FormatInt(o.OrderID, 10) != id {
+			continue
+		}
+		total, _ := o.TotalSize.Float64()
+		price, _ := o.Price.Float64()
+		aux, _ := o.AuxPrice.Float64()
+		r := ibkrOrderRequest{Conid: o.Conid, OrderType: o.OrderType, Side: o.Side, Quantity: total, Tif: o.TimeInForce,
+			Price: price, AuxPrice: aux, OutsideRTH: o.OutsideRTH}
+		if req.Qty != "" {
+			if r.Quantity, err = strconv.ParseFloat(req.Qty, 64); err != nil {
+				return nil, fmt.Errorf("qty %q: %w", req.Qty, err)
+			}
+		}
+		if req.TimeInForce != "" {
+			r.Tif = strings.ToUpper(req.TimeInForce)
+		}
+		if req.LimitPrice > 0 {
+			r.Price = req.LimitPrice
+		}
+		if req.StopPrice > 0 {
+			if r.OrderType == "STP" {
+				r.Price = req.StopPrice
+			} else {
+				r.AuxPrice = req.StopPrice
+			}
+		}
+		if _, err := b.submit("/iserver/account/"+url.PathEscape(b.accountID)+"/order/"+url.PathEscape(id), r); err != nil {
+			return nil, err
+		}
+		out := o.order()
+		out.Qty = strconv.FormatFloat(r.Quantity, 'f', -1, 64)
+		out.TimeInForce = strings.ToLower(r.Tif)
+		if req.LimitPrice > 0 {
+			p := alpaca.FlexFloat(req.LimitPrice)
+			out.LimitPrice = &p
+		}
+		out.Replaces = id
+		return &out, nil
+	}
+	return nil, fmt.Errorf("ibkr: order %s not found", id)
+}
+
+// CancelOrder requests cancellation of an open order. The final state arrives as a trade update.
+func (b *IBKR) CancelOrder(id string) error {
+	_, err := b.do("DELETE", "/iserver/account/"+url.PathEscape(b.accountID)+"/order/"+url.PathEscape(id), nil)
+	return err
+}
+
+// CancelAllOrders cancels every open order one by one (the API has no bulk cancel).
+func (b *IBKR) CancelAllOrders() error {
+	orders, err := b.GetOpenOrders()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, o := range orders {
+		if err := b.CancelOrder(o.ID); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// CloseAllPositions cancels open orders, then sends a market order against every position.
+func (b *IBKR) CloseAllPositions() error {
+	if err := b.CancelAllOrders(); err != nil {
+		return err
+	}
+	positions, err := b.GetPositions()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, p := range positions {
+		side := "sell"
+		qty := strings.TrimPrefix(p.Qty, "-")
+		if p.Side == "short" {
+			side = "buy"
+		}
+		if _, err := b.PlaceOrder(alpaca.OrderRequest{Symbol: p.Symbol, Qty: qty, Side: side, Type: "market", TimeInForce: "day"}); err != nil {
+			errs = append(errs, fmt.Errorf("close %s: %w", p.Symbol, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// TradeUpdates polls the session's orders and reports what changed since the last poll as Alpaca trade
+// updates ("new", "partial_fill", "fill", "canceled", "rejected"). A fill's price is the average price
+// of the quantity filled since the last poll. Each poll also keeps the gateway session alive.
+func (b *IBKR) TradeUpdates(onUpdate func(u alpaca.TradeUpdate)) UpdateStream {
+	return &ibkrUpdates{broker: b, onUpdate: onUpdate, closed: make(chan struct{})}
+}
+
+// ibkrSeen is an order as of the last poll.
+type ibkrSeen struct {
+	status string
+	filled float64
+	avg    float64
+}
+
+type ibkrUpdates struct {
+	broker   *IBKR
+	onUpdate func(u alpaca.TradeUpdate)
+	seen     map[int64]ibkrSeen // nil until the first poll: orders already there are the baseline
+
+	closeOnce sync.Once
+	closed    chan struct{} // closed by Close
+}
+
+func (s *ibkrUpdates) Close() error {
+	s.closeOnce.Do(func() { close(s.closed) })
+	return nil
+}
+
+// Run polls until a poll or the session keepalive fails, or Close.
+func (s *ibkrUpdates) Run() error {
+	slog.Info("trade updates polling", "broker", "ibkr", "interval", s.broker.poll)
+	lastTickle := time.Time{}
+	for {
+		if time.Since(lastTickle) > time.Minute {
+			if _, err := s.broker.do("POST", "/tickle", nil); err != nil {
+				return fmt.Errorf("ibkr session keepalive: %w", err)
+			}
+			lastTickle = time.Now()
+		}
+		rows, err := s.broker.orders()
+		if err != nil {
+			return err
+		}
+		s.diff(rows)
+		select {
+		case <-s.closed:
+			return alpaca.ErrStreamClosed
+		case <-time.After(s.broker.poll):
+		}
+	}
+}
+
+func (s *ibkrUpdates) diff(rows []ibkrOrder) {
+	baseline := s.seen == nil
+	if baseline {
+		s.seen = make(map[int64]ibkrSeen, len(rows))
+	}
+	for _, r := range rows {
+		filled, _ := r.FilledQuantity.Float64()
+		avg, _ := r.AvgPrice.Float64()
+		cur := ibkrSeen{status: r.status(), filled: filled, avg: avg}
+		prev, known := s.seen[r.OrderID]
+		s.seen[r.OrderID] = cur
+		if baseline || s.onUpdate == nil || (known && prev == cur) {
+			continue
+		}
+		o := r.order()
+		u := alpaca.TradeUpdate{Order: o, Timestamp: time.Now().UTC().Format(time.RFC3339Nano)}
+		if !known && !r.terminal() && filled == 0 {
+			u.Event = "new"
+			s.onUpdate(u)
+			continue
+		}
+		if filled > prev.filled {
+			qty := filled - prev.filled
+			price := (avg*filled - prev.avg*prev.filled) / qty
+			p, q := alpaca.FlexFloat(price), alpaca.FlexFloat(qty)
+			u.Price, u.Qty = &p, &q
+			u.ExecutionID = fmt.Sprintf("%d-%s", r.OrderID, strconv.FormatFloat(filled, 'f', -1, 64))
+			u.Event = "partial_fill"
+			if cur.status == "filled" {
+				u.Event = "fill"
+			}
+			s.onUpdate(u)
+			continue
+		}
+		if cur.status != prev.status {
+			switch cur.status {
+			case "canceled", "rejected", "pending_cancel":
+				u.Event = cur.status
+			case "new":
+				u.Event = "new"
+			default:
+				continue
+			}
+			s.onUpdate(u)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+// Package command gives the brain a write path back through the engine: it reads commands (orders,
+// subscription changes, kill switch) from a Redis stream, runs the registered handler for each and
+// reports the outcome as a command_result event.
+package command
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/sunnyp94/sentry-bridge/go-engine/brain"
+	"github.com/sunnyp94/sentry-bridge/go-engine/events"
+)
+
+const (
+	readBlock    = 5 * time.Second  // XREAD BLOCK; the reader checks for shutdown this often
+	readCount    = 100              // entries per XREAD
+	retryBackoff = 5 * time.Second  // wait after a failed read before reconnecting
+	redisTimeout = 5 * time.Second  // connection check at startup
+	maxAge       = 30 * time.Second // commands older than this when read are refused, not executed
+)
+
+// Consumer reads a Redis stream whose entries have the fields command (name), id (optional, echoed in
+// the result) and params (JSON object). Only entries added after the engine starts are read, so a
+// restart never replays old orders. Handlers run one at a time in stream order.
+type Consumer struct {
+	client   *redis.Client
+	stream   string
+	handlers map[string]brain.Handler
+
+	// OnResult receives the outcome of every command. Optional.
+	OnResult func(events.CommandResultEvent)
+}
+
+// NewConsumer reads commands from stream on the Redis server at url. An unreachable server is not an
+// error: Run keeps retrying.
+func NewConsumer(url, stream string) (*Consumer, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("command stream: %w", err)
+	}
+	return &Consumer{client: redis.NewClient(opts), stream: stream, handlers: make(map[string]brain.Handler)}, nil
+}
+
+// Handle registers the handler for a command name. Call before Run.
+func (c *Consumer) Handle(command string, h brain.Handler) {
+	c.handlers[strings.ToLower(command)] = h
+}
+
+// Run reads and executes commands until ctx is done, then closes the client.
+func (c *Consumer) Run(ctx context.Context) {
+	defer c.client.Close()
+	last := c.startID(ctx)
+	for ctx.Err() == nil {
+		res, err := c.client.XRead(ctx, &redis.XReadArgs{Streams: []string{c.stream, last}, Count: readCount, Block: readBlock}).Result()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			slog.Warn("command stream read failed; retrying", "stream", c.stream, "err", err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(retryBackoff):
+			}
+			continue
+		}
+		for _, s := range res {
+			for _, msg := range s.Messages {
+				last = msg.ID
+				c.execute(msg)
+			}
+		}
+	}
+}
+
+// startID is the stream's last entry ID at startup ("0-0" for a missing stream), so reads begin with
+// commands added from now on even across reconnects.
+func (c *Consumer) startID(ctx context.Context) string {
+	for {
+		cctx, cancel := context.WithTimeout(ctx, redisTimeout)
+		msgs, err := c.client.XRevRangeN(cctx, c.stream, "+", "-", 1).Result()
+		cancel()
+		if err == nil {
+			if len(msgs) == 0 {
+				return "0-0"
+			}
+			return msgs[0].ID
+		}
+		if ctx.Err() != nil {
+			return "$"
+		}
+		slog.Warn("command stream unreachable; retrying", "stream", c.stream, "err", err)
+		select {
+		case <-ctx.Done():
+			return "$"
+		case <-time.After(retryBackoff):
+		}
+	}
+}
+
+// execute validates one entry, runs its handler and reports the result.
+func (c *Consumer) execute(msg redis.XMessage) {
+	field := func(k string) string {
+		v, _ := msg.Values[k].(string)
+		return v
+	}
+	res := events.CommandResultEvent{ID: field("id"), Command: strings.ToLower(strings.TrimSpace(field("command"))), StreamID: msg.ID}
+	result, err := c.run(res.Command, field("params"), msg.ID)
+	if err != nil {
+		res.Error = err.Error()
+		slog.Warn("command failed", "command", res.Command, "id", res.ID, "stream_id", msg.ID, "err", err)
+	} else {
+		res.OK, res.Result = true, result
+		slog.Info("command executed", "command", res.Command, "id", res.ID, "stream_id", msg.ID)
+	}
+	if c.OnResult != nil {
+		c.OnResult(res)
+	}
+}
+
+func (c *Consumer) run(command, params, streamID string) (interface{}, error) {
+	h, ok := c.handlers[command]
+	if !ok {
+		return nil, fmt.Errorf("unknown command %q", command)
+	}
+	if age := time.Since(entryTime(streamID)); age > maxAge {
+		return nil, fmt.Errorf("command is %s old (max %s)", age.Round(time.Second), maxAge)
+	}
+	var raw json.RawMessage
+	if params != "" {
+		if !json.Valid([]byte(params)) {
+			return nil, errors.New("params is not valid JSON")
+		}
+		raw = json.RawMessage(params)
+	}
+	return h(raw)
+}
+
+// entryTime is the millisecond timestamp at the start of a stream entry ID.
+func entryTime(id string) time.Time {
+	ms, _, _ := strings.Cut(id, "-")
+	n, err := strconv.ParseInt(ms, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.UnixMilli(n)
+}
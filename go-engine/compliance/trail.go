@@ -0,0 +1,333 @@
+// Package compliance writes an order audit trail for compliance review (FINRA/CAT-style order lifecycle:
+// intents, new orders, modifications, executions, cancels). It is kept separate from operational logs:
+// one JSONL file per trading day under its own directory, pruned after a configurable retention period.
+package compliance
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sunnyp94/sentry-bridge/go-engine/alpaca"
+)
+
+// SchemaVersion is bumped whenever Event fields change meaning, so reviewers can parse older files.
+const SchemaVersion = 1
+
+// Event types written to the trail.
+const (
+	EventIntent      = "order_intent"       // order requested (brain or command stream) before submission
+	EventRejected    = "order_rejected"     // intent refused by the engine's checks, never sent to the broker
+	EventNew         = "order_new"          // order first seen at the broker
+	EventModified    = "order_modified"     // qty/price/tif changed or order replaced
+	EventPartialFill = "order_partial_fill" // filled_qty increased but order still working
+	EventFill        = "order_fill"         // order fully filled
+	EventCanceled    = "order_canceled"     // canceled, expired, or rejected
+	EventStatus      = "order_status"       // any other status transition
+)
+
+// Event is one line in the audit trail. RecordedAt is RFC3339Nano UTC; EventTime is the broker's own
+// timestamp; quantities are kept as the strings the broker reported so nothing is lost to float formatting.
+type Event struct {
+	Schema         int     `json:"schema"`
+	RecordedAt     string  `json:"recorded_at"`          // when the engine wrote this record
+	EventTime      string  `json:"event_time,omitempty"` // broker timestamp for the transition (updated_at/filled_at)
+	EventType      string  `json:"event_type"`           // one of the Event* constants
+	OrderID        string  `json:"order_id,omitempty"`   // broker order ID
+	ClientOrderID  string  `json:"client_order_id,omitempty"`
+	ParentOrderID  string  `json:"parent_order_id,omitempty"` // order this one replaces (modifications)
+	Symbol         string  `json:"symbol"`
+	Side           string  `json:"side,omitempty"`
+	OrderType      string  `json:"order_type,omitempty"`
+	TimeInForce    string  `json:"time_in_force,omitempty"`
+	Qty            string  `json:"qty,omitempty"`
+	FilledQty      string  `json:"filled_qty,omitempty"`
+	FilledAvgPrice float64 `json:"filled_avg_price,omitempty"`
+	LimitPrice     float64 `json:"limit_price,omitempty"`
+	StopPrice      float64 `json:"stop_price,omitempty"`
+	ExtendedHours  bool    `json:"extended_hours,omitempty"`
+	Status         string  `json:"status,omitempty"`
+	PrevStatus     string  `json:"prev_status,omitempty"`
+	Source         string  `json:"source,omitempty"`         // "broker" for polled orders, "brain"/"command" for intents
+	CorrelationID  string  `json:"correlation_id,omitempty"` // market event behind an intent
+	Detail         string  `json:"detail,omitempty"`
+}
+
+// orderState is what we remember per order ID to detect transitions between polls.
+type orderState struct {
+	status     string
+	qty        string
+	filledQty  string
+	limitPrice float64
+	stopPrice  float64
+	tif        string
+}
+
+// Trail appends Events to dir/order_audit_YYYY-MM-DD.jsonl (ET trading date) and prunes files older than
+// the retention period at startup and on each day rollover. Safe for concurrent use.
+type Trail struct {
+	dir           string
+	retentionDays int
+
+	mu       sync.Mutex
+	file     *os.File
+	fileDate string
+	seen     map[string]orderState
+}
+
+var eastern = func() *time.Location {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		return time.FixedZone("ET", -5*3600)
+	}
+	return loc
+}()
+
+// NewTrail creates dir if needed and prunes expired files. retentionDays <= 0 keeps files forever.
+func NewTrail(dir string, retentionDays int) (*Trail, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("compliance dir %s: %w", dir, err)
+	}
+	t := &Trail{
+		dir:           dir,
+		retentionDays: retentionDays,
+		seen:          make(map[string]orderState),
+	}
+	t.Prune(time.Now())
+	return t, nil
+}
+
+// Record writes one event. Schema and RecordedAt are filled in if empty.
+func (t *Trail) Record(ev Event) error {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.writeLocked(ev, time.Now())
+}
+
+func (t *Trail) writeLocked(ev Event, now time.Time) error {
+	ev.Schema = SchemaVersion
+	if ev.RecordedAt == "" {
+		ev.RecordedAt = now.UTC().Format(time.RFC3339Nano)
+	}
+	date := now.In(eastern).Format("2006-01-02")
+	if t.file == nil || t.fileDate != date {
+		if t.file != nil {
+			_ = t.file.Close()
+		}
+		f, err := os.OpenFile(filepath.Join(t.dir, "order_audit_"+date+".jsonl"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o640)
+		if err != nil {
+			t.file = nil
+			return err
+		}
+		t.file, t.fileDate = f, date
+		t.Prune(now)
+	}
+	line, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	_, err = t.file.Write(append(line, '\n'))
+	return err
+}
+
+// ObserveOrders diffs a broker order listing (e.g. GET /v2/orders?status=all) against the previous one and
+// records new orders, modifications, fills, cancels, and other status changes. State is in memory only, so
+// after an engine restart the day's orders are recorded again as order_new (dedupe on order_id + event_type).
+func (t *Trail) ObserveOrders(orders []alpaca.Order) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	for _, o := range orders {
+		cur := orderState{
+			status:     o.Status,
+			qty:        o.Qty,
+			filledQty:  o.FilledQty,
+			limitPrice: o.LimitPrice.Value(),
+			stopPrice:  o.StopPrice.Value(),
+			tif:        o.TimeInForce,
+		}
+		prev, known := t.seen[o.ID]
+		t.seen[o.ID] = cur
+		var events []Event
+		switch {
+		case !known:
+			events = append(events, orderEvent(o, EventNew, o.SubmittedAt))
+			if o.Replaces != "" {
+				ev := orderEvent(o, EventModified, o.SubmittedAt)
+				ev.ParentOrderID = o.Replaces
+				ev.Detail = "replaces " + o.Replaces
+				events = append(events, ev)
+			}
+			// First sighting of an already-terminal order still needs its execution/cancel on record.
+			if term := terminalEvent(o); term != "" {
+				events = append(events, orderEvent(o, term, transitionTime(o)))
+			}
+		default:
+			if prev.qty != cur.qty || prev.limitPrice != cur.limitPrice || prev.stopPrice != cur.stopPrice || prev.tif != cur.tif {
+				ev := orderEvent(o, EventModified, o.UpdatedAt)
+				ev.Detail = modificationDetail(prev, cur)
+				events = append(events, ev)
+			}
+			if prev.filledQty != cur.filledQty && cur.status != "filled" {
+				events = append(events, orderEvent(o, EventPartialFill, o.UpdatedAt))
+			}
+			if prev.status != cur.status {
+				typ := terminalEvent(o)
+				if typ == "" {
+					typ = EventStatus
+				}
+				ev := orderEvent(o, typ, transitionTime(o))
+				ev.PrevStatus = prev.status
+				events = append(events, ev)
+			}
+		}
+		for _, ev := range events {
+			if err := t.writeLocked(ev, now); err != nil {
+				slog.Error("compliance trail write failed", "order_id", o.ID, "err", err)
+			}
+		}
+	}
+}
+
+// Prune deletes trail files older than the retention period.
+func (t *Trail) Prune(now time.Time) {
+	if t == nil || t.retentionDays <= 0 {
+		return
+	}
+	cutoff := now.In(eastern).AddDate(0, 0, -t.retentionDays).Format("2006-01-02")
+	entries, err := os.ReadDir(t.dir)
+	if err != nil {
+		slog.Warn("compliance prune: read dir", "dir", t.dir, "err", err)
+		return
+	}
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		name := e.Name()
+		if strings.HasPrefix(name, "order_audit_") && strings.HasSuffix(name, ".jsonl") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		date := strings.TrimSuffix(strings.TrimPrefix(name, "order_audit_"), ".jsonl")
+		if date >= cutoff {
+			break
+		}
+		if err := os.Remove(filepath.Join(t.dir, name)); err != nil {
+			slog.Warn("compliance prune: remove", "file", name, "err", err)
+			continue
+		}
+		slog.Info("compliance trail pruned", "file", name, "retention_days", t.retentionDays)
+	}
+}
+
+// Close flushes and closes the current file.
+func (t *Trail) Close() error {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.file == nil {
+		return nil
+	}
+	err := t.file.Close()
+	t.file = nil
+	return err
+}
+
+func orderEvent(o alpaca.Order, typ, eventTime string) Event {
+	return Event{
+		EventTime:      eventTime,
+		EventType:      typ,
+		OrderID:        o.ID,
+		ClientOrderID:  o.ClientOrderID,
+		ParentOrderID:  o.Replaces,
+		Symbol:         o.Symbol,
+		Side:           o.Side,
+		OrderType:      o.Type,
+		TimeInForce:    o.TimeInForce,
+		Qty:            o.Qty,
+		FilledQty:      o.FilledQty,
+		FilledAvgPrice: o.FilledAvgPrice.Value(),
+		LimitPrice:     o.LimitPrice.Value(),
+		StopPrice:      o.StopPrice.Value(),
+		ExtendedHours:  o.ExtendedHours,
+		Status:         o.Status,
+		Source:         "broker",
+	}
+}
+
+// IntentEvent is the order_intent (or, with typ EventRejected, order_rejected) record for an order
+// request from the brain or the command stream; detail says why it was rejected.
+func IntentEvent(req alpaca.OrderRequest, typ, detail string) Event {
+	return Event{
+		EventTime:     time.Now().UTC().Format(time.RFC3339Nano),
+		EventType:     typ,
+		ClientOrderID: req.ClientOrderID,
+		Symbol:        req.Symbol,
+		Side:          req.Side,
+		OrderType:     req.Type,
+		TimeInForce:   req.TimeInForce,
+		Qty:           req.Qty,
+		LimitPrice:    req.LimitPrice,
+		StopPrice:     req.StopPrice,
+		ExtendedHours: req.ExtendedHours,
+		Source:        req.Source,
+		CorrelationID: req.CorrelationID,
+		Detail:        detail,
+	}
+}
+
+// terminalEvent maps a final order status to its event type ("" if the order is still working).
+func terminalEvent(o alpaca.Order) string {
+	switch o.Status {
+	case "filled":
+		return EventFill
+	case "canceled", "expired", "rejected", "done_for_day":
+		return EventCanceled
+	}
+	return ""
+}
+
+// transitionTime picks the most specific broker timestamp for the order's current status.
+func transitionTime(o alpaca.Order) string {
+	switch {
+	case o.Status == "filled" && o.FilledAt != "":
+		return o.FilledAt
+	case o.Status == "canceled" && o.CanceledAt != "":
+		return o.CanceledAt
+	case o.UpdatedAt != "":
+		return o.UpdatedAt
+	}
+	return o.SubmittedAt
+}
+
+func modificationDetail(prev, cur orderState) string {
+	var parts []string
+	if prev.qty != cur.qty {
+		parts = append(parts, fmt.Sprintf("qty %s->%s", prev.qty, cur.qty))
+	}
+	if prev.limitPrice != cur.limitPrice {
+		parts = append(parts, fmt.Sprintf("limit %g->%g", prev.limitPrice, cur.limitPrice))
+	}
+	if prev.stopPrice != cur.stopPrice {
+		parts = append(parts, fmt.Sprintf("stop %g->%g", prev.stopPrice, cur.stopPrice))
+	}
+	if prev.tif != cur.tif {
+		parts = append(parts, fmt.Sprintf("tif %s->%s", prev.tif, cur.tif))
+	}
+	return strings.Join(parts, "; ")
+}
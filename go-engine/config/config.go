@@ -1,5 +1,5 @@
 // Package config loads all engine settings from environment variables (.env or shell).
-// Required: APCA_API_KEY_ID, APCA_API_SECRET_KEY, ACTIVE_SYMBOLS_FILE (scanner runs at startup and 7:00 ET with discovery on market days).
+// Required: APCA_API_KEY_ID, APCA_API_SECRET_KEY, ACTIVE_SYMBOLS_FILE or WATCHLIST_NAME (scanner runs at startup and 7:00 ET with discovery on market days).
 // Optional: data URLs, BRAIN_CMD, STREAM.
 package config
 
@@ -23,7 +23,8 @@ func Load() (*Config, error) {
 	if streamWSURL == "" {
 		streamWSURL = dataURLToStreamWS(baseURL)
 	}
-	tickers := loadTickers()
+	symbolsFile := symbolsFilePath()
+	tickers := ReadSymbolsFile(symbolsFile)
 	stream := strings.ToLower(os.Getenv("STREAM")) != "false" && strings.ToLower(os.Getenv("STREAM")) != "0"
 	// Default SIP (full US consolidated). Set ALPACA_DATA_FEED=iex for IEX-only (free tier).
 	// Alpaca Pro/Algo Trader Plus: SIP, higher rate limits, no 15-min delay. OFI computed locally from trades/quotes.
@@ -31,6 +32,26 @@ func Load() (*Config, error) {
 	if dataFeed != "iex" && dataFeed != "sip" {
 		dataFeed = "sip"
 	}
+	// Market data from Alpaca (default) or Polygon.io (POLYGON_API_KEY); trading is always Alpaca.
+	dataProvider := strings.ToLower(strings.TrimSpace(os.Getenv("DATA_PROVIDER")))
+	if dataProvider != "polygon" {
+		dataProvider = "alpaca"
+	}
+	// Backup source for bars and snapshots when the provider's REST calls fail: off (default), yahoo or finnhub
+	dataBackup := strings.ToLower(strings.TrimSpace(os.Getenv("DATA_BACKUP")))
+	if dataBackup != "yahoo" && dataBackup != "finnhub" {
+		dataBackup = ""
+	}
+	// Daily and intraday bars from Alpaca: split-adjusted by default so splits don't read as volatility
+	barsAdjustment := strings.ToLower(strings.TrimSpace(envOrDefault("BARS_ADJUSTMENT", "split")))
+	if barsAdjustment != "raw" && barsAdjustment != "dividend" && barsAdjustment != "all" {
+		barsAdjustment = "split"
+	}
+	// Orders and account at Alpaca (default) or Interactive Brokers via the Client Portal Gateway.
+	brokerName := strings.ToLower(strings.TrimSpace(os.Getenv("BROKER")))
+	if brokerName != "ibkr" {
+		brokerName = "alpaca"
+	}
 	tradingBaseURL := os.Getenv("APCA_API_BASE_URL")
 	if tradingBaseURL == "" {
 		tradingBaseURL = "https://paper-api.alpaca.markets"
@@ -38,6 +59,55 @@ func Load() (*Config, error) {
 	// Brain closest to data: Go pipes events to this process via stdin (NDJSON).
 	// e.g. "python3 python-brain/consumer.py" when run from project root.
 	brainCmd := os.Getenv("BRAIN_CMD")
+	// Several brains: BRAIN_CMD_1..N (stops at the first gap) replace BRAIN_CMD; symbols are sharded by
+	// hash unless pinned with BRAIN_ROUTES="AAPL=1,MSFT=2" (1-based brain numbers).
+	var brainCmds []string
+	for i := 1; ; i++ {
+		c := strings.TrimSpace(os.Getenv("BRAIN_CMD_" + strconv.Itoa(i)))
+		if c == "" {
+			break
+		}
+		brainCmds = append(brainCmds, c)
+	}
+	if len(brainCmds) == 0 && strings.TrimSpace(brainCmd) != "" {
+		brainCmds = []string{brainCmd}
+	}
+	// BRAIN_TRANSPORT=grpc: serve the brain over gRPC instead of starting BRAIN_CMD, so it can run in its
+	// own container. One brain per address in BRAIN_GRPC_ADDR (comma-separated, default "127.0.0.1:50051").
+	brainTransport := strings.ToLower(strings.TrimSpace(envOrDefault("BRAIN_TRANSPORT", "pipe")))
+	if brainTransport != "grpc" {
+		brainTransport = "pipe"
+	}
+	var brainGRPCAddrs []string
+	for _, a := range strings.Split(envOrDefault("BRAIN_GRPC_ADDR", "127.0.0.1:50051"), ",") {
+		if a = strings.TrimSpace(a); a != "" {
+			brainGRPCAddrs = append(brainGRPCAddrs, a)
+		}
+	}
+	brainInstances := len(brainCmds)
+	if brainTransport == "grpc" {
+		brainInstances = len(brainGRPCAddrs)
+	}
+	brainRoutes := make(map[string]int)
+	for _, kv := range strings.Split(os.Getenv("BRAIN_ROUTES"), ",") {
+		sym, num, ok := strings.Cut(strings.TrimSpace(kv), "=")
+		if !ok {
+			continue
+		}
+		if n, err := strconv.Atoi(strings.TrimSpace(num)); err == nil && n >= 1 && n <= brainInstances {
+			brainRoutes[strings.ToUpper(strings.TrimSpace(sym))] = n - 1
+		}
+	}
+	// Brain stdin encoding: "json" (default), or length-prefixed "msgpack" / "protobuf" frames at high tick rates.
+	brainEncoding := strings.ToLower(strings.TrimSpace(envOrDefault("BRAIN_ENCODING", "json")))
+	if brainEncoding != "msgpack" && brainEncoding != "protobuf" {
+		brainEncoding = "json"
+	}
+	// Stale ticks (STALE_TICK_MS): "drop" (default) keeps them from the brain and sinks, "flag" sends them marked stale
+	staleTickAction := strings.ToLower(strings.TrimSpace(envOrDefault("STALE_TICK_ACTION", "drop")))
+	if staleTickAction != "flag" {
+		staleTickAction = "drop"
+	}
 	positionsIntervalSec := envIntOrDefault("POSITIONS_INTERVAL_SEC", 15)
 	if positionsIntervalSec < 5 {
 		positionsIntervalSec = 5
@@ -45,18 +115,419 @@ func Load() (*Config, error) {
 	if positionsIntervalSec > 300 {
 		positionsIntervalSec = 300
 	}
+	accountIntervalSec := envIntOrDefault("ACCOUNT_INTERVAL_SEC", 60)
+	if accountIntervalSec > 0 && accountIntervalSec < 5 {
+		accountIntervalSec = 5
+	}
+	// Volatility estimator: "close" (close-to-close, default) or "ewma" (RiskMetrics, VOL_EWMA_LAMBDA default 0.94).
+	volMethod := strings.ToLower(strings.TrimSpace(os.Getenv("VOL_METHOD")))
+	if volMethod != "ewma" {
+		volMethod = "close"
+	}
+	volEWMALambda := envFloatOrDefault("VOL_EWMA_LAMBDA", 0.94)
+	if volEWMALambda <= 0 || volEWMALambda >= 1 {
+		volEWMALambda = 0.94
+	}
+	// Volatility window (daily bars fetched and used) and minimum usable bars before a number is published.
+	volWindow := envIntOrDefault("VOL_WINDOW", 30)
+	if volWindow < 3 || volWindow > 1000 {
+		volWindow = 30
+	}
+	volMinBars := envIntOrDefault("VOL_MIN_BARS", 10)
+	if volMinBars < 3 {
+		volMinBars = 3
+	}
+	if volMinBars > volWindow {
+		volMinBars = volWindow
+	}
+	// Beta benchmark (daily returns regression, refreshed with volatility); "none" disables.
+	betaBenchmark := strings.ToUpper(strings.TrimSpace(envOrDefault("BETA_BENCHMARK", "SPY")))
+	if betaBenchmark == "NONE" {
+		betaBenchmark = ""
+	}
+	// Correlation events: pairwise return correlations across tickers, refreshed every CORRELATION_INTERVAL_MIN (0 = off).
+	correlationTimeframe := envOrDefault("CORRELATION_TIMEFRAME", "1Day")
+	correlationWindow := envIntOrDefault("CORRELATION_WINDOW", 30)
+	if correlationWindow < 5 || correlationWindow > 1000 {
+		correlationWindow = 30
+	}
+	correlationIntervalMin := envIntOrDefault("CORRELATION_INTERVAL_MIN", 15)
+	if correlationIntervalMin < 0 {
+		correlationIntervalMin = 0
+	}
+	// REST bar pollers: comma-separated timeframes (e.g. "1Min,5Min,1Hour"); empty = off.
+	var barTimeframes []string
+	for _, tf := range strings.Split(os.Getenv("BARS_TIMEFRAMES"), ",") {
+		if tf = strings.TrimSpace(tf); tf != "" {
+			barTimeframes = append(barTimeframes, tf)
+		}
+	}
+	barsLookback := envIntOrDefault("BARS_LOOKBACK", 50)
+	if barsLookback < 1 || barsLookback > 1000 {
+		barsLookback = 50
+	}
+	// Go fallback brain: "off" (default), "auto" (trade only while the Python brain is down/not configured), "on".
+	fallbackBrain := strings.ToLower(strings.TrimSpace(envOrDefault("FALLBACK_BRAIN", "off")))
+	if fallbackBrain != "auto" && fallbackBrain != "on" {
+		fallbackBrain = "off"
+	}
+	// Fallback positions once the Python brain is back: "flatten" (default) or "handoff".
+	fallbackRecovery := strings.ToLower(strings.TrimSpace(envOrDefault("FALLBACK_ON_RECOVERY", "flatten")))
+	if fallbackRecovery != "handoff" {
+		fallbackRecovery = "flatten"
+	}
+	// Order hours guard for engine-placed orders: "convert" (default), "block", or "off".
+	orderHoursGuard := strings.ToLower(strings.TrimSpace(envOrDefault("ORDER_HOURS_GUARD", "convert")))
+	if orderHoursGuard != "block" && orderHoursGuard != "off" {
+		orderHoursGuard = "convert"
+	}
+	// Order chase for resting limit orders: "off" (default), "cancel", "reprice", or "market".
+	orderChase := strings.ToLower(strings.TrimSpace(envOrDefault("ORDER_CHASE", "off")))
+	if orderChase != "cancel" && orderChase != "reprice" && orderChase != "market" {
+		orderChase = "off"
+	}
+	// Re-entry policy for engine-placed orders; per-symbol overrides as
+	// REENTRY_SYMBOLS="TSLA:exit=30,stop=60,max=1;AAPL:max=3" (minutes / re-entries per day; unset keys use the globals).
+	reentryDefault := ReentryRule{
+		ExitCooldownMin: envIntOrDefault("REENTRY_EXIT_COOLDOWN_MIN", 0),
+		StopCooldownMin: envIntOrDefault("REENTRY_STOP_COOLDOWN_MIN", 0),
+		MaxPerDay:       envIntOrDefault("REENTRY_MAX_PER_DAY", 0),
+	}
+	reentrySymbols := make(map[string]ReentryRule)
+	for _, entry := range strings.Split(os.Getenv("REENTRY_SYMBOLS"), ";") {
+		sym, rules, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		sym = strings.ToUpper(strings.TrimSpace(sym))
+		if !ok || sym == "" {
+			continue
+		}
+		rule := reentryDefault
+		for _, kv := range strings.Split(rules, ",") {
+			k, v, ok := strings.Cut(strings.TrimSpace(kv), "=")
+			n, err := strconv.Atoi(strings.TrimSpace(v))
+			if !ok || err != nil || n < 0 {
+				continue
+			}
+			switch strings.ToLower(strings.TrimSpace(k)) {
+			case "exit":
+				rule.ExitCooldownMin = n
+			case "stop":
+				rule.StopCooldownMin = n
+			case "max":
+				rule.MaxPerDay = n
+			}
+		}
+		reentrySymbols[sym] = rule
+	}
+	// Compliance order audit trail: separate directory from app logs; default retention 6 years (FINRA 17a-4).
+	complianceRetentionDays := envIntOrDefault("COMPLIANCE_RETENTION_DAYS", 2190)
+	// SINKS limits the event outputs, e.g. "brain,kafka"; unset enables every sink that is configured.
+	var sinks []string
+	for _, n := range strings.Split(os.Getenv("SINKS"), ",") {
+		if n = strings.ToLower(strings.TrimSpace(n)); n != "" {
+			sinks = append(sinks, n)
+		}
+	}
+	// Kafka sink: the full event firehose (JSON envelopes keyed by symbol) for the analytics stack.
+	var kafkaBrokers, kafkaDRBrokers []string
+	for _, b := range strings.Split(os.Getenv("KAFKA_BROKERS"), ",") {
+		if b = strings.TrimSpace(b); b != "" {
+			kafkaBrokers = append(kafkaBrokers, b)
+		}
+	}
+	// Disaster-recovery replicas: a second Redis and/or Kafka target (e.g. in another region) that gets its
+	// own copy of the stream with an independent queue.
+	for _, b := range strings.Split(os.Getenv("KAFKA_DR_BROKERS"), ",") {
+		if b = strings.TrimSpace(b); b != "" {
+			kafkaDRBrokers = append(kafkaDRBrokers, b)
+		}
+	}
+	redisStream := envOrDefault("REDIS_STREAM", "market:updates")
+	redisSnapshotPrefix := strings.TrimSpace(envOrDefault("REDIS_SNAPSHOT_PREFIX", "snapshot:"))
+	if strings.EqualFold(redisSnapshotPrefix, "off") {
+		redisSnapshotPrefix = ""
+	}
+	kafkaTopic := envOrDefault("KAFKA_TOPIC", "sentry-events")
+	// Event TTL: hot event types that are dropped instead of delivered late after a stall
+	var eventTTLTypes []string
+	for _, t := range strings.Split(envOrDefault("EVENT_TTL_TYPES", "trade,quote"), ",") {
+		if t = strings.ToLower(strings.TrimSpace(t)); t != "" {
+			eventTTLTypes = append(eventTTLTypes, t)
+		}
+	}
+	// IBKR precautionary warnings to confirm (IBKR_CONFIRM_MESSAGES is comma-separated message IDs)
+	var ibkrConfirm []string
+	for _, id := range strings.Split(os.Getenv("IBKR_CONFIRM_MESSAGES"), ",") {
+		if id = strings.ToLower(strings.TrimSpace(id)); id != "" {
+			ibkrConfirm = append(ibkrConfirm, id)
+		}
+	}
+	// Model signals (INFERENCE_SIGNALS); unset, they are on when INFERENCE_THRESHOLD is set, as before the flag
+	inferenceSignals := envBool("INFERENCE_SIGNALS")
+	if strings.TrimSpace(os.Getenv("INFERENCE_SIGNALS")) == "" {
+		inferenceSignals = strings.TrimSpace(os.Getenv("INFERENCE_THRESHOLD")) != ""
+	}
+	// Pre-trade risk limits (RISK_BANNED_SYMBOLS is comma-separated)
+	var riskBanned []string
+	for _, s := range strings.Split(os.Getenv("RISK_BANNED_SYMBOLS"), ",") {
+		if s = strings.ToUpper(strings.TrimSpace(s)); s != "" {
+			riskBanned = append(riskBanned, s)
+		}
+	}
+	// IEX vs SIP comparison sample (FEED_COMPARE_SYMBOLS is comma-separated)
+	var feedCompareSymbols []string
+	for _, s := range strings.Split(os.Getenv("FEED_COMPARE_SYMBOLS"), ",") {
+		if s = strings.ToUpper(strings.TrimSpace(s)); s != "" {
+			feedCompareSymbols = append(feedCompareSymbols, s)
+		}
+	}
+	// Options IV context (OPTIONS_UNDERLYINGS is comma-separated); feed "indicative" (free) or "opra"
+	var optionsUnderlyings []string
+	for _, s := range strings.Split(os.Getenv("OPTIONS_UNDERLYINGS"), ",") {
+		if s = strings.ToUpper(strings.TrimSpace(s)); s != "" {
+			optionsUnderlyings = append(optionsUnderlyings, s)
+		}
+	}
+	optionsFeed := strings.ToLower(strings.TrimSpace(envOrDefault("OPTIONS_FEED", "indicative")))
+	if optionsFeed != "opra" {
+		optionsFeed = "indicative"
+	}
+	// Tick-level P&L for risk dashboards, on its own stream (needs REDIS_URL) and optionally its own topic.
+	pnlStream := strings.TrimSpace(envOrDefault("PNL_STREAM", "pnl:updates"))
+	if strings.EqualFold(pnlStream, "off") {
+		pnlStream = ""
+	}
+	var digestTo []string
+	for _, addr := range strings.Split(os.Getenv("DIGEST_TO"), ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			digestTo = append(digestTo, addr)
+		}
+	}
+	var wsOrigins []string
+	for _, o := range strings.Split(os.Getenv("WS_ALLOWED_ORIGINS"), ",") {
+		if o = strings.TrimSpace(o); o != "" {
+			wsOrigins = append(wsOrigins, o)
+		}
+	}
 	return &Config{
-		APIKeyID:             os.Getenv("APCA_API_KEY_ID"),
-		APISecretKey:        os.Getenv("APCA_API_SECRET_KEY"),
-		DataBaseURL:         baseURL,
-		StreamWSURL:         streamWSURL,
-		TradingBaseURL:      tradingBaseURL,
-		Tickers:            tickers,
-		StreamingMode:      stream,
-		DataFeed:           dataFeed,
-		BrainCmd:           brainCmd,
-		PositionsIntervalSec: positionsIntervalSec,
-		MarketCloseET:        envOrDefault("MARKET_CLOSE_ET", "16:00"),
+		APIKeyID:                os.Getenv("APCA_API_KEY_ID"),
+		APISecretKey:            os.Getenv("APCA_API_SECRET_KEY"),
+		DataBaseURL:             baseURL,
+		StreamWSURL:             streamWSURL,
+		TradingBaseURL:          tradingBaseURL,
+		Broker:                  brokerName,
+		IBKRBaseURL:             envOrDefault("IBKR_BASE_URL", "https://localhost:5000/v1/api"),
+		IBKRAccountID:           strings.TrimSpace(os.Getenv("IBKR_ACCOUNT_ID")),
+		IBKRVerifyTLS:           envBool("IBKR_VERIFY_TLS"),
+		IBKRPollSec:             envIntOrDefault("IBKR_POLL_SEC", 2),
+		IBKRConfirmMessages:     ibkrConfirm,
+		Tickers:                 tickers,
+		SymbolsFile:             symbolsFile,
+		WatchlistName:           strings.TrimSpace(os.Getenv("WATCHLIST_NAME")),
+		WatchlistPush:           envBool("WATCHLIST_PUSH"),
+		WatchlistSyncSec:        envIntOrDefault("WATCHLIST_SYNC_SEC", 60),
+		StreamingMode:           stream,
+		DataFeed:                dataFeed,
+		DataProvider:            dataProvider,
+		PolygonAPIKey:           os.Getenv("POLYGON_API_KEY"),
+		PolygonBaseURL:          envOrDefault("POLYGON_BASE_URL", "https://api.polygon.io"),
+		PolygonWSURL:            envOrDefault("POLYGON_WS_URL", "wss://socket.polygon.io"),
+		PolygonNewsPollSec:      envIntOrDefault("POLYGON_NEWS_POLL_SEC", 30),
+		DataBackup:              dataBackup,
+		RESTMaxRetries:          envIntOrDefault("REST_MAX_RETRIES", 3),
+		RESTRetryBaseMs:         envIntOrDefault("REST_RETRY_BASE_MS", 500),
+		RESTRetryMaxMs:          envIntOrDefault("REST_RETRY_MAX_MS", 30000),
+		RESTRateLimitPerMin:     envIntOrDefault("REST_RATE_LIMIT_PER_MIN", 180),
+		BarsAdjustment:          barsAdjustment,
+		CorpActions:             os.Getenv("CORPORATE_ACTIONS") != "false",
+		CorpActionsAheadDays:    envIntOrDefault("CORPORATE_ACTIONS_AHEAD_DAYS", 30),
+		CorpActionsBackDays:     envIntOrDefault("CORPORATE_ACTIONS_BACK_DAYS", 7),
+		AssetPreflight:          os.Getenv("ASSET_PREFLIGHT") != "false",
+		ShortCheck:              os.Getenv("SHORT_CHECK") != "false",
+		ShortRequireETB:         os.Getenv("SHORT_REQUIRE_ETB") != "false",
+		FinnhubAPIKey:           os.Getenv("FINNHUB_API_KEY"),
+		BrainCmd:                brainCmd,
+		BrainCmds:               brainCmds,
+		BrainRoutes:             brainRoutes,
+		BrainTransport:          brainTransport,
+		BrainGRPCAddrs:          brainGRPCAddrs,
+		BrainGRPCToken:          os.Getenv("BRAIN_GRPC_TOKEN"),
+		BrainGRPCTLSCert:        strings.TrimSpace(os.Getenv("BRAIN_GRPC_TLS_CERT")),
+		BrainGRPCTLSKey:         strings.TrimSpace(os.Getenv("BRAIN_GRPC_TLS_KEY")),
+		PositionsIntervalSec:    positionsIntervalSec,
+		PositionsSnapshot:       os.Getenv("POSITIONS_SNAPSHOT") != "false",
+		PositionDeltas:          os.Getenv("POSITION_DELTAS") != "false",
+		AccountIntervalSec:      accountIntervalSec,
+		DailyPnLIntervalSec:     envIntOrDefault("DAILY_PNL_INTERVAL_SEC", 60),
+		DailyPnLTimeframe:       envOrDefault("DAILY_PNL_TIMEFRAME", "5Min"),
+		FeedCompareSymbols:      feedCompareSymbols,
+		FeedCompareIntervalSec:  envIntOrDefault("FEED_COMPARE_INTERVAL_SEC", 60),
+		MarketCloseET:           envOrDefault("MARKET_CLOSE_ET", "16:00"),
+		AutoSchedule:            envBool("AUTO_SCHEDULE"),
+		AutoScheduleLeadMin:     envIntOrDefault("AUTO_SCHEDULE_LEAD_MIN", 15),
+		AutoScheduleGraceMin:    envIntOrDefault("AUTO_SCHEDULE_GRACE_MIN", 5),
+		IdleEvictAt:             strings.TrimSpace(os.Getenv("IDLE_EVICT_AT")),
+		GapScanAt:               strings.TrimSpace(os.Getenv("GAP_SCAN_AT")),
+		GapScanMinPct:           envFloatOrDefault("GAP_SCAN_MIN_PCT", 0.02),
+		BackfillStart:           strings.TrimSpace(os.Getenv("BACKFILL_START")),
+		BackfillEnd:             strings.TrimSpace(os.Getenv("BACKFILL_END")),
+		BackfillTimeframe:       envOrDefault("BACKFILL_TIMEFRAME", "1Min"),
+		BackfillAdjustment:      envOrDefault("BACKFILL_ADJUSTMENT", "split"),
+		BackfillOut:             envOrDefault("BACKFILL_OUT", "bars.ndjson"),
+		IdleEvictMinVolume:      int64(envIntOrDefault("IDLE_EVICT_MIN_VOLUME", 50000)),
+		UniverseExpand:          envBool("UNIVERSE_EXPAND"),
+		UniverseExpandMinPrice:  envFloatOrDefault("UNIVERSE_EXPAND_MIN_PRICE", 5),
+		UniverseExpandMinVolume: int64(envIntOrDefault("UNIVERSE_EXPAND_MIN_VOLUME", 500000)),
+		UniverseExpandTrialMin:  envIntOrDefault("UNIVERSE_EXPAND_TRIAL_MIN", 60),
+		UniverseExpandMax:       envIntOrDefault("UNIVERSE_EXPAND_MAX", 10),
+		UniverseScan:            envBool("UNIVERSE_SCAN"),
+		UniverseScanMin:         envIntOrDefault("UNIVERSE_SCAN_INTERVAL_MIN", 15),
+		UniverseScanTop:         envIntOrDefault("UNIVERSE_SCAN_TOP", 20),
+		UniverseScanMax:         envIntOrDefault("UNIVERSE_SCAN_MAX", 10),
+		UniverseScanMinPrice:    envFloatOrDefault("UNIVERSE_SCAN_MIN_PRICE", 5),
+		UniverseScanMinVolume:   int64(envIntOrDefault("UNIVERSE_SCAN_MIN_VOLUME", 1000000)),
+		VolMethod:               volMethod,
+		VolEWMALambda:           volEWMALambda,
+		VolWindow:               volWindow,
+		VolMinBars:              volMinBars,
+		BetaBenchmark:           betaBenchmark,
+		CorrelationTimeframe:    correlationTimeframe,
+		CorrelationWindow:       correlationWindow,
+		CorrelationIntervalMin:  correlationIntervalMin,
+		BarTimeframes:           barTimeframes,
+		BarsLookback:            barsLookback,
+		FeatureVectors:          envBool("FEATURE_VECTORS"),
+		BrainQueueSize:          envIntOrDefault("BRAIN_QUEUE_SIZE", 10000),
+		BrainReadyTimeoutSec:    envIntOrDefault("BRAIN_READY_TIMEOUT_SEC", 30),
+		BrainEncoding:           brainEncoding,
+		BrainBufferMaxAgeSec:    envIntOrDefault("BRAIN_BUFFER_MAX_AGE_SEC", 30),
+		BrainBufferMemory:       envIntOrDefault("BRAIN_BUFFER_MEMORY", 10000),
+		BrainSpillDir:           strings.TrimSpace(os.Getenv("BRAIN_SPILL_DIR")),
+		BrainSpillMaxMB:         envIntOrDefault("BRAIN_SPILL_MAX_MB", 256),
+		BrainInjectLatencyMs:    envIntOrDefault("BRAIN_INJECT_LATENCY_MS", 0),
+		BrainInjectJitterMs:     envIntOrDefault("BRAIN_INJECT_JITTER_MS", 0),
+		InferenceModel:          strings.TrimSpace(os.Getenv("INFERENCE_MODEL")),
+		InferenceSignals:        inferenceSignals,
+		InferenceThreshold:      envFloatOrDefault("INFERENCE_THRESHOLD", 0),
+		InferenceInputName:      envOrDefault("INFERENCE_INPUT_NAME", "input"),
+		InferenceOutputName:     envOrDefault("INFERENCE_OUTPUT_NAME", "output"),
+		ONNXRuntimeLib:          strings.TrimSpace(os.Getenv("ONNXRUNTIME_LIB")),
+		OrderHoursGuard:         orderHoursGuard,
+		TradeUpdates:            strings.ToLower(strings.TrimSpace(envOrDefault("TRADE_UPDATES", "true"))) != "false",
+		PositionReconcile:       os.Getenv("POSITION_RECONCILE") != "false",
+		PositionDriftGraceSec:   envIntOrDefault("POSITION_DRIFT_GRACE_SEC", 30),
+		OrderChase:              orderChase,
+		OrderChaseTimeoutSec:    envIntOrDefault("ORDER_CHASE_TIMEOUT_SEC", 30),
+		OrderChaseMaxReprices:   envIntOrDefault("ORDER_CHASE_MAX_REPRICES", 3),
+		FallbackBrain:           fallbackBrain,
+		FallbackDryRun:          envBool("FALLBACK_DRY_RUN"),
+		FallbackMaxNotional:     envFloatOrDefault("FALLBACK_MAX_NOTIONAL", 1000),
+		FallbackMaxPositions:    envIntOrDefault("FALLBACK_MAX_POSITIONS", 3),
+		FallbackMaxEntries:      envIntOrDefault("FALLBACK_MAX_ENTRIES_PER_DAY", 10),
+		FallbackEntryZ:          envFloatOrDefault("FALLBACK_ENTRY_Z", 2),
+		FallbackExitZ:           envFloatOrDefault("FALLBACK_EXIT_Z", 1),
+		FallbackStopLossPct:     envFloatOrDefault("FALLBACK_STOP_LOSS_PCT", 0.01),
+		FallbackTakeProfitPct:   envFloatOrDefault("FALLBACK_TAKE_PROFIT_PCT", 0.02),
+		FallbackCooldownMin:     envIntOrDefault("FALLBACK_COOLDOWN_MIN", 15),
+		FallbackMinVolume1m:     int64(envIntOrDefault("FALLBACK_MIN_VOLUME_1M", 1000)),
+		FallbackOnRecovery:      fallbackRecovery,
+		SizingRiskPerTrade:      envFloatOrDefault("SIZING_RISK_PER_TRADE", 0.005),
+		SizingStopVolMult:       envFloatOrDefault("SIZING_STOP_VOL_MULT", 1),
+		SizingMaxPositionPct:    envFloatOrDefault("SIZING_MAX_POSITION_PCT", 0.10),
+		SizingBuyingPowerPct:    envFloatOrDefault("SIZING_BUYING_POWER_PCT", 0.95),
+		SizingFractional:        envBool("SIZING_FRACTIONAL"),
+		CostTable:               strings.TrimSpace(os.Getenv("COST_TABLE")),
+		Reentry:                 reentryDefault,
+		ReentrySymbols:          reentrySymbols,
+		BudgetMaxPositions:      envIntOrDefault("BUDGET_MAX_POSITIONS", 0),
+		BudgetMaxEntriesPerHour: envIntOrDefault("BUDGET_MAX_ENTRIES_PER_HOUR", 0),
+		BudgetMaxEntriesPerDay:  envIntOrDefault("BUDGET_MAX_ENTRIES_PER_DAY", 0),
+		BudgetMaxCapitalPerHour: envFloatOrDefault("BUDGET_MAX_CAPITAL_PER_HOUR", 0),
+		RiskMaxOrderNotional:    envFloatOrDefault("RISK_MAX_ORDER_NOTIONAL", 0),
+		RiskMaxPositionNotional: envFloatOrDefault("RISK_MAX_POSITION_NOTIONAL", 0),
+		RiskMaxPositionShares:   envFloatOrDefault("RISK_MAX_POSITION_SHARES", 0),
+		RiskMaxOpenOrders:       envIntOrDefault("RISK_MAX_OPEN_ORDERS", 0),
+		RiskBannedSymbols:       riskBanned,
+		RiskPDTGuard:            envBool("RISK_PDT_GUARD"),
+		DailyLossLimit:          envFloatOrDefault("DAILY_LOSS_LIMIT", 0),
+		DailyLossFlatten:        envBool("DAILY_LOSS_FLATTEN"),
+		RiskReportIntervalSec:   envIntOrDefault("RISK_REPORT_INTERVAL_SEC", 60),
+		EngineStatsIntervalMin:  envIntOrDefault("ENGINE_STATS_INTERVAL_MIN", 60),
+		NewsBackfillHours:       envIntOrDefault("NEWS_BACKFILL_HOURS", 12),
+		NewsBackfillMax:         envIntOrDefault("NEWS_BACKFILL_MAX", 1000),
+		NewsDedupeSize:          envIntOrDefault("NEWS_DEDUPE_SIZE", 10000),
+		NewsSentiment:           os.Getenv("NEWS_SENTIMENT") != "false",
+		NewsEnrichURL:           strings.TrimSpace(os.Getenv("NEWS_ENRICH_URL")),
+		NewsEnrichTimeoutMs:     envIntOrDefault("NEWS_ENRICH_TIMEOUT_MS", 2000),
+		NewsEnrichMax:           envIntOrDefault("NEWS_ENRICH_CONCURRENCY", 4),
+		NewsPerSymbol:           os.Getenv("NEWS_PER_SYMBOL") != "false",
+		NewsAll:                 strings.TrimSpace(os.Getenv("NEWS_SYMBOLS")) == "*",
+		NewsOtherStream:         strings.TrimSpace(os.Getenv("NEWS_OTHER_STREAM")),
+		NewsClusterMin:          envIntOrDefault("NEWS_CLUSTER_WINDOW_MIN", 60),
+		NewsClusterSim:          envFloatOrDefault("NEWS_CLUSTER_SIMILARITY", 0.5),
+		GapRecoverySec:          envIntOrDefault("GAP_RECOVERY_SEC", 30),
+		GapCheckSec:             envIntOrDefault("GAP_CHECK_SEC", 5),
+		StaleTickMs:             envIntOrDefault("STALE_TICK_MS", 0),
+		StaleTickAction:         staleTickAction,
+		TradeConditionFilter:    os.Getenv("TRADE_CONDITION_FILTER") != "false",
+		OptionsUnderlyings:      optionsUnderlyings,
+		OptionsFeed:             optionsFeed,
+		OptionsIntervalSec:      envIntOrDefault("OPTIONS_INTERVAL_SEC", 300),
+		OptionsMaxDays:          envIntOrDefault("OPTIONS_MAX_DAYS", 60),
+		OptionsStrikePct:        envFloatOrDefault("OPTIONS_STRIKE_PCT", 0.1),
+		OptionsStreamExpiries:   envIntOrDefault("OPTIONS_STREAM_EXPIRIES", 2),
+		Sinks:                   sinks,
+		RedisURL:                strings.TrimSpace(os.Getenv("REDIS_URL")),
+		RedisStream:             redisStream,
+		RedisDRURL:              strings.TrimSpace(os.Getenv("REDIS_DR_URL")),
+		RedisDRStream:           envOrDefault("REDIS_DR_STREAM", redisStream),
+		RedisStreamMaxLen:       int64(envIntOrDefault("REDIS_STREAM_MAXLEN", 1000000)),
+		RedisRetentionMin:       envIntOrDefault("REDIS_STREAM_RETENTION_MIN", 0),
+		RedisBatchSize:          envIntOrDefault("REDIS_BATCH_SIZE", 500),
+		RedisFlushMs:            envIntOrDefault("REDIS_FLUSH_MS", 5),
+		RedisOutbox:             envIntOrDefault("REDIS_OUTBOX", 100000),
+		RedisSnapshotPrefix:     redisSnapshotPrefix,
+		KafkaBrokers:            kafkaBrokers,
+		KafkaTopic:              kafkaTopic,
+		KafkaDRBrokers:          kafkaDRBrokers,
+		KafkaDRTopic:            envOrDefault("KAFKA_DR_TOPIC", kafkaTopic),
+		PnLStream:               pnlStream,
+		PnLKafkaTopic:           strings.TrimSpace(os.Getenv("PNL_KAFKA_TOPIC")),
+		SinkQueueSize:           envIntOrDefault("SINK_QUEUE_SIZE", 10000),
+		EventFile:               strings.TrimSpace(os.Getenv("EVENT_FILE")),
+		WSListenAddr:            strings.TrimSpace(os.Getenv("WS_LISTEN_ADDR")),
+		WSToken:                 os.Getenv("WS_TOKEN"),
+		WSOrigins:               wsOrigins,
+		EventFilters:            strings.TrimSpace(os.Getenv("EVENT_FILTERS")),
+		EventRoutes:             strings.TrimSpace(os.Getenv("EVENT_ROUTES")),
+		EventTTLMs:              envIntOrDefault("EVENT_TTL_MS", 0),
+		EventTTLTypes:           eventTTLTypes,
+		CommandsStream:          strings.TrimSpace(os.Getenv("COMMANDS_STREAM")),
+		WebhookListenAddr:       strings.TrimSpace(os.Getenv("WEBHOOK_LISTEN_ADDR")),
+		WebhookToken:            os.Getenv("WEBHOOK_TOKEN"),
+		ControlListenAddr:       strings.TrimSpace(os.Getenv("CONTROL_LISTEN_ADDR")),
+		ControlToken:            os.Getenv("CONTROL_TOKEN"),
+		DigestTo:                digestTo,
+		DigestAt:                envOrDefault("DIGEST_AT", "17:00"),
+		SMTPAddr:                strings.TrimSpace(os.Getenv("SMTP_ADDR")),
+		SMTPUser:                strings.TrimSpace(os.Getenv("SMTP_USER")),
+		SMTPPassword:            os.Getenv("SMTP_PASSWORD"),
+		DigestFrom:              envOrDefault("DIGEST_FROM", strings.TrimSpace(os.Getenv("SMTP_USER"))),
+		FlightRecorderSec:       envIntOrDefault("FLIGHT_RECORDER_SEC", 0),
+		FlightRecorderMax:       envIntOrDefault("FLIGHT_RECORDER_MAX_EVENTS", 200000),
+		FlightRecorderDir:       envOrDefault("FLIGHT_RECORDER_DIR", "flight-recorder"),
+		CommandsRedisURL:        strings.TrimSpace(envOrDefault("COMMANDS_REDIS_URL", os.Getenv("REDIS_URL"))),
+		KVPath:                  strings.TrimSpace(os.Getenv("KV_PATH")),
+		StateCheckpoint:         strings.TrimSpace(os.Getenv("STATE_CHECKPOINT")),
+		StateCheckpointKey:      envOrDefault("STATE_CHECKPOINT_KEY", "engine:state"),
+		StateCheckpointSec:      envIntOrDefault("STATE_CHECKPOINT_SEC", 15),
+		StateCheckpointAgeMin:   envIntOrDefault("STATE_CHECKPOINT_MAX_AGE_MIN", 10),
+		ComplianceAuditDir:      strings.TrimSpace(os.Getenv("COMPLIANCE_AUDIT_DIR")),
+		ComplianceRetentionDays: complianceRetentionDays,
+		AuditLogFile:            strings.TrimSpace(os.Getenv("AUDIT_LOG_FILE")),
+		AuditStream:             strings.TrimSpace(os.Getenv("AUDIT_STREAM")),
+		OTLPEndpoint:            strings.TrimSpace(os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")),
+		OTLPProtocol:            envOrDefault("OTEL_EXPORTER_OTLP_PROTOCOL", "grpc"),
+		OTLPHotSampleRatio:      envFloatOrDefault("TELEMETRY_HOT_SAMPLE_RATIO", 0.01),
 	}, nil
 }
 
@@ -67,6 +538,15 @@ func envOrDefault(key, def string) string {
 	return def
 }
 
+// envBool is true for "true", "1", or "yes" (case-insensitive).
+func envBool(key string) bool {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv(key))) {
+	case "true", "1", "yes":
+		return true
+	}
+	return false
+}
+
 func envIntOrDefault(key string, def int) int {
 	if v := os.Getenv(key); v != "" {
 		if n, err := strconv.Atoi(v); err == nil {
@@ -76,6 +556,15 @@ func envIntOrDefault(key string, def int) int {
 	return def
 }
 
+func envFloatOrDefault(key string, def float64) float64 {
+	if v := os.Getenv(key); v != "" {
+		if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+			return f
+		}
+	}
+	return def
+}
+
 // dataURLToStreamWS converts https://data.alpaca.markets -> wss://stream.data.alpaca.markets
 func dataURLToStreamWS(dataURL string) string {
 	if strings.HasPrefix(dataURL, "https://data.sandbox.alpaca.markets") {
@@ -84,18 +573,27 @@ func dataURLToStreamWS(dataURL string) string {
 	return "wss://stream.data.alpaca.markets"
 }
 
-// loadTickers returns symbols to stream. Only from ACTIVE_SYMBOLS_FILE (scanner output).
-// Scanner runs at container start and at 7:00 ET (discovery) on full market days.
-func loadTickers() []string {
+// symbolsFilePath returns ACTIVE_SYMBOLS_FILE, made absolute against the working directory ("" if unset).
+func symbolsFilePath() string {
 	filePath := os.Getenv("ACTIVE_SYMBOLS_FILE")
 	if filePath == "" {
-		return nil
+		return ""
 	}
 	if !filepath.IsAbs(filePath) {
 		if cwd, err := os.Getwd(); err == nil {
 			filePath = filepath.Join(cwd, filePath)
 		}
 	}
+	return filePath
+}
+
+// ReadSymbolsFile returns the symbols in a symbols file (scanner output): one per line, upper-cased,
+// blank lines and # comments skipped. nil if the file can't be read or lists none.
+// Scanner runs at container start and at 7:00 ET (discovery) on full market days.
+func ReadSymbolsFile(filePath string) []string {
+	if filePath == "" {
+		return nil
+	}
 	f, err := os.Open(filePath)
 	if err != nil {
 		return nil
@@ -117,15 +615,231 @@ func loadTickers() []string {
 
 // Config holds loaded env: Alpaca keys, data/trading/stream URLs, tickers, and brain command.
 type Config struct {
-	APIKeyID             string   // Alpaca API key (data + paper trading)
-	APISecretKey         string   // Alpaca secret
-	DataBaseURL          string   // e.g. https://data.alpaca.markets
-	StreamWSURL          string   // e.g. wss://stream.data.alpaca.markets
-	TradingBaseURL       string   // e.g. https://paper-api.alpaca.markets (positions, orders)
-	Tickers              []string // Symbols to stream and send to brain
-	StreamingMode        bool     // true = WebSocket streaming; false = one-shot REST
-	DataFeed             string   // "sip" (default) or "iex" — sip = full US consolidated tape
-	BrainCmd             string   // Command to start Python brain, e.g. python3 python-brain/consumer.py
-	PositionsIntervalSec int      // How often to fetch positions/orders (5–300s); default 15 (production-like)
-	MarketCloseET        string   // "16:00" = 4pm ET; engine exits at this time so entrypoint can sleep until 7am then discovery (set 13:00 for half-days)
+	APIKeyID                string                 // Alpaca API key (data + paper trading)
+	APISecretKey            string                 // Alpaca secret
+	DataBaseURL             string                 // e.g. https://data.alpaca.markets
+	StreamWSURL             string                 // e.g. wss://stream.data.alpaca.markets
+	TradingBaseURL          string                 // e.g. https://paper-api.alpaca.markets (positions, orders)
+	Broker                  string                 // Where orders go and positions/account come from: "alpaca" (default) or "ibkr"; market clock stays on Alpaca
+	IBKRBaseURL             string                 // Client Portal Gateway API, e.g. https://localhost:5000/v1/api (BROKER=ibkr)
+	IBKRAccountID           string                 // IBKR account, e.g. U1234567
+	IBKRVerifyTLS           bool                   // Verify the gateway's certificate (self-signed by default, so off)
+	IBKRPollSec             int                    // IBKR order status poll for trade updates; default 2
+	IBKRConfirmMessages     []string               // IBKR order warning message IDs confirmed automatically (e.g. o163); empty = none
+	Tickers                 []string               // Symbols to stream and send to brain
+	SymbolsFile             string                 // ACTIVE_SYMBOLS_FILE, absolute; Tickers are read from it
+	WatchlistName           string                 // Alpaca watchlist (WATCHLIST_NAME): Tickers come from it instead of the symbols file, unless WatchlistPush
+	WatchlistPush           bool                   // Push the symbols file to the watchlist at startup and whenever the file changes; the file stays the source
+	WatchlistSyncSec        int                    // How often the symbols file is checked for changes to push; default 60
+	StreamingMode           bool                   // true = WebSocket streaming; false = one-shot REST
+	DataFeed                string                 // "sip" (default) or "iex" — sip = full US consolidated tape
+	DataProvider            string                 // Market data source: "alpaca" (default) or "polygon"; orders and account stay on Alpaca
+	PolygonAPIKey           string                 // Polygon.io API key (DATA_PROVIDER=polygon)
+	PolygonBaseURL          string                 // e.g. https://api.polygon.io
+	PolygonWSURL            string                 // e.g. wss://socket.polygon.io (stocks stream at /stocks)
+	PolygonNewsPollSec      int                    // Polygon has no news stream: poll reference news this often; default 30
+	DataBackup              string                 // Bars and snapshots from "yahoo" or "finnhub" when the provider's calls fail; empty = off
+	FinnhubAPIKey           string                 // Finnhub API key (DATA_BACKUP=finnhub)
+	RESTMaxRetries          int                    // Alpaca REST retries on 429, 5xx and network errors (5xx/network: reads and cancels only); default 3, 0 = off
+	RESTRetryBaseMs         int                    // First retry backoff, doubled per retry; default 500
+	RESTRetryMaxMs          int                    // Cap on one backoff or Retry-After wait; default 30000
+	RESTRateLimitPerMin     int                    // Alpaca REST requests per minute, data and trading together (Alpaca allows 200); default 180, 0 = off
+	BarsAdjustment          string                 // Corporate action adjustment of Alpaca bars: "split" (default), "raw", "dividend" or "all"
+	CorpActions             bool                   // Send recent and upcoming splits and dividends as "corporate_action" events at startup; default true
+	CorpActionsAheadDays    int                    // Corporate actions with an ex date up to this many days ahead; default 30
+	CorpActionsBackDays     int                    // ...and this many days back; default 7
+	AssetPreflight          bool                   // Check the symbols' asset flags at startup, send "asset" events and drop non-tradable symbols; default true
+	ShortCheck              bool                   // Refuse short sales in assets Alpaca marks not shortable, with an "order_rejected" event; default true
+	ShortRequireETB         bool                   // With ShortCheck, also refuse shorts in assets that aren't easy to borrow (SHORT_REQUIRE_ETB); default true
+	BrainCmd                string                 // Command to start Python brain, e.g. python3 python-brain/consumer.py
+	BrainCmds               []string               // Brain instances to run: BRAIN_CMD_1..N, else [BrainCmd]
+	BrainRoutes             map[string]int         // Symbol -> brain index (BRAIN_ROUTES); other symbols sharded by hash
+	BrainTransport          string                 // "pipe" (child process, default) or "grpc" (brain connects to BrainGRPCAddrs)
+	BrainGRPCAddrs          []string               // gRPC listen addresses, one brain each (BRAIN_GRPC_ADDR); index is the route number
+	BrainGRPCToken          string                 // Bearer token a gRPC brain must send; required unless every address is loopback
+	BrainGRPCTLSCert        string                 // Serve the gRPC brain over TLS with this certificate (PEM file); empty = plaintext
+	BrainGRPCTLSKey         string                 // Private key for BrainGRPCTLSCert
+	PositionsIntervalSec    int                    // How often to fetch positions/orders (5–300s); default 15 (production-like)
+	PositionsSnapshot       bool                   // Send the full "positions" and "orders" snapshots every poll (POSITIONS_SNAPSHOT); default true
+	PositionDeltas          bool                   // Send position_opened/changed/closed and order_new/updated/filled/canceled between polls (POSITION_DELTAS); default true
+	AccountIntervalSec      int                    // How often to send the "account" event (equity, buying power); default 60, min 5, 0 = off
+	DailyPnLIntervalSec     int                    // How often to send the "daily_pnl" event from the portfolio history (Alpaca broker); default 60, 0 = off
+	DailyPnLTimeframe       string                 // Equity curve resolution for daily_pnl (DAILY_PNL_TIMEFRAME): 1Min, 5Min (default), 15Min, 1H
+	FeedCompareSymbols      []string               // Symbols also streamed from the other feed (IEX vs SIP) to measure divergence; empty = off
+	FeedCompareIntervalSec  int                    // Seconds per "feed_compare" report; default 60
+	MarketCloseET           string                 // "16:00" = 4pm ET; engine exits at this time so entrypoint can sleep until 7am then discovery (set 13:00 for half-days)
+	AutoSchedule            bool                   // Streaming: sleep while the market is closed, start before pre-market and stop after post-market each trading day (MARKET_CLOSE_ET is then ignored)
+	AutoScheduleLeadMin     int                    // AUTO_SCHEDULE: minutes before pre-market (04:00 ET) to connect and warm up; default 15
+	AutoScheduleGraceMin    int                    // AUTO_SCHEDULE: minutes after post-market (20:00 ET, earlier on half-days) to keep running; default 5
+	IdleEvictAt             string                 // "10:00" ET: drop symbols that traded less than IdleEvictMinVolume by then; empty = off
+	GapScanAt               string                 // "09:25" ET: send a "gap_scan" event of pre-market gaps before the open; empty = off
+	GapScanMinPct           float64                // Gap scan lists symbols at least this far from the previous close (fraction); default 0.02
+	BackfillStart           string                 // With STREAM=false: write historical bars from this date (YYYY-MM-DD ET or RFC3339) to BackfillOut and exit
+	BackfillEnd             string                 // Backfill end (same formats, exclusive); empty = now
+	BackfillTimeframe       string                 // Backfill bar size; default 1Min
+	BackfillAdjustment      string                 // Backfill corporate action adjustment: raw, split (default), dividend or all
+	BackfillOut             string                 // Backfill output, one JSON bar per line; default bars.ndjson
+	IdleEvictMinVolume      int64                  // Shares a symbol must have traded today (on the stream) to stay subscribed; default 50000
+	UniverseExpand          bool                   // Subscribe symbols mentioned in news mid-session for a trial window (UNIVERSE_EXPAND)
+	UniverseExpandMinPrice  float64                // Candidate's last trade must be at least this; default 5
+	UniverseExpandMinVolume int64                  // Candidate must have traded this many shares today; default 500000
+	UniverseExpandTrialMin  int                    // Minutes a trial symbol stays after its last mention; default 60
+	UniverseExpandMax       int                    // Symbols on trial at once; default 10, 0 = no limit
+	UniverseScan            bool                   // Stream the top movers/most-active symbols next to Tickers, rescanned periodically (UNIVERSE_SCAN)
+	UniverseScanMin         int                    // Minutes between scans; default 15
+	UniverseScanTop         int                    // Symbols taken from each screen (gainers, losers, most active); default 20
+	UniverseScanMax         int                    // Scanned symbols streamed at once; default 10
+	UniverseScanMinPrice    float64                // Scanned symbol's last trade must be at least this; default 5
+	UniverseScanMinVolume   int64                  // Scanned symbol must have traded this many shares today; default 1000000
+	VolMethod               string                 // "close" (close-to-close, default) or "ewma" (RiskMetrics exponentially weighted)
+	VolEWMALambda           float64                // EWMA decay factor (0–1); default 0.94
+	VolWindow               int                    // Daily bars fetched and used for volatility; default 30
+	VolMinBars              int                    // Fewer usable bars = symbol flagged insufficient_data instead of published; default 10
+	BetaBenchmark           string                 // Symbol beta is computed against (default SPY); empty = beta disabled
+	CorrelationTimeframe    string                 // Bars used for correlation: "1Day" (default) or intraday e.g. "5Min"
+	CorrelationWindow       int                    // Bars per symbol in the correlation window; default 30
+	CorrelationIntervalMin  int                    // Minutes between "correlation" events; default 15, 0 = disabled
+	BarTimeframes           []string               // Timeframes kept fresh via REST and sent as "bars_update" (e.g. 1Min, 5Min, 1Hour); empty = off
+	BarsLookback            int                    // Bars per symbol/timeframe in the first bars_update; default 50
+	FeatureVectors          bool                   // Add "features" (ordered float array) + "feature_schema" to trade/quote payloads
+	BrainQueueSize          int                    // Buffered events for the brain pipe; when full the oldest is dropped; default 10000
+	BrainEncoding           string                 // Brain stdin encoding: "json" (NDJSON, default), "msgpack" or "protobuf" (length-prefixed frames)
+	BrainReadyTimeoutSec    int                    // Wait for {"type":"ready"} from the brain before streaming (then send a snapshot); default 30, 0 = no handshake
+	BrainBufferMaxAgeSec    int                    // While the brain restarts, buffer events and replay those younger than this; default 30, 0 = discard
+	BrainBufferMemory       int                    // Events buffered in memory while the brain is down before spilling; default 10000
+	BrainSpillDir           string                 // Spill directory for events beyond BrainBufferMemory; empty = keep newest in memory only
+	BrainSpillMaxMB         int                    // Spill file size cap in MB; default 256, 0 = unlimited
+	BrainInjectLatencyMs    int                    // Testing: delay every event to the brain by this many ms; default 0
+	BrainInjectJitterMs     int                    // Testing: plus a random 0..N ms per event; default 0
+	InferenceModel          string                 // Model scored on every trade/quote feature vector (.json linear or .onnx with -tags onnx); empty = off
+	InferenceSignals        bool                   // Emit a "signal" event when model_score >= InferenceThreshold; off = only attach model_score
+	InferenceThreshold      float64                // Score at which InferenceSignals fires; 0 is a threshold like any other
+	InferenceInputName      string                 // ONNX input tensor name; default "input"
+	InferenceOutputName     string                 // ONNX output tensor name; default "output"
+	ONNXRuntimeLib          string                 // Path to libonnxruntime shared library (ONNX builds only)
+	OrderHoursGuard         string                 // Engine order gateway vs market clock: "convert" (mark extended_hours limits, refuse market orders off-hours), "block", "off"
+	TradeUpdates            bool                   // Stream account trade updates (fills, partial fills) as "trade_update" events; default true
+	PositionReconcile       bool                   // Compare polled positions with the engine's own orders and fills, "position_drift" on a mismatch (needs TradeUpdates); default true
+	PositionDriftGraceSec   int                    // How long a mismatch may last before position_drift (fills in flight); default 30
+	OrderChase              string                 // Resting limit orders past the timeout: "cancel", "reprice" (to the touch), "market" (remainder); default "off"
+	OrderChaseTimeoutSec    int                    // Seconds a limit order may rest (since submit or last reprice) before chasing; default 30
+	OrderChaseMaxReprices   int                    // Reprice mode cancels after this many reprices; default 3
+	FallbackBrain           string                 // Go fallback strategy: "off" (default), "auto" (only while Python brain is down), "on"
+	FallbackDryRun          bool                   // Fallback logs decisions without placing orders
+	FallbackMaxNotional     float64                // Fallback dollar cap per position; default 1000
+	FallbackMaxPositions    int                    // Fallback skips entries when the account holds this many positions; default 3
+	FallbackMaxEntries      int                    // Fallback new entries per ET day; default 10
+	FallbackEntryZ          float64                // Enter when return_5m >= this x vol-implied 5m move; default 2
+	FallbackExitZ           float64                // Exit when return_5m <= -this x vol-implied 5m move; default 1
+	FallbackStopLossPct     float64                // Fallback stop loss below entry (fraction); default 0.01
+	FallbackTakeProfitPct   float64                // Fallback take profit above entry (fraction); default 0.02
+	FallbackCooldownMin     int                    // Minutes between fallback orders in one symbol; default 15
+	FallbackMinVolume1m     int64                  // Fallback skips entries when volume_1m is below this; default 1000
+	FallbackOnRecovery      string                 // Fallback positions once the Python brain is back (auto): "flatten" (default) or "handoff"
+	SizingRiskPerTrade      float64                // "size" requests: fraction of equity risked per trade at conviction 1; default 0.005
+	SizingStopVolMult       float64                // Default stop distance in expected one-day moves (price x vol / sqrt(252)); default 1
+	SizingMaxPositionPct    float64                // Cap on one position's notional as a fraction of equity; default 0.10
+	SizingBuyingPowerPct    float64                // Fraction of remaining buying power one order may use; default 0.95
+	SizingFractional        bool                   // Size in fractional shares instead of whole shares
+	CostTable               string                 // CSV of per-symbol slippage_bps, fee_bps, borrow_rate_pct ("*" = default row); empty = no costs
+	Reentry                 ReentryRule            // Global re-entry policy for engine-placed orders (REENTRY_EXIT_COOLDOWN_MIN, REENTRY_STOP_COOLDOWN_MIN, REENTRY_MAX_PER_DAY); zero = off
+	ReentrySymbols          map[string]ReentryRule // Per-symbol re-entry rules (REENTRY_SYMBOLS)
+	BudgetMaxPositions      int                    // Engine-placed entries: max open positions (incl. working entries); 0 = unlimited
+	BudgetMaxEntriesPerHour int                    // Max entry orders in the rolling hour; 0 = unlimited
+	BudgetMaxEntriesPerDay  int                    // Max entry orders per ET day; 0 = unlimited
+	BudgetMaxCapitalPerHour float64                // Max entry notional in the rolling hour (dollars); 0 = unlimited
+	RiskMaxOrderNotional    float64                // Pre-trade: max dollars per order, exits included; 0 = unlimited
+	RiskMaxPositionNotional float64                // Pre-trade: max dollars held in one symbol after the order; 0 = unlimited
+	RiskMaxPositionShares   float64                // Pre-trade: max shares held in one symbol after the order; 0 = unlimited
+	RiskMaxOpenOrders       int                    // Pre-trade: working orders account-wide before entries are refused; 0 = unlimited
+	RiskBannedSymbols       []string               // Pre-trade: symbols that may not be added to (exits allowed)
+	RiskPDTGuard            bool                   // Pre-trade: refuse the day trade that would flag an account under $25k as a pattern day trader
+	DailyLossLimit          float64                // Halt trading and mute market data to the brain when the day's P&L reaches -this (dollars); 0 = off
+	DailyLossFlatten        bool                   // Also close every position when the daily loss limit trips
+	RiskReportIntervalSec   int                    // Seconds between "risk_report" events to the brain; default 60, 0 = off
+	EngineStatsIntervalMin  int                    // Minutes between "engine_stats" summaries; default 60, 0 = only at shutdown
+	NewsBackfillHours       int                    // At startup, send news from the last N hours flagged backfill; default 12, 0 = off
+	NewsBackfillMax         int                    // Cap on backfilled articles (oldest first); default 1000, 0 = no cap
+	NewsDedupeSize          int                    // Article IDs remembered to drop repeats and send changed articles as "news_update"; default 10000, 0 = off
+	NewsSentiment           bool                   // Score news headline and summary with a finance lexicon (sentiment, sentiment_confidence); default true
+	NewsEnrichURL           string                 // POST each live news event here (e.g. an LLM service) and attach the JSON object returned as "enrichment"; empty = off
+	NewsEnrichTimeoutMs     int                    // Wait for a slot and the response at most this long, then send without; default 2000
+	NewsEnrichMax           int                    // Enrichment requests in flight (NEWS_ENRICH_CONCURRENCY); default 4
+	NewsPerSymbol           bool                   // Send news as one event per tagged symbol with a relevance score, not one event for all; default true
+	NewsAll                 bool                   // NEWS_SYMBOLS=*: subscribe to all news; only articles tagging a streamed symbol go to the brain
+	NewsOtherStream         string                 // With NewsAll, the Redis stream (REDIS_URL) for the other articles; empty = dropped
+	NewsClusterMin          int                    // Cluster near-duplicate headlines published within this many minutes (NEWS_CLUSTER_WINDOW_MIN); default 60, 0 = off
+	NewsClusterSim          float64                // Headline cosine similarity that joins a cluster (NEWS_CLUSTER_SIMILARITY); default 0.5
+	GapRecoverySec          int                    // Stream outages at least this long get a "gap_recovery" event on reconnect; default 30, 0 = off
+	GapCheckSec             int                    // Seconds between checks of sink and brain queues for lost events, each loss sent as a "gap" event; default 5, 0 = off
+	StaleTickMs             int                    // Trades/quotes whose exchange time is older than this when processed are stale (e.g. reconnect bursts); 0 = off
+	StaleTickAction         string                 // STALE_TICK_MS: "drop" (default; still counted in state) or "flag" (sent with stale=true)
+	TradeConditionFilter    bool                   // Trades whose sale conditions don't update the last price (odd lots, late reports...) count as volume only; default true
+	OptionsUnderlyings      []string               // Underlyings whose option chains give implied_vol events (and option quotes); empty = off
+	OptionsFeed             string                 // Options data feed: "indicative" (default, free) or "opra"
+	OptionsIntervalSec      int                    // Option chain refresh; default 300
+	OptionsMaxDays          int                    // Expirations up to this many days out; default 60
+	OptionsStrikePct        float64                // Strikes within this fraction of spot; default 0.1 (±10%)
+	OptionsStreamExpiries   int                    // Stream quotes for the ATM call and put of the nearest N expirations; default 2, 0 = no stream
+	Sinks                   []string               // Event outputs to enable (SINKS: brain,redis,kafka,file); nil = every configured one
+	RedisURL                string                 // Redis sink, e.g. redis://localhost:6379/0; empty = off
+	RedisStream             string                 // Redis stream the sink appends to; default market:updates
+	RedisDRURL              string                 // Replica Redis (e.g. another region) with its own queue; empty = off
+	RedisDRStream           string                 // Stream on the replica Redis; default RedisStream
+	RedisStreamMaxLen       int64                  // Approximate cap on stream entries (XADD MAXLEN ~); default 1000000, 0 = unbounded
+	RedisRetentionMin       int                    // Trim stream entries older than this many minutes, once a minute; 0 = off
+	RedisBatchSize          int                    // Events per Redis pipeline; default 500
+	RedisFlushMs            int                    // Wait up to this long for a pipeline to fill before flushing; default 5, 0 = no wait
+	RedisOutbox             int                    // Events kept while Redis is down and retried in order; default 100000, 0 = drop
+	RedisSnapshotPrefix     string                 // Per-symbol latest-state hash prefix (snapshot:AAPL); default "snapshot:", "off" = none
+	KafkaBrokers            []string               // Kafka sink brokers (KAFKA_BROKERS, comma-separated); empty = off
+	KafkaTopic              string                 // Kafka sink topic; default sentry-events, keyed by symbol
+	KafkaDRBrokers          []string               // Replica Kafka cluster (KAFKA_DR_BROKERS) with its own queue; empty = off
+	KafkaDRTopic            string                 // Topic on the replica cluster; default KafkaTopic
+	PnLStream               string                 // Redis stream for tick-level P&L (on REDIS_URL); default pnl:updates, "off" = none
+	PnLKafkaTopic           string                 // Kafka topic for tick-level P&L (on KAFKA_BROKERS); empty = off
+	SinkQueueSize           int                    // Events queued per sink before the oldest is dropped; default 10000
+	EventFile               string                 // Append every event as NDJSON to this file; empty = off
+	WSListenAddr            string                 // Serve the event stream to WebSocket subscribers here, e.g. 127.0.0.1:8765; empty = off
+	WSToken                 string                 // Token WebSocket subscribers must present; required unless WSListenAddr is loopback
+	WSOrigins               []string               // Browser origins allowed on the WebSocket besides its own (WS_ALLOWED_ORIGINS, comma-separated)
+	EventFilters            string                 // JSON file of CEL drop/tag rules applied per sink and per brain; empty = off
+	EventRoutes             string                 // Event types (and 1-in-N sampling) per sink, inline ("redis=trade,quote/10;file=*") or a .json file; empty = everything everywhere
+	EventTTLMs              int                    // Hot events older than this are dropped unsent at every stage; 0 = off
+	EventTTLTypes           []string               // Event types the TTL applies to; default trade,quote
+	CommandsStream          string                 // Redis stream the engine reads brain commands from (e.g. brain:commands); empty = off
+	WebhookListenAddr       string                 // Accept external signals on POST /signal here, e.g. :8787; empty = off
+	WebhookToken            string                 // Shared secret webhook senders must present; the endpoint stays off without it
+	ControlListenAddr       string                 // Serve the control actions (pause, resume, flatten, kill) over HTTP here, e.g. 127.0.0.1:8788; empty = off
+	ControlToken            string                 // Bearer token the control endpoint requires; it stays off without it
+	DigestTo                []string               // Email the daily digest to these addresses (DIGEST_TO, comma-separated); empty = off
+	DigestAt                string                 // "17:00" ET: when the digest goes out on weekdays
+	SMTPAddr                string                 // SMTP server host:port for the digest (465 = implicit TLS, otherwise STARTTLS when offered)
+	SMTPUser                string                 // SMTP login; empty = no auth
+	SMTPPassword            string                 // SMTP password
+	DigestFrom              string                 // From address; default SMTP_USER
+	FlightRecorderSec       int                    // Seconds of events and decisions kept in memory and dumped on panic or kill switch; 0 = off
+	FlightRecorderMax       int                    // Ring capacity in records (bounds memory at high tick rates); default 200000
+	FlightRecorderDir       string                 // Directory flight recorder dumps are written to; default flight-recorder
+	CommandsRedisURL        string                 // Redis server for the command stream; default REDIS_URL
+	KVPath                  string                 // bbolt file for the brain's persistent scratchpad (kv.* requests), e.g. data/brain_kv.db; empty = disabled
+	StateCheckpoint         string                 // Checkpoint brain state here and restore it at startup: a file (data/state.msgpack) or a redis:// URL; empty = off
+	StateCheckpointKey      string                 // STATE_CHECKPOINT on Redis: key holding the checkpoint; default engine:state
+	StateCheckpointSec      int                    // Seconds between checkpoints (one more at shutdown); default 15
+	StateCheckpointAgeMin   int                    // Ignore a checkpoint saved longer ago than this at startup; default 10
+	ComplianceAuditDir      string                 // If set, write the order audit trail (JSONL per day) here; empty = disabled
+	ComplianceRetentionDays int                    // Delete compliance files older than this many days (<=0 = keep forever); default 2190
+	AuditLogFile            string                 // Append the audit log (intents, decisions, broker calls, fills, control actions) to this JSONL file; empty = off
+	AuditStream             string                 // Also publish audit records to this Redis stream (on REDIS_URL), e.g. audit:log; empty = off
+	OTLPEndpoint            string                 // Export OpenTelemetry traces and metrics to this OTLP collector, e.g. http://localhost:4317; empty = off
+	OTLPProtocol            string                 // OTLP transport: grpc (default) or http/protobuf (then usually port 4318)
+	OTLPHotSampleRatio      float64                // Share of WebSocket message and per-event spans exported (default 0.01); REST and Redis spans are all kept
+}
+
+// ReentryRule is a cooldown/re-entry policy: minutes after any exit, minutes after a stop-out, and
+// re-entries from flat per ET day (0 = no limit).
+type ReentryRule struct {
+	ExitCooldownMin int
+	StopCooldownMin int
+	MaxPerDay       int
 }
@@ -0,0 +1,136 @@
+// Package control is the engine's operator surface over local HTTP: pause and resume the brain's feed,
+// flatten the account, or kill the engine. The same actions are commands on the Redis command stream;
+// both go through one handler in the engine, so every action is audited the same way.
+package control
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	maxBody         = 16 << 10 // request bodies larger than this are refused
+	shutdownTimeout = 5 * time.Second
+)
+
+// Actions are the control actions, each served on POST /<action>.
+var Actions = []string{"pause", "resume", "flatten", "kill"}
+
+// Params are an action's optional parameters. Flatten and Halt apply to kill: over HTTP they default to
+// true, on the command stream to false.
+type Params struct {
+	Reason  string `json:"reason"`
+	Flatten *bool  `json:"flatten"`
+	Halt    *bool  `json:"halt"`
+}
+
+// Handler carries out an action for a source ("http", "command") and returns its outcome.
+type Handler func(action, source string, p Params) (interface{}, error)
+
+// Server takes control actions on POST /pause, /resume, /flatten and /kill, with an optional JSON Params
+// body, and reports the engine's state on GET /status. The token goes in an Authorization: Bearer header.
+type Server struct {
+	token  string
+	lis    net.Listener
+	srv    *http.Server
+	handle Handler
+	status func() interface{}
+}
+
+// NewServer listens on addr; token is required. handle carries out actions and status reports the state.
+func NewServer(addr, token string, handle Handler, status func() interface{}) (*Server, error) {
+	if token == "" {
+		return nil, errors.New("control: token required")
+	}
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("control: %w", err)
+	}
+	s := &Server{token: token, lis: lis, handle: handle, status: status}
+	mux := http.NewServeMux()
+	for _, action := range Actions {
+		action := action
+		mux.HandleFunc("/"+action, func(w http.ResponseWriter, r *http.Request) { s.act(w, r, action) })
+	}
+	mux.HandleFunc("/status", s.state)
+	s.srv = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second, ReadTimeout: 30 * time.Second}
+	return s, nil
+}
+
+// Addr is the address the server listens on.
+func (s *Server) Addr() string { return s.lis.Addr().String() }
+
+// Run serves until ctx is done, then shuts the server down.
+func (s *Server) Run(ctx context.Context) {
+	go func() {
+		<-ctx.Done()
+		sctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		_ = s.srv.Shutdown(sctx)
+	}()
+	if err := s.srv.Serve(s.lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		slog.Error("control server stopped", "err", err)
+	}
+}
+
+func (s *Server) act(w http.ResponseWriter, r *http.Request, action string) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		reply(w, http.StatusMethodNotAllowed, map[string]interface{}{"ok": false, "error": "POST only"})
+		return
+	}
+	if !s.authorized(r) {
+		reply(w, http.StatusUnauthorized, map[string]interface{}{"ok": false, "error": "bad or missing token"})
+		return
+	}
+	var p Params
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBody))
+	if err == nil && len(strings.TrimSpace(string(body))) > 0 {
+		err = json.Unmarshal(body, &p)
+	}
+	if err != nil {
+		reply(w, http.StatusBadRequest, map[string]interface{}{"ok": false, "error": fmt.Sprintf("body must be a JSON object: %v", err)})
+		return
+	}
+	slog.Info("control request", "action", action, "remote", r.RemoteAddr)
+	result, err := s.handle(action, "http", p)
+	if err != nil {
+		reply(w, http.StatusInternalServerError, map[string]interface{}{"ok": false, "error": err.Error(), "result": result})
+		return
+	}
+	reply(w, http.StatusOK, map[string]interface{}{"ok": true, "result": result})
+}
+
+func (s *Server) state(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		reply(w, http.StatusMethodNotAllowed, map[string]interface{}{"ok": false, "error": "GET only"})
+		return
+	}
+	if !s.authorized(r) {
+		reply(w, http.StatusUnauthorized, map[string]interface{}{"ok": false, "error": "bad or missing token"})
+		return
+	}
+	reply(w, http.StatusOK, s.status())
+}
+
+// authorized checks the bearer token.
+func (s *Server) authorized(r *http.Request) bool {
+	got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(got), []byte(s.token)) == 1
+}
+
+func reply(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
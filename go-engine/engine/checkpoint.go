@@ -0,0 +1,89 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// checkpointStore holds the latest brain state checkpoint (STATE_CHECKPOINT). Load returns nil, nil when
+// there is none yet.
+type checkpointStore interface {
+	Load() ([]byte, error)
+	Save(data []byte) error
+	String() string
+}
+
+// newCheckpointStore picks Redis for a redis:// or rediss:// target, otherwise a file.
+func newCheckpointStore(target, key string) (checkpointStore, error) {
+	if strings.HasPrefix(target, "redis://") || strings.HasPrefix(target, "rediss://") {
+		opts, err := redis.ParseURL(target)
+		if err != nil {
+			return nil, err
+		}
+		return &redisCheckpoint{client: redis.NewClient(opts), key: key}, nil
+	}
+	return fileCheckpoint(target), nil
+}
+
+// fileCheckpoint writes to a temporary file and renames it over the last one, so a crash mid-write
+// leaves the previous checkpoint intact.
+type fileCheckpoint string
+
+func (f fileCheckpoint) String() string { return string(f) }
+
+func (f fileCheckpoint) Load() ([]byte, error) {
+	data, err := os.ReadFile(string(f))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (f fileCheckpoint) Save(data []byte) error {
+	dir := filepath.Dir(string(f))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(string(f))+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), string(f))
+}
+
+type redisCheckpoint struct {
+	client *redis.Client
+	key    string
+}
+
+func (r *redisCheckpoint) String() string { return "redis key " + r.key }
+
+func (r *redisCheckpoint) Load() ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	data, err := r.client.Get(ctx, r.key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (r *redisCheckpoint) Save(data []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return r.client.Set(ctx, r.key, data, 0).Err()
+}
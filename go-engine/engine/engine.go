@@ -0,0 +1,2771 @@
+// Package engine is the streaming engine: it streams Alpaca market data (trades, quotes, news), computes
+// volatility and derived features, and sends events to the brains and every other configured sink, with
+// the order gateway, guards and background jobs behind them. The sentry-bridge binary is a thin wrapper;
+// another Go service can embed the same engine:
+//
+//	cfg, err := config.Load()
+//	if err != nil { ... }
+//	err = engine.New(cfg, mySink).Run(ctx)
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sunnyp94/sentry-bridge/go-engine/alpaca"
+	"github.com/sunnyp94/sentry-bridge/go-engine/audit"
+	"github.com/sunnyp94/sentry-bridge/go-engine/brain"
+	"github.com/sunnyp94/sentry-bridge/go-engine/clock"
+	"github.com/sunnyp94/sentry-bridge/go-engine/command"
+	"github.com/sunnyp94/sentry-bridge/go-engine/compliance"
+	"github.com/sunnyp94/sentry-bridge/go-engine/config"
+	"github.com/sunnyp94/sentry-bridge/go-engine/control"
+	"github.com/sunnyp94/sentry-bridge/go-engine/events"
+	"github.com/sunnyp94/sentry-bridge/go-engine/execution"
+	"github.com/sunnyp94/sentry-bridge/go-engine/fallback"
+	"github.com/sunnyp94/sentry-bridge/go-engine/inference"
+	"github.com/sunnyp94/sentry-bridge/go-engine/kv"
+	"github.com/sunnyp94/sentry-bridge/go-engine/news"
+	"github.com/sunnyp94/sentry-bridge/go-engine/report"
+	"github.com/sunnyp94/sentry-bridge/go-engine/sink"
+	"github.com/sunnyp94/sentry-bridge/go-engine/telemetry"
+	"github.com/sunnyp94/sentry-bridge/go-engine/universe"
+	"github.com/sunnyp94/sentry-bridge/go-engine/webhook"
+)
+
+// ErrKilled is returned by Run when a kill control action halted the engine.
+var ErrKilled = errors.New("engine halted by kill")
+
+// telemetryFlushTimeout bounds the export of the last spans and metrics at shutdown.
+const telemetryFlushTimeout = 5 * time.Second
+
+// statsFlushTimeout bounds the wait for the sinks to write the final engine_stats.
+const statsFlushTimeout = 5 * time.Second
+
+// stream is a live connection Run keeps up: the price, news, option and trade update streams. Close ends
+// its Run from another goroutine.
+type stream interface {
+	Run() error
+	Close() error
+}
+
+// inboundTypes are market data and signals: each gets an event ID, which an order intent can carry back
+// as its correlation_id, and they are withheld from the brain while it is paused. Account events
+// (positions, orders, fills) still flow, so the brain stays in sync.
+var inboundTypes = []string{
+	events.TypeTrade, events.TypeQuote, events.TypeBarsUpdate, events.TypeNews, events.TypeNewsUpdate,
+	events.TypeSignal, events.TypeExternalSignal,
+}
+
+// Engine is the streaming engine for one configuration: everything the binary runs in streaming mode.
+type Engine struct {
+	cfg   *config.Config
+	sinks []sink.Sink
+	clock clock.Clock
+}
+
+// New returns an engine for cfg (e.g. from config.Load). sinks receive the event stream next to the
+// outputs cfg configures: SINKS does not apply to them, EVENT_FILTERS and EVENT_ROUTES do (by sink name).
+func New(cfg *config.Config, sinks ...sink.Sink) *Engine {
+	return &Engine{cfg: cfg, sinks: sinks, clock: clock.Real{}}
+}
+
+// WithClock replaces the engine clock (real time by default), e.g. with a clock.Manual in a backtest. It
+// drives rolling windows, session labels, throttles and schedules; latency measurements stay on real
+// time. Call before Run.
+func (e *Engine) WithClock(c clock.Clock) *Engine {
+	e.clock = c
+	return e
+}
+
+// Run streams until parent is done, or until MARKET_CLOSE_ET when that is set, then shuts down: final
+// stats go out and every sink, including those passed to New, is flushed and closed. It returns an error
+// when the configuration can't be used (an event filter that doesn't compile, an unreadable cost table).
+// Run installs no signal handlers; cancel parent to stop it.
+func (e *Engine) Run(parent context.Context) error {
+	// A copy: the asset preflight narrows the tickers for this run only, leaving the caller's config
+	// (and a later Run) with the full list
+	c := *e.cfg
+	cfg := &c
+	slog.Info("streaming mode", "data_url", cfg.DataBaseURL, "stream_url", cfg.StreamWSURL, "tickers", cfg.Tickers)
+
+	// OpenTelemetry export, first in so it is the last thing flushed: spans from the sinks' final
+	// flushes still go out. The exporters stay up for a later Run in the same process.
+	if cfg.OTLPEndpoint != "" {
+		flush, err := telemetry.Setup(parent, telemetry.Config{Endpoint: cfg.OTLPEndpoint, Protocol: cfg.OTLPProtocol,
+			HotSampleRatio: cfg.OTLPHotSampleRatio})
+		if err != nil {
+			return err
+		}
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), telemetryFlushTimeout)
+			defer cancel()
+			if err := flush(ctx); err != nil {
+				slog.Warn("telemetry flush failed", "err", err)
+			}
+		}()
+		slog.Info("telemetry export enabled", "endpoint", cfg.OTLPEndpoint, "protocol", cfg.OTLPProtocol)
+	}
+
+	// One rate limit for both clients: Alpaca counts data and trading requests against the same account
+	restLimit := alpaca.NewRateLimiter(cfg.RESTRateLimitPerMin)
+	client := alpaca.NewClient(cfg.DataBaseURL, cfg.APIKeyID, cfg.APISecretKey)
+	client.SetRetryPolicy(RetryPolicy(cfg))
+	client.SetRateLimiter(restLimit)
+	client.SetBarsAdjustment(cfg.BarsAdjustment)
+	provider := NewDataProvider(cfg, client)
+	tradingClient := alpaca.NewTradingClient(cfg.TradingBaseURL, cfg.APIKeyID, cfg.APISecretKey)
+	tradingClient.SetRetryPolicy(RetryPolicy(cfg))
+	tradingClient.SetRateLimiter(restLimit)
+	trading := NewBroker(cfg, tradingClient)
+	if trading.Name() != "alpaca" {
+		slog.Info("broker", "name", trading.Name(), "url", cfg.IBKRBaseURL, "account", cfg.IBKRAccountID)
+	}
+	// Audit log: order intents, decisions, fills and control actions, and every broker call that changes
+	// the account (the broker is wrapped here, so nothing goes around it)
+	var auditLog *audit.Log
+	if cfg.AuditLogFile != "" || (cfg.AuditStream != "" && cfg.RedisURL != "") {
+		if l, err := audit.Open(cfg.AuditLogFile); err != nil {
+			slog.Error("audit log disabled", "file", cfg.AuditLogFile, "err", err)
+		} else {
+			auditLog = l
+			defer auditLog.Close()
+			trading = auditLog.Broker(trading)
+			slog.Info("audit log enabled", "file", cfg.AuditLogFile, "stream", cfg.AuditStream)
+		}
+	}
+
+	// Brain closest to data: pipe events to Python subprocess(es) via stdin (no Redis in hot path), or
+	// stream them to remote brains over gRPC. With several brains, symbols are sharded across them by the router.
+	var pipes []*brain.Pipe
+	brainTargets := cfg.BrainCmds
+	if cfg.BrainTransport == brain.TransportGRPC {
+		brainTargets = cfg.BrainGRPCAddrs
+	}
+	// Without BRAIN_ROUTES a brain that fails to start is left out and the others share its symbols.
+	// Routes pin symbols by brain number, which a missing brain would shift onto the wrong brains, so
+	// then startup fails instead.
+	pinnedFailed := func(i int, err error) error {
+		if len(cfg.BrainRoutes) == 0 {
+			return nil
+		}
+		for _, p := range pipes {
+			_ = p.Close()
+		}
+		return fmt.Errorf("brain %d failed to start and BRAIN_ROUTES pins symbols by brain number: %w", i+1, err)
+	}
+	for i, target := range brainTargets {
+		opts := brain.PipeOptions{
+			QueueSize:     cfg.BrainQueueSize,
+			BufferMaxAge:  time.Duration(cfg.BrainBufferMaxAgeSec) * time.Second,
+			BufferMemory:  cfg.BrainBufferMemory,
+			SpillDir:      cfg.BrainSpillDir,
+			SpillMaxBytes: int64(cfg.BrainSpillMaxMB) << 20,
+			ReadyTimeout:  time.Duration(cfg.BrainReadyTimeoutSec) * time.Second,
+			Encoding:      cfg.BrainEncoding,
+			InjectLatency: time.Duration(cfg.BrainInjectLatencyMs) * time.Millisecond,
+			InjectJitter:  time.Duration(cfg.BrainInjectJitterMs) * time.Millisecond,
+		}
+		if len(brainTargets) > 1 {
+			opts.Name = fmt.Sprintf("brain-%d", i+1)
+			opts.Env = []string{"BRAIN_INSTANCE=" + opts.Name, fmt.Sprintf("BRAIN_INSTANCES=%d", len(brainTargets))}
+			if opts.SpillDir != "" {
+				opts.SpillDir = filepath.Join(opts.SpillDir, opts.Name)
+			}
+		}
+		if cfg.BrainTransport == brain.TransportGRPC {
+			opts.GRPCToken, opts.GRPCTLSCert, opts.GRPCTLSKey = cfg.BrainGRPCToken, cfg.BrainGRPCTLSCert, cfg.BrainGRPCTLSKey
+			p, err := brain.ListenGRPC(target, opts)
+			if err != nil {
+				if err := pinnedFailed(i, err); err != nil {
+					return err
+				}
+				slog.Error("brain gRPC listen failed", "addr", target, "err", err)
+				continue
+			}
+			pipes = append(pipes, p)
+			slog.Info("brain gRPC server listening", "addr", target, "name", opts.Name, "token", opts.GRPCToken != "", "tls", opts.GRPCTLSCert != "")
+			continue
+		}
+		p, err := brain.StartPipe(target, opts)
+		if err != nil {
+			if err := pinnedFailed(i, err); err != nil {
+				return err
+			}
+			slog.Error("brain pipe start failed", "cmd", target, "err", err)
+			continue
+		}
+		if p != nil {
+			pipes = append(pipes, p)
+			slog.Info("brain pipe started", "cmd", target, "name", opts.Name, "encoding", cfg.BrainEncoding)
+		}
+	}
+	if len(pipes) > 0 && (cfg.BrainInjectLatencyMs > 0 || cfg.BrainInjectJitterMs > 0) {
+		slog.Warn("simulated feed latency enabled for the brain (testing only)", "latency_ms", cfg.BrainInjectLatencyMs, "jitter_ms", cfg.BrainInjectJitterMs)
+	}
+	brains := brain.NewRouter(pipes, cfg.BrainRoutes)
+
+	// Event outputs: every event goes through the dispatcher to each enabled sink (SINKS; by default every
+	// sink that is configured). Closing the dispatcher stops the brains and flushes the other sinks.
+	var sinks []sink.Sink
+	if brains != nil && sinkEnabled(cfg, sink.NameBrain) {
+		sinks = append(sinks, brains)
+	}
+	// Redis and Kafka each take an optional DR replica: a separate sink with its own queue and health, so
+	// a copy of the stream survives losing the primary host
+	redisTargets := []sink.RedisConfig{{URL: cfg.RedisURL, Stream: cfg.RedisStream}, {Name: "redis-dr:" + cfg.RedisDRStream, URL: cfg.RedisDRURL, Stream: cfg.RedisDRStream}}
+	for _, rc := range redisTargets {
+		if rc.URL == "" || !sinkEnabled(cfg, sink.NameRedis) {
+			continue
+		}
+		rc.QueueSize, rc.MaxLen, rc.Retention = cfg.SinkQueueSize, cfg.RedisStreamMaxLen, time.Duration(cfg.RedisRetentionMin)*time.Minute
+		rc.Batching = sink.Batching{Size: cfg.RedisBatchSize, Linger: time.Duration(cfg.RedisFlushMs) * time.Millisecond}
+		rc.Outbox, rc.Snapshots = cfg.RedisOutbox, cfg.RedisSnapshotPrefix
+		if r, err := sink.NewRedis(rc); err != nil {
+			slog.Error("redis sink disabled", "name", rc.Name, "stream", rc.Stream, "err", err)
+		} else {
+			sinks = append(sinks, r)
+			slog.Info("redis sink enabled", "name", r.Name(), "maxlen", rc.MaxLen, "retention_min", cfg.RedisRetentionMin)
+		}
+	}
+	kafkaTargets := []sink.KafkaConfig{{Brokers: cfg.KafkaBrokers, Topic: cfg.KafkaTopic}, {Name: "kafka-dr:" + cfg.KafkaDRTopic, Brokers: cfg.KafkaDRBrokers, Topic: cfg.KafkaDRTopic}}
+	for _, kc := range kafkaTargets {
+		if len(kc.Brokers) == 0 || !sinkEnabled(cfg, sink.NameKafka) {
+			continue
+		}
+		kc.QueueSize = cfg.SinkQueueSize
+		if k, err := sink.NewKafka(kc); err != nil {
+			slog.Error("kafka sink disabled", "name", kc.Name, "topic", kc.Topic, "err", err)
+		} else {
+			sinks = append(sinks, k)
+			slog.Info("kafka sink enabled", "name", k.Name(), "brokers", kc.Brokers)
+		}
+	}
+	if cfg.EventFile != "" && sinkEnabled(cfg, sink.NameFile) {
+		if f, err := sink.NewFile(cfg.EventFile, cfg.SinkQueueSize); err != nil {
+			slog.Error("event file sink disabled", "path", cfg.EventFile, "err", err)
+		} else {
+			sinks = append(sinks, f)
+			slog.Info("event file sink enabled", "path", cfg.EventFile)
+		}
+	}
+	if cfg.WSListenAddr != "" && sinkEnabled(cfg, sink.NameWebSocket) {
+		wsCfg := sink.WebSocketConfig{Addr: cfg.WSListenAddr, QueueSize: cfg.SinkQueueSize, Token: cfg.WSToken, Origins: cfg.WSOrigins}
+		if ws, err := sink.NewWebSocket(wsCfg); err != nil {
+			slog.Error("websocket sink disabled", "addr", cfg.WSListenAddr, "err", err)
+		} else {
+			sinks = append(sinks, ws)
+			slog.Info("websocket sink listening", "addr", ws.Addr())
+		}
+	}
+	// Sinks from the embedding application (New)
+	sinks = append(sinks, e.sinks...)
+	// User-defined CEL drop/tag rules, per sink and per brain (EVENT_FILTERS). A rule that doesn't compile
+	// stops the engine: silently passing everything would hide the mistake.
+	if cfg.EventFilters != "" {
+		rules, err := sink.LoadRules(cfg.EventFilters)
+		if err != nil {
+			return fmt.Errorf("event filters: %w", err)
+		}
+		for i, s := range sinks {
+			if s == sink.Sink(brains) {
+				fs := make([]*sink.Filter, len(brains.Pipes()))
+				for j, p := range brains.Pipes() {
+					if fs[j], err = sink.NewFilter(sink.RulesFor(rules, sink.NameBrain, p.Name())); err != nil {
+						return fmt.Errorf("event filters for %s: %w", s.Name(), err)
+					}
+				}
+				brains.SetFilters(fs)
+				continue
+			}
+			f, err := sink.NewFilter(sink.RulesFor(rules, s.Name()))
+			if err != nil {
+				return fmt.Errorf("event filters for %s: %w", s.Name(), err)
+			}
+			sinks[i] = sink.Filtered(s, f)
+		}
+		slog.Info("event filters loaded", "path", cfg.EventFilters, "rules", len(rules))
+	}
+	// Event types and sampling per sink (EVENT_ROUTES), so each output's load can be set on its own.
+	// Applied after the filters: only events a filter kept count toward a sample.
+	if cfg.EventRoutes != "" {
+		routes, err := sink.ParseRoutes(cfg.EventRoutes)
+		if err != nil {
+			return fmt.Errorf("event routes: %w", err)
+		}
+		for i, s := range sinks {
+			if rates := routes.For(s.Name()); rates != nil {
+				sinks[i] = sink.Routed(s, rates)
+				slog.Info("event route", "sink", s.Name(), "types", rates)
+			}
+		}
+	}
+	// Flight recorder: the last FLIGHT_RECORDER_SEC of events and decisions, dumped on a panic or the kill
+	// switch. Added after the filters so it records everything, whatever SINKS says.
+	var recorder *sink.Recorder
+	if cfg.FlightRecorderSec > 0 {
+		recorder = sink.NewRecorder(cfg.FlightRecorderDir, time.Duration(cfg.FlightRecorderSec)*time.Second, cfg.FlightRecorderMax)
+		sinks = append(sinks, recorder)
+		defer recorder.DumpOnPanic()
+		slog.Info("flight recorder enabled", "window_sec", cfg.FlightRecorderSec, "max_records", cfg.FlightRecorderMax, "dir", cfg.FlightRecorderDir)
+	}
+	// Daily email digest: tallies trades, P&L, risk and data-quality events from the stream. Like the
+	// recorder it reads everything, whatever SINKS says.
+	var digest *report.Digest
+	var mailer *report.Mailer
+	if len(cfg.DigestTo) > 0 {
+		if cfg.SMTPAddr == "" || cfg.DigestFrom == "" {
+			slog.Error("email digest disabled: set SMTP_ADDR and SMTP_USER or DIGEST_FROM")
+		} else {
+			digest = report.NewDigest(time.Now())
+			mailer = &report.Mailer{Addr: cfg.SMTPAddr, User: cfg.SMTPUser, Password: cfg.SMTPPassword, From: cfg.DigestFrom, To: cfg.DigestTo}
+			sinks = append(sinks, digest)
+			slog.Info("email digest enabled", "to", cfg.DigestTo, "at", cfg.DigestAt, "smtp", cfg.SMTPAddr)
+		}
+	}
+	out := sink.NewDispatcher(sinks...)
+	defer out.Close()
+	out.SetEventIDs(inboundTypes)
+	// Hot events expire EVENT_TTL_MS after dispatch; every stage drops them unsent after that
+	if cfg.EventTTLMs > 0 {
+		out.SetTTL(time.Duration(cfg.EventTTLMs)*time.Millisecond, cfg.EventTTLTypes)
+		slog.Info("event ttl enabled", "ms", cfg.EventTTLMs, "types", cfg.EventTTLTypes)
+	}
+	if brains != nil && !sinkEnabled(cfg, sink.NameBrain) {
+		defer brains.Close()
+	}
+	// P&L stream: held positions marked to market on every trade print, on their own Redis stream and/or
+	// Kafka topic so risk dashboards don't have to filter the market firehose
+	var pnlSinks []sink.Sink
+	if cfg.RedisURL != "" && cfg.PnLStream != "" {
+		rc := sink.RedisConfig{URL: cfg.RedisURL, Stream: cfg.PnLStream, QueueSize: cfg.SinkQueueSize, MaxLen: cfg.RedisStreamMaxLen, Outbox: cfg.RedisOutbox}
+		rc.Batching = sink.Batching{Size: cfg.RedisBatchSize, Linger: time.Duration(cfg.RedisFlushMs) * time.Millisecond}
+		if r, err := sink.NewRedis(rc); err != nil {
+			slog.Error("pnl redis sink disabled", "stream", rc.Stream, "err", err)
+		} else {
+			pnlSinks = append(pnlSinks, r)
+		}
+	}
+	if len(cfg.KafkaBrokers) > 0 && cfg.PnLKafkaTopic != "" {
+		if k, err := sink.NewKafka(sink.KafkaConfig{Brokers: cfg.KafkaBrokers, Topic: cfg.PnLKafkaTopic, QueueSize: cfg.SinkQueueSize}); err != nil {
+			slog.Error("pnl kafka sink disabled", "topic", cfg.PnLKafkaTopic, "err", err)
+		} else {
+			pnlSinks = append(pnlSinks, k)
+		}
+	}
+	pnlOut := sink.NewDispatcher(pnlSinks...)
+	defer pnlOut.Close()
+	if cfg.EventTTLMs > 0 {
+		pnlOut.SetTTL(time.Duration(cfg.EventTTLMs)*time.Millisecond, cfg.EventTTLTypes)
+	}
+	for _, s := range pnlSinks {
+		slog.Info("pnl stream enabled", "name", s.Name())
+	}
+	// Audit stream: the audit log's records on their own Redis stream, never trimmed; the type is the
+	// record kind
+	if auditLog != nil && cfg.AuditStream != "" && cfg.RedisURL != "" {
+		rc := sink.RedisConfig{URL: cfg.RedisURL, Stream: cfg.AuditStream, QueueSize: cfg.SinkQueueSize, Outbox: cfg.RedisOutbox}
+		if r, err := sink.NewRedis(rc); err != nil {
+			slog.Error("audit redis sink disabled", "stream", rc.Stream, "err", err)
+		} else {
+			auditOut := sink.NewDispatcher(r)
+			defer auditOut.Close()
+			auditLog.OnRecord = func(rec audit.Record) { auditOut.SendSymbol(rec.Symbol, rec.Kind, rec) }
+		}
+	}
+	// Brain tracebacks go out like any other event (alerting via Redis/Kafka, and the restarted brain)
+	brains.OnError(func(ev events.BrainErrorEvent) { out.Send(events.TypeBrainError, ev) })
+
+	// Compliance order audit trail (separate from app logs; retention-pruned)
+	var trail *compliance.Trail
+	if cfg.ComplianceAuditDir != "" {
+		if t, err := compliance.NewTrail(cfg.ComplianceAuditDir, cfg.ComplianceRetentionDays); err != nil {
+			slog.Error("compliance trail disabled", "dir", cfg.ComplianceAuditDir, "err", err)
+		} else {
+			trail = t
+			defer trail.Close()
+			slog.Info("compliance trail enabled", "dir", cfg.ComplianceAuditDir, "retention_days", cfg.ComplianceRetentionDays)
+		}
+	}
+
+	// Engine clock for rolling windows, session labels, throttles and schedules (WithClock)
+	clk := e.clock
+	if clk == nil {
+		clk = clock.Real{}
+	}
+
+	// Tick-level P&L for the P&L stream and the daily-loss limit: positions from the poll and fills, marked
+	// on every trade print
+	var pnl *execution.PnL
+	if pnlOut != nil || cfg.DailyLossLimit > 0 {
+		pnl = execution.NewPnL()
+		pnl.SetClock(clk)
+	}
+
+	// Brain state: price/volume history for returns and volume_1m/5m
+	state := brain.NewState()
+	state.SetClock(clk)
+	// State checkpoints: restore the last one so a quick restart keeps the lookback windows, then save
+	// every STATE_CHECKPOINT_SEC and once more at shutdown
+	var saveState func()
+	if cfg.StateCheckpoint != "" {
+		if store, err := newCheckpointStore(cfg.StateCheckpoint, cfg.StateCheckpointKey); err != nil {
+			slog.Error("state checkpoints disabled", "target", cfg.StateCheckpoint, "err", err)
+		} else {
+			if data, err := store.Load(); err != nil {
+				slog.Warn("state checkpoint not loaded", "store", store, "err", err)
+			} else if data != nil {
+				if n, err := state.Restore(data, time.Duration(cfg.StateCheckpointAgeMin)*time.Minute); err != nil {
+					slog.Warn("state checkpoint not restored", "store", store, "err", err)
+				} else {
+					slog.Info("state restored from checkpoint", "store", store, "symbols", n)
+				}
+			}
+			saveState = func() {
+				data, err := state.Checkpoint()
+				if err == nil {
+					err = store.Save(data)
+				}
+				if err != nil {
+					slog.Warn("state checkpoint failed", "store", store, "err", err)
+				}
+			}
+		}
+	}
+	// Brain can query state on demand (ticks, quote, stats) over its stdout request channel
+	for _, p := range brains.Pipes() {
+		brain.RegisterStateHandlers(p, state)
+	}
+	// Feature vector layout: sent with each ready snapshot and available on request
+	if brains != nil && cfg.FeatureVectors {
+		schema := events.FeatureSchemaEvent{Version: brain.FeatureSchemaVersion, Names: brain.FeatureNames}
+		brains.Handle("feature_schema", func(json.RawMessage) (interface{}, error) {
+			return schema, nil
+		})
+	}
+	// Market calendar from the broker: holidays and half-days for session labels, reloaded each ET day.
+	// Without it only weekends count as closed.
+	fetchCalendar := func(day time.Time) *brain.Calendar {
+		from, to := day.AddDate(0, 0, -7).Format("2006-01-02"), day.AddDate(0, 0, 30).Format("2006-01-02")
+		cal, err := tradingClient.GetCalendar(from, to)
+		if err != nil {
+			slog.Warn("market calendar unavailable; holidays and half-days not known", "err", err)
+			return nil
+		}
+		days := make([]brain.TradingDay, 0, len(cal))
+		for _, d := range cal {
+			days = append(days, brain.TradingDay{Date: d.Date, Open: d.Open, Close: d.Close})
+		}
+		return brain.NewCalendar(from, to, days)
+	}
+	// Options expiry: today's kind goes on trades, quotes and the ready snapshot, an expiry_day event is
+	// sent the morning of each expiration day, and the brain can ask for any date's expiration. Holiday
+	// Fridays come from the calendar (expiry moves to Thursday).
+	var expiryToday atomic.Value // string: ExpiryContext for calendarDate
+	expiryToday.Store("")
+	calendarDate := ""
+	refreshDay := func() {
+		now := clk.Now()
+		day := now.In(brain.Eastern()).Format("2006-01-02")
+		if day == calendarDate {
+			return
+		}
+		calendarDate = day
+		cal := fetchCalendar(now)
+		if cal != nil {
+			brain.SetCalendar(cal)
+		}
+		if brain.Session(now) == brain.SessionClosed {
+			slog.Info("market closed today", "date", day)
+		}
+		e := brain.NextExpiry(now, cal.Closed)
+		kind := ""
+		if e.Date == day {
+			kind = e.Kind
+		}
+		expiryToday.Store(kind)
+		if kind != "" {
+			slog.Info("options expiration day", "date", day, "kind", kind, "shifted", e.Shifted)
+			out.Send(events.TypeExpiryDay, events.ExpiryDayEvent{Date: day, Kind: kind, Shifted: e.Shifted})
+		}
+	}
+	refreshDay()
+	if brains != nil {
+		brains.Handle("expiry", func(raw json.RawMessage) (interface{}, error) {
+			var params struct {
+				Date string `json:"date"` // YYYY-MM-DD; default today
+			}
+			if len(raw) > 0 {
+				if err := json.Unmarshal(raw, &params); err != nil {
+					return nil, fmt.Errorf("bad params: %w", err)
+				}
+			}
+			day := clk.Now()
+			if params.Date != "" {
+				d, err := time.ParseInLocation("2006-01-02", params.Date, brain.Eastern())
+				if err != nil {
+					return nil, fmt.Errorf("bad date: %w", err)
+				}
+				day = d
+			}
+			return brain.NextExpiry(day, fetchCalendar(day).Closed), nil
+		})
+	}
+
+	// In-process model scoring of feature vectors (model_score on payloads, "signal" above threshold)
+	var model inference.Model
+	if cfg.InferenceModel != "" {
+		m, err := inference.Load(inference.Options{
+			Path:          cfg.InferenceModel,
+			InputName:     cfg.InferenceInputName,
+			OutputName:    cfg.InferenceOutputName,
+			SharedLibPath: cfg.ONNXRuntimeLib,
+			NumFeatures:   len(brain.FeatureNames),
+		})
+		if err != nil {
+			slog.Error("inference model disabled", "path", cfg.InferenceModel, "err", err)
+		} else {
+			model = m
+			defer model.Close()
+			slog.Info("inference model loaded", "path", cfg.InferenceModel, "signals", cfg.InferenceSignals, "threshold", cfg.InferenceThreshold)
+		}
+	}
+	scoreFeatures := func(eventType, symbol string, price float64, features []float64) *float64 {
+		score, err := model.Score(features)
+		if err != nil {
+			slog.Debug("inference score error", "symbol", symbol, "err", err)
+			return nil
+		}
+		if cfg.InferenceSignals && score >= cfg.InferenceThreshold && out != nil {
+			out.SendSymbol(symbol, events.TypeSignal, events.SignalEvent{
+				Symbol: symbol, Score: score, Threshold: cfg.InferenceThreshold,
+				Source: eventType, Price: price, Model: filepath.Base(cfg.InferenceModel),
+			})
+		}
+		return &score
+	}
+
+	// Persistent scratchpad for the brain (survives brain restarts)
+	if len(brains.Pipes()) > 0 && cfg.KVPath != "" {
+		if store, err := kv.Open(cfg.KVPath); err != nil {
+			slog.Error("kv store disabled", "path", cfg.KVPath, "err", err)
+		} else {
+			defer store.Close()
+			for _, p := range brains.Pipes() {
+				kv.RegisterHandlers(p, store)
+			}
+			slog.Info("kv store enabled", "path", cfg.KVPath)
+		}
+	}
+
+	// Latest positions, open orders and account, from the pollers below; position is the signed qty held
+	var acctMu sync.Mutex
+	var lastPositions []events.Position
+	var lastOrders []events.Order
+	var lastAccount *events.AccountEvent
+	position := func(symbol string) float64 {
+		acctMu.Lock()
+		defer acctMu.Unlock()
+		for _, p := range lastPositions {
+			if p.Symbol == symbol {
+				qty, _ := strconv.ParseFloat(p.Qty, 64)
+				if p.Side == "short" && qty > 0 {
+					qty = -qty
+				}
+				return qty
+			}
+		}
+		return 0
+	}
+
+	// Position sizing: the brain sends intents (side, conviction) and gets share quantities back, so sizing
+	// policy (risk per trade, vol stop, equity/buying-power caps) stays in one place
+	sizer := execution.NewSizer(execution.SizingConfig{
+		RiskPerTrade:   cfg.SizingRiskPerTrade,
+		StopVolMult:    cfg.SizingStopVolMult,
+		MaxPositionPct: cfg.SizingMaxPositionPct,
+		BuyingPowerPct: cfg.SizingBuyingPowerPct,
+		Fractional:     cfg.SizingFractional,
+	}, state, trading.GetAccount)
+	sizer.Position = position
+	// Per-symbol costs (slippage, fees, borrow): counted in sizing risk and sent with volatility events
+	var costs execution.CostTable
+	if cfg.CostTable != "" {
+		t, err := execution.LoadCostTable(cfg.CostTable)
+		if err != nil {
+			return err
+		}
+		costs = t
+		sizer.SetCosts(costs)
+		slog.Info("cost table loaded", "path", cfg.CostTable, "symbols", len(costs))
+	}
+	for _, p := range brains.Pipes() {
+		execution.RegisterHandlers(p, sizer)
+	}
+
+	// Order gateway for engine-placed orders: checked against the live market clock unless ORDER_HOURS_GUARD=off.
+	// Next to the broker, each placed order's correlation ID is kept, so trade updates trace back to the
+	// event behind the intent.
+	correlations := execution.NewCorrelations(trading)
+	if auditLog != nil {
+		auditLog.Correlation = correlations.Of
+	}
+	var orderPlacer alpaca.OrderPlacer = correlations
+	// Position reconciliation: what the streamed fills add up to against the positions poll
+	var reconciler *execution.Reconciler
+	if cfg.PositionReconcile && cfg.TradeUpdates {
+		reconciler = execution.NewReconciler(time.Duration(cfg.PositionDriftGraceSec) * time.Second)
+		reconciler.SetClock(clk)
+		reconciler.OnDrift = func(ev events.PositionDriftEvent) {
+			slog.Warn("position drift", "symbol", ev.Symbol, "expected", ev.Expected, "actual", ev.Actual, "since", ev.Since)
+			if out != nil {
+				out.SendSymbol(ev.Symbol, events.TypePosDrift, ev)
+			}
+		}
+		slog.Info("position reconciliation enabled", "grace_sec", cfg.PositionDriftGraceSec)
+	}
+	if cfg.OrderHoursGuard != alpaca.GuardOff {
+		hoursGuard := alpaca.NewHoursGuard(tradingClient, orderPlacer, cfg.OrderHoursGuard)
+		hoursGuard.SetClock(clk)
+		orderPlacer = hoursGuard
+	}
+	// Short sale check: sells that would go short in an asset Alpaca won't lend are refused here, with an
+	// order_rejected event, instead of bouncing at the broker
+	var shortGuard *execution.ShortGuard
+	if cfg.ShortCheck && trading.Name() == "alpaca" {
+		shortGuard = execution.NewShortGuard(orderPlacer, tradingClient.GetAsset, cfg.ShortRequireETB)
+		shortGuard.SetClock(clk)
+		shortGuard.OnReject = func(ev events.OrderRejectedEvent) {
+			slog.Warn("order rejected", "symbol", ev.Symbol, "reason", ev.Reason, "detail", ev.Detail)
+			if out != nil {
+				out.SendSymbol(ev.Symbol, events.TypeOrderRejected, ev)
+			}
+		}
+		orderPlacer = shortGuard
+		slog.Info("short sale check enabled", "require_easy_to_borrow", cfg.ShortRequireETB)
+	}
+	// Cooldown after exits/stop-outs and daily re-entry cap, enforced for every engine-placed entry
+	var reentryGuard *execution.ReentryGuard
+	if reentryEnabled(cfg) {
+		reentry := execution.ReentryConfig{Default: reentryRule(cfg.Reentry), Symbols: make(map[string]execution.ReentryRule)}
+		for sym, r := range cfg.ReentrySymbols {
+			reentry.Symbols[sym] = reentryRule(r)
+		}
+		reentryGuard = execution.NewReentryGuard(reentry, orderPlacer)
+		reentryGuard.SetClock(clk)
+		orderPlacer = reentryGuard
+		slog.Info("re-entry policy enabled", "exit_cooldown_min", cfg.Reentry.ExitCooldownMin,
+			"stop_cooldown_min", cfg.Reentry.StopCooldownMin, "max_per_day", cfg.Reentry.MaxPerDay, "symbol_rules", len(cfg.ReentrySymbols))
+	}
+	// Last trade price, for the notional of market orders
+	lastPrice := func(symbol string) float64 {
+		if ticks := state.LastTicks(symbol, 1); len(ticks) == 1 {
+			return ticks[0].Price
+		}
+		return 0
+	}
+	// Account-wide entry budget: open positions, entries per hour/day, capital deployed per hour
+	var budget *execution.Budget
+	if cfg.BudgetMaxPositions > 0 || cfg.BudgetMaxEntriesPerHour > 0 || cfg.BudgetMaxEntriesPerDay > 0 || cfg.BudgetMaxCapitalPerHour > 0 {
+		budget = execution.NewBudget(execution.BudgetConfig{
+			MaxPositions:      cfg.BudgetMaxPositions,
+			MaxEntriesPerHour: cfg.BudgetMaxEntriesPerHour,
+			MaxEntriesPerDay:  cfg.BudgetMaxEntriesPerDay,
+			MaxCapitalPerHour: cfg.BudgetMaxCapitalPerHour,
+		}, orderPlacer, lastPrice)
+		budget.SetClock(clk)
+		orderPlacer = budget
+		slog.Info("entry budget enabled", "max_positions", cfg.BudgetMaxPositions, "max_entries_per_hour", cfg.BudgetMaxEntriesPerHour,
+			"max_entries_per_day", cfg.BudgetMaxEntriesPerDay, "max_capital_per_hour", cfg.BudgetMaxCapitalPerHour)
+	}
+	// Pre-trade risk limits: order and position size, working orders, banned symbols
+	var riskGuard *execution.RiskGuard
+	if cfg.RiskMaxOrderNotional > 0 || cfg.RiskMaxPositionNotional > 0 || cfg.RiskMaxPositionShares > 0 || cfg.RiskMaxOpenOrders > 0 || len(cfg.RiskBannedSymbols) > 0 {
+		risk := execution.RiskConfig{
+			MaxOrderNotional:    cfg.RiskMaxOrderNotional,
+			MaxPositionNotional: cfg.RiskMaxPositionNotional,
+			MaxPositionShares:   cfg.RiskMaxPositionShares,
+			MaxOpenOrders:       cfg.RiskMaxOpenOrders,
+			Banned:              make(map[string]bool),
+		}
+		for _, s := range cfg.RiskBannedSymbols {
+			risk.Banned[s] = true
+		}
+		riskGuard = execution.NewRiskGuard(risk, orderPlacer, lastPrice)
+		orderPlacer = riskGuard
+		slog.Info("pre-trade risk limits enabled", "max_order_notional", cfg.RiskMaxOrderNotional, "max_position_notional", cfg.RiskMaxPositionNotional,
+			"max_position_shares", cfg.RiskMaxPositionShares, "max_open_orders", cfg.RiskMaxOpenOrders, "banned", cfg.RiskBannedSymbols)
+	}
+	// Pattern day trader guard: refuse the day trade that would flag an account under $25k
+	var pdtGuard *execution.PDTGuard
+	if cfg.RiskPDTGuard {
+		pdtGuard = execution.NewPDTGuard(orderPlacer)
+		pdtGuard.SetClock(clk)
+		pdtGuard.OnWarning = func(ev events.PDTEvent) {
+			slog.Warn("pattern day trader limit", "symbol", ev.Symbol, "day_trades", ev.DayTrades, "equity", ev.Equity, "blocked", ev.Blocked)
+			out.Send(events.TypePDT, ev)
+		}
+		orderPlacer = pdtGuard
+		slog.Info("pattern day trader guard enabled", "min_equity", execution.PDTMinEquity, "max_day_trades", execution.PDTMaxDayTrades)
+	}
+
+	// Kill switch: the outermost gate, so a halt stops every engine-placed order (commands, fallback, chaser)
+	killSwitch := execution.NewKillSwitch(orderPlacer)
+	killSwitch.SetClock(clk)
+	killSwitch.OnEngage = func(reason string) {
+		recorder.Note("kill_switch", reason)
+		// Engaging can happen on the trade callback (daily loss); the dump writes a file, so not there
+		go func() { _, _ = recorder.Dump("kill_switch") }()
+	}
+	// Daily-loss limit: once the day's P&L reaches -DAILY_LOSS_LIMIT, orders are halted, market data
+	// stops going to the brain and positions are optionally flattened. The P&L is checked on trade prints
+	// (realized + change in unrealized, from memory) and on every account poll (equity - last_equity, from
+	// the broker), so a restart mid-day doesn't reset it. It trips at most once per ET day and engine run,
+	// so a resume command overrides it until the next day.
+	var lossMu sync.Mutex
+	var lossDay string
+	checkDailyLoss := func(dayPL float64) {
+		if cfg.DailyLossLimit <= 0 || dayPL > -cfg.DailyLossLimit {
+			return
+		}
+		day := clk.Now().In(brain.Eastern()).Format("2006-01-02")
+		lossMu.Lock()
+		tripped := lossDay == day
+		lossDay = day
+		lossMu.Unlock()
+		if tripped {
+			return
+		}
+		reason := fmt.Sprintf("daily loss $%.2f reached the $%.2f limit", -dayPL, cfg.DailyLossLimit)
+		slog.Error("daily loss limit reached; trading halted", "day_pl", dayPL, "limit", cfg.DailyLossLimit, "flatten", cfg.DailyLossFlatten)
+		killSwitch.Engage(reason)
+		auditLog.Record(audit.Record{Kind: audit.KindControl, Source: "daily_loss", Action: "kill", Outcome: "ok", Reason: reason})
+		brains.Mute("daily_loss", events.TypeTrade, events.TypeQuote)
+		if cfg.DailyLossFlatten {
+			go func() {
+				if err := trading.CloseAllPositions(); err != nil {
+					slog.Error("daily loss flatten failed", "err", err)
+				}
+			}()
+		}
+		out.Send(events.TypeKillSwitch, events.KillSwitchEvent{Engaged: true, Reason: reason, Source: "daily_loss", Flatten: cfg.DailyLossFlatten})
+	}
+	killSwitch.OnRelease = func() { brains.Unmute("daily_loss") }
+	// Every submission and refusal (including the kill switch's) is noted in the flight recorder, and each
+	// intent and its decision in the audit log
+	orderPlacer = auditLog.Orders(recorder.Orders(killSwitch))
+	// Order intents from the brain ("order" request) and the command stream go through the whole chain;
+	// each outcome is published as an order_decision event
+	gateway := execution.NewGateway(orderPlacer)
+	gateway.OnInvalid = auditLog.Invalid
+	// An executed order command also refreshes positions and orders right away (coalesced), so the brain
+	// sees its own fills before the next poll
+	positionsRefresh := make(chan struct{}, 1)
+	refreshPositions := func() {
+		select {
+		case positionsRefresh <- struct{}{}:
+		default:
+		}
+	}
+	gateway.OnDecision = func(ev events.OrderDecisionEvent) {
+		out.Send(events.TypeOrderDecision, ev)
+		if ev.Accepted {
+			refreshPositions()
+		}
+	}
+	// The compliance trail has each intent as it arrives and each one the engine refused; what reaches
+	// the broker is recorded from the orders poll and trade updates
+	if trail != nil {
+		gateway.OnIntent = func(req alpaca.OrderRequest) {
+			if err := trail.Record(compliance.IntentEvent(req, compliance.EventIntent, "")); err != nil {
+				slog.Error("compliance trail write failed", "symbol", req.Symbol, "err", err)
+			}
+		}
+		decided := gateway.OnDecision
+		gateway.OnDecision = func(ev events.OrderDecisionEvent) {
+			decided(ev)
+			if ev.Accepted {
+				return
+			}
+			req := alpaca.OrderRequest{Symbol: ev.Symbol, Side: ev.Side, Qty: ev.Qty, Type: ev.Type, LimitPrice: ev.LimitPrice,
+				ClientOrderID: ev.ClientOrderID, Source: ev.Source, CorrelationID: ev.CorrelationID}
+			if err := trail.Record(compliance.IntentEvent(req, compliance.EventRejected, ev.Reason)); err != nil {
+				slog.Error("compliance trail write failed", "symbol", ev.Symbol, "err", err)
+			}
+		}
+	}
+	for _, p := range brains.Pipes() {
+		execution.RegisterOrderHandler(p, gateway)
+	}
+
+	// Go fallback brain: volatility-scaled momentum with strict caps when the Python brain is down/not configured
+	var fallbackBrain *fallback.Strategy
+	if cfg.FallbackBrain != fallback.ModeOff {
+		fallbackBrain = fallback.New(fallback.Config{
+			EntryZ:           cfg.FallbackEntryZ,
+			ExitZ:            cfg.FallbackExitZ,
+			StopLossPct:      cfg.FallbackStopLossPct,
+			TakeProfitPct:    cfg.FallbackTakeProfitPct,
+			MaxNotional:      cfg.FallbackMaxNotional,
+			MaxPositions:     cfg.FallbackMaxPositions,
+			MaxEntriesPerDay: cfg.FallbackMaxEntries,
+			Cooldown:         time.Duration(cfg.FallbackCooldownMin) * time.Minute,
+			MinVolume1m:      cfg.FallbackMinVolume1m,
+			OnRecovery:       cfg.FallbackOnRecovery,
+			DryRun:           cfg.FallbackDryRun,
+		}, orderPlacer)
+		slog.Info("fallback brain enabled", "mode", cfg.FallbackBrain, "max_notional", cfg.FallbackMaxNotional,
+			"max_positions", cfg.FallbackMaxPositions, "dry_run", cfg.FallbackDryRun)
+	}
+	fallbackActive := func(symbol string) bool {
+		return fallbackBrain != nil && (cfg.FallbackBrain == fallback.ModeOn || !brains.Alive(symbol))
+	}
+
+	// Asset preflight: each symbol's flags from the trading API. Symbols Alpaca can't trade, or doesn't
+	// know, are dropped before anything subscribes, instead of every order for them being rejected.
+	if cfg.AssetPreflight && trading.Name() == "alpaca" {
+		if assets, err := tradingClient.GetAssets(cfg.Tickers); err != nil {
+			slog.Warn("asset preflight unavailable; streaming every symbol", "err", err)
+		} else {
+			if shortGuard != nil {
+				shortGuard.Seed(assets)
+			}
+			kept := make([]string, 0, len(cfg.Tickers))
+			for _, sym := range cfg.Tickers {
+				a, known := assets[sym]
+				ev := assetEvent(sym, a, known)
+				if ev.Streamed {
+					kept = append(kept, sym)
+				} else {
+					slog.Warn("symbol not tradable; not streamed", "symbol", sym, "status", ev.Status)
+				}
+				out.SendSymbol(sym, events.TypeAsset, ev)
+			}
+			if len(kept) == 0 {
+				return fmt.Errorf("asset preflight: none of %d symbols is tradable", len(cfg.Tickers))
+			}
+			slog.Info("asset preflight", "symbols", len(kept), "of", len(cfg.Tickers))
+			cfg.Tickers = kept
+		}
+	}
+
+	// Shared volatility (updated every 5 min): each refresh publishes a new brain.VolSnapshot in State
+	// (copy-on-write), and payloads read every volatility value from one snapshot and carry its version
+	volSymbols := cfg.Tickers
+	if cfg.BetaBenchmark != "" && !containsString(cfg.Tickers, cfg.BetaBenchmark) {
+		volSymbols = append(append([]string(nil), cfg.Tickers...), cfg.BetaBenchmark)
+	}
+
+	// volatilityEvent builds the brain payload for sym from snapshot vs; false if there is nothing to send.
+	volatilityEvent := func(vs *brain.VolSnapshot, sym string) (events.VolatilityEvent, bool) {
+		var cost *events.SymbolCost
+		if c, ok := costs.Lookup(sym); ok {
+			cost = &c
+		}
+		if n, ok := vs.Insufficient[sym]; ok {
+			return events.VolatilityEvent{Symbol: sym, VolStatus: events.VolStatusInsufficientData, Bars: n, Costs: cost, VolVersion: vs.Version}, true
+		}
+		v := vs.Vol[sym]
+		if v <= 0 {
+			return events.VolatilityEvent{}, false
+		}
+		payload := events.VolatilityEvent{
+			Symbol: sym, AnnualizedVol30d: v, VolMethod: cfg.VolMethod, VolStatus: events.VolStatusOK,
+			ParkinsonVol30d: vs.Parkinson[sym], GarmanKlassVol30d: vs.GarmanKlass[sym], Costs: cost, VolVersion: vs.Version,
+		}
+		if beta, ok := vs.Beta[sym]; ok {
+			payload.Beta = &beta
+			payload.BetaBenchmark = cfg.BetaBenchmark
+		}
+		return payload, true
+	}
+
+	// Initial volatility and push to brain. Symbols with too few usable bars are skipped and flagged
+	// (vol_status=insufficient_data) rather than published as NaN.
+	updateVolatility := func() {
+		allBars, err := BarsWindow(provider, volSymbols, "1Day", cfg.VolWindow, clk.Now())
+		if err != nil {
+			slog.Error("volatility bars error", "err", err)
+			return
+		}
+		benchBars := allBars[cfg.BetaBenchmark]
+		// Range-based (OHLC) estimators alongside close-to-close, and beta vs cfg.BetaBenchmark from the
+		// same daily bars. Symbols with too few usable bars are flagged instead of published as NaN.
+		vs := &brain.VolSnapshot{
+			AsOf: clk.Now(), Vol: make(map[string]float64), Parkinson: make(map[string]float64),
+			GarmanKlass: make(map[string]float64), Beta: make(map[string]float64), Insufficient: make(map[string]int),
+		}
+		for _, sym := range cfg.Tickers {
+			bars := allBars[sym]
+			v, err := alpaca.Volatility(bars, VolOptions(cfg, cfg.VolMethod))
+			if err != nil {
+				vs.Insufficient[sym] = len(bars)
+				slog.Warn("volatility skipped", "symbol", sym, "bars", len(bars), "err", err)
+				continue
+			}
+			vs.Vol[sym] = v
+			if pv, err := alpaca.Volatility(bars, VolOptions(cfg, alpaca.MethodParkinson)); err == nil {
+				vs.Parkinson[sym] = pv
+			}
+			if gk, err := alpaca.Volatility(bars, VolOptions(cfg, alpaca.MethodGarmanKlass)); err == nil {
+				vs.GarmanKlass[sym] = gk
+			}
+			if cfg.BetaBenchmark != "" {
+				if b, err := alpaca.Beta(bars, benchBars, cfg.VolMinBars-1); err == nil {
+					vs.Beta[sym] = b
+				} else {
+					slog.Debug("beta skipped", "symbol", sym, "benchmark", cfg.BetaBenchmark, "err", err)
+				}
+			}
+		}
+		state.PublishVolatility(vs)
+		// Push volatility snapshot to brain (one event per symbol, all with the new vol_version)
+		if out != nil {
+			for _, sym := range cfg.Tickers {
+				if payload, ok := volatilityEvent(vs, sym); ok {
+					t0 := time.Now()
+					out.SendSymbol(sym, events.TypeVolatility, payload)
+					slog.Debug("latency", "step", "brain_send", "type", "volatility", "ms", time.Since(t0).Milliseconds())
+				}
+			}
+		}
+		for _, sym := range cfg.Tickers {
+			if v := vs.Vol[sym]; v > 0 {
+				slog.Info("volatility", "symbol", sym, "annualized_30d_pct", v*100, "method", cfg.VolMethod,
+					"parkinson_pct", vs.Parkinson[sym]*100, "garman_klass_pct", vs.GarmanKlass[sym]*100, "beta", vs.Beta[sym], "version", vs.Version)
+			}
+		}
+	}
+	updateVolatility()
+
+	// State warm-up: recent minute bars and snapshots seed price/volume history, the last trade and quote,
+	// and today's volume, so an engine started mid-session sends real return_1m/5m and volume_1m/5m from
+	// the first event instead of zeros
+	warmState := func() {
+		bars, err := provider.GetBarsSince(cfg.Tickers, "1Min", clk.Now().Add(-10*time.Minute).Truncate(time.Minute))
+		if err != nil {
+			slog.Warn("state warm-up: minute bars unavailable", "err", err)
+			bars = &alpaca.BarsResponse{}
+		}
+		snaps, err := provider.GetSnapshots(cfg.Tickers)
+		if err != nil {
+			slog.Warn("state warm-up: snapshots unavailable", "err", err)
+		}
+		seeded := 0
+		for _, sym := range cfg.Tickers {
+			snap := snaps[sym]
+			if len(bars.Bars[sym]) == 0 && snap.LatestTrade == nil {
+				continue
+			}
+			state.Seed(sym, bars.Bars[sym], snap)
+			seeded++
+		}
+		slog.Info("state warmed up", "symbols", seeded, "of", len(cfg.Tickers))
+	}
+	warmState()
+
+	// Corporate actions: recent and upcoming splits and dividends on the streamed symbols (from Alpaca
+	// whatever the data provider)
+	if cfg.CorpActions && out != nil {
+		today := clk.Now().In(brain.Eastern())
+		actions, err := client.GetCorporateActions(cfg.Tickers, today.AddDate(0, 0, -cfg.CorpActionsBackDays), today.AddDate(0, 0, cfg.CorpActionsAheadDays))
+		if err != nil {
+			slog.Warn("corporate actions unavailable", "err", err)
+		}
+		for _, a := range actions {
+			ev := corporateActionEvent(a, today, state)
+			out.SendSymbol(a.Symbol, events.TypeCorpAction, ev)
+			slog.Info("corporate action", "symbol", a.Symbol, "action", a.Type, "ex_date", a.ExDate, "days_to_ex", ev.DaysToEx,
+				"split_ratio", ev.SplitRatio, "dividend", ev.Dividend)
+		}
+	}
+
+	// Price stream (trades + quotes) — update state and send to brain
+	priceStream := provider.PriceStream(cfg.DataFeed, cfg.Tickers)
+	// IEX vs SIP comparison: the sample symbols are also streamed from the other feed and both are measured
+	var feedCompare *alpaca.FeedCompare
+	if len(cfg.FeedCompareSymbols) > 0 && provider.Name() != "alpaca" {
+		slog.Warn("feed comparison needs DATA_PROVIDER=alpaca (IEX vs SIP); off", "provider", provider.Name())
+	} else if len(cfg.FeedCompareSymbols) > 0 {
+		feedCompare = alpaca.NewFeedCompare(cfg.FeedCompareSymbols)
+	}
+	lastPrint := make(map[string]time.Time)
+	var printMu sync.Mutex
+	// Stale ticks (STALE_TICK_MS): after a reconnect the stream can burst ticks the market has already moved
+	// past. They still count in state (volumes, returns at their exchange time) but are dropped or flagged.
+	staleAfter := time.Duration(cfg.StaleTickMs) * time.Millisecond
+	var staleTicks atomic.Uint64
+	staleAge := func(t time.Time) (float64, bool) {
+		if staleAfter <= 0 || t.IsZero() {
+			return 0, false
+		}
+		age := clk.Now().Sub(t)
+		if age <= staleAfter {
+			return 0, false
+		}
+		staleTicks.Add(1)
+		return float64(age.Microseconds()) / 1000, true
+	}
+	// Trading halts and LULD pauses (stock stream statuses): the halt start per halted symbol. Quotes in a
+	// halt are sent with halted=true and the fallback strategy leaves the symbol alone.
+	var haltMu sync.Mutex
+	halts := make(map[string]time.Time)
+	isHalted := func(symbol string) bool {
+		haltMu.Lock()
+		defer haltMu.Unlock()
+		_, ok := halts[symbol]
+		return ok
+	}
+	// Sale conditions (TRADE_CONDITION_FILTER): odd lots, average-price, derivatively priced and late trades
+	// don't set the last price, so they only add volume and are not sent on.
+	var condSkipped atomic.Uint64
+	priceStream.Handlers().OnTrade = func(tr alpaca.StreamTrade) {
+		symbol, price, size, t, received := tr.Symbol, tr.Price, tr.Size, tr.Time, tr.Received
+		if feedCompare != nil {
+			feedCompare.Trade(cfg.DataFeed, symbol, size, t, received)
+		}
+		conditions, updatesLast := alpaca.DecodeConditions(tr.Tape, tr.Conditions)
+		if cfg.TradeConditionFilter && !updatesLast {
+			state.RecordVolume(symbol, price, size, t, brain.TradeRef{ID: tr.ID, Exchange: tr.Exchange})
+			condSkipped.Add(1)
+			return
+		}
+		state.RecordTrade(symbol, price, size, t, brain.TradeRef{ID: tr.ID, Exchange: tr.Exchange})
+		ageMs, stale := staleAge(t)
+		if stale && cfg.StaleTickAction == "drop" {
+			return
+		}
+		vs := state.VolSnapshot()
+		vol := vs.Volatility(symbol)
+		vol1m, vol5m := state.Volume1m(symbol), state.Volume5m(symbol)
+		ret1m, ret5m := state.Return1m(symbol, price), state.Return5m(symbol, price)
+		session := brain.Session(clk.Now())
+		payload := events.TradeEvent{
+			Symbol:        symbol,
+			Price:         price,
+			Size:          size,
+			Volume1m:      vol1m,
+			Volume5m:      vol5m,
+			Return1m:      ret1m,
+			Return5m:      ret5m,
+			Session:       session,
+			Volatility:    vol,
+			VolVersion:    vs.Version,
+			ExpiryContext: expiryToday.Load().(string),
+			ExchangeTS:    formatTS(t),
+			ReceivedTS:    formatTS(received),
+			Stale:         stale,
+			AgeMs:         ageMs,
+			Conditions:    conditions,
+			Exchange:      tr.Exchange,
+			Tape:          tr.Tape,
+		}
+		if cfg.FeatureVectors || model != nil {
+			q, _ := state.LastQuote(symbol)
+			features := brain.FeatureVector(brain.FeatureInput{
+				IsTrade: true, Price: price, Size: size, Quote: q,
+				Volume1m: vol1m, Volume5m: vol5m, Return1m: ret1m, Return5m: ret5m,
+				Volatility: vol, Session: session,
+			})
+			if cfg.FeatureVectors {
+				payload.Features = features
+				payload.FeatureSchema = brain.FeatureSchemaVersion
+			}
+			if model != nil {
+				payload.ModelScore = scoreFeatures(events.TypeTrade, symbol, price, features)
+			}
+		}
+		var eventID string
+		if out != nil {
+			t0 := time.Now()
+			eventID = out.SendSymbol(symbol, events.TypeTrade, payload)
+			slog.Debug("latency", "step", "brain_send", "type", "trade", "ms", time.Since(t0).Milliseconds())
+		}
+		if fallbackActive(symbol) && !stale && !isHalted(symbol) {
+			fallbackBrain.OnTrade(payload, eventID, clk.Now())
+		} else if fallbackBrain != nil && !stale && !isHalted(symbol) {
+			fallbackBrain.Recovered(payload, eventID, clk.Now())
+		}
+		if pnl != nil && !stale {
+			if ev, ok := pnl.Mark(symbol, price, t); ok {
+				pnlOut.SendSymbol(symbol, events.TypePnL, ev)
+				checkDailyLoss(ev.DayPL)
+			}
+		}
+		printMu.Lock()
+		now := clk.Now()
+		if now.Sub(lastPrint[symbol]) >= time.Second {
+			lastPrint[symbol] = now
+			slog.Debug("price", "symbol", symbol, "price", price, "size", size, "at", t.Format("15:04:05"))
+		}
+		printMu.Unlock()
+	}
+	// Corrections and cancellations of earlier trades: the trade is fixed (or removed) in state so volumes,
+	// returns and VWAP aren't skewed by busted prints, and the brain gets a correction event.
+	priceStream.Handlers().OnCorrection = func(c alpaca.StreamCorrection) {
+		var applied bool
+		ref := brain.TradeRef{ID: c.Original.ID, Exchange: c.Exchange}
+		if c.Action == "correction" {
+			applied = state.CorrectTrade(c.Symbol, ref, c.Original.Price, c.Original.Size, c.Corrected.Price, c.Corrected.Size, c.Time)
+		} else {
+			applied = state.CorrectTrade(c.Symbol, ref, c.Original.Price, c.Original.Size, 0, 0, c.Time)
+		}
+		original, _ := alpaca.DecodeConditions(c.Tape, c.Original.Conditions)
+		corrected, _ := alpaca.DecodeConditions(c.Tape, c.Corrected.Conditions)
+		slog.Info("trade correction", "symbol", c.Symbol, "action", c.Action, "price", c.Original.Price, "size", c.Original.Size,
+			"corrected_price", c.Corrected.Price, "corrected_size", c.Corrected.Size, "applied", applied)
+		out.SendSymbol(c.Symbol, events.TypeCorrection, events.CorrectionEvent{
+			Symbol:              c.Symbol,
+			Action:              c.Action,
+			OriginalID:          c.Original.ID,
+			OriginalPrice:       c.Original.Price,
+			OriginalSize:        c.Original.Size,
+			OriginalConditions:  original,
+			CorrectedID:         c.Corrected.ID,
+			CorrectedPrice:      c.Corrected.Price,
+			CorrectedSize:       c.Corrected.Size,
+			CorrectedConditions: corrected,
+			Exchange:            c.Exchange,
+			Tape:                c.Tape,
+			Applied:             applied,
+			ExchangeTS:          formatTS(c.Time),
+			ReceivedTS:          formatTS(c.Received),
+		})
+	}
+	priceStream.Handlers().OnStatus = func(st alpaca.StreamStatus) {
+		halted, known := st.Halted()
+		if !known {
+			slog.Debug("trading status", "symbol", st.Symbol, "status", st.Code, "message", st.Message)
+			return
+		}
+		at := st.Time
+		if at.IsZero() {
+			at = st.Received
+		}
+		ev := events.HaltEvent{
+			Symbol:        st.Symbol,
+			Status:        st.Code,
+			StatusMessage: st.Message,
+			Reason:        st.ReasonCode,
+			ReasonMessage: st.ReasonMessage,
+			Tape:          st.Tape,
+			ExchangeTS:    formatTS(st.Time),
+			ReceivedTS:    formatTS(st.Received),
+		}
+		haltMu.Lock()
+		since, wasHalted := halts[st.Symbol]
+		if halted && !wasHalted {
+			since = at
+			halts[st.Symbol] = since
+		} else if !halted {
+			delete(halts, st.Symbol)
+		}
+		haltMu.Unlock()
+		if halted || wasHalted {
+			ev.Since = formatTS(since)
+		}
+		typ := events.TypeHalt
+		if !halted {
+			typ = events.TypeResume
+			if wasHalted {
+				ev.HaltedSec = at.Sub(since).Seconds()
+			}
+		}
+		slog.Warn("trading status", "type", typ, "symbol", st.Symbol, "status", st.Code, "message", st.Message, "reason", st.ReasonCode, "halted_sec", ev.HaltedSec)
+		out.SendSymbol(st.Symbol, typ, ev)
+	}
+	priceStream.Handlers().OnQuote = func(symbol string, bid, ask float64, bidSize, askSize int, t, received time.Time) {
+		if feedCompare != nil {
+			feedCompare.Quote(cfg.DataFeed, symbol, bid, ask, t, received)
+		}
+		state.RecordQuote(symbol, bid, ask, bidSize, askSize, t)
+		ageMs, stale := staleAge(t)
+		if stale && cfg.StaleTickAction == "drop" {
+			return
+		}
+		mid := (bid + ask) / 2
+		var spreadBps float64
+		if mid > 0 {
+			spreadBps = (ask - bid) / mid * 10000
+		}
+		vs := state.VolSnapshot()
+		vol := vs.Volatility(symbol)
+		vol1m, vol5m := state.Volume1m(symbol), state.Volume5m(symbol)
+		ret1m, ret5m := state.Return1m(symbol, mid), state.Return5m(symbol, mid)
+		session := brain.Session(clk.Now())
+		payload := events.QuoteEvent{
+			Symbol:        symbol,
+			Bid:           bid,
+			Ask:           ask,
+			BidSize:       bidSize,
+			AskSize:       askSize,
+			Mid:           mid,
+			SpreadBps:     spreadBps,
+			Volume1m:      vol1m,
+			Volume5m:      vol5m,
+			Return1m:      ret1m,
+			Return5m:      ret5m,
+			Session:       session,
+			Volatility:    vol,
+			VolVersion:    vs.Version,
+			ExpiryContext: expiryToday.Load().(string),
+			ExchangeTS:    formatTS(t),
+			ReceivedTS:    formatTS(received),
+			Stale:         stale,
+			AgeMs:         ageMs,
+			Halted:        isHalted(symbol),
+		}
+		if cfg.FeatureVectors || model != nil {
+			q, _ := state.LastQuote(symbol)
+			features := brain.FeatureVector(brain.FeatureInput{
+				Price: mid, Quote: q,
+				Volume1m: vol1m, Volume5m: vol5m, Return1m: ret1m, Return5m: ret5m,
+				Volatility: vol, Session: session,
+			})
+			if cfg.FeatureVectors {
+				payload.Features = features
+				payload.FeatureSchema = brain.FeatureSchemaVersion
+			}
+			if model != nil {
+				payload.ModelScore = scoreFeatures(events.TypeQuote, symbol, mid, features)
+			}
+		}
+		if out != nil {
+			t0 := time.Now()
+			out.SendSymbol(symbol, events.TypeQuote, payload)
+			slog.Debug("latency", "step", "brain_send", "type", "quote", "ms", time.Since(t0).Milliseconds())
+		}
+		printMu.Lock()
+		now := clk.Now()
+		if now.Sub(lastPrint[symbol]) >= time.Second {
+			lastPrint[symbol] = now
+			slog.Debug("quote", "symbol", symbol, "bid", bid, "ask", ask, "mid", mid, "at", t.Format("15:04:05"))
+		}
+		printMu.Unlock()
+	}
+
+	// Intraday universe expansion: symbols mentioned in news or external signals that aren't streamed yet
+	// are checked against price/volume filters and subscribed for a trial window (renewed by further mentions)
+	var expander *universe.Expander
+	if cfg.UniverseExpand {
+		expander = universe.NewExpander(universe.Config{
+			MinPrice:  cfg.UniverseExpandMinPrice,
+			MinVolume: uint64(cfg.UniverseExpandMinVolume),
+			Trial:     time.Duration(cfg.UniverseExpandTrialMin) * time.Minute,
+			MaxTrials: cfg.UniverseExpandMax,
+		}, priceStream, provider.GetSnapshots)
+		expander.SetClock(clk)
+		expander.OnChange = func(ev events.UniverseEvent) { out.Send(events.TypeUniverse, ev) }
+	}
+
+	// News stream — send full article to brain. With NEWS_SYMBOLS=* it carries every article; those that
+	// tag no streamed symbol skip the brain and go to NEWS_OTHER_STREAM (Redis), if set, so market-moving
+	// macro headlines are still recorded
+	newsSymbols := cfg.Tickers
+	var otherNewsSinks []sink.Sink
+	if cfg.NewsAll {
+		newsSymbols = nil
+		if cfg.RedisURL != "" && cfg.NewsOtherStream != "" {
+			rc := sink.RedisConfig{URL: cfg.RedisURL, Stream: cfg.NewsOtherStream, QueueSize: cfg.SinkQueueSize, MaxLen: cfg.RedisStreamMaxLen}
+			if r, err := sink.NewRedis(rc); err != nil {
+				slog.Error("other news redis sink disabled", "stream", rc.Stream, "err", err)
+			} else {
+				otherNewsSinks = append(otherNewsSinks, r)
+				slog.Info("other news stream enabled", "name", r.Name())
+			}
+		}
+	}
+	otherNewsOut := sink.NewDispatcher(otherNewsSinks...)
+	defer otherNewsOut.Close()
+	watched := func(symbols []string) bool {
+		for _, s := range priceStream.Symbols() {
+			if containsString(symbols, s) {
+				return true
+			}
+		}
+		return false
+	}
+	newsStream := provider.NewsStream(newsSymbols)
+	// News events carry a lexicon sentiment score (NEWS_SENTIMENT) and their story cluster
+	// (NEWS_CLUSTER_WINDOW_MIN), whichever path the article came by
+	newsClusters := news.NewCluster(time.Duration(cfg.NewsClusterMin)*time.Minute, cfg.NewsClusterSim)
+	newsEvent := func(a alpaca.NewsArticle) events.NewsEvent {
+		ev := events.NewsFromArticle(a)
+		if cfg.NewsSentiment {
+			sent := news.Score(a.Headline, a.Summary)
+			ev.Sentiment, ev.Confidence = sent.Score, sent.Confidence
+		}
+		if newsClusters != nil {
+			ev.ClusterID, ev.ClusterSize = newsClusters.Assign(a)
+		}
+		return ev
+	}
+	// News goes out per tagged symbol (NEWS_PER_SYMBOL), each copy with the symbol's relevance and routed
+	// to that symbol only, so the brain can tell the subject from the peers tagged along
+	sendNews := func(typ string, ev events.NewsEvent) {
+		if !cfg.NewsPerSymbol || len(ev.Symbols) == 0 {
+			out.SendSymbols(ev.Symbols, typ, ev)
+			return
+		}
+		for i, r := range news.Relevance(ev.Headline, ev.Symbols) {
+			one := ev
+			one.Symbol, one.Relevance = ev.Symbols[i], r
+			out.SendSymbol(one.Symbol, typ, one)
+		}
+	}
+	// Articles seen by ID (NEWS_DEDUPE_SIZE): repeats are dropped and changed articles go out as
+	// news_update with the changed fields
+	newsSeen := news.NewDedupe(cfg.NewsDedupeSize)
+	// Optional enrichment service (NEWS_ENRICH_URL): live articles are posted to it and go out with its
+	// response, or without it after NEWS_ENRICH_TIMEOUT_MS
+	enricher := news.NewEnricher(cfg.NewsEnrichURL, time.Duration(cfg.NewsEnrichTimeoutMs)*time.Millisecond, cfg.NewsEnrichMax)
+	if enricher != nil {
+		slog.Info("news enrichment enabled", "url", cfg.NewsEnrichURL, "timeout_ms", cfg.NewsEnrichTimeoutMs, "concurrency", cfg.NewsEnrichMax)
+	}
+	// Enriched articles go out from their own goroutines, so each waits for the previous version of the
+	// same article (by ID) to go out first: a news_update never overtakes its news
+	var enrichMu sync.Mutex
+	enrichLast := make(map[int64]chan struct{}) // article ID -> closed once its latest version is sent
+	newsStream.Handlers().OnNews = func(a alpaca.NewsArticle) {
+		payload := newsEvent(a)
+		payload.ReceivedTS = formatTS(time.Now())
+		typ := events.TypeNews
+		if seen, changed := newsSeen.See(a); seen {
+			if len(changed) == 0 {
+				slog.Debug("news repeat dropped", "id", a.ID, "headline", a.Headline)
+				return
+			}
+			typ, payload.Changed = events.TypeNewsUpdate, changed
+		}
+		// Before the unwatched check: news about symbols outside the stream is what expansion is for
+		if expander != nil && typ == events.TypeNews {
+			expander.Consider(a.Symbols, "news")
+		}
+		if cfg.NewsAll && !watched(a.Symbols) {
+			otherNewsOut.SendSymbols(a.Symbols, typ, payload)
+			slog.Debug("news for unwatched symbols", "id", a.ID, "symbols", strings.Join(a.Symbols, ","), "headline", a.Headline)
+			return
+		}
+		publish := func(payload events.NewsEvent) {
+			if out != nil {
+				t0 := time.Now()
+				sendNews(typ, payload)
+				slog.Debug("latency", "step", "brain_send", "type", typ, "ms", time.Since(t0).Milliseconds())
+			}
+		}
+		if enricher != nil {
+			// Off the stream goroutine, so a slow service doesn't hold up the next article
+			done := make(chan struct{})
+			enrichMu.Lock()
+			prev := enrichLast[a.ID]
+			enrichLast[a.ID] = done
+			enrichMu.Unlock()
+			go func() {
+				enrichment, err := enricher.Enrich(payload)
+				if err != nil {
+					slog.Warn("news enrichment failed; sent without", "id", a.ID, "err", err)
+				}
+				payload.Enrichment = enrichment
+				if prev != nil {
+					<-prev
+				}
+				publish(payload)
+				close(done)
+				enrichMu.Lock()
+				if enrichLast[a.ID] == done {
+					delete(enrichLast, a.ID)
+				}
+				enrichMu.Unlock()
+			}()
+		} else {
+			publish(payload)
+		}
+		if typ == events.TypeNewsUpdate {
+			slog.Info("news update", "id", a.ID, "symbols", strings.Join(a.Symbols, ","), "headline", a.Headline, "changed", payload.Changed)
+			return
+		}
+		slog.Info("news", "symbols", strings.Join(a.Symbols, ","), "headline", a.Headline, "created_at", a.CreatedAt, "source", a.Source)
+	}
+
+	ctx, stop := context.WithCancel(parent)
+	defer stop()
+
+	// New ET day: reload the market calendar and recompute the expiry context (and send expiry_day) for
+	// engines that run overnight
+	go func() {
+		defer recorder.DumpOnPanic()
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				refreshDay()
+			}
+		}
+	}()
+
+	// Lost events: every GAP_CHECK_SEC, a sink or brain pipe whose dropped, expired or discarded counts
+	// went up gets a gap event, so consumers can tell a seq jump from filtering or a quiet market
+	if out != nil && cfg.GapCheckSec > 0 {
+		go func() {
+			defer recorder.DumpOnPanic()
+			type losses struct{ dropped, expired, discarded uint64 }
+			prev := make(map[string]losses)
+			since := time.Now()
+			ticker := time.NewTicker(time.Duration(cfg.GapCheckSec) * time.Second)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case now := <-ticker.C:
+					cur := make(map[string]losses)
+					for name, st := range out.SinkStats() {
+						if name != sink.NameBrain { // per pipe below
+							cur[name] = losses{dropped: st.Dropped, expired: st.Expired}
+						}
+					}
+					for name, st := range brains.PipeStats() {
+						cur[name] = losses{dropped: st.Dropped, expired: st.Expired, discarded: st.Discarded}
+					}
+					for name, l := range cur {
+						p := prev[name]
+						if l == p {
+							continue
+						}
+						out.Send(events.TypeGap, events.GapEvent{Reason: "dropped", Sink: name, From: since.UTC().Format(time.RFC3339Nano),
+							To: now.UTC().Format(time.RFC3339Nano), GapSec: now.Sub(since).Seconds(),
+							Dropped: l.dropped - p.dropped, Expired: l.expired - p.expired, Discarded: l.discarded - p.discarded})
+					}
+					prev, since = cur, now
+				}
+			}
+		}()
+	}
+
+	// Periodic state checkpoints (STATE_CHECKPOINT_SEC)
+	if saveState != nil && cfg.StateCheckpointSec > 0 {
+		go func() {
+			defer recorder.DumpOnPanic()
+			ticker := time.NewTicker(time.Duration(cfg.StateCheckpointSec) * time.Second)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					saveState()
+				}
+			}
+		}()
+	}
+
+	// Session health for engine_stats: stream reconnects here, routed events and brain counters from the router
+	started := time.Now()
+	var reconnectMu sync.Mutex
+	reconnects := make(map[string]uint64)
+	countReconnect := func(stream string) {
+		reconnectMu.Lock()
+		reconnects[stream]++
+		reconnectMu.Unlock()
+	}
+	// Streams run in the background until ctx is done, then are closed and waited for before the final
+	// stats, so nothing publishes into state or the sinks after Run returns. ended runs each time Run
+	// returns before shutdown; the stream reconnects retry later.
+	var streams []stream
+	var streamsDone sync.WaitGroup
+	runStream := func(s stream, retry time.Duration, ended func(err error)) {
+		streams = append(streams, s)
+		streamsDone.Add(1)
+		go func() {
+			defer streamsDone.Done()
+			defer recorder.DumpOnPanic()
+			for {
+				err := s.Run()
+				if ctx.Err() != nil {
+					return
+				}
+				ended(err)
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(retry):
+				}
+			}
+		}()
+	}
+	engineStats := func(final bool) events.EngineStatsEvent {
+		st := events.EngineStatsEvent{
+			Started:    started.UTC().Format(time.RFC3339),
+			UptimeSec:  time.Since(started).Seconds(),
+			Final:      final,
+			Events:     out.EventStats(),
+			Reconnects: make(map[string]uint64),
+			Sinks:      out.SinkStats(),
+		}
+		for _, ps := range brains.PipeStats() {
+			st.Enqueued += ps.Enqueued
+			st.Sent += ps.Sent
+			st.Dropped += ps.Dropped
+			st.Discarded += ps.Discarded
+			st.PublishErrors += ps.WriteErrors
+			st.Expired += ps.Expired
+			st.BrainRestarts += ps.Restarts
+		}
+		st.StaleTicks = staleTicks.Load()
+		st.CondSkipped = condSkipped.Load()
+		for _, rs := range []alpaca.RetryStats{client.RetryStats(), tradingClient.RetryStats()} {
+			st.RESTRetries += rs.Retries
+			st.RESTLimited += rs.RateLimited
+			st.RESTGaveUp += rs.GaveUp
+		}
+		if enricher != nil {
+			st.NewsEnriched, st.NewsEnrichErr = enricher.Stats()
+		}
+		for _, d := range []*sink.Dispatcher{pnlOut, otherNewsOut} {
+			for name, ss := range d.SinkStats() {
+				st.Sinks[name] = ss
+			}
+		}
+		for _, sk := range append(append(out.Sinks(), pnlOut.Sinks()...), otherNewsOut.Sinks()...) {
+			if sk.Name() == sink.NameBrain {
+				continue // counted per pipe above
+			}
+			ss := sk.Stats()
+			st.Dropped += ss.Dropped
+			st.PublishErrors += ss.Errors
+			st.Expired += ss.Expired
+		}
+		reconnectMu.Lock()
+		for k, v := range reconnects {
+			st.Reconnects[k] = v
+		}
+		reconnectMu.Unlock()
+		return st
+	}
+	logEngineStats := func(st events.EngineStatsEvent) {
+		slog.Info("engine stats", "final", st.Final, "uptime_sec", int64(st.UptimeSec), "event_types", len(st.Events), "sent", st.Sent,
+			"dropped", st.Dropped, "discarded", st.Discarded, "expired", st.Expired, "publish_errors", st.PublishErrors, "brain_restarts", st.BrainRestarts, "reconnects", st.Reconnects, "stale_ticks", st.StaleTicks, "condition_skipped", st.CondSkipped,
+			"rest_retries", st.RESTRetries, "rest_rate_limited", st.RESTLimited, "rest_gave_up", st.RESTGaveUp)
+	}
+
+	// Exit at market close ET (default 4pm) so entrypoint can sleep until 7am then run discovery 7–9:30.
+	// AUTO_SCHEDULE stops the engine itself after post-market instead.
+	if closeHour, closeMin := parseMarketCloseET(cfg.MarketCloseET); closeHour >= 0 && !cfg.AutoSchedule {
+		go func() {
+			defer recorder.DumpOnPanic()
+			loc, err := time.LoadLocation("America/New_York")
+			if err != nil {
+				slog.Warn("market close check disabled", "err", err)
+				return
+			}
+			ticker := time.NewTicker(60 * time.Second)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					now := clk.Now().In(loc)
+					if now.Weekday() == time.Saturday || now.Weekday() == time.Sunday {
+						continue
+					}
+					if now.Hour() > closeHour || (now.Hour() == closeHour && now.Minute() >= closeMin) {
+						slog.Info("market close; exiting so entrypoint can sleep until 7am then discovery", "at_et", fmt.Sprintf("%02d:%02d", closeHour, closeMin))
+						stop()
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	// Volatility refresh every 5 min
+	go func() {
+		defer recorder.DumpOnPanic()
+		ticker := time.NewTicker(5 * time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				updateVolatility()
+			}
+		}
+	}()
+
+	// Watchlist push (WATCHLIST_PUSH): the symbols file goes to the Alpaca watchlist at startup and again
+	// whenever the scanner rewrites it, so the web UI shows what the scanner picked
+	if cfg.WatchlistPush && cfg.WatchlistName != "" && cfg.SymbolsFile != "" {
+		pusher := &watchlistPusher{client: tradingClient, name: cfg.WatchlistName, path: cfg.SymbolsFile}
+		go func() {
+			defer recorder.DumpOnPanic()
+			pusher.sync()
+			if cfg.WatchlistSyncSec <= 0 {
+				return
+			}
+			ticker := time.NewTicker(time.Duration(cfg.WatchlistSyncSec) * time.Second)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					pusher.sync()
+				}
+			}
+		}()
+	}
+
+	// Correlation matrix across tickers so the brain can avoid stacking correlated positions
+	if cfg.CorrelationIntervalMin > 0 && len(cfg.Tickers) > 1 {
+		pushCorrelation := func() {
+			bars, err := BarsWindow(provider, cfg.Tickers, cfg.CorrelationTimeframe, cfg.CorrelationWindow, clk.Now())
+			if err != nil {
+				slog.Error("correlation bars error", "err", err)
+				return
+			}
+			pairs := alpaca.CorrelationMatrix(bars, cfg.Tickers, 0)
+			payload := events.CorrelationEvent{
+				Timeframe: cfg.CorrelationTimeframe,
+				Window:    cfg.CorrelationWindow,
+				Symbols:   cfg.Tickers,
+				Pairs:     pairs,
+			}
+			if out != nil {
+				t0 := time.Now()
+				out.Send(events.TypeCorrelation, payload)
+				slog.Debug("latency", "step", "brain_send", "type", "correlation", "ms", time.Since(t0).Milliseconds())
+			}
+			slog.Info("correlation", "timeframe", cfg.CorrelationTimeframe, "pairs", len(pairs))
+		}
+		go func() {
+			defer recorder.DumpOnPanic()
+			pushCorrelation()
+			ticker := time.NewTicker(time.Duration(cfg.CorrelationIntervalMin) * time.Minute)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					pushCorrelation()
+				}
+			}
+		}()
+	}
+
+	// Standard-timeframe bars via REST ("bars_update" per symbol/timeframe with only new bars)
+	if len(cfg.BarTimeframes) > 0 {
+		barPoller := alpaca.NewBarPoller(provider, cfg.Tickers, cfg.BarTimeframes, cfg.BarsLookback)
+		barPoller.OnBars = func(symbol, timeframe string, bars []alpaca.Bar) {
+			if out != nil {
+				t0 := time.Now()
+				out.SendSymbol(symbol, events.TypeBarsUpdate, events.BarsUpdateEvent{Symbol: symbol, Timeframe: timeframe, Bars: bars})
+				slog.Debug("latency", "step", "brain_send", "type", "bars_update", "ms", time.Since(t0).Milliseconds())
+			}
+		}
+		slog.Info("bar pollers", "timeframes", cfg.BarTimeframes, "lookback", cfg.BarsLookback)
+		go barPoller.Run(ctx)
+	}
+
+	// Order dry-run for the brain: an intent is sized and run through every gateway check (hours, re-entry,
+	// budget) and the would-be outcome is returned; nothing is submitted
+	dryRun := execution.NewDryRun(sizer, orderPlacer)
+	dryRun.Position = position
+	for _, p := range brains.Pipes() {
+		execution.RegisterDryRunHandler(p, dryRun)
+	}
+
+	// Positions, open orders and the account for the brain (intervals from config); the latest are kept for
+	// the ready snapshot, the sizer and the dry run.
+	slog.Info("positions/orders interval", "sec", cfg.PositionsIntervalSec)
+	go func() {
+		defer recorder.DumpOnPanic()
+		interval := time.Duration(cfg.PositionsIntervalSec) * time.Second
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		// Previous poll for the delta events; the first poll is the baseline
+		var prevPositions []events.Position
+		var prevOrders []events.Order
+		positionsPolled, ordersPolled := false, false
+		pushPositionsAndOrders := func() {
+			t0 := time.Now()
+			positions, err := trading.GetPositions()
+			if err != nil {
+				slog.Error("trading positions error", "err", err)
+				return
+			}
+			slog.Debug("latency", "step", "alpaca_get_positions", "ms", time.Since(t0).Milliseconds())
+			if fallbackBrain != nil {
+				fallbackBrain.SyncPositions(positions)
+			}
+			if reentryGuard != nil {
+				reentryGuard.SyncPositions(positions)
+			}
+			if budget != nil {
+				budget.SyncPositions(positions)
+			}
+			if riskGuard != nil {
+				riskGuard.SyncPositions(positions)
+			}
+			if pdtGuard != nil {
+				pdtGuard.SyncPositions(positions)
+			}
+			if pnl != nil {
+				pnl.SyncPositions(positions)
+			}
+			if reconciler != nil {
+				reconciler.SyncPositions(positions)
+			}
+			if shortGuard != nil {
+				shortGuard.SyncPositions(positions)
+			}
+			posPayload := make([]events.Position, 0, len(positions))
+			for _, p := range positions {
+				posPayload = append(posPayload, events.PositionFromAlpaca(p))
+			}
+			acctMu.Lock()
+			lastPositions = posPayload
+			acctMu.Unlock()
+			if out != nil && cfg.PositionsSnapshot {
+				t0 = time.Now()
+				out.Send(events.TypePositions, events.PositionsEvent{Positions: posPayload})
+				slog.Debug("latency", "step", "brain_send", "type", "positions", "ms", time.Since(t0).Milliseconds())
+			}
+			if out != nil && cfg.PositionDeltas && positionsPolled {
+				for _, d := range positionDeltas(prevPositions, posPayload) {
+					out.SendSymbol(d.symbol, d.typ, d.payload)
+				}
+			}
+			prevPositions, positionsPolled = posPayload, true
+			t0 = time.Now()
+			orders, err := trading.GetOpenOrders()
+			if err != nil {
+				slog.Error("trading orders error", "err", err)
+				return
+			}
+			slog.Debug("latency", "step", "alpaca_get_orders", "ms", time.Since(t0).Milliseconds())
+			ordPayload := make([]events.Order, 0, len(orders))
+			for _, o := range orders {
+				ordPayload = append(ordPayload, events.OrderFromAlpaca(o))
+			}
+			if riskGuard != nil {
+				riskGuard.SyncOrders(orders)
+			}
+			if shortGuard != nil {
+				shortGuard.SyncOrders(orders)
+			}
+			acctMu.Lock()
+			lastOrders = ordPayload
+			acctMu.Unlock()
+			if out != nil && cfg.PositionsSnapshot {
+				t0 = time.Now()
+				out.Send(events.TypeOrders, events.OrdersEvent{Orders: ordPayload})
+				slog.Debug("latency", "step", "brain_send", "type", "orders", "ms", time.Since(t0).Milliseconds())
+			}
+			next := ordPayload
+			if out != nil && cfg.PositionDeltas && ordersPolled {
+				// Orders gone from the open list are looked up among the closed ones for their final status;
+				// when that fails they stay in prevOrders and are looked up again next poll
+				final := func(gone []events.Order) (map[string]events.Order, error) {
+					after := time.Now()
+					for _, o := range gone {
+						if t, err := time.Parse(time.RFC3339Nano, o.CreatedAt); err == nil && t.Before(after) {
+							after = t
+						}
+					}
+					closed, err := ordersSince(trading, "closed", after.Add(-time.Second))
+					if err != nil {
+						slog.Warn("closed orders fetch error; order deltas wait for the next poll", "orders", len(gone), "err", err)
+						return nil, err
+					}
+					byID := make(map[string]events.Order, len(closed))
+					for _, o := range closed {
+						byID[o.ID] = events.OrderFromAlpaca(o)
+					}
+					return byID, nil
+				}
+				deltas, pending := orderDeltas(prevOrders, ordPayload, final)
+				for _, d := range deltas {
+					out.SendSymbol(d.symbol, d.typ, d.payload)
+				}
+				if len(pending) > 0 {
+					next = append(append(make([]events.Order, 0, len(ordPayload)+len(pending)), ordPayload...), pending...)
+				}
+			}
+			prevOrders, ordersPolled = next, true
+			if trail != nil {
+				// All of today's orders (open and closed) so fills and cancels between polls are recorded.
+				y, m, d := time.Now().In(brain.Eastern()).Date()
+				all, err := ordersSince(trading, "all", time.Date(y, m, d, 0, 0, 0, 0, brain.Eastern()))
+				if err != nil {
+					slog.Error("compliance orders fetch error", "err", err)
+					return
+				}
+				trail.ObserveOrders(all)
+			}
+		}
+		pushPositionsAndOrders()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				pushPositionsAndOrders()
+			case <-positionsRefresh:
+				// A moment for a market order to fill; the next tick is a full interval after this poll
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(positionsRefreshDelay):
+				}
+				pushPositionsAndOrders()
+				ticker.Reset(interval)
+			}
+		}
+	}()
+
+	// Account (equity, cash, buying power, day trade count) so brain sizing sees available capital; also
+	// polled for the PDT guard when the account event is off
+	accountInterval := cfg.AccountIntervalSec
+	if accountInterval <= 0 && (pdtGuard != nil || cfg.DailyLossLimit > 0) {
+		accountInterval = 60
+	}
+	if accountInterval > 0 {
+		slog.Info("account interval", "sec", accountInterval, "events", cfg.AccountIntervalSec > 0)
+		go func() {
+			defer recorder.DumpOnPanic()
+			ticker := time.NewTicker(time.Duration(accountInterval) * time.Second)
+			defer ticker.Stop()
+			pushAccount := func() {
+				t0 := time.Now()
+				acct, err := trading.GetAccount()
+				if err != nil {
+					slog.Error("trading account error", "err", err)
+					return
+				}
+				slog.Debug("latency", "step", "alpaca_get_account", "ms", time.Since(t0).Milliseconds())
+				if pdtGuard != nil {
+					pdtGuard.SyncAccount(*acct)
+				}
+				if acct.LastEquity.Value() > 0 {
+					checkDailyLoss(acct.Equity.Value() - acct.LastEquity.Value())
+				}
+				if cfg.AccountIntervalSec <= 0 {
+					return
+				}
+				ev := events.AccountFromAlpaca(*acct)
+				acctMu.Lock()
+				lastAccount = &ev
+				acctMu.Unlock()
+				if out != nil {
+					out.Send(events.TypeAccount, ev)
+				}
+			}
+			pushAccount()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					pushAccount()
+				}
+			}
+		}()
+	}
+
+	// Daily P&L from the broker's portfolio history: day P&L, the intraday equity curve and drawdown, for the
+	// brain and dashboards alike
+	if cfg.DailyPnLIntervalSec > 0 && trading.Name() == "alpaca" && out != nil {
+		slog.Info("daily pnl interval", "sec", cfg.DailyPnLIntervalSec, "timeframe", cfg.DailyPnLTimeframe)
+		go func() {
+			defer recorder.DumpOnPanic()
+			ticker := time.NewTicker(time.Duration(cfg.DailyPnLIntervalSec) * time.Second)
+			defer ticker.Stop()
+			pushDailyPnL := func() {
+				h, err := tradingClient.GetPortfolioHistory("1D", cfg.DailyPnLTimeframe, true)
+				if err != nil {
+					slog.Error("portfolio history error", "err", err)
+					return
+				}
+				out.Send(events.TypeDailyPnL, dailyPnL(h, clk.Now()))
+			}
+			pushDailyPnL()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					pushDailyPnL()
+				}
+			}
+		}()
+	}
+
+	// Account trade updates: fills/partial fills to the brain as they happen, the compliance trail, and the
+	// order chaser for resting limit orders
+	if cfg.TradeUpdates {
+		var chaser *execution.Chaser
+		if cfg.OrderChase != execution.ChaseOff {
+			chaser = execution.NewChaser(execution.ChaseConfig{
+				Action:      cfg.OrderChase,
+				Timeout:     time.Duration(cfg.OrderChaseTimeoutSec) * time.Second,
+				MaxReprices: cfg.OrderChaseMaxReprices,
+				// The Python brain's own orders carry ev:<correlation ID>:<nonce>
+				ClientOrderPrefixes: []string{"ev:", execution.ClientOrderPrefix, fallback.ClientOrderPrefix},
+			}, trading, orderPlacer, func(symbol string) (float64, float64, bool) {
+				q, ok := state.LastQuote(symbol)
+				return q.Bid, q.Ask, ok
+			})
+			chaser.SetClock(clk)
+			chaser.OnAction = func(ev events.OrderChaseEvent) {
+				if out != nil {
+					out.Send(events.TypeOrderChase, ev)
+				}
+			}
+			slog.Info("order chase enabled", "action", cfg.OrderChase, "timeout_sec", cfg.OrderChaseTimeoutSec)
+			go chaser.Run(ctx)
+		}
+		tradeUpdates := trading.TradeUpdates(func(u alpaca.TradeUpdate) {
+			correlationID := correlations.OnTradeUpdate(u)
+			if out != nil {
+				t0 := time.Now()
+				ev := events.TradeUpdateFromAlpaca(u)
+				ev.CorrelationID = correlationID
+				out.Send(events.TypeTradeUpdate, ev)
+				slog.Debug("latency", "step", "brain_send", "type", "trade_update", "ms", time.Since(t0).Milliseconds())
+			}
+			if trail != nil {
+				trail.ObserveOrders([]alpaca.Order{u.Order})
+			}
+			auditLog.OnTradeUpdate(u, correlationID)
+			if reentryGuard != nil {
+				reentryGuard.OnTradeUpdate(u)
+			}
+			if pnl != nil {
+				pnl.OnTradeUpdate(u)
+			}
+			if budget != nil {
+				budget.OnTradeUpdate(u)
+			}
+			if riskGuard != nil {
+				riskGuard.OnTradeUpdate(u)
+			}
+			if pdtGuard != nil {
+				pdtGuard.OnTradeUpdate(u)
+			}
+			if chaser != nil {
+				chaser.OnTradeUpdate(u)
+			}
+			if reconciler != nil {
+				reconciler.OnTradeUpdate(u)
+			}
+			if shortGuard != nil {
+				shortGuard.OnTradeUpdate(u)
+			}
+			slog.Info("trade update", "event", u.Event, "symbol", u.Order.Symbol, "side", u.Order.Side,
+				"filled_qty", u.Order.FilledQty, "qty", u.Order.Qty, "order_id", u.Order.ID)
+		})
+		runStream(tradeUpdates, 5*time.Second, func(err error) {
+			if err != nil {
+				slog.Error("trade updates stream ended", "err", err)
+			}
+			slog.Info("reconnecting trade updates stream in 5s")
+			countReconnect("trade_updates")
+		})
+	}
+
+	// Periodic risk report: entry budget usage next to its limits
+	if out != nil && budget != nil && cfg.RiskReportIntervalSec > 0 {
+		go func() {
+			defer recorder.DumpOnPanic()
+			ticker := time.NewTicker(time.Duration(cfg.RiskReportIntervalSec) * time.Second)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					usage := budget.Usage()
+					out.Send(events.TypeRiskReport, events.RiskReportEvent{Budget: &usage})
+					slog.Debug("risk report", "open_positions", usage.OpenPositions, "entries_last_hour", usage.EntriesLastHour,
+						"entries_today", usage.EntriesToday, "capital_last_hour", usage.CapitalLastHour, "rejected", usage.Rejected)
+				}
+			}
+		}()
+	}
+
+	// Hourly engine_stats summary (and once more at shutdown)
+	if cfg.EngineStatsIntervalMin > 0 {
+		go func() {
+			defer recorder.DumpOnPanic()
+			ticker := time.NewTicker(time.Duration(cfg.EngineStatsIntervalMin) * time.Minute)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					st := engineStats(false)
+					out.Send(events.TypeEngineStats, st)
+					logEngineStats(st)
+				}
+			}
+		}()
+	}
+
+	// Trial and scanned symbols with a position or open order stay until they are flat
+	holding := func(symbol string) bool {
+		acctMu.Lock()
+		defer acctMu.Unlock()
+		for _, p := range lastPositions {
+			if p.Symbol == symbol {
+				return true
+			}
+		}
+		for _, o := range lastOrders {
+			if o.Symbol == symbol {
+				return true
+			}
+		}
+		return false
+	}
+	if expander != nil {
+		expander.Keep = holding
+		go expander.Run(ctx)
+	}
+
+	// Dynamic universe (UNIVERSE_SCAN): the best of Alpaca's movers and most-actives screens, ranked by
+	// volume and range, are streamed next to the configured symbols and replaced as the screens change
+	if cfg.UniverseScan && cfg.UniverseScanMin > 0 {
+		scanner := universe.NewScanner(universe.ScannerConfig{
+			Interval:   time.Duration(cfg.UniverseScanMin) * time.Minute,
+			Top:        cfg.UniverseScanTop,
+			MaxSymbols: cfg.UniverseScanMax,
+			MinPrice:   cfg.UniverseScanMinPrice,
+			MinVolume:  uint64(cfg.UniverseScanMinVolume),
+		}, client, priceStream, provider.GetSnapshots)
+		scanner.Keep = holding
+		scanner.OnChange = func(ev events.UniverseEvent) { out.Send(events.TypeUniverse, ev) }
+		go scanner.Run(ctx)
+		slog.Info("universe scanner enabled", "interval_min", cfg.UniverseScanMin, "max", cfg.UniverseScanMax)
+	}
+
+	// Operator control, from the control endpoint and the command stream: pause withholds market data and
+	// signals from the brain, resume undoes a pause and releases the kill switch, flatten cancels open
+	// orders and closes every position, and kill engages the kill switch, flattens and halts the engine
+	// (flatten and halt can be turned off per call). Every action is audited as a control event.
+	var ctlMu sync.Mutex
+	var paused, halted atomic.Bool
+	flattenAccount := func() error {
+		defer refreshPositions()
+		if err := trading.CancelAllOrders(); err != nil {
+			return fmt.Errorf("cancel orders: %w", err)
+		}
+		if err := trading.CloseAllPositions(); err != nil {
+			return fmt.Errorf("close positions: %w", err)
+		}
+		return nil
+	}
+	controlAction := func(action, source string, p control.Params) (interface{}, error) {
+		ctlMu.Lock()
+		defer ctlMu.Unlock()
+		ev := events.ControlEvent{Action: action, Source: source, Reason: p.Reason}
+		var err error
+		switch action {
+		case "pause":
+			paused.Store(true)
+			brains.Mute("pause", inboundTypes...)
+		case "resume":
+			paused.Store(false)
+			if killSwitch.Release() {
+				slog.Warn("kill switch released")
+			}
+			brains.Unmute("pause")
+			out.Send(events.TypeKillSwitch, events.KillSwitchEvent{Source: source})
+		case "flatten":
+			err = flattenAccount()
+			ev.Flattened = err == nil
+		case "kill":
+			if ev.Reason == "" {
+				ev.Reason = "kill " + source
+			}
+			// Over HTTP kill defaults to flattening and halting. A command stream kill keeps its original
+			// meaning, stop new orders, so existing senders don't liquidate by surprise.
+			dflt := source != "command"
+			flatten, halt := dflt, dflt
+			if p.Flatten != nil {
+				flatten = *p.Flatten
+			}
+			if p.Halt != nil {
+				halt = *p.Halt
+			}
+			if killSwitch.Engage(ev.Reason) {
+				slog.Warn("kill switch engaged", "reason", ev.Reason, "flatten", flatten, "halt", halt)
+			}
+			if flatten {
+				err = flattenAccount()
+				ev.Flattened = err == nil
+			}
+			out.Send(events.TypeKillSwitch, events.KillSwitchEvent{Engaged: true, Reason: ev.Reason, Source: source, Flatten: ev.Flattened})
+			if halt {
+				// A moment for the reply and the events to go out; the shutdown flushes the sinks
+				ev.Halting = true
+				halted.Store(true)
+				time.AfterFunc(time.Second, stop)
+			}
+		default:
+			err = fmt.Errorf("unknown control action %q", action)
+		}
+		ev.Paused = paused.Load()
+		ev.KillSwitch, _ = killSwitch.Engaged()
+		ev.OK = err == nil
+		if err != nil {
+			ev.Error = err.Error()
+		}
+		slog.Warn("control action", "action", action, "source", source, "reason", ev.Reason, "ok", ev.OK, "err", ev.Error)
+		rec := audit.Record{Kind: audit.KindControl, Source: source, Action: action, Outcome: "ok", Reason: ev.Reason}
+		if err != nil {
+			rec.Outcome, rec.Detail = "error", ev.Error
+		}
+		auditLog.Record(rec)
+		out.Send(events.TypeControl, ev)
+		return ev, err
+	}
+	controlStatus := func() interface{} {
+		engaged, reason := killSwitch.Engaged()
+		return events.ControlStatus{Paused: paused.Load(), KillSwitch: engaged, KillReason: reason}
+	}
+
+	// Command stream: the brain's write path back through the engine. Orders go through the gateway like
+	// brain order requests; results come back as command_result events.
+	if cfg.CommandsStream != "" && cfg.CommandsRedisURL != "" {
+		if commands, err := command.NewConsumer(cfg.CommandsRedisURL, cfg.CommandsStream); err != nil {
+			slog.Error("command stream disabled", "err", err)
+		} else {
+			commands.OnResult = func(ev events.CommandResultEvent) { out.Send(events.TypeCommandResult, ev) }
+			commands.Handle("order", func(raw json.RawMessage) (interface{}, error) {
+				return gateway.Submit("command", raw)
+			})
+			commands.Handle("cancel", func(raw json.RawMessage) (interface{}, error) {
+				var p struct {
+					OrderID string `json:"order_id"`
+				}
+				if err := json.Unmarshal(raw, &p); err != nil || p.OrderID == "" {
+					return nil, errors.New("order_id required")
+				}
+				if err := trading.CancelOrder(p.OrderID); err != nil {
+					return nil, err
+				}
+				refreshPositions()
+				return nil, nil
+			})
+			changeSymbols := func(subscribe bool) brain.Handler {
+				return func(raw json.RawMessage) (interface{}, error) {
+					var p struct {
+						Symbols []string `json:"symbols"`
+					}
+					if err := json.Unmarshal(raw, &p); err != nil || len(p.Symbols) == 0 {
+						return nil, errors.New("symbols required")
+					}
+					for i, s := range p.Symbols {
+						p.Symbols[i] = strings.ToUpper(strings.TrimSpace(s))
+					}
+					ev := events.UniverseEvent{Reason: "command"}
+					var err error
+					if subscribe {
+						ev.Added, err = p.Symbols, priceStream.Subscribe(p.Symbols)
+					} else {
+						ev.Removed, err = p.Symbols, priceStream.Unsubscribe(p.Symbols)
+					}
+					if err != nil {
+						return nil, err
+					}
+					ev.Symbols = priceStream.Symbols()
+					out.Send(events.TypeUniverse, ev)
+					return ev, nil
+				}
+			}
+			commands.Handle("subscribe", changeSymbols(true))
+			commands.Handle("unsubscribe", changeSymbols(false))
+			for _, action := range control.Actions {
+				action := action
+				commands.Handle(action, func(raw json.RawMessage) (interface{}, error) {
+					var p control.Params
+					if len(raw) > 0 {
+						if err := json.Unmarshal(raw, &p); err != nil {
+							return nil, fmt.Errorf("bad params: %w", err)
+						}
+					}
+					return controlAction(action, "command", p)
+				})
+			}
+			go func() {
+				defer recorder.DumpOnPanic()
+				commands.Run(ctx)
+			}()
+			slog.Info("command stream enabled", "stream", cfg.CommandsStream)
+		}
+	}
+
+	// Control endpoint: the operator actions over local HTTP
+	if cfg.ControlListenAddr != "" {
+		if srv, err := control.NewServer(cfg.ControlListenAddr, cfg.ControlToken, controlAction, controlStatus); err != nil {
+			slog.Error("control endpoint disabled", "addr", cfg.ControlListenAddr, "err", err)
+		} else {
+			go func() {
+				defer recorder.DumpOnPanic()
+				srv.Run(ctx)
+			}()
+			slog.Info("control endpoint enabled", "addr", srv.Addr())
+		}
+	}
+
+	// Signal webhook: TradingView alerts and other outside systems post signals that reach the brain as
+	// external_signal events, through the same dispatcher as market data.
+	if cfg.WebhookListenAddr != "" {
+		if hook, err := webhook.NewServer(cfg.WebhookListenAddr, cfg.WebhookToken); err != nil {
+			slog.Error("signal webhook disabled", "addr", cfg.WebhookListenAddr, "err", err)
+		} else {
+			hook.OnSignal = func(ev events.ExternalSignalEvent) {
+				// An alert about a symbol outside the stream is an anomaly worth a trial
+				if expander != nil {
+					expander.Consider([]string{ev.Symbol}, "external_signal")
+				}
+				out.SendSymbol(ev.Symbol, events.TypeExternalSignal, ev)
+			}
+			go func() {
+				defer recorder.DumpOnPanic()
+				hook.Run(ctx)
+			}()
+			slog.Info("signal webhook enabled", "addr", hook.Addr())
+		}
+	}
+
+	// Idle-symbol eviction: at IDLE_EVICT_AT (ET) unsubscribe symbols that traded less than
+	// IDLE_EVICT_MIN_VOLUME shares today, so stream quota and CPU go to names that are moving. Symbols
+	// with a position or open order are kept. Volume is today's daily bar from the snapshots, which counts
+	// trades from before the engine started (or subscribed); the stream's count stands in when they fail,
+	// unless the engine started after the eviction time and missed part of the day.
+	if evictHour, evictMin := parseMarketCloseET(cfg.IdleEvictAt); evictHour >= 0 {
+		evictIdle := func(now time.Time, late bool) {
+			held := make(map[string]bool)
+			acctMu.Lock()
+			for _, p := range lastPositions {
+				held[p.Symbol] = true
+			}
+			for _, o := range lastOrders {
+				held[o.Symbol] = true
+			}
+			acctMu.Unlock()
+			symbols := priceStream.Symbols()
+			snaps, err := provider.GetSnapshots(symbols)
+			if err != nil {
+				if late {
+					slog.Warn("idle eviction skipped: snapshots failed and the stream missed the start of the day", "err", err)
+					return
+				}
+				slog.Warn("idle eviction: snapshots failed; using volume seen on the stream", "err", err)
+			}
+			today := now.Format("2006-01-02")
+			var idle []string
+			var total int64
+			for _, sym := range symbols {
+				v := state.DayVolume(sym)
+				if bar := snaps[sym].DailyBar; barDate(bar) == today && int64(bar.Volume) > v {
+					v = int64(bar.Volume)
+				}
+				total += v
+				if v < cfg.IdleEvictMinVolume && !held[sym] {
+					idle = append(idle, sym)
+				}
+			}
+			if total == 0 {
+				slog.Warn("idle eviction skipped: no volume today")
+				return
+			}
+			if len(idle) == 0 {
+				slog.Info("idle eviction: every symbol is trading", "min_volume", cfg.IdleEvictMinVolume)
+				return
+			}
+			if err := priceStream.Unsubscribe(idle); err != nil {
+				slog.Warn("idle eviction unsubscribe failed; symbols drop on reconnect", "err", err)
+			}
+			active := priceStream.Symbols()
+			out.Send(events.TypeUniverse, events.UniverseEvent{Symbols: active, Removed: idle, Reason: "idle"})
+			slog.Info("idle symbols evicted", "removed", idle, "active", len(active), "min_volume", cfg.IdleEvictMinVolume)
+		}
+		go func() {
+			defer recorder.DumpOnPanic()
+			evictAt := evictHour*60 + evictMin
+			past := func(now time.Time) bool { return now.Hour()*60+now.Minute() >= evictAt }
+			var done string // ET date evicted
+			var late string // ET date the engine started after the eviction time
+			if now := clk.Now().In(brain.Eastern()); past(now) {
+				late = now.Format("2006-01-02")
+			}
+			ticker := time.NewTicker(time.Minute)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					now := clk.Now().In(brain.Eastern())
+					day := now.Format("2006-01-02")
+					if day == done || !past(now) || now.Weekday() == time.Saturday || now.Weekday() == time.Sunday {
+						continue
+					}
+					done = day
+					evictIdle(now, day == late)
+				}
+			}
+		}()
+	}
+
+	// Pre-market gap scan at GAP_SCAN_AT (ET) on trading days: the streamed symbols gapping at least
+	// GAP_SCAN_MIN_PCT from the previous close, as one gap_scan event. An engine started after that time
+	// scans at once if the market hasn't opened yet.
+	if scanHour, scanMin := parseMarketCloseET(cfg.GapScanAt); scanHour >= 0 {
+		go func() {
+			defer recorder.DumpOnPanic()
+			scanAt := scanHour*60 + scanMin
+			due := func(now time.Time) bool {
+				m := now.Hour()*60 + now.Minute()
+				return m >= scanAt && m < 9*60+30 && brain.Session(now) != brain.SessionClosed
+			}
+			var done string // ET date scanned
+			scan := func() {
+				now := clk.Now().In(brain.Eastern())
+				day := now.Format("2006-01-02")
+				if day == done || !due(now) {
+					return
+				}
+				done = day
+				ev := gapScan(provider, priceStream.Symbols(), clk.Now(), cfg.GapScanMinPct)
+				out.Send(events.TypeGapScan, ev)
+				slog.Info("gap scan", "scanned", ev.Scanned, "gapping", len(ev.Gaps), "min_pct", cfg.GapScanMinPct)
+			}
+			scan()
+			ticker := time.NewTicker(time.Minute)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					scan()
+				}
+			}
+		}()
+	}
+
+	// Email digest at DIGEST_AT (ET) on weekdays, covering everything since the previous digest or the
+	// start. An engine started after that time sends its first digest the next weekday. An engine that
+	// stops before the day's digest went out (at MARKET_CLOSE_ET, 16:00 by default) sends it at shutdown.
+	var digestStopped chan struct{}
+	if digestHour, digestMin := parseMarketCloseET(cfg.DigestAt); digest != nil && digestHour >= 0 {
+		digestStopped = make(chan struct{})
+		go func() {
+			defer close(digestStopped)
+			defer recorder.DumpOnPanic()
+			digestAt := digestHour*60 + digestMin
+			past := func(now time.Time) bool { return now.Hour()*60+now.Minute() >= digestAt }
+			var done string // ET date the digest went out (or was skipped because the engine started late)
+			if now := clk.Now().In(brain.Eastern()); past(now) {
+				done = now.Format("2006-01-02")
+			}
+			send := func(now time.Time) {
+				done = now.Format("2006-01-02")
+				if err := digest.Send(mailer, now); err != nil {
+					slog.Error("email digest failed", "err", err)
+				} else {
+					slog.Info("email digest sent", "to", cfg.DigestTo)
+				}
+			}
+			ticker := time.NewTicker(time.Minute)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					if now := clk.Now().In(brain.Eastern()); now.Format("2006-01-02") != done {
+						send(now)
+					}
+					return
+				case <-ticker.C:
+					now := clk.Now().In(brain.Eastern())
+					if now.Format("2006-01-02") == done || !past(now) || now.Weekday() == time.Saturday || now.Weekday() == time.Sunday {
+						continue
+					}
+					send(now)
+				}
+			}
+		}()
+	} else if digest != nil {
+		slog.Error("email digest not scheduled: DIGEST_AT must be HH:MM", "digest_at", cfg.DigestAt)
+	}
+
+	// Initial snapshot for every brain that reports ready (start and restarts)
+	if brains != nil {
+		brains.SetSnapshot(func(p *brain.Pipe, owns func(string) bool) []events.Envelope {
+			vs := state.VolSnapshot()
+			snap := events.SnapshotEvent{Brain: p.Name(), VolVersion: vs.Version}
+			for _, sym := range priceStream.Symbols() {
+				if !owns(sym) {
+					continue
+				}
+				snap.Symbols = append(snap.Symbols, sym)
+				if v, ok := volatilityEvent(vs, sym); ok {
+					snap.Volatility = append(snap.Volatility, v)
+				}
+				lp := events.LastPrice{Symbol: sym}
+				st := state.Snapshot(sym)
+				if st.LastPrice > 0 {
+					lp.Price, lp.PriceTime = st.LastPrice, st.LastTime.UTC().Format(time.RFC3339Nano)
+				}
+				if st.Quote != nil {
+					lp.Bid, lp.Ask = st.Quote.Bid, st.Quote.Ask
+				}
+				if lp.Price > 0 || lp.Bid > 0 || lp.Ask > 0 {
+					snap.Prices = append(snap.Prices, lp)
+				}
+			}
+			acctMu.Lock()
+			snap.Positions, snap.Orders, snap.Account = lastPositions, lastOrders, lastAccount
+			snap.ExpiryContext = expiryToday.Load().(string)
+			acctMu.Unlock()
+			evs := []events.Envelope{{Type: events.TypeSnapshot, Payload: snap}}
+			if cfg.FeatureVectors {
+				evs = append(evs, events.Envelope{Type: events.TypeFeatureSchema, Payload: events.FeatureSchemaEvent{Version: brain.FeatureSchemaVersion, Names: brain.FeatureNames}})
+			}
+			return evs
+		})
+	}
+
+	// News backfill: replay the last hours of news (pre-market catalysts) before live news starts, so an
+	// engine restart mid-session doesn't leave the brain blind to what moved the tape
+	if out != nil && cfg.NewsBackfillHours > 0 {
+		since := time.Now().Add(-time.Duration(cfg.NewsBackfillHours) * time.Hour)
+		t0 := time.Now()
+		articles, err := provider.GetNewsSince(cfg.Tickers, since, cfg.NewsBackfillMax)
+		if err != nil {
+			slog.Warn("news backfill incomplete", "articles", len(articles), "err", err)
+		}
+		// Articles the stream already delivered are skipped, whichever version is newer
+		duplicates := 0
+		for _, a := range articles {
+			if seen, _ := newsSeen.See(a); seen {
+				duplicates++
+				continue
+			}
+			payload := newsEvent(a)
+			payload.Backfill = true
+			sendNews(events.TypeNews, payload)
+		}
+		slog.Info("news backfill sent", "articles", len(articles)-duplicates, "duplicates", duplicates, "hours", cfg.NewsBackfillHours, "ms", time.Since(t0).Milliseconds())
+	}
+
+	// Gap recovery: when the price or news stream comes back after an outage of GAP_RECOVERY_SEC or more,
+	// what was missed (price change, volume, news) is fetched from REST and sent as one gap_recovery event.
+	// The outage starts at the last message the stream delivered (or its connect, if it delivered none),
+	// not when its Run returned, which can be much later on a dead connection.
+	lastRecv := map[string]*atomic.Int64{"price": new(atomic.Int64), "news": new(atomic.Int64)} // unix nanos
+	ph := priceStream.Handlers()
+	onTrade, onQuote := ph.OnTrade, ph.OnQuote
+	ph.OnTrade = func(tr alpaca.StreamTrade) {
+		lastRecv["price"].Store(tr.Received.UnixNano())
+		onTrade(tr)
+	}
+	ph.OnQuote = func(symbol string, bid, ask float64, bidSize, askSize int, t, received time.Time) {
+		lastRecv["price"].Store(received.UnixNano())
+		onQuote(symbol, bid, ask, bidSize, askSize, t, received)
+	}
+	onNews := newsStream.Handlers().OnNews
+	newsStream.Handlers().OnNews = func(a alpaca.NewsArticle) {
+		lastRecv["news"].Store(time.Now().UnixNano())
+		onNews(a)
+	}
+	var gapMu sync.Mutex
+	downSince := make(map[string]time.Time)
+	streamDown := func(stream string) {
+		from := time.Now()
+		if n := lastRecv[stream].Load(); n > 0 && n < from.UnixNano() {
+			from = time.Unix(0, n)
+		}
+		gapMu.Lock()
+		if downSince[stream].IsZero() {
+			downSince[stream] = from
+		}
+		gapMu.Unlock()
+	}
+	// streamUp runs on the stream's goroutine before it reads anything, so the gap and its recovery reach
+	// the brain ahead of the first live tick or article
+	streamUp := func(stream string) {
+		gapMu.Lock()
+		from := downSince[stream]
+		delete(downSince, stream)
+		gapMu.Unlock()
+		to := time.Now()
+		lastRecv[stream].Store(to.UnixNano())
+		if from.IsZero() {
+			return
+		}
+		// Whatever the stream sent while down is lost: say so right away, whatever the length
+		out.Send(events.TypeGap, events.GapEvent{Reason: "reconnect", Stream: stream, From: from.UTC().Format(time.RFC3339Nano),
+			To: to.UTC().Format(time.RFC3339Nano), GapSec: to.Sub(from).Seconds()})
+		if cfg.GapRecoverySec <= 0 || to.Sub(from) < time.Duration(cfg.GapRecoverySec)*time.Second {
+			return
+		}
+		// Last prices before the gap, taken before the reconnected stream updates them
+		symbols := priceStream.Symbols()
+		before := make(map[string]float64, len(symbols))
+		for _, sym := range symbols {
+			if p := state.Snapshot(sym).LastPrice; p > 0 {
+				before[sym] = p
+			}
+		}
+		t0 := time.Now()
+		ev := gapRecovery(provider, stream, symbols, before, from, to, newsEvent)
+		out.Send(events.TypeGapRecovery, ev)
+		slog.Info("gap recovery sent", "stream", stream, "gap_sec", int64(ev.GapSec), "symbols", len(ev.Symbols), "news", len(ev.News), "ms", time.Since(t0).Milliseconds())
+	}
+	priceStream.Handlers().OnConnect = func() { streamUp("price") }
+	newsStream.Handlers().OnConnect = func() { streamUp("news") }
+
+	// Run price stream in background (reconnect on error for resilience)
+	runStream(priceStream, 5*time.Second, func(err error) {
+		if err != nil {
+			slog.Error("price stream ended", "err", err)
+		}
+		streamDown("price")
+		slog.Info("reconnecting price stream in 5s")
+		countReconnect("price")
+	})
+
+	// Options IV context (OPTIONS_UNDERLYINGS): every refresh pulls each underlying's near-the-money chain
+	// and sends implied_vol (ATM term structure next to the realized vol); the ATM call and put of the
+	// nearest expirations are streamed as option_quote events.
+	if len(cfg.OptionsUnderlyings) > 0 {
+		var optionStream *alpaca.OptionStream
+		var contractsMu sync.Mutex
+		contracts := make(map[string]streamedContract)
+		if cfg.OptionsStreamExpiries > 0 {
+			optionStream = alpaca.NewOptionStream(cfg.StreamWSURL, cfg.APIKeyID, cfg.APISecretKey, cfg.OptionsFeed)
+			optionStream.OnQuote = func(q alpaca.StreamOptionQuote) {
+				contractsMu.Lock()
+				c, ok := contracts[q.Symbol]
+				contractsMu.Unlock()
+				if !ok {
+					return
+				}
+				out.SendSymbol(c.Underlying, events.TypeOptionQuote, events.OptionQuoteEvent{
+					Symbol:     c.Underlying,
+					Contract:   c.Symbol,
+					Expiry:     c.Expiry.Format("2006-01-02"),
+					Right:      c.Right,
+					Strike:     c.Strike,
+					Bid:        q.Bid,
+					Ask:        q.Ask,
+					BidSize:    q.BidSize,
+					AskSize:    q.AskSize,
+					Mid:        (q.Bid + q.Ask) / 2,
+					IV:         c.iv,
+					Delta:      c.delta,
+					ExchangeTS: formatTS(q.Time),
+					ReceivedTS: formatTS(q.Received),
+				})
+			}
+			runStream(optionStream, 30*time.Second, func(err error) {
+				if err != nil {
+					slog.Warn("option stream ended", "feed", cfg.OptionsFeed, "err", err)
+				}
+				countReconnect("options")
+			})
+		}
+		refreshOptions := func() {
+			now := clk.Now().In(brain.Eastern())
+			vs := state.VolSnapshot()
+			next := make(map[string]streamedContract)
+			var stream []string
+			for _, u := range cfg.OptionsUnderlyings {
+				spot := state.Snapshot(u).LastPrice
+				if spot <= 0 {
+					if snaps, err := provider.GetSnapshots([]string{u}); err == nil && snaps[u].LatestTrade != nil {
+						spot = snaps[u].LatestTrade.Price
+					}
+				}
+				if spot <= 0 {
+					slog.Warn("option chain skipped: no spot price", "symbol", u)
+					continue
+				}
+				chain, err := client.GetOptionChain(u, alpaca.OptionChainFilter{
+					Feed:          cfg.OptionsFeed,
+					ExpirationGTE: now,
+					ExpirationLTE: now.AddDate(0, 0, cfg.OptionsMaxDays),
+					StrikeGTE:     spot * (1 - cfg.OptionsStrikePct),
+					StrikeLTE:     spot * (1 + cfg.OptionsStrikePct),
+				})
+				if err != nil {
+					slog.Warn("option chain", "symbol", u, "err", err)
+					continue
+				}
+				term := alpaca.ATMTermStructure(chain, spot, now)
+				if len(term) == 0 {
+					slog.Warn("option chain has no implied volatility", "symbol", u, "contracts", len(chain))
+					continue
+				}
+				ev := impliedVolEvent(u, spot, term, vs.Volatility(u))
+				ev.Contracts, ev.Feed = len(chain), cfg.OptionsFeed
+				out.SendSymbol(u, events.TypeImpliedVol, ev)
+				for i, p := range term {
+					if i >= cfg.OptionsStreamExpiries {
+						break
+					}
+					for _, sym := range []string{p.Call, p.Put} {
+						c, err := alpaca.ParseOptionSymbol(sym)
+						if err != nil {
+							continue
+						}
+						sc := streamedContract{OptionContract: c, iv: chain[sym].ImpliedVolatility}
+						if g := chain[sym].Greeks; g != nil {
+							sc.delta = g.Delta
+						}
+						next[sym] = sc
+						stream = append(stream, sym)
+					}
+				}
+			}
+			if optionStream == nil {
+				return
+			}
+			contractsMu.Lock()
+			contracts = next
+			contractsMu.Unlock()
+			if err := optionStream.SetSymbols(stream); err != nil {
+				slog.Warn("option stream subscription", "err", err)
+			}
+		}
+		slog.Info("options", "underlyings", cfg.OptionsUnderlyings, "feed", cfg.OptionsFeed, "interval_sec", cfg.OptionsIntervalSec,
+			"stream_expiries", cfg.OptionsStreamExpiries)
+		go func() {
+			defer recorder.DumpOnPanic()
+			interval := time.Duration(cfg.OptionsIntervalSec) * time.Second
+			if interval <= 0 {
+				interval = 5 * time.Minute
+			}
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				refreshOptions()
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}()
+	}
+
+	// Other feed for the comparison sample: only measured, never sent on. Needs a SIP subscription; an
+	// unentitled account gets a stream error every retry and the report shows the primary feed alone.
+	if feedCompare != nil {
+		otherFeed := "sip"
+		if cfg.DataFeed == "sip" {
+			otherFeed = "iex"
+		}
+		compareStream := alpaca.NewPriceStream(cfg.StreamWSURL, cfg.APIKeyID, cfg.APISecretKey, otherFeed, feedCompare.Symbols())
+		compareStream.OnTrade = func(tr alpaca.StreamTrade) {
+			feedCompare.Trade(otherFeed, tr.Symbol, tr.Size, tr.Time, tr.Received)
+		}
+		compareStream.OnQuote = func(symbol string, bid, ask float64, _, _ int, t, received time.Time) {
+			feedCompare.Quote(otherFeed, symbol, bid, ask, t, received)
+		}
+		slog.Info("feed comparison", "primary", cfg.DataFeed, "other", otherFeed, "symbols", feedCompare.Symbols(), "interval_sec", cfg.FeedCompareIntervalSec)
+		runStream(compareStream, 30*time.Second, func(err error) {
+			if err != nil {
+				slog.Warn("feed comparison stream ended", "feed", otherFeed, "err", err)
+			}
+		})
+		go func() {
+			defer recorder.DumpOnPanic()
+			interval := time.Duration(cfg.FeedCompareIntervalSec) * time.Second
+			if interval <= 0 {
+				interval = time.Minute
+			}
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					report := feedCompare.Report()
+					if len(report) == 0 {
+						continue
+					}
+					for _, d := range report {
+						slog.Info("feed divergence", "symbol", d.Symbol, "mid_diff_bps_avg", d.MidDiffBpsAvg, "same_quote_pct", d.SameQuotePct,
+							"lag_ms_diff", d.LagMsDiff, "volume_share", d.VolumeShare)
+					}
+					out.Send(events.TypeFeedCompare, events.FeedCompareEvent{WindowSec: int(interval.Seconds()), Primary: cfg.DataFeed, Symbols: report})
+				}
+			}
+		}()
+	}
+
+	// Run news stream in background
+	runStream(newsStream, 5*time.Second, func(err error) {
+		if err != nil {
+			slog.Error("news stream ended", "err", err)
+		}
+		streamDown("news")
+		slog.Info("reconnecting news stream in 5s")
+		countReconnect("news")
+	})
+
+	<-ctx.Done()
+	for _, s := range streams {
+		_ = s.Close()
+	}
+	streamsDone.Wait()
+	if saveState != nil {
+		saveState()
+	}
+	// The final stats are written before shutdown goes on: behind a backlog they could be dropped from a
+	// full queue or cut off by the close timeout
+	final := engineStats(true)
+	out.Send(events.TypeEngineStats, final)
+	if !out.Flush(statsFlushTimeout) {
+		slog.Warn("final engine_stats not written to every sink", "timeout", statsFlushTimeout)
+	}
+	logEngineStats(final)
+	if digestStopped != nil {
+		<-digestStopped
+	}
+	for name, st := range brains.PipeStats() {
+		slog.Info("brain pipe stats", "name", name, "enqueued", st.Enqueued, "sent", st.Sent, "dropped", st.Dropped, "discarded", st.Discarded, "write_errors", st.WriteErrors,
+			"buffered", st.Buffered, "replayed", st.Replayed, "expired", st.Expired, "restarts", st.Restarts)
+	}
+	for name, st := range out.SinkStats() {
+		slog.Info("sink stats", "name", name, "published", st.Published, "dropped", st.Dropped, "errors", st.Errors, "healthy", st.Healthy,
+			"batches", st.Batches, "avg_batch", st.AvgBatch, "avg_write_ms", st.AvgWriteMs, "max_write_ms", st.MaxWriteMs, "expired", st.Expired)
+	}
+	slog.Info("stopping")
+	if halted.Load() {
+		return ErrKilled
+	}
+	return nil
+}
@@ -0,0 +1,560 @@
+package engine
+
+import (
+	"log/slog"
+	"math"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sunnyp94/sentry-bridge/go-engine/alpaca"
+	"github.com/sunnyp94/sentry-bridge/go-engine/brain"
+	"github.com/sunnyp94/sentry-bridge/go-engine/broker"
+	"github.com/sunnyp94/sentry-bridge/go-engine/config"
+	"github.com/sunnyp94/sentry-bridge/go-engine/events"
+	"github.com/sunnyp94/sentry-bridge/go-engine/execution"
+	"github.com/sunnyp94/sentry-bridge/go-engine/marketdata"
+)
+
+// BarsWindow fetches the newest n bars of timeframe for each symbol. A multi-symbol GetBars limit is
+// shared across the symbols, so it asks for an explicit window instead: from a start far enough back for
+// n bars with nights, weekends and holidays in between.
+func BarsWindow(provider marketdata.DataProvider, symbols []string, timeframe string, n int, now time.Time) (map[string][]alpaca.Bar, error) {
+	d, err := alpaca.TimeframeDuration(timeframe)
+	if err != nil {
+		return nil, err
+	}
+	days := math.Ceil(float64(n)*d.Hours()/6.5)*1.5 + 3 // 6.5-hour regular session
+	if d >= 24*time.Hour {
+		days = float64(n)*d.Hours()/24*1.5 + 7
+	}
+	resp, err := provider.GetBarsSince(symbols, timeframe, now.Add(-time.Duration(days)*24*time.Hour))
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string][]alpaca.Bar, len(symbols))
+	if resp == nil {
+		return out, nil
+	}
+	for sym, bars := range resp.Bars {
+		if len(bars) > n {
+			bars = bars[len(bars)-n:]
+		}
+		out[sym] = bars
+	}
+	return out, nil
+}
+
+// parseMarketCloseET parses "HH:MM" (e.g. "16:00") and returns (hour, minute). Returns (-1, -1) if invalid.
+func parseMarketCloseET(s string) (hour, minute int) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return -1, -1
+	}
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 {
+		return -1, -1
+	}
+	h, err1 := strconv.Atoi(strings.TrimSpace(parts[0]))
+	m, err2 := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err1 != nil || err2 != nil || h < 0 || h > 23 || m < 0 || m > 59 {
+		return -1, -1
+	}
+	return h, m
+}
+
+// containsString reports whether list contains s.
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// VolOptions builds volatility options from config for the given estimator method.
+func VolOptions(cfg *config.Config, method string) alpaca.Options {
+	return alpaca.Options{
+		Window:  cfg.VolWindow,
+		MinBars: cfg.VolMinBars,
+		Method:  method,
+		Lambda:  cfg.VolEWMALambda,
+	}
+}
+
+// RetryPolicy builds the Alpaca REST retry policy from config.
+func RetryPolicy(cfg *config.Config) alpaca.RetryPolicy {
+	return alpaca.RetryPolicy{
+		MaxRetries: cfg.RESTMaxRetries,
+		BaseDelay:  time.Duration(cfg.RESTRetryBaseMs) * time.Millisecond,
+		MaxDelay:   time.Duration(cfg.RESTRetryMaxMs) * time.Millisecond,
+	}
+}
+
+// NewDataProvider returns the market-data provider chosen by DATA_PROVIDER, backed by DATA_BACKUP if set;
+// client is the Alpaca data client, used directly when the provider is Alpaca.
+func NewDataProvider(cfg *config.Config, client *alpaca.Client) marketdata.DataProvider {
+	var p marketdata.DataProvider
+	if cfg.DataProvider == "polygon" {
+		p = marketdata.NewPolygon(cfg.PolygonBaseURL, cfg.PolygonWSURL, cfg.PolygonAPIKey, time.Duration(cfg.PolygonNewsPollSec)*time.Second)
+	} else {
+		p = marketdata.NewAlpaca(client, cfg.StreamWSURL, cfg.APIKeyID, cfg.APISecretKey)
+	}
+	switch {
+	case cfg.DataBackup == "yahoo":
+		p = marketdata.WithBackup(p, marketdata.NewYahoo(""))
+	case cfg.DataBackup == "finnhub" && cfg.FinnhubAPIKey != "":
+		p = marketdata.WithBackup(p, marketdata.NewFinnhub("", cfg.FinnhubAPIKey))
+	case cfg.DataBackup == "finnhub":
+		slog.Warn("DATA_BACKUP=finnhub needs FINNHUB_API_KEY; no backup source")
+	}
+	return p
+}
+
+// NewBroker returns the broker chosen by BROKER; client is the Alpaca trading client, used directly when
+// the broker is Alpaca.
+func NewBroker(cfg *config.Config, client *alpaca.TradingClient) broker.Broker {
+	if cfg.Broker == "ibkr" {
+		return broker.NewIBKR(cfg.IBKRBaseURL, cfg.IBKRAccountID, time.Duration(cfg.IBKRPollSec)*time.Second, cfg.IBKRVerifyTLS, cfg.IBKRConfirmMessages)
+	}
+	return broker.NewAlpaca(client, cfg.TradingBaseURL, cfg.APIKeyID, cfg.APISecretKey)
+}
+
+// corporateActionEvent builds the corporate_action event for a; today is in ET. The dividend yield uses
+// the symbol's last price, when known.
+func corporateActionEvent(a alpaca.CorporateAction, today time.Time, state *brain.State) events.CorporateActionEvent {
+	ev := events.CorporateActionEvent{Symbol: a.Symbol, Action: a.Type, ExDate: a.ExDate, RecordDate: a.RecordDate, PayableDate: a.PayableDate}
+	if ex, err := time.ParseInLocation("2006-01-02", a.ExDate, today.Location()); err == nil {
+		y, m, d := today.Date()
+		ev.DaysToEx = int(math.Round(ex.Sub(time.Date(y, m, d, 0, 0, 0, 0, today.Location())).Hours() / 24))
+	}
+	switch a.Type {
+	case alpaca.ActionForwardSplit, alpaca.ActionReverseSplit:
+		ev.SplitRatio = a.SplitRatio()
+	case alpaca.ActionCashDividend:
+		ev.Dividend, ev.Special = a.Rate, a.Special
+		if ticks := state.LastTicks(a.Symbol, 1); len(ticks) > 0 && ticks[0].Price > 0 {
+			ev.DividendPct = a.Rate / ticks[0].Price
+		}
+	}
+	return ev
+}
+
+// assetEvent builds the asset event for sym from its asset (known false when the trading API didn't
+// list it). Only tradable symbols are streamed.
+func assetEvent(sym string, a alpaca.Asset, known bool) events.AssetEvent {
+	if !known {
+		return events.AssetEvent{Symbol: sym, Status: "unknown"}
+	}
+	return events.AssetEvent{
+		Symbol:       sym,
+		Name:         a.Name,
+		Exchange:     a.Exchange,
+		Status:       a.Status,
+		Tradable:     a.Tradable,
+		Shortable:    a.Shortable,
+		EasyToBorrow: a.EasyToBorrow,
+		Fractionable: a.Fractionable,
+		Marginable:   a.Marginable,
+		Streamed:     a.Tradable,
+	}
+}
+
+// watchlistPusher copies a symbols file to an Alpaca watchlist when the file changes.
+type watchlistPusher struct {
+	client  *alpaca.TradingClient
+	name    string
+	path    string
+	modTime time.Time
+	pushed  []string
+}
+
+// sync pushes the file if it was modified since the last successful check and lists other symbols than
+// were last pushed. An empty or unreadable file leaves the watchlist alone; a failed push is retried on
+// the next call.
+func (w *watchlistPusher) sync() {
+	fi, err := os.Stat(w.path)
+	if err != nil || fi.ModTime().Equal(w.modTime) {
+		return
+	}
+	syms := config.ReadSymbolsFile(w.path)
+	if len(syms) == 0 || slices.Equal(syms, w.pushed) {
+		w.modTime = fi.ModTime()
+		return
+	}
+	if err := w.client.SetWatchlist(w.name, syms); err != nil {
+		slog.Warn("watchlist push failed", "name", w.name, "err", err)
+		return
+	}
+	w.modTime, w.pushed = fi.ModTime(), syms
+	slog.Info("watchlist pushed", "name", w.name, "symbols", len(syms))
+}
+
+// gapScan builds the pre-market gap_scan event for symbols at now: the last trade against the previous
+// session's close from snapshots, and the volume since 04:00 ET from 5-minute bars. Only gaps of at
+// least minPct (fraction) are listed, largest first.
+func gapScan(client marketdata.DataProvider, symbols []string, now time.Time, minPct float64) events.GapScanEvent {
+	et := now.In(brain.Eastern())
+	today := et.Format("2006-01-02")
+	ev := events.GapScanEvent{Date: today, At: now.UTC().Format(time.RFC3339), MinPct: minPct, Gaps: []events.GapScanEntry{}}
+	snaps, err := client.GetSnapshots(symbols)
+	if err != nil {
+		slog.Warn("gap scan: snapshots failed", "err", err)
+		return ev
+	}
+	premarketOpen := time.Date(et.Year(), et.Month(), et.Day(), 4, 0, 0, 0, brain.Eastern())
+	bars, err := client.GetBarsSince(symbols, "5Min", premarketOpen)
+	if err != nil {
+		slog.Warn("gap scan: pre-market bars failed; volume unknown", "err", err)
+		bars = &alpaca.BarsResponse{}
+	}
+	for _, sym := range symbols {
+		snap := snaps[sym]
+		if snap.LatestTrade == nil || snap.LatestTrade.Price <= 0 {
+			continue
+		}
+		// Before the open the daily bar is usually the previous session's; once today's exists, the
+		// previous close is on PrevDailyBar
+		var prev *alpaca.Bar
+		if d := barDate(snap.DailyBar); d != "" && d < today {
+			prev = snap.DailyBar
+		} else {
+			prev = snap.PrevDailyBar
+		}
+		if prev == nil || prev.Close <= 0 {
+			continue
+		}
+		ev.Scanned++
+		gap := snap.LatestTrade.Price/prev.Close - 1
+		if math.Abs(gap) < minPct {
+			continue
+		}
+		var vol uint64
+		for _, b := range bars.Bars[sym] {
+			vol += b.Volume
+		}
+		ev.Gaps = append(ev.Gaps, events.GapScanEntry{
+			Symbol:          sym,
+			PrevClose:       prev.Close,
+			Price:           snap.LatestTrade.Price,
+			GapPct:          gap,
+			PremarketVolume: vol,
+			LastTradeTime:   snap.LatestTrade.Time,
+		})
+	}
+	sort.Slice(ev.Gaps, func(i, j int) bool { return math.Abs(ev.Gaps[i].GapPct) > math.Abs(ev.Gaps[j].GapPct) })
+	return ev
+}
+
+// barDate is the ET date (YYYY-MM-DD) of a bar, "" if there is none or its time doesn't parse.
+func barDate(b *alpaca.Bar) string {
+	if b == nil {
+		return ""
+	}
+	t, err := time.Parse(time.RFC3339, b.Time)
+	if err != nil {
+		return ""
+	}
+	return t.In(brain.Eastern()).Format("2006-01-02")
+}
+
+// gapNewsMax caps the articles fetched for one gap_recovery event.
+const gapNewsMax = 200
+
+// gapRecovery builds the gap_recovery event for symbols from REST: 1-minute bars over the gap, the
+// latest trade and the news published in it. before holds the last prices seen before the gap. A failed
+// request leaves its fields empty.
+func gapRecovery(client marketdata.DataProvider, stream string, symbols []string, before map[string]float64, from, to time.Time, newsEvent func(alpaca.NewsArticle) events.NewsEvent) events.GapRecoveryEvent {
+	ev := events.GapRecoveryEvent{
+		Stream: stream,
+		From:   from.UTC().Format(time.RFC3339),
+		To:     to.UTC().Format(time.RFC3339),
+		GapSec: to.Sub(from).Seconds(),
+		News:   []events.NewsEvent{},
+	}
+	bars, err := client.GetBarsSince(symbols, "1Min", from.Truncate(time.Minute))
+	if err != nil {
+		slog.Warn("gap recovery: bars failed", "err", err)
+		bars = &alpaca.BarsResponse{}
+	}
+	snaps, err := client.GetSnapshots(symbols)
+	if err != nil {
+		slog.Warn("gap recovery: snapshots failed", "err", err)
+	}
+	articles, err := client.GetNewsSince(symbols, from, gapNewsMax)
+	if err != nil {
+		slog.Warn("gap recovery: news incomplete", "articles", len(articles), "err", err)
+	}
+	mentions := make(map[string]int)
+	for _, a := range articles {
+		ev.News = append(ev.News, newsEvent(a))
+		for _, s := range a.Symbols {
+			mentions[s]++
+		}
+	}
+	for _, sym := range symbols {
+		gs := events.GapSymbol{Symbol: sym, PriceBefore: before[sym], News: mentions[sym]}
+		for _, b := range bars.Bars[sym] {
+			if t, err := time.Parse(time.RFC3339, b.Time); err == nil && !t.Before(to) {
+				break
+			}
+			if gs.PriceBefore == 0 {
+				gs.PriceBefore = b.Open
+			}
+			if b.High > gs.High {
+				gs.High = b.High
+			}
+			if gs.Low == 0 || b.Low < gs.Low {
+				gs.Low = b.Low
+			}
+			gs.Volume += b.Volume
+		}
+		if snap, ok := snaps[sym]; ok && snap.LatestTrade != nil {
+			gs.PriceAfter = snap.LatestTrade.Price
+		}
+		if gs.PriceBefore > 0 && gs.PriceAfter > 0 {
+			gs.Change = gs.PriceAfter - gs.PriceBefore
+			gs.Return = gs.Change / gs.PriceBefore
+		}
+		ev.Symbols = append(ev.Symbols, gs)
+	}
+	return ev
+}
+
+// sinkEnabled reports whether SINKS allows the named event output (all when SINKS is unset).
+func sinkEnabled(cfg *config.Config, name string) bool {
+	return len(cfg.Sinks) == 0 || containsString(cfg.Sinks, name)
+}
+
+// reentryEnabled reports whether any global or per-symbol re-entry limit is set.
+func reentryEnabled(cfg *config.Config) bool {
+	if cfg.Reentry != (config.ReentryRule{}) {
+		return true
+	}
+	for _, r := range cfg.ReentrySymbols {
+		if r != (config.ReentryRule{}) {
+			return true
+		}
+	}
+	return false
+}
+
+// reentryRule converts the configured minutes into an execution rule.
+func reentryRule(r config.ReentryRule) execution.ReentryRule {
+	return execution.ReentryRule{
+		ExitCooldown: time.Duration(r.ExitCooldownMin) * time.Minute,
+		StopCooldown: time.Duration(r.StopCooldownMin) * time.Minute,
+		MaxReentries: r.MaxPerDay,
+	}
+}
+
+// streamedContract is an option contract streamed for option_quote events, with its IV and delta from
+// the last chain refresh.
+type streamedContract struct {
+	alpaca.OptionContract
+	iv, delta float64
+}
+
+// impliedVolEvent summarizes an underlying's ATM term structure (nearest expiration first) against its
+// realized volatility (0 = unknown).
+func impliedVolEvent(symbol string, spot float64, term []alpaca.IVPoint, realized float64) events.ImpliedVolEvent {
+	ev := events.ImpliedVolEvent{Symbol: symbol, Spot: spot, RealizedVol: realized}
+	for _, p := range term {
+		if ev.ATMIV == 0 && p.Days >= 1 {
+			ev.ATMIV = p.IV
+		}
+		ev.Term = append(ev.Term, events.IVTermPoint{Expiry: p.Expiry.Format("2006-01-02"), Days: p.Days, Strike: p.Strike, IV: p.IV})
+	}
+	if ev.ATMIV == 0 && len(term) > 0 {
+		ev.ATMIV = term[0].IV
+	}
+	ev.ATMIV30d = alpaca.InterpolateIV(term, 30)
+	if realized > 0 {
+		ev.IVRVRatio = ev.ATMIV30d / realized
+	}
+	return ev
+}
+
+// formatTS renders a payload timestamp (exchange_ts, received_ts); "" for the zero time.
+func formatTS(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// positionsRefreshDelay is how long a refresh after an order command waits, so a market order has
+// usually filled by the time positions are read.
+const positionsRefreshDelay = 500 * time.Millisecond
+
+// delta is one event found by diffing two polls, routed to its symbol.
+type delta struct {
+	typ     string
+	symbol  string
+	payload interface{}
+}
+
+// positionDeltas diffs two positions polls by symbol. Only qty, side and cost basis count as a change:
+// market value and P&L move with every price and are left to the positions snapshot.
+func positionDeltas(prev, cur []events.Position) []delta {
+	before := make(map[string]events.Position, len(prev))
+	for _, p := range prev {
+		before[p.Symbol] = p
+	}
+	var out []delta
+	for _, p := range cur {
+		old, ok := before[p.Symbol]
+		if !ok {
+			out = append(out, delta{events.TypePosOpened, p.Symbol, events.PositionDeltaEvent{Position: p}})
+			continue
+		}
+		delete(before, p.Symbol)
+		var changed []string
+		if old.Qty != p.Qty {
+			changed = append(changed, "qty")
+		}
+		if old.Side != p.Side {
+			changed = append(changed, "side")
+		}
+		if old.CostBasis != p.CostBasis {
+			changed = append(changed, "cost_basis")
+		}
+		if len(changed) > 0 {
+			out = append(out, delta{events.TypePosChanged, p.Symbol, events.PositionDeltaEvent{Position: p, Prev: &old, Changed: changed}})
+		}
+	}
+	for _, p := range prev {
+		if _, ok := before[p.Symbol]; ok {
+			out = append(out, delta{events.TypePosClosed, p.Symbol, events.PositionDeltaEvent{Position: p}})
+		}
+	}
+	return out
+}
+
+// orderDeltas diffs two open-orders polls by order ID. final is called only when orders left the open
+// list and returns what the broker has for them by ID. An order missing there is taken as filled when
+// its last poll showed it fully filled, canceled otherwise, with status "filled" or "closed". When final
+// fails, the orders that left get no delta yet and are returned as pending, to be diffed again next poll.
+func orderDeltas(prev, cur []events.Order, final func(gone []events.Order) (map[string]events.Order, error)) (out []delta, pending []events.Order) {
+	before := make(map[string]events.Order, len(prev))
+	for _, o := range prev {
+		before[o.ID] = o
+	}
+	for _, o := range cur {
+		old, ok := before[o.ID]
+		if !ok {
+			out = append(out, delta{events.TypeOrderNew, o.Symbol, events.OrderDeltaEvent{Order: o}})
+			continue
+		}
+		delete(before, o.ID)
+		var changed []string
+		if old.Status != o.Status {
+			changed = append(changed, "status")
+		}
+		if old.Qty != o.Qty {
+			changed = append(changed, "qty")
+		}
+		if old.FilledQty != o.FilledQty {
+			changed = append(changed, "filled_qty")
+		}
+		if len(changed) > 0 {
+			out = append(out, delta{events.TypeOrderUpdated, o.Symbol, events.OrderDeltaEvent{Order: o, Prev: &old, Changed: changed}})
+		}
+	}
+	if len(before) == 0 {
+		return out, nil
+	}
+	var gone []events.Order
+	for _, o := range prev {
+		if _, ok := before[o.ID]; ok {
+			gone = append(gone, o)
+		}
+	}
+	closed, err := final(gone)
+	if err != nil {
+		return out, gone
+	}
+	for _, o := range gone {
+		f, ok := closed[o.ID]
+		if !ok {
+			f = o
+			f.Status = "closed"
+			if o.Qty != "" && o.FilledQty == o.Qty {
+				f.Status = "filled"
+			}
+		}
+		typ := events.TypeOrderCanceled
+		if f.Status == "filled" {
+			typ = events.TypeOrderFilled
+		}
+		out = append(out, delta{typ, f.Symbol, events.OrderDeltaEvent{Order: f}})
+	}
+	return out, nil
+}
+
+// dailyPnL builds the daily_pnl event from a one-day portfolio history, dated by its last point in ET
+// (the last session, on a day the market is closed; now when there is no point). Points without equity
+// (null or 0: not yet reached, or no account then) are left out of the curve.
+func dailyPnL(h *alpaca.PortfolioHistory, now time.Time) events.DailyPnLEvent {
+	ev := events.DailyPnLEvent{Date: now.In(brain.Eastern()).Format("2006-01-02"), Timeframe: h.Timeframe, BaseValue: h.BaseValue, Curve: []events.EquityPoint{}}
+	for i, ts := range h.Timestamp {
+		if i >= len(h.Equity) || h.Equity[i] == nil || *h.Equity[i] == 0 {
+			continue
+		}
+		eq := *h.Equity[i]
+		pl := eq - h.BaseValue
+		if i < len(h.ProfitLoss) && h.ProfitLoss[i] != nil {
+			pl = *h.ProfitLoss[i]
+		}
+		ev.Curve = append(ev.Curve, events.EquityPoint{TS: time.Unix(ts, 0).UTC().Format(time.RFC3339), Equity: eq, PL: pl})
+		ev.Date = time.Unix(ts, 0).In(brain.Eastern()).Format("2006-01-02")
+		ev.Equity, ev.DayPL = eq, pl
+		ev.PeakEquity = max(ev.PeakEquity, eq)
+		ev.MaxDrawdownPct = max(ev.MaxDrawdownPct, (ev.PeakEquity-eq)/ev.PeakEquity)
+	}
+	if ev.BaseValue > 0 {
+		ev.DayPLPct = ev.DayPL / ev.BaseValue
+	}
+	if ev.PeakEquity > 0 {
+		ev.Drawdown = ev.PeakEquity - ev.Equity
+		ev.DrawdownPct = ev.Drawdown / ev.PeakEquity
+	}
+	return ev
+}
+
+// ordersPage is the most orders one GetOrders call returns.
+const ordersPage = 500
+
+// ordersSince pages through the orders with status submitted after after, oldest first: each page starts
+// at the last order of the one before, so a day with more orders than fit in one call is listed whole.
+func ordersSince(b broker.Broker, status string, after time.Time) ([]alpaca.Order, error) {
+	var all []alpaca.Order
+	seen := make(map[string]bool)
+	for {
+		page, err := b.GetOrders(status, after, ordersPage)
+		if err != nil {
+			return all, err
+		}
+		added := 0
+		for _, o := range page {
+			if !seen[o.ID] {
+				seen[o.ID] = true
+				all = append(all, o)
+				added++
+			}
+		}
+		if len(page) < ordersPage || added == 0 {
+			return all, nil
+		}
+		// Orders submitted in the same instant as the page's last one are listed again and skipped above
+		last, err := time.Parse(time.RFC3339Nano, page[len(page)-1].SubmittedAt)
+		if err != nil {
+			return all, nil
+		}
+		after = last.Add(-time.Nanosecond)
+	}
+}
@@ -0,0 +1,52 @@
+package engine
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/sunnyp94/sentry-bridge/go-engine/alpaca"
+	"github.com/sunnyp94/sentry-bridge/go-engine/brain"
+	"github.com/sunnyp94/sentry-bridge/go-engine/clock"
+)
+
+// AwaitSession blocks until the engine should be streaming: from lead before a trading day's pre-market
+// until grace after its post-market. It returns the end of that window, or false if ctx ends first.
+// Trading days come from the broker calendar, re-read after each sleep; while it can't be fetched the
+// check is retried every minute. The window is judged by clk; the sleeps in between are on real time.
+func AwaitSession(ctx context.Context, clk clock.Clock, tc *alpaca.TradingClient, lead, grace time.Duration) (time.Time, bool) {
+	for {
+		now := clk.Now()
+		wait := time.Minute
+		days, err := tc.GetCalendar(now.In(brain.Eastern()).Format("2006-01-02"), now.AddDate(0, 0, 10).In(brain.Eastern()).Format("2006-01-02"))
+		if err != nil {
+			slog.Warn("auto schedule: market calendar unavailable; retrying in 1m", "err", err)
+		} else {
+			wait = 24 * time.Hour // no trading day in the next ten days: look again tomorrow
+			for _, d := range days {
+				open, close, err := d.ExtendedHours(brain.Eastern())
+				if err != nil {
+					slog.Warn("auto schedule: calendar day skipped", "err", err)
+					continue
+				}
+				start, end := open.Add(-lead), close.Add(grace)
+				if !now.Before(end) {
+					continue
+				}
+				if !now.Before(start) {
+					return end, true
+				}
+				wait = start.Sub(now)
+				slog.Info("auto schedule: market closed; sleeping", "until", start.In(brain.Eastern()).Format("2006-01-02 15:04 MST"))
+				break
+			}
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return time.Time{}, false
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,826 @@
+// Package events defines the typed payloads the engine publishes (brain pipe and other sinks).
+// JSON field names are the wire schema the Python brain reads; downstream Go consumers can import
+// these types instead of decoding into maps.
+package events
+
+import (
+	"encoding/json"
+
+	"github.com/sunnyp94/sentry-bridge/go-engine/alpaca"
+)
+
+// Event type names, used as the envelope "type".
+const (
+	TypeTrade          = "trade"
+	TypeQuote          = "quote"
+	TypeNews           = "news"
+	TypeVolatility     = "volatility"
+	TypePositions      = "positions"
+	TypeOrders         = "orders"
+	TypeCorrelation    = "correlation"
+	TypeBarsUpdate     = "bars_update"
+	TypeFeatureSchema  = "feature_schema"
+	TypeResponse       = "response"
+	TypeSignal         = "signal"
+	TypeSnapshot       = "snapshot"
+	TypeBrainError     = "brain_error"
+	TypeTradeUpdate    = "trade_update"
+	TypeOrderChase     = "order_chase"
+	TypeRiskReport     = "risk_report"
+	TypeEngineStats    = "engine_stats"
+	TypeUniverse       = "universe"
+	TypeGapRecovery    = "gap_recovery"
+	TypeCommandResult  = "command_result"
+	TypeKillSwitch     = "kill_switch"
+	TypePnL            = "pnl"
+	TypeOrderDecision  = "order_decision"
+	TypeAccount        = "account"
+	TypeFeedCompare    = "feed_compare"
+	TypeExpiryDay      = "expiry_day"
+	TypePDT            = "pdt"
+	TypeExternalSignal = "external_signal"
+	TypeGap            = "gap"
+	TypeHalt           = "halt"
+	TypeResume         = "resume"
+	TypeCorrection     = "correction"
+	TypeImpliedVol     = "implied_vol"
+	TypeOptionQuote    = "option_quote"
+	TypeCorpAction     = "corporate_action"
+	TypeAsset          = "asset"
+	TypeGapScan        = "gap_scan"
+	TypeNewsUpdate     = "news_update"
+	TypePosOpened      = "position_opened"
+	TypePosChanged     = "position_changed"
+	TypePosClosed      = "position_closed"
+	TypeOrderNew       = "order_new"
+	TypeOrderUpdated   = "order_updated"
+	TypeOrderFilled    = "order_filled"
+	TypeOrderCanceled  = "order_canceled"
+	TypeDailyPnL       = "daily_pnl"
+	TypePosDrift       = "position_drift"
+	TypeOrderRejected  = "order_rejected"
+	TypeControl        = "control"
+)
+
+// Envelope is one NDJSON line: {"type": ..., "ts": ..., "payload": ...}.
+type Envelope struct {
+	Type    string      `json:"type"`
+	TS      string      `json:"ts"`
+	Payload interface{} `json:"payload"`
+	Tags    []string    `json:"tags,omitempty"`    // labels added by EVENT_FILTERS tag rules
+	Expires string      `json:"expires,omitempty"` // hot events (EVENT_TTL_TYPES): dropped instead of delivered after this time
+	Seq     uint64      `json:"seq,omitempty"`     // per-symbol sequence number (first symbol; account-wide events share one), from 1 at engine start
+	ID      string      `json:"id,omitempty"`      // inbound market events: event ID, passed back as an order intent's correlation_id
+}
+
+// TradeEvent is a trade with derived returns/volumes.
+type TradeEvent struct {
+	Symbol        string    `json:"symbol"`
+	Price         float64   `json:"price"`
+	Size          int       `json:"size"`
+	Volume1m      int64     `json:"volume_1m"`
+	Volume5m      int64     `json:"volume_5m"`
+	Return1m      float64   `json:"return_1m"`
+	Return5m      float64   `json:"return_5m"`
+	Session       string    `json:"session"`
+	ExpiryContext string    `json:"expiry_context,omitempty"` // weekly, monthly or triple_witching on an options expiration day
+	Volatility    float64   `json:"volatility"`
+	VolVersion    int64     `json:"vol_version,omitempty"`    // volatility snapshot Volatility came from
+	ExchangeTS    string    `json:"exchange_ts,omitempty"`    // RFC3339Nano, when the exchange printed it; the envelope ts is when the engine published it
+	ReceivedTS    string    `json:"received_ts,omitempty"`    // RFC3339Nano, when the engine read it off the stream
+	Stale         bool      `json:"stale,omitempty"`          // STALE_TICK_ACTION=flag: older than STALE_TICK_MS when processed
+	AgeMs         float64   `json:"age_ms,omitempty"`         // with stale: how old, from the exchange time
+	Conditions    []string  `json:"conditions,omitempty"`     // SIP sale conditions by name (alpaca.DecodeConditions)
+	Exchange      string    `json:"exchange,omitempty"`       // exchange code, e.g. V (IEX), Q (Nasdaq), N (NYSE)
+	Tape          string    `json:"tape,omitempty"`           // A, B (CTA) or C (UTP)
+	Features      []float64 `json:"features,omitempty"`       // FEATURE_VECTORS=true
+	FeatureSchema int       `json:"feature_schema,omitempty"` // brain.FeatureSchemaVersion when Features is set
+	ModelScore    *float64  `json:"model_score,omitempty"`    // INFERENCE_MODEL output for this event
+}
+
+// QuoteEvent is an NBBO update with derived returns/volumes (returns computed from mid).
+type QuoteEvent struct {
+	Symbol        string    `json:"symbol"`
+	Bid           float64   `json:"bid"`
+	Ask           float64   `json:"ask"`
+	BidSize       int       `json:"bid_size"`
+	AskSize       int       `json:"ask_size"`
+	Mid           float64   `json:"mid"`
+	SpreadBps     float64   `json:"spread_bps"`
+	Volume1m      int64     `json:"volume_1m"`
+	Volume5m      int64     `json:"volume_5m"`
+	Return1m      float64   `json:"return_1m"`
+	Return5m      float64   `json:"return_5m"`
+	Session       string    `json:"session"`
+	ExpiryContext string    `json:"expiry_context,omitempty"` // weekly, monthly or triple_witching on an options expiration day
+	Volatility    float64   `json:"volatility"`
+	VolVersion    int64     `json:"vol_version,omitempty"`
+	ExchangeTS    string    `json:"exchange_ts,omitempty"` // as on TradeEvent
+	ReceivedTS    string    `json:"received_ts,omitempty"`
+	Stale         bool      `json:"stale,omitempty"`
+	AgeMs         float64   `json:"age_ms,omitempty"`
+	Halted        bool      `json:"halted,omitempty"` // the symbol is halted or paused (halt event): not tradable
+	Features      []float64 `json:"features,omitempty"`
+	FeatureSchema int       `json:"feature_schema,omitempty"`
+	ModelScore    *float64  `json:"model_score,omitempty"`
+}
+
+// NewsEvent is a full news article.
+type NewsEvent struct {
+	ID         int64    `json:"id"`
+	Headline   string   `json:"headline"`
+	Author     string   `json:"author"`
+	CreatedAt  string   `json:"created_at"`
+	UpdatedAt  string   `json:"updated_at"`
+	Summary    string   `json:"summary"`
+	URL        string   `json:"url"`
+	Symbols    []string `json:"symbols"`
+	Symbol     string   `json:"symbol,omitempty"`    // per-symbol news (NEWS_PER_SYMBOL): the symbol this copy is for
+	Relevance  float64  `json:"relevance,omitempty"` // ...and how much the article is about it, 0 to 1
+	Source     string   `json:"source"`
+	Backfill   bool     `json:"backfill,omitempty"`    // replayed from REST at startup, not live
+	ReceivedTS string   `json:"received_ts,omitempty"` // RFC3339Nano, when the engine read it off the news stream
+	Changed    []string `json:"changed,omitempty"`     // news_update: fields that differ from the version sent before
+	Sentiment  float64  `json:"sentiment"`             // lexicon score of headline and summary, -1 to 1 (NEWS_SENTIMENT)
+	Confidence float64  `json:"sentiment_confidence"`  // 0 = no sentiment words; towards 1 with more words that agree
+
+	// Near-duplicate headlines within NEWS_CLUSTER_WINDOW_MIN share a cluster, named by the ID of its first
+	// article; cluster_size counts its articles so far, this one included
+	ClusterID   int64 `json:"cluster_id,omitempty"`
+	ClusterSize int   `json:"cluster_size,omitempty"`
+
+	// The enrichment service's JSON object (NEWS_ENRICH_URL), e.g. sentiment, entities and impact as it
+	// returns them; absent when the service failed or timed out
+	Enrichment json.RawMessage `json:"enrichment,omitempty"`
+}
+
+// NewsFromArticle converts an Alpaca article to a NewsEvent.
+func NewsFromArticle(a alpaca.NewsArticle) NewsEvent {
+	return NewsEvent{
+		ID:        a.ID,
+		Headline:  a.Headline,
+		Author:    a.Author,
+		CreatedAt: a.CreatedAt,
+		UpdatedAt: a.UpdatedAt,
+		Summary:   a.Summary,
+		URL:       a.URL,
+		Symbols:   a.Symbols,
+		Source:    a.Source,
+	}
+}
+
+// Volatility status values.
+const (
+	VolStatusOK               = "ok"
+	VolStatusInsufficientData = "insufficient_data"
+)
+
+// VolatilityEvent carries the per-symbol volatility refresh. When VolStatus is insufficient_data only
+// Symbol, Bars and Costs are set.
+type VolatilityEvent struct {
+	Symbol            string      `json:"symbol"`
+	AnnualizedVol30d  float64     `json:"annualized_vol_30d,omitempty"`
+	VolMethod         string      `json:"vol_method,omitempty"`
+	VolStatus         string      `json:"vol_status"`
+	Bars              int         `json:"bars,omitempty"`
+	ParkinsonVol30d   float64     `json:"parkinson_vol_30d,omitempty"`
+	GarmanKlassVol30d float64     `json:"garman_klass_vol_30d,omitempty"`
+	Beta              *float64    `json:"beta,omitempty"`
+	BetaBenchmark     string      `json:"beta_benchmark,omitempty"`
+	Costs             *SymbolCost `json:"costs,omitempty"` // COST_TABLE row for the symbol
+	VolVersion        int64       `json:"vol_version"`     // refresh this came from; trades and quotes carry the same number
+}
+
+// SymbolCost is the user-supplied trading cost for one symbol (COST_TABLE).
+type SymbolCost struct {
+	SlippageBps   float64 `json:"slippage_bps"`    // expected slippage per side
+	FeeBps        float64 `json:"fee_bps"`         // fees and commission per side
+	BorrowRatePct float64 `json:"borrow_rate_pct"` // annual borrow (locate) rate for shorts
+}
+
+// Position is one open position.
+type Position struct {
+	Symbol         string  `json:"symbol"`
+	Qty            string  `json:"qty"`
+	Side           string  `json:"side"`
+	MarketValue    string  `json:"market_value"`
+	CostBasis      string  `json:"cost_basis"`
+	UnrealizedPL   string  `json:"unrealized_pl"`
+	UnrealizedPLPC string  `json:"unrealized_plpc"`
+	CurrentPrice   float64 `json:"current_price"`
+}
+
+// PositionFromAlpaca converts a broker position.
+func PositionFromAlpaca(p alpaca.Position) Position {
+	return Position{
+		Symbol: p.Symbol, Qty: p.Qty, Side: p.Side,
+		MarketValue: p.MarketValue, CostBasis: p.CostBasis,
+		UnrealizedPL: p.UnrealizedPL, UnrealizedPLPC: p.UnrealizedPLPC, CurrentPrice: p.CurrentPrice.Value(),
+	}
+}
+
+// PositionsEvent is the periodic positions snapshot.
+type PositionsEvent struct {
+	Positions []Position `json:"positions"`
+}
+
+// Order is one open order.
+type Order struct {
+	ID        string `json:"id"`
+	Symbol    string `json:"symbol"`
+	Side      string `json:"side"`
+	Qty       string `json:"qty"`
+	FilledQty string `json:"filled_qty"`
+	Type      string `json:"type"`
+	Status    string `json:"status"`
+	CreatedAt string `json:"created_at"`
+}
+
+// OrderFromAlpaca converts a broker order.
+func OrderFromAlpaca(o alpaca.Order) Order {
+	return Order{
+		ID: o.ID, Symbol: o.Symbol, Side: o.Side, Qty: o.Qty,
+		FilledQty: o.FilledQty, Type: o.Type, Status: o.Status,
+		CreatedAt: o.CreatedAt,
+	}
+}
+
+// OrdersEvent is the periodic open-orders snapshot.
+type OrdersEvent struct {
+	Orders []Order `json:"orders"`
+}
+
+// PositionDeltaEvent is a position that changed between two polls (position_opened, position_changed,
+// position_closed). For position_closed, Position is the last state seen.
+type PositionDeltaEvent struct {
+	Position Position  `json:"position"`
+	Prev     *Position `json:"prev,omitempty"`    // position_changed: the state at the previous poll
+	Changed  []string  `json:"changed,omitempty"` // position_changed: "qty", "side", "cost_basis"
+}
+
+// PositionDriftEvent is a position the broker reports differently from what the engine's own orders
+// and fills add up to, for longer than POSITION_DRIFT_GRACE_SEC. Quantities are signed (negative =
+// short); the broker's becomes the expected one after the report.
+type PositionDriftEvent struct {
+	Symbol   string  `json:"symbol"`
+	Expected float64 `json:"expected"`
+	Actual   float64 `json:"actual"`
+	Diff     float64 `json:"diff"`  // actual - expected
+	Since    string  `json:"since"` // when the mismatch was first seen
+}
+
+// OrderDeltaEvent is an open order that changed between two polls (order_new, order_updated,
+// order_filled, order_canceled). order_filled and order_canceled mean the order left the open list; its
+// Status is the final one (order_canceled also covers expired, rejected and replaced orders).
+type OrderDeltaEvent struct {
+	Order   Order    `json:"order"`
+	Prev    *Order   `json:"prev,omitempty"`    // order_updated: the state at the previous poll
+	Changed []string `json:"changed,omitempty"` // order_updated: "status", "qty", "filled_qty"
+}
+
+// AccountEvent is the periodic account snapshot (GET /v2/account), so sizing can see available capital.
+type AccountEvent struct {
+	Status           string  `json:"status"`
+	Currency         string  `json:"currency"`
+	Equity           float64 `json:"equity"`
+	LastEquity       float64 `json:"last_equity"` // equity at the previous close
+	Cash             float64 `json:"cash"`
+	BuyingPower      float64 `json:"buying_power"`
+	PortfolioValue   float64 `json:"portfolio_value"`
+	DaytradeCount    int     `json:"daytrade_count"` // day trades in the last five business days
+	PatternDayTrader bool    `json:"pattern_day_trader"`
+	TradingBlocked   bool    `json:"trading_blocked"`
+	ShortingEnabled  bool    `json:"shorting_enabled"`
+}
+
+// AccountFromAlpaca converts the broker account.
+func AccountFromAlpaca(a alpaca.Account) AccountEvent {
+	return AccountEvent{
+		Status: a.Status, Currency: a.Currency,
+		Equity: a.Equity.Value(), LastEquity: a.LastEquity.Value(), Cash: a.Cash.Value(),
+		BuyingPower: a.BuyingPower.Value(), PortfolioValue: a.PortfolioValue.Value(),
+		DaytradeCount: a.DaytradeCount, PatternDayTrader: a.PatternDayTrader,
+		TradingBlocked: a.TradingBlocked, ShortingEnabled: a.ShortingEnabled,
+	}
+}
+
+// DailyPnLEvent is the account's performance for the trading day, from the broker's portfolio history:
+// P&L against the previous close, the intraday equity curve and the drawdown from its high.
+type DailyPnLEvent struct {
+	Date           string        `json:"date"` // ET trading day
+	Timeframe      string        `json:"timeframe"`
+	BaseValue      float64       `json:"base_value"` // equity at the previous close
+	Equity         float64       `json:"equity"`     // latest point of the curve
+	DayPL          float64       `json:"day_pl"`
+	DayPLPct       float64       `json:"day_pl_pct"` // fraction of base_value
+	PeakEquity     float64       `json:"peak_equity"`
+	Drawdown       float64       `json:"drawdown"`         // peak_equity - equity
+	DrawdownPct    float64       `json:"drawdown_pct"`     // fraction of peak_equity
+	MaxDrawdownPct float64       `json:"max_drawdown_pct"` // deepest drawdown of the day so far
+	Curve          []EquityPoint `json:"curve"`
+}
+
+// EquityPoint is one point of the equity curve.
+type EquityPoint struct {
+	TS     string  `json:"ts"`
+	Equity float64 `json:"equity"`
+	PL     float64 `json:"pl"`
+}
+
+// CorrelationEvent is the pairwise return correlation matrix across tickers.
+type CorrelationEvent struct {
+	Timeframe string               `json:"timeframe"`
+	Window    int                  `json:"window"`
+	Symbols   []string             `json:"symbols"`
+	Pairs     []alpaca.Correlation `json:"pairs"`
+}
+
+// ExpiryDayEvent is sent in the morning of an options expiration day, when pinning and elevated volume
+// are expected.
+type ExpiryDayEvent struct {
+	Date    string `json:"date"`
+	Kind    string `json:"kind"`              // weekly, monthly or triple_witching
+	Shifted bool   `json:"shifted,omitempty"` // Thursday expiration because Friday is a market holiday
+}
+
+// FeedCompareEvent reports IEX versus SIP divergence for the sampled symbols over the last window.
+type FeedCompareEvent struct {
+	WindowSec int                     `json:"window_sec"`
+	Primary   string                  `json:"primary"` // feed the engine trades on (ALPACA_DATA_FEED)
+	Symbols   []alpaca.FeedDivergence `json:"symbols"`
+}
+
+// BarsUpdateEvent carries new bars for one symbol and timeframe.
+type BarsUpdateEvent struct {
+	Symbol    string       `json:"symbol"`
+	Timeframe string       `json:"timeframe"`
+	Bars      []alpaca.Bar `json:"bars"`
+}
+
+// SignalEvent is emitted when the in-engine model scores an event at or above the configured threshold.
+type SignalEvent struct {
+	Symbol    string  `json:"symbol"`
+	Score     float64 `json:"score"`
+	Threshold float64 `json:"threshold"`
+	Source    string  `json:"source"` // event type that was scored (trade or quote)
+	Price     float64 `json:"price"`  // trade price or quote mid
+	Model     string  `json:"model"`
+}
+
+// FeatureSchemaEvent describes the feature vector layout.
+type FeatureSchemaEvent struct {
+	Version int      `json:"version"`
+	Names   []string `json:"names"`
+}
+
+// LastPrice is the latest trade and quote for one symbol.
+type LastPrice struct {
+	Symbol    string  `json:"symbol"`
+	Price     float64 `json:"price,omitempty"`
+	PriceTime string  `json:"price_time,omitempty"`
+	Bid       float64 `json:"bid,omitempty"`
+	Ask       float64 `json:"ask,omitempty"`
+}
+
+// SnapshotEvent is sent once a (re)started brain reports ready, before any streamed events, so it starts
+// with current volatility, account state and prices.
+type SnapshotEvent struct {
+	Brain         string            `json:"brain,omitempty"` // instance name when several brains run
+	Symbols       []string          `json:"symbols"`         // symbols routed to this brain
+	Volatility    []VolatilityEvent `json:"volatility"`
+	VolVersion    int64             `json:"vol_version"` // volatility snapshot the entries came from
+	Positions     []Position        `json:"positions"`
+	Orders        []Order           `json:"orders"`
+	Account       *AccountEvent     `json:"account,omitempty"` // latest account poll, when one has succeeded
+	Prices        []LastPrice       `json:"prices"`
+	ExpiryContext string            `json:"expiry_context,omitempty"` // today's options expiry kind, if today is an expiration day
+}
+
+// BrainErrorEvent is a Python traceback captured from the brain's stderr. Count is how many times this
+// exception line has been seen since the engine started; Restarts is how often the brain was restarted.
+type BrainErrorEvent struct {
+	Brain     string `json:"brain,omitempty"` // instance name when several brains run
+	Exception string `json:"exception"`
+	Traceback string `json:"traceback"`
+	Count     int    `json:"count"`
+	Restarts  int    `json:"restarts"`
+	PID       int    `json:"pid"`
+}
+
+// TradeUpdateEvent is an order event from the trading stream (fills and partial fills as they happen).
+type TradeUpdateEvent struct {
+	Event         string  `json:"event"` // new, partial_fill, fill, canceled, replaced, rejected, expired, ...
+	OrderID       string  `json:"order_id"`
+	ClientOrderID string  `json:"client_order_id"`
+	Symbol        string  `json:"symbol"`
+	Side          string  `json:"side"`
+	Type          string  `json:"type"`
+	Status        string  `json:"status"`
+	Qty           string  `json:"qty"`
+	FilledQty     string  `json:"filled_qty"`
+	FilledAvg     float64 `json:"filled_avg_price,omitempty"`
+	LimitPrice    float64 `json:"limit_price,omitempty"`
+	Price         float64 `json:"price,omitempty"`        // this execution (fill/partial_fill)
+	ExecQty       float64 `json:"exec_qty,omitempty"`     // this execution's quantity
+	PositionQty   float64 `json:"position_qty,omitempty"` // position after this execution
+	Timestamp     string  `json:"timestamp"`
+	CorrelationID string  `json:"correlation_id,omitempty"` // the intent's correlation ID, for orders the engine placed
+}
+
+// TradeUpdateFromAlpaca converts a trading stream update.
+func TradeUpdateFromAlpaca(u alpaca.TradeUpdate) TradeUpdateEvent {
+	o := u.Order
+	return TradeUpdateEvent{
+		Event: u.Event, OrderID: o.ID, ClientOrderID: o.ClientOrderID, Symbol: o.Symbol, Side: o.Side,
+		Type: o.Type, Status: o.Status, Qty: o.Qty, FilledQty: o.FilledQty,
+		FilledAvg: o.FilledAvgPrice.Value(), LimitPrice: o.LimitPrice.Value(),
+		Price: u.Price.Value(), ExecQty: u.Qty.Value(), PositionQty: u.PositionQty.Value(), Timestamp: u.Timestamp,
+	}
+}
+
+// OrderChaseEvent reports an action the engine took on a resting limit order (cancel, reprice,
+// cancel_for_market, market).
+type OrderChaseEvent struct {
+	OrderID       string  `json:"order_id"`
+	NewOrderID    string  `json:"new_order_id,omitempty"` // replacement or market order
+	ClientOrderID string  `json:"client_order_id"`
+	Symbol        string  `json:"symbol"`
+	Side          string  `json:"side"`
+	Action        string  `json:"action"`
+	Reason        string  `json:"reason"` // timeout, max_reprices
+	Qty           string  `json:"qty"`
+	FilledQty     string  `json:"filled_qty"`
+	LimitPrice    float64 `json:"limit_price,omitempty"`
+	NewLimitPrice float64 `json:"new_limit_price,omitempty"`
+	Error         string  `json:"error,omitempty"`
+}
+
+// BudgetUsage is the entry budget enforced by the order gateway: current usage next to each limit
+// (0 limit = unlimited).
+type BudgetUsage struct {
+	OpenPositions     int     `json:"open_positions"` // including entries still working
+	MaxPositions      int     `json:"max_positions"`
+	EntriesLastHour   int     `json:"entries_last_hour"`
+	MaxEntriesPerHour int     `json:"max_entries_per_hour"`
+	EntriesToday      int     `json:"entries_today"`
+	MaxEntriesPerDay  int     `json:"max_entries_per_day"`
+	CapitalLastHour   float64 `json:"capital_last_hour"` // entry notional submitted in the rolling hour
+	MaxCapitalPerHour float64 `json:"max_capital_per_hour"`
+	Rejected          uint64  `json:"rejected"` // entries refused since start
+}
+
+// UniverseEvent is sent when the set of streamed symbols changes during the session.
+type UniverseEvent struct {
+	Symbols []string `json:"symbols"` // active universe after the change
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+	Reason  string   `json:"reason"` // e.g. "idle"
+}
+
+// CommandResultEvent answers one entry of the command stream (COMMANDS_STREAM).
+type CommandResultEvent struct {
+	ID       string      `json:"id,omitempty"` // the command's id field, for correlation
+	Command  string      `json:"command"`
+	StreamID string      `json:"stream_id"` // Redis entry ID of the command
+	OK       bool        `json:"ok"`
+	Error    string      `json:"error,omitempty"`
+	Result   interface{} `json:"result,omitempty"` // e.g. the broker order for "order"
+}
+
+// KillSwitchEvent is sent when order submission is halted or resumed.
+type KillSwitchEvent struct {
+	Engaged bool   `json:"engaged"`
+	Reason  string `json:"reason,omitempty"`
+	Source  string `json:"source"`            // who flipped it, e.g. "command"
+	Flatten bool   `json:"flatten,omitempty"` // open orders canceled and positions closed
+}
+
+// PDTEvent warns that the account is at the pattern day trader limit, or reports an order refused for it.
+type PDTEvent struct {
+	Symbol           string  `json:"symbol"`
+	DayTrades        int     `json:"day_trades"` // in the last five business days
+	Limit            int     `json:"limit"`
+	Equity           float64 `json:"equity"`
+	PatternDayTrader bool    `json:"pattern_day_trader"`
+	Blocked          bool    `json:"blocked"`
+	Reason           string  `json:"reason"`
+}
+
+// ExternalSignalEvent is a signal posted to the webhook endpoint by an outside system, validated and
+// normalized. Side is buy, sell, close or empty; Time is the sender's timestamp when given.
+type ExternalSignalEvent struct {
+	ID       string                 `json:"id,omitempty"`
+	Source   string                 `json:"source"`
+	Symbol   string                 `json:"symbol"`
+	Side     string                 `json:"side,omitempty"`
+	Strength *float64               `json:"strength,omitempty"`
+	Price    float64                `json:"price,omitempty"`
+	Comment  string                 `json:"comment,omitempty"`
+	Time     string                 `json:"time,omitempty"`
+	Received string                 `json:"received"`
+	Extra    map[string]interface{} `json:"extra,omitempty"` // fields the engine does not know, passed through
+}
+
+// OrderDecisionEvent is the gateway's answer to an order intent from the brain or the command stream.
+type OrderDecisionEvent struct {
+	Source        string  `json:"source"` // "brain" or "command"
+	Accepted      bool    `json:"accepted"`
+	Reason        string  `json:"reason,omitempty"` // why it was rejected (validation, a risk limit or guard, the broker)
+	OrderID       string  `json:"order_id,omitempty"`
+	ClientOrderID string  `json:"client_order_id,omitempty"`
+	Symbol        string  `json:"symbol"`
+	Side          string  `json:"side"`
+	Qty           string  `json:"qty"`
+	Type          string  `json:"type"`
+	LimitPrice    float64 `json:"limit_price,omitempty"`
+	OrderClass    string  `json:"order_class,omitempty"` // bracket, oco or oto; empty for a simple order
+	CorrelationID string  `json:"correlation_id,omitempty"`
+}
+
+// OrderRejectedEvent is an order the engine refused before it reached the broker because the broker
+// would have bounced it: a short sale in an asset that isn't shortable, or isn't easy to borrow.
+type OrderRejectedEvent struct {
+	Symbol        string  `json:"symbol"`
+	Side          string  `json:"side"`
+	Qty           string  `json:"qty"`
+	Type          string  `json:"type"`
+	ClientOrderID string  `json:"client_order_id,omitempty"`
+	CorrelationID string  `json:"correlation_id,omitempty"`
+	Reason        string  `json:"reason"` // not_shortable or hard_to_borrow
+	Detail        string  `json:"detail"`
+	Position      float64 `json:"position"` // signed qty held before the order
+	Shortable     bool    `json:"shortable"`
+	EasyToBorrow  bool    `json:"easy_to_borrow"`
+}
+
+// ControlEvent audits an operator action (pause, resume, flatten, kill) from the control endpoint or
+// the command stream, with the engine's state after it.
+type ControlEvent struct {
+	Action     string `json:"action"`
+	Source     string `json:"source"` // "http" or "command"
+	Reason     string `json:"reason,omitempty"`
+	OK         bool   `json:"ok"`
+	Error      string `json:"error,omitempty"`
+	Paused     bool   `json:"paused"`              // market data and signals withheld from the brain
+	KillSwitch bool   `json:"kill_switch"`         // new orders refused
+	Flattened  bool   `json:"flattened,omitempty"` // open orders cancelled and positions closed
+	Halting    bool   `json:"halting,omitempty"`   // the engine is shutting down
+}
+
+// ControlStatus is the engine's control state (GET /status on the control endpoint).
+type ControlStatus struct {
+	Paused     bool   `json:"paused"`
+	KillSwitch bool   `json:"kill_switch"`
+	KillReason string `json:"kill_reason,omitempty"`
+}
+
+// PnLEvent marks one held position to a trade print, with account totals at that moment. Sent on the
+// dedicated P&L stream, not to the brain.
+type PnLEvent struct {
+	Symbol            string  `json:"symbol"`
+	Price             float64 `json:"price"` // trade price used as the mark
+	Qty               float64 `json:"qty"`   // signed; negative = short
+	AvgEntryPrice     float64 `json:"avg_entry_price"`
+	MarketValue       float64 `json:"market_value"`
+	UnrealizedPL      float64 `json:"unrealized_pl"`
+	UnrealizedPLPC    float64 `json:"unrealized_plpc"`     // fraction of cost basis
+	TotalMarketValue  float64 `json:"total_market_value"`  // all held positions at their last marks
+	TotalUnrealizedPL float64 `json:"total_unrealized_pl"` // all held positions at their last marks
+	RealizedPL        float64 `json:"realized_pl"`         // from fills seen this trading day
+	TotalPL           float64 `json:"total_pl"`            // total unrealized + realized
+	DayPL             float64 `json:"day_pl"`              // realized + change in unrealized since the day (or the engine) started
+	Positions         int     `json:"positions"`           // number of held positions
+	Time              string  `json:"time"`                // trade time
+}
+
+// GapRecoveryEvent summarizes what happened while a stream was down, from REST, so the brain can
+// reconcile before acting on live data again.
+type GapRecoveryEvent struct {
+	Stream  string      `json:"stream"` // "price" or "news"
+	From    string      `json:"from"`   // RFC3339, when the stream dropped
+	To      string      `json:"to"`     // RFC3339, when it was back
+	GapSec  float64     `json:"gap_sec"`
+	Symbols []GapSymbol `json:"symbols"`
+	News    []NewsEvent `json:"news"` // articles published during the gap, oldest first
+}
+
+// GapEvent says the engine lost events, so a jump in seq or a silence can be told apart from a quiet
+// market. "reconnect": a market data stream was down from From to To and what it sent meanwhile is gone
+// (gap_recovery follows for long outages). "dropped": a sink or brain pipe lost events between From and
+// To, counted by cause.
+type GapEvent struct {
+	Reason    string  `json:"reason"`           // "reconnect" or "dropped"
+	Stream    string  `json:"stream,omitempty"` // reconnect: "price" or "news"
+	Sink      string  `json:"sink,omitempty"`   // dropped: sink or brain pipe name (brain, brain-2, redis:market:updates, ...)
+	From      string  `json:"from"`             // RFC3339
+	To        string  `json:"to"`
+	GapSec    float64 `json:"gap_sec"`
+	Dropped   uint64  `json:"dropped,omitempty"`   // evicted from a full queue
+	Expired   uint64  `json:"expired,omitempty"`   // outlived their TTL
+	Discarded uint64  `json:"discarded,omitempty"` // brain down with nowhere to buffer them
+}
+
+// ImpliedVolEvent is the implied volatility of an underlying's options (OPTIONS_UNDERLYINGS), next to the
+// realized volatility the engine computes from bars, from one refresh of the near-the-money chain.
+type ImpliedVolEvent struct {
+	Symbol      string        `json:"symbol"` // underlying
+	Spot        float64       `json:"spot"`
+	ATMIV       float64       `json:"atm_iv"`                 // annualized, nearest expiration at least a day out
+	ATMIV30d    float64       `json:"atm_iv_30d,omitempty"`   // interpolated to 30 calendar days in total variance
+	RealizedVol float64       `json:"realized_vol,omitempty"` // annualized_vol_30d, when the underlying is streamed
+	IVRVRatio   float64       `json:"iv_rv_ratio,omitempty"`  // atm_iv_30d / realized_vol
+	Term        []IVTermPoint `json:"term"`                   // ATM IV per expiration, nearest first
+	Contracts   int           `json:"contracts"`              // contracts in the chain window
+	Feed        string        `json:"feed"`                   // indicative or opra
+}
+
+// IVTermPoint is the at-the-money IV of one expiration.
+type IVTermPoint struct {
+	Expiry string  `json:"expiry"` // YYYY-MM-DD
+	Days   int     `json:"days"`
+	Strike float64 `json:"strike"`
+	IV     float64 `json:"iv"`
+}
+
+// OptionQuoteEvent is a quote on a streamed option contract (the ATM call or put of a near expiration).
+// IV and Delta are from the last chain refresh.
+type OptionQuoteEvent struct {
+	Symbol     string  `json:"symbol"` // underlying
+	Contract   string  `json:"contract"`
+	Expiry     string  `json:"expiry"` // YYYY-MM-DD
+	Right      string  `json:"right"`  // call or put
+	Strike     float64 `json:"strike"`
+	Bid        float64 `json:"bid"`
+	Ask        float64 `json:"ask"`
+	BidSize    int     `json:"bid_size"`
+	AskSize    int     `json:"ask_size"`
+	Mid        float64 `json:"mid"`
+	IV         float64 `json:"iv,omitempty"`
+	Delta      float64 `json:"delta,omitempty"`
+	ExchangeTS string  `json:"exchange_ts,omitempty"`
+	ReceivedTS string  `json:"received_ts,omitempty"`
+}
+
+// CorporateActionEvent is a split or cash dividend on a streamed symbol, recent or upcoming, sent at
+// startup. Daily bars are split-adjusted (BARS_ADJUSTMENT), so a split doesn't show up as volatility.
+type CorporateActionEvent struct {
+	Symbol      string  `json:"symbol"`
+	Action      string  `json:"action"` // forward_split, reverse_split or cash_dividend
+	ExDate      string  `json:"ex_date"`
+	RecordDate  string  `json:"record_date,omitempty"`
+	PayableDate string  `json:"payable_date,omitempty"`
+	DaysToEx    int     `json:"days_to_ex"`                 // negative once past
+	SplitRatio  float64 `json:"split_ratio,omitempty"`      // new shares per old share (10 for 10-for-1, 0.1 for 1-for-10)
+	Dividend    float64 `json:"dividend,omitempty"`         // cash per share
+	DividendPct float64 `json:"dividend_pct,omitempty"`     // dividend / last price
+	Special     bool    `json:"special_dividend,omitempty"` // one-off dividend
+}
+
+// AssetEvent is a configured symbol's asset flags from the trading API, sent at startup. Symbols that
+// aren't tradable (or unknown, status "unknown") are not streamed, so no order is sent only to be
+// rejected.
+type AssetEvent struct {
+	Symbol       string `json:"symbol"`
+	Name         string `json:"name,omitempty"`
+	Exchange     string `json:"exchange,omitempty"`
+	Status       string `json:"status"` // active, inactive or unknown
+	Tradable     bool   `json:"tradable"`
+	Shortable    bool   `json:"shortable"`
+	EasyToBorrow bool   `json:"easy_to_borrow"`
+	Fractionable bool   `json:"fractionable"`
+	Marginable   bool   `json:"marginable"`
+	Streamed     bool   `json:"streamed"` // false when dropped from the subscriptions
+}
+
+// GapScanEvent is the pre-market gap scan, sent once before the open (GAP_SCAN_AT): the streamed
+// symbols whose pre-market price is at least MinPct away from the previous close, largest gap first.
+type GapScanEvent struct {
+	Date    string         `json:"date"` // ET trading day
+	At      string         `json:"at"`   // RFC3339
+	MinPct  float64        `json:"min_pct"`
+	Scanned int            `json:"scanned"` // symbols with a previous close and a pre-market price
+	Gaps    []GapScanEntry `json:"gaps"`
+}
+
+// GapScanEntry is one gapping symbol of a gap_scan event.
+type GapScanEntry struct {
+	Symbol          string  `json:"symbol"`
+	PrevClose       float64 `json:"prev_close"`
+	Price           float64 `json:"price"`            // last pre-market trade
+	GapPct          float64 `json:"gap_pct"`          // price / prev_close - 1; negative for a gap down
+	PremarketVolume uint64  `json:"premarket_volume"` // shares since 04:00 ET
+	LastTradeTime   string  `json:"last_trade_time,omitempty"`
+}
+
+// CorrectionEvent is a correction or cancellation of an earlier trade. Action "correction" carries the
+// corrected price, size and conditions; "cancel" and "error" bust the trade. Applied says whether the
+// engine found the trade in its lookback window and fixed volume_1m/5m, returns and the day's VWAP.
+type CorrectionEvent struct {
+	Symbol              string   `json:"symbol"`
+	Action              string   `json:"action"` // "correction", "cancel" or "error"
+	OriginalID          int64    `json:"original_id,omitempty"`
+	OriginalPrice       float64  `json:"original_price"`
+	OriginalSize        int      `json:"original_size"`
+	OriginalConditions  []string `json:"original_conditions,omitempty"`
+	CorrectedID         int64    `json:"corrected_id,omitempty"`
+	CorrectedPrice      float64  `json:"corrected_price,omitempty"`
+	CorrectedSize       int      `json:"corrected_size,omitempty"`
+	CorrectedConditions []string `json:"corrected_conditions,omitempty"`
+	Exchange            string   `json:"exchange,omitempty"`
+	Tape                string   `json:"tape,omitempty"`
+	Applied             bool     `json:"applied"`
+	ExchangeTS          string   `json:"exchange_ts,omitempty"`
+	ReceivedTS          string   `json:"received_ts,omitempty"`
+}
+
+// HaltEvent is a trading status change for one symbol from the stock stream. "halt": trading stopped (a
+// regulatory or news halt, a LULD volatility pause or a market-wide circuit breaker), sent again when
+// quotes resume ahead of trading. "resume": trading resumed. Quotes in between carry halted=true.
+type HaltEvent struct {
+	Symbol        string  `json:"symbol"`
+	Status        string  `json:"status"`                   // H halt, P LULD pause, Q quotation resumption, T trading resumption (tape C); 2 halt, 3 resume (tapes A and B)
+	StatusMessage string  `json:"status_message,omitempty"` // e.g. "Trading Halt"
+	Reason        string  `json:"reason,omitempty"`         // e.g. T1 news pending, LUDP LULD pause, MWC1 circuit breaker
+	ReasonMessage string  `json:"reason_message,omitempty"`
+	Tape          string  `json:"tape,omitempty"`
+	Since         string  `json:"since,omitempty"`      // RFC3339Nano, when the halt began (if the engine saw it)
+	HaltedSec     float64 `json:"halted_sec,omitempty"` // resume: how long trading was halted
+	ExchangeTS    string  `json:"exchange_ts,omitempty"`
+	ReceivedTS    string  `json:"received_ts,omitempty"`
+}
+
+// GapSymbol is one symbol's change over a stream gap. Price fields are 0 when unknown.
+type GapSymbol struct {
+	Symbol      string  `json:"symbol"`
+	PriceBefore float64 `json:"price_before"` // last price seen before the gap
+	PriceAfter  float64 `json:"price_after"`  // latest trade after it
+	Change      float64 `json:"change"`
+	Return      float64 `json:"return"` // Change / PriceBefore, a fraction like return_1m
+	High        float64 `json:"high"`   // from 1-minute bars during the gap
+	Low         float64 `json:"low"`
+	Volume      uint64  `json:"volume"`
+	News        int     `json:"news"` // articles during the gap mentioning the symbol
+}
+
+// RiskReportEvent is the periodic risk summary.
+type RiskReportEvent struct {
+	Budget *BudgetUsage `json:"budget,omitempty"`
+}
+
+// EventTypeStats counts the events of one type dispatched to the sinks and how long handing them over took.
+type EventTypeStats struct {
+	Count     uint64  `json:"count"`
+	AvgSendMs float64 `json:"avg_send_ms"`
+	MaxSendMs float64 `json:"max_send_ms"`
+}
+
+// EngineStatsEvent is the engine health summary for the session so far, sent hourly and once at shutdown
+// (Final). Brain counters are summed over every brain.
+type EngineStatsEvent struct {
+	Started       string                    `json:"started"`
+	UptimeSec     float64                   `json:"uptime_sec"`
+	Final         bool                      `json:"final"`
+	Events        map[string]EventTypeStats `json:"events"`
+	Enqueued      uint64                    `json:"enqueued"`
+	Sent          uint64                    `json:"sent"`
+	Dropped       uint64                    `json:"dropped"`   // queue overflow
+	Discarded     uint64                    `json:"discarded"` // lost while a brain was down
+	PublishErrors uint64                    `json:"publish_errors"`
+	Expired       uint64                    `json:"expired"`                     // dropped unsent for outliving their TTL (EVENT_TTL_MS)
+	StaleTicks    uint64                    `json:"stale_ticks,omitempty"`       // trades and quotes past STALE_TICK_MS, dropped or flagged
+	CondSkipped   uint64                    `json:"condition_skipped,omitempty"` // trades that don't update the last price (TRADE_CONDITION_FILTER)
+	RESTRetries   uint64                    `json:"rest_retries"`                // Alpaca REST requests sent again (data + trading)
+	RESTLimited   uint64                    `json:"rest_rate_limited"`           // 429 responses
+	RESTGaveUp    uint64                    `json:"rest_gave_up"`                // calls that failed after every retry
+	NewsEnriched  uint64                    `json:"news_enriched,omitempty"`     // news events enriched by NEWS_ENRICH_URL
+	NewsEnrichErr uint64                    `json:"news_unenriched,omitempty"`   // ...and sent without after a failure or timeout
+	BrainRestarts uint64                    `json:"brain_restarts"`
+	Reconnects    map[string]uint64         `json:"reconnects"` // per market data / account stream
+	Sinks         map[string]SinkStats      `json:"sinks"`      // per event output, by sink name
+}
+
+// SinkStats are cumulative counters and current health for one event output (brain, Redis, Kafka, ...).
+type SinkStats struct {
+	Published uint64 `json:"published"` // events delivered
+	Dropped   uint64 `json:"dropped"`   // events evicted or lost because the sink was behind or down
+	Errors    uint64 `json:"errors"`    // events that failed to encode or write
+	Healthy   bool   `json:"healthy"`   // last write succeeded (brain: at least one brain is up)
+
+	// Batched sinks (Redis, Kafka, file): writes, events per write and time per write
+	Batches    uint64  `json:"batches,omitempty"`
+	AvgBatch   float64 `json:"avg_batch,omitempty"`
+	AvgWriteMs float64 `json:"avg_write_ms,omitempty"`
+	MaxWriteMs float64 `json:"max_write_ms,omitempty"`
+
+	// Events dropped by EVENT_FILTERS rules before they reached the sink
+	Filtered uint64 `json:"filtered,omitempty"`
+
+	// Events EVENT_ROUTES kept from the sink: types not routed to it, or sampled out
+	Unrouted uint64 `json:"unrouted,omitempty"`
+
+	// Failed events waiting in the sink's outbox to be retried (Redis)
+	Pending int64 `json:"pending,omitempty"`
+
+	// Events dropped unsent because they outlived their TTL (EVENT_TTL_MS) before the sink got to them
+	Expired uint64 `json:"expired,omitempty"`
+}
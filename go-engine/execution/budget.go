@@ -0,0 +1,279 @@
+package execution
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sunnyp94/sentry-bridge/go-engine/alpaca"
+	"github.com/sunnyp94/sentry-bridge/go-engine/brain"
+	"github.com/sunnyp94/sentry-bridge/go-engine/clock"
+	"github.com/sunnyp94/sentry-bridge/go-engine/events"
+)
+
+// ErrBudgetExceeded is returned (wrapped) when an entry would exceed the position or entry budget.
+var ErrBudgetExceeded = errors.New("order blocked by entry budget")
+
+// openingTTL forgets an unfilled opening order that produced no trade update or position by then.
+const openingTTL = 10 * time.Minute
+
+// BudgetConfig limits how fast capital is put to work account-wide. Zero disables a limit.
+type BudgetConfig struct {
+	MaxPositions      int     // Open positions, counting entries still working in new symbols
+	MaxEntriesPerHour int     // Entry orders in the rolling hour
+	MaxEntriesPerDay  int     // Entry orders per ET day
+	MaxCapitalPerHour float64 // Entry notional (qty x limit or last price) in the rolling hour
+}
+
+// PriceFunc returns the last price for symbol (0 if unknown).
+type PriceFunc func(symbol string) float64
+
+// entry is one accepted entry order in the rolling window.
+type entry struct {
+	at       time.Time
+	notional float64
+}
+
+// opening is a symbol with accepted entry orders but no position yet.
+type opening struct {
+	orders map[string]bool // working entry order IDs
+	at     time.Time
+}
+
+// Budget wraps an order placer and enforces global entry constraints before submission. Positions come
+// from the positions poll and trade updates, so positions opened outside the engine count too.
+type Budget struct {
+	cfg   BudgetConfig
+	next  alpaca.OrderPlacer
+	price PriceFunc
+	clock clock.Clock
+
+	mu       sync.Mutex
+	pos      map[string]float64 // signed position qty
+	opening  map[string]opening
+	recent   []entry // entries in the last hour, oldest first
+	day      string
+	today    int
+	rejected uint64
+}
+
+// NewBudget wraps next; price values market orders for the capital limit.
+func NewBudget(cfg BudgetConfig, next alpaca.OrderPlacer, price PriceFunc) *Budget {
+	return &Budget{cfg: cfg, next: next, price: price, clock: clock.Real{}, pos: make(map[string]float64), opening: make(map[string]opening)}
+}
+
+// SetClock replaces the clock for the rolling hour and ET day. Call before use.
+func (b *Budget) SetClock(c clock.Clock) { b.clock = c }
+
+// PlaceOrder forwards exits unchanged and checks entries against every limit. The entry is reserved
+// while the order is submitted and released if the broker rejects it.
+func (b *Budget) PlaceOrder(req alpaca.OrderRequest) (*alpaca.Order, error) {
+	symbol := strings.ToUpper(req.Symbol)
+	now := b.clock.Now()
+	b.mu.Lock()
+	pos := b.pos[symbol]
+	buy := strings.EqualFold(req.Side, "buy")
+	if (buy && pos < 0) || (!buy && pos > 0) {
+		b.mu.Unlock()
+		return b.next.PlaceOrder(req)
+	}
+	b.rollLocked(now)
+	_, pending := b.opening[symbol]
+	newSymbol := pos == 0 && !pending
+	notional := b.notional(req, symbol)
+	if err := b.checkLocked(req, symbol, newSymbol, notional); err != nil {
+		b.rejected++
+		b.mu.Unlock()
+		return nil, err
+	}
+	b.recent = append(b.recent, entry{at: now, notional: notional})
+	b.today++
+	if newSymbol {
+		b.opening[symbol] = opening{orders: make(map[string]bool), at: now}
+	}
+	b.mu.Unlock()
+
+	o, err := b.next.PlaceOrder(req)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err != nil {
+		for i := len(b.recent) - 1; i >= 0; i-- {
+			if b.recent[i].at.Equal(now) {
+				b.recent = append(b.recent[:i], b.recent[i+1:]...)
+				break
+			}
+		}
+		if b.today > 0 {
+			b.today--
+		}
+		if newSymbol {
+			delete(b.opening, symbol)
+		}
+		return o, err
+	}
+	if op, ok := b.opening[symbol]; ok && b.pos[symbol] == 0 {
+		op.orders[o.ID] = true
+	}
+	return o, nil
+}
+
+// CheckOrder applies the budget limits to an entry without reserving anything, then the checks further
+// down the chain.
+func (b *Budget) CheckOrder(req alpaca.OrderRequest) (alpaca.OrderRequest, error) {
+	symbol := strings.ToUpper(req.Symbol)
+	b.mu.Lock()
+	pos := b.pos[symbol]
+	buy := strings.EqualFold(req.Side, "buy")
+	if exit := (buy && pos < 0) || (!buy && pos > 0); !exit {
+		b.rollLocked(b.clock.Now())
+		_, pending := b.opening[symbol]
+		if err := b.checkLocked(req, symbol, pos == 0 && !pending, b.notional(req, symbol)); err != nil {
+			b.mu.Unlock()
+			return req, err
+		}
+	}
+	b.mu.Unlock()
+	return alpaca.CheckOrder(b.next, req)
+}
+
+// checkLocked returns the first limit the entry would exceed.
+func (b *Budget) checkLocked(req alpaca.OrderRequest, symbol string, newSymbol bool, notional float64) error {
+	refuse := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w: %s %s: %s", ErrBudgetExceeded, req.Side, symbol, fmt.Sprintf(format, args...))
+	}
+	if newSymbol && b.cfg.MaxPositions > 0 {
+		if n := b.openLocked(); n >= b.cfg.MaxPositions {
+			return refuse("%d open positions (max %d)", n, b.cfg.MaxPositions)
+		}
+	}
+	if b.cfg.MaxEntriesPerHour > 0 && len(b.recent) >= b.cfg.MaxEntriesPerHour {
+		return refuse("%d entries in the last hour (max %d)", len(b.recent), b.cfg.MaxEntriesPerHour)
+	}
+	if b.cfg.MaxEntriesPerDay > 0 && b.today >= b.cfg.MaxEntriesPerDay {
+		return refuse("%d entries today (max %d)", b.today, b.cfg.MaxEntriesPerDay)
+	}
+	if b.cfg.MaxCapitalPerHour > 0 {
+		if notional <= 0 {
+			return refuse("no price to check the hourly capital limit")
+		}
+		if used := b.capitalLocked(); used+notional > b.cfg.MaxCapitalPerHour {
+			return refuse("$%.2f deployed in the last hour + $%.2f exceeds $%.2f", used, notional, b.cfg.MaxCapitalPerHour)
+		}
+	}
+	return nil
+}
+
+// notional is qty x limit price (or the last price for market/stop orders); 0 if unknown.
+func (b *Budget) notional(req alpaca.OrderRequest, symbol string) float64 {
+	qty, err := strconv.ParseFloat(req.Qty, 64)
+	if err != nil || qty <= 0 {
+		return 0
+	}
+	px := req.LimitPrice
+	if px <= 0 && b.price != nil {
+		px = b.price(symbol)
+	}
+	return qty * px
+}
+
+// OnTradeUpdate follows fills and clears openings whose order ended without a position.
+func (b *Budget) OnTradeUpdate(u alpaca.TradeUpdate) {
+	symbol := strings.ToUpper(u.Order.Symbol)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch u.Event {
+	case "fill", "partial_fill":
+		if u.PositionQty != nil {
+			b.setPosLocked(symbol, u.PositionQty.Value())
+		}
+	case "canceled", "expired", "rejected", "done_for_day":
+		if op, ok := b.opening[symbol]; ok && op.orders[u.Order.ID] {
+			delete(op.orders, u.Order.ID)
+			if len(op.orders) == 0 {
+				delete(b.opening, symbol)
+			}
+		}
+	}
+}
+
+// SyncPositions replaces tracked positions with the broker's and drops stale openings.
+func (b *Budget) SyncPositions(positions []alpaca.Position) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.pos = make(map[string]float64, len(positions))
+	for _, p := range positions {
+		if qty, err := strconv.ParseFloat(p.Qty, 64); err == nil {
+			if p.Side == "short" && qty > 0 {
+				qty = -qty
+			}
+			b.setPosLocked(strings.ToUpper(p.Symbol), qty)
+		}
+	}
+	for symbol, op := range b.opening {
+		if clock.Since(b.clock, op.at) > openingTTL {
+			delete(b.opening, symbol)
+		}
+	}
+}
+
+// Usage returns current budget usage for the risk report.
+func (b *Budget) Usage() events.BudgetUsage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rollLocked(b.clock.Now())
+	return events.BudgetUsage{
+		OpenPositions:     b.openLocked(),
+		MaxPositions:      b.cfg.MaxPositions,
+		EntriesLastHour:   len(b.recent),
+		MaxEntriesPerHour: b.cfg.MaxEntriesPerHour,
+		EntriesToday:      b.today,
+		MaxEntriesPerDay:  b.cfg.MaxEntriesPerDay,
+		CapitalLastHour:   b.capitalLocked(),
+		MaxCapitalPerHour: b.cfg.MaxCapitalPerHour,
+		Rejected:          b.rejected,
+	}
+}
+
+func (b *Budget) setPosLocked(symbol string, qty float64) {
+	if qty == 0 {
+		delete(b.pos, symbol)
+		return
+	}
+	b.pos[symbol] = qty
+	delete(b.opening, symbol)
+}
+
+// openLocked counts positions plus openings in symbols without one.
+func (b *Budget) openLocked() int {
+	n := len(b.pos)
+	for symbol := range b.opening {
+		if b.pos[symbol] == 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func (b *Budget) capitalLocked() float64 {
+	var sum float64
+	for _, e := range b.recent {
+		sum += e.notional
+	}
+	return sum
+}
+
+// rollLocked drops entries older than an hour and resets the daily count at the ET date change.
+func (b *Budget) rollLocked(now time.Time) {
+	cut := 0
+	for cut < len(b.recent) && now.Sub(b.recent[cut].at) >= time.Hour {
+		cut++
+	}
+	b.recent = b.recent[cut:]
+	if day := now.In(brain.Eastern()).Format("2006-01-02"); day != b.day {
+		b.day = day
+		b.today = 0
+	}
+}
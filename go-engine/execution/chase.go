@@ -0,0 +1,281 @@
+// Package execution is the engine's order gateway: logic that sits between order intents and the broker
+// and manages orders once they are working.
+package execution
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sunnyp94/sentry-bridge/go-engine/alpaca"
+	"github.com/sunnyp94/sentry-bridge/go-engine/clock"
+	"github.com/sunnyp94/sentry-bridge/go-engine/events"
+)
+
+// Chase actions for resting limit orders that haven't filled within the timeout.
+const (
+	ChaseOff     = "off"
+	ChaseCancel  = "cancel"  // cancel the unfilled remainder
+	ChaseReprice = "reprice" // move the limit to the touch (ask for buys, bid for sells); cancel after MaxReprices
+	ChaseMarket  = "market"  // cancel, then send a market order for the unfilled remainder
+)
+
+// chaseTick is how often working orders are checked against the timeout.
+const chaseTick = time.Second
+
+// ChaseConfig configures the Chaser.
+type ChaseConfig struct {
+	Action      string        // ChaseCancel, ChaseReprice or ChaseMarket
+	Timeout     time.Duration // Time since submit (or last reprice) before acting
+	MaxReprices int           // ChaseReprice: cancel after this many reprices
+	// ClientOrderPrefixes are the client order ID prefixes of the orders the engine and brain place; orders
+	// without one (manual orders, other tools on the account) are never chased
+	ClientOrderPrefixes []string
+}
+
+// OrderAmender cancels and replaces working orders (the broker).
+type OrderAmender interface {
+	CancelOrder(id string) error
+	ReplaceOrder(id string, req alpaca.ReplaceRequest) (*alpaca.Order, error)
+}
+
+// QuoteFunc returns the latest bid/ask for symbol.
+type QuoteFunc func(symbol string) (bid, ask float64, ok bool)
+
+// chased is one working limit order.
+type chased struct {
+	order    alpaca.Order
+	since    time.Time // submitted or last repriced
+	reprices int
+	busy     bool // an action is in flight
+	convert  bool // canceled for ChaseMarket; send the remainder as market once the cancel lands
+}
+
+// Chaser watches limit orders via trade updates and, once one has rested past the timeout, cancels,
+// reprices or converts it to market. Every action is logged and reported through OnAction.
+type Chaser struct {
+	cfg    ChaseConfig
+	amend  OrderAmender
+	placer alpaca.OrderPlacer
+	quote  QuoteFunc
+	clock  clock.Clock
+
+	// OnAction receives every chase action (optional).
+	OnAction func(events.OrderChaseEvent)
+
+	mu     sync.Mutex
+	orders map[string]*chased
+}
+
+// NewChaser creates a chaser. placer sends the market order for ChaseMarket conversions.
+func NewChaser(cfg ChaseConfig, amend OrderAmender, placer alpaca.OrderPlacer, quote QuoteFunc) *Chaser {
+	return &Chaser{cfg: cfg, amend: amend, placer: placer, quote: quote, clock: clock.Real{}, orders: make(map[string]*chased)}
+}
+
+// SetClock replaces the clock that times working orders. Call before Run.
+func (c *Chaser) SetClock(clk clock.Clock) { c.clock = clk }
+
+// OnTradeUpdate tracks limit orders from the trading stream and forgets them when they finish. Only simple
+// day orders in regular hours with one of the configured client order ID prefixes are chased: a GTC order
+// (such as a take-profit) and an extended-hours order are meant to rest.
+func (c *Chaser) OnTradeUpdate(u alpaca.TradeUpdate) {
+	o := u.Order
+	c.mu.Lock()
+	t, tracked := c.orders[o.ID]
+	switch u.Event {
+	case "new", "accepted", "pending_new":
+		// An advanced order's resting legs are its protection, not entries waiting for a fill
+		if !tracked && c.chases(o) {
+			c.orders[o.ID] = &chased{order: o, since: c.clock.Now()}
+		}
+		c.mu.Unlock()
+	case "partial_fill":
+		if tracked {
+			t.order = o
+		}
+		c.mu.Unlock()
+	case "fill", "canceled", "expired", "rejected", "done_for_day", "replaced":
+		if !tracked {
+			c.mu.Unlock()
+			return
+		}
+		delete(c.orders, o.ID)
+		convert := t.convert && u.Event == "canceled"
+		c.mu.Unlock()
+		if convert {
+			c.sendMarket(o)
+		}
+	default:
+		c.mu.Unlock()
+	}
+}
+
+// chases reports whether o is an order the chaser acts on.
+func (c *Chaser) chases(o alpaca.Order) bool {
+	if o.Type != "limit" || (o.OrderClass != "" && o.OrderClass != "simple") {
+		return false
+	}
+	if !strings.EqualFold(o.TimeInForce, "day") || o.ExtendedHours {
+		return false
+	}
+	for _, p := range c.cfg.ClientOrderPrefixes {
+		if strings.HasPrefix(o.ClientOrderID, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Run checks working orders every second until ctx is done.
+func (c *Chaser) Run(ctx context.Context) {
+	ticker := time.NewTicker(chaseTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, t := range c.due(c.clock.Now()) {
+				c.act(t)
+			}
+		}
+	}
+}
+
+// due returns orders past the timeout and marks them busy.
+func (c *Chaser) due(now time.Time) []*chased {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var out []*chased
+	for _, t := range c.orders {
+		if !t.busy && now.Sub(t.since) >= c.cfg.Timeout {
+			t.busy = true
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// act applies the configured action to one overdue order.
+func (c *Chaser) act(t *chased) {
+	c.mu.Lock()
+	o, reprices := t.order, t.reprices
+	c.mu.Unlock()
+	switch c.cfg.Action {
+	case ChaseCancel:
+		c.cancel(t, o, ChaseCancel, "timeout")
+	case ChaseMarket:
+		c.mu.Lock()
+		t.convert = true
+		c.mu.Unlock()
+		c.cancel(t, o, "cancel_for_market", "timeout")
+	case ChaseReprice:
+		if c.cfg.MaxReprices > 0 && reprices >= c.cfg.MaxReprices {
+			c.cancel(t, o, ChaseCancel, "max_reprices")
+			return
+		}
+		bid, ask, ok := c.quote(o.Symbol)
+		target := ask
+		if o.Side == "sell" {
+			target = bid
+		}
+		if !ok || target <= 0 || target == o.LimitPrice.Value() {
+			c.release(t)
+			return
+		}
+		newOrder, err := c.amend.ReplaceOrder(o.ID, alpaca.ReplaceRequest{LimitPrice: target})
+		ev := chaseEvent(o, ChaseReprice, "timeout")
+		ev.NewLimitPrice = target
+		if err != nil {
+			ev.Error = err.Error()
+			c.report(ev)
+			c.release(t)
+			return
+		}
+		ev.NewOrderID = newOrder.ID
+		c.report(ev)
+		c.mu.Lock()
+		delete(c.orders, o.ID)
+		c.orders[newOrder.ID] = &chased{order: *newOrder, since: c.clock.Now(), reprices: reprices + 1}
+		c.mu.Unlock()
+	default:
+		c.release(t)
+	}
+}
+
+// cancel requests cancellation; the order is dropped from tracking when the "canceled" update arrives.
+func (c *Chaser) cancel(t *chased, o alpaca.Order, action, reason string) {
+	ev := chaseEvent(o, action, reason)
+	if err := c.amend.CancelOrder(o.ID); err != nil {
+		ev.Error = err.Error()
+		c.mu.Lock()
+		t.convert = false
+		c.mu.Unlock()
+		c.release(t)
+	}
+	c.report(ev)
+}
+
+// release makes t eligible again after another full timeout.
+func (c *Chaser) release(t *chased) {
+	c.mu.Lock()
+	t.busy = false
+	t.since = c.clock.Now()
+	c.mu.Unlock()
+}
+
+// sendMarket places a market order for the unfilled remainder of a canceled order.
+func (c *Chaser) sendMarket(o alpaca.Order) {
+	qty, _ := strconv.ParseFloat(o.Qty, 64)
+	filled, _ := strconv.ParseFloat(o.FilledQty, 64)
+	remaining := qty - filled
+	if remaining <= 0 {
+		return
+	}
+	ev := chaseEvent(o, ChaseMarket, "timeout")
+	ev.Qty = strconv.FormatFloat(remaining, 'f', -1, 64)
+	newOrder, err := c.placer.PlaceOrder(alpaca.OrderRequest{
+		Symbol:      o.Symbol,
+		Qty:         ev.Qty,
+		Side:        o.Side,
+		Type:        "market",
+		TimeInForce: "day",
+		Source:      "chase",
+	})
+	if err != nil {
+		ev.Error = err.Error()
+	} else {
+		ev.NewOrderID = newOrder.ID
+	}
+	c.report(ev)
+}
+
+// report logs the action and passes it to OnAction.
+func (c *Chaser) report(ev events.OrderChaseEvent) {
+	if ev.Error != "" {
+		slog.Error("order chase", "action", ev.Action, "order_id", ev.OrderID, "symbol", ev.Symbol, "reason", ev.Reason, "err", ev.Error)
+	} else {
+		slog.Info("order chase", "action", ev.Action, "order_id", ev.OrderID, "symbol", ev.Symbol, "reason", ev.Reason,
+			"filled_qty", ev.FilledQty, "qty", ev.Qty, "limit", ev.LimitPrice, "new_limit", ev.NewLimitPrice, "new_order_id", ev.NewOrderID)
+	}
+	if c.OnAction != nil {
+		c.OnAction(ev)
+	}
+}
+
+func chaseEvent(o alpaca.Order, action, reason string) events.OrderChaseEvent {
+	return events.OrderChaseEvent{
+		OrderID:       o.ID,
+		ClientOrderID: o.ClientOrderID,
+		Symbol:        o.Symbol,
+		Side:          o.Side,
+		Action:        action,
+		Reason:        reason,
+		Qty:           o.Qty,
+		FilledQty:     o.FilledQty,
+		LimitPrice:    o.LimitPrice.Value(),
+	}
+}
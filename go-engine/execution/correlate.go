@@ -0,0 +1,95 @@
+package execution
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/sunnyp94/sentry-bridge/go-engine/alpaca"
+)
+
+// Correlations wraps the broker and remembers the correlation ID of each order placed through it: the ID
+// of the market event the intent came from (an order's correlation_id), or the one the audit log gave
+// it. Trade updates are tied back through it, so a fill can be traced to the tick or news item behind it.
+// A replacement order inherits the ID of the order it replaces. Orders placed straight at the broker (the
+// Python brain's) carry the ID in their client order ID instead, as ev:<correlation ID>:<nonce>.
+type Correlations struct {
+	next alpaca.OrderPlacer
+
+	mu     sync.Mutex
+	orders map[string]string // broker order ID -> correlation ID, until the order is done
+}
+
+// NewCorrelations wraps next (the broker).
+func NewCorrelations(next alpaca.OrderPlacer) *Correlations {
+	return &Correlations{next: next, orders: make(map[string]string)}
+}
+
+// PlaceOrder forwards req and remembers its correlation ID for the order and its legs.
+func (c *Correlations) PlaceOrder(req alpaca.OrderRequest) (*alpaca.Order, error) {
+	o, err := c.next.PlaceOrder(req)
+	if err == nil && o != nil && o.ID != "" && req.CorrelationID != "" {
+		c.mu.Lock()
+		c.orders[o.ID] = req.CorrelationID
+		for _, leg := range o.Legs {
+			c.orders[leg.ID] = req.CorrelationID
+		}
+		c.mu.Unlock()
+	}
+	return o, err
+}
+
+// CheckOrder has nothing to check; it asks the placer it wraps.
+func (c *Correlations) CheckOrder(req alpaca.OrderRequest) (alpaca.OrderRequest, error) {
+	return alpaca.CheckOrder(c.next, req)
+}
+
+// Of returns the correlation ID of a broker order; "" when it wasn't placed through c with one.
+func (c *Correlations) Of(orderID string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.orders[orderID]
+}
+
+// OnTradeUpdate returns the correlation ID of the update's order, passing it on to a replacement, and
+// forgets the order once it is done.
+func (c *Correlations) OnTradeUpdate(u alpaca.TradeUpdate) string {
+	o := u.Order
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	id, ok := c.orders[o.ID]
+	if !ok && o.Replaces != "" {
+		if id, ok = c.orders[o.Replaces]; ok {
+			c.orders[o.ID] = id
+		}
+	}
+	if !ok {
+		if id, ok = FromClientOrderID(o.ClientOrderID); !ok {
+			return ""
+		}
+		c.orders[o.ID] = id
+	}
+	switch u.Event {
+	case "replaced":
+		if o.ReplacedBy != "" {
+			c.orders[o.ReplacedBy] = id
+		}
+		delete(c.orders, o.ID)
+	case "fill", "canceled", "expired", "rejected", "done_for_day":
+		delete(c.orders, o.ID)
+	}
+	return id
+}
+
+// FromClientOrderID returns the correlation ID carried in a client order ID of the form
+// ev:<correlation ID>:<nonce>.
+func FromClientOrderID(clientOrderID string) (string, bool) {
+	rest, ok := strings.CutPrefix(clientOrderID, "ev:")
+	if !ok {
+		return "", false
+	}
+	i := strings.LastIndexByte(rest, ':')
+	if i <= 0 {
+		return "", false
+	}
+	return rest[:i], true
+}
@@ -0,0 +1,104 @@
+package execution
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/sunnyp94/sentry-bridge/go-engine/events"
+)
+
+// DefaultCostSymbol is the cost table row used for symbols without their own.
+const DefaultCostSymbol = "*"
+
+// CostTable holds per-symbol trading costs so sizing and downstream risk see realistic per-name costs
+// instead of a flat assumption. A nil table has no costs.
+type CostTable map[string]events.SymbolCost
+
+// LoadCostTable reads a CSV with a header row: symbol, then any of slippage_bps, fee_bps and
+// borrow_rate_pct (other columns are ignored, empty cells are 0). A "*" row is the default for symbols
+// not listed. A row with fewer fields than the header is an error.
+func LoadCostTable(path string) (CostTable, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	r.Comment = '#'
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("cost table %s: header: %w", path, err)
+	}
+	col := make(map[string]int, len(header))
+	for i, h := range header {
+		col[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	if _, ok := col["symbol"]; !ok {
+		return nil, fmt.Errorf("cost table %s: no symbol column", path)
+	}
+	t := make(CostTable)
+	for line := 2; ; line++ {
+		rec, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return t, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cost table %s: %w", path, err)
+		}
+		if len(rec) < len(header) {
+			return nil, fmt.Errorf("cost table %s line %d: %d fields, header has %d", path, line, len(rec), len(header))
+		}
+		num := func(name string) (float64, error) {
+			i, ok := col[name]
+			if !ok || strings.TrimSpace(rec[i]) == "" {
+				return 0, nil
+			}
+			v, err := strconv.ParseFloat(strings.TrimSpace(rec[i]), 64)
+			if err != nil {
+				return 0, fmt.Errorf("cost table %s line %d: %s: %w", path, line, name, err)
+			}
+			return v, nil
+		}
+		symbol := strings.ToUpper(strings.TrimSpace(rec[col["symbol"]]))
+		if symbol == "" {
+			continue
+		}
+		var c events.SymbolCost
+		if c.SlippageBps, err = num("slippage_bps"); err != nil {
+			return nil, err
+		}
+		if c.FeeBps, err = num("fee_bps"); err != nil {
+			return nil, err
+		}
+		if c.BorrowRatePct, err = num("borrow_rate_pct"); err != nil {
+			return nil, err
+		}
+		t[symbol] = c
+	}
+}
+
+// Lookup returns the costs for symbol, else the default row; false when neither exists.
+func (t CostTable) Lookup(symbol string) (events.SymbolCost, bool) {
+	if c, ok := t[strings.ToUpper(symbol)]; ok {
+		return c, true
+	}
+	c, ok := t[DefaultCostSymbol]
+	return c, ok
+}
+
+// perShare is the expected cost per share of a round trip at price: slippage and fees on entry and exit,
+// plus one day of borrow for a short.
+func perShare(c events.SymbolCost, price float64, short bool) float64 {
+	cost := price * 2 * (c.SlippageBps + c.FeeBps) / 10000
+	if short {
+		cost += price * c.BorrowRatePct / 100 / 360
+	}
+	return cost
+}
@@ -0,0 +1,155 @@
+package execution
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sunnyp94/sentry-bridge/go-engine/alpaca"
+	"github.com/sunnyp94/sentry-bridge/go-engine/brain"
+)
+
+// Dry-run outcomes.
+const (
+	OutcomeAccepted = "accepted" // the order would go to the broker as requested (or as sized)
+	OutcomeResized  = "resized"  // the requested quantity exceeds the sizing caps; Order has the allowed qty
+	OutcomeRejected = "rejected" // a limit or market rule refuses the order; Reason says which
+)
+
+// OrderIntent is a hypothetical order: an intent for the sizer plus the order details the gateway checks.
+type OrderIntent struct {
+	Intent
+	Qty           float64 `json:"qty,omitempty"`  // Requested shares; 0 = whatever the sizer allows
+	Type          string  `json:"type,omitempty"` // "market" (default) or "limit"
+	LimitPrice    float64 `json:"limit_price,omitempty"`
+	TimeInForce   string  `json:"time_in_force,omitempty"` // default "day"
+	ExtendedHours bool    `json:"extended_hours,omitempty"`
+}
+
+// Evaluation is what would happen to an OrderIntent. Order is the request as the broker would receive
+// it, after sizing and any session adjustments (e.g. extended_hours set in convert mode).
+type Evaluation struct {
+	Outcome string               `json:"outcome"`
+	Reason  string               `json:"reason,omitempty"`
+	Size    *Size                `json:"size,omitempty"`
+	Order   *alpaca.OrderRequest `json:"order,omitempty"`
+	Notes   []string             `json:"notes,omitempty"`
+}
+
+// DryRun evaluates order intents against the sizing policy (risk, position and buying-power caps) and
+// the order gateway (trading hours, re-entry policy, entry budget) without submitting anything.
+type DryRun struct {
+	sizer   *Sizer
+	gateway alpaca.OrderPlacer
+
+	// Position returns the signed position in symbol (negative = short), so exits are not resized by
+	// the entry caps. Optional; without it every order is sized as an entry.
+	Position func(symbol string) float64
+}
+
+// NewDryRun evaluates against sizer and the guards in gateway (the engine's order placer chain).
+func NewDryRun(sizer *Sizer, gateway alpaca.OrderPlacer) *DryRun {
+	return &DryRun{sizer: sizer, gateway: gateway}
+}
+
+// Evaluate sizes in, builds the order and runs it through every gateway check.
+func (d *DryRun) Evaluate(in OrderIntent) Evaluation {
+	reject := func(format string, args ...interface{}) Evaluation {
+		return Evaluation{Outcome: OutcomeRejected, Reason: fmt.Sprintf(format, args...)}
+	}
+	side, err := normalizeSide(in.Side)
+	if err != nil {
+		return reject("%v", err)
+	}
+	symbol := strings.ToUpper(strings.TrimSpace(in.Symbol))
+	if symbol == "" {
+		return reject("symbol required")
+	}
+	if in.Price <= 0 && in.LimitPrice > 0 {
+		in.Price = in.LimitPrice
+	}
+	var ev Evaluation
+	qty := in.Qty
+	if qty > 0 && d.Position != nil {
+		if pos := d.Position(symbol); (side == "buy" && pos < 0) || (side == "sell" && pos > 0) {
+			ev.Notes = append(ev.Notes, fmt.Sprintf("reduces the %g share position; not sized", pos))
+			return d.check(ev, symbol, side, qty, in)
+		}
+	}
+	size, err := d.sizer.Size(in.Intent)
+	switch {
+	case err != nil && qty <= 0:
+		return reject("sizing: %v", err)
+	case err != nil:
+		ev.Notes = append(ev.Notes, "not sized: "+err.Error())
+	default:
+		ev.Size = &size
+		if qty <= 0 {
+			qty = size.Qty
+		} else if size.Qty < qty {
+			ev.Outcome, ev.Reason = OutcomeResized, fmt.Sprintf("%s limit allows %g of %g shares", size.Limit, size.Qty, qty)
+			qty = size.Qty
+		}
+	}
+	if qty <= 0 {
+		ev.Outcome, ev.Reason = OutcomeRejected, fmt.Sprintf("sizing leaves nothing to trade (%s limit)", size.Limit)
+		return ev
+	}
+	return d.check(ev, symbol, side, qty, in)
+}
+
+// check builds the order for qty and runs it through the gateway.
+func (d *DryRun) check(ev Evaluation, symbol, side string, qty float64, in OrderIntent) Evaluation {
+	req := alpaca.OrderRequest{
+		Symbol:        symbol,
+		Qty:           strconv.FormatFloat(qty, 'f', -1, 64),
+		Side:          side,
+		Type:          strings.ToLower(in.Type),
+		TimeInForce:   strings.ToLower(in.TimeInForce),
+		LimitPrice:    in.LimitPrice,
+		ExtendedHours: in.ExtendedHours,
+	}
+	if req.Type == "" {
+		req.Type = "market"
+	}
+	if req.TimeInForce == "" {
+		req.TimeInForce = "day"
+	}
+	if req.Type == "limit" && req.LimitPrice <= 0 {
+		ev.Outcome, ev.Reason = OutcomeRejected, "limit order needs limit_price"
+		return ev
+	}
+	checked, err := alpaca.CheckOrder(d.gateway, req)
+	if err != nil {
+		ev.Outcome, ev.Reason = OutcomeRejected, err.Error()
+		return ev
+	}
+	if checked.ExtendedHours != req.ExtendedHours {
+		ev.Notes = append(ev.Notes, fmt.Sprintf("extended_hours set to %t for the current session", checked.ExtendedHours))
+	}
+	if checked.TimeInForce != req.TimeInForce {
+		ev.Notes = append(ev.Notes, fmt.Sprintf("time_in_force changed to %s for the current session", checked.TimeInForce))
+	}
+	ev.Order = &checked
+	if ev.Outcome == "" {
+		ev.Outcome = OutcomeAccepted
+	}
+	return ev
+}
+
+// RegisterDryRunHandler exposes the evaluation on the brain request channel:
+//
+//	dry_run {"symbol","side","qty","type","limit_price","time_in_force","extended_hours","conviction",...}
+//	        -> Evaluation (outcome accepted/resized/rejected, reason, size, order, notes)
+func RegisterDryRunHandler(p *brain.Pipe, d *DryRun) {
+	p.Handle("dry_run", func(raw json.RawMessage) (interface{}, error) {
+		var in OrderIntent
+		if len(raw) > 0 {
+			if err := json.Unmarshal(raw, &in); err != nil {
+				return nil, fmt.Errorf("bad params: %w", err)
+			}
+		}
+		return d.Evaluate(in), nil
+	})
+}
@@ -0,0 +1,224 @@
+package execution
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/sunnyp94/sentry-bridge/go-engine/alpaca"
+	"github.com/sunnyp94/sentry-bridge/go-engine/brain"
+	"github.com/sunnyp94/sentry-bridge/go-engine/events"
+)
+
+// ClientOrderPrefix marks the orders the gateway placed for intents that came without a client order ID,
+// so the engine's orders are distinguishable in the account (the order chaser only acts on these).
+const ClientOrderPrefix = "gw-"
+
+// Gateway is where order intents from outside the engine (brain requests, the command stream) become
+// orders: each is validated, run through the order placer chain (risk limits, hours, re-entry, budget,
+// kill switch) and reported as an order_decision, accepted or rejected.
+type Gateway struct {
+	placer alpaca.OrderPlacer
+	seq    atomic.Uint64
+
+	// OnIntent receives each intent before it is validated and placed. Optional.
+	OnIntent func(req alpaca.OrderRequest)
+	// OnDecision receives every decision. Optional.
+	OnDecision func(events.OrderDecisionEvent)
+	// OnInvalid receives each intent that fails validation, which never reaches the placer. Optional.
+	OnInvalid func(req alpaca.OrderRequest, err error)
+}
+
+// NewGateway submits through placer (the engine's order placer chain).
+func NewGateway(placer alpaca.OrderPlacer) *Gateway {
+	return &Gateway{placer: placer}
+}
+
+// Submit validates raw order params, places the order and reports the decision; source says where the
+// intent came from ("brain", "command").
+func (g *Gateway) Submit(source string, raw json.RawMessage) (*alpaca.Order, error) {
+	req, err := ParseOrder(raw)
+	req.Source = source
+	if req.ClientOrderID == "" {
+		req.ClientOrderID = fmt.Sprintf("%s%s-%d-%d", ClientOrderPrefix, source, time.Now().UnixNano(), g.seq.Add(1))
+	}
+	if g.OnIntent != nil {
+		g.OnIntent(req)
+	}
+	var o *alpaca.Order
+	if err == nil {
+		o, err = g.placer.PlaceOrder(req)
+	} else if g.OnInvalid != nil {
+		g.OnInvalid(req, err)
+	}
+	ev := events.OrderDecisionEvent{Source: source, Accepted: err == nil, Symbol: req.Symbol, Side: req.Side, Qty: req.Qty,
+		Type: req.Type, LimitPrice: req.LimitPrice, ClientOrderID: req.ClientOrderID, OrderClass: req.OrderClass,
+		CorrelationID: req.CorrelationID}
+	if err != nil {
+		ev.Reason = err.Error()
+	} else if o != nil {
+		ev.OrderID = o.ID
+	}
+	if g.OnDecision != nil {
+		g.OnDecision(ev)
+	}
+	return o, err
+}
+
+// RegisterOrderHandler lets the brain place orders over its request channel:
+//
+//	order {"symbol","qty","side","type","time_in_force","limit_price","stop_price","extended_hours","client_order_id",
+//	       "order_class","take_profit":{"limit_price"},"stop_loss":{"stop_price","limit_price"},"correlation_id"}
+//	      -> the broker order, or the reason it was rejected
+//
+// correlation_id is the id of the event the order answers; its trade updates carry it back.
+func RegisterOrderHandler(p *brain.Pipe, g *Gateway) {
+	p.Handle("order", func(raw json.RawMessage) (interface{}, error) {
+		return g.Submit("brain", raw)
+	})
+}
+
+// orderParams is the params object of an order intent. qty may be a number or a string.
+type orderParams struct {
+	Symbol        string      `json:"symbol"`
+	Qty           json.Number `json:"qty"`
+	Side          string      `json:"side"`
+	Type          string      `json:"type"`
+	TimeInForce   string      `json:"time_in_force"`
+	LimitPrice    float64     `json:"limit_price"`
+	StopPrice     float64     `json:"stop_price"`
+	ExtendedHours bool        `json:"extended_hours"`
+	ClientOrderID string      `json:"client_order_id"`
+	CorrelationID string      `json:"correlation_id"` // id of the market event behind the intent
+
+	// Advanced orders: exit legs placed with the order
+	OrderClass string             `json:"order_class"`
+	TakeProfit *alpaca.TakeProfit `json:"take_profit"`
+	StopLoss   *alpaca.StopLoss   `json:"stop_loss"`
+}
+
+// ParseOrder validates the params of an order intent and returns the order request: a symbol, a
+// positive qty, side buy/sell, a known type and time in force, the prices that type needs, and for an
+// advanced order (order_class) the exit legs it needs.
+func ParseOrder(raw json.RawMessage) (alpaca.OrderRequest, error) {
+	var p orderParams
+	if len(raw) == 0 {
+		return alpaca.OrderRequest{}, errors.New("params required")
+	}
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return alpaca.OrderRequest{}, fmt.Errorf("bad params: %w", err)
+	}
+	req := alpaca.OrderRequest{
+		Symbol:        strings.ToUpper(strings.TrimSpace(p.Symbol)),
+		Side:          strings.ToLower(strings.TrimSpace(p.Side)),
+		Type:          strings.ToLower(strings.TrimSpace(p.Type)),
+		TimeInForce:   strings.ToLower(strings.TrimSpace(p.TimeInForce)),
+		LimitPrice:    p.LimitPrice,
+		StopPrice:     p.StopPrice,
+		ExtendedHours: p.ExtendedHours,
+		ClientOrderID: p.ClientOrderID,
+		CorrelationID: strings.TrimSpace(p.CorrelationID),
+		OrderClass:    strings.ToLower(strings.TrimSpace(p.OrderClass)),
+		TakeProfit:    p.TakeProfit,
+		StopLoss:      p.StopLoss,
+	}
+	if req.Symbol == "" {
+		return req, errors.New("symbol required")
+	}
+	qty, err := strconv.ParseFloat(p.Qty.String(), 64)
+	if err != nil || qty <= 0 {
+		return req, fmt.Errorf("qty must be a positive number, got %q", p.Qty)
+	}
+	req.Qty = strconv.FormatFloat(qty, 'f', -1, 64)
+	if req.Side != "buy" && req.Side != "sell" {
+		return req, fmt.Errorf("side must be buy or sell, got %q", p.Side)
+	}
+	if req.Type == "" {
+		req.Type = "market"
+		if req.OrderClass == "oco" {
+			req.Type = "limit"
+		}
+	}
+	if req.TimeInForce == "" {
+		req.TimeInForce = "day"
+	}
+	switch req.TimeInForce {
+	case "day", "gtc", "opg", "cls", "ioc", "fok":
+	default:
+		return req, fmt.Errorf("unknown time_in_force %q", req.TimeInForce)
+	}
+	switch req.Type {
+	case "market":
+	case "limit":
+		// An OCO order's limit is its take-profit leg
+		if req.LimitPrice <= 0 && req.OrderClass != "oco" {
+			return req, errors.New("limit order needs limit_price")
+		}
+	case "stop":
+		if req.StopPrice <= 0 {
+			return req, errors.New("stop order needs stop_price")
+		}
+	case "stop_limit":
+		if req.LimitPrice <= 0 || req.StopPrice <= 0 {
+			return req, errors.New("stop_limit order needs limit_price and stop_price")
+		}
+	default:
+		return req, fmt.Errorf("unknown order type %q", req.Type)
+	}
+	return req, checkOrderClass(&req)
+}
+
+// checkOrderClass validates an advanced order's legs: a bracket or OCO order has both, an OTO order one.
+// The take-profit must be on the profitable side of the stop for the position the legs close. Advanced
+// orders are day or GTC and regular hours only; an OCO order is a limit order.
+func checkOrderClass(req *alpaca.OrderRequest) error {
+	switch req.OrderClass {
+	case "", "simple":
+		if req.TakeProfit != nil || req.StopLoss != nil {
+			return errors.New("take_profit and stop_loss need order_class bracket, oco or oto")
+		}
+		req.OrderClass = ""
+		return nil
+	case "bracket", "oco", "oto":
+	default:
+		return fmt.Errorf("unknown order_class %q", req.OrderClass)
+	}
+	class, tp, sl := req.OrderClass, req.TakeProfit, req.StopLoss
+	if tp != nil && tp.LimitPrice <= 0 {
+		return errors.New("take_profit needs limit_price")
+	}
+	if sl != nil && (sl.StopPrice <= 0 || sl.LimitPrice < 0) {
+		return errors.New("stop_loss needs stop_price")
+	}
+	if class == "oto" {
+		if (tp == nil) == (sl == nil) {
+			return errors.New("oto order needs take_profit or stop_loss, not both")
+		}
+	} else if tp == nil || sl == nil {
+		return fmt.Errorf("%s order needs take_profit and stop_loss", class)
+	}
+	if class == "oco" && req.Type != "limit" {
+		return fmt.Errorf("oco order must be type limit, got %q", req.Type)
+	}
+	if req.TimeInForce != "day" && req.TimeInForce != "gtc" {
+		return fmt.Errorf("%s order needs time_in_force day or gtc", class)
+	}
+	if req.ExtendedHours {
+		return fmt.Errorf("%s order can't trade extended hours", class)
+	}
+	if tp != nil && sl != nil {
+		// The legs sell out of a long: after a buy entry, or an OCO sell
+		long := (req.Side == "buy") != (class == "oco")
+		if long && tp.LimitPrice <= sl.StopPrice {
+			return errors.New("take_profit limit_price must be above stop_loss stop_price when closing a long")
+		}
+		if !long && tp.LimitPrice >= sl.StopPrice {
+			return errors.New("take_profit limit_price must be below stop_loss stop_price when closing a short")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package execution
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sunnyp94/sentry-bridge/go-engine/alpaca"
+)
+
+func TestCheckOrderClass(t *testing.T) {
+	tp := func(limit float64) *alpaca.TakeProfit { return &alpaca.TakeProfit{LimitPrice: limit} }
+	sl := func(stop float64) *alpaca.StopLoss { return &alpaca.StopLoss{StopPrice: stop} }
+	tests := []struct {
+		name    string
+		req     alpaca.OrderRequest
+		wantErr string // substring; "" = valid
+	}{
+		{"simple", alpaca.OrderRequest{Side: "buy", Type: "market", TimeInForce: "day"}, ""},
+		{"simple class is normalized", alpaca.OrderRequest{Side: "buy", Type: "market", TimeInForce: "day", OrderClass: "simple"}, ""},
+		{"simple with legs", alpaca.OrderRequest{Side: "buy", Type: "market", TimeInForce: "day", TakeProfit: tp(110)},
+			"need order_class"},
+		{"unknown class", alpaca.OrderRequest{Side: "buy", OrderClass: "trailing"}, "unknown order_class"},
+
+		// Bracket: the entry's side decides which position the legs close
+		{"bracket buy", alpaca.OrderRequest{Side: "buy", Type: "limit", TimeInForce: "day", OrderClass: "bracket",
+			TakeProfit: tp(110), StopLoss: sl(90)}, ""},
+		{"bracket buy with the take-profit under the stop", alpaca.OrderRequest{Side: "buy", Type: "limit", TimeInForce: "day",
+			OrderClass: "bracket", TakeProfit: tp(90), StopLoss: sl(110)}, "above stop_loss"},
+		{"bracket sell", alpaca.OrderRequest{Side: "sell", Type: "market", TimeInForce: "gtc", OrderClass: "bracket",
+			TakeProfit: tp(90), StopLoss: sl(110)}, ""},
+		{"bracket sell with the take-profit over the stop", alpaca.OrderRequest{Side: "sell", Type: "market", TimeInForce: "gtc",
+			OrderClass: "bracket", TakeProfit: tp(110), StopLoss: sl(90)}, "below stop_loss"},
+		{"bracket without a stop-loss", alpaca.OrderRequest{Side: "buy", Type: "limit", TimeInForce: "day", OrderClass: "bracket",
+			TakeProfit: tp(110)}, "needs take_profit and stop_loss"},
+
+		// OCO: an exit, so a sell closes a long and a buy a short
+		{"oco sell", alpaca.OrderRequest{Side: "sell", Type: "limit", TimeInForce: "gtc", OrderClass: "oco",
+			TakeProfit: tp(110), StopLoss: sl(90)}, ""},
+		{"oco sell with the take-profit under the stop", alpaca.OrderRequest{Side: "sell", Type: "limit", TimeInForce: "gtc",
+			OrderClass: "oco", TakeProfit: tp(90), StopLoss: sl(110)}, "above stop_loss"},
+		{"oco buy", alpaca.OrderRequest{Side: "buy", Type: "limit", TimeInForce: "gtc", OrderClass: "oco",
+			TakeProfit: tp(90), StopLoss: sl(110)}, ""},
+		{"oco buy with the take-profit over the stop", alpaca.OrderRequest{Side: "buy", Type: "limit", TimeInForce: "gtc",
+			OrderClass: "oco", TakeProfit: tp(110), StopLoss: sl(90)}, "below stop_loss"},
+		{"oco market", alpaca.OrderRequest{Side: "sell", Type: "market", TimeInForce: "gtc", OrderClass: "oco",
+			TakeProfit: tp(110), StopLoss: sl(90)}, "must be type limit"},
+		{"oco without a take-profit", alpaca.OrderRequest{Side: "sell", Type: "limit", TimeInForce: "gtc", OrderClass: "oco",
+			StopLoss: sl(90)}, "needs take_profit and stop_loss"},
+
+		// OTO: exactly one leg, so there is no pair to order
+		{"oto with a take-profit", alpaca.OrderRequest{Side: "buy", Type: "limit", TimeInForce: "day", OrderClass: "oto",
+			TakeProfit: tp(110)}, ""},
+		{"oto with a stop-loss", alpaca.OrderRequest{Side: "sell", Type: "limit", TimeInForce: "day", OrderClass: "oto",
+			StopLoss: sl(110)}, ""},
+		{"oto with both legs", alpaca.OrderRequest{Side: "buy", Type: "limit", TimeInForce: "day", OrderClass: "oto",
+			TakeProfit: tp(110), StopLoss: sl(90)}, "not both"},
+		{"oto without a leg", alpaca.OrderRequest{Side: "buy", Type: "limit", TimeInForce: "day", OrderClass: "oto"}, "not both"},
+
+		// Leg prices, time in force and session
+		{"take-profit without a limit price", alpaca.OrderRequest{Side: "buy", Type: "limit", TimeInForce: "day",
+			OrderClass: "bracket", TakeProfit: tp(0), StopLoss: sl(90)}, "take_profit needs limit_price"},
+		{"stop-loss without a stop price", alpaca.OrderRequest{Side: "buy", Type: "limit", TimeInForce: "day",
+			OrderClass: "bracket", TakeProfit: tp(110), StopLoss: sl(0)}, "stop_loss needs stop_price"},
+		{"ioc bracket", alpaca.OrderRequest{Side: "buy", Type: "limit", TimeInForce: "ioc", OrderClass: "bracket",
+			TakeProfit: tp(110), StopLoss: sl(90)}, "time_in_force day or gtc"},
+		{"extended-hours bracket", alpaca.OrderRequest{Side: "buy", Type: "limit", TimeInForce: "day", ExtendedHours: true,
+			OrderClass: "bracket", TakeProfit: tp(110), StopLoss: sl(90)}, "extended hours"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := tt.req
+			err := checkOrderClass(&req)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("checkOrderClass() = %v, want nil", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Fatalf("checkOrderClass() = %v, want error containing %q", err, tt.wantErr)
+			}
+			if err == nil && tt.req.OrderClass == "simple" && req.OrderClass != "" {
+				t.Errorf("OrderClass = %q, want simple normalized to empty", req.OrderClass)
+			}
+		})
+	}
+}
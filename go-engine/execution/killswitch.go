@@ -0,0 +1,102 @@
+package execution
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sunnyp94/sentry-bridge/go-engine/alpaca"
+	"github.com/sunnyp94/sentry-bridge/go-engine/clock"
+)
+
+// ErrTradingHalted is returned (wrapped) for every order while the kill switch is engaged.
+var ErrTradingHalted = errors.New("order blocked by kill switch")
+
+// KillSwitch wraps an order placer and refuses every order while engaged. Flattening (cancel orders,
+// close positions) goes to the broker directly, so it is not blocked by the switch.
+type KillSwitch struct {
+	next  alpaca.OrderPlacer
+	clock clock.Clock
+
+	mu      sync.Mutex
+	engaged bool
+	reason  string
+	since   time.Time
+
+	// OnEngage is called (outside the lock) each time the switch goes from released to engaged. Optional.
+	OnEngage func(reason string)
+	// OnRelease is called (outside the lock) each time the switch is released. Optional.
+	OnRelease func()
+}
+
+// NewKillSwitch wraps next; the switch starts released.
+func NewKillSwitch(next alpaca.OrderPlacer) *KillSwitch {
+	return &KillSwitch{next: next, clock: clock.Real{}}
+}
+
+// SetClock replaces the clock for the engaged-since time. Call before use.
+func (k *KillSwitch) SetClock(c clock.Clock) { k.clock = c }
+
+// Engage halts order submission; false if it was already engaged (the first reason is kept).
+func (k *KillSwitch) Engage(reason string) bool {
+	k.mu.Lock()
+	if k.engaged {
+		k.mu.Unlock()
+		return false
+	}
+	k.engaged, k.reason, k.since = true, reason, k.clock.Now()
+	k.mu.Unlock()
+	if k.OnEngage != nil {
+		k.OnEngage(reason)
+	}
+	return true
+}
+
+// Release resumes order submission; false if it was not engaged.
+func (k *KillSwitch) Release() bool {
+	k.mu.Lock()
+	if !k.engaged {
+		k.mu.Unlock()
+		return false
+	}
+	k.engaged, k.reason = false, ""
+	k.mu.Unlock()
+	if k.OnRelease != nil {
+		k.OnRelease()
+	}
+	return true
+}
+
+// Engaged reports whether orders are halted, and why.
+func (k *KillSwitch) Engaged() (bool, string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.engaged, k.reason
+}
+
+// PlaceOrder forwards req unless the switch is engaged.
+func (k *KillSwitch) PlaceOrder(req alpaca.OrderRequest) (*alpaca.Order, error) {
+	if err := k.refusal(req); err != nil {
+		return nil, err
+	}
+	return k.next.PlaceOrder(req)
+}
+
+// CheckOrder refuses req while engaged, else applies the checks further down the chain.
+func (k *KillSwitch) CheckOrder(req alpaca.OrderRequest) (alpaca.OrderRequest, error) {
+	if err := k.refusal(req); err != nil {
+		return req, err
+	}
+	return alpaca.CheckOrder(k.next, req)
+}
+
+// refusal is the error for req while engaged, else nil.
+func (k *KillSwitch) refusal(req alpaca.OrderRequest) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if !k.engaged {
+		return nil
+	}
+	return fmt.Errorf("%w: %s %s: %s (since %s)", ErrTradingHalted, req.Side, req.Symbol, k.reason, k.since.Format(time.RFC3339))
+}
@@ -0,0 +1,291 @@
+package execution
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sunnyp94/sentry-bridge/go-engine/alpaca"
+	"github.com/sunnyp94/sentry-bridge/go-engine/brain"
+	"github.com/sunnyp94/sentry-bridge/go-engine/clock"
+	"github.com/sunnyp94/sentry-bridge/go-engine/events"
+)
+
+// ErrPDT is returned (wrapped) when an order would be the day trade that flags the account as a pattern
+// day trader.
+var ErrPDT = errors.New("order blocked by pattern day trader guard")
+
+// Pattern day trader rule: a margin account under PDTMinEquity that makes more than PDTMaxDayTrades day
+// trades in five business days is flagged.
+const (
+	PDTMinEquity    = 25000
+	PDTMaxDayTrades = 3
+)
+
+// PDTGuard wraps an order placer and refuses orders that would make a fourth day trade in five business
+// days while equity is under PDTMinEquity. A day trade is a fill that closes shares opened the same ET
+// day; closes use up shares held overnight first. The count is the broker's daytrade_count from the
+// account poll plus day trades seen in fills since, so trades placed outside the engine count too.
+// Positions held when the engine starts are treated as held overnight.
+type PDTGuard struct {
+	next  alpaca.OrderPlacer
+	clock clock.Clock
+
+	mu        sync.Mutex
+	equity    float64 // 0 until the first account sync: nothing is blocked
+	flagged   bool    // broker's pattern_day_trader
+	broker    int     // daytrade_count at the last account sync
+	syncedAt  time.Time
+	day       string
+	posDay    string             // ET day of the last positions sync
+	pos       map[string]float64 // signed position qty
+	overnight map[string]float64 // shares (absolute) held since before today, still open
+	opened    map[string]float64 // shares (absolute) opened today, still open
+	trades    []dayTrade         // day trades seen in fills, oldest first
+	counted   map[string]bool    // order IDs already counted as a day trade today
+
+	// OnWarning receives a pdt event when a day trade brings the account to the limit and when an order
+	// is refused. Optional.
+	OnWarning func(events.PDTEvent)
+}
+
+type dayTrade struct {
+	at  time.Time
+	day string // ET date
+}
+
+// NewPDTGuard wraps next.
+func NewPDTGuard(next alpaca.OrderPlacer) *PDTGuard {
+	return &PDTGuard{
+		next:      next,
+		clock:     clock.Real{},
+		pos:       make(map[string]float64),
+		overnight: make(map[string]float64),
+		opened:    make(map[string]float64),
+		counted:   make(map[string]bool),
+	}
+}
+
+// SetClock replaces the clock for the ET day and the five-day window. Call before use.
+func (g *PDTGuard) SetClock(c clock.Clock) { g.clock = c }
+
+// PlaceOrder forwards req unless it would be a day trade over the limit.
+func (g *PDTGuard) PlaceOrder(req alpaca.OrderRequest) (*alpaca.Order, error) {
+	if ev, err := g.check(req); err != nil {
+		if g.OnWarning != nil {
+			g.OnWarning(ev)
+		}
+		return nil, err
+	}
+	return g.next.PlaceOrder(req)
+}
+
+// CheckOrder applies the day trade limit without submitting or warning, then the checks further down
+// the chain.
+func (g *PDTGuard) CheckOrder(req alpaca.OrderRequest) (alpaca.OrderRequest, error) {
+	if _, err := g.check(req); err != nil {
+		return req, err
+	}
+	return alpaca.CheckOrder(g.next, req)
+}
+
+// check refuses req if it is a day trade over the limit; ev describes the refusal.
+func (g *PDTGuard) check(req alpaca.OrderRequest) (ev events.PDTEvent, err error) {
+	symbol := strings.ToUpper(req.Symbol)
+	g.mu.Lock()
+	now := g.clock.Now()
+	g.rollDayLocked(now)
+	pos := g.pos[symbol]
+	closing := (strings.EqualFold(req.Side, "sell") && pos > 0) || (strings.EqualFold(req.Side, "buy") && pos < 0)
+	qty, _ := strconv.ParseFloat(req.Qty, 64)
+	closes := math.Min(qty, math.Abs(pos))
+	dayTrade := closing && closes > g.overnight[symbol] && g.opened[symbol] > 0
+	count := g.countLocked(now)
+	ev = events.PDTEvent{Symbol: symbol, DayTrades: count, Limit: PDTMaxDayTrades, Equity: g.equity, PatternDayTrader: g.flagged}
+	blocked := dayTrade && g.equity > 0 && g.equity < PDTMinEquity && count >= PDTMaxDayTrades
+	g.mu.Unlock()
+	if !blocked {
+		return ev, nil
+	}
+	err = fmt.Errorf("%w: %s %s: would be day trade %d in 5 business days with equity $%.2f (under $%d)",
+		ErrPDT, req.Side, symbol, count+1, ev.Equity, PDTMinEquity)
+	ev.Blocked, ev.Reason = true, err.Error()
+	return ev, err
+}
+
+// OnTradeUpdate follows fills: opening shares are remembered for the day, and a close of shares opened
+// today counts as a day trade (once per order).
+func (g *PDTGuard) OnTradeUpdate(u alpaca.TradeUpdate) {
+	if u.Event != "fill" && u.Event != "partial_fill" {
+		return
+	}
+	symbol := strings.ToUpper(u.Order.Symbol)
+	qty := u.Qty.Value()
+	g.mu.Lock()
+	now := g.clock.Now()
+	g.rollDayLocked(now)
+	prev := g.pos[symbol]
+	after := prev
+	if u.PositionQty != nil {
+		after = u.PositionQty.Value()
+	} else if u.Order.Side == "buy" {
+		after = prev + qty
+	} else {
+		after = prev - qty
+	}
+	var warn *events.PDTEvent
+	if prev != 0 && (math.Abs(after) < math.Abs(prev) || prev*after < 0) {
+		closed := math.Min(math.Abs(prev), math.Abs(prev-after))
+		fromOvernight := math.Min(closed, g.overnight[symbol])
+		g.overnight[symbol] -= fromOvernight
+		fromToday := math.Min(closed-fromOvernight, g.opened[symbol])
+		g.opened[symbol] -= fromToday
+		if fromToday > 0 && !g.counted[u.Order.ID] {
+			g.counted[u.Order.ID] = true
+			g.trades = append(g.trades, dayTrade{at: now, day: g.day})
+			count := g.countLocked(now)
+			if g.equity > 0 && g.equity < PDTMinEquity && count >= PDTMaxDayTrades {
+				warn = &events.PDTEvent{Symbol: symbol, DayTrades: count, Limit: PDTMaxDayTrades, Equity: g.equity, PatternDayTrader: g.flagged,
+					Reason: fmt.Sprintf("%d day trades in 5 business days; another would flag the account", count)}
+			}
+		}
+		if prev*after < 0 {
+			g.opened[symbol] = math.Abs(after) // flipped: the new side was opened today
+		}
+	} else if math.Abs(after) > math.Abs(prev) {
+		g.opened[symbol] += math.Abs(after) - math.Abs(prev)
+	}
+	g.setPosLocked(symbol, after)
+	g.mu.Unlock()
+	if warn != nil && g.OnWarning != nil {
+		g.OnWarning(*warn)
+	}
+}
+
+// SyncPositions reconciles with the broker. On the first sync of a day every share counts as held
+// overnight; later, shares that appeared without a fill count as opened today and shares that vanished
+// come off overnight holdings first.
+func (g *PDTGuard) SyncPositions(positions []alpaca.Position) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.rollDayLocked(g.clock.Now())
+	first := g.posDay != g.day
+	g.posDay = g.day
+	seen := make(map[string]bool, len(positions))
+	for _, p := range positions {
+		qty, err := strconv.ParseFloat(p.Qty, 64)
+		if err != nil {
+			continue
+		}
+		if p.Side == "short" && qty > 0 {
+			qty = -qty
+		}
+		symbol := strings.ToUpper(p.Symbol)
+		seen[symbol] = true
+		g.reconcileLocked(symbol, qty, first)
+	}
+	for symbol := range g.pos {
+		if !seen[symbol] {
+			g.reconcileLocked(symbol, 0, first)
+		}
+	}
+}
+
+func (g *PDTGuard) reconcileLocked(symbol string, qty float64, first bool) {
+	held := math.Abs(qty)
+	if first {
+		g.overnight[symbol], g.opened[symbol] = held, 0
+	} else if g.pos[symbol]*qty < 0 {
+		g.overnight[symbol], g.opened[symbol] = 0, held // flipped between polls
+	} else if known := g.overnight[symbol] + g.opened[symbol]; held > known {
+		g.opened[symbol] += held - known
+	} else if held < known {
+		cut := known - held
+		fromOvernight := math.Min(cut, g.overnight[symbol])
+		g.overnight[symbol] -= fromOvernight
+		g.opened[symbol] = math.Max(0, g.opened[symbol]-(cut-fromOvernight))
+	}
+	g.setPosLocked(symbol, qty)
+}
+
+// SyncAccount takes equity, the pattern day trader flag and the broker's day trade count from the
+// account poll.
+func (g *PDTGuard) SyncAccount(a alpaca.Account) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.equity, g.flagged, g.broker = a.Equity.Value(), a.PatternDayTrader, a.DaytradeCount
+	g.syncedAt = g.clock.Now()
+}
+
+// DayTrades is the current count in the five-business-day window.
+func (g *PDTGuard) DayTrades() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.countLocked(g.clock.Now())
+}
+
+// countLocked is the broker's count plus day trades seen since the last account sync, or, before the
+// first sync, the day trades seen in the window.
+func (g *PDTGuard) countLocked(now time.Time) int {
+	window := businessDays(now, 5)
+	n := 0
+	for _, t := range g.trades {
+		if window[t.day] && (g.syncedAt.IsZero() || t.at.After(g.syncedAt)) {
+			n++
+		}
+	}
+	if g.syncedAt.IsZero() {
+		return n
+	}
+	return g.broker + n
+}
+
+// rollDayLocked starts a new ET day: shares still open become overnight holdings and day trades that
+// left the window are dropped.
+func (g *PDTGuard) rollDayLocked(now time.Time) {
+	day := now.In(brain.Eastern()).Format("2006-01-02")
+	if day == g.day {
+		return
+	}
+	g.day = day
+	for symbol, qty := range g.pos {
+		g.overnight[symbol], g.opened[symbol] = math.Abs(qty), 0
+	}
+	g.counted = make(map[string]bool)
+	window := businessDays(now, 5)
+	kept := g.trades[:0]
+	for _, t := range g.trades {
+		if window[t.day] {
+			kept = append(kept, t)
+		}
+	}
+	g.trades = kept
+}
+
+func (g *PDTGuard) setPosLocked(symbol string, qty float64) {
+	if qty == 0 {
+		delete(g.pos, symbol)
+		delete(g.overnight, symbol)
+		delete(g.opened, symbol)
+		return
+	}
+	g.pos[symbol] = qty
+}
+
+// businessDays is the set of the last n weekdays (ET dates) up to and including now's. Market holidays
+// are not skipped, so the window can be a day short around them.
+func businessDays(now time.Time, n int) map[string]bool {
+	out := make(map[string]bool, n)
+	d := now.In(brain.Eastern())
+	for len(out) < n {
+		if wd := d.Weekday(); wd != time.Saturday && wd != time.Sunday {
+			out[d.Format("2006-01-02")] = true
+		}
+		d = d.AddDate(0, 0, -1)
+	}
+	return out
+}
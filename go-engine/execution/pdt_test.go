@@ -0,0 +1,150 @@
+package execution
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sunnyp94/sentry-bridge/go-engine/alpaca"
+	"github.com/sunnyp94/sentry-bridge/go-engine/brain"
+	"github.com/sunnyp94/sentry-bridge/go-engine/clock"
+)
+
+// acceptAll is the broker at the end of the chain: every order goes through.
+type acceptAll struct{}
+
+func (acceptAll) PlaceOrder(req alpaca.OrderRequest) (*alpaca.Order, error) {
+	return &alpaca.Order{ID: "accepted", Symbol: req.Symbol, Side: req.Side}, nil
+}
+
+// pdtStep is one thing that happens to the guard: a poll, a fill or time passing.
+type pdtStep func(g *PDTGuard, clk *clock.Manual)
+
+func syncPositions(positions ...alpaca.Position) pdtStep {
+	return func(g *PDTGuard, clk *clock.Manual) { g.SyncPositions(positions) }
+}
+
+func syncAccount(equity float64, dayTrades int) pdtStep {
+	return func(g *PDTGuard, clk *clock.Manual) {
+		g.SyncAccount(alpaca.Account{Equity: alpaca.FlexFloat(equity), DaytradeCount: dayTrades})
+	}
+}
+
+// fill is a full fill of order id; a minute passes first, so fills and syncs are ordered in time.
+func fill(id, side string, qty float64) pdtStep {
+	return func(g *PDTGuard, clk *clock.Manual) {
+		clk.Advance(time.Minute)
+		q := alpaca.FlexFloat(qty)
+		g.OnTradeUpdate(alpaca.TradeUpdate{Event: "fill", Qty: &q, Order: alpaca.Order{ID: id, Symbol: "AAPL", Side: side}})
+	}
+}
+
+func advance(d time.Duration) pdtStep {
+	return func(g *PDTGuard, clk *clock.Manual) { clk.Advance(d) }
+}
+
+func long(qty string) alpaca.Position { return alpaca.Position{Symbol: "AAPL", Qty: qty, Side: "long"} }
+
+func TestPDTGuard(t *testing.T) {
+	// Monday 10:00 ET
+	start := time.Date(2026, 10, 12, 10, 0, 0, 0, brain.Eastern())
+	sell100 := alpaca.OrderRequest{Symbol: "AAPL", Qty: "100", Side: "sell", Type: "market", TimeInForce: "day"}
+	tests := []struct {
+		name        string
+		steps       []pdtStep
+		wantCount   int
+		probe       *alpaca.OrderRequest // checked after the steps, when set
+		wantBlocked bool
+	}{
+		{
+			name:      "closing an overnight hold is not a day trade",
+			steps:     []pdtStep{syncPositions(long("100")), fill("o1", "sell", 100)},
+			wantCount: 0,
+		},
+		{
+			name:      "day trade after an overnight hold: closes use up overnight shares first",
+			steps:     []pdtStep{syncPositions(long("100")), fill("o1", "buy", 50), fill("o2", "sell", 150)},
+			wantCount: 1,
+		},
+		{
+			name:      "partial close of an overnight hold plus today's shares",
+			steps:     []pdtStep{syncPositions(long("100")), fill("o1", "buy", 50), fill("o2", "sell", 100)},
+			wantCount: 0,
+		},
+		{
+			name:      "position flip is a day trade, and closing the new side another",
+			steps:     []pdtStep{syncPositions(), fill("o1", "buy", 100), fill("o2", "sell", 200), fill("o3", "buy", 100)},
+			wantCount: 2,
+		},
+		{
+			name:      "first sync of a day counts every share as held overnight",
+			steps:     []pdtStep{fill("o1", "buy", 100), syncPositions(long("100")), fill("o2", "sell", 100)},
+			wantCount: 0,
+		},
+		{
+			name:      "a later sync counts shares that appeared without a fill as opened today",
+			steps:     []pdtStep{syncPositions(long("100")), syncPositions(long("150")), fill("o1", "sell", 150)},
+			wantCount: 1,
+		},
+		{
+			name:      "shares bought yesterday are held overnight",
+			steps:     []pdtStep{syncPositions(), fill("o1", "buy", 100), advance(24 * time.Hour), fill("o2", "sell", 100)},
+			wantCount: 0,
+		},
+		{
+			name: "account sync replaces the day trades seen before it with the broker's count",
+			steps: []pdtStep{syncPositions(), fill("o1", "buy", 100), fill("o2", "sell", 100), fill("o3", "buy", 100), fill("o4", "sell", 100),
+				advance(time.Minute), syncAccount(20000, 1)},
+			wantCount: 1,
+		},
+		{
+			name: "count after an account sync adds the day trades seen since",
+			steps: []pdtStep{syncPositions(), syncAccount(20000, 1), fill("o1", "buy", 100), fill("o2", "sell", 100),
+				fill("o3", "buy", 100)},
+			wantCount:   2,
+			probe:       &sell100,
+			wantBlocked: false,
+		},
+		{
+			name:        "fourth day trade under the minimum equity is blocked",
+			steps:       []pdtStep{syncPositions(), syncAccount(20000, 3), fill("o1", "buy", 100)},
+			wantCount:   3,
+			probe:       &sell100,
+			wantBlocked: true,
+		},
+		{
+			name:        "fourth day trade at the minimum equity goes through",
+			steps:       []pdtStep{syncPositions(), syncAccount(PDTMinEquity, 3), fill("o1", "buy", 100)},
+			wantCount:   3,
+			probe:       &sell100,
+			wantBlocked: false,
+		},
+		{
+			name:        "closing an overnight hold at the limit goes through",
+			steps:       []pdtStep{syncPositions(long("100")), syncAccount(20000, 3)},
+			wantCount:   3,
+			probe:       &sell100,
+			wantBlocked: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clk := clock.NewManual(start)
+			g := NewPDTGuard(acceptAll{})
+			g.SetClock(clk)
+			for _, step := range tt.steps {
+				step(g, clk)
+			}
+			if got := g.DayTrades(); got != tt.wantCount {
+				t.Errorf("DayTrades() = %d, want %d", got, tt.wantCount)
+			}
+			if tt.probe == nil {
+				return
+			}
+			_, err := g.CheckOrder(*tt.probe)
+			if blocked := errors.Is(err, ErrPDT); blocked != tt.wantBlocked {
+				t.Errorf("CheckOrder() err = %v, want blocked %v", err, tt.wantBlocked)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package execution
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sunnyp94/sentry-bridge/go-engine/alpaca"
+	"github.com/sunnyp94/sentry-bridge/go-engine/brain"
+	"github.com/sunnyp94/sentry-bridge/go-engine/clock"
+	"github.com/sunnyp94/sentry-bridge/go-engine/events"
+)
+
+// PnL marks held positions to market on every trade print, so equity can be watched tick by tick instead
+// of on the positions poll. Quantities and entry prices come from the poll and are kept current between
+// polls by fills from the trading stream; fills that reduce a position add to the session's realized P&L,
+// which resets at the start of each New York trading day. The day's P&L is realized plus the change in
+// unrealized since the day started (or since the engine started, mid-day), so gains carried in from
+// earlier days don't count.
+type PnL struct {
+	clock clock.Clock
+
+	mu       sync.Mutex
+	held     map[string]*markedPosition
+	realized float64 // realized P&L from fills seen today
+	dayBase  float64 // total unrealized P&L when the day started
+	day      string  // New York date the realized P&L belongs to
+}
+
+type markedPosition struct {
+	qty  float64 // signed; negative = short
+	avg  float64 // average entry price
+	mark float64 // last trade price (or the broker's current price until the first trade)
+}
+
+// NewPnL returns an empty tracker; nothing is marked until the first SyncPositions.
+func NewPnL() *PnL {
+	return &PnL{clock: clock.Real{}, held: make(map[string]*markedPosition)}
+}
+
+// SetClock replaces the clock for the session day. Call before use.
+func (p *PnL) SetClock(c clock.Clock) { p.clock = c }
+
+// SyncPositions replaces the held quantities and entry prices with the broker's. A mark already set by a
+// trade print is kept; the broker's current price is only the starting mark.
+func (p *PnL) SyncPositions(positions []alpaca.Position) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	held := make(map[string]*markedPosition, len(positions))
+	for _, pos := range positions {
+		qty, err := strconv.ParseFloat(pos.Qty, 64)
+		if err != nil || qty == 0 {
+			continue
+		}
+		if pos.Side == "short" && qty > 0 {
+			qty = -qty
+		}
+		symbol := strings.ToUpper(pos.Symbol)
+		m := &markedPosition{qty: qty, avg: pos.AvgEntryPrice.Value(), mark: pos.CurrentPrice.Value()}
+		if prev := p.held[symbol]; prev != nil && prev.mark > 0 {
+			m.mark = prev.mark
+		}
+		held[symbol] = m
+	}
+	p.held = held
+	p.rollDayLocked(p.clock.Now())
+}
+
+// OnTradeUpdate applies a fill: the position quantity and average entry change, and a reduction realizes
+// P&L against the average entry.
+func (p *PnL) OnTradeUpdate(u alpaca.TradeUpdate) {
+	if u.Event != "fill" && u.Event != "partial_fill" {
+		return
+	}
+	symbol := strings.ToUpper(u.Order.Symbol)
+	price, qty := u.Price.Value(), u.Qty.Value()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rollDayLocked(p.clock.Now())
+	m := p.held[symbol]
+	if m == nil {
+		m = &markedPosition{}
+	}
+	prev := m.qty
+	after := prev
+	if u.PositionQty != nil {
+		after = u.PositionQty.Value()
+	} else if u.Order.Side == "buy" {
+		after = prev + qty
+	} else {
+		after = prev - qty
+	}
+	switch {
+	case prev != 0 && math.Abs(after) < math.Abs(prev) || prev*after < 0:
+		// Reduced, closed or flipped: the closed shares realize against the old entry
+		closed := math.Min(math.Abs(prev), math.Abs(prev-after))
+		if price > 0 && m.avg > 0 {
+			p.realized += (price - m.avg) * closed * sign(prev)
+		}
+		if prev*after < 0 {
+			m.avg = price
+		}
+	case math.Abs(after) > math.Abs(prev) && price > 0:
+		m.avg = (m.avg*math.Abs(prev) + price*(math.Abs(after)-math.Abs(prev))) / math.Abs(after)
+	}
+	if price > 0 {
+		m.mark = price
+	}
+	m.qty = after
+	if after == 0 {
+		delete(p.held, symbol)
+		return
+	}
+	p.held[symbol] = m
+}
+
+// Mark records a trade print for symbol and returns the marked position with account totals; false when
+// symbol is not held.
+func (p *PnL) Mark(symbol string, price float64, t time.Time) (events.PnLEvent, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	m := p.held[symbol]
+	if m == nil || price <= 0 {
+		return events.PnLEvent{}, false
+	}
+	p.rollDayLocked(p.clock.Now())
+	m.mark = price
+	ev := events.PnLEvent{
+		Symbol:        symbol,
+		Price:         price,
+		Qty:           m.qty,
+		AvgEntryPrice: m.avg,
+		MarketValue:   m.qty * price,
+		UnrealizedPL:  (price - m.avg) * m.qty,
+		RealizedPL:    p.realized,
+		Positions:     len(p.held),
+		Time:          t.UTC().Format(time.RFC3339Nano),
+	}
+	if basis := math.Abs(m.qty * m.avg); basis > 0 {
+		ev.UnrealizedPLPC = ev.UnrealizedPL / basis
+	}
+	for _, h := range p.held {
+		ev.TotalMarketValue += h.qty * h.mark
+	}
+	ev.TotalUnrealizedPL = p.unrealizedLocked()
+	ev.TotalPL = ev.TotalUnrealizedPL + ev.RealizedPL
+	ev.DayPL = ev.RealizedPL + ev.TotalUnrealizedPL - p.dayBase
+	return ev, true
+}
+
+// unrealizedLocked is the unrealized P&L of every held position at its last mark.
+func (p *PnL) unrealizedLocked() float64 {
+	var sum float64
+	for _, h := range p.held {
+		sum += (h.mark - h.avg) * h.qty
+	}
+	return sum
+}
+
+func (p *PnL) rollDayLocked(now time.Time) {
+	if day := now.In(brain.Eastern()).Format("2006-01-02"); day != p.day {
+		p.day = day
+		p.realized = 0
+		p.dayBase = p.unrealizedLocked()
+	}
+}
+
+func sign(x float64) float64 {
+	if x < 0 {
+		return -1
+	}
+	return 1
+}
@@ -0,0 +1,138 @@
+package execution
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sunnyp94/sentry-bridge/go-engine/alpaca"
+	"github.com/sunnyp94/sentry-bridge/go-engine/clock"
+	"github.com/sunnyp94/sentry-bridge/go-engine/events"
+)
+
+// driftEpsilon is the quantity difference below which positions agree (fractional shares round).
+const driftEpsilon = 1e-6
+
+// Reconciler tracks the positions the account's fills explain: the first positions poll, moved by every
+// fill on the trade update stream since, whoever placed the order (the engine, the brain placing orders
+// directly, or a manual trade). Each later poll is compared with that, and a symbol that still disagrees
+// after the grace period is reported as drift: a fill the trade update stream missed, or a position
+// change no order explains (assignment, exercise, a corporate action). After a report the broker's
+// position becomes the expected one, so each divergence is reported once.
+type Reconciler struct {
+	grace time.Duration
+	clock clock.Clock
+
+	mu       sync.Mutex
+	synced   bool
+	expected map[string]float64   // signed qty
+	filled   map[string]float64   // order ID -> filled qty applied so far, until the order is done
+	since    map[string]time.Time // symbol -> when the current mismatch was first seen
+
+	// OnDrift receives each position_drift event. Optional.
+	OnDrift func(events.PositionDriftEvent)
+}
+
+// NewReconciler returns a Reconciler that reports a mismatch once it has lasted grace.
+func NewReconciler(grace time.Duration) *Reconciler {
+	return &Reconciler{
+		grace:    grace,
+		clock:    clock.Real{},
+		expected: make(map[string]float64),
+		filled:   make(map[string]float64),
+		since:    make(map[string]time.Time),
+	}
+}
+
+// SetClock replaces the clock for the grace period. Call before use.
+func (r *Reconciler) SetClock(c clock.Clock) { r.clock = c }
+
+// OnTradeUpdate applies the fills of every order on the stream to the expected positions. Fills are
+// counted by the order's cumulative filled qty, so a repeated update is not counted twice.
+func (r *Reconciler) OnTradeUpdate(u alpaca.TradeUpdate) {
+	o := u.Order
+	symbol := strings.ToUpper(o.Symbol)
+	if o.ID == "" || symbol == "" {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if u.Event == "fill" || u.Event == "partial_fill" {
+		cum, err := strconv.ParseFloat(o.FilledQty, 64)
+		if err != nil {
+			cum = r.filled[o.ID] + u.Qty.Value()
+		}
+		if d := cum - r.filled[o.ID]; d > 0 {
+			if strings.EqualFold(o.Side, "sell") {
+				d = -d
+			}
+			r.expected[symbol] += d
+			r.filled[o.ID] = cum
+		}
+	}
+	switch u.Event {
+	case "fill", "canceled", "expired", "rejected", "replaced", "done_for_day":
+		delete(r.filled, o.ID)
+	}
+}
+
+// SyncPositions compares the broker's positions with the expected ones. The first call only sets the
+// expected positions.
+func (r *Reconciler) SyncPositions(positions []alpaca.Position) {
+	actual := make(map[string]float64, len(positions))
+	for _, p := range positions {
+		qty, err := strconv.ParseFloat(p.Qty, 64)
+		if err != nil {
+			continue
+		}
+		if p.Side == "short" && qty > 0 {
+			qty = -qty
+		}
+		actual[strings.ToUpper(p.Symbol)] = qty
+	}
+	var drift []events.PositionDriftEvent
+	r.mu.Lock()
+	if !r.synced {
+		r.expected, r.synced = actual, true
+		r.mu.Unlock()
+		return
+	}
+	now := r.clock.Now()
+	symbols := make(map[string]bool, len(actual)+len(r.expected))
+	for s := range actual {
+		symbols[s] = true
+	}
+	for s := range r.expected {
+		symbols[s] = true
+	}
+	for s := range symbols {
+		want, got := r.expected[s], actual[s]
+		if math.Abs(got-want) < driftEpsilon {
+			delete(r.since, s)
+			if got == 0 {
+				delete(r.expected, s)
+			}
+			continue
+		}
+		first, ok := r.since[s]
+		if !ok {
+			first = now
+			r.since[s] = now
+		}
+		if now.Sub(first) < r.grace {
+			continue
+		}
+		drift = append(drift, events.PositionDriftEvent{Symbol: s, Expected: want, Actual: got, Diff: got - want,
+			Since: first.UTC().Format(time.RFC3339)})
+		delete(r.since, s)
+		r.expected[s] = got
+	}
+	r.mu.Unlock()
+	if r.OnDrift != nil {
+		for _, ev := range drift {
+			r.OnDrift(ev)
+		}
+	}
+}
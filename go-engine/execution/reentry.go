@@ -0,0 +1,238 @@
+package execution
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sunnyp94/sentry-bridge/go-engine/alpaca"
+	"github.com/sunnyp94/sentry-bridge/go-engine/brain"
+	"github.com/sunnyp94/sentry-bridge/go-engine/clock"
+)
+
+// ErrReentryBlocked is returned (wrapped) when the re-entry policy refuses an entry order.
+var ErrReentryBlocked = errors.New("order blocked by re-entry policy")
+
+// ReentryRule is the whipsaw policy for one symbol. Zero values disable the individual check.
+type ReentryRule struct {
+	ExitCooldown time.Duration // No new entry this long after any exit (full or partial)
+	StopCooldown time.Duration // Longer wait after a stop-out (stop order fill or exit at a loss)
+	MaxReentries int           // Entries from flat after an exit, per symbol per ET day
+}
+
+// ReentryConfig is the global rule plus per-symbol rules, which replace the global rule for that symbol.
+type ReentryConfig struct {
+	Default ReentryRule
+	Symbols map[string]ReentryRule
+}
+
+// exitRecord is the last exit seen for a symbol.
+type exitRecord struct {
+	at   time.Time
+	stop bool
+}
+
+// ReentryGuard wraps an order placer and blocks entries that come too soon after an exit or exceed the
+// daily re-entry count. Exits are learned from trade updates and the positions poll, so they count no
+// matter who placed the exit order (engine, Python brain, or manual).
+type ReentryGuard struct {
+	cfg   ReentryConfig
+	next  alpaca.OrderPlacer
+	clock clock.Clock
+
+	mu        sync.Mutex
+	pos       map[string]float64 // signed position qty
+	avg       map[string]float64 // average entry price
+	upl       map[string]float64 // unrealized P&L at the last poll (classifies exits the poll discovers)
+	lastExit  map[string]exitRecord
+	day       string
+	exited    map[string]bool // went flat after an exit today
+	reentries map[string]int
+}
+
+// NewReentryGuard wraps next.
+func NewReentryGuard(cfg ReentryConfig, next alpaca.OrderPlacer) *ReentryGuard {
+	return &ReentryGuard{
+		cfg:       cfg,
+		next:      next,
+		clock:     clock.Real{},
+		pos:       make(map[string]float64),
+		avg:       make(map[string]float64),
+		upl:       make(map[string]float64),
+		lastExit:  make(map[string]exitRecord),
+		exited:    make(map[string]bool),
+		reentries: make(map[string]int),
+	}
+}
+
+// SetClock replaces the clock for cooldowns and the ET day. Call before use.
+func (g *ReentryGuard) SetClock(c clock.Clock) { g.clock = c }
+
+// Rule returns the rule in force for symbol.
+func (g *ReentryGuard) Rule(symbol string) ReentryRule {
+	if r, ok := g.cfg.Symbols[symbol]; ok {
+		return r
+	}
+	return g.cfg.Default
+}
+
+// PlaceOrder forwards exits unchanged and checks entries (orders that open or add to a position) against
+// the cooldown and re-entry count.
+func (g *ReentryGuard) PlaceOrder(req alpaca.OrderRequest) (*alpaca.Order, error) {
+	symbol := strings.ToUpper(req.Symbol)
+	rule := g.Rule(symbol)
+	now := g.clock.Now()
+	g.mu.Lock()
+	reentry, err := g.checkLocked(req, symbol, rule, now)
+	if err != nil {
+		g.mu.Unlock()
+		return nil, err
+	}
+	if reentry {
+		g.reentries[symbol]++ // reserve; released if the broker rejects the order
+	}
+	g.mu.Unlock()
+	o, err := g.next.PlaceOrder(req)
+	if err != nil && reentry {
+		g.mu.Lock()
+		g.reentries[symbol]--
+		g.mu.Unlock()
+	}
+	return o, err
+}
+
+// CheckOrder applies the cooldown and re-entry count without reserving anything, then the checks
+// further down the chain.
+func (g *ReentryGuard) CheckOrder(req alpaca.OrderRequest) (alpaca.OrderRequest, error) {
+	symbol := strings.ToUpper(req.Symbol)
+	g.mu.Lock()
+	_, err := g.checkLocked(req, symbol, g.Rule(symbol), g.clock.Now())
+	g.mu.Unlock()
+	if err != nil {
+		return req, err
+	}
+	return alpaca.CheckOrder(g.next, req)
+}
+
+// checkLocked refuses an entry that is inside a cooldown or over the daily re-entry count. reentry
+// reports an entry from flat after an exit (counted against MaxReentries); exits always pass.
+func (g *ReentryGuard) checkLocked(req alpaca.OrderRequest, symbol string, rule ReentryRule, now time.Time) (reentry bool, err error) {
+	g.rollDayLocked(now)
+	pos := g.pos[symbol]
+	buy := strings.EqualFold(req.Side, "buy")
+	if (buy && pos < 0) || (!buy && pos > 0) {
+		return false, nil
+	}
+	if ex, ok := g.lastExit[symbol]; ok {
+		wait, what := rule.ExitCooldown, "exit"
+		if ex.stop && rule.StopCooldown > wait {
+			wait, what = rule.StopCooldown, "stop-out"
+		}
+		if left := ex.at.Add(wait).Sub(now); left > 0 {
+			return false, fmt.Errorf("%w: %s %s: cooldown after %s, %s left", ErrReentryBlocked, req.Side, symbol, what, left.Round(time.Second))
+		}
+	}
+	reentry = pos == 0 && g.exited[symbol]
+	if reentry && rule.MaxReentries > 0 && g.reentries[symbol] >= rule.MaxReentries {
+		return false, fmt.Errorf("%w: %s %s: %d re-entries today (max %d)", ErrReentryBlocked, req.Side, symbol, g.reentries[symbol], rule.MaxReentries)
+	}
+	return reentry, nil
+}
+
+// OnTradeUpdate tracks fills; a fill that shrinks the position is an exit, and a stop order fill or an
+// exit past the average entry price is a stop-out.
+func (g *ReentryGuard) OnTradeUpdate(u alpaca.TradeUpdate) {
+	if u.Event != "fill" && u.Event != "partial_fill" {
+		return
+	}
+	o := u.Order
+	symbol := strings.ToUpper(o.Symbol)
+	price, qty := u.Price.Value(), u.Qty.Value()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.rollDayLocked(g.clock.Now())
+	prev := g.pos[symbol]
+	after := prev
+	if u.PositionQty != nil {
+		after = u.PositionQty.Value()
+	} else if o.Side == "buy" {
+		after = prev + qty
+	} else {
+		after = prev - qty
+	}
+	if prev != 0 && math.Abs(after) < math.Abs(prev) {
+		avg := g.avg[symbol]
+		stop := strings.Contains(o.Type, "stop") ||
+			(avg > 0 && price > 0 && ((prev > 0 && price < avg) || (prev < 0 && price > avg)))
+		g.recordExitLocked(symbol, after, stop)
+	} else if math.Abs(after) > math.Abs(prev) && price > 0 {
+		g.avg[symbol] = (g.avg[symbol]*math.Abs(prev) + price*(math.Abs(after)-math.Abs(prev))) / math.Abs(after)
+	}
+	g.setPosLocked(symbol, after)
+}
+
+// SyncPositions reconciles with the broker; positions that shrank since the last poll or update count
+// as exits (stop-out when they were last seen at a loss).
+func (g *ReentryGuard) SyncPositions(positions []alpaca.Position) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.rollDayLocked(g.clock.Now())
+	seen := make(map[string]bool, len(positions))
+	for _, p := range positions {
+		symbol := strings.ToUpper(p.Symbol)
+		seen[symbol] = true
+		qty, err := strconv.ParseFloat(p.Qty, 64)
+		if err != nil {
+			continue
+		}
+		if p.Side == "short" && qty > 0 {
+			qty = -qty
+		}
+		if prev := g.pos[symbol]; prev != 0 && math.Abs(qty) < math.Abs(prev) {
+			g.recordExitLocked(symbol, qty, g.upl[symbol] < 0)
+		}
+		g.setPosLocked(symbol, qty)
+		if avg := p.AvgEntryPrice.Value(); avg > 0 {
+			g.avg[symbol] = avg
+		}
+		if upl, err := strconv.ParseFloat(p.UnrealizedPL, 64); err == nil {
+			g.upl[symbol] = upl
+		}
+	}
+	for symbol, prev := range g.pos {
+		if !seen[symbol] && prev != 0 {
+			g.recordExitLocked(symbol, 0, g.upl[symbol] < 0)
+			g.setPosLocked(symbol, 0)
+		}
+	}
+}
+
+func (g *ReentryGuard) recordExitLocked(symbol string, after float64, stop bool) {
+	g.lastExit[symbol] = exitRecord{at: g.clock.Now(), stop: stop}
+	if after == 0 {
+		g.exited[symbol] = true
+	}
+}
+
+func (g *ReentryGuard) setPosLocked(symbol string, qty float64) {
+	if qty == 0 {
+		delete(g.pos, symbol)
+		delete(g.avg, symbol)
+		delete(g.upl, symbol)
+		return
+	}
+	g.pos[symbol] = qty
+}
+
+// rollDayLocked resets the daily re-entry counts at the ET date change.
+func (g *ReentryGuard) rollDayLocked(now time.Time) {
+	if day := now.In(brain.Eastern()).Format("2006-01-02"); day != g.day {
+		g.day = day
+		g.exited = make(map[string]bool)
+		g.reentries = make(map[string]int)
+	}
+}
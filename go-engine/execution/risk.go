@@ -0,0 +1,192 @@
+package execution
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sunnyp94/sentry-bridge/go-engine/alpaca"
+)
+
+// ErrRiskLimit is returned (wrapped) when an order breaks a pre-trade risk limit.
+var ErrRiskLimit = errors.New("order blocked by risk limit")
+
+// RiskConfig holds the pre-trade limits checked on every order. Zero (or empty) disables a limit.
+type RiskConfig struct {
+	MaxOrderNotional    float64         // Dollars per order (qty x limit or last price), exits included
+	MaxPositionNotional float64         // Dollars held in one symbol after the order and the working ones on its side fill
+	MaxPositionShares   float64         // Shares held in one symbol after the order and the working ones on its side fill
+	MaxOpenOrders       int             // Working orders account-wide before a new entry is refused
+	Banned              map[string]bool // Symbols that may not be added to; exits are still allowed
+}
+
+// RiskGuard wraps an order placer and refuses orders that break a RiskConfig limit. Orders that only
+// reduce a position are checked against the per-order notional cap alone. The position limits count the
+// unfilled qty of working orders on the same side, so a burst of entries can't overshoot them before the
+// fills arrive. Positions and working orders come from the positions/orders poll and trade updates, so
+// activity outside the engine counts too.
+type RiskGuard struct {
+	cfg   RiskConfig
+	next  alpaca.OrderPlacer
+	price PriceFunc
+
+	mu   sync.Mutex
+	pos  map[string]float64 // signed position qty
+	open map[string]working // by order ID
+}
+
+// working is an order still at the broker: its symbol and the signed qty it has left to fill.
+type working struct {
+	symbol string
+	qty    float64
+}
+
+// workingOrder returns o's symbol and unfilled qty, negative for a sell.
+func workingOrder(o alpaca.Order) working {
+	qty, _ := strconv.ParseFloat(o.Qty, 64)
+	filled, _ := strconv.ParseFloat(o.FilledQty, 64)
+	left := math.Max(qty-filled, 0)
+	if strings.EqualFold(o.Side, "sell") {
+		left = -left
+	}
+	return working{symbol: strings.ToUpper(o.Symbol), qty: left}
+}
+
+// NewRiskGuard wraps next; price values market orders for the notional limits.
+func NewRiskGuard(cfg RiskConfig, next alpaca.OrderPlacer, price PriceFunc) *RiskGuard {
+	return &RiskGuard{cfg: cfg, next: next, price: price, pos: make(map[string]float64), open: make(map[string]working)}
+}
+
+// PlaceOrder forwards req if it passes every limit; the accepted order counts as working right away.
+func (g *RiskGuard) PlaceOrder(req alpaca.OrderRequest) (*alpaca.Order, error) {
+	g.mu.Lock()
+	err := g.checkLocked(req)
+	g.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	o, err := g.next.PlaceOrder(req)
+	if err == nil && o != nil && o.ID != "" {
+		w := workingOrder(*o)
+		if w.qty == 0 { // a placer that doesn't echo the order back
+			w = workingOrder(alpaca.Order{Symbol: req.Symbol, Side: req.Side, Qty: req.Qty})
+		}
+		g.mu.Lock()
+		g.open[o.ID] = w
+		g.mu.Unlock()
+	}
+	return o, err
+}
+
+// CheckOrder applies the limits without submitting, then the checks further down the chain.
+func (g *RiskGuard) CheckOrder(req alpaca.OrderRequest) (alpaca.OrderRequest, error) {
+	g.mu.Lock()
+	err := g.checkLocked(req)
+	g.mu.Unlock()
+	if err != nil {
+		return req, err
+	}
+	return alpaca.CheckOrder(g.next, req)
+}
+
+// checkLocked returns the first limit req would break.
+func (g *RiskGuard) checkLocked(req alpaca.OrderRequest) error {
+	symbol := strings.ToUpper(req.Symbol)
+	refuse := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w: %s %s: %s", ErrRiskLimit, req.Side, symbol, fmt.Sprintf(format, args...))
+	}
+	qty, err := strconv.ParseFloat(req.Qty, 64)
+	if err != nil || qty <= 0 {
+		return refuse("qty %q is not a positive number", req.Qty)
+	}
+	pos := g.pos[symbol]
+	after := pos + qty
+	if strings.EqualFold(req.Side, "sell") {
+		after = pos - qty
+	}
+	adds := math.Abs(after) > math.Abs(pos)
+	if adds {
+		// Working orders on the same side fill into the same position
+		for _, w := range g.open {
+			if w.symbol == symbol && (w.qty > 0) == (after > pos) {
+				after += w.qty
+			}
+		}
+	}
+	if adds && g.cfg.Banned[symbol] {
+		return refuse("symbol is banned")
+	}
+	if adds && g.cfg.MaxOpenOrders > 0 && len(g.open) >= g.cfg.MaxOpenOrders {
+		return refuse("%d working orders (max %d)", len(g.open), g.cfg.MaxOpenOrders)
+	}
+	if adds && g.cfg.MaxPositionShares > 0 && math.Abs(after) > g.cfg.MaxPositionShares {
+		return refuse("position would be %g shares (max %g)", math.Abs(after), g.cfg.MaxPositionShares)
+	}
+	if g.cfg.MaxOrderNotional <= 0 && (!adds || g.cfg.MaxPositionNotional <= 0) {
+		return nil
+	}
+	px := req.LimitPrice
+	if px <= 0 && g.price != nil {
+		px = g.price(symbol)
+	}
+	if px <= 0 {
+		return refuse("no price to check the notional limits")
+	}
+	if n := qty * px; g.cfg.MaxOrderNotional > 0 && n > g.cfg.MaxOrderNotional {
+		return refuse("order notional $%.2f exceeds $%.2f", n, g.cfg.MaxOrderNotional)
+	}
+	if n := math.Abs(after) * px; adds && g.cfg.MaxPositionNotional > 0 && n > g.cfg.MaxPositionNotional {
+		return refuse("position would be $%.2f (max $%.2f)", n, g.cfg.MaxPositionNotional)
+	}
+	return nil
+}
+
+// OnTradeUpdate follows fills and the lifecycle of working orders.
+func (g *RiskGuard) OnTradeUpdate(u alpaca.TradeUpdate) {
+	symbol := strings.ToUpper(u.Order.Symbol)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	switch u.Event {
+	case "new", "accepted", "pending_new", "partial_fill":
+		if u.Order.ID != "" {
+			g.open[u.Order.ID] = workingOrder(u.Order)
+		}
+	case "fill", "canceled", "expired", "rejected", "done_for_day", "replaced":
+		delete(g.open, u.Order.ID)
+	}
+	if (u.Event == "fill" || u.Event == "partial_fill") && u.PositionQty != nil {
+		if qty := u.PositionQty.Value(); qty != 0 {
+			g.pos[symbol] = qty
+		} else {
+			delete(g.pos, symbol)
+		}
+	}
+}
+
+// SyncPositions replaces tracked positions with the broker's.
+func (g *RiskGuard) SyncPositions(positions []alpaca.Position) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.pos = make(map[string]float64, len(positions))
+	for _, p := range positions {
+		if qty, err := strconv.ParseFloat(p.Qty, 64); err == nil && qty != 0 {
+			if p.Side == "short" && qty > 0 {
+				qty = -qty
+			}
+			g.pos[strings.ToUpper(p.Symbol)] = qty
+		}
+	}
+}
+
+// SyncOrders replaces tracked working orders with the broker's open orders.
+func (g *RiskGuard) SyncOrders(orders []alpaca.Order) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.open = make(map[string]working, len(orders))
+	for _, o := range orders {
+		g.open[o.ID] = workingOrder(o)
+	}
+}
@@ -0,0 +1,225 @@
+package execution
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sunnyp94/sentry-bridge/go-engine/alpaca"
+	"github.com/sunnyp94/sentry-bridge/go-engine/clock"
+	"github.com/sunnyp94/sentry-bridge/go-engine/events"
+)
+
+// ErrNotShortable is returned (wrapped) when a sell would open or add to a short the broker won't allow.
+var ErrNotShortable = errors.New("order blocked by short sale check")
+
+// shortAssetTTL is how long an asset's shortable and easy_to_borrow flags are trusted; the broker
+// updates its borrow list daily.
+const shortAssetTTL = time.Hour
+
+// ShortGuard wraps an order placer and refuses sells that would open or add to a short in an asset the
+// broker marks not shortable, or not easy to borrow when RequireETB is set, instead of forwarding them
+// for the broker to reject. Sells up to the long position are exits and always pass; working sell orders
+// (and sells being placed) already claim part of it, so two exits can't both sell the same shares. Asset
+// flags are cached (seeded by the asset preflight); when they can't be fetched the order goes through.
+type ShortGuard struct {
+	next       alpaca.OrderPlacer
+	lookup     func(symbol string) (*alpaca.Asset, error)
+	requireETB bool
+	clock      clock.Clock
+
+	mu       sync.Mutex
+	pos      map[string]float64 // signed position qty
+	open     map[string]working // working orders by order ID
+	reserved map[string]float64 // qty of sells passed but not yet placed, by symbol
+	assets   map[string]cachedAsset
+
+	// OnReject receives an order_rejected event for each refused order. Optional.
+	OnReject func(events.OrderRejectedEvent)
+}
+
+type cachedAsset struct {
+	asset alpaca.Asset
+	at    time.Time
+}
+
+// NewShortGuard wraps next; lookup fetches an asset's flags (the trading API's GET /v2/assets/{symbol}).
+func NewShortGuard(next alpaca.OrderPlacer, lookup func(symbol string) (*alpaca.Asset, error), requireETB bool) *ShortGuard {
+	return &ShortGuard{next: next, lookup: lookup, requireETB: requireETB, clock: clock.Real{},
+		pos: make(map[string]float64), open: make(map[string]working), reserved: make(map[string]float64),
+		assets: make(map[string]cachedAsset)}
+}
+
+// SetClock replaces the clock for the asset cache. Call before use.
+func (g *ShortGuard) SetClock(c clock.Clock) { g.clock = c }
+
+// Seed caches assets already fetched, by symbol.
+func (g *ShortGuard) Seed(assets map[string]alpaca.Asset) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := g.clock.Now()
+	for sym, a := range assets {
+		g.assets[strings.ToUpper(sym)] = cachedAsset{asset: a, at: now}
+	}
+}
+
+// PlaceOrder forwards req unless it is a short sale the asset doesn't allow. An accepted sell counts as
+// working right away.
+func (g *ShortGuard) PlaceOrder(req alpaca.OrderRequest) (*alpaca.Order, error) {
+	ev, reserved, err := g.check(req, true)
+	if err != nil {
+		if g.OnReject != nil {
+			g.OnReject(ev)
+		}
+		return nil, err
+	}
+	o, err := g.next.PlaceOrder(req)
+	if reserved > 0 {
+		symbol := strings.ToUpper(req.Symbol)
+		g.mu.Lock()
+		if g.reserved[symbol] -= reserved; g.reserved[symbol] <= 0 {
+			delete(g.reserved, symbol)
+		}
+		if err == nil && o != nil && o.ID != "" {
+			w := workingOrder(*o)
+			if w.qty == 0 { // a placer that doesn't echo the order back
+				w = workingOrder(alpaca.Order{Symbol: req.Symbol, Side: req.Side, Qty: req.Qty})
+			}
+			g.open[o.ID] = w
+		}
+		g.mu.Unlock()
+	}
+	return o, err
+}
+
+// CheckOrder applies the short sale check without submitting or reporting, then the checks further
+// down the chain.
+func (g *ShortGuard) CheckOrder(req alpaca.OrderRequest) (alpaca.OrderRequest, error) {
+	if _, _, err := g.check(req, false); err != nil {
+		return req, err
+	}
+	return alpaca.CheckOrder(g.next, req)
+}
+
+// check refuses req if it sells short an asset that can't be shorted; ev describes the refusal. The long
+// position left to sell is what working and reserved sells haven't claimed. With reserve, a sell that
+// passes is reserved until PlaceOrder has placed it; reserved is its qty.
+func (g *ShortGuard) check(req alpaca.OrderRequest, reserve bool) (ev events.OrderRejectedEvent, reserved float64, err error) {
+	if !strings.EqualFold(req.Side, "sell") {
+		return ev, 0, nil
+	}
+	symbol := strings.ToUpper(req.Symbol)
+	qty, _ := strconv.ParseFloat(req.Qty, 64)
+	pass := func() (events.OrderRejectedEvent, float64, error) {
+		if !reserve || qty <= 0 {
+			return ev, 0, nil
+		}
+		g.mu.Lock()
+		g.reserved[symbol] += qty
+		g.mu.Unlock()
+		return ev, qty, nil
+	}
+	g.mu.Lock()
+	pos := g.pos[symbol]
+	selling := g.reserved[symbol]
+	for _, w := range g.open {
+		if w.symbol == symbol && w.qty < 0 {
+			selling -= w.qty
+		}
+	}
+	g.mu.Unlock()
+	left := pos - selling
+	if qty <= left {
+		return pass()
+	}
+	a, ok := g.asset(symbol)
+	if !ok {
+		return pass()
+	}
+	ev = events.OrderRejectedEvent{Symbol: symbol, Side: req.Side, Qty: req.Qty, Type: req.Type, ClientOrderID: req.ClientOrderID,
+		CorrelationID: req.CorrelationID, Position: pos, Shortable: a.Shortable, EasyToBorrow: a.EasyToBorrow}
+	switch {
+	case !a.Shortable:
+		ev.Reason = "not_shortable"
+	case g.requireETB && !a.EasyToBorrow:
+		ev.Reason = "hard_to_borrow"
+	default:
+		return pass()
+	}
+	ev.Detail = fmt.Sprintf("sell %s %s would go short %g (position %g, %g already selling): %s", req.Qty, symbol, qty-max(left, 0), pos,
+		selling, strings.ReplaceAll(ev.Reason, "_", " "))
+	return ev, 0, fmt.Errorf("%w: %s", ErrNotShortable, ev.Detail)
+}
+
+// asset returns symbol's cached flags, fetching them when missing or older than shortAssetTTL; false
+// when they can't be had.
+func (g *ShortGuard) asset(symbol string) (alpaca.Asset, bool) {
+	now := g.clock.Now()
+	g.mu.Lock()
+	c, ok := g.assets[symbol]
+	g.mu.Unlock()
+	if ok && now.Sub(c.at) < shortAssetTTL {
+		return c.asset, true
+	}
+	a, err := g.lookup(symbol)
+	if err != nil {
+		slog.Warn("short sale check: asset lookup failed; order not checked", "symbol", symbol, "err", err)
+		return alpaca.Asset{}, false
+	}
+	g.mu.Lock()
+	g.assets[symbol] = cachedAsset{asset: *a, at: now}
+	g.mu.Unlock()
+	return *a, true
+}
+
+// OnTradeUpdate follows fills and the lifecycle of working orders.
+func (g *ShortGuard) OnTradeUpdate(u alpaca.TradeUpdate) {
+	symbol := strings.ToUpper(u.Order.Symbol)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	switch u.Event {
+	case "new", "accepted", "pending_new", "partial_fill":
+		if u.Order.ID != "" {
+			g.open[u.Order.ID] = workingOrder(u.Order)
+		}
+	case "fill", "canceled", "expired", "rejected", "done_for_day", "replaced":
+		delete(g.open, u.Order.ID)
+	}
+	if (u.Event != "fill" && u.Event != "partial_fill") || u.PositionQty == nil {
+		return
+	}
+	if qty := u.PositionQty.Value(); qty != 0 {
+		g.pos[symbol] = qty
+	} else {
+		delete(g.pos, symbol)
+	}
+}
+
+// SyncPositions replaces tracked positions with the broker's.
+func (g *ShortGuard) SyncPositions(positions []alpaca.Position) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.pos = make(map[string]float64, len(positions))
+	for _, p := range positions {
+		if qty, err := strconv.ParseFloat(p.Qty, 64); err == nil && qty != 0 {
+			if p.Side == "short" && qty > 0 {
+				qty = -qty
+			}
+			g.pos[strings.ToUpper(p.Symbol)] = qty
+		}
+	}
+}
+
+// SyncOrders replaces tracked working orders with the broker's open orders.
+func (g *ShortGuard) SyncOrders(orders []alpaca.Order) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.open = make(map[string]working, len(orders))
+	for _, o := range orders {
+		g.open[o.ID] = workingOrder(o)
+	}
+}
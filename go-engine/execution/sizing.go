@@ -0,0 +1,239 @@
+package execution
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sunnyp94/sentry-bridge/go-engine/alpaca"
+	"github.com/sunnyp94/sentry-bridge/go-engine/brain"
+	"github.com/sunnyp94/sentry-bridge/go-engine/events"
+)
+
+// tradingDays converts annualized volatility to an expected one-day move.
+const tradingDays = 252
+
+// accountTTL bounds how stale the cached account (equity, buying power) may be when sizing.
+const accountTTL = 10 * time.Second
+
+// SizingConfig is the account-wide sizing policy.
+type SizingConfig struct {
+	RiskPerTrade   float64 // Fraction of equity lost if the stop is hit at conviction 1 (e.g. 0.005)
+	StopVolMult    float64 // Default stop distance in expected one-day moves (price x vol / sqrt(252))
+	MaxPositionPct float64 // Cap on one position's notional as a fraction of equity (0 = no cap)
+	BuyingPowerPct float64 // Fraction of remaining buying power one order may use (0 = no cap)
+	Fractional     bool    // Allow fractional shares (otherwise round down to whole shares)
+}
+
+// Intent is an abstract order from the brain: direction and conviction, no quantity.
+type Intent struct {
+	Symbol     string  `json:"symbol"`
+	Side       string  `json:"side"`                 // "buy"/"long" or "sell"/"short"
+	Conviction float64 `json:"conviction,omitempty"` // 0..1; 0 or omitted = 1
+	Price      float64 `json:"price,omitempty"`      // Reference price; default last trade (or mid)
+	StopPrice  float64 `json:"stop_price,omitempty"` // Explicit stop; overrides the volatility stop
+	ATR        float64 `json:"atr,omitempty"`        // Brain-supplied ATR in dollars; overrides volatility
+}
+
+// Size is the sizing decision for one intent. Limit names the constraint that set Qty.
+type Size struct {
+	Symbol       string             `json:"symbol"`
+	Side         string             `json:"side"`
+	Qty          float64            `json:"qty"`
+	Price        float64            `json:"price"`
+	Notional     float64            `json:"notional"`
+	StopDistance float64            `json:"stop_distance"`
+	RiskDollars  float64            `json:"risk_dollars"`
+	Conviction   float64            `json:"conviction"`
+	Equity       float64            `json:"equity"`
+	BuyingPower  float64            `json:"buying_power"`
+	Position     float64            `json:"position,omitempty"`       // signed position held before the order
+	Limit        string             `json:"limit"`                    // "risk", "max_position", "buying_power" or "position"
+	Costs        *events.SymbolCost `json:"costs,omitempty"`          // cost table row used
+	CostPerShare float64            `json:"cost_per_share,omitempty"` // round-trip cost, counted in the risk per share
+}
+
+// Market supplies the reference price and annualized volatility for a symbol (brain.State).
+type Market interface {
+	LastTicks(symbol string, n int) []brain.Tick
+	LastQuote(symbol string) (brain.QuoteSnapshot, bool)
+	Volatility(symbol string) float64
+}
+
+// AccountFunc fetches the broker account (alpaca.TradingClient.GetAccount).
+type AccountFunc func() (*alpaca.Account, error)
+
+// Sizer converts intents into share quantities so sizing policy lives in one place rather than in each
+// strategy: risk per trade scaled by conviction over the stop distance, capped by position size and
+// remaining buying power. An intent against the position held closes it instead.
+type Sizer struct {
+	cfg     SizingConfig
+	market  Market
+	account AccountFunc
+	costs   CostTable
+
+	// Position returns the signed position in symbol (negative = short): a sell against a long (or a buy
+	// against a short) is sized to close it, and an intent adding to it is capped by what is left of
+	// MaxPositionPct. Optional; set before use. Without it every intent is sized as a new position.
+	Position func(symbol string) float64
+
+	mu        sync.Mutex
+	acct      *alpaca.Account
+	fetchedAt time.Time
+}
+
+// NewSizer creates a sizer using market for prices/volatility and account for equity/buying power.
+func NewSizer(cfg SizingConfig, market Market, account AccountFunc) *Sizer {
+	return &Sizer{cfg: cfg, market: market, account: account}
+}
+
+// SetCosts sets the per-symbol cost table; a symbol's round-trip cost is added to its stop distance, so
+// costlier names get smaller sizes for the same risk. Call before use.
+func (s *Sizer) SetCosts(t CostTable) { s.costs = t }
+
+// Size computes the quantity for in. A zero Qty with no error means the caps left nothing to trade.
+func (s *Sizer) Size(in Intent) (Size, error) {
+	symbol := strings.ToUpper(strings.TrimSpace(in.Symbol))
+	side, err := normalizeSide(in.Side)
+	if err != nil {
+		return Size{}, err
+	}
+	if symbol == "" {
+		return Size{}, errors.New("symbol required")
+	}
+	conviction := in.Conviction
+	if conviction <= 0 || conviction > 1 {
+		conviction = 1
+	}
+	price := in.Price
+	if price <= 0 {
+		price = s.lastPrice(symbol)
+	}
+	if price <= 0 {
+		return Size{}, fmt.Errorf("no price for %s", symbol)
+	}
+	var pos float64
+	if s.Position != nil {
+		pos = s.Position(symbol)
+	}
+	if (side == "sell" && pos > 0) || (side == "buy" && pos < 0) {
+		qty := math.Abs(pos)
+		return Size{Symbol: symbol, Side: side, Qty: qty, Price: price, Notional: qty * price, Conviction: conviction,
+			Position: pos, Limit: "position"}, nil
+	}
+	stop := s.stopDistance(symbol, price, in)
+	if stop <= 0 {
+		return Size{}, fmt.Errorf("no volatility for %s (pass atr or stop_price)", symbol)
+	}
+	acct, err := s.cachedAccount()
+	if err != nil {
+		return Size{}, fmt.Errorf("account: %w", err)
+	}
+	equity, buyingPower := acct.Equity.Value(), acct.BuyingPower.Value()
+	if equity <= 0 {
+		return Size{}, errors.New("account equity is zero")
+	}
+
+	out := Size{Symbol: symbol, Side: side, Price: price, StopDistance: stop, Conviction: conviction,
+		Equity: equity, BuyingPower: buyingPower, Position: pos, Limit: "risk"}
+	risk := stop
+	if c, ok := s.costs.Lookup(symbol); ok {
+		out.Costs = &c
+		out.CostPerShare = perShare(c, price, side == "sell")
+		risk += out.CostPerShare
+	}
+	qty := equity * s.cfg.RiskPerTrade * conviction / risk
+	if s.cfg.MaxPositionPct > 0 {
+		if capQty := equity*s.cfg.MaxPositionPct/price - math.Abs(pos); capQty < qty {
+			qty, out.Limit = capQty, "max_position"
+		}
+	}
+	if s.cfg.BuyingPowerPct > 0 {
+		if capQty := math.Max(buyingPower, 0) * s.cfg.BuyingPowerPct / price; capQty < qty {
+			qty, out.Limit = capQty, "buying_power"
+		}
+	}
+	if s.cfg.Fractional {
+		qty = math.Floor(qty*1e4) / 1e4
+	} else {
+		qty = math.Floor(qty)
+	}
+	if qty < 0 {
+		qty = 0
+	}
+	out.Qty = qty
+	out.Notional = qty * price
+	out.RiskDollars = qty * risk
+	return out, nil
+}
+
+// stopDistance is the per-share loss at the stop: explicit stop price, else ATR, else the volatility stop.
+func (s *Sizer) stopDistance(symbol string, price float64, in Intent) float64 {
+	if in.StopPrice > 0 {
+		return math.Abs(price - in.StopPrice)
+	}
+	mult := s.cfg.StopVolMult
+	if mult <= 0 {
+		mult = 1
+	}
+	if in.ATR > 0 {
+		return in.ATR * mult
+	}
+	return price * s.market.Volatility(symbol) / math.Sqrt(tradingDays) * mult
+}
+
+// lastPrice is the last trade, else the quote mid.
+func (s *Sizer) lastPrice(symbol string) float64 {
+	if ticks := s.market.LastTicks(symbol, 1); len(ticks) == 1 && ticks[0].Price > 0 {
+		return ticks[0].Price
+	}
+	if q, ok := s.market.LastQuote(symbol); ok && q.Bid > 0 && q.Ask > 0 {
+		return (q.Bid + q.Ask) / 2
+	}
+	return 0
+}
+
+// cachedAccount returns the account, refetching when older than accountTTL.
+func (s *Sizer) cachedAccount() (*alpaca.Account, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.acct != nil && time.Since(s.fetchedAt) < accountTTL {
+		return s.acct, nil
+	}
+	acct, err := s.account()
+	if err != nil {
+		return nil, err
+	}
+	s.acct, s.fetchedAt = acct, time.Now()
+	return acct, nil
+}
+
+// normalizeSide maps long/short aliases onto order sides.
+func normalizeSide(side string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(side)) {
+	case "buy", "long":
+		return "buy", nil
+	case "sell", "short":
+		return "sell", nil
+	}
+	return "", fmt.Errorf("side must be buy/long or sell/short, got %q", side)
+}
+
+// RegisterHandlers exposes the sizer on the brain request channel:
+//
+//	size {"symbol","side","conviction","price","stop_price","atr"} -> Size (qty, notional, risk_dollars, limit, ...)
+func RegisterHandlers(p *brain.Pipe, s *Sizer) {
+	p.Handle("size", func(raw json.RawMessage) (interface{}, error) {
+		var in Intent
+		if len(raw) > 0 {
+			if err := json.Unmarshal(raw, &in); err != nil {
+				return nil, fmt.Errorf("bad params: %w", err)
+			}
+		}
+		return s.Size(in)
+	})
+}
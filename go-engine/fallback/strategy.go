@@ -0,0 +1,251 @@
+// Package fallback is a minimal rules-based brain in Go: volatility-scaled momentum on trade events with
+// strict risk caps. It trades only when enabled (FALLBACK_BRAIN) and, in auto mode, only while the Python
+// brain is down or not configured, so the engine is a complete trading loop on its own.
+package fallback
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sunnyp94/sentry-bridge/go-engine/alpaca"
+	"github.com/sunnyp94/sentry-bridge/go-engine/brain"
+	"github.com/sunnyp94/sentry-bridge/go-engine/events"
+)
+
+// Modes for FALLBACK_BRAIN.
+const (
+	ModeOff  = "off"  // never trade
+	ModeAuto = "auto" // trade while the Python brain is not running
+	ModeOn   = "on"   // always trade (alongside or instead of the Python brain)
+)
+
+// What happens to the fallback's positions when the Python brain is back (auto mode).
+const (
+	RecoveryFlatten = "flatten" // sell them
+	RecoveryHandoff = "handoff" // leave them to the brain, which sees them in the positions poll
+)
+
+// ClientOrderPrefix marks orders placed by the fallback brain so they are distinguishable in the account
+// and audit trail.
+const ClientOrderPrefix = "fb-"
+
+// fiveMinPerYear converts annualized volatility to the expected 5-minute move (252 days x 78 bars).
+const fiveMinPerYear = 252 * 78
+
+// Config holds the strategy rules and risk caps.
+type Config struct {
+	EntryZ           float64       // Enter long when return_5m >= EntryZ x expected 5m move
+	ExitZ            float64       // Exit when return_5m <= -ExitZ x expected 5m move
+	StopLossPct      float64       // Exit when price falls this fraction below entry (e.g. 0.01)
+	TakeProfitPct    float64       // Exit when price rises this fraction above entry (e.g. 0.02)
+	MaxNotional      float64       // Dollar cap per position
+	MaxPositions     int           // Cap on open positions account-wide (including ones the fallback didn't open)
+	MaxEntriesPerDay int           // Cap on new entries per ET day
+	Cooldown         time.Duration // Minimum time between orders in one symbol
+	MinVolume1m      int64         // Skip entries on thin tape
+	OnRecovery       string        // RecoveryFlatten (default) or RecoveryHandoff
+	DryRun           bool          // Log decisions without placing orders
+}
+
+// position is a long opened by the fallback brain.
+type position struct {
+	Qty        float64
+	EntryPrice float64
+	OpenedAt   time.Time
+}
+
+// Strategy is safe for concurrent use; order submission runs off the market-data goroutine.
+type Strategy struct {
+	cfg    Config
+	placer alpaca.OrderPlacer
+
+	mu         sync.Mutex
+	positions  map[string]position  // longs opened by us
+	held       map[string]bool      // every symbol with a broker position (from SyncPositions)
+	pending    map[string]bool      // order in flight
+	lastOrder  map[string]time.Time // per-symbol cooldown
+	entryDay   string
+	entries    int
+	orderCount int
+}
+
+// New creates a strategy placing orders through placer.
+func New(cfg Config, placer alpaca.OrderPlacer) *Strategy {
+	return &Strategy{
+		cfg:       cfg,
+		placer:    placer,
+		positions: make(map[string]position),
+		held:      make(map[string]bool),
+		pending:   make(map[string]bool),
+		lastOrder: make(map[string]time.Time),
+	}
+}
+
+// OnTrade evaluates one trade event; eventID (its event ID, if any) becomes the correlation ID of an
+// order it leads to. Orders are only sent in the regular session (market orders).
+func (s *Strategy) OnTrade(ev events.TradeEvent, eventID string, now time.Time) {
+	if ev.Session != "regular" || ev.Price <= 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if day := now.In(brain.Eastern()).Format("2006-01-02"); day != s.entryDay {
+		s.entryDay = day
+		s.entries = 0
+	}
+	if s.pending[ev.Symbol] {
+		return
+	}
+	move := ev.Volatility * math.Sqrt(1.0/fiveMinPerYear)
+	if pos, ok := s.positions[ev.Symbol]; ok {
+		reason := ""
+		switch {
+		case s.cfg.StopLossPct > 0 && ev.Price <= pos.EntryPrice*(1-s.cfg.StopLossPct):
+			reason = "stop_loss"
+		case s.cfg.TakeProfitPct > 0 && ev.Price >= pos.EntryPrice*(1+s.cfg.TakeProfitPct):
+			reason = "take_profit"
+		case move > 0 && ev.Return5m <= -s.cfg.ExitZ*move:
+			reason = "momentum_reversal"
+		}
+		if reason != "" {
+			s.submitLocked(ev.Symbol, "sell", pos.Qty, ev.Price, reason, eventID, now)
+		}
+		return
+	}
+	// Entry: every cap must pass
+	if move <= 0 || ev.Return5m < s.cfg.EntryZ*move {
+		return
+	}
+	if s.held[ev.Symbol] || ev.Volume1m < s.cfg.MinVolume1m {
+		return
+	}
+	if s.cfg.MaxEntriesPerDay > 0 && s.entries >= s.cfg.MaxEntriesPerDay {
+		return
+	}
+	if s.cfg.MaxPositions > 0 && s.openCountLocked() >= s.cfg.MaxPositions {
+		return
+	}
+	if last, ok := s.lastOrder[ev.Symbol]; ok && now.Sub(last) < s.cfg.Cooldown {
+		return
+	}
+	qty := math.Floor(s.cfg.MaxNotional / ev.Price)
+	if qty < 1 {
+		return
+	}
+	s.entries++
+	s.submitLocked(ev.Symbol, "buy", qty, ev.Price, "momentum", eventID, now)
+}
+
+// Recovered is OnTrade for a symbol the Python brain is running again: a position the fallback opened
+// is sold on the trade (RecoveryFlatten, regular session) or forgotten (RecoveryHandoff), since nothing
+// here watches its exits any more. Cheap when the fallback holds nothing in the symbol.
+func (s *Strategy) Recovered(ev events.TradeEvent, eventID string, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pos, ok := s.positions[ev.Symbol]
+	if !ok || s.pending[ev.Symbol] {
+		return
+	}
+	if s.cfg.OnRecovery == RecoveryHandoff {
+		delete(s.positions, ev.Symbol)
+		slog.Info("fallback brain position handed to the brain", "symbol", ev.Symbol, "qty", pos.Qty, "entry_price", pos.EntryPrice)
+		return
+	}
+	if ev.Session == "regular" && ev.Price > 0 {
+		s.submitLocked(ev.Symbol, "sell", pos.Qty, ev.Price, "brain_recovered", eventID, now)
+	}
+}
+
+// openCountLocked counts distinct symbols held at the broker or opened by us.
+func (s *Strategy) openCountLocked() int {
+	n := len(s.held)
+	for sym := range s.positions {
+		if !s.held[sym] {
+			n++
+		}
+	}
+	for sym := range s.pending {
+		if !s.held[sym] {
+			if _, ok := s.positions[sym]; !ok {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// submitLocked marks the symbol pending and places the order in the background.
+func (s *Strategy) submitLocked(symbol, side string, qty, price float64, reason, eventID string, now time.Time) {
+	s.pending[symbol] = true
+	s.lastOrder[symbol] = now
+	s.orderCount++
+	req := alpaca.OrderRequest{
+		Symbol:        symbol,
+		Qty:           strconv.FormatFloat(qty, 'f', -1, 64),
+		Side:          side,
+		Type:          "market",
+		TimeInForce:   "day",
+		ClientOrderID: fmt.Sprintf("%s%s-%d-%d", ClientOrderPrefix, symbol, now.UnixNano(), s.orderCount),
+		Source:        "fallback",
+		CorrelationID: eventID,
+	}
+	slog.Info("fallback brain order", "symbol", symbol, "side", side, "qty", req.Qty, "price", price, "reason", reason, "dry_run", s.cfg.DryRun)
+	day := s.entryDay
+	go func() {
+		var err error
+		if !s.cfg.DryRun {
+			_, err = s.placer.PlaceOrder(req)
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.pending, symbol)
+		if err != nil {
+			// A refused entry doesn't use up the day's allowance
+			if side == "buy" && s.entryDay == day && s.entries > 0 {
+				s.entries--
+			}
+			slog.Error("fallback brain order failed", "symbol", symbol, "side", side, "err", err)
+			return
+		}
+		if side == "buy" {
+			s.positions[symbol] = position{Qty: qty, EntryPrice: price, OpenedAt: now}
+		} else {
+			delete(s.positions, symbol)
+		}
+	}()
+}
+
+// SyncPositions reconciles with broker positions: positions we opened that no longer exist (closed
+// elsewhere) are forgotten, and entry price/qty follow the broker's average fill.
+func (s *Strategy) SyncPositions(positions []alpaca.Position) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	held := make(map[string]bool, len(positions))
+	for _, p := range positions {
+		held[p.Symbol] = true
+		pos, ok := s.positions[p.Symbol]
+		if !ok {
+			continue
+		}
+		if q, err := strconv.ParseFloat(p.Qty, 64); err == nil && q > 0 {
+			pos.Qty = q
+		}
+		if avg := p.AvgEntryPrice.Value(); avg > 0 {
+			pos.EntryPrice = avg
+		}
+		s.positions[p.Symbol] = pos
+	}
+	s.held = held
+	if s.cfg.DryRun {
+		return
+	}
+	for sym := range s.positions {
+		if !held[sym] && !s.pending[sym] {
+			delete(s.positions, sym)
+		}
+	}
+}
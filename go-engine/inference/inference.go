@@ -0,0 +1,93 @@
+// Package inference scores brain feature vectors in-process so a model_score (or a "signal" event) can be
+// attached before the Python hop. Models are selected by file extension:
+//
+//	.json  linear/logistic model (pure Go, always available)
+//	.onnx  ONNX Runtime model (requires building with -tags onnx and the onnxruntime shared library)
+package inference
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Model scores one feature vector laid out per brain.FeatureNames.
+type Model interface {
+	Score(features []float64) (float64, error)
+	Close() error
+}
+
+// Options configures Load.
+type Options struct {
+	Path          string // model file (.json or .onnx)
+	InputName     string // ONNX input tensor name; default "input"
+	OutputName    string // ONNX output tensor name; default "output"
+	SharedLibPath string // path to libonnxruntime (ONNX only); empty = platform default
+	NumFeatures   int    // expected vector length (len(brain.FeatureNames))
+}
+
+// Load opens the model at opts.Path.
+func Load(opts Options) (Model, error) {
+	if opts.Path == "" {
+		return nil, errors.New("inference: model path required")
+	}
+	if opts.InputName == "" {
+		opts.InputName = "input"
+	}
+	if opts.OutputName == "" {
+		opts.OutputName = "output"
+	}
+	switch strings.ToLower(filepath.Ext(opts.Path)) {
+	case ".json":
+		return loadLinear(opts.Path, opts.NumFeatures)
+	case ".onnx":
+		return loadONNX(opts)
+	}
+	return nil, fmt.Errorf("inference: unsupported model type %q (want .json or .onnx)", filepath.Ext(opts.Path))
+}
+
+// linearModel is {"weights":[...], "bias":b, "logistic":true}: score = w·x + b, passed through a sigmoid
+// when logistic is set. Handy for exported sklearn/XGBoost-linear models without a cgo dependency.
+type linearModel struct {
+	Weights  []float64 `json:"weights"`
+	Bias     float64   `json:"bias"`
+	Logistic bool      `json:"logistic"`
+}
+
+func loadLinear(path string, numFeatures int) (Model, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("inference: read %s: %w", path, err)
+	}
+	var m linearModel
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("inference: parse %s: %w", path, err)
+	}
+	if len(m.Weights) == 0 {
+		return nil, fmt.Errorf("inference: %s has no weights", path)
+	}
+	if numFeatures > 0 && len(m.Weights) != numFeatures {
+		return nil, fmt.Errorf("inference: %s has %d weights, feature schema has %d", path, len(m.Weights), numFeatures)
+	}
+	return &m, nil
+}
+
+func (m *linearModel) Score(features []float64) (float64, error) {
+	if len(features) != len(m.Weights) {
+		return 0, fmt.Errorf("inference: got %d features, model expects %d", len(features), len(m.Weights))
+	}
+	z := m.Bias
+	for i, w := range m.Weights {
+		z += w * features[i]
+	}
+	if m.Logistic {
+		z = 1 / (1 + math.Exp(-z))
+	}
+	return z, nil
+}
+
+func (m *linearModel) Close() error { return nil }
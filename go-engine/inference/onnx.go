@@ -0,0 +1,76 @@
+//go:build onnx
+
+package inference
+
+import (
+	"fmt"
+	"sync"
+
+	ort "github.com/yalue/onnxruntime_go"
+)
+
+// onnxModel runs a [1, N] float32 input through an ONNX Runtime session and reads output[0].
+// Sessions reuse their bound tensors, so Score is serialized with a mutex.
+type onnxModel struct {
+	mu      sync.Mutex
+	session *ort.AdvancedSession
+	input   *ort.Tensor[float32]
+	output  *ort.Tensor[float32]
+}
+
+func loadONNX(opts Options) (Model, error) {
+	if opts.NumFeatures <= 0 {
+		return nil, fmt.Errorf("inference: ONNX model needs the feature count")
+	}
+	if opts.SharedLibPath != "" {
+		ort.SetSharedLibraryPath(opts.SharedLibPath)
+	}
+	if !ort.IsInitialized() {
+		if err := ort.InitializeEnvironment(); err != nil {
+			return nil, fmt.Errorf("inference: onnxruntime init: %w", err)
+		}
+	}
+	input, err := ort.NewEmptyTensor[float32](ort.NewShape(1, int64(opts.NumFeatures)))
+	if err != nil {
+		return nil, err
+	}
+	output, err := ort.NewEmptyTensor[float32](ort.NewShape(1, 1))
+	if err != nil {
+		input.Destroy()
+		return nil, err
+	}
+	session, err := ort.NewAdvancedSession(opts.Path,
+		[]string{opts.InputName}, []string{opts.OutputName},
+		[]ort.Value{input}, []ort.Value{output}, nil)
+	if err != nil {
+		input.Destroy()
+		output.Destroy()
+		return nil, fmt.Errorf("inference: load %s: %w", opts.Path, err)
+	}
+	return &onnxModel{session: session, input: input, output: output}, nil
+}
+
+func (m *onnxModel) Score(features []float64) (float64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	in := m.input.GetData()
+	if len(features) != len(in) {
+		return 0, fmt.Errorf("inference: got %d features, model expects %d", len(features), len(in))
+	}
+	for i, f := range features {
+		in[i] = float32(f)
+	}
+	if err := m.session.Run(); err != nil {
+		return 0, err
+	}
+	return float64(m.output.GetData()[0]), nil
+}
+
+func (m *onnxModel) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.session.Destroy()
+	m.input.Destroy()
+	m.output.Destroy()
+	return nil
+}
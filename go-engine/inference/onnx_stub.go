@@ -0,0 +1,10 @@
+//go:build !onnx
+
+package inference
+
+import "errors"
+
+// loadONNX is unavailable in the default (CGO_ENABLED=0) build.
+func loadONNX(Options) (Model, error) {
+	return nil, errors.New("inference: built without ONNX support; rebuild with -tags onnx (needs cgo and the onnxruntime shared library)")
+}
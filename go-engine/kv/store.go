@@ -0,0 +1,200 @@
+// Package kv is a small engine-managed persistent key-value scratchpad (bbolt) that the brain reads and
+// writes over the pipe request channel. Keys live in namespaces (one bucket each) so strategy state such as
+// cooldowns and per-symbol flags survives brain crashes and restarts.
+package kv
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/sunnyp94/sentry-bridge/go-engine/brain"
+)
+
+// Limits keep a misbehaving brain from filling the disk.
+const (
+	maxKeyLen   = 256
+	maxValueLen = 64 * 1024
+)
+
+// Store wraps a bbolt database. Values are arbitrary JSON.
+type Store struct {
+	db *bolt.DB
+}
+
+// Open opens (or creates) the database at path.
+func Open(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 2 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("kv open %s: %w", path, err)
+	}
+	return &Store{db: db}, nil
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	if s == nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+func validate(ns, key string) error {
+	if ns == "" {
+		return errors.New("namespace required")
+	}
+	if key == "" {
+		return errors.New("key required")
+	}
+	if len(ns) > maxKeyLen || len(key) > maxKeyLen {
+		return fmt.Errorf("namespace/key longer than %d bytes", maxKeyLen)
+	}
+	return nil
+}
+
+// Get returns the value for ns/key, or nil if absent.
+func (s *Store) Get(ns, key string) (json.RawMessage, error) {
+	if err := validate(ns, key); err != nil {
+		return nil, err
+	}
+	var out json.RawMessage
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(ns))
+		if b == nil {
+			return nil
+		}
+		if v := b.Get([]byte(key)); v != nil {
+			out = append(json.RawMessage(nil), v...)
+		}
+		return nil
+	})
+	return out, err
+}
+
+// Put stores value (must be valid JSON) under ns/key.
+func (s *Store) Put(ns, key string, value json.RawMessage) error {
+	if err := validate(ns, key); err != nil {
+		return err
+	}
+	if len(value) == 0 || !json.Valid(value) {
+		return errors.New("value must be valid JSON")
+	}
+	if len(value) > maxValueLen {
+		return fmt.Errorf("value larger than %d bytes", maxValueLen)
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(ns))
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(key), value)
+	})
+}
+
+// Delete removes ns/key (no error if absent).
+func (s *Store) Delete(ns, key string) error {
+	if err := validate(ns, key); err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(ns))
+		if b == nil {
+			return nil
+		}
+		return b.Delete([]byte(key))
+	})
+}
+
+// List returns all key/value pairs in ns whose key starts with prefix.
+func (s *Store) List(ns, prefix string) (map[string]json.RawMessage, error) {
+	if ns == "" {
+		return nil, errors.New("namespace required")
+	}
+	out := make(map[string]json.RawMessage)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(ns))
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		for k, v := c.Seek([]byte(prefix)); k != nil && strings.HasPrefix(string(k), prefix); k, v = c.Next() {
+			out[string(k)] = append(json.RawMessage(nil), v...)
+		}
+		return nil
+	})
+	return out, err
+}
+
+// kvParams is the request shape for all kv.* methods.
+type kvParams struct {
+	Namespace string          `json:"ns"`
+	Key       string          `json:"key"`
+	Prefix    string          `json:"prefix"`
+	Value     json.RawMessage `json:"value"`
+}
+
+func parseParams(raw json.RawMessage) (kvParams, error) {
+	var p kvParams
+	if len(raw) == 0 {
+		return p, errors.New("params required")
+	}
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return p, fmt.Errorf("bad params: %w", err)
+	}
+	return p, nil
+}
+
+// RegisterHandlers exposes the store on the brain request channel:
+//
+//	kv.get    {"ns","key"}          -> {"value": <json or null>}
+//	kv.put    {"ns","key","value"}  -> {"ok": true}
+//	kv.delete {"ns","key"}          -> {"ok": true}
+//	kv.list   {"ns","prefix"}       -> {"items": {key: value}}
+func RegisterHandlers(p *brain.Pipe, s *Store) {
+	p.Handle("kv.get", func(raw json.RawMessage) (interface{}, error) {
+		kp, err := parseParams(raw)
+		if err != nil {
+			return nil, err
+		}
+		v, err := s.Get(kp.Namespace, kp.Key)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"ns": kp.Namespace, "key": kp.Key, "value": v}, nil
+	})
+	p.Handle("kv.put", func(raw json.RawMessage) (interface{}, error) {
+		kp, err := parseParams(raw)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.Put(kp.Namespace, kp.Key, kp.Value); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"ok": true}, nil
+	})
+	p.Handle("kv.delete", func(raw json.RawMessage) (interface{}, error) {
+		kp, err := parseParams(raw)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.Delete(kp.Namespace, kp.Key); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"ok": true}, nil
+	})
+	p.Handle("kv.list", func(raw json.RawMessage) (interface{}, error) {
+		kp, err := parseParams(raw)
+		if err != nil {
+			return nil, err
+		}
+		items, err := s.List(kp.Namespace, kp.Prefix)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"ns": kp.Namespace, "items": items}, nil
+	})
+}
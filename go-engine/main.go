@@ -1,23 +1,26 @@
 // Package main runs the Sentry Bridge engine: streams Alpaca market data (trades, quotes, news),
 // computes volatility, and pushes events to a Python brain (stdin pipe).
 // The Python brain decides buy/sell and places paper orders via Alpaca. Set STREAM=false for one-shot REST mode.
+// The streaming engine itself lives in package engine, so other Go services can embed it.
 package main
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"log/slog"
 	"os"
 	"os/signal"
-	"strconv"
 	"strings"
-	"sync"
+	"syscall"
 	"time"
 
 	"github.com/sunnyp94/sentry-bridge/go-engine/alpaca"
 	"github.com/sunnyp94/sentry-bridge/go-engine/brain"
+	"github.com/sunnyp94/sentry-bridge/go-engine/clock"
 	"github.com/sunnyp94/sentry-bridge/go-engine/config"
+	"github.com/sunnyp94/sentry-bridge/go-engine/engine"
 )
 
 // initLogger configures slog from LOG_LEVEL (DEBUG/INFO/WARN/ERROR) and LOG_FORMAT (json or text).
@@ -45,24 +48,6 @@ func initLogger() {
 	slog.SetDefault(slog.New(h))
 }
 
-// parseMarketCloseET parses "HH:MM" (e.g. "16:00") and returns (hour, minute). Returns (-1, -1) if invalid.
-func parseMarketCloseET(s string) (hour, minute int) {
-	s = strings.TrimSpace(s)
-	if s == "" {
-		return -1, -1
-	}
-	parts := strings.Split(s, ":")
-	if len(parts) != 2 {
-		return -1, -1
-	}
-	h, err1 := strconv.Atoi(strings.TrimSpace(parts[0]))
-	m, err2 := strconv.Atoi(strings.TrimSpace(parts[1]))
-	if err1 != nil || err2 != nil || h < 0 || h > 23 || m < 0 || m > 59 {
-		return -1, -1
-	}
-	return h, m
-}
-
 func main() {
 	initLogger()
 	cfg, err := config.Load()
@@ -74,324 +59,111 @@ func main() {
 		slog.Error("missing credentials", "msg", "set APCA_API_KEY_ID and APCA_API_SECRET_KEY (e.g. in .env)")
 		os.Exit(1)
 	}
+	if cfg.DataProvider == "polygon" && cfg.PolygonAPIKey == "" {
+		slog.Error("missing credentials", "msg", "DATA_PROVIDER=polygon needs POLYGON_API_KEY")
+		os.Exit(1)
+	}
+	if cfg.Broker == "ibkr" && cfg.IBKRAccountID == "" {
+		slog.Error("missing credentials", "msg", "BROKER=ibkr needs IBKR_ACCOUNT_ID (and a logged-in Client Portal Gateway)")
+		os.Exit(1)
+	}
+	if cfg.WatchlistName != "" && !cfg.WatchlistPush {
+		loadWatchlist(cfg)
+	}
 	if len(cfg.Tickers) == 0 {
-		slog.Error("missing tickers", "msg", "set ACTIVE_SYMBOLS_FILE; scanner runs at container start and 7:00 ET on market days")
+		slog.Error("missing tickers", "msg", "set ACTIVE_SYMBOLS_FILE or WATCHLIST_NAME; scanner runs at container start and 7:00 ET on market days")
 		os.Exit(1)
 	}
 
+	if cfg.StreamingMode && cfg.AutoSchedule {
+		runScheduled(cfg)
+		return
+	}
 	if cfg.StreamingMode {
-		runStreaming(cfg)
+		// SIGTERM too: that is what docker stop and Kubernetes send before the kill
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		if err := engine.New(cfg).Run(ctx); err != nil && !errors.Is(err, engine.ErrKilled) {
+			slog.Error("engine stopped", "err", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if cfg.BackfillStart != "" {
+		runBackfill(cfg)
 		return
 	}
 	runOneShot(cfg)
 }
 
-// runStreaming: WebSocket price + news, volatility refresh every 5 min; pipe events directly to Python brain.
-func runStreaming(cfg *config.Config) {
-	slog.Info("streaming mode", "data_url", cfg.DataBaseURL, "stream_url", cfg.StreamWSURL, "tickers", cfg.Tickers)
-
-	client := alpaca.NewClient(cfg.DataBaseURL, cfg.APIKeyID, cfg.APISecretKey)
+// runScheduled is AUTO_SCHEDULE: sleep while the market is closed, stream from shortly before pre-market
+// until just after post-market on trading days (startup does the volatility and snapshot warm-up), then
+// start over. Starting over is an exec of the engine itself: the shutdown flushes sinks and stops the
+// brains, and the new process drops every stream connection and starts clean for the next session.
+func runScheduled(cfg *config.Config) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 	tradingClient := alpaca.NewTradingClient(cfg.TradingBaseURL, cfg.APIKeyID, cfg.APISecretKey)
-
-	// Brain closest to data: pipe events to Python subprocess via stdin (no Redis in hot path)
-	var brainPipe *brain.Pipe
-	if cfg.BrainCmd != "" {
-		if p, err := brain.StartPipe(cfg.BrainCmd); err != nil {
-			slog.Error("brain pipe start failed", "cmd", cfg.BrainCmd, "err", err)
-		} else if p != nil {
-			brainPipe = p
-			defer brainPipe.Close()
-			slog.Info("brain pipe started", "cmd", cfg.BrainCmd)
-		}
+	lead, grace := time.Duration(cfg.AutoScheduleLeadMin)*time.Minute, time.Duration(cfg.AutoScheduleGraceMin)*time.Minute
+	end, ok := engine.AwaitSession(ctx, clock.Real{}, tradingClient, lead, grace)
+	if !ok {
+		slog.Info("stopping")
+		return
 	}
-
-	// Brain state: price/volume history for returns and volume_1m/5m
-	state := brain.NewState()
-
-	// Shared volatility (updated every 5 min)
-	var volMu sync.RWMutex
-	volatility := make(map[string]float64)
-
-	// Initial volatility and push to brain
-	updateVolatility := func() {
-		barsResp, err := client.GetBars(cfg.Tickers, "1Day", 30)
-		if err != nil {
-			slog.Error("volatility bars error", "err", err)
-			return
-		}
-		volMu.Lock()
-		for _, sym := range cfg.Tickers {
-			bars, ok := barsResp.Bars[sym]
-			if !ok || len(bars) < 2 {
-				continue
-			}
-			volatility[sym] = alpaca.AnnualizedVolatility(bars)
-		}
-		volMu.Unlock()
-		state.SetVolatilityMap(volatility)
-		// Push volatility snapshot to brain (one event per symbol)
-		for _, sym := range cfg.Tickers {
-			volMu.RLock()
-			v := volatility[sym]
-			volMu.RUnlock()
-			if v > 0 {
-				payload := map[string]interface{}{"symbol": sym, "annualized_vol_30d": v}
-				if brainPipe != nil {
-					t0 := time.Now()
-					_ = brainPipe.Send("volatility", payload)
-					slog.Debug("latency", "step", "brain_send", "type", "volatility", "ms", time.Since(t0).Milliseconds())
-				}
-			}
-		}
-		volMu.RLock()
-		for _, sym := range cfg.Tickers {
-			if v := volatility[sym]; v > 0 {
-				slog.Info("volatility", "symbol", sym, "annualized_30d_pct", v*100)
-			}
-		}
-		volMu.RUnlock()
+	slog.Info("auto schedule: session starting", "until", end.In(brain.Eastern()).Format("2006-01-02 15:04 MST"))
+	sessionCtx, cancel := context.WithDeadline(ctx, end)
+	err := engine.New(cfg).Run(sessionCtx)
+	cancel()
+	if errors.Is(err, engine.ErrKilled) {
+		return // no next session until someone restarts the engine
 	}
-	updateVolatility()
-
-	// Price stream (trades + quotes) — update state and send to brain
-	priceStream := alpaca.NewPriceStream(cfg.StreamWSURL, cfg.APIKeyID, cfg.APISecretKey, cfg.DataFeed, cfg.Tickers)
-	lastPrint := make(map[string]time.Time)
-	var printMu sync.Mutex
-	priceStream.OnTrade = func(symbol string, price float64, size int, t time.Time) {
-		state.RecordTrade(symbol, price, size, t)
-		volMu.RLock()
-		vol := volatility[symbol]
-		volMu.RUnlock()
-		payload := map[string]interface{}{
-			"symbol":     symbol,
-			"price":      price,
-			"size":       size,
-			"volume_1m":  state.Volume1m(symbol),
-			"volume_5m":  state.Volume5m(symbol),
-			"return_1m":  state.Return1m(symbol, price),
-			"return_5m":  state.Return5m(symbol, price),
-			"session":    brain.Session(time.Now()),
-			"volatility": vol,
-		}
-		if brainPipe != nil {
-			t0 := time.Now()
-			_ = brainPipe.Send("trade", payload)
-			slog.Debug("latency", "step", "brain_send", "type", "trade", "ms", time.Since(t0).Milliseconds())
-		}
-		printMu.Lock()
-		now := time.Now()
-		if now.Sub(lastPrint[symbol]) >= time.Second {
-			lastPrint[symbol] = now
-			slog.Debug("price", "symbol", symbol, "price", price, "size", size, "at", t.Format("15:04:05"))
-		}
-		printMu.Unlock()
+	if err != nil {
+		slog.Error("engine stopped", "err", err)
+		os.Exit(1)
 	}
-	priceStream.OnQuote = func(symbol string, bid, ask float64, bidSize, askSize int, t time.Time) {
-		mid := (bid + ask) / 2
-		volMu.RLock()
-		vol := volatility[symbol]
-		volMu.RUnlock()
-		payload := map[string]interface{}{
-			"symbol":     symbol,
-			"bid":        bid,
-			"ask":        ask,
-			"bid_size":   bidSize,
-			"ask_size":   askSize,
-			"mid":        mid,
-			"volume_1m":  state.Volume1m(symbol),
-			"volume_5m":  state.Volume5m(symbol),
-			"return_1m":  state.Return1m(symbol, mid),
-			"return_5m":  state.Return5m(symbol, mid),
-			"session":    brain.Session(time.Now()),
-			"volatility": vol,
-		}
-		if brainPipe != nil {
-			t0 := time.Now()
-			_ = brainPipe.Send("quote", payload)
-			slog.Debug("latency", "step", "brain_send", "type", "quote", "ms", time.Since(t0).Milliseconds())
-		}
-		printMu.Lock()
-		now := time.Now()
-		if now.Sub(lastPrint[symbol]) >= time.Second {
-			lastPrint[symbol] = now
-			slog.Debug("quote", "symbol", symbol, "bid", bid, "ask", ask, "mid", mid, "at", t.Format("15:04:05"))
-		}
-		printMu.Unlock()
+	if ctx.Err() != nil {
+		return // interrupted, not the end of the session
 	}
-
-	// News stream — send full article to brain
-	newsStream := alpaca.NewNewsStream(cfg.StreamWSURL, cfg.APIKeyID, cfg.APISecretKey, cfg.Tickers)
-	newsStream.OnNews = func(a alpaca.NewsArticle) {
-		payloadBytes, _ := json.Marshal(map[string]interface{}{
-			"id":         a.ID,
-			"headline":   a.Headline,
-			"author":     a.Author,
-			"created_at": a.CreatedAt,
-			"updated_at": a.UpdatedAt,
-			"summary":    a.Summary,
-			"url":        a.URL,
-			"symbols":    a.Symbols,
-			"source":     a.Source,
-		})
-		var payload map[string]interface{}
-		_ = json.Unmarshal(payloadBytes, &payload)
-		if brainPipe != nil {
-			t0 := time.Now()
-			_ = brainPipe.Send("news", payload)
-			slog.Debug("latency", "step", "brain_send", "type", "news", "ms", time.Since(t0).Milliseconds())
-		}
-		slog.Info("news", "symbols", strings.Join(a.Symbols, ","), "headline", a.Headline, "created_at", a.CreatedAt, "source", a.Source)
+	exe, err := os.Executable()
+	if err != nil {
+		slog.Error("auto schedule: cannot restart for the next session", "err", err)
+		os.Exit(1)
 	}
-
-	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
-	defer stop()
-
-	// Exit at market close ET (default 4pm) so entrypoint can sleep until 7am then run discovery 7–9:30.
-	if closeHour, closeMin := parseMarketCloseET(cfg.MarketCloseET); closeHour >= 0 {
-		go func() {
-			loc, err := time.LoadLocation("America/New_York")
-			if err != nil {
-				slog.Warn("market close check disabled", "err", err)
-				return
-			}
-			ticker := time.NewTicker(60 * time.Second)
-			defer ticker.Stop()
-			for {
-				select {
-				case <-ctx.Done():
-					return
-				case <-ticker.C:
-					now := time.Now().In(loc)
-					if now.Weekday() == time.Saturday || now.Weekday() == time.Sunday {
-						continue
-					}
-					if now.Hour() > closeHour || (now.Hour() == closeHour && now.Minute() >= closeMin) {
-						slog.Info("market close; exiting so entrypoint can sleep until 7am then discovery", "at_et", fmt.Sprintf("%02d:%02d", closeHour, closeMin))
-						stop()
-						os.Exit(0)
-					}
-				}
-			}
-		}()
+	slog.Info("auto schedule: session over; restarting to wait for the next one")
+	if err := syscall.Exec(exe, os.Args, os.Environ()); err != nil {
+		slog.Error("auto schedule: cannot restart for the next session", "err", err)
+		os.Exit(1)
 	}
+}
 
-	// Volatility refresh every 5 min
-	go func() {
-		ticker := time.NewTicker(5 * time.Minute)
-		defer ticker.Stop()
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case <-ticker.C:
-				updateVolatility()
-			}
-		}
-	}()
-
-	// Positions and open orders for the brain (interval from config, default 30s)
-	slog.Info("positions/orders interval", "sec", cfg.PositionsIntervalSec)
-	go func() {
-		interval := time.Duration(cfg.PositionsIntervalSec) * time.Second
-		ticker := time.NewTicker(interval)
-		defer ticker.Stop()
-		pushPositionsAndOrders := func() {
-			t0 := time.Now()
-			positions, err := tradingClient.GetPositions()
-			if err != nil {
-				slog.Error("trading positions error", "err", err)
-				return
-			}
-			slog.Debug("latency", "step", "alpaca_get_positions", "ms", time.Since(t0).Milliseconds())
-			posPayload := make([]map[string]interface{}, 0, len(positions))
-			for _, p := range positions {
-				posPayload = append(posPayload, map[string]interface{}{
-					"symbol": p.Symbol, "qty": p.Qty, "side": p.Side,
-					"market_value": p.MarketValue, "cost_basis": p.CostBasis,
-					"unrealized_pl": p.UnrealizedPL, "unrealized_plpc": p.UnrealizedPLPC, "current_price": float64(p.CurrentPrice),
-				})
-			}
-			if brainPipe != nil {
-				t0 = time.Now()
-				_ = brainPipe.Send("positions", map[string]interface{}{"positions": posPayload})
-				slog.Debug("latency", "step", "brain_send", "type", "positions", "ms", time.Since(t0).Milliseconds())
-			}
-			t0 = time.Now()
-			orders, err := tradingClient.GetOpenOrders()
-			if err != nil {
-				slog.Error("trading orders error", "err", err)
-				return
-			}
-			slog.Debug("latency", "step", "alpaca_get_orders", "ms", time.Since(t0).Milliseconds())
-			ordPayload := make([]map[string]interface{}, 0, len(orders))
-			for _, o := range orders {
-				ordPayload = append(ordPayload, map[string]interface{}{
-					"id": o.ID, "symbol": o.Symbol, "side": o.Side, "qty": o.Qty,
-					"filled_qty": o.FilledQty, "type": o.Type, "status": o.Status,
-					"created_at": o.CreatedAt,
-				})
-			}
-			if brainPipe != nil {
-				t0 = time.Now()
-				_ = brainPipe.Send("orders", map[string]interface{}{"orders": ordPayload})
-				slog.Debug("latency", "step", "brain_send", "type", "orders", "ms", time.Since(t0).Milliseconds())
-			}
-		}
-		pushPositionsAndOrders()
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case <-ticker.C:
-				pushPositionsAndOrders()
-			}
-		}
-	}()
-
-	// Run price stream in background (reconnect on error for resilience)
-	go func() {
-		for {
-			if err := priceStream.Run(); err != nil {
-				slog.Error("price stream ended", "err", err)
-			}
-			select {
-			case <-ctx.Done():
-				return
-			default:
-				slog.Info("reconnecting price stream in 5s")
-				time.Sleep(5 * time.Second)
-			}
-		}
-	}()
-
-	// Run news stream in background
-	go func() {
-		for {
-			if err := newsStream.Run(); err != nil {
-				slog.Error("news stream ended", "err", err)
-			}
-			select {
-			case <-ctx.Done():
-				return
-			default:
-				slog.Info("reconnecting news stream in 5s")
-				time.Sleep(5 * time.Second)
-			}
-		}
-	}()
-
-	<-ctx.Done()
-	slog.Info("stopping")
+// loadWatchlist replaces cfg.Tickers with the symbols of the Alpaca watchlist WATCHLIST_NAME. When the
+// watchlist can't be read, is missing or is empty, the ACTIVE_SYMBOLS_FILE symbols are kept.
+func loadWatchlist(cfg *config.Config) {
+	tradingClient := alpaca.NewTradingClient(cfg.TradingBaseURL, cfg.APIKeyID, cfg.APISecretKey)
+	tradingClient.SetRetryPolicy(engine.RetryPolicy(cfg))
+	w, err := tradingClient.GetWatchlist(cfg.WatchlistName)
+	switch {
+	case err != nil:
+		slog.Warn("watchlist unavailable; using ACTIVE_SYMBOLS_FILE", "name", cfg.WatchlistName, "err", err)
+	case w == nil || len(w.Assets) == 0:
+		slog.Warn("watchlist missing or empty; using ACTIVE_SYMBOLS_FILE", "name", cfg.WatchlistName)
+	default:
+		cfg.Tickers = w.Symbols()
+		slog.Info("tickers from watchlist", "name", cfg.WatchlistName, "symbols", len(cfg.Tickers))
+	}
 }
 
 // runOneShot: single REST fetch and print (original behavior).
 func runOneShot(cfg *config.Config) {
-	slog.Info("one-shot REST", "data_url", cfg.DataBaseURL, "tickers", cfg.Tickers)
-	client := alpaca.NewClient(cfg.DataBaseURL, cfg.APIKeyID, cfg.APISecretKey)
+	slog.Info("one-shot REST", "provider", cfg.DataProvider, "data_url", cfg.DataBaseURL, "tickers", cfg.Tickers)
+	dataClient := alpaca.NewClient(cfg.DataBaseURL, cfg.APIKeyID, cfg.APISecretKey)
+	dataClient.SetRetryPolicy(engine.RetryPolicy(cfg))
+	dataClient.SetRateLimiter(alpaca.NewRateLimiter(cfg.RESTRateLimitPerMin))
+	client := engine.NewDataProvider(cfg, dataClient)
 
 	news, errNews := client.GetNews(cfg.Tickers, 50)
 	snapshots, errSnap := client.GetSnapshots(cfg.Tickers)
-	barsResp, errBars := client.GetBars(cfg.Tickers, "1Day", 30)
+	allBars, errBars := engine.BarsWindow(client, cfg.Tickers, "1Day", cfg.VolWindow, time.Now())
 
 	if errNews != nil {
 		slog.Error("news fetch error", "err", errNews)
@@ -444,14 +216,83 @@ func runOneShot(cfg *config.Config) {
 			slog.Info("price", "symbol", sym, "msg", "no data (US market closed weekends 9:30am–4pm ET)")
 		}
 
-		bars, ok := barsResp.Bars[sym]
-		if ok && len(bars) > 0 {
-			vol := alpaca.AnnualizedVolatility(bars)
-			slog.Info("volatility", "symbol", sym, "annualized_30d_pct", vol*100)
+		bars := allBars[sym]
+		if vol, err := alpaca.Volatility(bars, engine.VolOptions(cfg, cfg.VolMethod)); err == nil {
+			slog.Info("volatility", "symbol", sym, "annualized_30d_pct", vol*100, "method", cfg.VolMethod)
 		} else {
-			slog.Debug("volatility", "symbol", sym, "msg", "no bar data")
+			slog.Info("volatility", "symbol", sym, "bars", len(bars), "msg", "insufficient data", "err", err)
 		}
 	}
 
 	slog.Info("one-shot done")
 }
+
+// runBackfill is STREAM=false with BACKFILL_START: historical bars for the tickers, written as NDJSON
+// ({"symbol","t","o","h","l","c","v","vw"} per line, per symbol oldest first) for seeding indicators or
+// a replay.
+func runBackfill(cfg *config.Config) {
+	start, err := parseBackfillTime(cfg.BackfillStart)
+	if err != nil {
+		slog.Error("BACKFILL_START", "err", err)
+		os.Exit(1)
+	}
+	var end time.Time
+	if cfg.BackfillEnd != "" {
+		if end, err = parseBackfillTime(cfg.BackfillEnd); err != nil {
+			slog.Error("BACKFILL_END", "err", err)
+			os.Exit(1)
+		}
+	}
+	dataClient := alpaca.NewClient(cfg.DataBaseURL, cfg.APIKeyID, cfg.APISecretKey)
+	dataClient.SetRetryPolicy(engine.RetryPolicy(cfg))
+	dataClient.SetRateLimiter(alpaca.NewRateLimiter(cfg.RESTRateLimitPerMin))
+	provider := engine.NewDataProvider(cfg, dataClient)
+
+	f, err := os.Create(cfg.BackfillOut)
+	if err != nil {
+		slog.Error("backfill output", "err", err)
+		os.Exit(1)
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	slog.Info("backfill", "provider", provider.Name(), "tickers", cfg.Tickers, "start", start, "end", end,
+		"timeframe", cfg.BackfillTimeframe, "adjustment", cfg.BackfillAdjustment, "out", cfg.BackfillOut)
+	total := 0
+	req := alpaca.BarsRequest{Timeframe: cfg.BackfillTimeframe, Start: start, End: end, Adjustment: cfg.BackfillAdjustment}
+	if cfg.DataProvider == "alpaca" {
+		req.Feed = cfg.DataFeed
+	}
+	err = alpaca.Backfill(provider, cfg.Tickers, req, 0, func(symbol string, bars []alpaca.Bar) error {
+		for _, b := range bars {
+			row := struct {
+				Symbol string `json:"symbol"`
+				alpaca.Bar
+			}{symbol, b}
+			if err := enc.Encode(row); err != nil {
+				return err
+			}
+		}
+		total += len(bars)
+		slog.Debug("backfill chunk", "symbol", symbol, "bars", len(bars), "from", bars[0].Time)
+		return nil
+	})
+	if ferr := w.Flush(); err == nil {
+		err = ferr
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		slog.Error("backfill failed", "err", err, "bars_written", total)
+		os.Exit(1)
+	}
+	slog.Info("backfill done", "bars", total, "out", cfg.BackfillOut)
+}
+
+// parseBackfillTime accepts a date (midnight ET) or an RFC3339 time.
+func parseBackfillTime(s string) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", s, brain.Eastern()); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
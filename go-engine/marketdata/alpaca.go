@@ -0,0 +1,26 @@
+package marketdata
+
+import "github.com/sunnyp94/sentry-bridge/go-engine/alpaca"
+
+// Alpaca is the default provider: Alpaca's data REST API and its stock and news WebSockets.
+type Alpaca struct {
+	*alpaca.Client
+	streamURL string
+	keyID     string
+	secretKey string
+}
+
+// NewAlpaca wraps client, streaming from streamURL (e.g. wss://stream.data.alpaca.markets).
+func NewAlpaca(client *alpaca.Client, streamURL, keyID, secretKey string) *Alpaca {
+	return &Alpaca{Client: client, streamURL: streamURL, keyID: keyID, secretKey: secretKey}
+}
+
+func (a *Alpaca) Name() string { return "alpaca" }
+
+func (a *Alpaca) PriceStream(feed string, symbols []string) PriceStream {
+	return alpaca.NewPriceStream(a.streamURL, a.keyID, a.secretKey, feed, symbols)
+}
+
+func (a *Alpaca) NewsStream(symbols []string) NewsStream {
+	return alpaca.NewNewsStream(a.streamURL, a.keyID, a.secretKey, symbols)
+}
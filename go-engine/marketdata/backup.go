@@ -0,0 +1,156 @@
+package marketdata
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/sunnyp94/sentry-bridge/go-engine/alpaca"
+)
+
+// BackupSource is a secondary source of bars and snapshots, used when the provider's REST calls fail
+// (an outage, rate limiting), so volatility inputs and the one-shot fetch still get data.
+type BackupSource interface {
+	Name() string
+	GetBars(symbols []string, timeframe string, limit int) (*alpaca.BarsResponse, error)
+	GetBarsSince(symbols []string, timeframe string, start time.Time) (*alpaca.BarsResponse, error)
+	GetBarsRange(symbols []string, req alpaca.BarsRequest) (*alpaca.BarsResponse, error)
+	GetSnapshots(symbols []string) (map[string]alpaca.SnapshotData, error)
+}
+
+// withBackup is a provider whose bars and snapshots calls fall back to a backup source on error.
+type withBackup struct {
+	DataProvider
+	backup BackupSource
+	used   atomic.Int64
+}
+
+// WithBackup returns primary with GetBars, GetBarsSince, GetBarsRange and GetSnapshots falling back to
+// backup when they fail. Other calls (streams, news, quotes) go to primary only.
+func WithBackup(primary DataProvider, backup BackupSource) DataProvider {
+	return &withBackup{DataProvider: primary, backup: backup}
+}
+
+func (w *withBackup) GetBars(symbols []string, timeframe string, limit int) (*alpaca.BarsResponse, error) {
+	resp, err := w.DataProvider.GetBars(symbols, timeframe, limit)
+	if err == nil {
+		return resp, nil
+	}
+	n := w.used.Add(1)
+	slog.Warn("bars from backup source", "provider", w.DataProvider.Name(), "backup", w.backup.Name(), "err", err, "backup_calls", n)
+	resp, berr := w.backup.GetBars(symbols, timeframe, limit)
+	if berr != nil {
+		return nil, fmt.Errorf("%w (backup %s: %v)", err, w.backup.Name(), berr)
+	}
+	return resp, nil
+}
+
+func (w *withBackup) GetBarsSince(symbols []string, timeframe string, start time.Time) (*alpaca.BarsResponse, error) {
+	resp, err := w.DataProvider.GetBarsSince(symbols, timeframe, start)
+	if err == nil {
+		return resp, nil
+	}
+	n := w.used.Add(1)
+	slog.Warn("bars from backup source", "provider", w.DataProvider.Name(), "backup", w.backup.Name(), "err", err, "backup_calls", n)
+	resp, berr := w.backup.GetBarsSince(symbols, timeframe, start)
+	if berr != nil {
+		return nil, fmt.Errorf("%w (backup %s: %v)", err, w.backup.Name(), berr)
+	}
+	return resp, nil
+}
+
+func (w *withBackup) GetBarsRange(symbols []string, req alpaca.BarsRequest) (*alpaca.BarsResponse, error) {
+	resp, err := w.DataProvider.GetBarsRange(symbols, req)
+	if err == nil {
+		return resp, nil
+	}
+	n := w.used.Add(1)
+	slog.Warn("bars from backup source", "provider", w.DataProvider.Name(), "backup", w.backup.Name(), "err", err, "backup_calls", n)
+	resp, berr := w.backup.GetBarsRange(symbols, req)
+	if berr != nil {
+		return nil, fmt.Errorf("%w (backup %s: %v)", err, w.backup.Name(), berr)
+	}
+	return resp, nil
+}
+
+func (w *withBackup) GetSnapshots(symbols []string) (map[string]alpaca.SnapshotData, error) {
+	snaps, err := w.DataProvider.GetSnapshots(symbols)
+	if err == nil {
+		return snaps, nil
+	}
+	n := w.used.Add(1)
+	slog.Warn("snapshots from backup source", "provider", w.DataProvider.Name(), "backup", w.backup.Name(), "err", err, "backup_calls", n)
+	snaps, berr := w.backup.GetSnapshots(symbols)
+	if berr != nil {
+		return nil, fmt.Errorf("%w (backup %s: %v)", err, w.backup.Name(), berr)
+	}
+	return snaps, nil
+}
+
+// backupLookback returns a lookback wide enough for limit bars of an Alpaca timeframe (weekends and
+// holidays for daily bars, the 6.5-hour session for intraday ones).
+func backupLookback(timeframe string, limit int) (time.Duration, error) {
+	var step time.Duration
+	switch timeframe {
+	case "", "1Day":
+		step = 24 * time.Hour
+	case "1Min":
+		step = time.Minute
+	case "5Min":
+		step = 5 * time.Minute
+	case "15Min":
+		step = 15 * time.Minute
+	case "30Min":
+		step = 30 * time.Minute
+	case "1Hour":
+		step = time.Hour
+	default:
+		return 0, fmt.Errorf("timeframe %q not supported by the backup source", timeframe)
+	}
+	if limit <= 0 {
+		limit = 30
+	}
+	if step == 24*time.Hour {
+		return time.Duration(float64(limit)*1.5+7) * 24 * time.Hour, nil
+	}
+	days := math.Ceil(float64(limit)*step.Hours()/6.5)*1.5 + 3
+	return time.Duration(days) * 24 * time.Hour, nil
+}
+
+// lastBars keeps the newest limit bars.
+func lastBars(bars []alpaca.Bar, limit int) []alpaca.Bar {
+	if limit > 0 && len(bars) > limit {
+		return bars[len(bars)-limit:]
+	}
+	return bars
+}
+
+// dailySnapshot builds a snapshot from daily bars (oldest first) and the latest price, for sources
+// without quotes.
+func dailySnapshot(daily []alpaca.Bar, price float64, at time.Time) alpaca.SnapshotData {
+	var d alpaca.SnapshotData
+	if price > 0 {
+		d.LatestTrade = &alpaca.Trade{Price: price, Time: at.UTC().Format(time.RFC3339)}
+	}
+	if n := len(daily); n > 0 {
+		day := daily[n-1]
+		d.DailyBar = &day
+		if n > 1 {
+			prev := daily[n-2]
+			d.PrevDailyBar = &prev
+		}
+	}
+	return d
+}
+
+// upper returns symbols upper-cased.
+func upper(symbols []string) []string {
+	out := make([]string, len(symbols))
+	for i, s := range symbols {
+		out[i] = strings.ToUpper(s)
+	}
+	return out
+}
@@ -0,0 +1,191 @@
+package marketdata
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sunnyp94/sentry-bridge/go-engine/alpaca"
+)
+
+// Finnhub is a backup source on Finnhub's REST API (FINNHUB_API_KEY). Quotes are on the free plan;
+// candles (bars) need a plan that includes them, and fail otherwise.
+type Finnhub struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+}
+
+// NewFinnhub builds the Finnhub backup source; baseURL defaults to https://finnhub.io/api/v1.
+func NewFinnhub(baseURL, apiKey string) *Finnhub {
+	if baseURL == "" {
+		baseURL = "https://finnhub.io/api/v1"
+	}
+	return &Finnhub{baseURL: strings.TrimRight(baseURL, "/"), apiKey: apiKey, httpClient: &http.Client{Timeout: 15 * time.Second}}
+}
+
+func (f *Finnhub) Name() string { return "finnhub" }
+
+func (f *Finnhub) get(path string, params url.Values, v interface{}) error {
+	params.Set("token", f.apiKey)
+	resp, err := f.httpClient.Get(f.baseURL + path + "?" + params.Encode())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("finnhub %s: %s (status %d)", path, string(body), resp.StatusCode)
+	}
+	return json.Unmarshal(body, v)
+}
+
+// finnhubCandles is the response of /stock/candle: parallel arrays, s "ok" or "no_data".
+type finnhubCandles struct {
+	S string    `json:"s"`
+	O []float64 `json:"o"`
+	H []float64 `json:"h"`
+	L []float64 `json:"l"`
+	C []float64 `json:"c"`
+	V []float64 `json:"v"`
+	T []int64   `json:"t"`
+}
+
+// finnhubResolutions maps Alpaca timeframes to Finnhub candle resolutions.
+var finnhubResolutions = map[string]string{"": "D", "1Day": "D", "1Min": "1", "5Min": "5", "15Min": "15", "30Min": "30", "1Hour": "60"}
+
+func (f *Finnhub) candles(symbol, resolution string, from, to time.Time) ([]alpaca.Bar, error) {
+	params := url.Values{}
+	params.Set("symbol", symbol)
+	params.Set("resolution", resolution)
+	params.Set("from", strconv.FormatInt(from.Unix(), 10))
+	params.Set("to", strconv.FormatInt(to.Unix(), 10))
+	var c finnhubCandles
+	if err := f.get("/stock/candle", params, &c); err != nil {
+		return nil, err
+	}
+	if c.S != "ok" {
+		return nil, nil
+	}
+	bars := make([]alpaca.Bar, 0, len(c.T))
+	for i, ts := range c.T {
+		if i >= len(c.C) || i >= len(c.O) || i >= len(c.H) || i >= len(c.L) {
+			break
+		}
+		b := alpaca.Bar{Open: c.O[i], High: c.H[i], Low: c.L[i], Close: c.C[i], Time: time.Unix(ts, 0).UTC().Format(time.RFC3339)}
+		if i < len(c.V) {
+			b.Volume = uint64(c.V[i])
+		}
+		bars = append(bars, b)
+	}
+	return bars, nil
+}
+
+// GetBars fetches the newest limit bars per symbol, one request each. Symbols that fail are left out;
+// the call fails only when every symbol does.
+func (f *Finnhub) GetBars(symbols []string, timeframe string, limit int) (*alpaca.BarsResponse, error) {
+	resolution, ok := finnhubResolutions[timeframe]
+	if !ok {
+		return nil, fmt.Errorf("timeframe %q not supported by finnhub", timeframe)
+	}
+	lookback, err := backupLookback(timeframe, limit)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	out := &alpaca.BarsResponse{Bars: make(map[string][]alpaca.Bar, len(symbols))}
+	var lastErr error
+	for _, sym := range upper(symbols) {
+		bars, err := f.candles(sym, resolution, now.Add(-lookback), now)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		out.Bars[sym] = lastBars(bars, limit)
+	}
+	if len(out.Bars) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return out, nil
+}
+
+// GetBarsSince fetches bars from start until now per symbol, oldest first.
+func (f *Finnhub) GetBarsSince(symbols []string, timeframe string, start time.Time) (*alpaca.BarsResponse, error) {
+	return f.GetBarsRange(symbols, alpaca.BarsRequest{Timeframe: timeframe, Start: start})
+}
+
+// GetBarsRange fetches bars between req.Start and req.End (zero = now) per symbol, one request each,
+// oldest first; req.Adjustment and req.Feed are ignored. Symbols that fail are left out; the call fails
+// only when every symbol does.
+func (f *Finnhub) GetBarsRange(symbols []string, req alpaca.BarsRequest) (*alpaca.BarsResponse, error) {
+	resolution, ok := finnhubResolutions[req.Timeframe]
+	if !ok {
+		return nil, fmt.Errorf("timeframe %q not supported by finnhub", req.Timeframe)
+	}
+	end := req.End
+	if end.IsZero() {
+		end = time.Now()
+	}
+	out := &alpaca.BarsResponse{Bars: make(map[string][]alpaca.Bar, len(symbols))}
+	var lastErr error
+	for _, sym := range upper(symbols) {
+		bars, err := f.candles(sym, resolution, req.Start, end)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if req.Limit > 0 && len(bars) > req.Limit {
+			bars = bars[:req.Limit]
+		}
+		out.Bars[sym] = bars
+	}
+	if len(out.Bars) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return out, nil
+}
+
+// finnhubQuote is the response of /quote: current, day high/low/open, previous close, Unix time.
+type finnhubQuote struct {
+	C  float64 `json:"c"`
+	H  float64 `json:"h"`
+	L  float64 `json:"l"`
+	O  float64 `json:"o"`
+	PC float64 `json:"pc"`
+	T  int64   `json:"t"`
+}
+
+// GetSnapshots returns the last price and today's bar from /quote. The previous day's bar has only its
+// close; volume isn't reported.
+func (f *Finnhub) GetSnapshots(symbols []string) (map[string]alpaca.SnapshotData, error) {
+	out := make(map[string]alpaca.SnapshotData, len(symbols))
+	var lastErr error
+	for _, sym := range upper(symbols) {
+		params := url.Values{}
+		params.Set("symbol", sym)
+		var q finnhubQuote
+		if err := f.get("/quote", params, &q); err != nil {
+			lastErr = err
+			continue
+		}
+		if q.C <= 0 {
+			continue // unknown symbol
+		}
+		at := time.Unix(q.T, 0)
+		day := alpaca.Bar{Open: q.O, High: q.H, Low: q.L, Close: q.C, Time: etMidnight(at).UTC().Format(time.RFC3339)}
+		var daily []alpaca.Bar
+		if q.PC > 0 {
+			daily = append(daily, alpaca.Bar{Open: q.PC, High: q.PC, Low: q.PC, Close: q.PC})
+		}
+		out[sym] = dailySnapshot(append(daily, day), q.C, at)
+	}
+	if len(out) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return out, nil
+}
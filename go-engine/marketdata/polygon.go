@@ -0,0 +1,399 @@
+package marketdata
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sunnyp94/sentry-bridge/go-engine/alpaca"
+)
+
+// Polygon is the Polygon.io provider: REST aggregates, snapshots and reference news, the stocks
+// WebSocket for trades and quotes, and news polled over REST (Polygon has no news stream). Polygon has no
+// trading status or trade correction messages on the stocks stream, so halt and correction events are
+// Alpaca-only.
+type Polygon struct {
+	baseURL    string // e.g. https://api.polygon.io
+	wsURL      string // e.g. wss://socket.polygon.io (real-time) or wss://delayed.polygon.io
+	apiKey     string
+	newsPoll   time.Duration
+	httpClient *http.Client
+}
+
+// NewPolygon builds a Polygon provider; news is polled every newsPoll (default 30s).
+func NewPolygon(baseURL, wsURL, apiKey string, newsPoll time.Duration) *Polygon {
+	if newsPoll <= 0 {
+		newsPoll = 30 * time.Second
+	}
+	return &Polygon{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		wsURL:      strings.TrimRight(wsURL, "/"),
+		apiKey:     apiKey,
+		newsPoll:   newsPoll,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (p *Polygon) Name() string { return "polygon" }
+
+// get fetches path (or an absolute next_url) with the API key added.
+func (p *Polygon) get(path string, params url.Values) ([]byte, error) {
+	u := path
+	if !strings.HasPrefix(u, "http") {
+		u = p.baseURL + path
+	}
+	if params == nil {
+		params = url.Values{}
+	}
+	params.Set("apiKey", p.apiKey)
+	sep := "?"
+	if strings.Contains(u, "?") {
+		sep = "&"
+	}
+	resp, err := p.httpClient.Get(u + sep + params.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		path, _, _ = strings.Cut(strings.TrimPrefix(u, p.baseURL), "?")
+		return nil, fmt.Errorf("polygon API GET %s: %s (status %d)", path, string(body), resp.StatusCode)
+	}
+	return body, nil
+}
+
+// polygonAgg is one aggregate bar; t is the bar start in Unix milliseconds.
+type polygonAgg struct {
+	O  float64 `json:"o"`
+	H  float64 `json:"h"`
+	L  float64 `json:"l"`
+	C  float64 `json:"c"`
+	V  float64 `json:"v"`
+	VW float64 `json:"vw"`
+	T  int64   `json:"t"`
+}
+
+func (a polygonAgg) bar() alpaca.Bar {
+	return alpaca.Bar{Open: a.O, High: a.H, Low: a.L, Close: a.C, Volume: uint64(a.V), VWAP: a.VW,
+		Time: time.UnixMilli(a.T).UTC().Format(time.RFC3339)}
+}
+
+type polygonAggs struct {
+	Results []polygonAgg `json:"results"`
+	NextURL string       `json:"next_url"`
+}
+
+// polygonTimeframe turns an Alpaca timeframe (1Min, 15Min, 1Hour, 1Day) into Polygon's multiplier and
+// timespan.
+func polygonTimeframe(tf string) (int, string, time.Duration, error) {
+	d, err := alpaca.TimeframeDuration(tf)
+	if err != nil {
+		return 0, "", 0, err
+	}
+	switch {
+	case d%(24*time.Hour) == 0:
+		return int(d / (24 * time.Hour)), "day", d, nil
+	case d%time.Hour == 0:
+		return int(d / time.Hour), "hour", d, nil
+	default:
+		return int(d / time.Minute), "minute", d, nil
+	}
+}
+
+// aggs fetches one symbol's bars between from and to, following next_url.
+func (p *Polygon) aggs(symbol, timeframe string, from, to time.Time, params url.Values) ([]alpaca.Bar, error) {
+	mult, span, _, err := polygonTimeframe(timeframe)
+	if err != nil {
+		return nil, err
+	}
+	path := fmt.Sprintf("/v2/aggs/ticker/%s/range/%d/%s/%d/%d", url.PathEscape(symbol), mult, span, from.UnixMilli(), to.UnixMilli())
+	if params.Get("adjusted") == "" {
+		params.Set("adjusted", "true")
+	}
+	var out []alpaca.Bar
+	for path != "" {
+		body, err := p.get(path, params)
+		if err != nil {
+			return nil, err
+		}
+		var page polygonAggs
+		if err := json.Unmarshal(body, &page); err != nil {
+			return nil, err
+		}
+		for _, a := range page.Results {
+			out = append(out, a.bar())
+		}
+		path, params = page.NextURL, nil
+	}
+	return out, nil
+}
+
+// GetBars fetches the latest limit bars per symbol (one request each).
+func (p *Polygon) GetBars(symbols []string, timeframe string, limit int) (*alpaca.BarsResponse, error) {
+	if len(symbols) == 0 {
+		return nil, nil
+	}
+	if timeframe == "" {
+		timeframe = "1Day"
+	}
+	if limit <= 0 || limit > 10000 {
+		limit = 30
+	}
+	_, _, d, err := polygonTimeframe(timeframe)
+	if err != nil {
+		return nil, err
+	}
+	// Wide enough for nights, weekends and holidays; the newest limit bars are kept
+	now := time.Now()
+	from := now.Add(-3*time.Duration(limit)*d - 7*24*time.Hour)
+	out := &alpaca.BarsResponse{Bars: make(map[string][]alpaca.Bar)}
+	for _, sym := range symbols {
+		params := url.Values{}
+		params.Set("sort", "desc")
+		params.Set("limit", strconv.Itoa(limit))
+		bars, err := p.aggs(sym, timeframe, from, now, params)
+		if err != nil {
+			return nil, err
+		}
+		if len(bars) > limit {
+			bars = bars[:limit]
+		}
+		for i, j := 0, len(bars)-1; i < j; i, j = i+1, j-1 {
+			bars[i], bars[j] = bars[j], bars[i]
+		}
+		out.Bars[sym] = bars
+	}
+	return out, nil
+}
+
+// GetBarsSince fetches bars from start until now per symbol, oldest first.
+func (p *Polygon) GetBarsSince(symbols []string, timeframe string, start time.Time) (*alpaca.BarsResponse, error) {
+	return p.GetBarsRange(symbols, alpaca.BarsRequest{Timeframe: timeframe, Start: start})
+}
+
+// GetBarsRange fetches bars between req.Start and req.End (zero = now) per symbol, oldest first.
+// Polygon adjusts for splits unless req.Adjustment is "raw", and never for dividends; req.Feed is
+// ignored.
+func (p *Polygon) GetBarsRange(symbols []string, req alpaca.BarsRequest) (*alpaca.BarsResponse, error) {
+	if len(symbols) == 0 {
+		return nil, nil
+	}
+	if req.Timeframe == "" {
+		req.Timeframe = "1Day"
+	}
+	end := req.End
+	if end.IsZero() {
+		end = time.Now()
+	}
+	out := &alpaca.BarsResponse{Bars: make(map[string][]alpaca.Bar)}
+	for _, sym := range symbols {
+		params := url.Values{}
+		params.Set("sort", "asc")
+		params.Set("limit", "50000")
+		params.Set("adjusted", strconv.FormatBool(req.Adjustment != "raw"))
+		bars, err := p.aggs(sym, req.Timeframe, req.Start, end, params)
+		if err != nil {
+			return nil, err
+		}
+		if req.Limit > 0 && len(bars) > req.Limit {
+			bars = bars[:req.Limit]
+		}
+		out.Bars[sym] = bars
+	}
+	return out, nil
+}
+
+// polygonSnapshot is one ticker of /v2/snapshot/locale/us/markets/stocks/tickers; times are Unix ns.
+type polygonSnapshot struct {
+	Ticker    string     `json:"ticker"`
+	Day       polygonAgg `json:"day"`
+	PrevDay   polygonAgg `json:"prevDay"`
+	LastTrade struct {
+		P float64 `json:"p"`
+		S float64 `json:"s"`
+		T int64   `json:"t"`
+		X int     `json:"x"`
+		C []int   `json:"c"`
+	} `json:"lastTrade"`
+	LastQuote struct {
+		Bid     float64 `json:"p"`
+		BidSize float64 `json:"s"`
+		Ask     float64 `json:"P"`
+		AskSize float64 `json:"S"`
+		T       int64   `json:"t"`
+	} `json:"lastQuote"`
+	Updated int64 `json:"updated"`
+}
+
+// GetSnapshots returns the latest trade, quote and daily bars per symbol.
+func (p *Polygon) GetSnapshots(symbols []string) (map[string]alpaca.SnapshotData, error) {
+	if len(symbols) == 0 {
+		return nil, nil
+	}
+	params := url.Values{}
+	params.Set("tickers", strings.Join(symbols, ","))
+	body, err := p.get("/v2/snapshot/locale/us/markets/stocks/tickers", params)
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Tickers []polygonSnapshot `json:"tickers"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, err
+	}
+	out := make(map[string]alpaca.SnapshotData, len(resp.Tickers))
+	for _, s := range resp.Tickers {
+		var d alpaca.SnapshotData
+		if lt := s.LastTrade; lt.P > 0 {
+			d.LatestTrade = &alpaca.Trade{Price: lt.P, Size: uint64(lt.S), Time: time.Unix(0, lt.T).UTC().Format(time.RFC3339Nano),
+				Exchange: polygonExchange(lt.X)}
+		}
+		if lq := s.LastQuote; lq.Bid > 0 || lq.Ask > 0 {
+			d.LatestQuote = &alpaca.Quote{BidPrice: lq.Bid, AskPrice: lq.Ask, BidSize: uint64(lq.BidSize), AskSize: uint64(lq.AskSize),
+				Timestamp: time.Unix(0, lq.T).UTC().Format(time.RFC3339Nano)}
+		}
+		// Snapshot day bars carry no time: the day is the one of the last update (ET midnight, as Alpaca)
+		if s.Day.C > 0 && s.Updated > 0 {
+			day := s.Day.bar()
+			day.Time = etMidnight(time.Unix(0, s.Updated)).UTC().Format(time.RFC3339)
+			d.DailyBar = &day
+		}
+		if s.PrevDay.C > 0 {
+			prev := s.PrevDay.bar()
+			d.PrevDailyBar = &prev
+		}
+		out[s.Ticker] = d
+	}
+	return out, nil
+}
+
+func etMidnight(t time.Time) time.Time {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		loc = time.FixedZone("EST", -5*3600)
+	}
+	y, m, d := t.In(loc).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, loc)
+}
+
+// polygonArticle is one result of /v2/reference/news.
+type polygonArticle struct {
+	ID        string `json:"id"`
+	Publisher struct {
+		Name string `json:"name"`
+	} `json:"publisher"`
+	Title        string   `json:"title"`
+	Author       string   `json:"author"`
+	PublishedUTC string   `json:"published_utc"`
+	ArticleURL   string   `json:"article_url"`
+	Tickers      []string `json:"tickers"`
+	Description  string   `json:"description"`
+}
+
+func (a polygonArticle) article() alpaca.NewsArticle {
+	// Polygon IDs are strings; the engine keys articles by a positive int64
+	h := fnv.New64a()
+	h.Write([]byte(a.ID))
+	return alpaca.NewsArticle{
+		ID:        int64(h.Sum64() >> 1),
+		Headline:  a.Title,
+		Author:    a.Author,
+		CreatedAt: a.PublishedUTC,
+		UpdatedAt: a.PublishedUTC,
+		Summary:   a.Description,
+		URL:       a.ArticleURL,
+		Symbols:   a.Tickers,
+		Source:    a.Publisher.Name,
+	}
+}
+
+// news pages through /v2/reference/news newest first, published at or after start, keeping articles that
+// mention one of symbols (any, when empty) until max are kept (0 = all).
+func (p *Polygon) news(symbols []string, start time.Time, max int) ([]alpaca.NewsArticle, error) {
+	want := make(map[string]bool, len(symbols))
+	for _, s := range symbols {
+		want[strings.ToUpper(s)] = true
+	}
+	params := url.Values{}
+	params.Set("published_utc.gte", start.UTC().Format(time.RFC3339))
+	params.Set("order", "desc")
+	params.Set("sort", "published_utc")
+	params.Set("limit", "1000")
+	if len(symbols) == 1 {
+		params.Set("ticker", strings.ToUpper(symbols[0]))
+	}
+	var out []alpaca.NewsArticle
+	for path := "/v2/reference/news"; path != ""; {
+		body, err := p.get(path, params)
+		if err != nil {
+			return out, err
+		}
+		var page struct {
+			Results []polygonArticle `json:"results"`
+			NextURL string           `json:"next_url"`
+		}
+		if err := json.Unmarshal(body, &page); err != nil {
+			return out, err
+		}
+		for _, a := range page.Results {
+			if len(want) > 0 && !mentions(a.Tickers, want) {
+				continue
+			}
+			out = append(out, a.article())
+			if max > 0 && len(out) >= max {
+				return out, nil
+			}
+		}
+		path, params = page.NextURL, nil
+	}
+	return out, nil
+}
+
+func mentions(tickers []string, want map[string]bool) bool {
+	for _, t := range tickers {
+		if want[strings.ToUpper(t)] {
+			return true
+		}
+	}
+	return false
+}
+
+// GetNews returns the latest limit articles for symbols from the last day, newest first.
+func (p *Polygon) GetNews(symbols []string, limit int) (*alpaca.NewsResponse, error) {
+	if limit <= 0 || limit > 50 {
+		limit = 10
+	}
+	articles, err := p.news(symbols, time.Now().Add(-24*time.Hour), limit)
+	if err != nil {
+		return nil, err
+	}
+	return &alpaca.NewsResponse{News: articles}, nil
+}
+
+// GetNewsSince returns articles for symbols published since start, oldest first; with max > 0 only the
+// newest max.
+func (p *Polygon) GetNewsSince(symbols []string, start time.Time, max int) ([]alpaca.NewsArticle, error) {
+	out, err := p.news(symbols, start, max)
+	sort.SliceStable(out, func(i, j int) bool { return out[i].CreatedAt < out[j].CreatedAt })
+	return out, err
+}
+
+func (p *Polygon) PriceStream(_ string, symbols []string) PriceStream {
+	return newPolygonStream(p.wsURL, p.apiKey, symbols)
+}
+
+func (p *Polygon) NewsStream(symbols []string) NewsStream {
+	return &polygonNews{provider: p, symbols: symbols, closed: make(chan struct{})}
+}
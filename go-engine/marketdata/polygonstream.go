@@ -0,0 +1,385 @@
+package marketdata
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/sunnyp94/sentry-bridge/go-engine/alpaca"
+	"github.com/sunnyp94/sentry-bridge/go-engine/telemetry"
+)
+
+// polygonStream is the Polygon stocks WebSocket (T.* trades and Q.* quotes), converted to the alpaca
+// stream callbacks: exchange IDs become Alpaca's exchange letters, tapes 1-3 become A-C, and condition
+// IDs become SIP codes so alpaca.DecodeConditions applies.
+type polygonStream struct {
+	url    string
+	apiKey string
+
+	// Requested symbols, those with both channels acknowledged on the live connection, and the
+	// connection itself. mu also serializes writes to conn.
+	mu      sync.Mutex
+	symbols []string
+	acked   map[string]int // symbol -> channels acknowledged (T, Q)
+	conn    *websocket.Conn
+
+	closer alpaca.StreamCloser
+
+	alpaca.StreamHandlers
+}
+
+func newPolygonStream(wsURL, apiKey string, symbols []string) *polygonStream {
+	return &polygonStream{url: wsURL + "/stocks", apiKey: apiKey, symbols: symbols}
+}
+
+func (s *polygonStream) Handlers() *alpaca.StreamHandlers { return &s.StreamHandlers }
+
+func (s *polygonStream) Close() error { return s.closer.Close() }
+
+// polygonMessage is any message on the stocks stream; fields are per event type (ev).
+type polygonMessage struct {
+	Ev      string  `json:"ev"`
+	Status  string  `json:"status"`
+	Message string  `json:"message"`
+	Sym     string  `json:"sym"`
+	X       int     `json:"x"`
+	ID      string  `json:"i"`
+	Z       int     `json:"z"`
+	P       float64 `json:"p"`
+	S       float64 `json:"s"`
+	C       []int   `json:"c"`
+	T       int64   `json:"t"` // Unix ms
+	BP      float64 `json:"bp"`
+	BS      float64 `json:"bs"`
+	AP      float64 `json:"ap"`
+	AS      float64 `json:"as"`
+}
+
+// Run connects, authenticates, subscribes to trades and quotes and processes messages until the
+// connection fails or Close is called.
+func (s *polygonStream) Run() error {
+	conn, resp, err := websocket.DefaultDialer.Dial(s.url, nil)
+	if err != nil {
+		if resp != nil {
+			return fmt.Errorf("dial %s: %w (status %d)", s.url, err, resp.StatusCode)
+		}
+		return fmt.Errorf("dial %s: %w", s.url, err)
+	}
+	if !s.closer.Track(conn) {
+		return alpaca.ErrStreamClosed
+	}
+	defer s.closer.Untrack()
+	defer conn.Close()
+
+	if err := conn.WriteJSON(map[string]string{"action": "auth", "params": s.apiKey}); err != nil {
+		return fmt.Errorf("auth write: %w", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	for authed := false; !authed; {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return fmt.Errorf("awaiting auth: %w", err)
+		}
+		var msgs []polygonMessage
+		if err := json.Unmarshal(data, &msgs); err != nil {
+			return fmt.Errorf("unexpected control: %s", string(data))
+		}
+		for _, m := range msgs {
+			switch m.Status {
+			case "auth_success":
+				authed = true
+			case "auth_failed", "error":
+				return fmt.Errorf("polygon stream error: %s", m.Message)
+			}
+		}
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	s.mu.Lock()
+	symbols := append([]string(nil), s.symbols...)
+	s.acked = make(map[string]int)
+	if err := conn.WriteJSON(polygonSubscription("subscribe", symbols)); err != nil {
+		s.mu.Unlock()
+		return fmt.Errorf("subscribe write: %w", err)
+	}
+	s.conn = conn
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.conn = nil
+		s.mu.Unlock()
+	}()
+
+	slog.Info("price stream connected", "provider", "polygon", "url", s.url, "symbols", symbols)
+	if s.OnConnect != nil {
+		s.OnConnect()
+	}
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return fmt.Errorf("read: %w", err)
+		}
+		received := time.Now()
+		err = s.handleMessage(data, received)
+		telemetry.Message("polygon.stocks", received, err)
+		if err != nil {
+			slog.Error("stream handle message", "provider", "polygon", "err", err)
+		}
+	}
+}
+
+// polygonSubscription is a subscribe or unsubscribe message for the trade and quote channels.
+func polygonSubscription(action string, symbols []string) map[string]string {
+	params := make([]string, 0, 2*len(symbols))
+	for _, sym := range symbols {
+		sym = strings.ToUpper(sym)
+		params = append(params, "T."+sym, "Q."+sym)
+	}
+	return map[string]string{"action": action, "params": strings.Join(params, ",")}
+}
+
+func (s *polygonStream) handleMessage(data []byte, received time.Time) error {
+	var msgs []polygonMessage
+	if err := json.Unmarshal(data, &msgs); err != nil {
+		return err
+	}
+	for _, m := range msgs {
+		switch m.Ev {
+		case "status":
+			// "subscribed to: T.AAPL"
+			if ch, ok := strings.CutPrefix(m.Message, "subscribed to: "); ok && m.Status == "success" {
+				if _, sym, ok := strings.Cut(ch, "."); ok {
+					s.mu.Lock()
+					s.acked[sym]++
+					s.mu.Unlock()
+				}
+			} else if m.Status == "error" {
+				slog.Error("polygon stream status", "message", m.Message)
+			}
+		case "T":
+			if s.OnTrade == nil {
+				continue
+			}
+			tr := alpaca.StreamTrade{Symbol: m.Sym, Price: m.P, Size: int(m.S), Time: time.UnixMilli(m.T), Received: received,
+				Exchange: polygonExchange(m.X), Tape: polygonTape(m.Z)}
+			tr.ID, _ = strconv.ParseInt(m.ID, 10, 64)
+			tr.Conditions = polygonConditions(tr.Tape, m.C)
+			s.OnTrade(tr)
+		case "Q":
+			if s.OnQuote != nil {
+				s.OnQuote(m.Sym, m.BP, m.AP, int(m.BS), int(m.AS), time.UnixMilli(m.T), received)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *polygonStream) Symbols() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.symbols...)
+}
+
+// Confirmed returns the symbols whose trade and quote subscriptions were both acknowledged.
+func (s *polygonStream) Confirmed() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []string
+	for _, sym := range s.symbols {
+		if s.acked[strings.ToUpper(sym)] >= 2 {
+			out = append(out, sym)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func (s *polygonStream) Subscribe(symbols []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	have := make(map[string]bool, len(s.symbols))
+	for _, sym := range s.symbols {
+		have[strings.ToUpper(sym)] = true
+	}
+	var add []string
+	for _, sym := range symbols {
+		if sym = strings.ToUpper(sym); !have[sym] {
+			have[sym] = true
+			add = append(add, sym)
+		}
+	}
+	if len(add) == 0 {
+		return nil
+	}
+	s.symbols = append(s.symbols, add...)
+	if s.conn == nil {
+		return nil
+	}
+	if err := s.conn.WriteJSON(polygonSubscription("subscribe", add)); err != nil {
+		return fmt.Errorf("subscribe write: %w", err)
+	}
+	return nil
+}
+
+func (s *polygonStream) Unsubscribe(symbols []string) error {
+	if len(symbols) == 0 {
+		return nil
+	}
+	drop := make(map[string]bool, len(symbols))
+	for _, sym := range symbols {
+		drop[strings.ToUpper(sym)] = true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var kept []string
+	for _, sym := range s.symbols {
+		if !drop[strings.ToUpper(sym)] {
+			kept = append(kept, sym)
+		}
+	}
+	s.symbols = kept
+	for sym := range drop {
+		delete(s.acked, sym)
+	}
+	if s.conn == nil {
+		return nil
+	}
+	if err := s.conn.WriteJSON(polygonSubscription("unsubscribe", symbols)); err != nil {
+		return fmt.Errorf("unsubscribe write: %w", err)
+	}
+	return nil
+}
+
+// polygonExchanges maps Polygon exchange IDs to the letters Alpaca uses.
+var polygonExchanges = map[int]string{
+	1: "A", 2: "B", 3: "C", 4: "D", 6: "I", 7: "J", 8: "K", 9: "M", 10: "N", 11: "P",
+	12: "Q", 14: "L", 15: "V", 16: "W", 17: "X", 18: "Y", 19: "Z", 20: "H", 21: "U",
+}
+
+func polygonExchange(id int) string {
+	if x, ok := polygonExchanges[id]; ok {
+		return x
+	}
+	if id == 0 {
+		return ""
+	}
+	return strconv.Itoa(id)
+}
+
+func polygonTape(z int) string {
+	switch z {
+	case 1:
+		return "A"
+	case 2:
+		return "B"
+	case 3:
+		return "C"
+	}
+	return ""
+}
+
+// polygonConditionCodes maps Polygon's trade condition IDs to SIP codes, CTA then UTP where they differ.
+var polygonConditionCodes = map[int][2]string{
+	0: {"@", "@"}, 1: {"A", "A"}, 2: {"B", "W"}, 3: {"E", "E"}, 4: {"B", "B"}, 5: {"G", "G"}, 7: {"C", "C"},
+	8: {"6", "6"}, 9: {"X", "X"}, 10: {"4", "4"}, 11: {"D", "D"}, 12: {"T", "T"}, 13: {"U", "U"}, 14: {"F", "F"},
+	15: {"M", "M"}, 16: {"Q", "Q"}, 17: {"O", "O"}, 18: {"5", "5"}, 19: {"6", "6"}, 20: {"N", "N"},
+	21: {"H", "H"}, 22: {"P", "P"}, 23: {"K", "K"}, 24: {"K", "K"}, 25: {"O", "O"}, 28: {"5", "5"},
+	29: {"R", "R"}, 30: {"L", "L"}, 32: {"Z", "Z"}, 34: {"S", "S"}, 36: {"Y", "Y"}, 37: {"I", "I"},
+	38: {"9", "9"}, 52: {"V", "V"}, 53: {"7", "7"},
+}
+
+// polygonConditions converts condition IDs to SIP codes on tape; unknown IDs are kept as numbers.
+func polygonConditions(tape string, ids []int) []string {
+	var out []string
+	for _, id := range ids {
+		codes, ok := polygonConditionCodes[id]
+		switch {
+		case !ok:
+			out = append(out, strconv.Itoa(id))
+		case tape == "C":
+			out = append(out, codes[1])
+		default:
+			out = append(out, codes[0])
+		}
+	}
+	return out
+}
+
+// polygonNews polls Polygon's reference news (there is no news stream) and hands on each new article.
+type polygonNews struct {
+	provider *Polygon
+	symbols  []string
+	seen     map[int64]time.Time // article ID -> when first seen, for dedupe across overlapping polls
+	last     time.Time           // newest publish time seen
+
+	closeOnce sync.Once
+	closed    chan struct{} // closed by Close
+
+	alpaca.NewsHandlers
+}
+
+func (n *polygonNews) Handlers() *alpaca.NewsHandlers { return &n.NewsHandlers }
+
+func (n *polygonNews) Close() error {
+	n.closeOnce.Do(func() { close(n.closed) })
+	return nil
+}
+
+// Run polls every news interval until Close; three failed polls in a row end it so the caller reconnects.
+func (n *polygonNews) Run() error {
+	select {
+	case <-n.closed:
+		return alpaca.ErrStreamClosed
+	default:
+	}
+	if n.seen == nil {
+		n.seen = make(map[int64]time.Time)
+		n.last = time.Now()
+	}
+	slog.Info("news stream connected", "provider", "polygon", "poll", n.provider.newsPoll)
+	if n.OnConnect != nil {
+		n.OnConnect()
+	}
+	failures := 0
+	for {
+		// Overlap the last poll a little: Polygon can publish articles slightly out of order
+		articles, err := n.provider.GetNewsSince(n.symbols, n.last.Add(-time.Minute), 0)
+		if err != nil {
+			if failures++; failures >= 3 {
+				return fmt.Errorf("news poll: %w", err)
+			}
+			slog.Warn("news poll", "provider", "polygon", "err", err)
+		} else {
+			failures = 0
+		}
+		now := time.Now()
+		for _, a := range articles {
+			if _, dup := n.seen[a.ID]; dup {
+				continue
+			}
+			n.seen[a.ID] = now
+			if t, err := time.Parse(time.RFC3339, a.CreatedAt); err == nil && t.After(n.last) {
+				n.last = t
+			}
+			if n.OnNews != nil {
+				n.OnNews(a)
+			}
+		}
+		for id, t := range n.seen {
+			if now.Sub(t) > time.Hour {
+				delete(n.seen, id)
+			}
+		}
+		select {
+		case <-n.closed:
+			return alpaca.ErrStreamClosed
+		case <-time.After(n.provider.newsPoll):
+		}
+	}
+}
@@ -0,0 +1,44 @@
+// Package marketdata puts the market-data source (DATA_PROVIDER) behind one interface: REST for bars,
+// snapshots and news, and the price and news streams. Payloads are the alpaca types throughout, so a
+// provider other than Alpaca converts into them and the rest of the engine doesn't change.
+package marketdata
+
+import (
+	"time"
+
+	"github.com/sunnyp94/sentry-bridge/go-engine/alpaca"
+)
+
+// DataProvider is a market-data source.
+type DataProvider interface {
+	Name() string
+	GetBars(symbols []string, timeframe string, limit int) (*alpaca.BarsResponse, error)
+	GetBarsSince(symbols []string, timeframe string, start time.Time) (*alpaca.BarsResponse, error)
+	GetBarsRange(symbols []string, req alpaca.BarsRequest) (*alpaca.BarsResponse, error)
+	GetSnapshots(symbols []string) (map[string]alpaca.SnapshotData, error)
+	GetNews(symbols []string, limit int) (*alpaca.NewsResponse, error)
+	GetNewsSince(symbols []string, start time.Time, max int) ([]alpaca.NewsArticle, error)
+	// PriceStream streams trades and quotes for symbols; feed is provider-specific (Alpaca: sip or iex).
+	PriceStream(feed string, symbols []string) PriceStream
+	// NewsStream streams news for symbols (empty = all news).
+	NewsStream(symbols []string) NewsStream
+}
+
+// PriceStream is a live trade and quote stream. Run connects and processes messages until the
+// connection fails; the caller reconnects. Close ends Run from another goroutine, and for good.
+type PriceStream interface {
+	Run() error
+	Close() error
+	Handlers() *alpaca.StreamHandlers
+	Symbols() []string
+	Confirmed() []string
+	Subscribe(symbols []string) error
+	Unsubscribe(symbols []string) error
+}
+
+// NewsStream is a live news stream; Run and Close as for PriceStream.
+type NewsStream interface {
+	Run() error
+	Close() error
+	Handlers() *alpaca.NewsHandlers
+}
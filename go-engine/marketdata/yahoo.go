@@ -0,0 +1,198 @@
+package marketdata
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sunnyp94/sentry-bridge/go-engine/alpaca"
+)
+
+// Yahoo is a backup source on Yahoo Finance's public chart API (no key; unofficial, so best effort).
+// There are no quotes: snapshots carry the last price and the daily bars.
+type Yahoo struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewYahoo builds the Yahoo backup source; baseURL defaults to https://query1.finance.yahoo.com.
+func NewYahoo(baseURL string) *Yahoo {
+	if baseURL == "" {
+		baseURL = "https://query1.finance.yahoo.com"
+	}
+	return &Yahoo{baseURL: strings.TrimRight(baseURL, "/"), httpClient: &http.Client{Timeout: 15 * time.Second}}
+}
+
+func (y *Yahoo) Name() string { return "yahoo" }
+
+// yahooChart is the response of /v8/finance/chart/{symbol}. Missing bars come back as nulls.
+type yahooChart struct {
+	Chart struct {
+		Result []struct {
+			Meta struct {
+				RegularMarketPrice float64 `json:"regularMarketPrice"`
+				RegularMarketTime  int64   `json:"regularMarketTime"`
+			} `json:"meta"`
+			Timestamp  []int64 `json:"timestamp"`
+			Indicators struct {
+				Quote []struct {
+					Open   []*float64 `json:"open"`
+					High   []*float64 `json:"high"`
+					Low    []*float64 `json:"low"`
+					Close  []*float64 `json:"close"`
+					Volume []*float64 `json:"volume"`
+				} `json:"quote"`
+			} `json:"indicators"`
+		} `json:"result"`
+		Error *struct {
+			Code        string `json:"code"`
+			Description string `json:"description"`
+		} `json:"error"`
+	} `json:"chart"`
+}
+
+// yahooIntervals maps Alpaca timeframes to Yahoo chart intervals.
+var yahooIntervals = map[string]string{"": "1d", "1Day": "1d", "1Min": "1m", "5Min": "5m", "15Min": "15m", "30Min": "30m", "1Hour": "60m"}
+
+// chart returns symbol's bars (oldest first) between from and to, and its latest price and time.
+func (y *Yahoo) chart(symbol, interval string, from, to time.Time) ([]alpaca.Bar, float64, time.Time, error) {
+	params := url.Values{}
+	params.Set("interval", interval)
+	params.Set("period1", strconv.FormatInt(from.Unix(), 10))
+	params.Set("period2", strconv.FormatInt(to.Unix(), 10))
+	// Yahoo uses dashes for share classes (BRK-B)
+	path := "/v8/finance/chart/" + url.PathEscape(strings.ReplaceAll(symbol, ".", "-"))
+	req, err := http.NewRequest("GET", y.baseURL+path+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, 0, time.Time{}, err
+	}
+	// Requests without a browser-like user agent are rate limited at once
+	req.Header.Set("User-Agent", "Mozilla/5.0")
+	resp, err := y.httpClient.Do(req)
+	if err != nil {
+		return nil, 0, time.Time{}, err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, time.Time{}, fmt.Errorf("yahoo %s: %s (status %d)", path, string(body), resp.StatusCode)
+	}
+	var c yahooChart
+	if err := json.Unmarshal(body, &c); err != nil {
+		return nil, 0, time.Time{}, err
+	}
+	if c.Chart.Error != nil {
+		return nil, 0, time.Time{}, fmt.Errorf("yahoo %s: %s", symbol, c.Chart.Error.Description)
+	}
+	if len(c.Chart.Result) == 0 {
+		return nil, 0, time.Time{}, fmt.Errorf("yahoo %s: no data", symbol)
+	}
+	r := c.Chart.Result[0]
+	var bars []alpaca.Bar
+	if len(r.Indicators.Quote) > 0 {
+		q := r.Indicators.Quote[0]
+		for i, ts := range r.Timestamp {
+			if i >= len(q.Close) || q.Close[i] == nil || q.Open[i] == nil || q.High[i] == nil || q.Low[i] == nil {
+				continue
+			}
+			t := time.Unix(ts, 0)
+			if interval == "1d" {
+				t = etMidnight(t) // stamped at the open; Alpaca's daily bars are at ET midnight
+			}
+			b := alpaca.Bar{Open: *q.Open[i], High: *q.High[i], Low: *q.Low[i], Close: *q.Close[i], Time: t.UTC().Format(time.RFC3339)}
+			if i < len(q.Volume) && q.Volume[i] != nil {
+				b.Volume = uint64(*q.Volume[i])
+			}
+			bars = append(bars, b)
+		}
+	}
+	return bars, r.Meta.RegularMarketPrice, time.Unix(r.Meta.RegularMarketTime, 0), nil
+}
+
+// GetBars fetches the newest limit bars per symbol, one request each. Symbols that fail are left out;
+// the call fails only when every symbol does.
+func (y *Yahoo) GetBars(symbols []string, timeframe string, limit int) (*alpaca.BarsResponse, error) {
+	interval, ok := yahooIntervals[timeframe]
+	if !ok {
+		return nil, fmt.Errorf("timeframe %q not supported by yahoo", timeframe)
+	}
+	lookback, err := backupLookback(timeframe, limit)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	out := &alpaca.BarsResponse{Bars: make(map[string][]alpaca.Bar, len(symbols))}
+	var lastErr error
+	for _, sym := range upper(symbols) {
+		bars, _, _, err := y.chart(sym, interval, now.Add(-lookback), now)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		out.Bars[sym] = lastBars(bars, limit)
+	}
+	if len(out.Bars) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return out, nil
+}
+
+// GetBarsSince fetches bars from start until now per symbol, oldest first.
+func (y *Yahoo) GetBarsSince(symbols []string, timeframe string, start time.Time) (*alpaca.BarsResponse, error) {
+	return y.GetBarsRange(symbols, alpaca.BarsRequest{Timeframe: timeframe, Start: start})
+}
+
+// GetBarsRange fetches bars between req.Start and req.End (zero = now) per symbol, one request each,
+// oldest first. Yahoo's bars are split-adjusted; req.Adjustment and req.Feed are ignored. Symbols that
+// fail are left out; the call fails only when every symbol does.
+func (y *Yahoo) GetBarsRange(symbols []string, req alpaca.BarsRequest) (*alpaca.BarsResponse, error) {
+	interval, ok := yahooIntervals[req.Timeframe]
+	if !ok {
+		return nil, fmt.Errorf("timeframe %q not supported by yahoo", req.Timeframe)
+	}
+	end := req.End
+	if end.IsZero() {
+		end = time.Now()
+	}
+	out := &alpaca.BarsResponse{Bars: make(map[string][]alpaca.Bar, len(symbols))}
+	var lastErr error
+	for _, sym := range upper(symbols) {
+		bars, _, _, err := y.chart(sym, interval, req.Start, end)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if req.Limit > 0 && len(bars) > req.Limit {
+			bars = bars[:req.Limit]
+		}
+		out.Bars[sym] = bars
+	}
+	if len(out.Bars) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return out, nil
+}
+
+// GetSnapshots returns the last price and the latest two daily bars per symbol.
+func (y *Yahoo) GetSnapshots(symbols []string) (map[string]alpaca.SnapshotData, error) {
+	out := make(map[string]alpaca.SnapshotData, len(symbols))
+	var lastErr error
+	for _, sym := range upper(symbols) {
+		now := time.Now()
+		bars, price, at, err := y.chart(sym, "1d", now.Add(-7*24*time.Hour), now)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		out[sym] = dailySnapshot(bars, price, at)
+	}
+	if len(out) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return out, nil
+}
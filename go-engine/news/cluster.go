@@ -0,0 +1,141 @@
+package news
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/sunnyp94/sentry-bridge/go-engine/alpaca"
+)
+
+// stopwords are left out of headline vectors: they make unrelated headlines look alike.
+var stopwords = wordSet(`
+	a an and are as at be by for from has have in is it its of on or that the this to was were will with
+	after over says said amid new shares stock stocks inc corp co ltd
+`)
+
+// Cluster groups near-duplicate headlines: an article joins the cluster of the most similar earlier
+// article published within the window, if the cosine similarity of their headline words reaches the
+// threshold, so one story reported by several outlets carries one cluster ID and a growing size.
+type Cluster struct {
+	window    time.Duration
+	threshold float64
+
+	mu      sync.Mutex
+	recent  []clusterEntry
+	byID    map[int64]int64 // article ID -> cluster ID
+	sizes   map[int64]int   // cluster ID -> articles
+	newest  time.Time
+	pruneAt time.Time
+}
+
+type clusterEntry struct {
+	id      int64
+	cluster int64
+	at      time.Time
+	vec     map[string]float64
+	norm    float64
+}
+
+// NewCluster clusters articles within window of each other at a similarity of at least threshold (0
+// to 1); nil for window <= 0 (every article is its own cluster).
+func NewCluster(window time.Duration, threshold float64) *Cluster {
+	if window <= 0 {
+		return nil
+	}
+	return &Cluster{window: window, threshold: threshold, byID: make(map[int64]int64), sizes: make(map[int64]int)}
+}
+
+// Assign returns a's cluster ID (the ID of the cluster's first article) and how many articles the
+// cluster has, a included. An article seen before keeps its cluster. A nil Cluster puts each article in
+// its own cluster of one.
+func (c *Cluster) Assign(a alpaca.NewsArticle) (id int64, size int) {
+	if c == nil {
+		return a.ID, 1
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cid, ok := c.byID[a.ID]; ok {
+		return cid, c.sizes[cid]
+	}
+	at, err := time.Parse(time.RFC3339, a.CreatedAt)
+	if err != nil {
+		at = time.Now()
+	}
+	vec, norm := headlineVector(a.Headline)
+	cluster, best := a.ID, 0.0
+	if norm > 0 {
+		for _, e := range c.recent {
+			if e.norm == 0 || at.Sub(e.at) > c.window || e.at.Sub(at) > c.window {
+				continue
+			}
+			if sim := cosine(vec, norm, e.vec, e.norm); sim >= c.threshold && sim > best {
+				cluster, best = e.cluster, sim
+			}
+		}
+	}
+	c.recent = append(c.recent, clusterEntry{id: a.ID, cluster: cluster, at: at, vec: vec, norm: norm})
+	c.byID[a.ID] = cluster
+	c.sizes[cluster]++
+	if at.After(c.newest) {
+		c.newest = at
+	}
+	c.prune()
+	return cluster, c.sizes[cluster]
+}
+
+// prune forgets articles more than two windows older than the newest, at most once a window.
+func (c *Cluster) prune() {
+	if c.newest.Before(c.pruneAt) {
+		return
+	}
+	c.pruneAt = c.newest.Add(c.window)
+	cutoff := c.newest.Add(-2 * c.window)
+	kept := c.recent[:0]
+	live := make(map[int64]bool)
+	for _, e := range c.recent {
+		if e.at.Before(cutoff) {
+			delete(c.byID, e.id)
+			continue
+		}
+		kept = append(kept, e)
+		live[e.cluster] = true
+	}
+	c.recent = kept
+	for cid := range c.sizes {
+		if !live[cid] {
+			delete(c.sizes, cid)
+		}
+	}
+}
+
+// headlineVector counts a headline's words, lower-cased, stopwords and single letters left out, and
+// returns the counts with their Euclidean norm.
+func headlineVector(headline string) (map[string]float64, float64) {
+	vec := make(map[string]float64)
+	for _, w := range strings.FieldsFunc(strings.ToLower(headline), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len(w) > 1 && !stopwords[w] {
+			vec[w]++
+		}
+	}
+	var sq float64
+	for _, n := range vec {
+		sq += n * n
+	}
+	return vec, math.Sqrt(sq)
+}
+
+func cosine(a map[string]float64, an float64, b map[string]float64, bn float64) float64 {
+	if len(b) < len(a) {
+		a, b = b, a
+	}
+	var dot float64
+	for w, n := range a {
+		dot += n * b[w]
+	}
+	return dot / (an * bn)
+}
@@ -0,0 +1,78 @@
+// Package news processes articles between the news sources (stream, startup backfill) and the event
+// outputs.
+package news
+
+import (
+	"container/list"
+	"slices"
+	"sync"
+
+	"github.com/sunnyp94/sentry-bridge/go-engine/alpaca"
+)
+
+// Dedupe remembers the most recent articles by ID, so an article delivered twice (the stream re-sends
+// updated articles, the startup backfill overlaps the stream) goes out once, and a changed one goes out
+// as an update naming what changed. The oldest article is forgotten once size are remembered.
+type Dedupe struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List              // front = most recently seen
+	byID  map[int64]*list.Element // value: alpaca.NewsArticle as last seen
+}
+
+// NewDedupe remembers up to size articles; nil for size <= 0 (every article is new).
+func NewDedupe(size int) *Dedupe {
+	if size <= 0 {
+		return nil
+	}
+	return &Dedupe{size: size, order: list.New(), byID: make(map[int64]*list.Element)}
+}
+
+// See records a and reports whether its ID was seen before and, if so, which fields changed since
+// ("headline", "summary", "url", "symbols", "author", "source"). Seen with no changed fields is an exact
+// repeat. A nil Dedupe sees nothing twice.
+func (d *Dedupe) See(a alpaca.NewsArticle) (seen bool, changed []string) {
+	if d == nil {
+		return false, nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if el, ok := d.byID[a.ID]; ok {
+		prev := el.Value.(alpaca.NewsArticle)
+		el.Value = a
+		d.order.MoveToFront(el)
+		return true, articleChanges(prev, a)
+	}
+	d.byID[a.ID] = d.order.PushFront(a)
+	if d.order.Len() > d.size {
+		oldest := d.order.Back()
+		d.order.Remove(oldest)
+		delete(d.byID, oldest.Value.(alpaca.NewsArticle).ID)
+	}
+	return false, nil
+}
+
+// articleChanges lists the content fields that differ between two versions of an article. UpdatedAt
+// alone is not a change.
+func articleChanges(prev, cur alpaca.NewsArticle) []string {
+	var out []string
+	if prev.Headline != cur.Headline {
+		out = append(out, "headline")
+	}
+	if prev.Summary != cur.Summary {
+		out = append(out, "summary")
+	}
+	if prev.URL != cur.URL {
+		out = append(out, "url")
+	}
+	if !slices.Equal(prev.Symbols, cur.Symbols) {
+		out = append(out, "symbols")
+	}
+	if prev.Author != cur.Author {
+		out = append(out, "author")
+	}
+	if prev.Source != cur.Source {
+		out = append(out, "source")
+	}
+	return out
+}
@@ -0,0 +1,98 @@
+package news
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/sunnyp94/sentry-bridge/go-engine/events"
+)
+
+// maxEnrichmentBytes caps the response attached to one news event.
+const maxEnrichmentBytes = 64 << 10
+
+// Enricher posts news events to an external HTTP service (e.g. an LLM that extracts sentiment, entities
+// and expected impact) and returns its JSON object for the event's enrichment field. Requests are
+// limited to a number in flight; waiting for a slot counts against the timeout, so a slow service
+// delays news by at most the timeout and never queues it up.
+type Enricher struct {
+	url        string
+	timeout    time.Duration
+	slots      chan struct{}
+	httpClient *http.Client
+
+	ok     atomic.Uint64
+	failed atomic.Uint64
+}
+
+// NewEnricher posts to url with at most concurrency requests in flight (min 1), each given timeout
+// including the wait for a slot. nil when url is empty.
+func NewEnricher(url string, timeout time.Duration, concurrency int) *Enricher {
+	if url == "" {
+		return nil
+	}
+	return &Enricher{url: url, timeout: timeout, slots: make(chan struct{}, max(1, concurrency)), httpClient: &http.Client{}}
+}
+
+// Enrich posts ev as JSON and returns the service's response, which must be a JSON object. On any
+// failure (no slot in time, timeout, non-2xx status, not an object) it returns an error and the event
+// should go out without enrichment.
+func (e *Enricher) Enrich(ev events.NewsEvent) (json.RawMessage, error) {
+	out, err := e.enrich(ev)
+	if err != nil {
+		e.failed.Add(1)
+		return nil, err
+	}
+	e.ok.Add(1)
+	return out, nil
+}
+
+func (e *Enricher) enrich(ev events.NewsEvent) (json.RawMessage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), e.timeout)
+	defer cancel()
+	select {
+	case e.slots <- struct{}{}:
+		defer func() { <-e.slots }()
+	case <-ctx.Done():
+		return nil, fmt.Errorf("news enrichment: no free slot within %s", e.timeout)
+	}
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxEnrichmentBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("news enrichment: status %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+	if len(body) > maxEnrichmentBytes {
+		return nil, fmt.Errorf("news enrichment: response over %d bytes", maxEnrichmentBytes)
+	}
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(body, &obj); err != nil {
+		return nil, fmt.Errorf("news enrichment: response is not a JSON object: %w", err)
+	}
+	return json.RawMessage(body), nil
+}
+
+// Stats returns how many articles were enriched and how many went out without.
+func (e *Enricher) Stats() (ok, failed uint64) {
+	return e.ok.Load(), e.failed.Load()
+}
@@ -0,0 +1,66 @@
+package news
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Relevance weights: named in the headline, place in the article's symbol list, and how many symbols
+// share the article. They sum to 1, so a sole symbol named in the headline scores 1.
+const (
+	relevanceHeadline = 0.5
+	relevancePosition = 0.3
+	relevanceShare    = 0.2
+)
+
+// Relevance scores how much an article is about each of its symbols, 0 to 1, in the order of symbols.
+// Providers list the subject first and tag peers and index members after it, so the first symbol and
+// symbols named in the headline score highest, and an article tagging ten symbols is worth less to each.
+func Relevance(headline string, symbols []string) []float64 {
+	out := make([]float64, len(symbols))
+	if len(symbols) == 0 {
+		return out
+	}
+	tokens := headlineTokens(headline)
+	share := 1 / float64(len(symbols))
+	for i, sym := range symbols {
+		r := relevancePosition/float64(i+1) + relevanceShare*share
+		if mentions(headline, tokens, strings.ToUpper(sym)) {
+			r += relevanceHeadline
+		}
+		out[i] = r
+	}
+	return out
+}
+
+// headlineTokens splits a headline into words, keeping dots inside share-class tickers (BRK.B).
+func headlineTokens(headline string) map[string]bool {
+	out := make(map[string]bool)
+	for _, t := range strings.FieldsFunc(headline, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '.'
+	}) {
+		out[strings.Trim(t, ".")] = true
+	}
+	return out
+}
+
+// mentions reports whether the headline names sym. The ticker must appear in capitals as a word of its
+// own; a one-letter ticker only counts as $X or (X), since "A" or "F" alone is usually just a word.
+func mentions(headline string, tokens map[string]bool, sym string) bool {
+	if len(sym) > 1 {
+		return tokens[sym]
+	}
+	if strings.Contains(headline, "("+sym+")") {
+		return true
+	}
+	for rest := headline; ; {
+		i := strings.Index(rest, "$"+sym)
+		if i < 0 {
+			return false
+		}
+		rest = rest[i+2:]
+		if rest == "" || !unicode.IsLetter(rune(rest[0])) {
+			return true
+		}
+	}
+}
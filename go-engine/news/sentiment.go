@@ -0,0 +1,151 @@
+package news
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Sentiment is a lexicon score of an article: Score from -1 (negative) to 1 (positive) and Confidence
+// from 0 (no sentiment words) towards 1 (many words, all pointing the same way).
+type Sentiment struct {
+	Score      float64
+	Confidence float64
+}
+
+// headlineWeight is how much more a headline word counts than a summary word: the headline is what
+// moves the stock, summaries are often boilerplate.
+const headlineWeight = 2
+
+// negationWindow is how many words after "not", "no", ... a sentiment word is flipped.
+const negationWindow = 3
+
+// positiveWords and negativeWords are finance-tuned: "beat", "upgrade" and "raised" are good news here
+// even though a general-purpose lexicon scores them neutral; "liability" or "volatile" are bad news.
+var positiveWords = wordSet(`
+	beat beats beating exceeded exceeds exceed outperform outperforms outperformed topped tops surpass surpassed
+	upgrade upgrades upgraded raise raises raised boost boosts boosted lift lifts lifted hike hikes hiked
+	record high highs surge surges surged soar soars soared jump jumps jumped rally rallies rallied gain gains gained
+	climb climbs climbed rise rises rising rebound rebounds rebounded spike spikes spiked
+	profit profits profitable profitability growth grow grows growing strong stronger strongest robust solid
+	bullish buy overweight accumulate positive optimistic upbeat confident confidence momentum tailwind tailwinds
+	approval approved approves clearance cleared breakthrough launch launches launched wins win won award awarded
+	partnership partners acquire acquires acquisition deal contract contracts expands expansion expand
+	dividend buyback buybacks repurchase repurchases upside improve improves improved improvement recovery recovers
+	exceeding accelerates accelerating accelerated milestone success successful favorable resilient
+`)
+
+var negativeWords = wordSet(`
+	miss misses missed missing shortfall underperform underperforms underperformed disappoint disappoints
+	disappointing disappointed downgrade downgrades downgraded cut cuts cutting slash slashes slashed lower lowers
+	lowered reduce reduces reduced weak weaker weakest weakness soft slump slumps slumped plunge plunges plunged
+	tumble tumbles tumbled sink sinks sank fall falls fell drop drops dropped decline declines declined slide slides
+	slid crash crashes crashed selloff loss losses lose loses losing bearish sell underweight negative pessimistic
+	warning warns warned caution cautious headwind headwinds concern concerns worried worries fear fears risk risks
+	lawsuit lawsuits sue sues sued probe probes investigation investigated subpoena fraud scandal fine fined
+	penalty penalties settlement recall recalls recalled halt halted suspend suspends suspended delay delays delayed
+	reject rejects rejected denial denied fail fails failed failure bankruptcy bankrupt default defaults insolvency
+	layoff layoffs restructuring impairment writedown dilution dilutive offering delisting delisted downturn
+	volatile volatility liability liabilities deficit shortage breach hack resign resigns resigned ousted
+`)
+
+// negations flip the sentiment words that follow them.
+var negations = wordSet(`not no never without neither nor lack lacks`)
+
+// phrases are multi-word patterns scored as a whole before single words (value: +1 or -1).
+var phrases = map[string]float64{
+	"price target raised":  1,
+	"raises price target":  1,
+	"raised price target":  1,
+	"beats estimates":      1,
+	"above estimates":      1,
+	"better than expected": 1,
+	"raises guidance":      1,
+	"raised guidance":      1,
+	"price target cut":     -1,
+	"cuts price target":    -1,
+	"lowers price target":  -1,
+	"below estimates":      -1,
+	"misses estimates":     -1,
+	"worse than expected":  -1,
+	"cuts guidance":        -1,
+	"lowers guidance":      -1,
+	"going concern":        -1,
+	"short seller":         -1,
+	"short report":         -1,
+}
+
+func wordSet(words string) map[string]bool {
+	out := make(map[string]bool)
+	for _, w := range strings.Fields(words) {
+		out[w] = true
+	}
+	return out
+}
+
+// Score rates headline and summary with the finance lexicon. Phrases count before the words in them,
+// a negation flips the next few words, and headline words weigh double.
+func Score(headline, summary string) Sentiment {
+	var pos, neg float64
+	for _, part := range []struct {
+		text   string
+		weight float64
+	}{{headline, headlineWeight}, {summary, 1}} {
+		p, n := scoreText(part.text)
+		pos += p * part.weight
+		neg += n * part.weight
+	}
+	hits := pos + neg
+	if hits == 0 {
+		return Sentiment{}
+	}
+	// Damped so one word isn't a full-strength signal; confidence grows with evidence and falls when
+	// positive and negative words disagree
+	score := (pos - neg) / (hits + 1)
+	agreement := (pos - neg) / hits
+	if agreement < 0 {
+		agreement = -agreement
+	}
+	return Sentiment{Score: score, Confidence: hits / (hits + 2) * agreement}
+}
+
+// scoreText counts positive and negative hits in text.
+func scoreText(text string) (pos, neg float64) {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '-'
+	})
+	negateUntil := -1
+	for i := 0; i < len(words); i++ {
+		if negations[words[i]] {
+			negateUntil = i + negationWindow
+			continue
+		}
+		v, n := 0.0, 1
+		for _, l := range []int{3, 2} {
+			if i+l <= len(words) {
+				if pv, ok := phrases[strings.Join(words[i:i+l], " ")]; ok {
+					v, n = pv, l
+					break
+				}
+			}
+		}
+		if v == 0 {
+			switch {
+			case positiveWords[words[i]]:
+				v = 1
+			case negativeWords[words[i]]:
+				v = -1
+			}
+		}
+		if v != 0 && i <= negateUntil {
+			v = -v
+		}
+		switch {
+		case v > 0:
+			pos++
+		case v < 0:
+			neg++
+		}
+		i += n - 1
+	}
+	return pos, neg
+}
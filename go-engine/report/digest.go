@@ -0,0 +1,319 @@
+// Package report builds the daily email digest: a plain-text record of what the engine did (trades, P&L,
+// risk events, data-quality issues, uptime) for users who don't run dashboards.
+package report
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sunnyp94/sentry-bridge/go-engine/alpaca"
+	"github.com/sunnyp94/sentry-bridge/go-engine/events"
+	"github.com/sunnyp94/sentry-bridge/go-engine/sink"
+)
+
+// NameDigest is the digest's sink name. It reads the event stream rather than exporting it, so SINKS
+// does not apply to it.
+const NameDigest = "digest"
+
+// maxListed caps each list in the digest (kill switch changes, PDT events, gaps, brain errors).
+const maxListed = 20
+
+// Digest is a sink that tallies the events worth a nightly summary. Flush renders everything since the
+// previous Flush (or the start) and starts a new period. Quotes, trades and the other hot events are
+// ignored with a single type switch, so publishing stays cheap.
+type Digest struct {
+	started time.Time
+
+	mu        sync.Mutex
+	since     time.Time
+	fills     map[string]*symbolFills
+	orders    map[string]int       // trade update events other than fills: canceled, rejected, expired, ...
+	accepted  int                  // order intents the gateway accepted
+	rejected  map[string]int       // refused order intents by reason
+	account   *events.AccountEvent // latest, kept across periods
+	startEq   float64              // equity at the first account event of the period
+	kills     []string
+	pdt       []string
+	gaps      []string
+	brainErrs []string
+	brainErrN int
+	feedWorst *alpaca.FeedDivergence // symbol and window with the largest IEX/SIP mid divergence
+	universe  int
+	stats     *events.EngineStatsEvent
+
+	sent, failed int
+	lastFailed   bool
+}
+
+type symbolFills struct {
+	executions   int
+	bought, sold float64 // shares
+	boughtValue  float64
+	soldValue    float64
+}
+
+// NewDigest starts the first period now; started is when the engine started, for uptime.
+func NewDigest(started time.Time) *Digest {
+	d := &Digest{started: started}
+	d.reset(time.Now())
+	return d
+}
+
+func (d *Digest) reset(now time.Time) {
+	d.since = now
+	d.fills = make(map[string]*symbolFills)
+	d.orders = make(map[string]int)
+	d.rejected = make(map[string]int)
+	d.accepted, d.startEq = 0, 0
+	if d.account != nil {
+		d.startEq = d.account.Equity // the last account event carries over as the new period's start
+	}
+	d.kills, d.pdt, d.gaps, d.brainErrs, d.brainErrN = nil, nil, nil, nil, 0
+	d.feedWorst, d.universe = nil, 0
+}
+
+// Name implements sink.Sink.
+func (d *Digest) Name() string { return NameDigest }
+
+// Publish implements sink.Sink.
+func (d *Digest) Publish(ev sink.Event) {
+	switch ev.Type {
+	case events.TypeTradeUpdate, events.TypeOrderDecision, events.TypeAccount, events.TypeKillSwitch, events.TypePDT,
+		events.TypeGapRecovery, events.TypeBrainError, events.TypeFeedCompare, events.TypeUniverse, events.TypeEngineStats:
+	default:
+		return
+	}
+	at := ev.TS
+	if t, err := time.Parse(time.RFC3339Nano, ev.TS); err == nil {
+		at = t.UTC().Format("15:04 UTC")
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	switch p := ev.Payload.(type) {
+	case events.TradeUpdateEvent:
+		if p.Event != "fill" && p.Event != "partial_fill" {
+			d.orders[p.Event]++
+			return
+		}
+		f := d.fills[p.Symbol]
+		if f == nil {
+			f = &symbolFills{}
+			d.fills[p.Symbol] = f
+		}
+		f.executions++
+		if p.Side == "buy" {
+			f.bought += p.ExecQty
+			f.boughtValue += p.ExecQty * p.Price
+		} else {
+			f.sold += p.ExecQty
+			f.soldValue += p.ExecQty * p.Price
+		}
+	case events.OrderDecisionEvent:
+		if p.Accepted {
+			d.accepted++
+			return
+		}
+		reason := p.Reason
+		if i := strings.Index(reason, ": "); i > 0 {
+			reason = reason[:i] // the guard or check, without the order details
+		}
+		d.rejected[reason]++
+	case events.AccountEvent:
+		if d.startEq == 0 {
+			d.startEq = p.Equity
+		}
+		d.account = &p
+	case events.KillSwitchEvent:
+		state := "released"
+		if p.Engaged {
+			state = "engaged"
+		}
+		d.kills = appendCapped(d.kills, fmt.Sprintf("%s %s by %s: %s", at, state, p.Source, p.Reason))
+	case events.PDTEvent:
+		what := "warning"
+		if p.Blocked {
+			what = "blocked " + p.Symbol
+		}
+		d.pdt = appendCapped(d.pdt, fmt.Sprintf("%s %s: %d/%d day trades, equity $%.2f", at, what, p.DayTrades, p.Limit, p.Equity))
+	case events.GapRecoveryEvent:
+		d.gaps = appendCapped(d.gaps, fmt.Sprintf("%s %s stream down %.0fs", at, p.Stream, p.GapSec))
+	case events.BrainErrorEvent:
+		d.brainErrN++
+		d.brainErrs = appendCapped(d.brainErrs, fmt.Sprintf("%s %s %s", at, p.Brain, p.Exception))
+	case events.FeedCompareEvent:
+		for i, s := range p.Symbols {
+			if d.feedWorst == nil || s.MidDiffBpsMax > d.feedWorst.MidDiffBpsMax {
+				d.feedWorst = &p.Symbols[i]
+			}
+		}
+	case events.UniverseEvent:
+		d.universe++
+	case events.EngineStatsEvent:
+		d.stats = &p
+	}
+}
+
+func appendCapped(list []string, s string) []string {
+	if len(list) >= maxListed {
+		return list
+	}
+	return append(list, strings.TrimSpace(s))
+}
+
+// Stats implements sink.Sink: Published counts digests sent, Errors digests that failed to send.
+func (d *Digest) Stats() sink.Stats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return sink.Stats{Published: uint64(d.sent), Errors: uint64(d.failed), Healthy: !d.lastFailed}
+}
+
+// Close implements sink.Sink.
+func (d *Digest) Close() error { return nil }
+
+// Flush renders the digest for the period ending now and starts the next one.
+func (d *Digest) Flush(now time.Time) (subject, body string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var b strings.Builder
+	line := func(format string, args ...interface{}) { fmt.Fprintf(&b, format+"\n", args...) }
+
+	line("Period: %s to %s", d.since.UTC().Format("2006-01-02 15:04"), now.UTC().Format("2006-01-02 15:04 UTC"))
+	line("Engine up since %s (%s)", d.started.UTC().Format("2006-01-02 15:04 UTC"), now.Sub(d.started).Round(time.Minute))
+
+	line("\nP&L")
+	dayPL := math.NaN()
+	if a := d.account; a != nil {
+		dayPL = a.Equity - a.LastEquity
+		line("  Equity $%.2f (previous close $%.2f): day P&L %+.2f", a.Equity, a.LastEquity, dayPL)
+		line("  Change over the period %+.2f; cash $%.2f, buying power $%.2f", a.Equity-d.startEq, a.Cash, a.BuyingPower)
+		if a.TradingBlocked {
+			line("  Trading is blocked on the account")
+		}
+	} else {
+		line("  No account events (they need ACCOUNT_INTERVAL_SEC > 0)")
+	}
+
+	line("\nTrades")
+	symbols := make([]string, 0, len(d.fills))
+	executions := 0
+	for s, f := range d.fills {
+		symbols = append(symbols, s)
+		executions += f.executions
+	}
+	sort.Strings(symbols)
+	if len(symbols) == 0 {
+		line("  No fills")
+	}
+	for _, s := range symbols {
+		f := d.fills[s]
+		line("  %-6s %3d fills  bought %g ($%.2f)  sold %g ($%.2f)", s, f.executions, f.bought, f.boughtValue, f.sold, f.soldValue)
+	}
+	if len(d.orders) > 0 {
+		line("  Other order events: %s", countList(d.orders))
+	}
+	line("  Order intents accepted: %d, refused: %d", d.accepted, sum(d.rejected))
+
+	line("\nRisk")
+	if len(d.rejected) == 0 && len(d.kills) == 0 && len(d.pdt) == 0 {
+		line("  No refusals or kill switch changes")
+	}
+	if len(d.rejected) > 0 {
+		line("  Refused: %s", countList(d.rejected))
+	}
+	list(&b, "Kill switch", d.kills)
+	list(&b, "Pattern day trader", d.pdt)
+
+	line("\nData quality")
+	if len(d.gaps) == 0 && d.brainErrN == 0 && d.feedWorst == nil {
+		line("  No stream gaps or brain errors")
+	}
+	list(&b, "Stream gaps", d.gaps)
+	if d.brainErrN > 0 {
+		list(&b, fmt.Sprintf("Brain errors (%d)", d.brainErrN), d.brainErrs)
+	}
+	if s := d.feedWorst; s != nil {
+		line("  Largest IEX/SIP divergence: %s %.1f bps (avg %.1f, same quote %.0f%%)", s.Symbol, s.MidDiffBpsMax, s.MidDiffBpsAvg, s.SameQuotePct)
+	}
+	if d.universe > 0 {
+		line("  Universe changes: %d", d.universe)
+	}
+	if st := d.stats; st != nil {
+		line("  Since start: %d events dropped, %d expired, %d publish errors, %d brain restarts", st.Dropped, st.Expired, st.PublishErrors, st.BrainRestarts)
+		if len(st.Reconnects) > 0 {
+			rc := make(map[string]int, len(st.Reconnects))
+			for k, v := range st.Reconnects {
+				rc[k] = int(v)
+			}
+			line("  Reconnects: %s", countList(rc))
+		}
+	}
+
+	subject = fmt.Sprintf("Engine digest %s: %d fills in %d symbols", now.Format("2006-01-02"), executions, len(symbols))
+	if !math.IsNaN(dayPL) {
+		subject += fmt.Sprintf(", day P&L %+.2f", dayPL)
+	}
+	if len(d.kills) > 0 || len(d.pdt) > 0 || d.brainErrN > 0 {
+		subject += " (see risk and data quality)"
+	}
+	d.reset(now)
+	return subject, b.String()
+}
+
+// Send flushes the digest and mails it. The period starts over even if mailing fails.
+func (d *Digest) Send(m *Mailer, now time.Time) error {
+	subject, body := d.Flush(now)
+	err := m.Send(subject, body)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.lastFailed = err != nil
+	if err != nil {
+		d.failed++
+	} else {
+		d.sent++
+	}
+	return err
+}
+
+func list(b *strings.Builder, title string, items []string) {
+	if len(items) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "  %s:\n", title)
+	for _, it := range items {
+		fmt.Fprintf(b, "    %s\n", it)
+	}
+	if len(items) == maxListed {
+		fmt.Fprintf(b, "    (first %d shown)\n", maxListed)
+	}
+}
+
+// countList renders counts as "a 3, b 1", largest first.
+func countList(m map[string]int) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if m[keys[i]] != m[keys[j]] {
+			return m[keys[i]] > m[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%s %d", k, m[k])
+	}
+	return strings.Join(parts, ", ")
+}
+
+func sum(m map[string]int) int {
+	n := 0
+	for _, v := range m {
+		n += v
+	}
+	return n
+}
@@ -0,0 +1,92 @@
+package report
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Mailer sends plain-text mail through an SMTP server. Port 465 uses implicit TLS; other ports upgrade
+// with STARTTLS when the server offers it. Auth is skipped when User is empty.
+type Mailer struct {
+	Addr     string // host:port
+	User     string
+	Password string
+	From     string
+	To       []string
+}
+
+// Send mails subject and body to every recipient.
+func (m *Mailer) Send(subject, body string) error {
+	if len(m.To) == 0 {
+		return errors.New("smtp: no recipients")
+	}
+	host, port, err := net.SplitHostPort(m.Addr)
+	if err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+	var c *smtp.Client
+	if port == "465" {
+		conn, err := tls.DialWithDialer(&net.Dialer{Timeout: 30 * time.Second}, "tcp", m.Addr, &tls.Config{ServerName: host})
+		if err != nil {
+			return fmt.Errorf("smtp: %w", err)
+		}
+		c, err = smtp.NewClient(conn, host)
+		if err != nil {
+			conn.Close()
+			return fmt.Errorf("smtp: %w", err)
+		}
+	} else {
+		conn, err := net.DialTimeout("tcp", m.Addr, 30*time.Second)
+		if err != nil {
+			return fmt.Errorf("smtp: %w", err)
+		}
+		c, err = smtp.NewClient(conn, host)
+		if err != nil {
+			conn.Close()
+			return fmt.Errorf("smtp: %w", err)
+		}
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+				c.Close()
+				return fmt.Errorf("smtp: starttls: %w", err)
+			}
+		}
+	}
+	defer c.Close()
+	if m.User != "" {
+		if err := c.Auth(smtp.PlainAuth("", m.User, m.Password, host)); err != nil {
+			return fmt.Errorf("smtp: auth: %w", err)
+		}
+	}
+	if err := c.Mail(m.From); err != nil {
+		return fmt.Errorf("smtp: from: %w", err)
+	}
+	for _, to := range m.To {
+		if err := c.Rcpt(to); err != nil {
+			return fmt.Errorf("smtp: to %s: %w", to, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+	msg := "From: " + m.From + "\r\n" +
+		"To: " + strings.Join(m.To, ", ") + "\r\n" +
+		"Subject: " + subject + "\r\n" +
+		"Date: " + time.Now().Format(time.RFC1123Z) + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n\r\n" +
+		strings.ReplaceAll(body, "\n", "\r\n")
+	if _, err := w.Write([]byte(msg)); err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+	return c.Quit()
+}
@@ -0,0 +1,190 @@
+package sink
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sunnyp94/sentry-bridge/go-engine/events"
+)
+
+// Dispatcher fans each event out to every configured sink and keeps per-type counts. Adding an output
+// is a new Sink in the list, not another call next to every send. Each sink numbers the events it
+// receives on its own (Sequenced). All methods are no-ops on a nil Dispatcher.
+type Dispatcher struct {
+	sinks []Sink
+	types sync.Map // event type -> *typeCounter
+	ids   sync.Map // first symbol ("" = account-wide) -> *atomic.Uint64, for event IDs
+
+	ttl      time.Duration
+	ttlTypes map[string]bool
+	idPrefix string          // run prefix of event IDs
+	idTypes  map[string]bool // event types that get an ID
+}
+
+// typeCounter tracks dispatched events of one type and how long handing them to the sinks took.
+type typeCounter struct {
+	count atomic.Uint64
+	nanos atomic.Int64
+	max   atomic.Int64
+}
+
+// NewDispatcher returns a dispatcher for sinks, or nil if there are none.
+func NewDispatcher(sinks ...Sink) *Dispatcher {
+	if len(sinks) == 0 {
+		return nil
+	}
+	d := &Dispatcher{sinks: make([]Sink, len(sinks))}
+	for i, s := range sinks {
+		d.sinks[i] = Sequenced(s)
+	}
+	return d
+}
+
+// SetTTL gives events of types an expiry ttl after dispatch; each stage drops them unsent once it has
+// passed. ttl 0 turns expiry off. Call before use.
+func (d *Dispatcher) SetTTL(ttl time.Duration, types []string) {
+	if d == nil {
+		return
+	}
+	d.ttl, d.ttlTypes = ttl, make(map[string]bool, len(types))
+	for _, t := range types {
+		d.ttlTypes[t] = true
+	}
+}
+
+// SetEventIDs gives events of types an ID, <run>-<symbol>-<n>, unique across engine runs: the run part
+// is the engine's start time and n counts the symbol's events with an ID. Consumers pass it back to tie what they do to the event. Call before use.
+func (d *Dispatcher) SetEventIDs(types []string) {
+	if d == nil {
+		return
+	}
+	d.idPrefix = strconv.FormatInt(time.Now().UnixMilli(), 36) + "-"
+	d.idTypes = make(map[string]bool, len(types))
+	for _, t := range types {
+		d.idTypes[t] = true
+	}
+}
+
+// Send publishes an account-wide event (positions, orders, trade updates, ...) and returns its ID, if
+// its type gets one.
+func (d *Dispatcher) Send(typ string, payload interface{}) string {
+	return d.dispatch(nil, typ, payload)
+}
+
+// SendSymbol publishes an event that belongs to symbol and returns its ID, if its type gets one.
+func (d *Dispatcher) SendSymbol(symbol, typ string, payload interface{}) string {
+	return d.dispatch([]string{symbol}, typ, payload)
+}
+
+// SendSymbols publishes an event tagged with several symbols (e.g. a news article) and returns its ID,
+// if its type gets one.
+func (d *Dispatcher) SendSymbols(symbols []string, typ string, payload interface{}) string {
+	return d.dispatch(symbols, typ, payload)
+}
+
+func (d *Dispatcher) dispatch(symbols []string, typ string, payload interface{}) string {
+	if d == nil {
+		return ""
+	}
+	t0 := time.Now()
+	ev := Event{Symbols: symbols, Envelope: events.Envelope{Type: typ, TS: t0.UTC().Format(time.RFC3339Nano), Payload: payload}, vars: &activation{}}
+	if d.ttl > 0 && d.ttlTypes[typ] {
+		ev.Deadline = t0.Add(d.ttl)
+		ev.Expires = ev.Deadline.UTC().Format(time.RFC3339Nano)
+	}
+	if d.idTypes[typ] {
+		v, ok := d.ids.Load(ev.Key())
+		if !ok {
+			v, _ = d.ids.LoadOrStore(ev.Key(), new(atomic.Uint64))
+		}
+		ev.ID = d.idPrefix + ev.Key() + "-" + strconv.FormatUint(v.(*atomic.Uint64).Add(1), 10)
+	}
+	for _, s := range d.sinks {
+		s.Publish(ev)
+	}
+	d.record(typ, time.Since(t0).Nanoseconds())
+	return ev.ID
+}
+
+func (d *Dispatcher) record(typ string, nanos int64) {
+	v, ok := d.types.Load(typ)
+	if !ok {
+		v, _ = d.types.LoadOrStore(typ, &typeCounter{})
+	}
+	c := v.(*typeCounter)
+	c.count.Add(1)
+	c.nanos.Add(nanos)
+	for {
+		m := c.max.Load()
+		if nanos <= m || c.max.CompareAndSwap(m, nanos) {
+			break
+		}
+	}
+}
+
+// EventStats returns dispatched event counts and send latency per event type since start.
+func (d *Dispatcher) EventStats() map[string]events.EventTypeStats {
+	out := make(map[string]events.EventTypeStats)
+	if d == nil {
+		return out
+	}
+	d.types.Range(func(k, v interface{}) bool {
+		c := v.(*typeCounter)
+		st := events.EventTypeStats{Count: c.count.Load(), MaxSendMs: float64(c.max.Load()) / 1e6}
+		if st.Count > 0 {
+			st.AvgSendMs = float64(c.nanos.Load()) / float64(st.Count) / 1e6
+		}
+		out[k.(string)] = st
+		return true
+	})
+	return out
+}
+
+// Sinks returns the configured sinks.
+func (d *Dispatcher) Sinks() []Sink {
+	if d == nil {
+		return nil
+	}
+	return d.sinks
+}
+
+// SinkStats returns counters per sink, keyed by name.
+func (d *Dispatcher) SinkStats() map[string]Stats {
+	out := make(map[string]Stats)
+	if d == nil {
+		return out
+	}
+	for _, s := range d.sinks {
+		out[s.Name()] = s.Stats()
+	}
+	return out
+}
+
+// Flush waits, up to timeout in all, for every sink to write the events sent before it (Flusher). It
+// reports false when the time ran out first.
+func (d *Dispatcher) Flush(timeout time.Duration) bool {
+	if d == nil {
+		return true
+	}
+	deadline := time.Now().Add(timeout)
+	ok := true
+	for _, s := range d.sinks {
+		if !Flush(s, time.Until(deadline)) {
+			ok = false
+		}
+	}
+	return ok
+}
+
+// Close flushes and closes every sink in order.
+func (d *Dispatcher) Close() error {
+	if d == nil {
+		return nil
+	}
+	for _, s := range d.sinks {
+		_ = s.Close()
+	}
+	return nil
+}
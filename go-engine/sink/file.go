@@ -0,0 +1,46 @@
+package sink
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// fileWriter appends each event as one JSON envelope line (the brain's NDJSON format) to a file.
+type fileWriter struct {
+	f *os.File
+	w *bufio.Writer
+}
+
+// NewFile opens path for appending (creating its directory) and returns the event file sink.
+func NewFile(path string, queueSize int) (*Queued, error) {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return NewQueued(NameFile+":"+path, &fileWriter{f: f, w: bufio.NewWriter(f)}, queueSize), nil
+}
+
+func (fw *fileWriter) Write(batch []Event) error {
+	for _, ev := range batch {
+		b, err := json.Marshal(ev.Envelope)
+		if err != nil {
+			return err
+		}
+		// bufio keeps the first write error; Flush reports it.
+		_, _ = fw.w.Write(b)
+		_ = fw.w.WriteByte('\n')
+	}
+	return fw.w.Flush()
+}
+
+func (fw *fileWriter) Close() error {
+	_ = fw.w.Flush()
+	return fw.f.Close()
+}
@@ -0,0 +1,228 @@
+package sink
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/cel-go/cel"
+)
+
+// Rule is one EVENT_FILTERS entry: a CEL condition checked against every event and what to do when it
+// holds. The expression sees event_type (string), symbol (first symbol, "" for account-wide events),
+// symbols (list) and payload (the event's JSON payload as a map), e.g.
+//
+//	{"when": "event_type == 'quote' && payload.spread_bps > 100", "drop": true, "sinks": ["brain"]}
+//	{"when": "event_type == 'trade' && payload.size > 10000", "tag": "block_trade"}
+type Rule struct {
+	When  string   `json:"when"`            // CEL expression; must evaluate to a bool
+	Drop  bool     `json:"drop,omitempty"`  // matching events are not published
+	Tag   string   `json:"tag,omitempty"`   // matching events get this tag (Envelope.Tags)
+	Sinks []string `json:"sinks,omitempty"` // sinks the rule applies to, by name or kind (brain, brain-2, redis, kafka-dr, ...); empty = all
+}
+
+// LoadRules reads a JSON array of rules.
+func LoadRules(path string) ([]Rule, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules []Rule
+	if err := json.Unmarshal(b, &rules); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return rules, nil
+}
+
+// Filter evaluates the rules for one sink (or one brain). Apply is a no-op on a nil Filter.
+type Filter struct {
+	rules []compiledRule
+}
+
+type compiledRule struct {
+	src     string
+	prg     cel.Program
+	drop    bool
+	tag     string
+	evalErr atomic.Uint64 // evaluations that failed (e.g. a payload field missing); the rule is skipped
+}
+
+// RulesFor returns the rules that apply to a sink known by any of names (e.g. "redis:market:updates", or
+// "brain" and "brain-2"). A rule's sink entry matches a name exactly or the name's kind, the part before
+// the first ':'; like SINKS, "redis" and "kafka" also cover their DR replicas.
+func RulesFor(rules []Rule, names ...string) []Rule {
+	var out []Rule
+	for _, r := range rules {
+		if len(r.Sinks) == 0 || matchesSink(r.Sinks, names) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+func matchesSink(sinks, names []string) bool {
+	for _, s := range sinks {
+		s = strings.ToLower(strings.TrimSpace(s))
+		for _, n := range names {
+			kind, _, _ := strings.Cut(n, ":")
+			kind = strings.ToLower(kind)
+			if s == strings.ToLower(n) || s == kind || s == strings.TrimSuffix(kind, "-dr") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// NewFilter compiles rules; nil (no filtering) when there are none. An expression that doesn't compile
+// or isn't boolean is an error, so a typo is caught at startup instead of silently passing everything.
+func NewFilter(rules []Rule) (*Filter, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	env, err := cel.NewEnv(
+		cel.Variable("event_type", cel.StringType), // "type" is a CEL builtin
+		cel.Variable("symbol", cel.StringType),
+		cel.Variable("symbols", cel.ListType(cel.StringType)),
+		cel.Variable("payload", cel.MapType(cel.StringType, cel.DynType)),
+		cel.CrossTypeNumericComparisons(true), // JSON numbers are doubles: payload.size > 10000 must work
+	)
+	if err != nil {
+		return nil, err
+	}
+	f := &Filter{rules: make([]compiledRule, len(rules))}
+	for i, r := range rules {
+		if r.Drop == (r.Tag != "") {
+			return nil, fmt.Errorf("filter %q: set exactly one of drop or tag", r.When)
+		}
+		ast, iss := env.Compile(r.When)
+		if iss.Err() != nil {
+			return nil, fmt.Errorf("filter %q: %w", r.When, iss.Err())
+		}
+		if ast.OutputType() != cel.BoolType && ast.OutputType() != cel.DynType {
+			return nil, fmt.Errorf("filter %q: result is %s, want bool", r.When, ast.OutputType())
+		}
+		prg, err := env.Program(ast)
+		if err != nil {
+			return nil, fmt.Errorf("filter %q: %w", r.When, err)
+		}
+		c := &f.rules[i]
+		c.src, c.prg, c.drop, c.tag = r.When, prg, r.Drop, r.Tag
+	}
+	return f, nil
+}
+
+// Apply runs the rules in order: the first matching drop rule drops ev (false); tag rules add their tag.
+// The payload is converted for CEL once per event, not per rule or per sink.
+func (f *Filter) Apply(ev Event) (Event, bool) {
+	if f == nil {
+		return ev, true
+	}
+	act := ev.vars
+	if act == nil { // not from a Dispatcher
+		act = &activation{}
+	}
+	vars, err := act.get(ev)
+	if err != nil {
+		return ev, true // unencodable payloads are the sink's problem, as without a filter
+	}
+	tagged := false
+	for i := range f.rules {
+		r := &f.rules[i]
+		out, _, err := r.prg.Eval(vars)
+		if err != nil {
+			if r.evalErr.Add(1) == 1 {
+				slog.Warn("event filter evaluation failed; rule skipped for such events", "filter", r.src, "type", ev.Type, "err", err)
+			}
+			continue
+		}
+		if hit, _ := out.Value().(bool); !hit {
+			continue
+		}
+		if r.drop {
+			return ev, false
+		}
+		if !tagged {
+			// Copy on first write: the same event goes to every sink
+			ev.Tags = append([]string(nil), ev.Tags...)
+			tagged = true
+		}
+		ev.Tags = append(ev.Tags, r.tag)
+	}
+	return ev, true
+}
+
+// activation holds an event's CEL variables, built the first time a filter needs them. The dispatcher
+// gives each event one, so every sink's filter shares the conversion.
+type activation struct {
+	once sync.Once
+	vars map[string]interface{}
+	err  error
+}
+
+func (a *activation) get(ev Event) (map[string]interface{}, error) {
+	a.once.Do(func() { a.vars, a.err = buildActivation(ev) })
+	return a.vars, a.err
+}
+
+// buildActivation builds the CEL variables for ev.
+func buildActivation(ev Event) (map[string]interface{}, error) {
+	b, err := json.Marshal(ev.Payload)
+	if err != nil {
+		return nil, err
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(b, &payload); err != nil || payload == nil {
+		payload = map[string]interface{}{} // non-object payloads have no fields
+	}
+	symbols := ev.Symbols
+	if symbols == nil {
+		symbols = []string{}
+	}
+	return map[string]interface{}{
+		"event_type": ev.Type,
+		"symbol":     ev.Key(),
+		"symbols":    symbols,
+		"payload":    payload,
+	}, nil
+}
+
+// Filtered applies f in front of s: dropped events never reach s and are counted as Filtered in its
+// stats. Events that pass are numbered for s (Sequenced), so dropped ones leave no gap in its seq.
+// Returns s itself when f is nil.
+func Filtered(s Sink, f *Filter) Sink {
+	if f == nil {
+		return s
+	}
+	return &filtered{Sink: Sequenced(s), f: f}
+}
+
+type filtered struct {
+	Sink
+	f       *Filter
+	dropped atomic.Uint64
+}
+
+func (s *filtered) Publish(ev Event) {
+	ev, ok := s.f.Apply(ev)
+	if !ok {
+		s.dropped.Add(1)
+		return
+	}
+	s.Sink.Publish(ev)
+}
+
+func (s *filtered) NumbersEvents() {}
+
+func (s *filtered) Flush(timeout time.Duration) bool { return Flush(s.Sink, timeout) }
+
+func (s *filtered) Stats() Stats {
+	st := s.Sink.Stats()
+	st.Filtered = s.dropped.Load()
+	return st
+}
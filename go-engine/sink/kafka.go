@@ -0,0 +1,70 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+const (
+	kafkaBatchTimeout = 50 * time.Millisecond // flush a partial batch after this long
+	kafkaWriteTimeout = 10 * time.Second
+)
+
+// KafkaConfig configures the Kafka sink.
+type KafkaConfig struct {
+	Name      string // sink name in stats and logs; default kafka:<topic>
+	Brokers   []string
+	Topic     string
+	QueueSize int // 0 = DefaultQueueSize
+}
+
+// kafkaWriter writes each event as a JSON envelope to one topic, keyed by symbol so a symbol's events
+// stay ordered within a partition.
+type kafkaWriter struct {
+	w *kafka.Writer
+}
+
+// NewKafka returns the Kafka sink. Brokers are not contacted until the first event.
+func NewKafka(cfg KafkaConfig) (*Queued, error) {
+	if len(cfg.Brokers) == 0 || cfg.Topic == "" {
+		return nil, errors.New("kafka sink: brokers and topic required")
+	}
+	w := &kafkaWriter{w: &kafka.Writer{
+		Addr:         kafka.TCP(cfg.Brokers...),
+		Topic:        cfg.Topic,
+		Balancer:     &kafka.Hash{},
+		BatchSize:    maxBatch,
+		BatchTimeout: kafkaBatchTimeout,
+		WriteTimeout: kafkaWriteTimeout,
+		RequiredAcks: kafka.RequireOne,
+	}}
+	name := cfg.Name
+	if name == "" {
+		name = NameKafka + ":" + cfg.Topic
+	}
+	return NewQueued(name, w, cfg.QueueSize), nil
+}
+
+func (k *kafkaWriter) Write(batch []Event) error {
+	msgs := make([]kafka.Message, 0, len(batch))
+	for _, ev := range batch {
+		b, err := json.Marshal(ev.Envelope)
+		if err != nil {
+			return err
+		}
+		msg := kafka.Message{Value: b}
+		if key := ev.Key(); key != "" {
+			msg.Key = []byte(key)
+		}
+		msgs = append(msgs, msg)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), kafkaWriteTimeout)
+	defer cancel()
+	return k.w.WriteMessages(ctx, msgs...)
+}
+
+func (k *kafkaWriter) Close() error { return k.w.Close() }
@@ -0,0 +1,395 @@
+package sink
+
+import (
+	"errors"
+	"log/slog"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultQueueSize is a sink's queue capacity when none is configured.
+const DefaultQueueSize = 10000
+
+const (
+	maxBatch          = 500              // default cap on events handed to one Writer.Write call
+	errLogInterval    = 10 * time.Second // rate-limits the write failure warning
+	queueCloseTimeout = 5 * time.Second  // Close gives up flushing after this long
+	retryMin          = time.Second      // first outbox retry after a failed write
+	retryMax          = 30 * time.Second // retry backoff cap
+)
+
+// Writer delivers a batch of events synchronously. An error counts the whole batch as failed.
+type Writer interface {
+	Write(batch []Event) error
+	Close() error
+}
+
+// Unencodable is what a Writer returns after writing the rest of a batch when some of its events can't
+// be encoded at all (a NaN in a JSON payload, say). Those events count as errors and are never held in
+// the outbox: no retry would fix them.
+type Unencodable struct {
+	Events int   // events skipped
+	Err    error // the first encoding error
+}
+
+func (e *Unencodable) Error() string {
+	return strconv.Itoa(e.Events) + " unencodable events skipped: " + e.Err.Error()
+}
+
+func (e *Unencodable) Unwrap() error { return e.Err }
+
+// Batching controls how the drain goroutine groups events into Writer.Write calls.
+type Batching struct {
+	Size   int           // flush at this many events; 0 = 500
+	Linger time.Duration // wait up to this long after the first event for a batch to fill; 0 = write what is queued
+}
+
+// Queued runs a Writer behind a bounded queue drained by one goroutine, so Publish never blocks and a
+// slow or failing target only loses its own events.
+type Queued struct {
+	name   string
+	w      Writer
+	batch  Batching
+	queue  chan Event
+	stop   chan struct{}
+	done   chan struct{}
+	flush  chan chan struct{} // Flush requests, answered by closing the channel sent
+	closed atomic.Bool
+	log    *slog.Logger
+
+	closeErr error // the writer's Close error, set by the writer goroutine before done is closed
+
+	published atomic.Uint64
+	dropped   atomic.Uint64
+	errors    atomic.Uint64
+	batches   atomic.Uint64
+	writeNs   atomic.Int64 // total time in Writer.Write
+	maxNs     atomic.Int64
+	unhealthy atomic.Bool // last write failed
+	lastLog   time.Time   // writer goroutine only
+
+	// Outbox (SetOutbox): failed events waiting to be retried in order. Writer goroutine only, except
+	// pending, which mirrors len(outbox) for Stats.
+	outboxMax int
+	outbox    []Event
+	retryAt   time.Time
+	backoff   time.Duration
+	pending   atomic.Int64
+
+	expired atomic.Uint64 // events past their TTL, dropped before a write
+}
+
+// NewQueued starts the drain goroutine for w. size 0 = DefaultQueueSize.
+func NewQueued(name string, w Writer, size int) *Queued {
+	return NewQueuedBatch(name, w, size, Batching{})
+}
+
+// NewQueuedBatch is NewQueued with explicit batching, for targets where a round trip per write is the
+// bottleneck.
+func NewQueuedBatch(name string, w Writer, size int, b Batching) *Queued {
+	if size <= 0 {
+		size = DefaultQueueSize
+	}
+	if b.Size <= 0 {
+		b.Size = maxBatch
+	}
+	q := &Queued{
+		name:  name,
+		w:     w,
+		batch: b,
+		queue: make(chan Event, size),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+		flush: make(chan chan struct{}),
+		log:   slog.Default().With("sink", name),
+	}
+	go q.run()
+	return q
+}
+
+// SetOutbox keeps up to n events from failed writes and retries them, oldest first and with backoff,
+// before anything newer is written; beyond n the oldest are dropped. 0 (the default) counts failed
+// events as errors and moves on. Call before publishing.
+func (q *Queued) SetOutbox(n int) { q.outboxMax = n }
+
+// Name identifies the sink in stats and logs.
+func (q *Queued) Name() string { return q.name }
+
+// Publish queues ev; when the queue is full the oldest event is dropped.
+func (q *Queued) Publish(ev Event) {
+	if q.closed.Load() {
+		return
+	}
+	for {
+		select {
+		case q.queue <- ev:
+			return
+		default:
+		}
+		select {
+		case <-q.queue:
+			q.dropped.Add(1)
+		default:
+		}
+	}
+}
+
+// run writes queued events in batches until Close, then flushes what is left.
+func (q *Queued) run() {
+	defer close(q.done)
+	// The writer is closed here, once the last Write has returned, never from Close while one is in flight
+	defer func() { q.closeErr = q.w.Close() }()
+	batch := make([]Event, 0, q.batch.Size)
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	armed := false
+	for {
+		var retry <-chan time.Time
+		if len(q.outbox) > 0 {
+			if !armed {
+				timer.Reset(time.Until(q.retryAt))
+				armed = true
+			}
+			retry = timer.C
+		}
+		select {
+		case ev := <-q.queue:
+			batch = q.fill(append(batch[:0], ev))
+			if q.batch.Linger > 0 && len(batch) < q.batch.Size {
+				batch = q.linger(batch)
+			}
+			q.write(batch)
+		case flushed := <-q.flush:
+			// What was queued before the request; later events wait their turn, so a busy stream can't
+			// hold the caller
+			for n := len(q.queue); n > 0; n -= len(batch) {
+				if batch = q.fill(batch[:0]); len(batch) == 0 {
+					break
+				}
+				q.write(batch)
+			}
+			close(flushed)
+		case <-retry:
+			armed = false
+			if !time.Now().Before(q.retryAt) {
+				q.retry()
+			}
+		case <-q.stop:
+			for {
+				batch = q.fill(batch[:0])
+				if len(batch) == 0 {
+					break
+				}
+				q.write(batch)
+			}
+			q.retry()
+			if n := len(q.outbox); n > 0 {
+				q.log.Warn("sink closed with unsent outbox events", "events", n)
+			}
+			return
+		}
+	}
+}
+
+// fill adds queued events to batch without waiting, up to the batch size.
+func (q *Queued) fill(batch []Event) []Event {
+	for len(batch) < q.batch.Size {
+		select {
+		case ev := <-q.queue:
+			batch = append(batch, ev)
+		default:
+			return batch
+		}
+	}
+	return batch
+}
+
+// linger waits for more events until the batch is full, the linger time is up or the sink closes.
+func (q *Queued) linger(batch []Event) []Event {
+	timer := time.NewTimer(q.batch.Linger)
+	defer timer.Stop()
+	for len(batch) < q.batch.Size {
+		select {
+		case ev := <-q.queue:
+			batch = append(batch, ev)
+		case <-timer.C:
+			return batch
+		case <-q.stop:
+			return batch
+		}
+	}
+	return batch
+}
+
+func (q *Queued) write(batch []Event) {
+	if q.outboxMax > 0 && len(q.outbox) > 0 {
+		// Events behind a failed write wait their turn so order is kept
+		q.hold(batch)
+		if !time.Now().Before(q.retryAt) {
+			q.retry()
+		}
+		return
+	}
+	if err := q.send(batch); err != nil && q.outboxMax > 0 {
+		q.hold(batch)
+		q.backOff()
+	}
+}
+
+// send writes one batch and updates the counters and health. Without an outbox, failed events count as
+// errors.
+func (q *Queued) send(batch []Event) error {
+	if batch = q.unexpired(batch); len(batch) == 0 {
+		return nil
+	}
+	t0 := time.Now()
+	err := q.w.Write(batch)
+	ns := time.Since(t0).Nanoseconds()
+	q.batches.Add(1)
+	q.writeNs.Add(ns)
+	for {
+		m := q.maxNs.Load()
+		if ns <= m || q.maxNs.CompareAndSwap(m, ns) {
+			break
+		}
+	}
+	var bad *Unencodable
+	if errors.As(err, &bad) {
+		n := q.errors.Add(uint64(bad.Events))
+		if time.Since(q.lastLog) >= errLogInterval {
+			q.lastLog = time.Now()
+			q.log.Warn("sink skipped unencodable events", "events", bad.Events, "total_errors", n, "err", bad.Err)
+		}
+		batch, err = batch[bad.Events:], nil // the rest were written
+	}
+	if err != nil {
+		n := q.errors.Load()
+		if q.outboxMax == 0 {
+			n = q.errors.Add(uint64(len(batch)))
+		}
+		if !q.unhealthy.Swap(true) {
+			q.log.Error("sink unhealthy", "err", err)
+		}
+		if time.Since(q.lastLog) >= errLogInterval {
+			q.lastLog = time.Now()
+			q.log.Warn("sink write failed", "events", len(batch), "total_errors", n, "outbox", len(q.outbox), "err", err)
+		}
+		return err
+	}
+	q.published.Add(uint64(len(batch)))
+	if q.unhealthy.Swap(false) {
+		q.log.Info("sink recovered", "total_errors", q.errors.Load())
+	}
+	return nil
+}
+
+// unexpired returns batch without the events past their TTL, counting them. batch itself is not modified
+// (it may be a slice of the outbox).
+func (q *Queued) unexpired(batch []Event) []Event {
+	now := time.Now()
+	for i, ev := range batch {
+		if !ev.Expired(now) {
+			continue
+		}
+		fresh := append(make([]Event, 0, len(batch)-1), batch[:i]...)
+		for _, ev := range batch[i+1:] {
+			if ev.Expired(now) {
+				q.expired.Add(1)
+			} else {
+				fresh = append(fresh, ev)
+			}
+		}
+		q.expired.Add(1)
+		return fresh
+	}
+	return batch
+}
+
+// hold appends failed or waiting events to the outbox, dropping the oldest beyond its size.
+func (q *Queued) hold(batch []Event) {
+	q.outbox = append(q.outbox, batch...)
+	if over := len(q.outbox) - q.outboxMax; over > 0 {
+		q.outbox = q.outbox[over:]
+		q.dropped.Add(uint64(over))
+	}
+	q.pending.Store(int64(len(q.outbox)))
+}
+
+// retry writes the outbox in batches, oldest first, until it is empty or a write fails.
+func (q *Queued) retry() {
+	for len(q.outbox) > 0 {
+		n := len(q.outbox)
+		if n > q.batch.Size {
+			n = q.batch.Size
+		}
+		if err := q.send(q.outbox[:n]); err != nil {
+			q.backOff()
+			return
+		}
+		q.outbox = q.outbox[n:]
+		q.pending.Store(int64(len(q.outbox)))
+	}
+	q.outbox, q.backoff = nil, 0
+}
+
+// backOff schedules the next retry, doubling the wait up to retryMax.
+func (q *Queued) backOff() {
+	q.backoff *= 2
+	if q.backoff < retryMin {
+		q.backoff = retryMin
+	} else if q.backoff > retryMax {
+		q.backoff = retryMax
+	}
+	q.retryAt = time.Now().Add(q.backoff)
+}
+
+// Stats returns cumulative counters, batch sizes, write latency and events waiting in the outbox.
+func (q *Queued) Stats() Stats {
+	st := Stats{Published: q.published.Load(), Dropped: q.dropped.Load(), Errors: q.errors.Load(), Healthy: !q.unhealthy.Load(), Pending: q.pending.Load(), Expired: q.expired.Load()}
+	if n := q.batches.Load(); n > 0 {
+		st.Batches = n
+		st.AvgBatch = float64(st.Published+st.Errors) / float64(n)
+		st.AvgWriteMs = float64(q.writeNs.Load()) / float64(n) / 1e6
+		st.MaxWriteMs = float64(q.maxNs.Load()) / 1e6
+	}
+	return st
+}
+
+// Flush waits until the events queued before it are written (or held in the outbox), up to timeout. It
+// reports false when the time ran out.
+func (q *Queued) Flush(timeout time.Duration) bool {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	flushed := make(chan struct{})
+	select {
+	case q.flush <- flushed:
+	case <-q.done:
+		return true
+	case <-timer.C:
+		return false
+	}
+	select {
+	case <-flushed:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// Close flushes queued events and closes the writer, waiting up to queueCloseTimeout. When the time runs
+// out (a write hung on a dead target) it returns without waiting further; the writer is closed once that
+// write returns.
+func (q *Queued) Close() error {
+	if !q.closed.CompareAndSwap(false, true) {
+		return nil
+	}
+	close(q.stop)
+	select {
+	case <-q.done:
+		return q.closeErr
+	case <-time.After(queueCloseTimeout):
+		q.log.Warn("sink close timed out; unsent events lost, writer closed after its current write", "queued", len(q.queue))
+		return nil
+	}
+}
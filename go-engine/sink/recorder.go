@@ -0,0 +1,233 @@
+package sink
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sunnyp94/sentry-bridge/go-engine/alpaca"
+	"github.com/sunnyp94/sentry-bridge/go-engine/events"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// NameRecorder is the flight recorder's sink name. It is not an output, so SINKS does not apply to it.
+const NameRecorder = "recorder"
+
+// Recorder is the flight recorder: a sink that keeps the last window of events, plus decisions the engine
+// notes along the way (orders submitted or refused, the kill switch), in a fixed-size ring in memory and
+// writes them to a file when something goes wrong. Events are recorded as they are dispatched, so a dump
+// has exactly what the engine saw even when other sinks were behind. All methods are no-ops on a nil
+// Recorder.
+//
+// A dump is a sequence of MessagePack maps: a header {kind: "header", reason, dumped_at, window_sec,
+// records}, then one {kind: "event" or "decision", at, type, symbols, tags, payload} per record, oldest
+// first, with the JSON field names.
+type Recorder struct {
+	dir    string
+	window time.Duration
+
+	mu   sync.Mutex
+	ring []record
+	next int // slot the next record goes to
+	full bool
+
+	recorded atomic.Uint64
+	dumpErrs atomic.Uint64
+}
+
+type record struct {
+	at       time.Time
+	decision bool
+	ev       Event
+}
+
+// NewRecorder keeps up to maxRecords records and dumps those from the last window into dir.
+func NewRecorder(dir string, window time.Duration, maxRecords int) *Recorder {
+	if maxRecords <= 0 {
+		maxRecords = DefaultQueueSize
+	}
+	return &Recorder{dir: dir, window: window, ring: make([]record, maxRecords)}
+}
+
+// Name implements Sink.
+func (r *Recorder) Name() string { return NameRecorder }
+
+// Publish implements Sink: the event goes into the ring.
+func (r *Recorder) Publish(ev Event) {
+	ev.vars = nil // the ring outlives the filters; don't keep their variables
+	r.add(record{at: time.Now(), ev: ev})
+}
+
+// Note records an engine decision that is not an event, e.g. an order refused by a guard.
+func (r *Recorder) Note(kind string, detail interface{}) {
+	if r == nil {
+		return
+	}
+	now := time.Now()
+	r.add(record{at: now, decision: true, ev: Event{Envelope: events.Envelope{Type: kind, TS: now.UTC().Format(time.RFC3339Nano), Payload: detail}}})
+}
+
+func (r *Recorder) add(rec record) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	r.ring[r.next] = rec
+	r.next++
+	if r.next == len(r.ring) {
+		r.next, r.full = 0, true
+	}
+	r.mu.Unlock()
+	r.recorded.Add(1)
+}
+
+// Stats implements Sink. Errors counts failed dumps.
+func (r *Recorder) Stats() Stats {
+	return Stats{Published: r.recorded.Load(), Errors: r.dumpErrs.Load(), Healthy: r.dumpErrs.Load() == 0}
+}
+
+// Close implements Sink. The ring is only written on Dump, so there is nothing to flush.
+func (r *Recorder) Close() error { return nil }
+
+// Dump writes the records from the last window to a new file in dir and returns its path.
+func (r *Recorder) Dump(reason string) (string, error) {
+	if r == nil {
+		return "", nil
+	}
+	now := time.Now()
+	recs := r.since(now.Add(-r.window))
+	path, err := r.write(reason, now, recs)
+	if err != nil {
+		r.dumpErrs.Add(1)
+		slog.Error("flight recorder dump failed", "reason", reason, "err", err)
+		return "", err
+	}
+	slog.Warn("flight recorder dumped", "reason", reason, "path", path, "records", len(recs))
+	return path, nil
+}
+
+// DumpOnPanic dumps the ring if the goroutine is panicking, then lets the panic continue. Defer it
+// directly at the top of a goroutine: defer recorder.DumpOnPanic().
+func (r *Recorder) DumpOnPanic() {
+	if r == nil {
+		return // without a recorder the panic is not intercepted at all
+	}
+	if p := recover(); p != nil {
+		r.Note("panic", fmt.Sprint(p))
+		_, _ = r.Dump("panic")
+		panic(p)
+	}
+}
+
+// since copies the records at or after from, oldest first.
+func (r *Recorder) since(from time.Time) []record {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []record
+	add := func(recs []record) {
+		for _, rec := range recs {
+			if !rec.at.Before(from) {
+				out = append(out, rec)
+			}
+		}
+	}
+	if r.full {
+		add(r.ring[r.next:])
+	}
+	add(r.ring[:r.next])
+	return out
+}
+
+func (r *Recorder) write(reason string, now time.Time, recs []record) (string, error) {
+	if err := os.MkdirAll(r.dir, 0o755); err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("flight-%s-%s.msgpack", now.UTC().Format("20060102T150405.000Z"), strings.ReplaceAll(reason, "/", "_"))
+	path := filepath.Join(r.dir, name)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return "", err
+	}
+	w := bufio.NewWriter(f)
+	enc := msgpack.NewEncoder(w)
+	enc.SetCustomStructTag("json")
+	enc.UseCompactInts(true)
+	err = enc.Encode(map[string]interface{}{
+		"kind":       "header",
+		"reason":     reason,
+		"dumped_at":  now.UTC().Format(time.RFC3339Nano),
+		"window_sec": r.window.Seconds(),
+		"records":    len(recs),
+	})
+	for i := 0; err == nil && i < len(recs); i++ {
+		rec := recs[i]
+		kind := "event"
+		if rec.decision {
+			kind = "decision"
+		}
+		err = enc.Encode(map[string]interface{}{
+			"kind":    kind,
+			"at":      rec.at.UTC().Format(time.RFC3339Nano),
+			"type":    rec.ev.Type,
+			"symbols": rec.ev.Symbols,
+			"tags":    rec.ev.Tags,
+			"payload": rec.ev.Payload,
+		})
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// Orders wraps an order placer so every submission and refusal is noted in the ring. Returns next itself
+// on a nil Recorder.
+func (r *Recorder) Orders(next alpaca.OrderPlacer) alpaca.OrderPlacer {
+	if r == nil {
+		return next
+	}
+	return &recordedOrders{next: next, r: r}
+}
+
+type recordedOrders struct {
+	next alpaca.OrderPlacer
+	r    *Recorder
+}
+
+// orderDecision is the detail of an order_submitted or order_refused note.
+type orderDecision struct {
+	Request alpaca.OrderRequest `json:"request"`
+	OrderID string              `json:"order_id,omitempty"`
+	Error   string              `json:"error,omitempty"`
+}
+
+func (o *recordedOrders) PlaceOrder(req alpaca.OrderRequest) (*alpaca.Order, error) {
+	order, err := o.next.PlaceOrder(req)
+	if err != nil {
+		o.r.Note("order_refused", orderDecision{Request: req, Error: err.Error()})
+		return order, err
+	}
+	d := orderDecision{Request: req}
+	if order != nil {
+		d.OrderID = order.ID
+	}
+	o.r.Note("order_submitted", d)
+	return order, nil
+}
+
+// CheckOrder keeps the chain's dry-run checks reachable through the wrapper; checks are not recorded.
+func (o *recordedOrders) CheckOrder(req alpaca.OrderRequest) (alpaca.OrderRequest, error) {
+	return alpaca.CheckOrder(o.next, req)
+}
@@ -0,0 +1,209 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/sunnyp94/sentry-bridge/go-engine/events"
+	"github.com/sunnyp94/sentry-bridge/go-engine/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	redisTimeout      = 5 * time.Second
+	redisTrimInterval = time.Minute // how often entries older than Retention are trimmed
+)
+
+// RedisConfig configures the Redis stream sink.
+type RedisConfig struct {
+	Name      string        // sink name in stats and logs; default redis:<stream>
+	URL       string        // redis://[:password@]host:port/db
+	Stream    string        // stream key
+	QueueSize int           // 0 = DefaultQueueSize
+	MaxLen    int64         // approximate cap on stream entries (XADD MAXLEN ~); 0 = unbounded
+	Retention time.Duration // trim entries older than this once a minute (XTRIM MINID ~); 0 = off
+	Batching  Batching      // events per pipeline and how long to wait for a batch to fill
+	Outbox    int           // failed events kept and retried in order once Redis is back; 0 = drop them
+	Snapshots string        // key prefix for per-symbol latest-state hashes (e.g. "snapshot:"); empty = off
+}
+
+// writeDuration is how long a batch takes to reach Redis.
+var writeDuration = telemetry.Histogram("redis.write.duration", "Redis stream write latency per batch")
+
+// redisWriter appends each event to a Redis stream with fields type, symbol, ts, payload (JSON) and, for
+// events that have one, id. A batch is sent as one pipeline, so it costs a single round trip. With a
+// snapshot prefix, trades and quotes also update a hash per symbol (<prefix><SYMBOL>) holding the latest
+// values, so a consumer that joins late reads current state with one HGETALL instead of replaying the
+// stream.
+type redisWriter struct {
+	client    *redis.Client
+	stream    string
+	maxLen    int64
+	snapshots string
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// NewRedis returns the Redis sink. Only a bad URL is an error: when the server is unreachable at startup
+// the sink starts unhealthy, the client reconnects on each write, and events wait in the outbox until
+// Redis is back.
+func NewRedis(cfg RedisConfig) (*Queued, error) {
+	opts, err := redis.ParseURL(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("redis sink: %w", err)
+	}
+	client := redis.NewClient(opts)
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	pingErr := client.Ping(ctx).Err()
+	w := &redisWriter{client: client, stream: cfg.Stream, maxLen: cfg.MaxLen, snapshots: cfg.Snapshots, stop: make(chan struct{}), done: make(chan struct{})}
+	if cfg.Retention > 0 {
+		go w.trimLoop(cfg.Retention)
+	} else {
+		close(w.done)
+	}
+	name := cfg.Name
+	if name == "" {
+		name = NameRedis + ":" + cfg.Stream
+	}
+	q := NewQueuedBatch(name, w, cfg.QueueSize, cfg.Batching)
+	q.SetOutbox(cfg.Outbox)
+	if pingErr != nil {
+		q.unhealthy.Store(true)
+		q.log.Warn("redis unreachable at startup; will keep retrying", "err", pingErr)
+	}
+	return q, nil
+}
+
+// Write sends batch as one pipeline. Events whose payload can't be marshalled are left out and reported
+// as Unencodable so they aren't retried. The write is a client span linked to the events' traces and a
+// redis.write.duration sample.
+func (r *redisWriter) Write(batch []Event) (err error) {
+	start := time.Now()
+	defer func() {
+		ids := make([]string, 0, len(batch))
+		for _, ev := range batch {
+			ids = append(ids, ev.ID)
+		}
+		ms := telemetry.LinkedSpan(ids, "redis XADD "+r.stream, trace.SpanKindClient, start, err, attribute.String("db.system", "redis"),
+			attribute.String("redis.stream", r.stream), attribute.Int("messaging.batch.message_count", len(batch)))
+		writeDuration.Record(context.Background(), ms, metric.WithAttributes(attribute.String("redis.stream", r.stream)))
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	pipe := r.client.Pipeline()
+	var latest map[string]map[string]interface{} // symbol -> snapshot fields; one HSET per symbol per batch
+	var bad *Unencodable
+	for _, ev := range batch {
+		payload, err := json.Marshal(ev.Payload)
+		if err != nil {
+			if bad == nil {
+				bad = &Unencodable{Err: err}
+			}
+			bad.Events++
+			continue
+		}
+		values := []interface{}{"type", ev.Type, "symbol", ev.Key(), "ts", ev.TS, "payload", payload}
+		if ev.ID != "" {
+			values = append(values, "id", ev.ID)
+		}
+		pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: r.stream,
+			MaxLen: r.maxLen,
+			Approx: r.maxLen > 0,
+			Values: values,
+		})
+		if r.snapshots != "" {
+			latest = snapshotFields(latest, ev)
+		}
+	}
+	for sym, fields := range latest {
+		pipe.HSet(ctx, r.snapshots+sym, fields)
+	}
+	if bad != nil && bad.Events == len(batch) {
+		return bad
+	}
+	if _, err = pipe.Exec(ctx); err != nil {
+		return err
+	}
+	if bad != nil {
+		return bad
+	}
+	return nil
+}
+
+// snapshotFields merges the latest-state fields of a trade or quote into m; other events are ignored.
+func snapshotFields(m map[string]map[string]interface{}, ev Event) map[string]map[string]interface{} {
+	var sym string
+	var fields map[string]interface{}
+	switch p := ev.Payload.(type) {
+	case events.TradeEvent:
+		sym = p.Symbol
+		fields = map[string]interface{}{
+			"price": p.Price, "size": p.Size, "volume_1m": p.Volume1m, "volume_5m": p.Volume5m,
+			"return_1m": p.Return1m, "return_5m": p.Return5m, "volatility": p.Volatility, "session": p.Session,
+			"trade_at": ev.TS,
+		}
+	case events.QuoteEvent:
+		sym = p.Symbol
+		fields = map[string]interface{}{
+			"bid": p.Bid, "ask": p.Ask, "bid_size": p.BidSize, "ask_size": p.AskSize, "mid": p.Mid,
+			"spread_bps": p.SpreadBps, "volatility": p.Volatility, "session": p.Session, "quote_at": ev.TS,
+		}
+	default:
+		return m
+	}
+	if sym == "" {
+		return m
+	}
+	if m == nil {
+		m = make(map[string]map[string]interface{})
+	}
+	cur := m[sym]
+	if cur == nil {
+		cur = make(map[string]interface{}, len(fields)+1)
+		m[sym] = cur
+	}
+	for k, v := range fields {
+		cur[k] = v
+	}
+	cur["updated_at"] = ev.TS
+	return m
+}
+
+// trimLoop drops entries older than retention. Stream IDs start with the millisecond timestamp, so the
+// cutoff is a MINID.
+func (r *redisWriter) trimLoop(retention time.Duration) {
+	defer close(r.done)
+	ticker := time.NewTicker(redisTrimInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case now := <-ticker.C:
+			minID := strconv.FormatInt(now.Add(-retention).UnixMilli(), 10)
+			ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+			n, err := r.client.XTrimMinIDApprox(ctx, r.stream, minID, 0).Result()
+			cancel()
+			if err != nil {
+				slog.Warn("redis stream trim failed", "stream", r.stream, "err", err)
+			} else if n > 0 {
+				slog.Debug("redis stream trimmed", "stream", r.stream, "removed", n, "retention", retention)
+			}
+		}
+	}
+}
+
+func (r *redisWriter) Close() error {
+	close(r.stop)
+	<-r.done
+	return r.client.Close()
+}
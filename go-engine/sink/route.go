@@ -0,0 +1,147 @@
+package sink
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Routes is EVENT_ROUTES: for each listed sink (by name or kind, as in EVENT_FILTERS), the event types it
+// receives and a sampling rate for each, N = one event in N (1 = every event). Type "*" covers the types
+// not listed. A sink that isn't listed receives everything.
+type Routes map[string]map[string]int
+
+// ParseRoutes reads EVENT_ROUTES: either inline, sinks separated by ';' and types by ',' with an optional
+// "/N" sample rate,
+//
+//	brain=*;redis=trade,quote/10,news;file=*,quote/100
+//
+// or the path of a JSON file (ending in .json) with the same content as an object:
+//
+//	{"brain": {"*": 1}, "redis": {"trade": 1, "quote": 10, "news": 1}}
+func ParseRoutes(spec string) (Routes, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, nil
+	}
+	routes := make(Routes)
+	if strings.HasSuffix(strings.ToLower(spec), ".json") {
+		b, err := os.ReadFile(spec)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(b, &routes); err != nil {
+			return nil, fmt.Errorf("%s: %w", spec, err)
+		}
+	} else {
+		for _, part := range strings.Split(spec, ";") {
+			if strings.TrimSpace(part) == "" {
+				continue
+			}
+			name, types, ok := strings.Cut(part, "=")
+			name = strings.TrimSpace(name)
+			if !ok || name == "" {
+				return nil, fmt.Errorf("route %q: want sink=type,type/N,...", part)
+			}
+			rates := make(map[string]int)
+			for _, t := range strings.Split(types, ",") {
+				typ, n, sampled := strings.Cut(strings.TrimSpace(t), "/")
+				if typ == "" {
+					continue
+				}
+				rate := 1
+				if sampled {
+					var err error
+					if rate, err = strconv.Atoi(strings.TrimSpace(n)); err != nil {
+						return nil, fmt.Errorf("route %q: sample rate %q is not a number", part, n)
+					}
+				}
+				rates[typ] = rate
+			}
+			routes[name] = rates
+		}
+	}
+	for name, rates := range routes {
+		for typ, n := range rates {
+			if n < 1 {
+				return nil, fmt.Errorf("route %s: sample rate for %s must be at least 1 (one in N)", name, typ)
+			}
+		}
+	}
+	return routes, nil
+}
+
+// For returns the route for a sink known by any of names (nil = everything passes). The most specific
+// entry wins: the exact name, then its kind (the part before the first ':'), then for a DR replica the
+// primary's kind.
+func (r Routes) For(names ...string) map[string]int {
+	keys := make(map[string]map[string]int, len(r))
+	for k, rates := range r {
+		keys[strings.ToLower(strings.TrimSpace(k))] = rates
+	}
+	for _, candidate := range []func(string) string{
+		func(n string) string { return n },
+		func(n string) string { kind, _, _ := strings.Cut(n, ":"); return kind },
+		func(n string) string { kind, _, _ := strings.Cut(n, ":"); return strings.TrimSuffix(kind, "-dr") },
+	} {
+		for _, n := range names {
+			if rates, ok := keys[candidate(strings.ToLower(n))]; ok {
+				return rates
+			}
+		}
+	}
+	return nil
+}
+
+// Routed keeps events not routed to s (EVENT_ROUTES) from reaching it and samples the rest, counting
+// what it holds back as Unrouted in its stats. Events that pass are numbered for s (Sequenced), so held
+// ones leave no gap in its seq. Returns s itself when rates is nil.
+func Routed(s Sink, rates map[string]int) Sink {
+	if rates == nil {
+		return s
+	}
+	return &routed{Sink: Sequenced(s), rates: rates}
+}
+
+type routed struct {
+	Sink
+	rates    map[string]int
+	seen     sync.Map // event type -> *atomic.Uint64, for sampled types
+	unrouted atomic.Uint64
+}
+
+func (s *routed) Publish(ev Event) {
+	n, ok := s.rates[ev.Type]
+	if !ok {
+		n, ok = s.rates["*"]
+	}
+	if !ok || (n > 1 && !s.sample(ev.Type, n)) {
+		s.unrouted.Add(1)
+		return
+	}
+	s.Sink.Publish(ev)
+}
+
+// sample passes the first of every n events of typ.
+func (s *routed) sample(typ string, n int) bool {
+	c, ok := s.seen.Load(typ)
+	if !ok {
+		c, _ = s.seen.LoadOrStore(typ, new(atomic.Uint64))
+	}
+	return (c.(*atomic.Uint64).Add(1)-1)%uint64(n) == 0
+}
+
+func (s *routed) NumbersEvents() {}
+
+func (s *routed) Flush(timeout time.Duration) bool { return Flush(s.Sink, timeout) }
+
+func (s *routed) Stats() Stats {
+	st := s.Sink.Stats()
+	st.Unrouted = s.unrouted.Load()
+	return st
+}
@@ -0,0 +1,60 @@
+package sink
+
+import (
+	"sync"
+	"time"
+)
+
+// Sequencer numbers the events one output receives (Event.Seq): per symbol (the first; account-wide
+// events share one counter), from 1. It counts after filters and routes held events back, so a jump in
+// an output's seq is always a lost event. The zero value is ready to use; don't copy it after use.
+type Sequencer struct {
+	seqs sync.Map // first symbol ("" = account-wide) -> *seqCounter
+}
+
+// seqCounter numbers one symbol's events. mu is held while the output takes the event, so it sees a
+// symbol's events in seq order.
+type seqCounter struct {
+	mu sync.Mutex
+	n  uint64
+}
+
+// Publish numbers ev and hands it to publish.
+func (q *Sequencer) Publish(ev Event, publish func(Event)) {
+	v, ok := q.seqs.Load(ev.Key())
+	if !ok {
+		v, _ = q.seqs.LoadOrStore(ev.Key(), &seqCounter{})
+	}
+	c := v.(*seqCounter)
+	c.mu.Lock()
+	c.n++
+	ev.Seq = c.n
+	publish(ev)
+	c.mu.Unlock()
+}
+
+// Sequencing is implemented by sinks that number the events they deliver themselves, after deciding
+// which to hold back (Filtered, Routed, the brain router). The dispatcher numbers events for the others.
+type Sequencing interface {
+	Sink
+	NumbersEvents()
+}
+
+// Sequenced numbers the events s receives with its own Sequencer. Returns s itself when it already does.
+func Sequenced(s Sink) Sink {
+	if _, ok := s.(Sequencing); ok {
+		return s
+	}
+	return &sequenced{Sink: s}
+}
+
+type sequenced struct {
+	Sink
+	q Sequencer
+}
+
+func (s *sequenced) Publish(ev Event) { s.q.Publish(ev, s.Sink.Publish) }
+
+func (s *sequenced) NumbersEvents() {}
+
+func (s *sequenced) Flush(timeout time.Duration) bool { return Flush(s.Sink, timeout) }
@@ -0,0 +1,68 @@
+// Package sink fans the engine's event stream out to every configured output: the brain, and consumers
+// outside the trading path (Redis, Kafka, an event file, WebSocket subscribers). Sinks never block the caller: each has its own
+// bounded queue and drops the oldest events when it falls behind, so a slow output only affects itself.
+package sink
+
+import (
+	"time"
+
+	"github.com/sunnyp94/sentry-bridge/go-engine/events"
+)
+
+// Sink names (SINKS).
+const (
+	NameBrain     = "brain"
+	NameRedis     = "redis"
+	NameKafka     = "kafka"
+	NameFile      = "file"
+	NameWebSocket = "websocket"
+)
+
+// Event is one engine event on its way to the sinks.
+type Event struct {
+	Symbols  []string  // symbols the event belongs to (a news article may have several); empty = account-wide
+	Deadline time.Time // end of the event's TTL (Envelope.Expires); zero = never expires
+	events.Envelope
+
+	vars *activation // CEL variables for filters, shared by every sink the event goes to
+}
+
+// Key is the partition key: the first symbol, "" for account-wide events.
+func (e Event) Key() string {
+	if len(e.Symbols) == 0 {
+		return ""
+	}
+	return e.Symbols[0]
+}
+
+// Expired reports whether ev outlived its TTL by now. Each stage that holds events (sink queues, outboxes,
+// brain pipes) checks it before delivering, so after a stall consumers get current data instead of a
+// backlog of stale ticks.
+func (e Event) Expired(now time.Time) bool {
+	return !e.Deadline.IsZero() && now.After(e.Deadline)
+}
+
+// Sink is one output of the event stream. Publish must not block.
+type Sink interface {
+	Name() string
+	Publish(ev Event)
+	Stats() Stats
+	Close() error
+}
+
+// Flusher is implemented by sinks that write in the background (Queued, and the wrappers around one):
+// Flush returns once the events published before it are written, or after timeout, and reports which.
+type Flusher interface {
+	Flush(timeout time.Duration) bool
+}
+
+// Flush flushes s if it is a Flusher; other sinks have already handed off what they were given.
+func Flush(s Sink, timeout time.Duration) bool {
+	if f, ok := s.(Flusher); ok {
+		return f.Flush(timeout)
+	}
+	return true
+}
+
+// Stats are cumulative counters and health for one sink.
+type Stats = events.SinkStats
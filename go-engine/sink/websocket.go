@@ -0,0 +1,457 @@
+package sink
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	wsPingInterval = 30 * time.Second // keeps idle connections (and proxies) alive
+	wsWriteTimeout = 10 * time.Second // a client that can't take a frame in this long is disconnected
+)
+
+// WebSocket re-broadcasts the event stream to local subscribers (dashboards, secondary tools) without a
+// Redis hop, as WebSocket messages on any path or as Server-Sent Events on /events (for browsers and
+// curl). Each connection picks what it gets with ?symbols=AAPL,MSFT&types=trade,quote (empty = all); a
+// WebSocket client can replace its filter later by sending {"symbols":[...],"types":[...]}. The symbol
+// filter only applies to symbol events; account-wide events (positions, orders, ...) pass it. A client
+// that falls behind loses its own oldest events.
+//
+// With a token, a client must present it as an Authorization: Bearer header or a ?token= parameter
+// (browsers can't set headers on a WebSocket). A browser connection is accepted from the server's own
+// origin and the allowed origins only, so an arbitrary web page can't open the feed.
+type WebSocket struct {
+	addr      string
+	srv       *http.Server
+	queueSize int
+	token     string
+	origins   map[string]bool
+	upgrader  websocket.Upgrader
+	log       *slog.Logger
+
+	mu      sync.Mutex
+	clients map[*subscriber]struct{}
+	closed  bool
+
+	published atomic.Uint64
+	dropped   atomic.Uint64
+	errors    atomic.Uint64
+	expired   atomic.Uint64
+}
+
+// frame is one encoded event in a subscriber's queue.
+type frame struct {
+	b        []byte
+	deadline time.Time // zero = never expires
+}
+
+// subscriber is one connection with its filter and send queue.
+type subscriber struct {
+	conn  *websocket.Conn // nil for SSE
+	send  chan frame
+	done  chan struct{}
+	once  sync.Once
+	mu    sync.RWMutex
+	syms  map[string]bool // nil = every symbol
+	types map[string]bool // nil = every type
+}
+
+// wsFilter is the message a client sends to change its subscription.
+type wsFilter struct {
+	Symbols []string `json:"symbols"`
+	Types   []string `json:"types"`
+}
+
+// WebSocketConfig configures the WebSocket sink.
+type WebSocketConfig struct {
+	Addr      string   // listen address, e.g. 127.0.0.1:8765
+	QueueSize int      // each client's buffer; 0 = DefaultQueueSize
+	Token     string   // required from clients when set; a non-loopback Addr needs one
+	Origins   []string // browser origins allowed besides the server's own, e.g. https://dash.example.com
+}
+
+// NewWebSocket listens on cfg.Addr and serves WebSocket subscribers at any path and SSE ones at /events.
+// It refuses to listen beyond loopback without a token.
+func NewWebSocket(cfg WebSocketConfig) (*WebSocket, error) {
+	if cfg.Token == "" && !loopback(cfg.Addr) {
+		return nil, fmt.Errorf("websocket sink: %s is not a loopback address; set a token (WS_TOKEN)", cfg.Addr)
+	}
+	queueSize := cfg.QueueSize
+	if queueSize <= 0 {
+		queueSize = DefaultQueueSize
+	}
+	lis, err := net.Listen("tcp", cfg.Addr)
+	if err != nil {
+		return nil, err
+	}
+	ws := &WebSocket{
+		addr:      lis.Addr().String(),
+		queueSize: queueSize,
+		token:     cfg.Token,
+		origins:   make(map[string]bool),
+		clients:   make(map[*subscriber]struct{}),
+	}
+	for _, o := range cfg.Origins {
+		ws.origins[strings.TrimRight(strings.ToLower(o), "/")] = true
+	}
+	ws.upgrader = websocket.Upgrader{CheckOrigin: ws.originAllowed}
+	ws.log = slog.Default().With("sink", ws.Name())
+	ws.srv = &http.Server{Handler: http.HandlerFunc(ws.serve), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := ws.srv.Serve(lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			ws.log.Error("websocket server stopped", "err", err)
+		}
+	}()
+	return ws, nil
+}
+
+// Name identifies the sink in stats and logs.
+func (ws *WebSocket) Name() string { return NameWebSocket + ":" + ws.addr }
+
+// Addr is the address the server listens on.
+func (ws *WebSocket) Addr() string { return ws.addr }
+
+// Clients returns the number of connected subscribers.
+func (ws *WebSocket) Clients() int {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	return len(ws.clients)
+}
+
+// originAllowed accepts requests without an Origin (non-browser clients), from the server's own origin,
+// and from the configured origins.
+func (ws *WebSocket) originAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	return ws.origins[strings.TrimRight(strings.ToLower(origin), "/")]
+}
+
+// authorized checks the token from the Authorization header or the token parameter.
+func (ws *WebSocket) authorized(r *http.Request) bool {
+	if ws.token == "" {
+		return true
+	}
+	got := r.URL.Query().Get("token")
+	if h := r.Header.Get("Authorization"); h != "" {
+		got = strings.TrimPrefix(h, "Bearer ")
+	}
+	return subtle.ConstantTimeCompare([]byte(got), []byte(ws.token)) == 1
+}
+
+func (ws *WebSocket) serve(w http.ResponseWriter, r *http.Request) {
+	if !websocket.IsWebSocketUpgrade(r) {
+		if r.URL.Path == "/events" {
+			ws.serveSSE(w, r)
+			return
+		}
+		http.NotFound(w, r)
+		return
+	}
+	if !ws.authorized(r) {
+		http.Error(w, "bad or missing token", http.StatusUnauthorized)
+		return
+	}
+	conn, err := ws.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return // Upgrade already replied with the error
+	}
+	c := ws.add(conn, r)
+	if c == nil {
+		conn.Close()
+		return
+	}
+	go ws.writeLoop(c)
+	ws.readLoop(c)
+	ws.remove(c, r)
+}
+
+// serveSSE streams matching events as "data: <envelope>" messages until the client goes away. It takes
+// the same token as the WebSocket, and a cross-origin browser request only gets a CORS grant for an
+// allowed origin.
+func (ws *WebSocket) serveSSE(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	if !ws.originAllowed(r) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+	if !ws.authorized(r) {
+		http.Error(w, "bad or missing token", http.StatusUnauthorized)
+		return
+	}
+	c := ws.add(nil, r)
+	if c == nil {
+		http.Error(w, "shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	defer ws.remove(c, r)
+	defer c.close()
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	if origin := r.Header.Get("Origin"); origin != "" {
+		h.Set("Access-Control-Allow-Origin", origin)
+		h.Set("Vary", "Origin")
+	}
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	rc := http.NewResponseController(w)
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+	for {
+		var err error
+		select {
+		case <-r.Context().Done():
+			return
+		case <-c.done:
+			return
+		case f := <-c.send:
+			if ws.stale(f) {
+				continue
+			}
+			_ = rc.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if _, err = fmt.Fprintf(w, "data: %s\n\n", f.b); err != nil {
+				ws.errors.Add(1)
+				return
+			}
+			ws.published.Add(1)
+		case <-ping.C:
+			_ = rc.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			_, err = io.WriteString(w, ": ping\n\n")
+		}
+		if err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// add registers a subscriber with the request's filter; nil once the sink is closed.
+func (ws *WebSocket) add(conn *websocket.Conn, r *http.Request) *subscriber {
+	c := &subscriber{conn: conn, send: make(chan frame, ws.queueSize), done: make(chan struct{})}
+	q := r.URL.Query()
+	c.setFilter(wsFilter{Symbols: splitList(q.Get("symbols")), Types: splitList(q.Get("types"))})
+	ws.mu.Lock()
+	if ws.closed {
+		ws.mu.Unlock()
+		return nil
+	}
+	ws.clients[c] = struct{}{}
+	n := len(ws.clients)
+	ws.mu.Unlock()
+	ws.log.Info("event subscriber connected", "remote", r.RemoteAddr, "sse", conn == nil, "symbols", q.Get("symbols"), "types", q.Get("types"), "clients", n)
+	return c
+}
+
+func (ws *WebSocket) remove(c *subscriber, r *http.Request) {
+	ws.mu.Lock()
+	delete(ws.clients, c)
+	ws.mu.Unlock()
+	ws.log.Info("event subscriber disconnected", "remote", r.RemoteAddr, "sse", c.conn == nil)
+}
+
+// readLoop applies filter updates until the client goes away.
+func (ws *WebSocket) readLoop(c *subscriber) {
+	defer c.close()
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var f wsFilter
+		if err := json.Unmarshal(data, &f); err != nil {
+			ws.log.Debug("websocket subscriber sent an invalid filter", "err", err)
+			continue
+		}
+		c.setFilter(f)
+	}
+}
+
+// writeLoop sends queued events and keepalive pings.
+func (ws *WebSocket) writeLoop(c *subscriber) {
+	defer c.close()
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case <-c.done:
+			_ = c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, ""), time.Now().Add(time.Second))
+			return
+		case f := <-c.send:
+			if ws.stale(f) {
+				continue
+			}
+			_ = c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if err := c.conn.WriteMessage(websocket.TextMessage, f.b); err != nil {
+				ws.errors.Add(1)
+				return
+			}
+			ws.published.Add(1)
+		case <-ping.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Publish queues ev for every subscriber whose filter matches. The envelope is encoded once.
+func (ws *WebSocket) Publish(ev Event) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	var b []byte
+	for c := range ws.clients {
+		if !c.wants(ev) {
+			continue
+		}
+		if b == nil {
+			var err error
+			if b, err = json.Marshal(ev.Envelope); err != nil {
+				ws.errors.Add(1)
+				return
+			}
+		}
+		for {
+			select {
+			case c.send <- frame{b: b, deadline: ev.Deadline}:
+			default:
+				select {
+				case <-c.send:
+					ws.dropped.Add(1)
+				default:
+				}
+				continue
+			}
+			break
+		}
+	}
+}
+
+// stale reports (and counts) a frame that outlived its TTL in a subscriber's queue.
+func (ws *WebSocket) stale(f frame) bool {
+	if f.deadline.IsZero() || !time.Now().After(f.deadline) {
+		return false
+	}
+	ws.expired.Add(1)
+	return true
+}
+
+// Stats counts frames written to subscribers, frames dropped for slow ones or past their TTL, and failed
+// writes.
+func (ws *WebSocket) Stats() Stats {
+	return Stats{Published: ws.published.Load(), Dropped: ws.dropped.Load(), Errors: ws.errors.Load(), Healthy: true, Expired: ws.expired.Load()}
+}
+
+// Close stops the server and disconnects every subscriber.
+func (ws *WebSocket) Close() error {
+	ws.mu.Lock()
+	ws.closed = true
+	for c := range ws.clients {
+		c.close()
+	}
+	ws.mu.Unlock()
+	return ws.srv.Close()
+}
+
+func (c *subscriber) close() {
+	c.once.Do(func() {
+		close(c.done)
+		if c.conn != nil {
+			// Unblocks the read loop; the write loop sends a close frame first when it can.
+			time.AfterFunc(time.Second, func() { c.conn.Close() })
+		}
+	})
+}
+
+func (c *subscriber) setFilter(f wsFilter) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.syms, c.types = toSet(f.Symbols, strings.ToUpper), toSet(f.Types, strings.ToLower)
+}
+
+// wants reports whether ev passes the client's filter.
+func (c *subscriber) wants(ev Event) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.types != nil && !c.types[ev.Type] {
+		return false
+	}
+	if c.syms == nil || len(ev.Symbols) == 0 {
+		return true
+	}
+	for _, s := range ev.Symbols {
+		if c.syms[strings.ToUpper(s)] {
+			return true
+		}
+	}
+	return false
+}
+
+// splitList splits a comma-separated query value, skipping blanks.
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// toSet builds a normalized set; nil when list is empty (no filter).
+func toSet(list []string, norm func(string) string) map[string]bool {
+	if len(list) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(list))
+	for _, v := range list {
+		if v = strings.TrimSpace(v); v != "" {
+			set[norm(v)] = true
+		}
+	}
+	if len(set) == 0 {
+		return nil
+	}
+	return set
+}
+
+// loopback reports whether addr (host:port) only listens on the local machine. An empty host is every
+// interface.
+func loopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil || host == "" {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
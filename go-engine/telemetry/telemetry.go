@@ -0,0 +1,254 @@
+// Package telemetry exports the engine's OpenTelemetry traces and metrics over OTLP: REST calls,
+// WebSocket message handling, brain pipe sends and Redis writes, each a span and a latency histogram, so
+// the engine's part of a tick's or an order's path shows up in an existing tracing stack.
+//
+// Instrumented code records against the global providers, which do nothing until Setup installs the
+// exporters. A program embedding the engine can install its own providers instead.
+//
+// Spans about one event (a brain pipe send, a Redis write carrying it) belong to a trace whose ID is
+// derived from the event ID, so they can be found together. Per-message and per-event spans are the hot
+// path and are sampled (Config.HotSampleRatio); the histograms count every operation.
+package telemetry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// scope names the engine's tracer and meter.
+const scope = "github.com/sunnyp94/sentry-bridge/go-engine"
+
+// serviceName is the service.name of the engine's telemetry unless OTEL_SERVICE_NAME says otherwise.
+const serviceName = "sentry-bridge-engine"
+
+// Config says where telemetry goes. The exporters and SDK also read the standard OTEL_* variables
+// (OTEL_EXPORTER_OTLP_HEADERS, OTEL_EXPORTER_OTLP_INSECURE, OTEL_TRACES_SAMPLER,
+// OTEL_METRIC_EXPORT_INTERVAL, OTEL_RESOURCE_ATTRIBUTES, ...).
+type Config struct {
+	Endpoint       string  // OTLP collector URL, e.g. http://localhost:4317 (grpc) or http://localhost:4318 (http/protobuf); empty = off
+	Protocol       string  // "grpc" (default) or "http/protobuf"
+	HotSampleRatio float64 // share of WebSocket message and per-event spans exported, 0 to 1; REST and Redis write spans are all kept
+}
+
+// installed is the exporting setup of this process. The global instruments bind to the first providers
+// installed (later ones are ignored), so they are installed once and every later Setup reuses them.
+var installed struct {
+	sync.Mutex
+	cfg   *Config
+	flush func(context.Context) error
+}
+
+// hotRatio is math.Float64bits of the hot-path span sampling ratio; all are kept until Setup sets it, so
+// an embedding program's own providers and sampler see every span.
+var hotRatio atomic.Uint64
+
+func init() { hotRatio.Store(math.Float64bits(1)) }
+
+func setHotRatio(r float64) { hotRatio.Store(math.Float64bits(math.Max(0, math.Min(1, r)))) }
+
+// Setup installs OTLP trace and metric exporters as the global providers and returns the function that
+// flushes them. The providers stay installed for the life of the process: a later Setup with the same
+// endpoint and protocol reuses them, a different one is an error. With no endpoint it installs nothing
+// and the returned function does nothing.
+func Setup(ctx context.Context, cfg Config) (flush func(context.Context) error, err error) {
+	flush = func(context.Context) error { return nil }
+	if cfg.Endpoint == "" {
+		return flush, nil
+	}
+	installed.Lock()
+	defer installed.Unlock()
+	if c := installed.cfg; c != nil {
+		if c.Endpoint != cfg.Endpoint || c.Protocol != cfg.Protocol {
+			return flush, fmt.Errorf("telemetry: already exporting to %s (%s)", c.Endpoint, c.Protocol)
+		}
+		setHotRatio(cfg.HotSampleRatio)
+		return installed.flush, nil
+	}
+	// Later options win: OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the default name
+	res, err := resource.New(ctx, resource.WithAttributes(semconv.ServiceName(serviceName)), resource.WithFromEnv(),
+		resource.WithTelemetrySDK(), resource.WithHost(), resource.WithProcessPID())
+	if err != nil {
+		return flush, fmt.Errorf("telemetry: resource: %w", err)
+	}
+	spans, metrics, err := exporters(ctx, cfg)
+	if err != nil {
+		return flush, err
+	}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(spans), sdktrace.WithResource(res))
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metrics)), sdkmetric.WithResource(res))
+	otel.SetTracerProvider(tp)
+	otel.SetMeterProvider(mp)
+	setHotRatio(cfg.HotSampleRatio)
+	installed.cfg = &cfg
+	installed.flush = func(ctx context.Context) error {
+		return errors.Join(tp.ForceFlush(ctx), mp.ForceFlush(ctx))
+	}
+	return installed.flush, nil
+}
+
+// exporters builds the span and metric exporters for cfg's protocol. Over HTTP the endpoint is a base
+// URL and each signal gets its standard path, as with OTEL_EXPORTER_OTLP_ENDPOINT.
+func exporters(ctx context.Context, cfg Config) (sdktrace.SpanExporter, sdkmetric.Exporter, error) {
+	var spans sdktrace.SpanExporter
+	var metrics sdkmetric.Exporter
+	var err error
+	switch p := strings.ToLower(strings.TrimSpace(cfg.Protocol)); p {
+	case "", "grpc":
+		if spans, err = otlptracegrpc.New(ctx, otlptracegrpc.WithEndpointURL(cfg.Endpoint)); err != nil {
+			return nil, nil, fmt.Errorf("telemetry: trace exporter: %w", err)
+		}
+		metrics, err = otlpmetricgrpc.New(ctx, otlpmetricgrpc.WithEndpointURL(cfg.Endpoint))
+	case "http/protobuf", "http":
+		base := strings.TrimRight(cfg.Endpoint, "/")
+		if spans, err = otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(base+"/v1/traces")); err != nil {
+			return nil, nil, fmt.Errorf("telemetry: trace exporter: %w", err)
+		}
+		metrics, err = otlpmetrichttp.New(ctx, otlpmetrichttp.WithEndpointURL(base+"/v1/metrics"))
+	default:
+		return nil, nil, fmt.Errorf("telemetry: unknown OTLP protocol %q (grpc or http/protobuf)", p)
+	}
+	if err != nil {
+		_ = spans.Shutdown(ctx)
+		return nil, nil, fmt.Errorf("telemetry: metric exporter: %w", err)
+	}
+	return spans, metrics, nil
+}
+
+// Histogram returns a latency histogram in milliseconds on the engine's meter. Instruments made before
+// Setup record to the providers it installs.
+func Histogram(name, description string) metric.Float64Histogram {
+	h, err := otel.Meter(scope).Float64Histogram(name, metric.WithDescription(description), metric.WithUnit("ms"))
+	if err != nil {
+		otel.Handle(err)
+	}
+	if h == nil {
+		return noop.Float64Histogram{}
+	}
+	return h
+}
+
+// Span records a finished operation that began at start as a span ending now, marked failed when err is
+// set, and returns its duration in milliseconds for a histogram. Recording after the fact keeps the
+// instrumented code free of contexts it doesn't otherwise carry.
+func Span(name string, kind trace.SpanKind, start time.Time, err error, attrs ...attribute.KeyValue) float64 {
+	return record(context.Background(), name, kind, start, err, nil, attrs)
+}
+
+// HotSpan is Span for an operation on the hot path, recorded for a HotSampleRatio share of calls only.
+func HotSpan(name string, kind trace.SpanKind, start time.Time, err error, attrs ...attribute.KeyValue) float64 {
+	if r := math.Float64frombits(hotRatio.Load()); r < 1 && rand.Float64() >= r {
+		return float64(time.Since(start)) / float64(time.Millisecond)
+	}
+	return Span(name, kind, start, err, attrs...)
+}
+
+// EventSpan is Span for an operation on one event: the span joins the event's trace (see EventContext),
+// which is kept or dropped whole.
+func EventSpan(eventID, name string, kind trace.SpanKind, start time.Time, err error, attrs ...attribute.KeyValue) float64 {
+	ctx := context.Background()
+	if eventID != "" {
+		ctx = trace.ContextWithRemoteSpanContext(ctx, EventContext(eventID))
+	}
+	return record(ctx, name, kind, start, err, nil, attrs)
+}
+
+// LinkedSpan is Span for an operation on many events at once (a batch write): the span links to the
+// traces of the sampled events among them.
+func LinkedSpan(eventIDs []string, name string, kind trace.SpanKind, start time.Time, err error, attrs ...attribute.KeyValue) float64 {
+	var links []trace.Link
+	for _, id := range eventIDs {
+		if len(links) == maxLinks {
+			break
+		}
+		if sc := EventContext(id); id != "" && sc.IsSampled() {
+			links = append(links, trace.Link{SpanContext: sc})
+		}
+	}
+	return record(context.Background(), name, kind, start, err, links, attrs)
+}
+
+// maxLinks is the SDK's default cap on links per span.
+const maxLinks = 128
+
+// EventContext is the span context standing for the event with this ID: the trace ID and span ID are
+// derived from the ID, so every process that knows the ID can join the trace, and it is sampled for a
+// HotSampleRatio share of IDs. No span is exported for it; it is the parent of the event's spans.
+func EventContext(eventID string) trace.SpanContext {
+	sum := sha256.Sum256([]byte(eventID))
+	var tid trace.TraceID
+	var sid trace.SpanID
+	copy(tid[:], sum[:16])
+	copy(sid[:], sum[16:24])
+	var flags trace.TraceFlags
+	if r := math.Float64frombits(hotRatio.Load()); float64(binary.BigEndian.Uint64(sum[24:]))/math.MaxUint64 < r {
+		flags = trace.FlagsSampled
+	}
+	return trace.NewSpanContext(trace.SpanContextConfig{TraceID: tid, SpanID: sid, TraceFlags: flags, Remote: true})
+}
+
+// record starts and ends the span for Span and its variants.
+func record(ctx context.Context, name string, kind trace.SpanKind, start time.Time, err error, links []trace.Link, attrs []attribute.KeyValue) float64 {
+	end := time.Now()
+	_, span := otel.Tracer(scope).Start(ctx, name, trace.WithTimestamp(start), trace.WithSpanKind(kind),
+		trace.WithAttributes(attrs...), trace.WithLinks(links...))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End(trace.WithTimestamp(end))
+	return float64(end.Sub(start)) / float64(time.Millisecond)
+}
+
+// restDuration is the latency of REST calls, retries and rate limit waits included.
+var restDuration = Histogram("rest.client.duration", "REST call latency, retries included")
+
+// REST records a REST call to api ("alpaca.data", "alpaca.trading", "ibkr") that began at start: a
+// client span and a rest.client.duration sample. status is 0 when no response arrived (err says why);
+// a 4xx or 5xx status fails the span too.
+func REST(api, method, path string, start time.Time, status, resends int, err error) {
+	failed := err
+	if failed == nil && status >= 400 {
+		failed = errors.New("status " + strconv.Itoa(status))
+	}
+	ms := Span(api+" "+method, trace.SpanKindClient, start, failed, attribute.String("http.request.method", method),
+		attribute.String("url.path", path), attribute.Int("http.response.status_code", status),
+		attribute.Int("http.request.resend_count", resends))
+	restDuration.Record(context.Background(), ms, metric.WithAttributes(attribute.String("api", api),
+		attribute.String("http.request.method", method), attribute.Int("http.response.status_code", status)))
+}
+
+// messageDuration is how long handling one WebSocket message took, its callbacks included.
+var messageDuration = Histogram("ws.message.duration", "WebSocket message handling latency, callbacks included")
+
+// Message records the handling of one WebSocket message from stream ("alpaca.stocks", "alpaca.news",
+// ...) read at received: a consumer span and a ws.message.duration sample.
+func Message(stream string, received time.Time, err error) {
+	ms := HotSpan(stream+" message", trace.SpanKindConsumer, received, err, attribute.String("messaging.system", "websocket"),
+		attribute.String("messaging.destination.name", stream))
+	messageDuration.Record(context.Background(), ms, metric.WithAttributes(attribute.String("stream", stream)))
+}
@@ -0,0 +1,211 @@
+// Package universe changes the set of streamed symbols during the session: symbols that show up in
+// breaking news (or other anomaly sources) are added for a trial window, so movers outside the morning
+// list aren't missed.
+package universe
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sunnyp94/sentry-bridge/go-engine/alpaca"
+	"github.com/sunnyp94/sentry-bridge/go-engine/clock"
+	"github.com/sunnyp94/sentry-bridge/go-engine/events"
+)
+
+// recheckAfter is how long a symbol that failed the filters is left alone before it is looked at again.
+const recheckAfter = 15 * time.Minute
+
+// Stream is the price stream whose subscription is extended (alpaca.PriceStream).
+type Stream interface {
+	Symbols() []string
+	Subscribe(symbols []string) error
+	Unsubscribe(symbols []string) error
+}
+
+// SnapshotFunc returns the latest trade and daily bar per symbol (alpaca.Client.GetSnapshots).
+type SnapshotFunc func(symbols []string) (map[string]alpaca.SnapshotData, error)
+
+// Config holds the filters a candidate must pass and the trial rules.
+type Config struct {
+	MinPrice  float64       // Last trade at or above this price
+	MinVolume uint64        // Shares traded today at or above this
+	Trial     time.Duration // How long an added symbol stays after its last mention
+	MaxTrials int           // Symbols on trial at once; 0 = no limit
+}
+
+// Expander adds mentioned symbols that pass the filters to the stream and drops them again when their
+// trial ends, unless Keep says they are still needed (e.g. a position or open order).
+type Expander struct {
+	cfg       Config
+	stream    Stream
+	snapshots SnapshotFunc
+	clock     clock.Clock
+
+	// Keep reports whether an expiring symbol must stay subscribed. Optional.
+	Keep func(symbol string) bool
+	// OnChange receives a universe event after every addition or removal. Optional.
+	OnChange func(events.UniverseEvent)
+
+	mu       sync.Mutex
+	trials   map[string]time.Time // symbol -> trial end
+	rejected map[string]time.Time // symbol -> when it failed the filters
+	checking map[string]bool      // snapshot lookup in flight
+}
+
+// NewExpander extends stream with symbols whose snapshots pass cfg.
+func NewExpander(cfg Config, stream Stream, snapshots SnapshotFunc) *Expander {
+	return &Expander{
+		cfg:       cfg,
+		stream:    stream,
+		snapshots: snapshots,
+		clock:     clock.Real{},
+		trials:    make(map[string]time.Time),
+		rejected:  make(map[string]time.Time),
+		checking:  make(map[string]bool),
+	}
+}
+
+// SetClock replaces the clock for trial windows. Call before use.
+func (x *Expander) SetClock(c clock.Clock) { x.clock = c }
+
+// Consider looks at the symbols of a news article or anomaly. Symbols on trial get a fresh window; new
+// ones are checked against the filters in the background (one snapshot request) and added if they pass.
+func (x *Expander) Consider(symbols []string, reason string) {
+	active := make(map[string]bool)
+	for _, s := range x.stream.Symbols() {
+		active[strings.ToUpper(s)] = true
+	}
+	now := x.clock.Now()
+	var candidates []string
+	x.mu.Lock()
+	for _, s := range symbols {
+		s = strings.ToUpper(strings.TrimSpace(s))
+		if s == "" {
+			continue
+		}
+		if _, ok := x.trials[s]; ok {
+			x.trials[s] = now.Add(x.cfg.Trial)
+			continue
+		}
+		if active[s] || x.checking[s] {
+			continue
+		}
+		if at, ok := x.rejected[s]; ok && now.Sub(at) < recheckAfter {
+			continue
+		}
+		x.checking[s] = true
+		candidates = append(candidates, s)
+	}
+	x.mu.Unlock()
+	if len(candidates) > 0 {
+		go x.check(candidates, reason)
+	}
+}
+
+// check fetches snapshots for candidates and subscribes the ones that pass.
+func (x *Expander) check(candidates []string, reason string) {
+	snaps, err := x.snapshots(candidates)
+	now := x.clock.Now()
+	x.mu.Lock()
+	for _, s := range candidates {
+		delete(x.checking, s)
+	}
+	if err != nil {
+		x.mu.Unlock()
+		slog.Warn("universe expansion: snapshot failed", "symbols", candidates, "err", err)
+		return
+	}
+	var added []string
+	for _, s := range candidates {
+		if ok, why := passes(snaps[s], x.cfg.MinPrice, x.cfg.MinVolume); !ok {
+			x.rejected[s] = now
+			slog.Debug("universe expansion: candidate filtered", "symbol", s, "reason", why)
+			continue
+		}
+		if x.cfg.MaxTrials > 0 && len(x.trials) >= x.cfg.MaxTrials {
+			slog.Info("universe expansion: trial limit reached", "symbol", s, "max", x.cfg.MaxTrials)
+			continue
+		}
+		x.trials[s] = now.Add(x.cfg.Trial)
+		added = append(added, s)
+	}
+	x.mu.Unlock()
+	if len(added) == 0 {
+		return
+	}
+	if err := x.stream.Subscribe(added); err != nil {
+		slog.Warn("universe expansion: subscribe failed; symbols are added on reconnect", "err", err)
+	}
+	slog.Info("universe expanded", "added", added, "reason", reason, "trial", x.cfg.Trial)
+	x.changed(added, nil, reason)
+}
+
+// passes applies the price and volume filters to a snapshot.
+func passes(snap alpaca.SnapshotData, minPrice float64, minVolume uint64) (bool, string) {
+	if snap.LatestTrade == nil || snap.LatestTrade.Price <= 0 {
+		return false, "no trade"
+	}
+	if snap.LatestTrade.Price < minPrice {
+		return false, "price"
+	}
+	if minVolume > 0 && (snap.DailyBar == nil || snap.DailyBar.Volume < minVolume) {
+		return false, "volume"
+	}
+	return true, ""
+}
+
+// Run ends expired trials once a minute until ctx is done.
+func (x *Expander) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			x.expire(x.clock.Now())
+		}
+	}
+}
+
+// expire unsubscribes symbols whose trial ended, keeping those Keep still needs for another window.
+func (x *Expander) expire(now time.Time) {
+	var removed []string
+	x.mu.Lock()
+	for s, end := range x.trials {
+		if now.Before(end) {
+			continue
+		}
+		if x.Keep != nil && x.Keep(s) {
+			x.trials[s] = now.Add(x.cfg.Trial)
+			continue
+		}
+		delete(x.trials, s)
+		removed = append(removed, s)
+	}
+	for s, at := range x.rejected {
+		if now.Sub(at) >= recheckAfter {
+			delete(x.rejected, s)
+		}
+	}
+	x.mu.Unlock()
+	if len(removed) == 0 {
+		return
+	}
+	sort.Strings(removed)
+	if err := x.stream.Unsubscribe(removed); err != nil {
+		slog.Warn("universe trial end: unsubscribe failed; symbols drop on reconnect", "err", err)
+	}
+	slog.Info("universe trial ended", "removed", removed)
+	x.changed(nil, removed, "trial_expired")
+}
+
+func (x *Expander) changed(added, removed []string, reason string) {
+	if x.OnChange != nil {
+		x.OnChange(events.UniverseEvent{Symbols: x.stream.Symbols(), Added: added, Removed: removed, Reason: reason})
+	}
+}
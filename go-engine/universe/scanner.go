@@ -0,0 +1,254 @@
+package universe
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sunnyp94/sentry-bridge/go-engine/alpaca"
+	"github.com/sunnyp94/sentry-bridge/go-engine/events"
+)
+
+// Screener returns the market-wide screens the scanner draws candidates from (alpaca.Client).
+type Screener interface {
+	GetMovers(top int) (*alpaca.Movers, error)
+	GetMostActives(by string, top int) ([]alpaca.MostActive, error)
+}
+
+// ScannerConfig holds the scanner's schedule, filters and cap.
+type ScannerConfig struct {
+	Interval   time.Duration // Time between scans
+	Top        int           // Symbols taken from each screen (gainers, losers, most active)
+	MaxSymbols int           // Scanned symbols streamed at once, on top of the configured ones
+	MinPrice   float64       // Last trade at or above this price
+	MinVolume  uint64        // Shares traded today at or above this
+}
+
+// Scanner streams the strongest symbols of the movers and most-actives screens next to the configured
+// ones. Each scan ranks the candidates that pass the filters by today's volume and price range and keeps
+// the best MaxSymbols subscribed: new ones are added, scanned symbols that dropped out are removed
+// unless Keep says they are still needed. Symbols it didn't add itself are never removed.
+type Scanner struct {
+	cfg       ScannerConfig
+	screener  Screener
+	stream    Stream
+	snapshots SnapshotFunc
+
+	// Keep reports whether a scanned symbol that fell out of the ranking must stay subscribed. Optional.
+	Keep func(symbol string) bool
+	// OnChange receives a universe event after every scan that changed the stream. Optional.
+	OnChange func(events.UniverseEvent)
+
+	mu    sync.Mutex
+	owned map[string]bool // symbols the scanner subscribed
+}
+
+// NewScanner scans screener every cfg.Interval and adjusts stream; snapshots supply volume and range.
+func NewScanner(cfg ScannerConfig, screener Screener, stream Stream, snapshots SnapshotFunc) *Scanner {
+	return &Scanner{cfg: cfg, screener: screener, stream: stream, snapshots: snapshots, owned: make(map[string]bool)}
+}
+
+// scanCandidate is a screened symbol with the two ranking inputs.
+type scanCandidate struct {
+	symbol string
+	volume float64
+	rng    float64 // today's high-low range (or move since the previous close, if larger) over the previous close
+	score  float64
+}
+
+// Scan runs one scan.
+func (s *Scanner) Scan() {
+	active := make(map[string]bool)
+	for _, sym := range s.stream.Symbols() {
+		active[strings.ToUpper(sym)] = true
+	}
+	// Picks unsubscribed by something else (idle eviction, a command) are no longer the scanner's
+	s.mu.Lock()
+	owned := make(map[string]bool, len(s.owned))
+	for sym := range s.owned {
+		if active[sym] {
+			owned[sym] = true
+		} else {
+			delete(s.owned, sym)
+		}
+	}
+	s.mu.Unlock()
+
+	// Candidates: everything screened that isn't streamed for another reason, plus the current picks
+	seen := make(map[string]bool)
+	var symbols []string
+	add := func(sym string) {
+		sym = strings.ToUpper(strings.TrimSpace(sym))
+		if sym == "" || seen[sym] || (active[sym] && !owned[sym]) {
+			return
+		}
+		seen[sym] = true
+		symbols = append(symbols, sym)
+	}
+	movers, moversErr := s.screener.GetMovers(s.cfg.Top)
+	if moversErr != nil {
+		slog.Warn("universe scan: movers failed", "err", moversErr)
+	} else {
+		for _, m := range append(movers.Gainers, movers.Losers...) {
+			add(m.Symbol)
+		}
+	}
+	actives, activesErr := s.screener.GetMostActives("volume", s.cfg.Top)
+	if activesErr != nil {
+		slog.Warn("universe scan: most actives failed", "err", activesErr)
+	}
+	for _, m := range actives {
+		add(m.Symbol)
+	}
+	if moversErr != nil && activesErr != nil {
+		return
+	}
+	for sym := range owned {
+		add(sym)
+	}
+	var snaps map[string]alpaca.SnapshotData
+	if len(symbols) > 0 {
+		var err error
+		if snaps, err = s.snapshots(symbols); err != nil {
+			slog.Warn("universe scan: snapshots failed", "err", err)
+			return
+		}
+	}
+
+	var cands []scanCandidate
+	for _, sym := range symbols {
+		snap := snaps[sym]
+		if ok, why := passes(snap, s.cfg.MinPrice, s.cfg.MinVolume); !ok {
+			slog.Debug("universe scan: candidate filtered", "symbol", sym, "reason", why)
+			continue
+		}
+		cands = append(cands, scanCandidate{symbol: sym, volume: dayVolume(snap), rng: dayRange(snap)})
+	}
+	rankCandidates(cands)
+	picked := make(map[string]bool)
+	for i := 0; i < len(cands) && i < s.cfg.MaxSymbols; i++ {
+		picked[cands[i].symbol] = true
+	}
+
+	var added, removed []string
+	for _, c := range cands {
+		if picked[c.symbol] && !owned[c.symbol] {
+			added = append(added, c.symbol)
+		}
+	}
+	for sym := range owned {
+		if picked[sym] || (s.Keep != nil && s.Keep(sym)) {
+			continue
+		}
+		removed = append(removed, sym)
+	}
+	sort.Strings(removed)
+	s.mu.Lock()
+	for _, sym := range added {
+		s.owned[sym] = true
+	}
+	for _, sym := range removed {
+		delete(s.owned, sym)
+	}
+	s.mu.Unlock()
+	if len(removed) > 0 {
+		if err := s.stream.Unsubscribe(removed); err != nil {
+			slog.Warn("universe scan: unsubscribe failed; symbols drop on reconnect", "err", err)
+		}
+	}
+	if len(added) > 0 {
+		if err := s.stream.Subscribe(added); err != nil {
+			slog.Warn("universe scan: subscribe failed; symbols are added on reconnect", "err", err)
+		}
+	}
+	slog.Info("universe scan", "candidates", len(cands), "streamed", len(picked), "added", added, "removed", removed)
+	if (len(added) > 0 || len(removed) > 0) && s.OnChange != nil {
+		s.OnChange(events.UniverseEvent{Symbols: s.stream.Symbols(), Added: added, Removed: removed, Reason: "scanner"})
+	}
+}
+
+// Run scans at once and then every cfg.Interval until ctx is done.
+func (s *Scanner) Run(ctx context.Context) {
+	s.Scan()
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Scan()
+		}
+	}
+}
+
+// dayVolume is today's volume from a snapshot.
+func dayVolume(snap alpaca.SnapshotData) float64 {
+	if snap.DailyBar == nil {
+		return 0
+	}
+	return float64(snap.DailyBar.Volume)
+}
+
+// dayRange is today's high-low range over the previous close, or the move from the previous close to
+// the last trade when that is larger (a gap isn't inside today's range).
+func dayRange(snap alpaca.SnapshotData) float64 {
+	ref := 0.0
+	if snap.PrevDailyBar != nil {
+		ref = snap.PrevDailyBar.Close
+	}
+	if ref <= 0 && snap.DailyBar != nil {
+		ref = snap.DailyBar.Open
+	}
+	if ref <= 0 {
+		return 0
+	}
+	var r float64
+	if snap.DailyBar != nil {
+		r = (snap.DailyBar.High - snap.DailyBar.Low) / ref
+	}
+	if snap.PrevDailyBar != nil && snap.LatestTrade != nil {
+		r = max(r, math.Abs(snap.LatestTrade.Price/ref-1))
+	}
+	return r
+}
+
+// rankCandidates scores each candidate by the mean of its volume and range percentiles and sorts them
+// best first (ties by symbol).
+func rankCandidates(cands []scanCandidate) {
+	n := len(cands)
+	if n == 0 {
+		return
+	}
+	percentile := func(less func(i, j int) bool) []float64 {
+		idx := make([]int, n)
+		for i := range idx {
+			idx[i] = i
+		}
+		sort.SliceStable(idx, func(a, b int) bool { return less(idx[a], idx[b]) })
+		p := make([]float64, n)
+		for rank, i := range idx {
+			if n > 1 {
+				p[i] = float64(rank) / float64(n-1)
+			} else {
+				p[i] = 1
+			}
+		}
+		return p
+	}
+	vol := percentile(func(i, j int) bool { return cands[i].volume < cands[j].volume })
+	rng := percentile(func(i, j int) bool { return cands[i].rng < cands[j].rng })
+	for i := range cands {
+		cands[i].score = (vol[i] + rng[i]) / 2
+	}
+	sort.Slice(cands, func(i, j int) bool {
+		if cands[i].score != cands[j].score {
+			return cands[i].score > cands[j].score
+		}
+		return cands[i].symbol < cands[j].symbol
+	})
+}
@@ -0,0 +1,252 @@
+// Package webhook accepts trading signals from external systems (TradingView alerts, internal scanners)
+// over HTTP, so they reach the brain through the same pipeline as everything else, as external_signal
+// events.
+package webhook
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sunnyp94/sentry-bridge/go-engine/events"
+)
+
+const (
+	maxBody         = 64 << 10         // request bodies larger than this are refused
+	maxAge          = 5 * time.Minute  // signals timestamped longer ago than this are refused
+	maxSkew         = 30 * time.Second // and so are those this far in the future
+	shutdownTimeout = 5 * time.Second
+)
+
+// symbolPattern is a normalized US equity symbol (BRK.B, BF-B).
+var symbolPattern = regexp.MustCompile(`^[A-Z][A-Z0-9.\-]{0,9}$`)
+
+// Server takes signals as JSON on POST /signal. The shared token goes in an Authorization: Bearer
+// header, a token query parameter, or a "token" field in the body (TradingView alerts can only set the
+// URL and body). Accepted fields, with aliases:
+//
+//	symbol | ticker            "AAPL" or "NASDAQ:AAPL" (exchange prefix dropped); required
+//	side | action             buy/long, sell/short or close/exit/flat; optional
+//	strength | conviction | score   number
+//	price                     number or numeric string
+//	source | strategy         default "webhook"
+//	id, comment | message, time | timestamp (RFC 3339 or Unix seconds/milliseconds)
+//
+// Other fields are passed through in extra.
+type Server struct {
+	token string
+	lis   net.Listener
+	srv   *http.Server
+
+	// OnSignal receives every accepted signal. Set before Run.
+	OnSignal func(events.ExternalSignalEvent)
+}
+
+// NewServer listens on addr; token is required.
+func NewServer(addr, token string) (*Server, error) {
+	if token == "" {
+		return nil, errors.New("webhook: token required")
+	}
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("webhook: %w", err)
+	}
+	s := &Server{token: token, lis: lis}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/signal", s.handle)
+	s.srv = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second, ReadTimeout: 30 * time.Second}
+	return s, nil
+}
+
+// Addr is the address the server listens on.
+func (s *Server) Addr() string { return s.lis.Addr().String() }
+
+// Run serves until ctx is done, then shuts the server down.
+func (s *Server) Run(ctx context.Context) {
+	go func() {
+		<-ctx.Done()
+		sctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		_ = s.srv.Shutdown(sctx)
+	}()
+	if err := s.srv.Serve(s.lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		slog.Error("webhook server stopped", "err", err)
+	}
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		s.reply(w, http.StatusMethodNotAllowed, errors.New("POST only"), nil)
+		return
+	}
+	var body map[string]interface{}
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBody))
+	dec.UseNumber()
+	if err := dec.Decode(&body); err != nil {
+		s.reply(w, http.StatusBadRequest, fmt.Errorf("body must be a JSON object: %w", err), nil)
+		return
+	}
+	if !s.authorized(r, body) {
+		s.reply(w, http.StatusUnauthorized, errors.New("bad or missing token"), nil)
+		return
+	}
+	delete(body, "token")
+	ev, err := normalize(body, time.Now())
+	if err != nil {
+		s.reply(w, http.StatusBadRequest, err, nil)
+		return
+	}
+	slog.Info("external signal", "source", ev.Source, "symbol", ev.Symbol, "side", ev.Side, "id", ev.ID, "remote", r.RemoteAddr)
+	if s.OnSignal != nil {
+		s.OnSignal(ev)
+	}
+	s.reply(w, http.StatusAccepted, nil, &ev)
+}
+
+// authorized checks the token from the header, the query string or the body.
+func (s *Server) authorized(r *http.Request, body map[string]interface{}) bool {
+	got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if got == "" {
+		got = r.URL.Query().Get("token")
+	}
+	if got == "" {
+		got, _ = body["token"].(string)
+	}
+	return subtle.ConstantTimeCompare([]byte(got), []byte(s.token)) == 1
+}
+
+func (s *Server) reply(w http.ResponseWriter, status int, err error, ev *events.ExternalSignalEvent) {
+	resp := map[string]interface{}{"accepted": err == nil}
+	if err != nil {
+		resp["error"] = err.Error()
+		slog.Warn("external signal rejected", "status", status, "err", err)
+	} else {
+		resp["signal"] = ev
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// normalize validates a signal body and maps its aliases onto the event; known fields are removed from
+// body and what is left becomes Extra.
+func normalize(body map[string]interface{}, now time.Time) (events.ExternalSignalEvent, error) {
+	take := func(names ...string) (interface{}, bool) {
+		for _, n := range names {
+			if v, ok := body[n]; ok && v != nil && v != "" {
+				for _, m := range names {
+					delete(body, m)
+				}
+				return v, true
+			}
+		}
+		return nil, false
+	}
+	str := func(names ...string) string {
+		v, _ := take(names...)
+		if v == nil {
+			return ""
+		}
+		return strings.TrimSpace(fmt.Sprint(v))
+	}
+	ev := events.ExternalSignalEvent{Received: now.UTC().Format(time.RFC3339Nano)}
+
+	ev.Symbol = strings.ToUpper(str("symbol", "ticker"))
+	if i := strings.LastIndex(ev.Symbol, ":"); i >= 0 {
+		ev.Symbol = ev.Symbol[i+1:]
+	}
+	if !symbolPattern.MatchString(ev.Symbol) {
+		return ev, fmt.Errorf("symbol %q is not a valid symbol", ev.Symbol)
+	}
+	switch side := strings.ToLower(str("side", "action")); side {
+	case "":
+	case "buy", "long":
+		ev.Side = "buy"
+	case "sell", "short":
+		ev.Side = "sell"
+	case "close", "exit", "flat":
+		ev.Side = "close"
+	default:
+		return ev, fmt.Errorf("side %q must be buy/long, sell/short or close/exit/flat", side)
+	}
+	if v, ok := take("strength", "conviction", "score"); ok {
+		f, err := number(v)
+		if err != nil {
+			return ev, fmt.Errorf("strength: %w", err)
+		}
+		ev.Strength = &f
+	}
+	if v, ok := take("price"); ok {
+		f, err := number(v)
+		if err != nil || f < 0 {
+			return ev, fmt.Errorf("price %v is not a non-negative number", v)
+		}
+		ev.Price = f
+	}
+	ev.Source = str("source", "strategy")
+	if ev.Source == "" {
+		ev.Source = "webhook"
+	}
+	ev.ID = str("id")
+	ev.Comment = str("comment", "message")
+	if v, ok := take("time", "timestamp"); ok {
+		t, err := signalTime(v)
+		if err != nil {
+			return ev, err
+		}
+		if age := now.Sub(t); age > maxAge || age < -maxSkew {
+			return ev, fmt.Errorf("signal time %s is outside the accepted window (%s old at most)", t.UTC().Format(time.RFC3339), maxAge)
+		}
+		ev.Time = t.UTC().Format(time.RFC3339Nano)
+	}
+	if len(body) > 0 {
+		ev.Extra = body
+	}
+	return ev, nil
+}
+
+// number reads a JSON number or numeric string.
+func number(v interface{}) (float64, error) {
+	var f float64
+	var err error
+	switch x := v.(type) {
+	case json.Number:
+		f, err = x.Float64()
+	case string:
+		f, err = strconv.ParseFloat(strings.TrimSpace(x), 64)
+	default:
+		err = fmt.Errorf("%v is not a number", v)
+	}
+	if err == nil && (math.IsNaN(f) || math.IsInf(f, 0)) {
+		err = fmt.Errorf("%v is not a finite number", v)
+	}
+	return f, err
+}
+
+// signalTime reads RFC 3339 or Unix seconds/milliseconds (values above 1e12 are milliseconds).
+func signalTime(v interface{}) (time.Time, error) {
+	if s, ok := v.(string); ok {
+		if t, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(s)); err == nil {
+			return t, nil
+		}
+	}
+	f, err := number(v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("time %v is not RFC 3339 or Unix seconds/milliseconds", v)
+	}
+	if f > 1e12 {
+		return time.UnixMilli(int64(f)), nil
+	}
+	return time.Unix(int64(f), 0), nil
+}
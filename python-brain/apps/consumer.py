@@ -44,6 +44,7 @@ from brain.rules.drawdown import update_drawdown_peak, is_drawdown_halt
 from brain.market_calendar import is_full_trading_day
 from brain import config as brain_config
 from brain.core.parse_utils import parse_unrealized_plpc
+from brain.core import wire
 try:
     from brain.execution.smart_position_management import is_morning_flush, run_eod_prune, is_eod_prune_time
 except ImportError:
@@ -55,6 +56,9 @@ except ImportError:
         return False
 from brain.discovery import run_discovery, DiscoveryEngine, _parse_et_time as discovery_parse_et
 
+# ID of the event being handled; orders placed while handling it carry it back as their correlation ID
+_current_event_id: Optional[str] = None
+
 # OFI (Order Flow Imbalance) from live trade/quote when USE_OFI=true (brain.signals.microstructure.OFITracker)
 _ofi_tracker: Optional[object] = None
 
@@ -190,6 +194,8 @@ _scaled_50_at_vwap: set = set()
 _scale_out_done: dict[str, set] = {}
 # Session: default "regular" so buys allowed unless pipe sends session=pre_open (or other). Only explicit non-regular blocks.
 session_by_symbol: dict[str, str] = defaultdict(lambda: "regular")
+# Symbols halted or LULD-paused (halt/resume events from Go): no new orders until trading resumes
+halted_symbols: set = set()
 ORDER_COOLDOWN_SEC = getattr(brain_config, "ORDER_COOLDOWN_SEC", 30)
 last_order_time_by_symbol: dict[str, float] = {}
 # Rolling price history per symbol for RSI/technical (when USE_TECHNICAL_INDICATORS=true)
@@ -331,7 +337,7 @@ def _try_place_order(
     for h in logging.getLogger().handlers:
         if getattr(h, "flush", None):
             h.flush()
-    if place_order(d, current_price=price):
+    if place_order(d, current_price=price, correlation_id=_current_event_id):
         last_order_time_by_symbol[d.symbol] = now
         # Optimistic update: count this order as position so we don't double-buy before next positions event
         if d.action == "buy":
@@ -554,6 +560,9 @@ def run_strategy_for_symbols(symbols: list) -> None:
     drawdown_halt = is_drawdown_halt()
     t0 = _PERF()
     for sym in symbols:
+        if sym in halted_symbols:
+            log.debug("skip strategy symbol=%s: trading halted", sym)
+            continue
         combined = dict(last_payload_by_symbol.get(sym, {}))
         combined.setdefault("return_1m", 0)
         combined.setdefault("return_5m", 0)
@@ -642,6 +651,8 @@ def run_strategy_on_news(payload: dict) -> None:
 
 def handle_event(ev: dict) -> None:
     """Update state from event and run strategy/stop-loss when relevant (news, positions)."""
+    global _current_event_id
+    _current_event_id = ev.get("id") or None
     typ = ev.get("type", "?")
     payload = ev.get("payload") or {}
     # Single gate: trading logic only on full trading days (market_calendar: weekends, holidays, half-days excluded).
@@ -689,7 +700,15 @@ def handle_event(ev: dict) -> None:
             mid = payload.get("mid")
             if mid is not None and isinstance(mid, (int, float)) and mid > 0:
                 price_history_by_symbol[sym].append(float(mid))
-    elif typ == "volatility":
+    elif typ in ("halt", "resume"):
+        sym = payload.get("symbol")
+        if sym:
+            if typ == "halt":
+                halted_symbols.add(sym)
+            else:
+                halted_symbols.discard(sym)
+            log.warning("%s symbol=%s status=%s reason=%s", typ, sym, payload.get("status"), payload.get("reason"))
+    elif typ in ("volatility", "implied_vol"):
         sym = payload.get("symbol")
         if sym:
             last_payload_by_symbol[sym] = {**last_payload_by_symbol.get(sym, {}), **payload}
@@ -1003,7 +1022,13 @@ def main() -> None:
     if ZoneInfo:
         threading.Thread(target=_optimizer_scheduler_loop, daemon=True).start()
 
-    log.info("reading from stdin (NDJSON)")
+    if wire.transport() == "grpc":
+        link = wire.GrpcLink()
+        log.info("streaming from engine over gRPC (%s)", link.target)
+        stream = link.events()  # sends the ready handshake on every (re)connect
+    else:
+        log.info("reading from stdin (BRAIN_ENCODING=%s)", wire.encoding())
+        stream = None
     if os.environ.get("APCA_API_KEY_ID") or os.environ.get("ALPACA_API_KEY_ID"):
         paper = os.environ.get("TRADE_PAPER", "true").lower() in ("true", "1", "yes")
         live_ok = os.environ.get("LIVE_TRADING_ENABLED", "").lower() in ("true", "1", "yes")
@@ -1012,18 +1037,16 @@ def main() -> None:
     else:
         log.info("No Alpaca keys; strategy will log decisions only (no orders)")
 
-    for line in sys.stdin:
-        line = line.strip()
-        if not line:
-            continue
+    if stream is None:
+        # Handshake: engine holds the stream until this line, then sends a "snapshot" event first.
+        print(json.dumps({"type": "ready"}), flush=True)
+        stream = wire.read_events(sys.stdin)
+    for ev in stream:
         try:
-            ev = json.loads(line)
             log_event(ev)
             t0 = _PERF()
             handle_event(ev)
             log.debug("latency step=event_handle type=%s ms=%.1f", ev.get("type", "?"), (_PERF() - t0) * 1000)
-        except json.JSONDecodeError as e:
-            log.error("invalid JSON: %s", e)
         except Exception as e:
             log.exception("error processing event")
 
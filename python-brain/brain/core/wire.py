@@ -0,0 +1,241 @@
+"""
+Decoders for the engine's stdin encodings (BRAIN_ENCODING, set by the Go engine in our environment).
+json: one envelope per line. msgpack / protobuf: 4-byte big-endian length, then the encoded envelope
+(protobuf schema: go-engine/brain/brain.proto). Every encoding yields the same dict as the JSON line.
+With BRAIN_TRANSPORT=grpc the brain runs on its own and connects to the engine instead (GrpcLink).
+"""
+import json
+import logging
+import os
+import queue
+import struct
+import time
+from typing import Any, Dict, IO, Iterator
+
+log = logging.getLogger(__name__)
+
+# brain.proto field number -> (name, kind) for the native messages; other event types arrive as JSON.
+_TRADE_FIELDS = {
+    1: ("symbol", "str"), 2: ("price", "f64"), 3: ("size", "int"), 4: ("volume_1m", "int"),
+    5: ("volume_5m", "int"), 6: ("return_1m", "f64"), 7: ("return_5m", "f64"), 8: ("session", "str"),
+    9: ("volatility", "f64"), 10: ("features", "f64s"), 11: ("feature_schema", "int"), 12: ("model_score", "f64"),
+    13: ("exchange_ts", "str"), 14: ("received_ts", "str"), 15: ("stale", "bool"), 16: ("age_ms", "f64"),
+    17: ("conditions", "strs"), 18: ("exchange", "str"), 19: ("tape", "str"), 20: ("expiry_context", "str"),
+    21: ("vol_version", "int"),
+}
+_QUOTE_FIELDS = {
+    1: ("symbol", "str"), 2: ("bid", "f64"), 3: ("ask", "f64"), 4: ("bid_size", "int"), 5: ("ask_size", "int"),
+    6: ("mid", "f64"), 7: ("volume_1m", "int"), 8: ("volume_5m", "int"), 9: ("return_1m", "f64"),
+    10: ("return_5m", "f64"), 11: ("session", "str"), 12: ("volatility", "f64"), 13: ("features", "f64s"),
+    14: ("feature_schema", "int"), 15: ("model_score", "f64"), 16: ("spread_bps", "f64"),
+    17: ("exchange_ts", "str"), 18: ("received_ts", "str"), 19: ("stale", "bool"), 20: ("age_ms", "f64"),
+    21: ("halted", "bool"), 22: ("expiry_context", "str"), 23: ("vol_version", "int"),
+}
+# Fields the JSON encoding always includes (proto3 omits zero values).
+_OPTIONAL = (
+    "features", "feature_schema", "model_score", "exchange_ts", "received_ts", "stale", "age_ms",
+    "conditions", "exchange", "tape", "halted", "expiry_context", "vol_version",
+)
+
+
+def encoding() -> str:
+    return (os.environ.get("BRAIN_ENCODING") or "json").strip().lower()
+
+
+def transport() -> str:
+    return (os.environ.get("BRAIN_TRANSPORT") or "pipe").strip().lower()
+
+
+def read_events(stdin: IO, enc: str = "") -> Iterator[Dict[str, Any]]:
+    """Yield decoded envelopes from the engine until EOF; undecodable JSON lines are logged and skipped."""
+    enc = enc or encoding()
+    if enc == "json":
+        for line in stdin:
+            line = line.strip()
+            if not line:
+                continue
+            try:
+                yield json.loads(line)
+            except json.JSONDecodeError as e:
+                log.error("invalid JSON: %s", e)
+        return
+    if enc == "msgpack":
+        import msgpack  # only needed for BRAIN_ENCODING=msgpack
+
+        decode = lambda b: msgpack.unpackb(b, raw=False, timestamp=3)
+    elif enc == "protobuf":
+        decode = decode_protobuf
+    else:
+        raise ValueError("unknown BRAIN_ENCODING %r" % enc)
+    stream = stdin.buffer if hasattr(stdin, "buffer") else stdin
+    while True:
+        hdr = stream.read(4)
+        if len(hdr) < 4:
+            return
+        (n,) = struct.unpack(">I", hdr)
+        body = stream.read(n)
+        if len(body) < n:
+            return
+        yield decode(body)
+
+
+def _varint(b: bytes, i: int):
+    shift = result = 0
+    while True:
+        c = b[i]
+        i += 1
+        result |= (c & 0x7F) << shift
+        if c < 0x80:
+            return result, i
+        shift += 7
+
+
+def _fields(b: bytes):
+    """Yield (field number, wire type, value) for one message; length-delimited values are bytes."""
+    i = 0
+    while i < len(b):
+        key, i = _varint(b, i)
+        num, wt = key >> 3, key & 7
+        if wt == 0:
+            v, i = _varint(b, i)
+        elif wt == 1:
+            v, i = b[i:i + 8], i + 8
+        elif wt == 2:
+            n, i = _varint(b, i)
+            v, i = b[i:i + n], i + n
+        elif wt == 5:
+            v, i = b[i:i + 4], i + 4
+        else:
+            raise ValueError("unsupported protobuf wire type %d" % wt)
+        yield num, wt, v
+
+
+def _message(b: bytes, spec) -> Dict[str, Any]:
+    out: Dict[str, Any] = {}
+    for num, wt, v in _fields(b):
+        if num not in spec:
+            continue
+        name, kind = spec[num]
+        if kind == "str":
+            out[name] = v.decode("utf-8")
+        elif kind == "int":
+            out[name] = v - (1 << 64) if v >= 1 << 63 else v
+        elif kind == "strs":
+            out.setdefault(name, []).append(v.decode("utf-8"))
+        elif kind == "bool":
+            out[name] = bool(v)
+        elif kind == "f64":
+            out[name] = struct.unpack("<d", v)[0]
+        elif kind == "f64s":
+            out[name] = list(struct.unpack("<%dd" % (len(v) // 8), v)) if wt == 2 else [struct.unpack("<d", v)[0]]
+    for name, kind in spec.values():
+        if name not in out and name not in _OPTIONAL:
+            out[name] = "" if kind == "str" else 0
+    return out
+
+
+def decode_protobuf(b: bytes) -> Dict[str, Any]:
+    ev: Dict[str, Any] = {"type": "", "ts": "", "payload": None}
+    for num, _, v in _fields(b):
+        if num == 1:
+            ev["type"] = v.decode("utf-8")
+        elif num == 2:
+            ev["ts"] = v.decode("utf-8")
+        elif num == 3:
+            ev["payload"] = json.loads(v)
+        elif num == 4:
+            ev["payload"] = _message(v, _TRADE_FIELDS)
+        elif num == 5:
+            ev["payload"] = _message(v, _QUOTE_FIELDS)
+        elif num == 6:
+            ev.setdefault("tags", []).append(v.decode("utf-8"))
+        elif num == 7:
+            ev["expires"] = v.decode("utf-8")
+        elif num == 8:
+            ev["seq"] = v
+        elif num == 9:
+            ev["id"] = v.decode("utf-8")
+    return ev
+
+
+def encode_decision(msg: Dict[str, Any]) -> bytes:
+    """brain.proto Decision for a message a stdin brain would print ({"type": "ready"}, requests)."""
+    out = bytearray()
+    for num, key in ((1, "type"), (2, "id"), (3, "method")):
+        v = str(msg.get(key) or "").encode("utf-8")
+        if v:
+            out += _tag_bytes(num, v)
+    if msg.get("params") is not None:
+        out += _tag_bytes(4, json.dumps(msg["params"]).encode("utf-8"))
+    return bytes(out)
+
+
+def _tag_bytes(num: int, v: bytes) -> bytes:
+    return _uvarint(num << 3 | 2) + _uvarint(len(v)) + v
+
+
+def _uvarint(n: int) -> bytes:
+    out = bytearray()
+    while n >= 0x80:
+        out.append(n & 0x7F | 0x80)
+        n >>= 7
+    out.append(n)
+    return bytes(out)
+
+
+class GrpcLink:
+    """
+    Stream from the engine's Brain service (BRAIN_GRPC_TARGET, default localhost:50051). events() sends
+    the ready handshake on every (re)connect and reconnects after the engine goes away; send() queues a
+    Decision (e.g. a request) on the current stream. BRAIN_GRPC_TOKEN is sent as a bearer token; with
+    BRAIN_GRPC_TLS=true (or a CA file in BRAIN_GRPC_TLS_CA) the channel uses TLS.
+    """
+
+    RECONNECT_SEC = 5
+
+    def __init__(self, target: str = ""):
+        self.target = target or os.environ.get("BRAIN_GRPC_TARGET") or "localhost:50051"
+        self.token = os.environ.get("BRAIN_GRPC_TOKEN", "")
+        self.tls_ca = os.environ.get("BRAIN_GRPC_TLS_CA", "").strip()
+        self.tls = self.tls_ca != "" or os.environ.get("BRAIN_GRPC_TLS", "").strip().lower() in ("1", "true", "yes")
+        self._out: "queue.Queue" = queue.Queue()
+
+    def send(self, msg: Dict[str, Any]) -> None:
+        self._out.put(encode_decision(msg))
+
+    def _decisions(self) -> Iterator[bytes]:
+        while True:
+            b = self._out.get()
+            if b is None:
+                return
+            yield b
+
+    def _channel(self, grpc: Any) -> Any:
+        if not self.tls:
+            return grpc.insecure_channel(self.target)
+        roots = None
+        if self.tls_ca:
+            with open(self.tls_ca, "rb") as f:
+                roots = f.read()
+        return grpc.secure_channel(self.target, grpc.ssl_channel_credentials(root_certificates=roots))
+
+    def events(self) -> Iterator[Dict[str, Any]]:
+        import grpc  # only needed for BRAIN_TRANSPORT=grpc
+
+        while True:
+            self._out = queue.Queue()
+            self.send({"type": "ready"})
+            out = self._out
+            with self._channel(grpc) as channel:
+                # No serializers: messages are the raw brain.proto bytes.
+                metadata = [("authorization", "Bearer " + self.token)] if self.token else None
+                call = channel.stream_stream("/sentry.brain.Brain/Stream")(self._decisions(), metadata=metadata)
+                try:
+                    for b in call:
+                        yield decode_protobuf(b)
+                    log.warning("engine ended the stream; reconnecting in %ss", self.RECONNECT_SEC)
+                except grpc.RpcError as e:
+                    log.warning("engine stream to %s failed (%s); reconnecting in %ss", self.target, e.code(), self.RECONNECT_SEC)
+                finally:
+                    out.put(None)
+            time.sleep(self.RECONNECT_SEC)
@@ -6,6 +6,7 @@ Otherwise market orders. Also exposes get_account_equity() for rules.
 import logging
 import os
 import time
+import uuid
 from typing import Any, Dict, List, Optional
 
 from brain.core import config
@@ -111,9 +112,19 @@ def _get_latest_quote_price(symbol: str) -> Optional[float]:
         return None
 
 
-def place_order(decision: Decision, current_price: Optional[float] = None) -> bool:
+def _client_order_id(correlation_id: Optional[str]) -> Optional[str]:
+    """Client order ID carrying the correlation ID (the ID of the event behind the order) so the engine can tie
+    the order's trade updates back to it: ev:<correlation_id>:<nonce>. None when there is none or it won't fit."""
+    if not correlation_id:
+        return None
+    coid = f"ev:{correlation_id}:{uuid.uuid4().hex[:8]}"
+    return coid if len(coid) <= 128 else None
+
+
+def place_order(decision: Decision, current_price: Optional[float] = None, correlation_id: Optional[str] = None) -> bool:
     """
     Place order for the given decision. Returns True if submitted.
+    correlation_id (the ID of the event that triggered the decision) is echoed in the order's client_order_id.
     When USE_LIMIT_ORDERS=true and current_price is set: submit limit order
     (buy below mid, sell above mid by LIMIT_ORDER_OFFSET_BPS) to reduce slippage.
     Otherwise submit market order.
@@ -149,6 +160,7 @@ def place_order(decision: Decision, current_price: Optional[float] = None) -> bo
     price_str = f"{current_price:.2f}" if current_price is not None and current_price > 0 else "market"
     log.info("place_order %s %s qty=%d price=%s", decision.action.upper(), decision.symbol, qty, price_str)
     side = OrderSide.BUY if decision.action == "buy" else OrderSide.SELL
+    client_order_id = _client_order_id(correlation_id)
     use_limit = config.USE_LIMIT_ORDERS and current_price is not None and current_price > 0 and LimitOrderRequest is not None
     try:
         t0 = time.perf_counter()
@@ -165,6 +177,7 @@ def place_order(decision: Decision, current_price: Optional[float] = None) -> bo
                 side=side,
                 time_in_force=TimeInForce.DAY,
                 limit_price=limit_price,
+                client_order_id=client_order_id,
             )
             order = client.submit_order(req)
             log.debug(
@@ -177,6 +190,7 @@ def place_order(decision: Decision, current_price: Optional[float] = None) -> bo
                 qty=qty,
                 side=side,
                 time_in_force=TimeInForce.DAY,
+                client_order_id=client_order_id,
             )
             order = client.submit_order(req)
             log.debug(